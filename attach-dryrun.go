@@ -0,0 +1,345 @@
+package libbpfgo
+
+import (
+	"bufio"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//
+// Attach dry runs
+//
+// Deploy pipelines check that the programs of a module can be attached on a
+// host before rolling it out. A dry run validates an attach spec without
+// attaching anything: the target exists (kernel function, binary and symbol,
+// tracepoint, interface or cgroup), the program type fits the spec, and the
+// process has the capabilities to attach it. It reports the resolved target:
+//
+//	report := m.DryRunAttach(map[string][]string{
+//		"trace_connect":  {"kprobe:tcp_connect"},
+//		"trace_readline": {"uretprobe:bash:readline"},
+//	})
+//	if err := report.Err(); err != nil {
+//		log.Fatalf("preflight failed:\n%s", report)
+//	}
+//
+// The checks cover the usual causes of attach failures, they do not
+// guarantee the attach: the kernel may still refuse it (exclusive attachments,
+// LSMs, ...).
+//
+
+// AttachCheck is the result of the dry run of an attach spec.
+type AttachCheck struct {
+	Program string
+	Spec    AttachSpec
+	// Target is the resolved target: kernel function (and its module),
+	// binary path and symbol, tracefs directory, interface or cgroup.
+	Target string
+	// Err is the reason the attach would fail, nil if none was found.
+	Err error
+}
+
+func (c AttachCheck) String() string {
+	if c.Err != nil {
+		return fmt.Sprintf("%s %s: %v", c.Program, c.Spec, c.Err)
+	}
+
+	return fmt.Sprintf("%s %s: ok (%s)", c.Program, c.Spec, c.Target)
+}
+
+// AttachReport is the result of the dry run of attach specs.
+type AttachReport []AttachCheck
+
+// Err returns the errors of the failed checks, joined, or nil.
+func (r AttachReport) Err() error {
+	var errs []error
+	for _, c := range r {
+		if c.Err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", c.Program, c.Spec, c.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r AttachReport) String() string {
+	var b strings.Builder
+	for _, c := range r {
+		b.WriteString(c.String())
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// DryRunAttach checks the attach specs of the programs, by name, without
+// attaching them. The checks are sorted by program name, then in the order
+// of their specs.
+func (m *Module) DryRunAttach(specs map[string][]string) AttachReport {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var report AttachReport
+	for _, name := range names {
+		prog, err := m.GetProgram(name)
+		for _, spec := range specs[name] {
+			if err != nil {
+				report = append(report, AttachCheck{Program: name, Err: err})
+				continue
+			}
+			report = append(report, prog.DryRunAttachBySpec(spec))
+		}
+	}
+
+	return report
+}
+
+// DryRunAttachBySpec checks the attach spec of the program (see
+// AttachBySpec()) without attaching it.
+func (p *BPFProg) DryRunAttachBySpec(spec string) AttachCheck {
+	check := AttachCheck{Program: p.Name()}
+
+	s, err := ParseAttachSpec(spec)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	check.Spec = s
+
+	if progTypes := attachSpecProgTypes[s.Type]; !slices.Contains(progTypes, p.GetType()) {
+		check.Err = fmt.Errorf("program type %s does not fit %s: %w", p.GetType(), s, syscall.EINVAL)
+		return check
+	}
+
+	check.Target, check.Err = checkAttachTarget(s)
+	if check.Err == nil {
+		check.Err = checkAttachCapabilities(s.Type, readCapabilities())
+	}
+
+	return check
+}
+
+// attachSpecProgTypes are the program types attached by each attach spec
+// type.
+var attachSpecProgTypes = map[LinkType][]BPFProgType{
+	Kprobe:        {BPFProgTypeKprobe},
+	Kretprobe:     {BPFProgTypeKprobe},
+	Uprobe:        {BPFProgTypeKprobe},
+	Uretprobe:     {BPFProgTypeKprobe},
+	Tracepoint:    {BPFProgTypeTracepoint},
+	RawTracepoint: {BPFProgTypeRawTracepoint, BPFProgTypeRawTracepointWritable},
+	XDP:           {BPFProgTypeXdp},
+	Cgroup: {
+		BPFProgTypeCgroupSkb,
+		BPFProgTypeCgroupSock,
+		BPFProgTypeCgroupDevice,
+		BPFProgTypeCgroupSockAddr,
+		BPFProgTypeCgroupSysctl,
+		BPFProgTypeCgroupSockopt,
+		BPFProgTypeSockOps,
+	},
+}
+
+var kallsymsPath = "/proc/kallsyms"
+
+// cgroup2SuperMagic is CGROUP2_SUPER_MAGIC, the file system type of cgroup
+// v2 directories.
+const cgroup2SuperMagic = 0x63677270
+
+// checkAttachTarget resolves the target of the attach spec, failing if it
+// does not exist.
+func checkAttachTarget(s AttachSpec) (string, error) {
+	switch s.Type {
+	case Kprobe, Kretprobe:
+		module, err := findKernelFunction(kallsymsPath, s.Target)
+		if err != nil {
+			return "", err
+		}
+		if module != "" {
+			return fmt.Sprintf("%s [%s]", s.Target, module), nil
+		}
+		return s.Target, nil
+	case Uprobe, Uretprobe:
+		return checkUprobeTarget(s)
+	case Tracepoint:
+		return findTracepoint(tracefsPaths(), s.Category, s.Target)
+	case RawTracepoint:
+		return findTracepoint(tracefsPaths(), "*", s.Target)
+	case XDP:
+		iface, err := net.InterfaceByName(s.Target)
+		if err != nil {
+			return "", fmt.Errorf("interface %s: %w", s.Target, err)
+		}
+		return fmt.Sprintf("%s (ifindex %d)", iface.Name, iface.Index), nil
+	case Cgroup:
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(s.Path, &stat); err != nil {
+			return "", fmt.Errorf("cgroup %s: %w", s.Path, err)
+		}
+		if stat.Type != cgroup2SuperMagic {
+			return "", fmt.Errorf("%s is not a cgroup v2 directory: %w", s.Path, syscall.ENOTDIR)
+		}
+		return s.Path, nil
+	}
+
+	return "", fmt.Errorf("unsupported attach spec %s: %w", s, syscall.EINVAL)
+}
+
+// findKernelFunction looks the function up in kallsyms, returning its module
+// ("" for vmlinux).
+func findKernelFunction(kallsyms string, name string) (string, error) {
+	f, err := os.Open(kallsyms)
+	if err != nil {
+		return "", fmt.Errorf("failed to look kernel function %s up: %w", name, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// address type name [module]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[2] != name {
+			continue
+		}
+		if fields[1] != "t" && fields[1] != "T" {
+			continue
+		}
+		if len(fields) > 3 {
+			return strings.Trim(fields[3], "[]"), nil
+		}
+		return "", nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to look kernel function %s up: %w", name, err)
+	}
+
+	return "", fmt.Errorf("kernel function %s: %w", name, fs.ErrNotExist)
+}
+
+// checkUprobeTarget resolves the binary of the uprobe spec as the attach
+// would, and looks its function up.
+func checkUprobeTarget(s AttachSpec) (string, error) {
+	path, err := uprobeTarget(s.Path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(path, "/") {
+		// Binaries are looked up in PATH
+		if path, err = exec.LookPath(path); err != nil {
+			return "", fmt.Errorf("binary %s: %w", s.Path, fs.ErrNotExist)
+		}
+	}
+
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("binary %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if s.Target == "" {
+		return fmt.Sprintf("%s:0x%x", path, s.Offset), nil
+	}
+
+	syms, _ := f.Symbols()
+	dynSyms, _ := f.DynamicSymbols()
+	for _, sym := range append(syms, dynSyms...) {
+		if elf.ST_TYPE(sym.Info) == elf.STT_FUNC && sym.Name == s.Target && sym.Section != elf.SHN_UNDEF {
+			return fmt.Sprintf("%s:%s", path, s.Target), nil
+		}
+	}
+
+	return "", fmt.Errorf("function %s in %s: %w", s.Target, path, fs.ErrNotExist)
+}
+
+// findTracepoint returns the tracefs directory of the tracepoint, category
+// being a pattern.
+func findTracepoint(tracefs []string, category, name string) (string, error) {
+	mounted := false
+	for _, root := range tracefs {
+		if _, err := os.Stat(filepath.Join(root, "events")); err != nil {
+			continue
+		}
+		mounted = true
+
+		matches, _ := filepath.Glob(filepath.Join(root, "events", category, name))
+		if len(matches) > 0 {
+			return matches[0], nil
+		}
+	}
+	if !mounted {
+		return "", fmt.Errorf("tracepoint %s: tracefs not mounted: %w", name, fs.ErrNotExist)
+	}
+
+	return "", fmt.Errorf("tracepoint %s:%s: %w", category, name, fs.ErrNotExist)
+}
+
+// readTracepointID returns the id of the tracepoint, which perf events of
+// type PERF_TYPE_TRACEPOINT are opened with, from its tracefs id file.
+func readTracepointID(tracefs []string, category, name string) (uint64, error) {
+	dir, err := findTracepoint(tracefs, category, name)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "id"))
+	if err != nil {
+		return 0, fmt.Errorf("tracepoint %s:%s: %w", category, name, err)
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("tracepoint %s:%s: invalid id %q", category, name, data)
+	}
+
+	return id, nil
+}
+
+// checkAttachCapabilities fails if the effective capabilities do not allow
+// the attach.
+func checkAttachCapabilities(linkType LinkType, caps capabilities) error {
+	if caps.has(capSysAdmin) {
+		return nil
+	}
+
+	// Perf events (kprobes, uprobes, tracepoints) and raw tracepoints need
+	// CAP_PERFMON, networking programs CAP_NET_ADMIN, all CAP_BPF
+	var needed []int
+	switch linkType {
+	case Kprobe, Kretprobe, Uprobe, Uretprobe, Tracepoint, RawTracepoint:
+		needed = []int{capBPF, capPerfmon}
+	case XDP, Cgroup:
+		needed = []int{capBPF, capNetAdmin}
+	}
+
+	var missing []string
+	for _, capability := range needed {
+		if !caps.has(capability) {
+			missing = append(missing, capabilityNames[capability])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s (or CAP_SYS_ADMIN): %w", strings.Join(missing, " and "), syscall.EPERM)
+	}
+
+	return nil
+}
+
+var capabilityNames = map[int]string{
+	capNetAdmin: "CAP_NET_ADMIN",
+	capSysAdmin: "CAP_SYS_ADMIN",
+	capPerfmon:  "CAP_PERFMON",
+	capBPF:      "CAP_BPF",
+}
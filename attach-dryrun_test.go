@@ -0,0 +1,126 @@
+package libbpfgo
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindKernelFunction(t *testing.T) {
+	kallsyms := filepath.Join(t.TempDir(), "kallsyms")
+	require.NoError(t, os.WriteFile(kallsyms, []byte(
+		"0000000000000000 T tcp_connect\n"+
+			"0000000000000000 D tcp_hashinfo\n"+
+			"0000000000000000 t nf_conntrack_in\t[nf_conntrack]\n",
+	), 0o644))
+
+	module, err := findKernelFunction(kallsyms, "tcp_connect")
+	require.NoError(t, err)
+	assert.Empty(t, module)
+
+	module, err = findKernelFunction(kallsyms, "nf_conntrack_in")
+	require.NoError(t, err)
+	assert.Equal(t, "nf_conntrack", module)
+
+	// Not a function
+	_, err = findKernelFunction(kallsyms, "tcp_hashinfo")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = findKernelFunction(kallsyms, "tcp_v4_connect")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestFindTracepoint(t *testing.T) {
+	root := t.TempDir()
+	tracefs := []string{filepath.Join(root, "missing"), root}
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "events", "syscalls", "sys_enter_openat"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "events", "sched", "sched_switch"), 0o755))
+
+	dir, err := findTracepoint(tracefs, "syscalls", "sys_enter_openat")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "events", "syscalls", "sys_enter_openat"), dir)
+
+	// Raw tracepoints are in any category
+	dir, err = findTracepoint(tracefs, "*", "sched_switch")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "events", "sched", "sched_switch"), dir)
+
+	_, err = findTracepoint(tracefs, "syscalls", "sys_enter_open2")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = findTracepoint(tracefs[:1], "syscalls", "sys_enter_openat")
+	assert.ErrorContains(t, err, "tracefs not mounted")
+}
+
+func TestReadTracepointID(t *testing.T) {
+	root := t.TempDir()
+	tracefs := []string{filepath.Join(root, "tracing"), filepath.Join(root, "debug", "tracing")}
+	dir := filepath.Join(tracefs[1], "events", "syscalls", "sys_enter_openat")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "id"), []byte("624\n"), 0o644))
+
+	id, err := readTracepointID(tracefs, "syscalls", "sys_enter_openat")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(624), id)
+
+	_, err = readTracepointID(tracefs, "syscalls", "sys_enter_open2")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "id"), []byte("abc\n"), 0o644))
+	_, err = readTracepointID(tracefs, "syscalls", "sys_enter_openat")
+	assert.ErrorContains(t, err, "invalid id")
+}
+
+func TestCheckUprobeTarget(t *testing.T) {
+	libc, err := ResolveLibrary("libc")
+	if err != nil {
+		t.Skip("libc not found")
+	}
+
+	// Looked up as the attach does
+	target, err := checkUprobeTarget(AttachSpec{Type: Uprobe, Path: "libc", Target: "malloc"})
+	require.NoError(t, err)
+	assert.Equal(t, libc+":malloc", target)
+
+	_, err = checkUprobeTarget(AttachSpec{Type: Uprobe, Path: libc, Target: "no_such_function"})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = checkUprobeTarget(AttachSpec{Type: Uprobe, Path: filepath.Join(t.TempDir(), "missing"), Target: "main"})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestCheckAttachCapabilities(t *testing.T) {
+	assert.NoError(t, checkAttachCapabilities(Kprobe, 1<<capSysAdmin))
+	assert.NoError(t, checkAttachCapabilities(Kprobe, 1<<capBPF|1<<capPerfmon))
+	assert.NoError(t, checkAttachCapabilities(XDP, 1<<capBPF|1<<capNetAdmin))
+
+	err := checkAttachCapabilities(Tracepoint, 1<<capBPF)
+	assert.ErrorIs(t, err, syscall.EPERM)
+	assert.ErrorContains(t, err, "missing CAP_PERFMON")
+
+	err = checkAttachCapabilities(Cgroup, 0)
+	assert.ErrorContains(t, err, "missing CAP_BPF and CAP_NET_ADMIN")
+}
+
+func TestAttachReport(t *testing.T) {
+	report := AttachReport{
+		{Program: "trace_connect", Spec: AttachSpec{Type: Kprobe, Target: "tcp_connect"}, Target: "tcp_connect"},
+		{Program: "trace_open", Spec: AttachSpec{Type: Tracepoint, Category: "syscalls", Target: "sys_enter_open2"}, Err: fs.ErrNotExist},
+	}
+
+	err := report.Err()
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.Equal(t, "trace_open tracepoint:syscalls:sys_enter_open2: file does not exist", err.Error())
+	assert.Equal(t,
+		"trace_connect kprobe:tcp_connect: ok (tcp_connect)\n"+
+			"trace_open tracepoint:syscalls:sys_enter_open2: file does not exist\n",
+		report.String())
+
+	assert.NoError(t, report[:1].Err())
+	assert.False(t, errors.Is(report[:1].Err(), fs.ErrNotExist))
+}
@@ -0,0 +1,274 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+)
+
+//
+// Attach options
+//
+// The Opts variants of the attach methods take optional parameters as
+// functional options, so that new kernel attach features become new options
+// instead of new methods:
+//
+//	prog.AttachKprobeOpts("tcp_connect", WithCookie(1), WithAttachMode(ProbeAttachModePerf))
+//	prog.AttachUprobeOpts("/bin/bash", WithFunc("readline"), WithPID(pid))
+//
+// Each attach point accepts only the options it supports, and fails with
+// the names of the others:
+//
+//	AttachKprobeOpts, AttachKretprobeOpts      WithCookie, WithOffset, WithAttachMode
+//	AttachKprobeOffsetOpts, AttachKretprobeOnOffsetOpts
+//	                                           WithCookie, WithAttachMode
+//	AttachKsyscall, AttachKretsyscall          WithCookie
+//	AttachUprobeOpts, AttachURetprobeOpts      WithCookie, WithOffset, WithAttachMode, WithPID, WithFunc
+//	AttachUprobeLibrary, AttachURetprobeLibrary
+//	                                           same
+//	AttachUSDT, AttachUSDTLibrary              WithCookie
+//	AttachTracepointOpts                       WithCookie
+//	AttachPerfEventOpts                        WithCookie, WithAttachMode
+//	AttachTCXOpts, AttachCgroupOpts, AttachCgroupFDOpts
+//	                                           WithBefore, WithAfter, WithExpectedRevision
+//
+// Multi-program hooks (TCX, cgroup on v6.12+) run their programs in order.
+// WithBefore and WithAfter place the new program relative to another one, so
+// that cooperating agents get a deterministic order:
+//
+//	prog.AttachTCXOpts("eth0", WithBefore(AnchorLink(firewallLink)))
+//	prog.AttachTCXOpts("eth0", WithAfter(AttachAnchor{})) // last
+//
+
+// ProbeAttachMode is the mechanism used to attach kprobes, uprobes and perf
+// events, as defined by libbpf's enum probe_attach_mode. Perf events have no
+// legacy mode.
+type ProbeAttachMode uint32
+
+const (
+	// ProbeAttachModeDefault lets libbpf pick the best mechanism supported.
+	ProbeAttachModeDefault ProbeAttachMode = C.PROBE_ATTACH_MODE_DEFAULT
+	// ProbeAttachModeLegacy creates the probe through tracefs, for kernels
+	// without perf kprobes and uprobes (before v4.17).
+	ProbeAttachModeLegacy ProbeAttachMode = C.PROBE_ATTACH_MODE_LEGACY
+	// ProbeAttachModePerf attaches through a perf event and ioctl(). The
+	// program stays attached as long as the perf event is open.
+	ProbeAttachModePerf ProbeAttachMode = C.PROBE_ATTACH_MODE_PERF
+	// ProbeAttachModeLink attaches through a perf event and a BPF link
+	// (v5.15), which can be pinned and queried like other links. Cookies
+	// (WithCookie) are set through perf links only.
+	ProbeAttachModeLink ProbeAttachMode = C.PROBE_ATTACH_MODE_LINK
+)
+
+var probeAttachModeToString = map[ProbeAttachMode]string{
+	ProbeAttachModeDefault: "default",
+	ProbeAttachModeLegacy:  "legacy",
+	ProbeAttachModePerf:    "perf",
+	ProbeAttachModeLink:    "link",
+}
+
+func (m ProbeAttachMode) String() string {
+	str, ok := probeAttachModeToString[m]
+	if !ok {
+		return fmt.Sprintf("ProbeAttachMode(%d)", uint32(m))
+	}
+
+	return str
+}
+
+// attachOptionSet is a set of attach options, by kind.
+type attachOptionSet uint32
+
+const (
+	attachOptCookie attachOptionSet = 1 << iota
+	attachOptPID
+	attachOptOffset
+	attachOptAttachMode
+	attachOptFunc
+	attachOptOrder
+	attachOptRevision
+)
+
+var attachOptionNames = []struct {
+	opt  attachOptionSet
+	name string
+}{
+	{attachOptCookie, "WithCookie"},
+	{attachOptPID, "WithPID"},
+	{attachOptOffset, "WithOffset"},
+	{attachOptAttachMode, "WithAttachMode"},
+	{attachOptFunc, "WithFunc"},
+	{attachOptOrder, "WithBefore/WithAfter"},
+	{attachOptRevision, "WithExpectedRevision"},
+}
+
+func (s attachOptionSet) String() string {
+	names := []string{}
+	for _, o := range attachOptionNames {
+		if s&o.opt != 0 {
+			names = append(names, o.name)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// attachOptions are the optional parameters of an attachment.
+type attachOptions struct {
+	set        attachOptionSet
+	cookie     uint64
+	pid        int
+	offset     uint64
+	attachMode ProbeAttachMode
+	funcName   string
+
+	// multi-program hook ordering (BPF_F_BEFORE, BPF_F_AFTER, ...)
+	orderFlags       uint32
+	relativeFd       int
+	relativeID       uint32
+	expectedRevision uint64
+}
+
+// AttachOption sets an optional parameter of an attachment.
+type AttachOption func(*attachOptions)
+
+// WithCookie sets the cookie of the attachment, which the program reads with
+// the bpf_get_attach_cookie() helper to tell apart its attachments (v5.15+).
+func WithCookie(cookie uint64) AttachOption {
+	return func(o *attachOptions) {
+		o.set |= attachOptCookie
+		o.cookie = cookie
+	}
+}
+
+// WithPID restricts a uprobe to the process with the given pid, instead of
+// all processes.
+func WithPID(pid int) AttachOption {
+	return func(o *attachOptions) {
+		o.set |= attachOptPID
+		o.pid = pid
+	}
+}
+
+// WithOffset sets the offset of a probe: within the kernel function of a
+// kprobe, within the function of a uprobe if given, or else within the
+// binary or library.
+func WithOffset(offset uint64) AttachOption {
+	return func(o *attachOptions) {
+		o.set |= attachOptOffset
+		o.offset = offset
+	}
+}
+
+// WithAttachMode sets the mechanism used to attach a kprobe, uprobe or perf
+// event.
+func WithAttachMode(mode ProbeAttachMode) AttachOption {
+	return func(o *attachOptions) {
+		o.set |= attachOptAttachMode
+		o.attachMode = mode
+	}
+}
+
+// WithFunc sets the function of a uprobe, whose offset libbpf resolves from
+// the symbols of the binary or library.
+func WithFunc(funcName string) AttachOption {
+	return func(o *attachOptions) {
+		o.set |= attachOptFunc
+		o.funcName = funcName
+	}
+}
+
+// AttachAnchor designates the program or link next to which a program is
+// attached in a multi-program hook. The zero AttachAnchor designates the
+// whole hook: WithBefore() then attaches first, and WithAfter() last.
+type AttachAnchor struct {
+	flags uint32
+	fd    int
+	id    uint32
+}
+
+// AnchorProgram designates the program, attached to the hook with or
+// without a link.
+func AnchorProgram(p *BPFProg) AttachAnchor {
+	return AttachAnchor{fd: p.FileDescriptor()}
+}
+
+// AnchorLink designates the program attached to the hook by the link.
+func AnchorLink(l *BPFLink) AttachAnchor {
+	return AttachAnchor{flags: C.BPF_F_LINK, fd: l.FileDescriptor()}
+}
+
+// AnchorProgramID designates the program by id, such as a program of another
+// agent listed by QueryTCX().
+func AnchorProgramID(id uint32) AttachAnchor {
+	return AttachAnchor{flags: C.BPF_F_ID, id: id}
+}
+
+// AnchorLinkID designates the program attached to the hook by the link with
+// the id.
+func AnchorLinkID(id uint32) AttachAnchor {
+	return AttachAnchor{flags: C.BPF_F_ID | C.BPF_F_LINK, id: id}
+}
+
+// WithBefore attaches the program right before the anchor in a multi-program
+// hook, or first if the anchor is the zero AttachAnchor.
+func WithBefore(anchor AttachAnchor) AttachOption {
+	return withOrder(C.BPF_F_BEFORE, anchor)
+}
+
+// WithAfter attaches the program right after the anchor in a multi-program
+// hook, or last if the anchor is the zero AttachAnchor.
+func WithAfter(anchor AttachAnchor) AttachOption {
+	return withOrder(C.BPF_F_AFTER, anchor)
+}
+
+func withOrder(flag uint32, anchor AttachAnchor) AttachOption {
+	return func(o *attachOptions) {
+		o.set |= attachOptOrder
+		o.orderFlags = flag | anchor.flags
+		o.relativeFd = anchor.fd
+		o.relativeID = anchor.id
+	}
+}
+
+// WithExpectedRevision makes the attachment fail with ESTALE if the revision
+// of the multi-program hook, as returned by QueryTCX(), changed, so that a
+// program is placed according to an up-to-date view of the hook.
+func WithExpectedRevision(revision uint64) AttachOption {
+	return func(o *attachOptions) {
+		o.set |= attachOptRevision
+		o.expectedRevision = revision
+	}
+}
+
+// newAttachOptions applies the options, and fails if any of them is not in
+// the allowed set.
+func newAttachOptions(allowed attachOptionSet, opts []AttachOption) (*attachOptions, error) {
+	o := &attachOptions{
+		pid:        -1,
+		attachMode: ProbeAttachModeDefault,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	if unsupported := o.set &^ allowed; unsupported != 0 {
+		return nil, fmt.Errorf("unsupported attach options: %s", unsupported)
+	}
+	if _, ok := probeAttachModeToString[o.attachMode]; !ok {
+		return nil, fmt.Errorf("invalid probe attach mode %s", o.attachMode)
+	}
+	// Cookies are set through perf links only, libbpf refuses them otherwise
+	if o.cookie != 0 && (o.attachMode == ProbeAttachModePerf || o.attachMode == ProbeAttachModeLegacy) {
+		return nil, fmt.Errorf("WithCookie needs a perf link, not the %s attach mode", o.attachMode)
+	}
+
+	return o, nil
+}
@@ -0,0 +1,93 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAttachOptions(t *testing.T) {
+	o, err := newAttachOptions(attachOptCookie|attachOptPID|attachOptOffset, nil)
+	require.NoError(t, err)
+	assert.Equal(t, -1, o.pid, "uprobes attach to all processes by default")
+	assert.Equal(t, ProbeAttachModeDefault, o.attachMode)
+	assert.Zero(t, o.set)
+
+	o, err = newAttachOptions(attachOptCookie|attachOptPID|attachOptOffset|attachOptAttachMode|attachOptFunc, []AttachOption{
+		WithCookie(42),
+		WithPID(1000),
+		WithOffset(0x10),
+		WithAttachMode(ProbeAttachModeLink),
+		WithFunc("readline"),
+		nil,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), o.cookie)
+	assert.Equal(t, 1000, o.pid)
+	assert.Equal(t, uint64(0x10), o.offset)
+	assert.Equal(t, ProbeAttachModeLink, o.attachMode)
+	assert.Equal(t, "readline", o.funcName)
+
+	// The last option wins
+	o, err = newAttachOptions(attachOptCookie, []AttachOption{WithCookie(1), WithCookie(2)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), o.cookie)
+}
+
+func TestNewAttachOptionsUnsupported(t *testing.T) {
+	_, err := newAttachOptions(attachOptCookie, []AttachOption{WithCookie(1), WithAttachMode(ProbeAttachModeLink), WithPID(1)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WithPID, WithAttachMode")
+	assert.NotContains(t, err.Error(), "WithCookie")
+
+	_, err = newAttachOptions(attachOptAttachMode, []AttachOption{WithAttachMode(ProbeAttachMode(100))})
+	assert.Error(t, err)
+}
+
+func TestNewAttachOptionsCookieAttachMode(t *testing.T) {
+	allowed := attachOptCookie | attachOptAttachMode
+
+	_, err := newAttachOptions(allowed, []AttachOption{WithCookie(1), WithAttachMode(ProbeAttachModeLegacy)})
+	assert.ErrorContains(t, err, "WithCookie needs a perf link, not the legacy attach mode")
+	_, err = newAttachOptions(allowed, []AttachOption{WithCookie(1), WithAttachMode(ProbeAttachModePerf)})
+	assert.Error(t, err)
+
+	for _, mode := range []ProbeAttachMode{ProbeAttachModeDefault, ProbeAttachModeLink} {
+		_, err = newAttachOptions(allowed, []AttachOption{WithCookie(1), WithAttachMode(mode)})
+		assert.NoError(t, err, mode)
+	}
+	_, err = newAttachOptions(allowed, []AttachOption{WithAttachMode(ProbeAttachModeLegacy)})
+	assert.NoError(t, err)
+}
+
+func TestProbeAttachModeString(t *testing.T) {
+	assert.Equal(t, "link", ProbeAttachModeLink.String())
+	assert.Equal(t, "ProbeAttachMode(100)", ProbeAttachMode(100).String())
+}
+
+func TestNewAttachOptionsOrder(t *testing.T) {
+	anchor := AnchorLinkID(7)
+
+	before, err := newAttachOptions(attachOptOrder|attachOptRevision, []AttachOption{WithBefore(anchor), WithExpectedRevision(3)})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), before.relativeID)
+	assert.Zero(t, before.relativeFd)
+	assert.Equal(t, uint64(3), before.expectedRevision)
+	assert.Equal(t, anchor.flags, before.orderFlags&anchor.flags)
+
+	after, err := newAttachOptions(attachOptOrder, []AttachOption{WithAfter(anchor)})
+	require.NoError(t, err)
+	assert.NotEqual(t, before.orderFlags, after.orderFlags)
+	assert.Equal(t, anchor.flags, after.orderFlags&anchor.flags)
+
+	// The zero anchor designates the whole hook
+	first, err := newAttachOptions(attachOptOrder, []AttachOption{WithBefore(AttachAnchor{})})
+	require.NoError(t, err)
+	assert.Equal(t, before.orderFlags&^anchor.flags, first.orderFlags)
+	assert.Zero(t, first.relativeID)
+
+	_, err = newAttachOptions(attachOptCookie, []AttachOption{WithAfter(anchor), WithExpectedRevision(1)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WithBefore/WithAfter, WithExpectedRevision")
+}
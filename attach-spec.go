@@ -0,0 +1,223 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// Attach specs
+//
+// Attach specs are bpftrace-style strings describing where to attach a
+// program, so tools can drive attachments from configuration files:
+//
+//	kprobe:tcp_connect               (k:tcp_connect)
+//	kprobe:tcp_connect+0x1a          (within the function)
+//	kretprobe:tcp_connect            (kr:tcp_connect)
+//	uprobe:/bin/bash:readline        (u:/bin/bash:readline)
+//	uretprobe:/bin/bash:0x1234       (ur:/bin/bash:0x1234)
+//	tracepoint:syscalls:sys_enter_openat (t:syscalls:sys_enter_openat)
+//	rawtracepoint:sched_switch       (rt:sched_switch)
+//	xdp:eth0
+//	cgroup:/sys/fs/cgroup/app        (c:/sys/fs/cgroup/app)
+//
+
+// AttachSpec is a parsed attach spec.
+type AttachSpec struct {
+	Type     LinkType
+	Category string // tracepoint category
+	Path     string // uprobe binary or library, cgroup directory
+	Target   string // function, tracepoint or interface name
+	Offset   uint64 // kprobe offset within the function, uprobe offset when given instead of a function name
+}
+
+var attachSpecProbes = map[string]LinkType{
+	"kprobe":        Kprobe,
+	"k":             Kprobe,
+	"kretprobe":     Kretprobe,
+	"kr":            Kretprobe,
+	"uprobe":        Uprobe,
+	"u":             Uprobe,
+	"uretprobe":     Uretprobe,
+	"ur":            Uretprobe,
+	"tracepoint":    Tracepoint,
+	"t":             Tracepoint,
+	"rawtracepoint": RawTracepoint,
+	"rt":            RawTracepoint,
+	"xdp":           XDP,
+	"cgroup":        Cgroup,
+	"c":             Cgroup,
+}
+
+// ParseAttachSpec parses an attach spec such as "kprobe:tcp_connect".
+func ParseAttachSpec(spec string) (AttachSpec, error) {
+	probe, rest, found := strings.Cut(strings.TrimSpace(spec), ":")
+	if !found || rest == "" {
+		return AttachSpec{}, fmt.Errorf("invalid attach spec %q: missing target", spec)
+	}
+
+	linkType, ok := attachSpecProbes[probe]
+	if !ok {
+		return AttachSpec{}, fmt.Errorf("invalid attach spec %q: unknown probe type %s", spec, probe)
+	}
+
+	s := AttachSpec{Type: linkType}
+
+	switch linkType {
+	case Uprobe, Uretprobe:
+		// The path may contain colons, the function name may not
+		i := strings.LastIndex(rest, ":")
+		if i <= 0 || i == len(rest)-1 {
+			return AttachSpec{}, fmt.Errorf("invalid attach spec %q: expected %s:PATH:FUNCTION", spec, probe)
+		}
+		s.Path, s.Target = rest[:i], rest[i+1:]
+
+		if strings.HasPrefix(s.Target, "0x") {
+			offset, err := strconv.ParseUint(s.Target[2:], 16, 64)
+			if err != nil {
+				return AttachSpec{}, fmt.Errorf("invalid attach spec %q: invalid offset %s", spec, s.Target)
+			}
+			s.Target, s.Offset = "", offset
+		}
+	case Kprobe:
+		if strings.Contains(rest, ":") {
+			return AttachSpec{}, fmt.Errorf("invalid attach spec %q: expected %s:FUNCTION[+OFFSET]", spec, probe)
+		}
+		symbol, offset, err := splitSymbolOffset(rest)
+		if err != nil {
+			return AttachSpec{}, fmt.Errorf("invalid attach spec %q: %w", spec, err)
+		}
+		s.Target, s.Offset = symbol, offset
+	case Kretprobe:
+		if strings.ContainsAny(rest, ":+") {
+			return AttachSpec{}, fmt.Errorf("invalid attach spec %q: expected %s:FUNCTION", spec, probe)
+		}
+		s.Target = rest
+	case Tracepoint:
+		category, name, found := strings.Cut(rest, ":")
+		if !found || category == "" || name == "" {
+			return AttachSpec{}, fmt.Errorf("invalid attach spec %q: expected %s:CATEGORY:NAME", spec, probe)
+		}
+		s.Category, s.Target = category, name
+	case Cgroup:
+		s.Path = rest
+	default:
+		if strings.Contains(rest, ":") {
+			return AttachSpec{}, fmt.Errorf("invalid attach spec %q: unexpected ':' in %s", spec, rest)
+		}
+		s.Target = rest
+	}
+
+	return s, nil
+}
+
+// String returns the canonical form of the attach spec.
+func (s AttachSpec) String() string {
+	switch s.Type {
+	case Kprobe:
+		if s.Offset != 0 {
+			return fmt.Sprintf("kprobe:%s+0x%x", s.Target, s.Offset)
+		}
+		return "kprobe:" + s.Target
+	case Kretprobe:
+		return "kretprobe:" + s.Target
+	case Uprobe, Uretprobe:
+		probe := "uprobe"
+		if s.Type == Uretprobe {
+			probe = "uretprobe"
+		}
+		if s.Target == "" {
+			return fmt.Sprintf("%s:%s:0x%x", probe, s.Path, s.Offset)
+		}
+		return fmt.Sprintf("%s:%s:%s", probe, s.Path, s.Target)
+	case Tracepoint:
+		return fmt.Sprintf("tracepoint:%s:%s", s.Category, s.Target)
+	case RawTracepoint:
+		return "rawtracepoint:" + s.Target
+	case XDP:
+		return "xdp:" + s.Target
+	case Cgroup:
+		return "cgroup:" + s.Path
+	}
+
+	return fmt.Sprintf("unknown(%d):%s", s.Type, s.Target)
+}
+
+// AttachBySpec attaches the program as described by an attach spec, such as
+// "kprobe:tcp_connect" or "uprobe:/bin/bash:readline". Uprobes are attached
+// to all processes.
+func (p *BPFProg) AttachBySpec(spec string) (*BPFLink, error) {
+	s, err := ParseAttachSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.Type {
+	case Kprobe:
+		return p.AttachKprobeOpts(s.Target, WithOffset(s.Offset))
+	case Kretprobe:
+		return p.AttachKretprobe(s.Target)
+	case Uprobe:
+		if s.Target == "" {
+			return p.attachUprobeSpecOffset(s, false)
+		}
+		return p.AttachUprobeFunc(-1, s.Path, s.Target)
+	case Uretprobe:
+		if s.Target == "" {
+			return p.attachUprobeSpecOffset(s, true)
+		}
+		return p.AttachURetprobeFunc(-1, s.Path, s.Target)
+	case Tracepoint:
+		return p.AttachTracepoint(s.Category, s.Target)
+	case RawTracepoint:
+		return p.AttachRawTracepoint(s.Target)
+	case XDP:
+		return p.AttachXDP(s.Target)
+	case Cgroup:
+		return p.AttachCgroup(s.Path)
+	}
+
+	return nil, fmt.Errorf("failed to attach program %s: unsupported attach spec %s", p.Name(), s)
+}
+
+// splitSymbolOffset splits a "symbol+offset" probe target, the offset being
+// hexadecimal (0x prefix) or decimal.
+func splitSymbolOffset(target string) (string, uint64, error) {
+	symbol, offsetStr, found := strings.Cut(target, "+")
+	if !found {
+		return target, 0, nil
+	}
+	if symbol == "" {
+		return "", 0, fmt.Errorf("missing symbol in %s", target)
+	}
+
+	offset, err := strconv.ParseUint(offsetStr, 0, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid offset in %s", target)
+	}
+
+	return symbol, offset, nil
+}
+
+func (p *BPFProg) attachUprobeSpecOffset(s AttachSpec, isUretprobe bool) (*BPFLink, error) {
+	if s.Offset > uint64(^uint32(0)) {
+		return nil, fmt.Errorf("failed to attach program %s: offset 0x%x out of range", p.Name(), s.Offset)
+	}
+	if isUretprobe {
+		return p.AttachURetprobe(-1, s.Path, uint32(s.Offset))
+	}
+
+	return p.AttachUprobe(-1, s.Path, uint32(s.Offset))
+}
+
+// AttachProgramBySpec attaches the named program as described by an attach
+// spec (see BPFProg.AttachBySpec()).
+func (m *Module) AttachProgramBySpec(progName string, spec string) (*BPFLink, error) {
+	prog, err := m.GetProgram(progName)
+	if err != nil {
+		return nil, err
+	}
+
+	return prog.AttachBySpec(spec)
+}
@@ -0,0 +1,119 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAttachSpec(t *testing.T) {
+	testCases := []struct {
+		spec      string
+		expected  AttachSpec
+		canonical string
+	}{
+		{
+			spec:      "kprobe:tcp_connect",
+			expected:  AttachSpec{Type: Kprobe, Target: "tcp_connect"},
+			canonical: "kprobe:tcp_connect",
+		},
+		{
+			spec:      "kprobe:tcp_connect+0x1a",
+			expected:  AttachSpec{Type: Kprobe, Target: "tcp_connect", Offset: 0x1a},
+			canonical: "kprobe:tcp_connect+0x1a",
+		},
+		{
+			spec:      "k:tcp_connect+26",
+			expected:  AttachSpec{Type: Kprobe, Target: "tcp_connect", Offset: 0x1a},
+			canonical: "kprobe:tcp_connect+0x1a",
+		},
+		{
+			spec:      "kr:tcp_connect",
+			expected:  AttachSpec{Type: Kretprobe, Target: "tcp_connect"},
+			canonical: "kretprobe:tcp_connect",
+		},
+		{
+			spec:      "uprobe:/bin/bash:readline",
+			expected:  AttachSpec{Type: Uprobe, Path: "/bin/bash", Target: "readline"},
+			canonical: "uprobe:/bin/bash:readline",
+		},
+		{
+			spec:      "ur:/opt/a:b/lib.so:0x1a2b",
+			expected:  AttachSpec{Type: Uretprobe, Path: "/opt/a:b/lib.so", Offset: 0x1a2b},
+			canonical: "uretprobe:/opt/a:b/lib.so:0x1a2b",
+		},
+		{
+			spec:      "t:syscalls:sys_enter_openat",
+			expected:  AttachSpec{Type: Tracepoint, Category: "syscalls", Target: "sys_enter_openat"},
+			canonical: "tracepoint:syscalls:sys_enter_openat",
+		},
+		{
+			spec:      " rawtracepoint:sched_switch ",
+			expected:  AttachSpec{Type: RawTracepoint, Target: "sched_switch"},
+			canonical: "rawtracepoint:sched_switch",
+		},
+		{
+			spec:      "xdp:eth0",
+			expected:  AttachSpec{Type: XDP, Target: "eth0"},
+			canonical: "xdp:eth0",
+		},
+		{
+			spec:      "c:/sys/fs/cgroup/app",
+			expected:  AttachSpec{Type: Cgroup, Path: "/sys/fs/cgroup/app"},
+			canonical: "cgroup:/sys/fs/cgroup/app",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			s, err := ParseAttachSpec(tc.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, s)
+			assert.Equal(t, tc.canonical, s.String())
+		})
+	}
+}
+
+func TestParseAttachSpecInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"kprobe",
+		"kprobe:",
+		"fentry:tcp_connect",
+		"kprobe:a:b",
+		"kprobe:+0x10",
+		"kprobe:tcp_connect+zz",
+		"kretprobe:tcp_connect+0x10",
+		"kretprobe:a:b",
+		"uprobe:readline",
+		"uprobe:/bin/bash:",
+		"uprobe:/bin/bash:0xzz",
+		"tracepoint:sys_enter_openat",
+		"tracepoint::sys_enter_openat",
+	} {
+		_, err := ParseAttachSpec(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSplitSymbolOffset(t *testing.T) {
+	symbol, offset, err := splitSymbolOffset("tcp_connect")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp_connect", symbol)
+	assert.Zero(t, offset)
+
+	symbol, offset, err = splitSymbolOffset("tcp_connect+0x1a")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp_connect", symbol)
+	assert.Equal(t, uint64(0x1a), offset)
+
+	_, offset, err = splitSymbolOffset("tcp_connect+26")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(26), offset)
+
+	for _, target := range []string{"+0x1a", "tcp_connect+", "tcp_connect+0xzz", "tcp_connect+-1"} {
+		_, _, err := splitSymbolOffset(target)
+		assert.Error(t, err, target)
+	}
+}
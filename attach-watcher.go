@@ -0,0 +1,311 @@
+package libbpfgo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+//
+// AttachWatcher
+//
+// Interfaces may be deleted and recreated with the same name but a new index
+// (e.g. veth pairs of restarted containers). XDP and TCX links are bound to
+// the interface index, so such attachments silently stop working. The
+// AttachWatcher listens to rtnetlink link events and attaches again when a
+// watched interface name shows up with a new index.
+//
+
+// AttachFunc attaches a program to the given network device, for example
+// BPFProg.AttachXDP or BPFProg.AttachTCX.
+type AttachFunc func(deviceName string) (*BPFLink, error)
+
+// AttachNotifyFunc is called by the AttachWatcher after an attempt to attach
+// again to a recreated interface.
+type AttachNotifyFunc func(deviceName string, link *BPFLink, err error)
+
+// rtmgrpLink is the rtnetlink multicast group of link events (RTMGRP_LINK).
+const rtmgrpLink = 0x1
+
+type watchedDevice struct {
+	ifindex int // 0 if not attached
+	link    *BPFLink
+}
+
+type AttachWatcher struct {
+	attach  AttachFunc
+	notify  AttachNotifyFunc
+	fd      int
+	waker   *pollWaker
+	devices map[string]*watchedDevice
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+}
+
+// NewAttachWatcher creates an AttachWatcher that uses attach to attach to
+// the watched interfaces. notify, if not nil, is called from the watcher
+// goroutine after each attempt to attach again.
+func NewAttachWatcher(attach AttachFunc, notify AttachNotifyFunc) (*AttachWatcher, error) {
+	if attach == nil {
+		return nil, fmt.Errorf("failed to create attach watcher: nil attach function")
+	}
+
+	fd, err := syscall.Socket(
+		syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK,
+		syscall.NETLINK_ROUTE,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create netlink socket: %w", err)
+	}
+
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpLink}
+	if err := syscall.Bind(fd, sa); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind netlink socket: %w", err)
+	}
+
+	waker, err := newPollWaker(fd)
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	w := &AttachWatcher{
+		attach:  attach,
+		notify:  notify,
+		fd:      fd,
+		waker:   waker,
+		devices: make(map[string]*watchedDevice),
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w, nil
+}
+
+// Watch attaches to the interface, if it exists, and keeps it attached when
+// it is recreated. An interface that does not exist yet is attached to once
+// it is created, and a nil link is returned.
+func (w *AttachWatcher) Watch(deviceName string) (*BPFLink, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, fmt.Errorf("failed to watch %s: watcher closed", deviceName)
+	}
+	if dev, ok := w.devices[deviceName]; ok {
+		return dev.link, nil
+	}
+
+	dev := &watchedDevice{}
+	w.devices[deviceName] = dev
+
+	iface, err := net.InterfaceByName(deviceName)
+	if err != nil {
+		// Not there yet, wait for it to be created
+		return nil, nil
+	}
+
+	return w.attachLocked(deviceName, dev, iface.Index)
+}
+
+// Unwatch stops watching the interface and destroys its link.
+func (w *AttachWatcher) Unwatch(deviceName string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dev, ok := w.devices[deviceName]
+	if !ok {
+		return nil
+	}
+	delete(w.devices, deviceName)
+
+	return destroyWatchedLink(dev)
+}
+
+// Close stops the watcher and destroys the links it created.
+func (w *AttachWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	_ = w.waker.wake()
+	w.wg.Wait()
+	w.waker.close()
+	_ = syscall.Close(w.fd)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []error
+	for name, dev := range w.devices {
+		if err := destroyWatchedLink(dev); err != nil {
+			errs = append(errs, fmt.Errorf("failed to destroy link on %s: %w", name, err))
+		}
+	}
+	w.devices = nil
+
+	return errors.Join(errs...)
+}
+
+func destroyWatchedLink(dev *watchedDevice) error {
+	if dev.link == nil {
+		return nil
+	}
+
+	err := dev.link.Destroy()
+	dev.link = nil
+	dev.ifindex = 0
+
+	return err
+}
+
+func (w *AttachWatcher) attachLocked(deviceName string, dev *watchedDevice, ifindex int) (*BPFLink, error) {
+	// The previous link is defunct once its interface is gone
+	_ = destroyWatchedLink(dev)
+
+	link, err := w.attach(deviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	dev.link = link
+	dev.ifindex = ifindex
+
+	return link, nil
+}
+
+func (w *AttachWatcher) run() {
+	defer w.wg.Done()
+
+	buf := make([]byte, syscall.Getpagesize()*4)
+
+	for {
+		ready, err := w.waker.wait()
+		if err != nil || !ready {
+			return
+		}
+
+		for {
+			n, _, err := syscall.Recvfrom(w.fd, buf, 0)
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				break
+			}
+			if err == syscall.ENOBUFS {
+				// Events were dropped, check all interfaces
+				w.resync()
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			for _, event := range parseLinkEvents(buf[:n]) {
+				w.handle(event)
+			}
+		}
+	}
+}
+
+func (w *AttachWatcher) handle(event linkEvent) {
+	w.mu.Lock()
+	dev, ok := w.devices[event.name]
+	if w.closed || !ok || event.deleted || dev.ifindex == event.ifindex {
+		w.mu.Unlock()
+		return
+	}
+
+	link, err := w.attachLocked(event.name, dev, event.ifindex)
+	w.mu.Unlock()
+
+	if w.notify != nil {
+		w.notify(event.name, link, err)
+	}
+}
+
+func (w *AttachWatcher) resync() {
+	w.mu.Lock()
+	names := make([]string, 0, len(w.devices))
+	for name := range w.devices {
+		names = append(names, name)
+	}
+	w.mu.Unlock()
+
+	for _, name := range names {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			continue
+		}
+		w.handle(linkEvent{name: name, ifindex: iface.Index})
+	}
+}
+
+//
+// rtnetlink link events
+//
+
+type linkEvent struct {
+	name    string
+	ifindex int
+	deleted bool
+}
+
+// parseLinkEvents parses the RTM_NEWLINK and RTM_DELLINK messages in a
+// netlink datagram. Malformed messages are skipped.
+func parseLinkEvents(buf []byte) []linkEvent {
+	msgs, err := syscall.ParseNetlinkMessage(buf)
+	if err != nil {
+		return nil
+	}
+
+	var events []linkEvent
+	for i := range msgs {
+		msg := &msgs[i]
+		if msg.Header.Type != syscall.RTM_NEWLINK && msg.Header.Type != syscall.RTM_DELLINK {
+			continue
+		}
+		if len(msg.Data) < syscall.SizeofIfInfomsg {
+			continue
+		}
+
+		ifinfo := (*syscall.IfInfomsg)(unsafe.Pointer(&msg.Data[0]))
+		attrs, err := syscall.ParseNetlinkRouteAttr(msg)
+		if err != nil {
+			continue
+		}
+
+		for _, attr := range attrs {
+			if attr.Attr.Type != syscall.IFLA_IFNAME {
+				continue
+			}
+
+			events = append(events, linkEvent{
+				name:    string(trimNull(attr.Value)),
+				ifindex: int(ifinfo.Index),
+				deleted: msg.Header.Type == syscall.RTM_DELLINK,
+			})
+		}
+	}
+
+	return events
+}
+
+func trimNull(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+
+	return b
+}
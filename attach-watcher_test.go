@@ -0,0 +1,44 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// linkMessage builds a rtnetlink link message with an IFLA_IFNAME attribute.
+func linkMessage(msgType uint16, ifindex int32, name string) []byte {
+	nameAttrLen := syscall.SizeofRtAttr + len(name) + 1
+	msgLen := syscall.NLMSG_HDRLEN + syscall.SizeofIfInfomsg + (nameAttrLen+3)&^3
+
+	b := make([]byte, msgLen)
+	binary.LittleEndian.PutUint32(b[0:], uint32(msgLen))
+	binary.LittleEndian.PutUint16(b[4:], msgType)
+
+	ifinfo := b[syscall.NLMSG_HDRLEN:]
+	binary.LittleEndian.PutUint32(ifinfo[4:], uint32(ifindex))
+
+	attr := ifinfo[syscall.SizeofIfInfomsg:]
+	binary.LittleEndian.PutUint16(attr[0:], uint16(nameAttrLen))
+	binary.LittleEndian.PutUint16(attr[2:], syscall.IFLA_IFNAME)
+	copy(attr[syscall.SizeofRtAttr:], name)
+
+	return b
+}
+
+func TestParseLinkEvents(t *testing.T) {
+	var buf []byte
+	buf = append(buf, linkMessage(syscall.RTM_NEWLINK, 7, "veth0")...)
+	buf = append(buf, linkMessage(syscall.RTM_NEWADDR, 7, "ignored")...)
+	buf = append(buf, linkMessage(syscall.RTM_DELLINK, 3, "eth10")...)
+
+	events := parseLinkEvents(buf)
+	assert.Equal(t, []linkEvent{
+		{name: "veth0", ifindex: 7},
+		{name: "eth10", ifindex: 3, deleted: true},
+	}, events)
+
+	assert.Empty(t, parseLinkEvents([]byte{1, 2, 3}))
+}
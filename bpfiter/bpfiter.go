@@ -0,0 +1,181 @@
+// Package bpfiter enumerates the tasks, sockets and BPF objects of the system
+// with BPF iterators, instead of parsing /proc:
+//
+//	it, err := bpfiter.Open()
+//	if err != nil {
+//	    return err
+//	}
+//	defer it.Close()
+//
+//	tasks, err := it.Tasks()
+//
+// An iterator walks the kernel data structures directly and returns a
+// consistent snapshot of each object, without racing with processes exiting
+// between a readdir() and the read of their files, and without the cost of
+// formatting and parsing text. The BPF programs (iterators.bpf.c) are built
+// with the Makefile of this directory (or `go generate`), and embedded in the
+// package with the libbpfgo_embed build tag:
+//
+//	go generate ./bpfiter && go build -tags libbpfgo_embed
+//
+// The iterators need CAP_BPF and CAP_PERFMON (or CAP_SYS_ADMIN), and a kernel
+// with BTF (v5.10+ for the BPF program iterator, v5.9+ for the others).
+package bpfiter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+//go:generate make
+
+// ErrNotEmbedded is the error of Open() in builds without the libbpfgo_embed
+// tag, which do not embed the BPF object.
+var ErrNotEmbedded = errors.New("bpfiter: BPF object not embedded, build with the libbpfgo_embed tag")
+
+// Iterators runs the canned iterator programs.
+type Iterators struct {
+	m *bpf.Module
+}
+
+// Open loads the embedded iterator programs.
+func Open() (*Iterators, error) {
+	if len(iteratorsObject) == 0 {
+		return nil, ErrNotEmbedded
+	}
+
+	return OpenObject(iteratorsObject)
+}
+
+// OpenObject loads the iterator programs from the given build of
+// iterators.bpf.c.
+func OpenObject(obj []byte) (*Iterators, error) {
+	m, err := bpf.NewModuleFromBufferArgs(bpf.NewModuleArgs{
+		BPFObjBuff: obj,
+		BPFObjName: "bpfiter",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open iterators: %w", err)
+	}
+
+	if err := m.BPFLoadObject(); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to load iterators: %w", err)
+	}
+
+	return &Iterators{m: m}, nil
+}
+
+// Close unloads the iterator programs.
+func (it *Iterators) Close() {
+	it.m.Close()
+}
+
+// run runs the iterator program and returns its whole output.
+func (it *Iterators) run(progName string) ([]byte, error) {
+	prog, err := it.m.GetProgram(progName)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := prog.AttachIter(bpf.IterOpts{})
+	if err != nil {
+		return nil, err
+	}
+	defer link.Destroy()
+
+	reader, err := link.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// The iterator read returns 0 bytes, and no error, once done
+	var out bytes.Buffer
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := reader.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read iterator %s: %w", progName, err)
+		}
+		if n == 0 {
+			break
+		}
+		out.Write(buf[:n])
+	}
+
+	return out.Bytes(), nil
+}
+
+// Tasks returns all the tasks (threads) of the system. The processes are the
+// thread group leaders.
+func (it *Iterators) Tasks() ([]Task, error) {
+	data, err := it.run("dump_tasks")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTasks(data)
+}
+
+// Processes returns the thread group leaders of the system, one per process.
+func (it *Iterators) Processes() ([]Task, error) {
+	tasks, err := it.Tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	procs := tasks[:0]
+	for _, t := range tasks {
+		if t.IsThreadGroupLeader() {
+			procs = append(procs, t)
+		}
+	}
+
+	return procs, nil
+}
+
+// TCPSockets returns the TCP sockets, IPv4 and IPv6, of the network namespace
+// of the caller.
+func (it *Iterators) TCPSockets() ([]Socket, error) {
+	data, err := it.run("dump_tcp")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSockets(data)
+}
+
+// UDPSockets returns the UDP sockets, IPv4 and IPv6, of the network namespace
+// of the caller.
+func (it *Iterators) UDPSockets() ([]Socket, error) {
+	data, err := it.run("dump_udp")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSockets(data)
+}
+
+// Programs returns the BPF programs loaded in the kernel.
+func (it *Iterators) Programs() ([]Program, error) {
+	data, err := it.run("dump_progs")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePrograms(data)
+}
+
+// Maps returns the BPF maps created in the kernel.
+func (it *Iterators) Maps() ([]Map, error) {
+	data, err := it.run("dump_maps")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMaps(data)
+}
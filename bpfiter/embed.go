@@ -0,0 +1,12 @@
+//go:build libbpfgo_embed
+
+package bpfiter
+
+import (
+	_ "embed"
+)
+
+// iteratorsObject is the build of iterators.bpf.c, with `go generate`.
+//
+//go:embed iterators.bpf.o
+var iteratorsObject []byte
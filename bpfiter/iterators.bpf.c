@@ -0,0 +1,165 @@
+//+build ignore
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_endian.h>
+#include <bpf/bpf_helpers.h>
+
+#define AF_INET  2
+#define AF_INET6 10
+
+#define IPPROTO_TCP 6
+#define IPPROTO_UDP 17
+
+// The records below are decoded by records.go, keep them in sync.
+
+struct task_rec {
+    __u32 pid;
+    __u32 tgid;
+    __u32 ppid;
+    __u32 uid;
+    __u32 gid;
+    __u32 flags;
+    __u64 start_time;
+    char comm[16];
+};
+
+struct sock_rec {
+    __u16 family;
+    __u8 protocol;
+    __u8 state;
+    __u16 sport;
+    __u16 dport;
+    __u32 uid;
+    __u32 pad;
+    __u8 saddr[16];
+    __u8 daddr[16];
+};
+
+struct prog_rec {
+    __u32 id;
+    __u32 type;
+    __u8 tag[8];
+    char name[16];
+};
+
+struct map_rec {
+    __u32 id;
+    __u32 type;
+    __u32 key_size;
+    __u32 value_size;
+    __u32 max_entries;
+    __u32 flags;
+    char name[16];
+};
+
+SEC("iter/task")
+int dump_tasks(struct bpf_iter__task *ctx)
+{
+    struct task_struct *task = ctx->task;
+    struct task_rec rec = {};
+
+    if (task == NULL)
+        return 0;
+
+    rec.pid = BPF_CORE_READ(task, pid);
+    rec.tgid = BPF_CORE_READ(task, tgid);
+    rec.ppid = BPF_CORE_READ(task, real_parent, tgid);
+    rec.uid = BPF_CORE_READ(task, cred, uid.val);
+    rec.gid = BPF_CORE_READ(task, cred, gid.val);
+    rec.flags = BPF_CORE_READ(task, flags);
+    rec.start_time = BPF_CORE_READ(task, start_time);
+    BPF_CORE_READ_STR_INTO(&rec.comm, task, comm);
+
+    bpf_seq_write(ctx->meta->seq, &rec, sizeof(rec));
+    return 0;
+}
+
+static __always_inline void
+fill_sock_rec(struct sock_rec *rec, struct sock_common *skc, __u8 protocol, __u32 uid)
+{
+    rec->family = BPF_CORE_READ(skc, skc_family);
+    rec->protocol = protocol;
+    rec->state = BPF_CORE_READ(skc, skc_state);
+    rec->sport = BPF_CORE_READ(skc, skc_num);
+    rec->dport = bpf_ntohs(BPF_CORE_READ(skc, skc_dport));
+    rec->uid = uid;
+
+    if (rec->family == AF_INET6) {
+        bpf_probe_read_kernel(&rec->saddr, sizeof(rec->saddr), &skc->skc_v6_rcv_saddr);
+        bpf_probe_read_kernel(&rec->daddr, sizeof(rec->daddr), &skc->skc_v6_daddr);
+    } else {
+        bpf_probe_read_kernel(&rec->saddr, 4, &skc->skc_rcv_saddr);
+        bpf_probe_read_kernel(&rec->daddr, 4, &skc->skc_daddr);
+    }
+}
+
+SEC("iter/tcp")
+int dump_tcp(struct bpf_iter__tcp *ctx)
+{
+    struct sock_common *skc = ctx->sk_common;
+    struct sock_rec rec = {};
+
+    if (skc == NULL)
+        return 0;
+
+    fill_sock_rec(&rec, skc, IPPROTO_TCP, ctx->uid);
+    bpf_seq_write(ctx->meta->seq, &rec, sizeof(rec));
+    return 0;
+}
+
+SEC("iter/udp")
+int dump_udp(struct bpf_iter__udp *ctx)
+{
+    struct udp_sock *udp_sk = ctx->udp_sk;
+    struct sock_rec rec = {};
+
+    if (udp_sk == NULL)
+        return 0;
+
+    fill_sock_rec(&rec, &udp_sk->inet.sk.__sk_common, IPPROTO_UDP, ctx->uid);
+    bpf_seq_write(ctx->meta->seq, &rec, sizeof(rec));
+    return 0;
+}
+
+SEC("iter/bpf_prog")
+int dump_progs(struct bpf_iter__bpf_prog *ctx)
+{
+    struct bpf_prog *prog = ctx->prog;
+    struct prog_rec rec = {};
+
+    if (prog == NULL)
+        return 0;
+
+    rec.id = BPF_CORE_READ(prog, aux, id);
+    rec.type = BPF_CORE_READ(prog, type);
+    BPF_CORE_READ_INTO(&rec.tag, prog, tag);
+    BPF_CORE_READ_STR_INTO(&rec.name, prog, aux, name);
+
+    bpf_seq_write(ctx->meta->seq, &rec, sizeof(rec));
+    return 0;
+}
+
+SEC("iter/bpf_map")
+int dump_maps(struct bpf_iter__bpf_map *ctx)
+{
+    struct bpf_map *map = ctx->map;
+    struct map_rec rec = {};
+
+    if (map == NULL)
+        return 0;
+
+    rec.id = BPF_CORE_READ(map, id);
+    rec.type = BPF_CORE_READ(map, map_type);
+    rec.key_size = BPF_CORE_READ(map, key_size);
+    rec.value_size = BPF_CORE_READ(map, value_size);
+    rec.max_entries = BPF_CORE_READ(map, max_entries);
+    rec.flags = BPF_CORE_READ(map, map_flags);
+    BPF_CORE_READ_STR_INTO(&rec.name, map, name);
+
+    bpf_seq_write(ctx->meta->seq, &rec, sizeof(rec));
+    return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,7 @@
+//go:build !libbpfgo_embed
+
+package bpfiter
+
+// iteratorsObject is empty without the libbpfgo_embed build tag: Open() fails with
+// ErrNotEmbedded, OpenObject() loads a build of iterators.bpf.c given at run time.
+var iteratorsObject []byte
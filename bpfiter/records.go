@@ -0,0 +1,232 @@
+package bpfiter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"syscall"
+	"time"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+// The records are written by the programs of iterators.bpf.c, in native byte
+// order, and must match their C layout.
+
+type taskRec struct {
+	Pid       uint32
+	Tgid      uint32
+	Ppid      uint32
+	Uid       uint32
+	Gid       uint32
+	Flags     uint32
+	StartTime uint64
+	Comm      [16]byte
+}
+
+type sockRec struct {
+	Family   uint16
+	Protocol uint8
+	State    uint8
+	Sport    uint16
+	Dport    uint16
+	Uid      uint32
+	_        uint32
+	Saddr    [16]byte
+	Daddr    [16]byte
+}
+
+type progRec struct {
+	ID   uint32
+	Type uint32
+	Tag  [8]byte
+	Name [16]byte
+}
+
+type mapRec struct {
+	ID         uint32
+	Type       uint32
+	KeySize    uint32
+	ValueSize  uint32
+	MaxEntries uint32
+	Flags      uint32
+	Name       [16]byte
+}
+
+// pfKthread is the task flag of kernel threads (PF_KTHREAD).
+const pfKthread = 0x00200000
+
+// Task is a task (thread) of the system.
+type Task struct {
+	PID  int // thread id
+	TGID int // process id
+	PPID int // process id of the parent
+	UID  uint32
+	GID  uint32
+	// StartTime is the time since boot the task started at, in
+	// CLOCK_MONOTONIC.
+	StartTime time.Duration
+	Comm      string
+	// KernelThread is set for kernel threads.
+	KernelThread bool
+}
+
+// IsThreadGroupLeader reports whether the task is the main thread of its
+// process.
+func (t Task) IsThreadGroupLeader() bool {
+	return t.PID == t.TGID
+}
+
+// Socket is a TCP or UDP socket of the system, of the network namespace of
+// the caller.
+type Socket struct {
+	Protocol int // syscall.IPPROTO_TCP or syscall.IPPROTO_UDP
+	// State is the TCP state (TCP_ESTABLISHED, TCP_LISTEN...) of TCP
+	// sockets, or the state of the UDP socket (TCP_ESTABLISHED if
+	// connected, TCP_CLOSE otherwise).
+	State  uint8
+	Local  netip.AddrPort
+	Remote netip.AddrPort
+	UID    uint32 // owner of the socket
+}
+
+// Program is a BPF program loaded in the kernel.
+type Program struct {
+	ID   uint32
+	Type bpf.BPFProgType
+	Tag  [8]byte
+	Name string
+}
+
+// Map is a BPF map created in the kernel.
+type Map struct {
+	ID         uint32
+	Type       bpf.MapType
+	KeySize    uint32
+	ValueSize  uint32
+	MaxEntries uint32
+	Flags      uint32
+	Name       string
+}
+
+// decodeRecords decodes the fixed size records of an iterator output.
+func decodeRecords[T any](data []byte) ([]T, error) {
+	var rec T
+	size := binary.Size(rec)
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("failed to decode iterator output: %d bytes is not a multiple of the record size %d", len(data), size)
+	}
+
+	recs := make([]T, len(data)/size)
+	if err := binary.Read(bytes.NewReader(data), binary.NativeEndian, recs); err != nil {
+		return nil, fmt.Errorf("failed to decode iterator output: %w", err)
+	}
+
+	return recs, nil
+}
+
+// cString returns the NUL terminated string of a fixed size C array.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+func decodeTasks(data []byte) ([]Task, error) {
+	recs, err := decodeRecords[taskRec](data)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]Task, 0, len(recs))
+	for _, r := range recs {
+		tasks = append(tasks, Task{
+			PID:          int(r.Pid),
+			TGID:         int(r.Tgid),
+			PPID:         int(r.Ppid),
+			UID:          r.Uid,
+			GID:          r.Gid,
+			StartTime:    time.Duration(r.StartTime),
+			Comm:         cString(r.Comm[:]),
+			KernelThread: r.Flags&pfKthread != 0,
+		})
+	}
+
+	return tasks, nil
+}
+
+// sockAddr returns the address and port of a socket of the given family.
+func sockAddr(family uint16, addr [16]byte, port uint16) netip.AddrPort {
+	if family == syscall.AF_INET6 {
+		return netip.AddrPortFrom(netip.AddrFrom16(addr), port)
+	}
+
+	return netip.AddrPortFrom(netip.AddrFrom4([4]byte(addr[:4])), port)
+}
+
+func decodeSockets(data []byte) ([]Socket, error) {
+	recs, err := decodeRecords[sockRec](data)
+	if err != nil {
+		return nil, err
+	}
+
+	socks := make([]Socket, 0, len(recs))
+	for _, r := range recs {
+		if r.Family != syscall.AF_INET && r.Family != syscall.AF_INET6 {
+			continue
+		}
+		socks = append(socks, Socket{
+			Protocol: int(r.Protocol),
+			State:    r.State,
+			Local:    sockAddr(r.Family, r.Saddr, r.Sport),
+			Remote:   sockAddr(r.Family, r.Daddr, r.Dport),
+			UID:      r.Uid,
+		})
+	}
+
+	return socks, nil
+}
+
+func decodePrograms(data []byte) ([]Program, error) {
+	recs, err := decodeRecords[progRec](data)
+	if err != nil {
+		return nil, err
+	}
+
+	progs := make([]Program, 0, len(recs))
+	for _, r := range recs {
+		progs = append(progs, Program{
+			ID:   r.ID,
+			Type: bpf.BPFProgType(r.Type),
+			Tag:  r.Tag,
+			Name: cString(r.Name[:]),
+		})
+	}
+
+	return progs, nil
+}
+
+func decodeMaps(data []byte) ([]Map, error) {
+	recs, err := decodeRecords[mapRec](data)
+	if err != nil {
+		return nil, err
+	}
+
+	maps := make([]Map, 0, len(recs))
+	for _, r := range recs {
+		maps = append(maps, Map{
+			ID:         r.ID,
+			Type:       bpf.MapType(r.Type),
+			KeySize:    r.KeySize,
+			ValueSize:  r.ValueSize,
+			MaxEntries: r.MaxEntries,
+			Flags:      r.Flags,
+			Name:       cString(r.Name[:]),
+		})
+	}
+
+	return maps, nil
+}
@@ -0,0 +1,106 @@
+package bpfiter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+func encodeRecords(t *testing.T, recs ...interface{}) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, r := range recs {
+		require.NoError(t, binary.Write(&buf, binary.NativeEndian, r))
+	}
+
+	return buf.Bytes()
+}
+
+func TestRecordSizes(t *testing.T) {
+	// Sizes of the C structures of iterators.bpf.c
+	assert.Equal(t, 48, binary.Size(taskRec{}))
+	assert.Equal(t, 48, binary.Size(sockRec{}))
+	assert.Equal(t, 32, binary.Size(progRec{}))
+	assert.Equal(t, 40, binary.Size(mapRec{}))
+}
+
+func TestDecodeTasks(t *testing.T) {
+	comm := func(s string) (b [16]byte) {
+		copy(b[:], s)
+		return b
+	}
+	data := encodeRecords(t,
+		taskRec{Pid: 1, Tgid: 1, Ppid: 0, StartTime: uint64(time.Second), Comm: comm("systemd")},
+		taskRec{Pid: 2, Tgid: 2, Flags: pfKthread, Comm: comm("kthreadd")},
+		taskRec{Pid: 101, Tgid: 100, Ppid: 1, Uid: 1000, Gid: 1000, Comm: comm("worker")},
+	)
+
+	tasks, err := decodeTasks(data)
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+
+	assert.Equal(t, Task{PID: 1, TGID: 1, StartTime: time.Second, Comm: "systemd"}, tasks[0])
+	assert.True(t, tasks[1].KernelThread)
+	assert.Equal(t, "worker", tasks[2].Comm)
+	assert.Equal(t, uint32(1000), tasks[2].UID)
+	assert.False(t, tasks[2].IsThreadGroupLeader())
+
+	_, err = decodeTasks(data[:len(data)-1])
+	assert.Error(t, err)
+}
+
+func TestDecodeSockets(t *testing.T) {
+	v4 := sockRec{Family: syscall.AF_INET, Protocol: syscall.IPPROTO_TCP, State: 10, Sport: 22, Uid: 0}
+	copy(v4.Saddr[:], []byte{127, 0, 0, 1})
+
+	v6 := sockRec{Family: syscall.AF_INET6, Protocol: syscall.IPPROTO_UDP, State: 7, Sport: 53, Dport: 4000, Uid: 1000}
+	v6.Saddr = netip.MustParseAddr("::1").As16()
+	v6.Daddr = netip.MustParseAddr("fe80::1").As16()
+
+	unix := sockRec{Family: syscall.AF_UNIX}
+
+	socks, err := decodeSockets(encodeRecords(t, v4, v6, unix))
+	require.NoError(t, err)
+	require.Len(t, socks, 2)
+
+	assert.Equal(t, Socket{
+		Protocol: syscall.IPPROTO_TCP,
+		State:    10,
+		Local:    netip.MustParseAddrPort("127.0.0.1:22"),
+		Remote:   netip.MustParseAddrPort("0.0.0.0:0"),
+	}, socks[0])
+	assert.Equal(t, Socket{
+		Protocol: syscall.IPPROTO_UDP,
+		State:    7,
+		Local:    netip.MustParseAddrPort("[::1]:53"),
+		Remote:   netip.MustParseAddrPort("[fe80::1]:4000"),
+		UID:      1000,
+	}, socks[1])
+}
+
+func TestDecodeProgramsAndMaps(t *testing.T) {
+	var name [16]byte
+	copy(name[:], "xdp_filter")
+
+	progs, err := decodePrograms(encodeRecords(t, progRec{ID: 7, Type: uint32(bpf.BPFProgTypeXdp), Tag: [8]byte{1, 2}, Name: name}))
+	require.NoError(t, err)
+	assert.Equal(t, []Program{{ID: 7, Type: bpf.BPFProgTypeXdp, Tag: [8]byte{1, 2}, Name: "xdp_filter"}}, progs)
+
+	copy(name[:], "events\x00")
+	maps, err := decodeMaps(encodeRecords(t, mapRec{ID: 3, Type: uint32(bpf.MapTypeRingbuf), MaxEntries: 4096, Name: name}))
+	require.NoError(t, err)
+	assert.Equal(t, []Map{{ID: 3, Type: bpf.MapTypeRingbuf, MaxEntries: 4096, Name: "events"}}, maps)
+
+	empty, err := decodeMaps(nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
@@ -0,0 +1,404 @@
+//go:build linux
+
+package bpfsys
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Cmd is a command of the bpf(2) system call (enum bpf_cmd).
+type Cmd int
+
+const (
+	CmdMapCreate      Cmd = 0
+	CmdMapLookupElem  Cmd = 1
+	CmdMapUpdateElem  Cmd = 2
+	CmdMapDeleteElem  Cmd = 3
+	CmdMapGetNextKey  Cmd = 4
+	CmdProgLoad       Cmd = 5
+	CmdObjPin         Cmd = 6
+	CmdObjGet         Cmd = 7
+	CmdProgAttach     Cmd = 8
+	CmdProgDetach     Cmd = 9
+	CmdProgRun        Cmd = 10
+	CmdProgGetNextID  Cmd = 11
+	CmdMapGetNextID   Cmd = 12
+	CmdProgGetFDByID  Cmd = 13
+	CmdMapGetFDByID   Cmd = 14
+	CmdObjGetInfoByFD Cmd = 15
+	CmdProgQuery      Cmd = 16
+	CmdLinkCreate     Cmd = 28
+	CmdLinkUpdate     Cmd = 29
+	CmdLinkGetFDByID  Cmd = 30
+	CmdLinkGetNextID  Cmd = 31
+	CmdLinkDetach     Cmd = 34
+)
+
+var cmdToString = map[Cmd]string{
+	CmdMapCreate:      "BPF_MAP_CREATE",
+	CmdMapLookupElem:  "BPF_MAP_LOOKUP_ELEM",
+	CmdMapUpdateElem:  "BPF_MAP_UPDATE_ELEM",
+	CmdMapDeleteElem:  "BPF_MAP_DELETE_ELEM",
+	CmdMapGetNextKey:  "BPF_MAP_GET_NEXT_KEY",
+	CmdProgLoad:       "BPF_PROG_LOAD",
+	CmdObjPin:         "BPF_OBJ_PIN",
+	CmdObjGet:         "BPF_OBJ_GET",
+	CmdProgAttach:     "BPF_PROG_ATTACH",
+	CmdProgDetach:     "BPF_PROG_DETACH",
+	CmdProgRun:        "BPF_PROG_RUN",
+	CmdProgGetNextID:  "BPF_PROG_GET_NEXT_ID",
+	CmdMapGetNextID:   "BPF_MAP_GET_NEXT_ID",
+	CmdProgGetFDByID:  "BPF_PROG_GET_FD_BY_ID",
+	CmdMapGetFDByID:   "BPF_MAP_GET_FD_BY_ID",
+	CmdObjGetInfoByFD: "BPF_OBJ_GET_INFO_BY_FD",
+	CmdProgQuery:      "BPF_PROG_QUERY",
+	CmdLinkCreate:     "BPF_LINK_CREATE",
+	CmdLinkUpdate:     "BPF_LINK_UPDATE",
+	CmdLinkGetFDByID:  "BPF_LINK_GET_FD_BY_ID",
+	CmdLinkGetNextID:  "BPF_LINK_GET_NEXT_ID",
+	CmdLinkDetach:     "BPF_LINK_DETACH",
+}
+
+func (c Cmd) String() string {
+	str, ok := cmdToString[c]
+	if !ok {
+		return fmt.Sprintf("BPF_CMD_%d", int(c))
+	}
+
+	return str
+}
+
+// BPF runs the command with the attributes of size bytes at attr, returning
+// the result of the system call. Attributes holding pointers must hold them
+// as Pointer.
+func BPF(cmd Cmd, attr unsafe.Pointer, size uintptr) (int, error) {
+	r, _, errno := syscall.Syscall(sysBPF, uintptr(cmd), uintptr(attr), size)
+	runtime.KeepAlive(attr)
+	if errno != 0 {
+		return int(r), fmt.Errorf("%s: %w", cmd, errno)
+	}
+
+	return int(r), nil
+}
+
+//
+// Pointers
+//
+
+// NewPointer returns the Pointer to p.
+func NewPointer(p unsafe.Pointer) Pointer {
+	return Pointer{ptr: p}
+}
+
+// NewSlicePointer returns the Pointer to the first byte of b, the zero
+// Pointer if b is empty.
+func NewSlicePointer(b []byte) Pointer {
+	if len(b) == 0 {
+		return Pointer{}
+	}
+
+	return Pointer{ptr: unsafe.Pointer(&b[0])}
+}
+
+// NewStringPointer returns the Pointer to a NUL terminated copy of s.
+func NewStringPointer(s string) (Pointer, error) {
+	p, err := syscall.BytePtrFromString(s)
+	if err != nil {
+		return Pointer{}, err
+	}
+
+	return Pointer{ptr: unsafe.Pointer(p)}, nil
+}
+
+//
+// Objects
+//
+
+// ObjAttr are the attributes of BPF_OBJ_PIN and BPF_OBJ_GET.
+type ObjAttr struct {
+	Pathname  Pointer
+	BPFFd     uint32
+	FileFlags uint32
+	// PathFd is the directory Pathname is relative to, with the
+	// BPF_F_PATH_FD flag in FileFlags (v6.5).
+	PathFd int32
+	_      uint32
+}
+
+// ObjPin pins the program, map or link at path, in a BPF file system.
+func ObjPin(fd int, path string) error {
+	pathname, err := NewStringPointer(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", CmdObjPin, err)
+	}
+	attr := ObjAttr{
+		Pathname: pathname,
+		BPFFd:    uint32(fd),
+	}
+	_, err = BPF(CmdObjPin, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+
+	return err
+}
+
+// ObjGet opens the program, map or link pinned at path, with the
+// BPF_F_RDONLY or BPF_F_WRONLY flags.
+func ObjGet(path string, flags uint32) (int, error) {
+	pathname, err := NewStringPointer(path)
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", CmdObjGet, err)
+	}
+	attr := ObjAttr{
+		Pathname:  pathname,
+		FileFlags: flags,
+	}
+
+	return BPF(CmdObjGet, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+// InfoAttr are the attributes of BPF_OBJ_GET_INFO_BY_FD.
+type InfoAttr struct {
+	BPFFd   uint32
+	InfoLen uint32
+	Info    Pointer
+}
+
+// ObjGetInfoByFD fills the size bytes at info with the information of the
+// program, map, BTF or link (struct bpf_prog_info, bpf_map_info, ...),
+// returning the size filled by the kernel.
+func ObjGetInfoByFD(fd int, info unsafe.Pointer, size uint32) (uint32, error) {
+	attr := InfoAttr{
+		BPFFd:   uint32(fd),
+		InfoLen: size,
+		Info:    NewPointer(info),
+	}
+	if _, err := BPF(CmdObjGetInfoByFD, unsafe.Pointer(&attr), unsafe.Sizeof(attr)); err != nil {
+		return 0, err
+	}
+
+	return attr.InfoLen, nil
+}
+
+// GetIDAttr are the attributes of the BPF_*_GET_NEXT_ID and
+// BPF_*_GET_FD_BY_ID commands.
+type GetIDAttr struct {
+	// ID is the start ID of BPF_*_GET_NEXT_ID, the ID to open of
+	// BPF_*_GET_FD_BY_ID.
+	ID        uint32
+	NextID    uint32
+	OpenFlags uint32
+}
+
+func getNextID(cmd Cmd, start uint32) (uint32, error) {
+	attr := GetIDAttr{ID: start}
+	if _, err := BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr)); err != nil {
+		return 0, err
+	}
+
+	return attr.NextID, nil
+}
+
+func getFDByID(cmd Cmd, id uint32) (int, error) {
+	attr := GetIDAttr{ID: id}
+
+	return BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+// ProgGetNextID returns the ID of the loaded program following start, ENOENT
+// after the last one.
+func ProgGetNextID(start uint32) (uint32, error) {
+	return getNextID(CmdProgGetNextID, start)
+}
+
+// MapGetNextID returns the ID of the map following start, ENOENT after the
+// last one.
+func MapGetNextID(start uint32) (uint32, error) {
+	return getNextID(CmdMapGetNextID, start)
+}
+
+// LinkGetNextID returns the ID of the link following start, ENOENT after the
+// last one.
+func LinkGetNextID(start uint32) (uint32, error) {
+	return getNextID(CmdLinkGetNextID, start)
+}
+
+// ProgGetFDByID opens the program of the ID.
+func ProgGetFDByID(id uint32) (int, error) {
+	return getFDByID(CmdProgGetFDByID, id)
+}
+
+// MapGetFDByID opens the map of the ID.
+func MapGetFDByID(id uint32) (int, error) {
+	return getFDByID(CmdMapGetFDByID, id)
+}
+
+// LinkGetFDByID opens the link of the ID.
+func LinkGetFDByID(id uint32) (int, error) {
+	return getFDByID(CmdLinkGetFDByID, id)
+}
+
+//
+// Map elements
+//
+
+// MapElemAttr are the attributes of the BPF_MAP_*_ELEM and
+// BPF_MAP_GET_NEXT_KEY commands.
+type MapElemAttr struct {
+	MapFd uint32
+	_     uint32
+	Key   Pointer
+	// Value is the next key of BPF_MAP_GET_NEXT_KEY.
+	Value Pointer
+	Flags uint64
+}
+
+func mapElem(cmd Cmd, fd int, key, value unsafe.Pointer, flags uint64) error {
+	attr := MapElemAttr{
+		MapFd: uint32(fd),
+		Key:   NewPointer(key),
+		Value: NewPointer(value),
+		Flags: flags,
+	}
+	_, err := BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+
+	return err
+}
+
+// MapLookupElem looks the key up into value, with the BPF_F_LOCK flag or
+// none.
+func MapLookupElem(fd int, key, value unsafe.Pointer, flags uint64) error {
+	return mapElem(CmdMapLookupElem, fd, key, value, flags)
+}
+
+// MapUpdateElem updates the key with value, with the BPF_ANY, BPF_NOEXIST,
+// BPF_EXIST or BPF_F_LOCK flags.
+func MapUpdateElem(fd int, key, value unsafe.Pointer, flags uint64) error {
+	return mapElem(CmdMapUpdateElem, fd, key, value, flags)
+}
+
+// MapDeleteElem deletes the key.
+func MapDeleteElem(fd int, key unsafe.Pointer) error {
+	return mapElem(CmdMapDeleteElem, fd, key, nil, 0)
+}
+
+// MapGetNextKey copies the key following key into nextKey, the first key if
+// key is nil, ENOENT after the last one.
+func MapGetNextKey(fd int, key, nextKey unsafe.Pointer) error {
+	return mapElem(CmdMapGetNextKey, fd, key, nextKey, 0)
+}
+
+//
+// Program queries
+//
+
+// ProgQueryAttr are the attributes of BPF_PROG_QUERY.
+type ProgQueryAttr struct {
+	// TargetFd is the target ifindex of networking attach types.
+	TargetFd    uint32
+	AttachType  uint32
+	QueryFlags  uint32
+	AttachFlags uint32
+	// ProgIDs holds ProgCnt IDs, ProgCnt being updated with the count of
+	// attached programs.
+	ProgIDs Pointer
+	ProgCnt uint32
+	_       uint32
+	// ProgAttachFlags holds the attach flags of each program (v6.0).
+	ProgAttachFlags Pointer
+}
+
+// ProgQuery queries the programs attached to a target (cgroup, interface,
+// network namespace, ...).
+func ProgQuery(attr *ProgQueryAttr) error {
+	_, err := BPF(CmdProgQuery, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+
+	return err
+}
+
+//
+// Links
+//
+
+// LinkCreateAttr are the attributes of BPF_LINK_CREATE for targets without
+// options, or identified by their BTF ID (tracing, LSM and struct_ops
+// programs).
+type LinkCreateAttr struct {
+	ProgFd uint32
+	// TargetFd is the target ifindex of networking attach types.
+	TargetFd    uint32
+	AttachType  uint32
+	Flags       uint32
+	TargetBTFID uint32
+	_           [44]byte
+}
+
+// LinkCreateIterAttr are the attributes of BPF_LINK_CREATE for iterators.
+type LinkCreateIterAttr struct {
+	ProgFd     uint32
+	TargetFd   uint32
+	AttachType uint32
+	Flags      uint32
+	// IterInfo holds the union bpf_iter_link_info of IterInfoLen bytes.
+	IterInfo    Pointer
+	IterInfoLen uint32
+	_           [36]byte
+}
+
+// LinkCreatePerfEventAttr are the attributes of BPF_LINK_CREATE for perf
+// events (v5.15), TargetFd being the perf event.
+type LinkCreatePerfEventAttr struct {
+	ProgFd     uint32
+	TargetFd   uint32
+	AttachType uint32
+	Flags      uint32
+	BPFCookie  uint64
+	_          [40]byte
+}
+
+// LinkCreate creates a link attaching the program to the target.
+func LinkCreate(attr *LinkCreateAttr) (int, error) {
+	return BPF(CmdLinkCreate, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+}
+
+// LinkCreateIter creates an iterator link.
+func LinkCreateIter(attr *LinkCreateIterAttr) (int, error) {
+	return BPF(CmdLinkCreate, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+}
+
+// LinkCreatePerfEvent creates a link attaching the program to a perf event.
+func LinkCreatePerfEvent(attr *LinkCreatePerfEventAttr) (int, error) {
+	return BPF(CmdLinkCreate, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+}
+
+// LinkUpdateAttr are the attributes of BPF_LINK_UPDATE.
+type LinkUpdateAttr struct {
+	LinkFd uint32
+	// NewProgFd is the new map of struct_ops links.
+	NewProgFd uint32
+	Flags     uint32
+	// OldProgFd is the expected program (or map) of the link, with the
+	// BPF_F_REPLACE flag.
+	OldProgFd uint32
+}
+
+// LinkUpdate replaces the program of the link.
+func LinkUpdate(attr *LinkUpdateAttr) error {
+	_, err := BPF(CmdLinkUpdate, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+
+	return err
+}
+
+// LinkDetachAttr are the attributes of BPF_LINK_DETACH.
+type LinkDetachAttr struct {
+	LinkFd uint32
+}
+
+// LinkDetach detaches the link from its target, the link remaining open.
+func LinkDetach(fd int) error {
+	attr := LinkDetachAttr{LinkFd: uint32(fd)}
+	_, err := BPF(CmdLinkDetach, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+
+	return err
+}
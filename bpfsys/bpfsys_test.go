@@ -0,0 +1,64 @@
+//go:build linux
+
+package bpfsys
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The attributes must be laid out as union bpf_attr.
+func TestAttrLayout(t *testing.T) {
+	assert.EqualValues(t, 8, unsafe.Sizeof(Pointer{}))
+
+	var obj ObjAttr
+	assert.EqualValues(t, 8, unsafe.Offsetof(obj.BPFFd))
+	assert.EqualValues(t, 16, unsafe.Offsetof(obj.PathFd))
+	assert.EqualValues(t, 24, unsafe.Sizeof(obj))
+
+	var info InfoAttr
+	assert.EqualValues(t, 8, unsafe.Offsetof(info.Info))
+	assert.EqualValues(t, 16, unsafe.Sizeof(info))
+
+	var elem MapElemAttr
+	assert.EqualValues(t, 8, unsafe.Offsetof(elem.Key))
+	assert.EqualValues(t, 16, unsafe.Offsetof(elem.Value))
+	assert.EqualValues(t, 24, unsafe.Offsetof(elem.Flags))
+	assert.EqualValues(t, 32, unsafe.Sizeof(elem))
+
+	var query ProgQueryAttr
+	assert.EqualValues(t, 16, unsafe.Offsetof(query.ProgIDs))
+	assert.EqualValues(t, 24, unsafe.Offsetof(query.ProgCnt))
+	assert.EqualValues(t, 32, unsafe.Offsetof(query.ProgAttachFlags))
+
+	var link LinkCreateAttr
+	var iter LinkCreateIterAttr
+	var perf LinkCreatePerfEventAttr
+	assert.EqualValues(t, 16, unsafe.Offsetof(link.TargetBTFID))
+	assert.EqualValues(t, 16, unsafe.Offsetof(iter.IterInfo))
+	assert.EqualValues(t, 24, unsafe.Offsetof(iter.IterInfoLen))
+	assert.EqualValues(t, 16, unsafe.Offsetof(perf.BPFCookie))
+	assert.EqualValues(t, 64, unsafe.Sizeof(link))
+	assert.EqualValues(t, 64, unsafe.Sizeof(iter))
+	assert.EqualValues(t, 64, unsafe.Sizeof(perf))
+
+	var update LinkUpdateAttr
+	assert.EqualValues(t, 12, unsafe.Offsetof(update.OldProgFd))
+}
+
+func TestCmdString(t *testing.T) {
+	assert.Equal(t, "BPF_OBJ_GET_INFO_BY_FD", CmdObjGetInfoByFD.String())
+	assert.Equal(t, "BPF_CMD_99", Cmd(99).String())
+}
+
+func TestNewStringPointer(t *testing.T) {
+	p, err := NewStringPointer("/sys/fs/bpf/events")
+	require.NoError(t, err)
+	assert.Equal(t, "/sys/fs/bpf/events\x00", unsafe.String((*byte)(p.ptr), len("/sys/fs/bpf/events")+1))
+
+	_, err = NewStringPointer("/sys/fs/bpf/\x00events")
+	assert.Error(t, err)
+}
@@ -0,0 +1,24 @@
+// Package bpfsys is a thin layer over the bpf(2) system call, for the
+// commands libbpfgo users reach for beyond the libbpf API: pinning and
+// getting objects, map element operations, program queries, links, and the
+// enumeration of programs, maps and links by ID.
+//
+//	fd, err := bpfsys.ObjGet("/sys/fs/bpf/events", 0)
+//	if err != nil {
+//	    return err
+//	}
+//	defer syscall.Close(fd)
+//
+//	var value uint64
+//	key := uint32(0)
+//	err = bpfsys.MapLookupElem(fd, unsafe.Pointer(&key), unsafe.Pointer(&value), 0)
+//
+// The attributes of the commands are laid out as union bpf_attr, pointers
+// included (see Pointer), so they are passed to the kernel as is. The
+// functions return the errno of the kernel, wrapped with the command:
+// errors.Is(err, syscall.ENOENT) holds for a missing key. The file
+// descriptors they return are owned by the caller.
+//
+// The package does not use cgo and builds on Linux only, the system call
+// number and the layout of pointers depending on the architecture.
+package bpfsys
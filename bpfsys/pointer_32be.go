@@ -0,0 +1,13 @@
+//go:build linux && mips
+
+package bpfsys
+
+import "unsafe"
+
+// Pointer is a pointer to userspace memory in the attributes of a command,
+// as __aligned_u64. It holds an unsafe.Pointer, so the memory is neither
+// collected nor moved while referenced.
+type Pointer struct {
+	_   uint32
+	ptr unsafe.Pointer
+}
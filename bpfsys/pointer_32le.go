@@ -0,0 +1,13 @@
+//go:build linux && (386 || arm || mipsle)
+
+package bpfsys
+
+import "unsafe"
+
+// Pointer is a pointer to userspace memory in the attributes of a command,
+// as __aligned_u64. It holds an unsafe.Pointer, so the memory is neither
+// collected nor moved while referenced.
+type Pointer struct {
+	ptr unsafe.Pointer
+	_   uint32
+}
@@ -0,0 +1,3 @@
+package bpfsys
+
+const sysBPF = 357
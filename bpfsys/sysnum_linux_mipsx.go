@@ -0,0 +1,5 @@
+//go:build linux && (mips || mipsle)
+
+package bpfsys
+
+const sysBPF = 4355
@@ -0,0 +1,5 @@
+//go:build linux && (ppc64 || ppc64le)
+
+package bpfsys
+
+const sysBPF = 361
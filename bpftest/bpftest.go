@@ -0,0 +1,204 @@
+// Package bpftest helps unit testing BPF programs with go test, without
+// attaching them to real hooks or booting a VM. Programs are executed with
+// BPF_PROG_TEST_RUN on canned packets and contexts, and their effects are
+// checked on the maps:
+//
+//	func TestDropTelnet(t *testing.T) {
+//	    m := bpftest.LoadModule(t, "filter.bpf.o")
+//	    prog := bpftest.Program(t, m, "xdp_filter")
+//
+//	    pkt := bpftest.MustMarshal(t, bpftest.Packet{
+//	        Src:   netip.MustParseAddrPort("10.0.0.1:40000"),
+//	        Dst:   netip.MustParseAddrPort("10.0.0.2:23"),
+//	        Proto: syscall.IPPROTO_TCP,
+//	    })
+//
+//	    res := bpftest.RunXDP(t, prog, pkt, nil)
+//	    if res.RetVal != bpftest.XDPDrop {
+//	        t.Errorf("retval = %d; want XDP_DROP", res.RetVal)
+//	    }
+//	    bpftest.AssertMapValue(t, bpftest.Map(t, m, "drops"), uint32(0), uint64(1))
+//	}
+//
+// Loading and running programs needs CAP_BPF (plus CAP_PERFMON for tracing
+// programs and CAP_NET_ADMIN for networking ones, or CAP_SYS_ADMIN on kernels
+// older than 5.8). Tests are skipped, instead of failing, when the privileges
+// are missing, so they can run unconditionally in CI.
+package bpftest
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+// dataOutSlack is the room left in the output buffer for programs growing
+// the packet (bpf_xdp_adjust_head(), bpf_skb_change_tail()).
+const dataOutSlack = 256
+
+// LoadModule opens and loads the BPF object, calling the setup functions
+// in between (to set global variables or resize maps). The module is closed
+// when the test ends. The test is skipped if the process lacks the
+// privileges to load it.
+func LoadModule(tb testing.TB, objPath string, setup ...func(*bpf.Module) error) *bpf.Module {
+	tb.Helper()
+
+	m, err := bpf.NewModuleFromFile(objPath)
+	if err != nil {
+		tb.Fatalf("failed to open BPF object %s: %v", objPath, err)
+	}
+	tb.Cleanup(m.Close)
+
+	for _, fn := range setup {
+		if err := fn(m); err != nil {
+			tb.Fatalf("failed to set up BPF object %s: %v", objPath, err)
+		}
+	}
+
+	if err := m.BPFLoadObject(); err != nil {
+		skipIfNotPermitted(tb, err)
+		tb.Fatalf("failed to load BPF object %s: %v", objPath, err)
+	}
+
+	return m
+}
+
+// Program returns the program with the given name, failing the test if it
+// does not exist.
+func Program(tb testing.TB, m *bpf.Module, name string) *bpf.BPFProg {
+	tb.Helper()
+
+	prog, err := m.GetProgram(name)
+	if err != nil {
+		tb.Fatalf("failed to get program %s: %v", name, err)
+	}
+
+	return prog
+}
+
+// Map returns the map with the given name, failing the test if it does not
+// exist.
+func Map(tb testing.TB, m *bpf.Module, name string) *bpf.BPFMap {
+	tb.Helper()
+
+	bpfMap, err := m.GetMap(name)
+	if err != nil {
+		tb.Fatalf("failed to get map %s: %v", name, err)
+	}
+
+	return bpfMap
+}
+
+// Input is the input of a program run.
+type Input struct {
+	// Data is the packet, for networking programs.
+	Data []byte
+	// Ctx is the program context, e.g. from SkBuff.Bytes(), XDPMd.Bytes() or
+	// the arguments of raw tracepoint and syscall programs.
+	Ctx []byte
+	// Repeat runs the program several times, Result.Duration being the
+	// average run time.
+	Repeat int
+	// Flags and CPU are passed as is to BPF_PROG_TEST_RUN.
+	Flags bpf.RunFlag
+	CPU   uint32
+}
+
+// Result is the output of a program run.
+type Result struct {
+	RetVal   uint32
+	Data     []byte // packet as modified by the program
+	Ctx      []byte // context as modified by the program
+	Duration time.Duration
+}
+
+// XDPAction returns the return code of an XDP program.
+func (r Result) XDPAction() bpf.XDPAction {
+	return bpf.XDPAction(r.RetVal)
+}
+
+// TCAction returns the return code of a tc program.
+func (r Result) TCAction() bpf.TCAction {
+	return bpf.TCAction(int32(r.RetVal))
+}
+
+// Run executes the program once (or Input.Repeat times) with
+// BPF_PROG_TEST_RUN and returns its output. The test is skipped if the
+// process lacks the privileges to run it.
+func Run(tb testing.TB, prog *bpf.BPFProg, in Input) Result {
+	tb.Helper()
+
+	opts := bpf.RunOpts{
+		Repeat: in.Repeat,
+		Flags:  in.Flags,
+		CPU:    in.CPU,
+	}
+	if len(in.Data) > 0 {
+		opts.DataIn = in.Data
+		opts.DataSizeIn = uint32(len(in.Data))
+		opts.DataOut = make([]byte, len(in.Data)+dataOutSlack)
+		opts.DataSizeOut = uint32(len(opts.DataOut))
+	}
+	if len(in.Ctx) > 0 {
+		opts.CtxIn = in.Ctx
+		opts.CtxSizeIn = uint32(len(in.Ctx))
+		opts.CtxOut = make([]byte, len(in.Ctx))
+		opts.CtxSizeOut = uint32(len(opts.CtxOut))
+	}
+
+	if err := prog.Run(&opts); err != nil {
+		skipIfNotPermitted(tb, err)
+		tb.Fatalf("failed to run program %s: %v", prog.Name(), err)
+	}
+
+	res := Result{
+		RetVal:   opts.RetVal,
+		Duration: opts.Duration,
+	}
+	if len(in.Data) > 0 {
+		res.Data = opts.DataOut
+	}
+	if len(in.Ctx) > 0 {
+		res.Ctx = opts.CtxOut
+	}
+
+	return res
+}
+
+// RunXDP runs an XDP program on the packet. md may be nil.
+func RunXDP(tb testing.TB, prog *bpf.BPFProg, packet []byte, md *XDPMd) Result {
+	tb.Helper()
+
+	in := Input{Data: packet}
+	if md != nil {
+		in.Ctx = md.Bytes(len(packet))
+	}
+
+	return Run(tb, prog, in)
+}
+
+// RunSkb runs a tc (sched_cls, sched_act) or socket filter program on the
+// packet. skb may be nil.
+func RunSkb(tb testing.TB, prog *bpf.BPFProg, packet []byte, skb *SkBuff) Result {
+	tb.Helper()
+
+	in := Input{Data: packet}
+	if skb != nil {
+		in.Ctx = skb.Bytes()
+	}
+
+	return Run(tb, prog, in)
+}
+
+// skipIfNotPermitted skips the test when err is due to missing privileges.
+// EACCES is not enough, the verifier also returns it for rejected programs.
+func skipIfNotPermitted(tb testing.TB, err error) {
+	tb.Helper()
+
+	if errors.Is(err, bpf.ErrPermission) || errors.Is(err, syscall.EPERM) {
+		tb.Skipf("insufficient privileges to load or run BPF programs: %v", err)
+	}
+}
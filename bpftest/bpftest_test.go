@@ -0,0 +1,258 @@
+package bpftest
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+func TestPacketMarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		packet  Packet
+		wantLen int
+	}{
+		{
+			name: "IPv4 TCP",
+			packet: Packet{
+				Src:     netip.MustParseAddrPort("10.0.0.1:40000"),
+				Dst:     netip.MustParseAddrPort("10.0.0.2:80"),
+				Proto:   syscall.IPPROTO_TCP,
+				Payload: []byte("GET /"),
+			},
+			wantLen: ethHdrLen + ipv4HdrLen + tcpHdrLen + 5,
+		},
+		{
+			name: "IPv4 UDP odd payload",
+			packet: Packet{
+				Src:     netip.MustParseAddrPort("192.168.1.1:5353"),
+				Dst:     netip.MustParseAddrPort("224.0.0.251:5353"),
+				Proto:   syscall.IPPROTO_UDP,
+				Payload: []byte{1, 2, 3},
+			},
+			wantLen: ethHdrLen + ipv4HdrLen + udpHdrLen + 3,
+		},
+		{
+			name: "IPv6 TCP",
+			packet: Packet{
+				Src:   netip.MustParseAddrPort("[2001:db8::1]:40000"),
+				Dst:   netip.MustParseAddrPort("[2001:db8::2]:443"),
+				Proto: syscall.IPPROTO_TCP,
+			},
+			wantLen: ethHdrLen + ipv6HdrLen + tcpHdrLen,
+		},
+		{
+			name: "IPv6 UDP",
+			packet: Packet{
+				Src:     netip.MustParseAddrPort("[2001:db8::1]:1234"),
+				Dst:     netip.MustParseAddrPort("[2001:db8::2]:53"),
+				Proto:   syscall.IPPROTO_UDP,
+				Payload: []byte("query"),
+			},
+			wantLen: ethHdrLen + ipv6HdrLen + udpHdrLen + 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.packet.Marshal()
+			require.NoError(t, err)
+			require.Len(t, b, tt.wantLen)
+
+			src, dst := tt.packet.Src.Addr(), tt.packet.Dst.Addr()
+			var l4 []byte
+			if src.Is4() {
+				assert.Equal(t, uint16(syscall.ETH_P_IP), binary.BigEndian.Uint16(b[12:]))
+				ip := b[ethHdrLen : ethHdrLen+ipv4HdrLen]
+				assert.Equal(t, uint16(0), checksum(0, ip), "IPv4 header checksum")
+				assert.Equal(t, tt.packet.Proto, ip[9])
+				l4 = b[ethHdrLen+ipv4HdrLen:]
+			} else {
+				assert.Equal(t, uint16(syscall.ETH_P_IPV6), binary.BigEndian.Uint16(b[12:]))
+				assert.Equal(t, tt.packet.Proto, b[ethHdrLen+6])
+				l4 = b[ethHdrLen+ipv6HdrLen:]
+			}
+
+			assert.Equal(t, tt.packet.Src.Port(), binary.BigEndian.Uint16(l4[0:]))
+			assert.Equal(t, tt.packet.Dst.Port(), binary.BigEndian.Uint16(l4[2:]))
+			sum := pseudoHeaderSum(src, dst, tt.packet.Proto, len(l4))
+			assert.Equal(t, uint16(0), checksum(sum, l4), "L4 checksum")
+		})
+	}
+}
+
+func TestPacketMarshalErrors(t *testing.T) {
+	_, err := Packet{
+		Src:   netip.MustParseAddrPort("10.0.0.1:1"),
+		Dst:   netip.MustParseAddrPort("[2001:db8::2]:2"),
+		Proto: syscall.IPPROTO_UDP,
+	}.Marshal()
+	assert.Error(t, err)
+
+	_, err = Packet{Proto: syscall.IPPROTO_UDP}.Marshal()
+	assert.Error(t, err)
+}
+
+func TestPacketMarshalDefaults(t *testing.T) {
+	b, err := Packet{
+		Src:   netip.MustParseAddrPort("[::ffff:10.0.0.1]:1"),
+		Dst:   netip.MustParseAddrPort("10.0.0.2:2"),
+		Proto: syscall.IPPROTO_TCP,
+	}.Marshal()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint8(64), b[ethHdrLen+8], "TTL")
+	assert.Equal(t, TCPFlagSYN, b[ethHdrLen+ipv4HdrLen+13], "TCP flags")
+}
+
+func TestUnmarshalPacket(t *testing.T) {
+	for _, packet := range []Packet{
+		{
+			Src:      netip.MustParseAddrPort("10.0.0.1:1234"),
+			Dst:      netip.MustParseAddrPort("10.0.0.2:80"),
+			Proto:    syscall.IPPROTO_TCP,
+			TCPFlags: TCPFlagACK | TCPFlagPSH,
+			Seq:      1000,
+			Ack:      2000,
+			Window:   512,
+			VLANID:   42,
+			TOS:      0x10,
+			Payload:  []byte("GET /"),
+		},
+		{
+			Src:     netip.MustParseAddrPort("[fd00::1]:53"),
+			Dst:     netip.MustParseAddrPort("[fd00::2]:5353"),
+			Proto:   syscall.IPPROTO_UDP,
+			TOS:     0xb8,
+			Payload: []byte("query"),
+		},
+	} {
+		b := MustMarshal(t, packet)
+		got := MustUnmarshal(t, b)
+		assert.Equal(t, packet.Src, got.Src)
+		assert.Equal(t, packet.Dst, got.Dst)
+		assert.Equal(t, packet.Proto, got.Proto)
+		assert.Equal(t, packet.TCPFlags, got.TCPFlags)
+		assert.Equal(t, packet.Seq, got.Seq)
+		assert.Equal(t, packet.Ack, got.Ack)
+		assert.Equal(t, packet.Window, got.Window)
+		assert.Equal(t, packet.VLANID, got.VLANID)
+		assert.Equal(t, packet.TOS, got.TOS)
+		assert.Equal(t, packet.Payload, got.Payload)
+	}
+
+	// A program rewriting the destination port must fix the checksum
+	b := MustMarshal(t, Packet{
+		Src:   netip.MustParseAddrPort("10.0.0.1:1"),
+		Dst:   netip.MustParseAddrPort("10.0.0.2:2"),
+		Proto: syscall.IPPROTO_UDP,
+	})
+	binary.BigEndian.PutUint16(b[ethHdrLen+ipv4HdrLen+2:], 3)
+	_, err := UnmarshalPacket(b)
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	b[ethHdrLen+8]-- // TTL
+	_, err = UnmarshalPacket(b)
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	_, err = UnmarshalPacket(b[:ethHdrLen+10])
+	assert.Error(t, err)
+}
+
+func TestICMPEcho(t *testing.T) {
+	for _, addrs := range [][2]string{{"10.0.0.1", "10.0.0.2"}, {"fd00::1", "fd00::2"}} {
+		src, dst := netip.MustParseAddr(addrs[0]), netip.MustParseAddr(addrs[1])
+		packet := ICMPEcho(src, dst, 7, 1, []byte("ping"))
+		b := MustMarshal(t, packet)
+		got := MustUnmarshal(t, b)
+		assert.Equal(t, packet.Proto, got.Proto)
+		require.Len(t, got.Payload, icmpHdrLen+4)
+		assert.Equal(t, uint16(7), binary.BigEndian.Uint16(got.Payload[4:]))
+		assert.Equal(t, uint16(1), binary.BigEndian.Uint16(got.Payload[6:]))
+
+		var sum uint32
+		if src.Is6() {
+			sum = pseudoHeaderSum(src, dst, got.Proto, len(got.Payload))
+		}
+		assert.Zero(t, checksum(sum, got.Payload), "ICMP checksum")
+	}
+}
+
+func TestSkBuffBytes(t *testing.T) {
+	skb := SkBuff{
+		Mark:     0x1234,
+		Priority: 7,
+		Ifindex:  2,
+		CB:       [5]uint32{1, 2, 3, 4, 5},
+		Tstamp:   1000,
+		HWTstamp: 2000,
+	}
+
+	b := skb.Bytes()
+	require.Len(t, b, skbSize)
+	assert.Equal(t, uint32(0x1234), binary.NativeEndian.Uint32(b[8:]))
+	assert.Equal(t, uint32(5), binary.NativeEndian.Uint32(b[64:]))
+	assert.Equal(t, skb, ParseSkBuff(b))
+	assert.Equal(t, SkBuff{Mark: 0x1234}, ParseSkBuff(b[:12:12]))
+}
+
+func TestXDPMdBytes(t *testing.T) {
+	md := XDPMd{IngressIfindex: 3, RxQueueIndex: 1}
+
+	b := md.Bytes(60)
+	require.Len(t, b, xdpMdSize)
+	assert.Equal(t, uint32(0), binary.NativeEndian.Uint32(b[0:]))
+	assert.Equal(t, uint32(60), binary.NativeEndian.Uint32(b[4:]))
+	assert.Equal(t, uint32(3), binary.NativeEndian.Uint32(b[12:]))
+	assert.Equal(t, uint32(1), binary.NativeEndian.Uint32(b[16:]))
+}
+
+func TestEncode(t *testing.T) {
+	b, err := encode(uint32(1), 4)
+	require.NoError(t, err)
+	assert.Equal(t, binary.NativeEndian.AppendUint32(nil, 1), b)
+
+	b, err = encode([]byte{1, 2}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, b)
+
+	_, err = encode(uint64(1), 4)
+	assert.Error(t, err)
+
+	_, err = encode("key", 3)
+	assert.Error(t, err)
+}
+
+func TestDiffEntries(t *testing.T) {
+	before := map[string][]byte{
+		"\x01": {1},
+		"\x02": {2},
+	}
+	after := map[string][]byte{
+		"\x01": {1},
+		"\x02": {3},
+		"\x04": {4},
+	}
+
+	assert.Empty(t, diffEntries(before, before))
+	assert.Equal(t, []string{
+		"key 02 value changed from 02 to 03",
+		"key 04 added with value 04",
+	}, diffEntries(before, after))
+	assert.Equal(t, []string{
+		"key 02 value changed from 03 to 02",
+		"key 04 deleted",
+	}, diffEntries(after, before))
+}
+
+func TestResultActions(t *testing.T) {
+	assert.Equal(t, bpf.XDPDrop, Result{RetVal: XDPDrop}.XDPAction())
+	assert.Equal(t, bpf.TCActUnspec, Result{RetVal: TCActUnspec}.TCAction())
+	assert.Equal(t, bpf.TCActRedirect, Result{RetVal: TCActRedirect}.TCAction())
+}
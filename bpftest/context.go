@@ -0,0 +1,124 @@
+package bpftest
+
+import (
+	"encoding/binary"
+)
+
+// XDP program return codes.
+const (
+	XDPAborted uint32 = iota
+	XDPDrop
+	XDPPass
+	XDPTx
+	XDPRedirect
+)
+
+// tc program return codes.
+const (
+	TCActUnspec     uint32 = ^uint32(0) // -1
+	TCActOK         uint32 = 0
+	TCActReclassify uint32 = 1
+	TCActShot       uint32 = 2
+	TCActPipe       uint32 = 3
+	TCActStolen     uint32 = 4
+	TCActRedirect   uint32 = 7
+)
+
+// XDPMd is the context of XDP programs (struct xdp_md). Only the fields
+// accepted by BPF_PROG_TEST_RUN are exposed, data pointers are derived from
+// the packet.
+type XDPMd struct {
+	IngressIfindex uint32
+	RxQueueIndex   uint32
+}
+
+// xdpMdSize is sizeof(struct xdp_md).
+const xdpMdSize = 24
+
+// Bytes encodes the context of a packet of dataLen bytes.
+func (md *XDPMd) Bytes(dataLen int) []byte {
+	b := make([]byte, xdpMdSize)
+	binary.NativeEndian.PutUint32(b[0:], 0)               // data
+	binary.NativeEndian.PutUint32(b[4:], uint32(dataLen)) // data_end
+	binary.NativeEndian.PutUint32(b[8:], 0)               // data_meta
+	binary.NativeEndian.PutUint32(b[12:], md.IngressIfindex)
+	binary.NativeEndian.PutUint32(b[16:], md.RxQueueIndex)
+
+	return b
+}
+
+// SkBuff is the context of tc and socket filter programs (struct
+// __sk_buff). Only the fields accepted by BPF_PROG_TEST_RUN are exposed, the
+// others are derived from the packet.
+type SkBuff struct {
+	Mark           uint32
+	Priority       uint32
+	IngressIfindex uint32
+	Ifindex        uint32
+	CB             [5]uint32
+	Tstamp         uint64
+	WireLen        uint32
+	GSOSegs        uint32
+	GSOSize        uint32
+	HWTstamp       uint64
+}
+
+// Offsets in struct __sk_buff.
+const (
+	skbMarkOffset           = 8
+	skbPriorityOffset       = 32
+	skbIngressIfindexOffset = 36
+	skbIfindexOffset        = 40
+	skbCBOffset             = 48
+	skbTstampOffset         = 152
+	skbWireLenOffset        = 160
+	skbGSOSegsOffset        = 164
+	skbGSOSizeOffset        = 176
+	skbHWTstampOffset       = 184
+	skbSize                 = 192
+)
+
+// Bytes encodes the context. Kernels with a smaller struct __sk_buff accept
+// it as long as the fields they do not know are zero.
+func (skb *SkBuff) Bytes() []byte {
+	b := make([]byte, skbSize)
+	binary.NativeEndian.PutUint32(b[skbMarkOffset:], skb.Mark)
+	binary.NativeEndian.PutUint32(b[skbPriorityOffset:], skb.Priority)
+	binary.NativeEndian.PutUint32(b[skbIngressIfindexOffset:], skb.IngressIfindex)
+	binary.NativeEndian.PutUint32(b[skbIfindexOffset:], skb.Ifindex)
+	for i, cb := range skb.CB {
+		binary.NativeEndian.PutUint32(b[skbCBOffset+4*i:], cb)
+	}
+	binary.NativeEndian.PutUint64(b[skbTstampOffset:], skb.Tstamp)
+	binary.NativeEndian.PutUint32(b[skbWireLenOffset:], skb.WireLen)
+	binary.NativeEndian.PutUint32(b[skbGSOSegsOffset:], skb.GSOSegs)
+	binary.NativeEndian.PutUint32(b[skbGSOSizeOffset:], skb.GSOSize)
+	binary.NativeEndian.PutUint64(b[skbHWTstampOffset:], skb.HWTstamp)
+
+	return b
+}
+
+// ParseSkBuff decodes the context returned by a program run, to check the
+// fields it modified (mark, priority, cb, tstamp).
+func ParseSkBuff(b []byte) SkBuff {
+	if len(b) < skbSize {
+		b = append(b, make([]byte, skbSize-len(b))...)
+	}
+
+	skb := SkBuff{
+		Mark:           binary.NativeEndian.Uint32(b[skbMarkOffset:]),
+		Priority:       binary.NativeEndian.Uint32(b[skbPriorityOffset:]),
+		IngressIfindex: binary.NativeEndian.Uint32(b[skbIngressIfindexOffset:]),
+		Ifindex:        binary.NativeEndian.Uint32(b[skbIfindexOffset:]),
+		Tstamp:         binary.NativeEndian.Uint64(b[skbTstampOffset:]),
+		WireLen:        binary.NativeEndian.Uint32(b[skbWireLenOffset:]),
+		GSOSegs:        binary.NativeEndian.Uint32(b[skbGSOSegsOffset:]),
+		GSOSize:        binary.NativeEndian.Uint32(b[skbGSOSizeOffset:]),
+		HWTstamp:       binary.NativeEndian.Uint64(b[skbHWTstampOffset:]),
+	}
+	for i := range skb.CB {
+		skb.CB[i] = binary.NativeEndian.Uint32(b[skbCBOffset+4*i:])
+	}
+
+	return skb
+}
@@ -0,0 +1,246 @@
+package bpftest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+//
+// Map helpers
+//
+// Keys and values given to the map helpers are either []byte, used as is, or
+// fixed size values (integers, arrays, structs without padding issues)
+// encoded in host byte order. Values of per-CPU maps hold one slot per
+// possible CPU, for them use the slices of LookupPerCPU() or a
+// libbpfgo.Counter.
+//
+
+// encode encodes a key or value and checks its size.
+func encode(v interface{}, size int) ([]byte, error) {
+	var b []byte
+	switch v := v.(type) {
+	case []byte:
+		b = v
+	default:
+		buf := bytes.NewBuffer(nil)
+		if err := binary.Write(buf, binary.NativeEndian, v); err != nil {
+			return nil, fmt.Errorf("failed to encode %T: %w", v, err)
+		}
+		b = buf.Bytes()
+	}
+
+	if len(b) != size {
+		return nil, fmt.Errorf("%T size is %d, expected %d", v, len(b), size)
+	}
+
+	return b, nil
+}
+
+func encodeKey(tb testing.TB, m *bpf.BPFMap, key interface{}) []byte {
+	tb.Helper()
+
+	k, err := encode(key, m.KeySize())
+	if err != nil {
+		tb.Fatalf("invalid key for map %s: %v", m.Name(), err)
+	}
+
+	return k
+}
+
+// Lookup returns the value of the key, and false if the key is not in the
+// map.
+func Lookup(tb testing.TB, m *bpf.BPFMap, key interface{}) ([]byte, bool) {
+	tb.Helper()
+
+	k := encodeKey(tb, m, key)
+	value, err := m.GetValue(unsafe.Pointer(&k[0]))
+	if errors.Is(err, syscall.ENOENT) {
+		return nil, false
+	}
+	if err != nil {
+		tb.Fatalf("failed to look up map %s: %v", m.Name(), err)
+	}
+
+	return value, true
+}
+
+// LookupPerCPU returns the per CPU values of the key of a per-CPU map, and
+// false if the key is not in the map.
+func LookupPerCPU(tb testing.TB, m *bpf.BPFMap, key interface{}) ([][]byte, bool) {
+	tb.Helper()
+
+	value, ok := Lookup(tb, m, key)
+	if !ok {
+		return nil, false
+	}
+
+	// Per-CPU values are 8 bytes aligned
+	slotSize := (m.ValueSize() + 7) &^ 7
+	values := make([][]byte, 0, len(value)/slotSize)
+	for i := 0; i+slotSize <= len(value); i += slotSize {
+		values = append(values, value[i:i+m.ValueSize()])
+	}
+
+	return values, true
+}
+
+// Update sets the value of the key, to seed a map before a run.
+func Update(tb testing.TB, m *bpf.BPFMap, key, value interface{}) {
+	tb.Helper()
+
+	valueSize, err := bpf.CalcMapValueSize(m.ValueSize(), m.Type())
+	if err != nil {
+		tb.Fatalf("failed to update map %s: %v", m.Name(), err)
+	}
+
+	k := encodeKey(tb, m, key)
+	v, err := encode(value, valueSize)
+	if err != nil {
+		tb.Fatalf("invalid value for map %s: %v", m.Name(), err)
+	}
+
+	if err := m.Update(unsafe.Pointer(&k[0]), unsafe.Pointer(&v[0])); err != nil {
+		tb.Fatalf("failed to update map %s: %v", m.Name(), err)
+	}
+}
+
+// Entries returns the entries of the map, indexed by the string of the key
+// bytes. It is meant to compare the content of a map before and after a
+// run.
+func Entries(tb testing.TB, m *bpf.BPFMap) map[string][]byte {
+	tb.Helper()
+
+	entries := make(map[string][]byte)
+
+	it := m.Iterator()
+	for it.Next() {
+		key := it.Key()
+		value, err := m.GetValue(unsafe.Pointer(&key[0]))
+		if errors.Is(err, syscall.ENOENT) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			tb.Fatalf("failed to look up map %s: %v", m.Name(), err)
+		}
+		entries[string(key)] = value
+	}
+	if err := it.Err(); err != nil {
+		tb.Fatalf("failed to iterate map %s: %v", m.Name(), err)
+	}
+
+	return entries
+}
+
+// Clear deletes all the keys of a hash-like map, or zeroes the values of an
+// array map, to reset it between test cases.
+func Clear(tb testing.TB, m *bpf.BPFMap) {
+	tb.Helper()
+
+	isArray := m.Type() == bpf.MapTypeArray || m.Type() == bpf.MapTypePerCPUArray
+
+	for key, value := range Entries(tb, m) {
+		k := []byte(key)
+
+		var err error
+		if isArray {
+			zero := make([]byte, len(value))
+			err = m.Update(unsafe.Pointer(&k[0]), unsafe.Pointer(&zero[0]))
+		} else {
+			err = m.DeleteKey(unsafe.Pointer(&k[0]))
+		}
+		if err != nil && !errors.Is(err, syscall.ENOENT) {
+			tb.Fatalf("failed to clear map %s: %v", m.Name(), err)
+		}
+	}
+}
+
+// AssertMapValue reports an error if the value of the key is not want.
+func AssertMapValue(tb testing.TB, m *bpf.BPFMap, key, want interface{}) bool {
+	tb.Helper()
+
+	got, ok := Lookup(tb, m, key)
+	if !ok {
+		tb.Errorf("map %s: key %v not found", m.Name(), key)
+		return false
+	}
+
+	w, err := encode(want, len(got))
+	if err != nil {
+		tb.Fatalf("invalid value for map %s: %v", m.Name(), err)
+	}
+	if !bytes.Equal(got, w) {
+		tb.Errorf("map %s: key %v value is %x, want %x", m.Name(), key, got, w)
+		return false
+	}
+
+	return true
+}
+
+// AssertMapKeyMissing reports an error if the key is in the map.
+func AssertMapKeyMissing(tb testing.TB, m *bpf.BPFMap, key interface{}) bool {
+	tb.Helper()
+
+	if got, ok := Lookup(tb, m, key); ok {
+		tb.Errorf("map %s: key %v unexpectedly found, value is %x", m.Name(), key, got)
+		return false
+	}
+
+	return true
+}
+
+// AssertMapLen reports an error if the map does not hold n keys.
+func AssertMapLen(tb testing.TB, m *bpf.BPFMap, n int) bool {
+	tb.Helper()
+
+	if got := len(Entries(tb, m)); got != n {
+		tb.Errorf("map %s holds %d keys, want %d", m.Name(), got, n)
+		return false
+	}
+
+	return true
+}
+
+// AssertMapUnchanged reports an error if the map content differs from the
+// entries taken earlier with Entries().
+func AssertMapUnchanged(tb testing.TB, m *bpf.BPFMap, before map[string][]byte) bool {
+	tb.Helper()
+
+	diff := diffEntries(before, Entries(tb, m))
+	for _, d := range diff {
+		tb.Errorf("map %s: %s", m.Name(), d)
+	}
+
+	return len(diff) == 0
+}
+
+// diffEntries describes the differences between two map contents.
+func diffEntries(before, after map[string][]byte) []string {
+	var diff []string
+
+	for key, value := range before {
+		newValue, ok := after[key]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("key %x deleted", key))
+		case !bytes.Equal(value, newValue):
+			diff = append(diff, fmt.Sprintf("key %x value changed from %x to %x", key, value, newValue))
+		}
+	}
+	for key, value := range after {
+		if _, ok := before[key]; !ok {
+			diff = append(diff, fmt.Sprintf("key %x added with value %x", key, value))
+		}
+	}
+
+	sort.Strings(diff)
+
+	return diff
+}
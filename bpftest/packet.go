@@ -0,0 +1,366 @@
+package bpftest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"testing"
+)
+
+// TCP flags.
+const (
+	TCPFlagFIN uint8 = 1 << iota
+	TCPFlagSYN
+	TCPFlagRST
+	TCPFlagPSH
+	TCPFlagACK
+	TCPFlagURG
+)
+
+const (
+	ethHdrLen  = 14
+	vlanHdrLen = 4
+	ipv4HdrLen = 20
+	ipv6HdrLen = 40
+	tcpHdrLen  = 20
+	udpHdrLen  = 8
+	icmpHdrLen = 8
+
+	ethPVLAN = 0x8100 // ETH_P_8021Q
+
+	icmpEchoRequest   = 8
+	icmpv6EchoRequest = 128
+)
+
+// ErrChecksum is returned by UnmarshalPacket() for a frame with an invalid IP
+// or L4 checksum.
+var ErrChecksum = errors.New("invalid checksum")
+
+// Packet describes an Ethernet frame carrying an IPv4 or IPv6 packet, to be
+// used as the input of networking programs. Frames built by other packet
+// libraries, such as gopacket.SerializeLayers(), can be given to the Run
+// functions as well, and their output checked with UnmarshalPacket().
+type Packet struct {
+	SrcMAC net.HardwareAddr // defaults to 00:00:00:00:00:00
+	DstMAC net.HardwareAddr // defaults to 00:00:00:00:00:00
+	// VLANID adds an 802.1Q tag with the VLAN ID, if not 0.
+	VLANID uint16
+	// Src and Dst select IPv4 or IPv6, both must be of the same family.
+	Src netip.AddrPort
+	Dst netip.AddrPort
+	// Proto is the IP protocol (syscall.IPPROTO_*). For protocols other than
+	// TCP and UDP, the ports are ignored and Payload holds the L4 header.
+	Proto    uint8
+	TCPFlags uint8  // defaults to SYN
+	Seq      uint32 // TCP sequence number
+	Ack      uint32 // TCP acknowledgment number
+	Window   uint16 // TCP window, defaults to 65535
+	TTL      uint8  // defaults to 64
+	TOS      uint8  // IPv4 TOS or IPv6 traffic class
+	Payload  []byte
+}
+
+// Marshal builds the frame, with valid IP and L4 checksums.
+func (p Packet) Marshal() ([]byte, error) {
+	src, dst := p.Src.Addr().Unmap(), p.Dst.Addr().Unmap()
+	if !src.IsValid() || !dst.IsValid() {
+		return nil, errors.New("invalid packet addresses")
+	}
+	if src.Is4() != dst.Is4() {
+		return nil, fmt.Errorf("packet addresses %v and %v of different families", src, dst)
+	}
+
+	l4 := p.marshalL4(src, dst)
+
+	ttl := p.TTL
+	if ttl == 0 {
+		ttl = 64
+	}
+
+	l2Len := ethHdrLen
+	if p.VLANID != 0 {
+		l2Len += vlanHdrLen
+	}
+
+	var b []byte
+	if src.Is4() {
+		b = make([]byte, l2Len+ipv4HdrLen, l2Len+ipv4HdrLen+len(l4))
+		binary.BigEndian.PutUint16(b[l2Len-2:], syscall.ETH_P_IP)
+
+		ip := b[l2Len:]
+		ip[0] = 0x45 // version 4, 5 words header
+		ip[1] = p.TOS
+		binary.BigEndian.PutUint16(ip[2:], uint16(ipv4HdrLen+len(l4)))
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // don't fragment
+		ip[8] = ttl
+		ip[9] = p.Proto
+		s, d := src.As4(), dst.As4()
+		copy(ip[12:], s[:])
+		copy(ip[16:], d[:])
+		binary.BigEndian.PutUint16(ip[10:], checksum(0, ip[:ipv4HdrLen]))
+	} else {
+		b = make([]byte, l2Len+ipv6HdrLen, l2Len+ipv6HdrLen+len(l4))
+		binary.BigEndian.PutUint16(b[l2Len-2:], syscall.ETH_P_IPV6)
+
+		ip := b[l2Len:]
+		binary.BigEndian.PutUint32(ip[0:], 6<<28|uint32(p.TOS)<<20) // version 6
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(l4)))
+		ip[6] = p.Proto
+		ip[7] = ttl
+		s, d := src.As16(), dst.As16()
+		copy(ip[8:], s[:])
+		copy(ip[24:], d[:])
+	}
+
+	copy(b[0:6], p.DstMAC)
+	copy(b[6:12], p.SrcMAC)
+	if p.VLANID != 0 {
+		binary.BigEndian.PutUint16(b[12:], ethPVLAN)
+		binary.BigEndian.PutUint16(b[14:], p.VLANID&0x0fff)
+	}
+
+	return append(b, l4...), nil
+}
+
+// marshalL4 builds the L4 header and payload.
+func (p Packet) marshalL4(src, dst netip.Addr) []byte {
+	var l4 []byte
+	var csumOffset int
+
+	switch p.Proto {
+	case syscall.IPPROTO_TCP:
+		l4 = make([]byte, tcpHdrLen, tcpHdrLen+len(p.Payload))
+		binary.BigEndian.PutUint16(l4[0:], p.Src.Port())
+		binary.BigEndian.PutUint16(l4[2:], p.Dst.Port())
+		binary.BigEndian.PutUint32(l4[4:], p.Seq)
+		binary.BigEndian.PutUint32(l4[8:], p.Ack)
+		l4[12] = (tcpHdrLen / 4) << 4
+		l4[13] = p.TCPFlags
+		if l4[13] == 0 {
+			l4[13] = TCPFlagSYN
+		}
+		window := p.Window
+		if window == 0 {
+			window = 0xffff
+		}
+		binary.BigEndian.PutUint16(l4[14:], window)
+		csumOffset = 16
+	case syscall.IPPROTO_UDP:
+		l4 = make([]byte, udpHdrLen, udpHdrLen+len(p.Payload))
+		binary.BigEndian.PutUint16(l4[0:], p.Src.Port())
+		binary.BigEndian.PutUint16(l4[2:], p.Dst.Port())
+		binary.BigEndian.PutUint16(l4[4:], uint16(udpHdrLen+len(p.Payload)))
+		csumOffset = 6
+	default:
+		return append([]byte(nil), p.Payload...)
+	}
+
+	l4 = append(l4, p.Payload...)
+
+	csum := checksum(pseudoHeaderSum(src, dst, p.Proto, len(l4)), l4)
+	if csum == 0 && p.Proto == syscall.IPPROTO_UDP {
+		csum = 0xffff // 0 means no checksum
+	}
+	binary.BigEndian.PutUint16(l4[csumOffset:], csum)
+
+	return l4
+}
+
+// pseudoHeaderSum returns the sum of the pseudo header covered by the TCP
+// and UDP checksums.
+func pseudoHeaderSum(src, dst netip.Addr, proto uint8, length int) uint32 {
+	var sum uint32
+
+	for _, addr := range []netip.Addr{src, dst} {
+		for _, w := range wordsOf(addr.AsSlice()) {
+			sum += uint32(w)
+		}
+	}
+	sum += uint32(proto)
+	sum += uint32(length) >> 16
+	sum += uint32(length) & 0xffff
+
+	return sum
+}
+
+func wordsOf(b []byte) []uint16 {
+	words := make([]uint16, 0, (len(b)+1)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		words = append(words, binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		words = append(words, uint16(b[len(b)-1])<<8)
+	}
+
+	return words
+}
+
+// checksum returns the internet checksum (RFC 1071) of b, starting from the
+// partial sum.
+func checksum(sum uint32, b []byte) uint16 {
+	for _, w := range wordsOf(b) {
+		sum += uint32(w)
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+
+	return ^uint16(sum)
+}
+
+// MustMarshal builds the frame, failing the test on error.
+func MustMarshal(tb testing.TB, p Packet) []byte {
+	tb.Helper()
+
+	b, err := p.Marshal()
+	if err != nil {
+		tb.Fatalf("failed to build packet: %v", err)
+	}
+
+	return b
+}
+
+// ICMPEcho returns the packet of an ICMP (IPv4) or ICMPv6 (IPv6) echo
+// request, with a valid ICMP checksum.
+func ICMPEcho(src, dst netip.Addr, id, seq uint16, data []byte) Packet {
+	src, dst = src.Unmap(), dst.Unmap()
+
+	icmp := make([]byte, icmpHdrLen, icmpHdrLen+len(data))
+	binary.BigEndian.PutUint16(icmp[4:], id)
+	binary.BigEndian.PutUint16(icmp[6:], seq)
+	icmp = append(icmp, data...)
+
+	p := Packet{
+		Src:     netip.AddrPortFrom(src, 0),
+		Dst:     netip.AddrPortFrom(dst, 0),
+		Payload: icmp,
+	}
+	if src.Is4() {
+		p.Proto = syscall.IPPROTO_ICMP
+		icmp[0] = icmpEchoRequest
+		binary.BigEndian.PutUint16(icmp[2:], checksum(0, icmp))
+	} else {
+		// ICMPv6 checksums cover a pseudo header, as TCP and UDP
+		p.Proto = syscall.IPPROTO_ICMPV6
+		icmp[0] = icmpv6EchoRequest
+		binary.BigEndian.PutUint16(icmp[2:], checksum(pseudoHeaderSum(src, dst, p.Proto, len(icmp)), icmp))
+	}
+
+	return p
+}
+
+// UnmarshalPacket parses an Ethernet frame carrying an IPv4 or IPv6 packet,
+// such as the output of a program rewriting packets, and checks its IPv4
+// header, TCP and UDP checksums (ErrChecksum). IPv6 extension headers are
+// not parsed: Proto is then the next header, and Payload starts with the
+// extension header. For protocols other than TCP and UDP, Payload holds the
+// L4 header.
+func UnmarshalPacket(b []byte) (Packet, error) {
+	var p Packet
+	if len(b) < ethHdrLen {
+		return p, fmt.Errorf("frame of %d bytes too short", len(b))
+	}
+
+	p.DstMAC = net.HardwareAddr(append([]byte(nil), b[0:6]...))
+	p.SrcMAC = net.HardwareAddr(append([]byte(nil), b[6:12]...))
+	ethType := binary.BigEndian.Uint16(b[12:])
+	b = b[ethHdrLen:]
+	if ethType == ethPVLAN {
+		if len(b) < vlanHdrLen {
+			return p, fmt.Errorf("802.1Q tag truncated")
+		}
+		p.VLANID = binary.BigEndian.Uint16(b) & 0x0fff
+		ethType = binary.BigEndian.Uint16(b[2:])
+		b = b[vlanHdrLen:]
+	}
+
+	var src, dst netip.Addr
+	switch ethType {
+	case syscall.ETH_P_IP:
+		if len(b) < ipv4HdrLen || b[0]>>4 != 4 {
+			return p, fmt.Errorf("invalid IPv4 header")
+		}
+		hdrLen := int(b[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(b[2:]))
+		if hdrLen < ipv4HdrLen || totalLen < hdrLen || totalLen > len(b) {
+			return p, fmt.Errorf("invalid IPv4 header length %d or total length %d", hdrLen, totalLen)
+		}
+		if checksum(0, b[:hdrLen]) != 0 {
+			return p, fmt.Errorf("IPv4 header: %w", ErrChecksum)
+		}
+		p.TOS = b[1]
+		p.TTL = b[8]
+		p.Proto = b[9]
+		src = netip.AddrFrom4([4]byte(b[12:16]))
+		dst = netip.AddrFrom4([4]byte(b[16:20]))
+		b = b[hdrLen:totalLen]
+	case syscall.ETH_P_IPV6:
+		if len(b) < ipv6HdrLen || b[0]>>4 != 6 {
+			return p, fmt.Errorf("invalid IPv6 header")
+		}
+		payloadLen := int(binary.BigEndian.Uint16(b[4:]))
+		if ipv6HdrLen+payloadLen > len(b) {
+			return p, fmt.Errorf("invalid IPv6 payload length %d", payloadLen)
+		}
+		p.TOS = uint8(binary.BigEndian.Uint32(b) >> 20)
+		p.Proto = b[6]
+		p.TTL = b[7]
+		src = netip.AddrFrom16([16]byte(b[8:24]))
+		dst = netip.AddrFrom16([16]byte(b[24:40]))
+		b = b[ipv6HdrLen : ipv6HdrLen+payloadLen]
+	default:
+		return p, fmt.Errorf("unsupported ethertype %#04x", ethType)
+	}
+
+	var srcPort, dstPort uint16
+	switch p.Proto {
+	case syscall.IPPROTO_TCP:
+		if len(b) < tcpHdrLen || int(b[12]>>4)*4 < tcpHdrLen || int(b[12]>>4)*4 > len(b) {
+			return p, fmt.Errorf("invalid TCP header")
+		}
+		if checksum(pseudoHeaderSum(src, dst, p.Proto, len(b)), b) != 0 {
+			return p, fmt.Errorf("TCP: %w", ErrChecksum)
+		}
+		srcPort, dstPort = binary.BigEndian.Uint16(b[0:]), binary.BigEndian.Uint16(b[2:])
+		p.Seq = binary.BigEndian.Uint32(b[4:])
+		p.Ack = binary.BigEndian.Uint32(b[8:])
+		p.TCPFlags = b[13]
+		p.Window = binary.BigEndian.Uint16(b[14:])
+		b = b[int(b[12]>>4)*4:]
+	case syscall.IPPROTO_UDP:
+		if len(b) < udpHdrLen || int(binary.BigEndian.Uint16(b[4:])) != len(b) {
+			return p, fmt.Errorf("invalid UDP header")
+		}
+		// A 0 checksum means none over IPv4
+		if binary.BigEndian.Uint16(b[6:]) != 0 || dst.Is6() {
+			if checksum(pseudoHeaderSum(src, dst, p.Proto, len(b)), b) != 0 {
+				return p, fmt.Errorf("UDP: %w", ErrChecksum)
+			}
+		}
+		srcPort, dstPort = binary.BigEndian.Uint16(b[0:]), binary.BigEndian.Uint16(b[2:])
+		b = b[udpHdrLen:]
+	}
+
+	p.Src = netip.AddrPortFrom(src, srcPort)
+	p.Dst = netip.AddrPortFrom(dst, dstPort)
+	if len(b) > 0 {
+		p.Payload = append([]byte(nil), b...)
+	}
+
+	return p, nil
+}
+
+// MustUnmarshal parses the frame, failing the test on error.
+func MustUnmarshal(tb testing.TB, b []byte) Packet {
+	tb.Helper()
+
+	p, err := UnmarshalPacket(b)
+	if err != nil {
+		tb.Fatalf("failed to parse packet: %v", err)
+	}
+
+	return p
+}
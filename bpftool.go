@@ -0,0 +1,168 @@
+package libbpfgo
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+//
+// bpftool compatible JSON
+//
+// The MarshalBpftoolJSON() methods produce the same JSON objects as
+// "bpftool {map,prog,link} show --json", so tooling parsing bpftool output
+// can consume the information reported by libbpfgo users unchanged. Fields
+// bpftool gathers from other sources (e.g. fdinfo, pinned paths) are omitted.
+//
+
+// bpftoolTypeName returns the libbpf name of a type, or its numeric value if
+// libbpf does not know it, as bpftool does.
+func bpftoolTypeName(name string, value uint32) interface{} {
+	if name == "" {
+		return value
+	}
+
+	return name
+}
+
+type bpftoolDev struct {
+	IfIndex uint32 `json:"ifindex"`
+	NsDev   uint64 `json:"ns_dev"`
+	NsInode uint64 `json:"ns_inode"`
+}
+
+type bpftoolMap struct {
+	ID         uint32      `json:"id"`
+	Type       interface{} `json:"type"`
+	Name       string      `json:"name"`
+	Flags      uint32      `json:"flags"`
+	Dev        *bpftoolDev `json:"dev,omitempty"`
+	BytesKey   uint32      `json:"bytes_key"`
+	BytesValue uint32      `json:"bytes_value"`
+	MaxEntries uint32      `json:"max_entries"`
+	MapExtra   uint64      `json:"map_extra,omitempty"`
+	BTFID      uint32      `json:"btf_id,omitempty"`
+}
+
+// MarshalBpftoolJSON encodes the map information as "bpftool map show --json"
+// does.
+func (i *BPFMapInfo) MarshalBpftoolJSON() ([]byte, error) {
+	m := bpftoolMap{
+		ID:         i.ID,
+		Type:       bpftoolTypeName(i.Type.Name(), uint32(i.Type)),
+		Name:       i.Name,
+		Flags:      i.MapFlags,
+		BytesKey:   i.KeySize,
+		BytesValue: i.ValueSize,
+		MaxEntries: i.MaxEntries,
+		MapExtra:   i.MapExtra,
+		BTFID:      i.BTFID,
+	}
+	if i.IfIndex != 0 {
+		m.Dev = &bpftoolDev{
+			IfIndex: i.IfIndex,
+			NsDev:   i.NetnsDev,
+			NsInode: i.NetnsIno,
+		}
+	}
+
+	return json.Marshal(m)
+}
+
+type bpftoolProg struct {
+	ID              uint32      `json:"id"`
+	Type            interface{} `json:"type"`
+	Name            string      `json:"name,omitempty"`
+	Tag             string      `json:"tag"`
+	GPLCompatible   bool        `json:"gpl_compatible"`
+	RunTimeNs       uint64      `json:"run_time_ns,omitempty"`
+	RunCnt          uint64      `json:"run_cnt,omitempty"`
+	RecursionMisses uint64      `json:"recursion_misses,omitempty"`
+	LoadedAt        int64       `json:"loaded_at,omitempty"`
+	UID             uint32      `json:"uid"`
+	BytesXlated     uint32      `json:"bytes_xlated"`
+	Jited           bool        `json:"jited"`
+	BytesJited      uint32      `json:"bytes_jited,omitempty"`
+	MapIDs          []uint32    `json:"map_ids,omitempty"`
+	BTFID           uint32      `json:"btf_id,omitempty"`
+	VerifiedInsns   uint32      `json:"verified_insns,omitempty"`
+}
+
+// MarshalBpftoolJSON encodes the program information as "bpftool prog show
+// --json" does.
+func (i *BPFProgInfo) MarshalBpftoolJSON() ([]byte, error) {
+	p := bpftoolProg{
+		ID:              i.ID,
+		Type:            bpftoolTypeName(i.Type.Name(), uint32(i.Type)),
+		Name:            i.Name,
+		Tag:             hex.EncodeToString(i.Tag[:]),
+		GPLCompatible:   i.GPLCompatible,
+		RunTimeNs:       i.RunTimeNs,
+		RunCnt:          i.RunCnt,
+		RecursionMisses: i.RecursionMisses,
+		UID:             i.CreatedByUID,
+		BytesXlated:     i.XlatedProgLen,
+		Jited:           i.JitedProgLen > 0,
+		BytesJited:      i.JitedProgLen,
+		MapIDs:          i.MapIDs,
+		BTFID:           i.BTFID,
+		VerifiedInsns:   i.VerifiedInsns,
+	}
+	if i.LoadTime != 0 {
+		p.LoadedAt = bootTimeToUnix(i.LoadTime)
+	}
+
+	return json.Marshal(p)
+}
+
+type bpftoolLink struct {
+	ID          uint32      `json:"id"`
+	Type        interface{} `json:"type"`
+	ProgID      uint32      `json:"prog_id"`
+	AttachType  interface{} `json:"attach_type,omitempty"`
+	TargetObjID uint32      `json:"target_obj_id,omitempty"`
+	TargetBTFID uint32      `json:"target_btf_id,omitempty"`
+	CgroupID    uint64      `json:"cgroup_id,omitempty"`
+	NetnsIno    uint32      `json:"netns_ino,omitempty"`
+	IfIndex     uint32      `json:"ifindex,omitempty"`
+}
+
+// MarshalBpftoolJSON encodes the link information as "bpftool link show
+// --json" does.
+func (i *BPFLinkInfo) MarshalBpftoolJSON() ([]byte, error) {
+	l := bpftoolLink{
+		ID:          i.ID,
+		Type:        bpftoolTypeName(i.Type.Name(), uint32(i.Type)),
+		ProgID:      i.ProgID,
+		TargetObjID: i.TargetObjID,
+		TargetBTFID: i.TargetBTFID,
+		CgroupID:    i.CgroupID,
+		NetnsIno:    i.NetnsIno,
+		IfIndex:     i.IfIndex,
+	}
+
+	switch i.Type {
+	case BPFLinkTypeTracing, BPFLinkTypeCgroup, BPFLinkTypeNetns:
+		l.AttachType = bpftoolTypeName(i.AttachType.Name(), uint32(i.AttachType))
+	}
+
+	return json.Marshal(l)
+}
+
+// bootTimeToUnix converts a CLOCK_BOOTTIME timestamp, in nanoseconds, into
+// seconds since the Unix epoch.
+func bootTimeToUnix(ns uint64) int64 {
+	const clockBoottime = 7 // CLOCK_BOOTTIME
+
+	var ts syscall.Timespec
+	_, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockBoottime, uintptr(unsafe.Pointer(&ts)), 0)
+	if errno != 0 {
+		return 0
+	}
+
+	bootTime := time.Now().UnixNano() - ts.Nano()
+
+	return (bootTime + int64(ns)) / int64(time.Second)
+}
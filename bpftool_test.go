@@ -0,0 +1,153 @@
+package libbpfgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBPFMapInfoMarshalBpftoolJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		info     BPFMapInfo
+		expected string
+	}{
+		{
+			name: "hash",
+			info: BPFMapInfo{
+				Type:       MapTypeHash,
+				ID:         12,
+				Name:       "events",
+				MapFlags:   1,
+				KeySize:    4,
+				ValueSize:  8,
+				MaxEntries: 1024,
+				BTFID:      5,
+			},
+			expected: `{"id":12,"type":"hash","name":"events","flags":1,"bytes_key":4,"bytes_value":8,"max_entries":1024,"btf_id":5}`,
+		},
+		{
+			name: "offloaded",
+			info: BPFMapInfo{
+				Type:       MapTypeArray,
+				ID:         13,
+				Name:       "offloaded",
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+				IfIndex:    3,
+				NetnsDev:   4,
+				NetnsIno:   4026531840,
+				MapExtra:   7,
+			},
+			expected: `{"id":13,"type":"array","name":"offloaded","flags":0,"dev":{"ifindex":3,"ns_dev":4,"ns_inode":4026531840},"bytes_key":4,"bytes_value":4,"max_entries":1,"map_extra":7}`,
+		},
+		{
+			name:     "unknown type",
+			info:     BPFMapInfo{Type: MapType(0xffff), ID: 14, Name: "new"},
+			expected: `{"id":14,"type":65535,"name":"new","flags":0,"bytes_key":0,"bytes_value":0,"max_entries":0}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := tc.info.MarshalBpftoolJSON()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestBPFProgInfoMarshalBpftoolJSON(t *testing.T) {
+	info := BPFProgInfo{
+		Type:            BPFProgTypeKprobe,
+		ID:              42,
+		Tag:             [8]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		Name:            "kprobe__sys_mmap",
+		CreatedByUID:    1000,
+		MapIDs:          []uint32{12, 13},
+		JitedProgLen:    80,
+		XlatedProgLen:   120,
+		GPLCompatible:   true,
+		BTFID:           5,
+		RunTimeNs:       100,
+		RunCnt:          2,
+		RecursionMisses: 1,
+		VerifiedInsns:   15,
+	}
+
+	data, err := info.MarshalBpftoolJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":42,"type":"kprobe","name":"kprobe__sys_mmap","tag":"0123456789abcdef","gpl_compatible":true,`+
+		`"run_time_ns":100,"run_cnt":2,"recursion_misses":1,"uid":1000,"bytes_xlated":120,"jited":true,"bytes_jited":80,`+
+		`"map_ids":[12,13],"btf_id":5,"verified_insns":15}`, string(data))
+
+	// Not jited, without name and stats
+	info = BPFProgInfo{Type: BPFProgTypeKprobe, ID: 43, XlatedProgLen: 16}
+	data, err = info.MarshalBpftoolJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":43,"type":"kprobe","tag":"0000000000000000","gpl_compatible":false,"uid":0,"bytes_xlated":16,"jited":false}`, string(data))
+}
+
+func TestBPFLinkInfoMarshalBpftoolJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		info     BPFLinkInfo
+		expected string
+	}{
+		{
+			name: "tracing",
+			info: BPFLinkInfo{
+				Type:        BPFLinkTypeTracing,
+				ID:          7,
+				ProgID:      42,
+				AttachType:  BPFAttachTypeTraceFentry,
+				TargetObjID: 1,
+				TargetBTFID: 100,
+			},
+			expected: `{"id":7,"type":"tracing","prog_id":42,"attach_type":"trace_fentry","target_obj_id":1,"target_btf_id":100}`,
+		},
+		{
+			name: "cgroup",
+			info: BPFLinkInfo{
+				Type:       BPFLinkTypeCgroup,
+				ID:         8,
+				ProgID:     43,
+				AttachType: BPFAttachTypeCgroupInetIngress,
+				CgroupID:   1234,
+			},
+			expected: `{"id":8,"type":"cgroup","prog_id":43,"attach_type":"cgroup_inet_ingress","cgroup_id":1234}`,
+		},
+		{
+			// bpftool does not report the attach type of the other links
+			name: "xdp",
+			info: BPFLinkInfo{
+				Type:       BPFLinkTypeXDP,
+				ID:         9,
+				ProgID:     44,
+				AttachType: BPFAttachTypeXDP,
+				IfIndex:    2,
+			},
+			expected: `{"id":9,"type":"xdp","prog_id":44,"ifindex":2}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := tc.info.MarshalBpftoolJSON()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestBootTimeToUnix(t *testing.T) {
+	// The boot, at boot time zero, is in the past
+	bootTime := bootTimeToUnix(0)
+	assert.Positive(t, bootTime)
+	assert.LessOrEqual(t, bootTime, time.Now().Unix())
+
+	assert.InDelta(t, bootTime+60, bootTimeToUnix(uint64(time.Minute)), 1)
+}
@@ -24,3 +24,61 @@ func GetBTFFDByID(id uint32) (int, error) {
 
 	return int(fdC), nil
 }
+
+// btfTypeNameMaxDepth bounds the types followed by btfTypeName, against
+// malformed BTF.
+const btfTypeNameMaxDepth = 32
+
+// btfTypeName returns the C name of the BTF type: the name of integers and
+// typedefs, "struct name" for structs, unions and enums, and the declarators
+// of pointers and arrays (e.g. "u8[16]", "struct task_struct *"). Anonymous
+// types are named "struct {...}".
+func btfTypeName(btf *C.struct_btf, typeID uint32) string {
+	return btfTypeNameDepth(btf, C.__u32(typeID), 0)
+}
+
+func btfTypeNameDepth(btf *C.struct_btf, id C.__u32, depth int) string {
+	if id == 0 {
+		return "void"
+	}
+	if depth >= btfTypeNameMaxDepth {
+		return "..."
+	}
+
+	name := C.GoString(C.cgo_btf_type_name(btf, id))
+
+	switch kind := C.cgo_btf_type_kind(btf, id); kind {
+	case C.BTF_KIND_STRUCT, C.BTF_KIND_UNION, C.BTF_KIND_ENUM, C.BTF_KIND_ENUM64, C.BTF_KIND_FWD:
+		keyword := "struct"
+		switch kind {
+		case C.BTF_KIND_UNION:
+			keyword = "union"
+		case C.BTF_KIND_ENUM, C.BTF_KIND_ENUM64:
+			keyword = "enum"
+		}
+		if name == "" {
+			return keyword + " {...}"
+		}
+		return keyword + " " + name
+
+	case C.BTF_KIND_CONST:
+		return "const " + btfTypeNameDepth(btf, C.cgo_btf_type_ref(btf, id), depth+1)
+	case C.BTF_KIND_VOLATILE:
+		return "volatile " + btfTypeNameDepth(btf, C.cgo_btf_type_ref(btf, id), depth+1)
+	case C.BTF_KIND_RESTRICT, C.BTF_KIND_TYPE_TAG:
+		return btfTypeNameDepth(btf, C.cgo_btf_type_ref(btf, id), depth+1)
+
+	case C.BTF_KIND_PTR:
+		return btfTypeNameDepth(btf, C.cgo_btf_ptr_type(btf, id), depth+1) + " *"
+	case C.BTF_KIND_ARRAY:
+		elem := btfTypeNameDepth(btf, C.cgo_btf_array_type(btf, id), depth+1)
+		return fmt.Sprintf("%s[%d]", elem, uint32(C.cgo_btf_array_nelems(btf, id)))
+	}
+
+	// Integers, floats and typedefs are named
+	if name == "" {
+		return fmt.Sprintf("type %d", uint32(id))
+	}
+
+	return name
+}
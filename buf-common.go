@@ -6,11 +6,144 @@ package libbpfgo
 */
 import "C"
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
 const (
 	// Maximum number of channels (RingBuffers + PerfBuffers) supported
 	maxEventChannels = 512
 )
 
+// DefaultPollTimeout is the timeout (in milliseconds) used by the deprecated
+// Start() methods, and by Poll() when a negative timeout is given but the
+// buffer can not be waited for with the Go runtime poller.
+const DefaultPollTimeout = 300
+
 var (
 	eventChannels = newRWArray(maxEventChannels)
 )
+
+//
+// pollWaker
+//
+
+// pollWaker waits for a file descriptor to be readable without blocking an
+// OS thread, and allows the waiting goroutine to be woken up. libbpf buffers
+// expose their epoll fd, which is itself pollable: a duplicate of it is
+// registered with the Go runtime poller, which parks the waiting goroutine
+// instead of keeping a thread blocked in epoll_wait() for each buffer. A
+// service polling many buffers then only needs threads for the short cgo
+// calls consuming the records.
+//
+// The runtime poller is edge-triggered: after wait() returns true, the
+// caller must consume all available data, or the rest may not be reported.
+type pollWaker struct {
+	file *os.File
+	conn syscall.RawConn
+}
+
+func newPollWaker(fd int) (*pollWaker, error) {
+	dupFD, err := DupFD(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	// The runtime poller only takes non-blocking fds. The flag is shared with
+	// fd, which epoll_wait() and the non-blocking readers of libbpfgo ignore.
+	if err := syscall.SetNonblock(dupFD, true); err != nil {
+		_ = syscall.Close(dupFD)
+		return nil, fmt.Errorf("failed to set fd %d non-blocking: %w", fd, err)
+	}
+
+	file := os.NewFile(uintptr(dupFD), fmt.Sprintf("libbpfgo-poll-%d", fd))
+	// Setting a deadline fails if the fd is not in the runtime poller
+	if err := file.SetReadDeadline(time.Time{}); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to add fd %d to the runtime poller: %w", fd, err)
+	}
+
+	conn, err := file.SyscallConn()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to add fd %d to the runtime poller: %w", fd, err)
+	}
+
+	return &pollWaker{file: file, conn: conn}, nil
+}
+
+// wait blocks until the fd is readable, returning true, or until wake() is
+// called, returning false.
+func (w *pollWaker) wait() (bool, error) {
+	waited := false
+	err := w.conn.Read(func(uintptr) bool {
+		// Returning false parks the goroutine until the fd is readable
+		if waited {
+			return true
+		}
+		waited = true
+
+		return false
+	})
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to wait for fd: %w", err)
+	}
+
+	return true, nil
+}
+
+// wake wakes up the goroutine blocked in wait(), and makes the following
+// calls to wait() return immediately.
+func (w *pollWaker) wake() error {
+	return w.file.SetReadDeadline(time.Unix(1, 0))
+}
+
+func (w *pollWaker) close() {
+	_ = w.file.Close()
+}
+
+//
+// Poll goroutines
+//
+
+// runPoll runs the poll function in a goroutine tracked by wg. Its error, if
+// any, is sent to the returned channel, closed once it returned.
+func runPoll(wg *sync.WaitGroup, poll func() error) <-chan error {
+	errs := make(chan error, 1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(errs)
+
+		if err := poll(); err != nil {
+			errs <- err
+		}
+	}()
+
+	return errs
+}
+
+// stopOnDone calls stop once ctx is done, unless stopped is closed first.
+func stopOnDone(ctx context.Context, stopped <-chan struct{}, stop func()) {
+	if ctx.Done() == nil {
+		return
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-stopped:
+		}
+	}()
+}
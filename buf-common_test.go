@@ -0,0 +1,147 @@
+package libbpfgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollWaker(t *testing.T) {
+	// A pipe stands in for the buffer epoll fd.
+	var p [2]int
+	require.NoError(t, syscall.Pipe(p[:]))
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+
+	w, err := newPollWaker(p[0])
+	require.NoError(t, err)
+	defer w.close()
+
+	_, err = syscall.Write(p[1], []byte{0})
+	require.NoError(t, err)
+
+	ready, err := w.wait()
+	require.NoError(t, err)
+	assert.True(t, ready, "expected buffer to be ready")
+
+	_, err = syscall.Read(p[0], make([]byte, 1))
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = w.wake()
+	}()
+
+	ready, err = w.wait()
+	require.NoError(t, err)
+	assert.False(t, ready, "expected to be woken up")
+}
+
+func TestPollWakerEpoll(t *testing.T) {
+	// Like a libbpf buffer, an epoll instance watching the data fd
+	var p [2]int
+	require.NoError(t, syscall.Pipe2(p[:], syscall.O_CLOEXEC|syscall.O_NONBLOCK))
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	require.NoError(t, err)
+	defer syscall.Close(epfd)
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(p[0])}
+	require.NoError(t, syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, p[0], &event))
+
+	w, err := newPollWaker(epfd)
+	require.NoError(t, err)
+	defer w.close()
+
+	for i := 0; i < 3; i++ {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = syscall.Write(p[1], []byte{0})
+		}()
+
+		ready, err := w.wait()
+		require.NoError(t, err)
+		assert.True(t, ready, "expected buffer to be ready")
+
+		// Consume everything, the runtime poller is edge-triggered
+		_, err = syscall.Read(p[0], make([]byte, 16))
+		require.NoError(t, err)
+	}
+}
+
+func TestPollWakerWokenBeforeWait(t *testing.T) {
+	var p [2]int
+	require.NoError(t, syscall.Pipe(p[:]))
+	defer syscall.Close(p[0])
+	defer syscall.Close(p[1])
+
+	w, err := newPollWaker(p[0])
+	require.NoError(t, err)
+	defer w.close()
+
+	require.NoError(t, w.wake())
+
+	// Every wait returns once woken up
+	for i := 0; i < 2; i++ {
+		ready, err := w.wait()
+		require.NoError(t, err)
+		assert.False(t, ready)
+	}
+}
+
+func TestRunPoll(t *testing.T) {
+	var wg sync.WaitGroup
+
+	errPoll := errors.New("poll failed")
+	errs := runPoll(&wg, func() error { return errPoll })
+	wg.Wait()
+	assert.Equal(t, errPoll, <-errs)
+	_, open := <-errs
+	assert.False(t, open)
+
+	// No error, the channel is only closed
+	errs = runPoll(&wg, func() error { return nil })
+	wg.Wait()
+	_, open = <-errs
+	assert.False(t, open)
+}
+
+func TestStopOnDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopCalled := make(chan struct{})
+	stopOnDone(ctx, make(chan struct{}), func() { close(stopCalled) })
+
+	cancel()
+	select {
+	case <-stopCalled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop not called once the context is done")
+	}
+
+	// Stopped before the context is done
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan struct{})
+	called := false
+	var mu sync.Mutex
+	stopOnDone(ctx, stopped, func() {
+		mu.Lock()
+		called = true
+		mu.Unlock()
+	})
+	close(stopped)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.False(t, called)
+}
@@ -7,7 +7,9 @@ package libbpfgo
 import "C"
 
 import (
+	"context"
 	"fmt"
+	"math/bits"
 	"sync"
 	"syscall"
 )
@@ -20,79 +22,300 @@ type PerfBuffer struct {
 	pb         *C.struct_perf_buffer
 	bpfMap     *BPFMap
 	slot       uint
+	pageCnt    int // data pages per CPU buffer
 	eventsChan chan []byte
 	lostChan   chan uint64
-	stop       chan struct{}
+	sampleFn   func(cpu int, data []byte) // used instead of eventsChan, if set
+	lostFn     func(cpu int, cnt uint64)  // used instead of lostChan, if set
+	stop       chan struct{}              // signals the poll goroutine to exit
+	done       chan struct{}              // abandons deliveries blocked on eventsChan/lostChan
+	doneOnce   sync.Once
+	waker      *pollWaker // set when polling with the runtime poller
+	limiter    *EventLimiter
+	polling    bool
+	stopped    bool
 	closed     bool
+	mu         sync.Mutex
 	wg         sync.WaitGroup
 }
 
-// Poll will wait until timeout in milliseconds to gather
-// data from the perf buffer.
+// validatePerfBufPageCnt checks the number of data pages of each per CPU
+// buffer, which the kernel requires to be a power of two.
+func validatePerfBufPageCnt(pageCnt int) error {
+	if pageCnt <= 0 {
+		return fmt.Errorf("invalid perf buffer page count %d: must be a positive power of two: %w", pageCnt, syscall.EINVAL)
+	}
+	if pageCnt&(pageCnt-1) != 0 {
+		lower := 1 << (bits.Len(uint(pageCnt)) - 1)
+		return fmt.Errorf("invalid perf buffer page count %d: must be a power of two, such as %d or %d: %w",
+			pageCnt, lower, lower<<1, syscall.EINVAL)
+	}
+
+	return nil
+}
+
+// PageCount returns the number of data pages of each per CPU buffer.
+func (pb *PerfBuffer) PageCount() int {
+	return pb.pageCnt
+}
+
+// PerCPUSize returns the size in bytes of the data area of each per CPU
+// buffer.
+func (pb *PerfBuffer) PerCPUSize() int {
+	return pb.pageCnt * syscall.Getpagesize()
+}
+
+// BufferCount returns the number of per CPU buffers, one per possible CPU
+// present in the perf event array.
+func (pb *PerfBuffer) BufferCount() int {
+	return int(C.perf_buffer__buffer_cnt(pb.pb))
+}
+
+// TotalSize returns the memory in bytes mapped by the perf buffer: the data
+// area and the metadata page of every per CPU buffer.
+func (pb *PerfBuffer) TotalSize() int {
+	return pb.BufferCount() * (pb.pageCnt + 1) * syscall.Getpagesize()
+}
+
+// Poll starts a goroutine delivering the data of the perf buffer to the
+// events channel.
+//
+// The goroutine waits for data with the Go runtime poller, parked without
+// holding an OS thread and without waking up periodically, and Stop() wakes
+// it up so it can exit. If the buffer can not be added to the runtime poller,
+// the goroutine falls back to polling the buffer in libbpf, waking up every
+// timeout milliseconds (DefaultPollTimeout if negative).
 func (pb *PerfBuffer) Poll(timeout int) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if pb.polling || pb.stopped {
+		return
+	}
+
+	pb.pollLocked(timeout)
+}
+
+// StartCtx starts polling the perf buffer as Poll(-1), until ctx is done or
+// Stop() is called, which then closes the events channel. A poll failure,
+// which ends the polling, is sent to the returned channel, closed once the
+// polling ended.
+func (pb *PerfBuffer) StartCtx(ctx context.Context) (<-chan error, error) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if pb.polling || pb.stopped {
+		return nil, fmt.Errorf("failed to start perf buffer: already polled")
+	}
+
+	errs := pb.pollLocked(-1)
+	stopOnDone(ctx, pb.stop, pb.Stop)
+
+	return errs, nil
+}
+
+// pollLocked starts the poll goroutine, and returns the channel of its error.
+func (pb *PerfBuffer) pollLocked(timeout int) <-chan error {
+	pb.polling = true
 	pb.stop = make(chan struct{})
-	pb.wg.Add(1)
-	go pb.poll(timeout)
+	emitBuffer(ModuleEventBufferStarted, pb.bpfMap)
+
+	waker, err := newPollWaker(int(C.perf_buffer__epoll_fd(pb.pb)))
+	if err == nil {
+		pb.waker = waker
+
+		return runPoll(&pb.wg, pb.pollWait)
+	}
+
+	// A poll blocking forever could not be woken up by Stop()
+	if timeout < 0 {
+		timeout = DefaultPollTimeout
+	}
+
+	return runPoll(&pb.wg, func() error { return pb.poll(timeout) })
 }
 
-// Deprecated: use PerfBuffer.Poll() instead.
+// SetEventLimiter sets the EventLimiter deciding which records are passed
+// to the consumer, or removes it if nil. It must be called before Poll().
+func (pb *PerfBuffer) SetEventLimiter(limiter *EventLimiter) error {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if pb.polling {
+		return fmt.Errorf("failed to set event limiter: perf buffer already polled")
+	}
+	pb.limiter = limiter
+
+	return nil
+}
+
+// Deprecated: use PerfBuffer.Poll() or PerfBuffer.StartCtx() instead.
 func (pb *PerfBuffer) Start() {
-	pb.Poll(300)
+	pb.Poll(DefaultPollTimeout)
 }
 
+// Stop stops polling the perf buffer and closes the event and lost channels.
+// Samples that could not be delivered yet are dropped (see PerfBuffer.Drain()).
+//
+// It is safe to call Stop multiple times, and it does not block if the
+// consumer already stopped reading from the channels.
 func (pb *PerfBuffer) Stop() {
-	if pb.stop == nil {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	pb.stopLocked()
+}
+
+func (pb *PerfBuffer) stopLocked() {
+	if !pb.polling || pb.stopped {
 		return
 	}
 
-	// Signal the poll goroutine to exit
+	// Signal the poll goroutine to exit and abandon any delivery in progress.
+	// The consumer may have stopped at this point, so a callback blocked on a
+	// full channel would otherwise deadlock the poll goroutine.
 	close(pb.stop)
+	pb.abandon()
+	pb.wakePoll()
 
-	// The event and lost channels should be drained here since the consumer
-	// may have stopped at this point. Failure to drain it will
-	// result in a deadlock: the channel will fill up and the poll
-	// goroutine will block in the callback.
-	go func() {
-		// revive:disable:empty-block
-		for range pb.eventsChan {
-		}
+	// Wait for the poll goroutine to exit
+	pb.wg.Wait()
+	pb.closeWaker()
 
-		if pb.lostChan != nil {
-			for range pb.lostChan {
-			}
+	// Close the channels -- this is useful for the consumer to know that no
+	// more events will be sent.
+	pb.closeChannels()
+	pb.stopped = true
+	emitBuffer(ModuleEventBufferStopped, pb.bpfMap)
+}
+
+// Drain stops polling the perf buffer, delivers the samples already produced
+// to the events channel and then closes the channels. The consumer must keep
+// reading from the channels until they are closed.
+//
+// If ctx is done before all samples are delivered, the remaining ones are
+// dropped and the context error is returned.
+func (pb *PerfBuffer) Drain(ctx context.Context) error {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if pb.stopped || pb.closed {
+		return nil
+	}
+
+	drained := make(chan struct{})
+	defer close(drained)
+	go func() {
+		select {
+		case <-ctx.Done():
+			pb.abandon()
+		case <-drained:
 		}
-		// revive:enable:empty-block
 	}()
 
-	// Wait for the poll goroutine to exit
-	pb.wg.Wait()
+	if pb.polling {
+		close(pb.stop)
+		pb.wakePoll()
+		pb.wg.Wait()
+		pb.closeWaker()
+	}
 
-	// Close the channel -- this is useful for the consumer but
-	// also to terminate the drain goroutine above.
-	close(pb.eventsChan)
-	if pb.lostChan != nil {
-		close(pb.lostChan)
+	var err error
+	retC := C.perf_buffer__consume(pb.pb)
+	if retC < 0 {
+		err = fmt.Errorf("error draining perf buffer: %w", syscall.Errno(-retC))
+	}
+
+	pb.abandon()
+	pb.closeChannels()
+	pb.stopped = true
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
 	}
 
-	// Reset pb.stop to allow multiple safe calls to Stop()
-	pb.stop = nil
+	return err
 }
 
+// Close stops the perf buffer and frees its resources. It is safe to call
+// Close multiple times.
 func (pb *PerfBuffer) Close() {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
 	if pb.closed {
 		return
 	}
 
-	pb.Stop()
+	pb.stopLocked()
 	C.perf_buffer__free(pb.pb)
 	eventChannels.remove(pb.slot)
 	pb.closed = true
 }
 
+func (pb *PerfBuffer) closeChannels() {
+	if pb.eventsChan != nil {
+		close(pb.eventsChan)
+	}
+	if pb.lostChan != nil {
+		close(pb.lostChan)
+	}
+}
+
+// wakePoll wakes up the poll goroutine if it waits with the runtime poller.
+func (pb *PerfBuffer) wakePoll() {
+	if pb.waker != nil {
+		_ = pb.waker.wake()
+	}
+}
+
+func (pb *PerfBuffer) closeWaker() {
+	if pb.waker != nil {
+		pb.waker.close()
+		pb.waker = nil
+	}
+}
+
+// abandon unblocks the callbacks waiting to deliver samples.
+func (pb *PerfBuffer) abandon() {
+	pb.doneOnce.Do(func() {
+		close(pb.done)
+	})
+}
+
+// deliver sends the sample to the events channel unless deliveries were
+// abandoned. It is called from the perf buffer sample callback.
+func (pb *PerfBuffer) deliver(data []byte) {
+	select {
+	case pb.eventsChan <- data:
+	case <-pb.done:
+	}
+}
+
+// deliverLost sends the lost samples count to the lost channel, if any, unless
+// deliveries were abandoned. It is called from the perf buffer lost callback.
+func (pb *PerfBuffer) deliverLost(cnt uint64) {
+	if pb.lostChan == nil {
+		return
+	}
+
+	select {
+	case pb.lostChan <- cnt:
+	case <-pb.done:
+	}
+}
+
+func (pb *PerfBuffer) isStopped() bool {
+	select {
+	case <-pb.stop:
+		return true
+	default:
+		return false
+	}
+}
+
 // todo: consider writing the perf polling in go as c to go calls (callback) are expensive
 func (pb *PerfBuffer) poll(timeout int) error {
-	defer pb.wg.Done()
-
 	for {
 		select {
 		case <-pb.stop:
@@ -110,3 +333,27 @@ func (pb *PerfBuffer) poll(timeout int) error {
 		}
 	}
 }
+
+// pollWait waits for data with the runtime poller, relying on the waker to
+// be woken up when stopping.
+func (pb *PerfBuffer) pollWait() error {
+	for {
+		ready, err := pb.waker.wait()
+		if err != nil {
+			return fmt.Errorf("error polling perf buffer: %w", err)
+		}
+		if !ready || pb.isStopped() {
+			return nil
+		}
+
+		retC := C.perf_buffer__consume(pb.pb)
+		if retC < 0 {
+			errno := syscall.Errno(-retC)
+			if errno == syscall.EINTR {
+				continue
+			}
+
+			return fmt.Errorf("error polling perf buffer: %w", errno)
+		}
+	}
+}
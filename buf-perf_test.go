@@ -0,0 +1,20 @@
+package libbpfgo
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePerfBufPageCnt(t *testing.T) {
+	for _, pageCnt := range []int{1, 2, 8, 64, 1024} {
+		assert.NoError(t, validatePerfBufPageCnt(pageCnt), pageCnt)
+	}
+
+	for _, pageCnt := range []int{0, -1, 3, 6, 100} {
+		assert.ErrorIs(t, validatePerfBufPageCnt(pageCnt), syscall.EINVAL, pageCnt)
+	}
+
+	assert.ErrorContains(t, validatePerfBufPageCnt(100), "such as 64 or 128")
+}
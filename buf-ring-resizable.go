@@ -0,0 +1,228 @@
+package libbpfgo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+//
+// ResizableRingBuf
+//
+// The size of a ring buffer map is fixed at creation. To be resizable at
+// runtime, the producers reach the ring buffer through a map-in-map, and
+// swapping the inner map moves them to a new ring buffer:
+//
+//	struct ringbuf {
+//	    __uint(type, BPF_MAP_TYPE_RINGBUF);
+//	    __uint(max_entries, 4096);
+//	};
+//
+//	struct {
+//	    __uint(type, BPF_MAP_TYPE_ARRAY_OF_MAPS);
+//	    __uint(max_entries, 1);
+//	    __type(key, u32);
+//	    __array(values, struct ringbuf);
+//	} events SEC(".maps");
+//
+//	u32 zero = 0;
+//	void *rb = bpf_map_lookup_elem(&events, &zero);
+//	if (rb)
+//	    bpf_ringbuf_output(rb, &event, sizeof(event), 0);
+//
+// Ring buffers of any size can replace the inner map template since v5.10.
+// Updating a map-in-map waits for the running programs to finish, so once the
+// new ring buffer is in place nothing writes to the old one anymore, which is
+// then drained and freed.
+//
+
+// ResizableRingBuf is a ring buffer reached through a map-in-map, which can
+// be replaced by a ring buffer of another size without losing records.
+type ResizableRingBuf struct {
+	outer      *BPFMap
+	key        uint32
+	eventsChan chan []byte
+	rb         *RingBuffer
+	ringFD     int
+	size       int
+	timeout    int
+	limiter    *EventLimiter
+	polling    bool
+	closed     bool
+	mu         sync.Mutex
+}
+
+// validateRingBufSize checks the size of a ring buffer map, which the kernel
+// requires to be a power of two multiple of the page size.
+func validateRingBufSize(size int) error {
+	if size < syscall.Getpagesize() || size&(size-1) != 0 {
+		return fmt.Errorf("invalid ring buffer size %d: must be a power of two multiple of the page size (%d): %w",
+			size, syscall.Getpagesize(), syscall.EINVAL)
+	}
+
+	return nil
+}
+
+// InitResizableRingBuf creates a ring buffer of the given size, stores it at
+// key in the outer map (an array or hash of maps with u32 keys) and sends its
+// records to eventsChan. The events channel is closed by Close().
+func (m *Module) InitResizableRingBuf(outerMapName string, key uint32, size int, eventsChan chan []byte) (*ResizableRingBuf, error) {
+	if eventsChan == nil {
+		return nil, fmt.Errorf("events channel can not be nil")
+	}
+
+	outer, err := m.GetMap(outerMapName)
+	if err != nil {
+		return nil, err
+	}
+	if outer.Type() != MapTypeArrayOfMaps && outer.Type() != MapTypeHashOfMaps {
+		return nil, fmt.Errorf("failed to init resizable ring buffer: map %s is %s, not a map-in-map", outerMapName, outer.Type())
+	}
+	if outer.KeySize() != int(unsafe.Sizeof(key)) {
+		return nil, fmt.Errorf("failed to init resizable ring buffer: map %s keys are not u32", outerMapName)
+	}
+
+	r := &ResizableRingBuf{
+		outer:      outer,
+		key:        key,
+		eventsChan: eventsChan,
+		ringFD:     -1,
+	}
+
+	if err := r.swap(context.Background(), size); err != nil {
+		return nil, fmt.Errorf("failed to init resizable ring buffer: %w", err)
+	}
+
+	m.resizableRingBufs = append(m.resizableRingBufs, r)
+
+	return r, nil
+}
+
+// Poll starts delivering the data of the ring buffer to the events channel,
+// like RingBuffer.Poll(). The ring buffers replacing it are polled the same
+// way.
+func (r *ResizableRingBuf) Poll(timeout int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.polling || r.closed {
+		return
+	}
+
+	r.polling = true
+	r.timeout = timeout
+	r.rb.Poll(timeout)
+	emitBuffer(ModuleEventBufferStarted, r.outer)
+}
+
+// SetEventLimiter sets the EventLimiter deciding which records are passed to
+// the consumer, or removes it if nil. It must be called before Poll().
+func (r *ResizableRingBuf) SetEventLimiter(limiter *EventLimiter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.polling {
+		return fmt.Errorf("failed to set event limiter: ring buffer already polled")
+	}
+	r.limiter = limiter
+
+	return r.rb.SetEventLimiter(limiter)
+}
+
+// Size returns the size in bytes of the current ring buffer.
+func (r *ResizableRingBuf) Size() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.size
+}
+
+// Resize replaces the ring buffer by a new one of the given size. The records
+// left in the old ring buffer are delivered to the events channel before
+// those of the new one; if ctx is done first, they are dropped and the
+// context error is returned, but the new ring buffer is in place anyway.
+func (r *ResizableRingBuf) Resize(ctx context.Context, size int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return fmt.Errorf("failed to resize ring buffer: %w", syscall.EBADF)
+	}
+
+	if err := r.swap(ctx, size); err != nil {
+		return fmt.Errorf("failed to resize ring buffer: %w", err)
+	}
+
+	return nil
+}
+
+// swap creates a ring buffer of the given size, stores it in the outer map,
+// and drains and frees the previous one.
+func (r *ResizableRingBuf) swap(ctx context.Context, size int) error {
+	if err := validateRingBufSize(size); err != nil {
+		return err
+	}
+
+	ringMap, err := CreateMap(MapTypeRingbuf, r.outer.Name(), 0, 0, size, nil)
+	if err != nil {
+		return err
+	}
+	ringFD := ringMap.FileDescriptor()
+
+	rb := &RingBuffer{
+		eventsChan: r.eventsChan,
+		done:       make(chan struct{}),
+		keepChan:   true,
+		limiter:    r.limiter,
+	}
+	if err := initRingBuf(ringFD, rb); err != nil {
+		_ = syscall.Close(ringFD)
+		return err
+	}
+
+	fd := uint32(ringFD)
+	if err := r.outer.Update(unsafe.Pointer(&r.key), unsafe.Pointer(&fd)); err != nil {
+		rb.Close()
+		_ = syscall.Close(ringFD)
+		return err
+	}
+
+	oldRb, oldFD := r.rb, r.ringFD
+	r.rb, r.ringFD, r.size = rb, ringFD, size
+
+	// The producers moved to the new ring buffer, deliver what is left in the
+	// old one before polling the new one, to keep the records in order
+	var errDrain error
+	if oldRb != nil {
+		errDrain = oldRb.Drain(ctx)
+		oldRb.Close()
+		_ = syscall.Close(oldFD)
+	}
+
+	if r.polling {
+		rb.Poll(r.timeout)
+	}
+
+	return errDrain
+}
+
+// Close stops polling the ring buffer, closes the events channel and frees
+// the ring buffer. It is safe to call Close multiple times.
+func (r *ResizableRingBuf) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	r.rb.Close()
+	_ = syscall.Close(r.ringFD)
+	close(r.eventsChan)
+	r.closed = true
+	if r.polling {
+		emitBuffer(ModuleEventBufferStopped, r.outer)
+	}
+}
@@ -0,0 +1,21 @@
+package libbpfgo
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRingBufSize(t *testing.T) {
+	pageSize := os.Getpagesize()
+
+	for _, size := range []int{pageSize, 2 * pageSize, 1 << 24} {
+		assert.NoError(t, validateRingBufSize(size), size)
+	}
+
+	for _, size := range []int{0, -pageSize, pageSize / 2, 3 * pageSize, 1000} {
+		assert.ErrorIs(t, validateRingBufSize(size), syscall.EINVAL, size)
+	}
+}
@@ -7,6 +7,7 @@ package libbpfgo
 import "C"
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"syscall"
@@ -17,69 +18,235 @@ import (
 //
 
 type RingBuffer struct {
-	rb     *C.struct_ring_buffer
-	bpfMap *BPFMap
-	slot   uint
-	stop   chan struct{}
-	closed bool
-	wg     sync.WaitGroup
+	rb         *C.struct_ring_buffer
+	bpfMap     *BPFMap
+	slot       uint
+	eventsChan chan []byte
+	stop       chan struct{} // signals the poll goroutine to exit
+	done       chan struct{} // abandons deliveries blocked on eventsChan
+	doneOnce   sync.Once
+	waker      *pollWaker // set when polling with the runtime poller
+	keepChan   bool       // eventsChan is closed by a ResizableRingBuf
+	limiter    *EventLimiter
+	polling    bool
+	stopped    bool
+	closed     bool
+	mu         sync.Mutex
+	wg         sync.WaitGroup
 }
 
-// Poll will wait until timeout in milliseconds to gather
-// data from the ring buffer.
+// Poll starts a goroutine delivering the data of the ring buffer to the
+// events channel.
+//
+// The goroutine waits for data with the Go runtime poller, parked without
+// holding an OS thread and without waking up periodically, and Stop() wakes
+// it up so it can exit. If the buffer can not be added to the runtime poller,
+// the goroutine falls back to polling the buffer in libbpf, waking up every
+// timeout milliseconds (DefaultPollTimeout if negative).
 func (rb *RingBuffer) Poll(timeout int) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.polling || rb.stopped {
+		return
+	}
+
+	rb.pollLocked(timeout)
+}
+
+// StartCtx starts polling the ring buffer as Poll(-1), until ctx is done or
+// Stop() is called, which then closes the events channel. A poll failure,
+// which ends the polling, is sent to the returned channel, closed once the
+// polling ended.
+func (rb *RingBuffer) StartCtx(ctx context.Context) (<-chan error, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.polling || rb.stopped {
+		return nil, fmt.Errorf("failed to start ring buffer: already polled")
+	}
+
+	errs := rb.pollLocked(-1)
+	stopOnDone(ctx, rb.stop, rb.Stop)
+
+	return errs, nil
+}
+
+// pollLocked starts the poll goroutine, and returns the channel of its error.
+func (rb *RingBuffer) pollLocked(timeout int) <-chan error {
+	rb.polling = true
 	rb.stop = make(chan struct{})
-	rb.wg.Add(1)
-	go rb.poll(timeout)
+	emitBuffer(ModuleEventBufferStarted, rb.bpfMap)
+
+	waker, err := newPollWaker(int(C.ring_buffer__epoll_fd(rb.rb)))
+	if err == nil {
+		rb.waker = waker
+
+		return runPoll(&rb.wg, rb.pollWait)
+	}
+
+	// A poll blocking forever could not be woken up by Stop()
+	if timeout < 0 {
+		timeout = DefaultPollTimeout
+	}
+
+	return runPoll(&rb.wg, func() error { return rb.poll(timeout) })
 }
 
-// Deprecated: use RingBuffer.Poll() instead.
+// SetEventLimiter sets the EventLimiter deciding which records are passed
+// to the consumer, or removes it if nil. It must be called before Poll().
+func (rb *RingBuffer) SetEventLimiter(limiter *EventLimiter) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.polling {
+		return fmt.Errorf("failed to set event limiter: ring buffer already polled")
+	}
+	rb.limiter = limiter
+
+	return nil
+}
+
+// Deprecated: use RingBuffer.Poll() or RingBuffer.StartCtx() instead.
 func (rb *RingBuffer) Start() {
-	rb.Poll(300)
+	rb.Poll(DefaultPollTimeout)
 }
 
+// Stop stops polling the ring buffer and closes the events channel. Records
+// that could not be delivered yet are dropped (see RingBuffer.Drain()).
+//
+// It is safe to call Stop multiple times, and it does not block if the
+// consumer already stopped reading from the events channel.
 func (rb *RingBuffer) Stop() {
-	if rb.stop == nil {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.stopLocked()
+}
+
+func (rb *RingBuffer) stopLocked() {
+	if !rb.polling || rb.stopped {
 		return
 	}
 
-	// Signal the poll goroutine to exit
+	// Signal the poll goroutine to exit and abandon any delivery in progress.
+	// The consumer may have stopped at this point, so a callback blocked on a
+	// full events channel would otherwise deadlock the poll goroutine.
 	close(rb.stop)
+	rb.abandon()
+	rb.wakePoll()
 
-	// The event channel should be drained here since the consumer
-	// may have stopped at this point. Failure to drain it will
-	// result in a deadlock: the channel will fill up and the poll
-	// goroutine will block in the callback.
-	eventChan := eventChannels.get(rb.slot).(chan []byte)
+	// Wait for the poll goroutine to exit
+	rb.wg.Wait()
+	rb.closeWaker()
+
+	// Close the channel -- this is useful for the consumer to know that no
+	// more events will be sent.
+	rb.closeChannel()
+	rb.stopped = true
+	emitBuffer(ModuleEventBufferStopped, rb.bpfMap)
+}
+
+// Drain stops polling the ring buffer, delivers the records already produced
+// to the events channel and then closes it. The consumer must keep reading
+// from the events channel until it is closed.
+//
+// If ctx is done before all records are delivered, the remaining ones are
+// dropped and the context error is returned.
+func (rb *RingBuffer) Drain(ctx context.Context) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.stopped || rb.closed {
+		return nil
+	}
+
+	drained := make(chan struct{})
+	defer close(drained)
 	go func() {
-		// revive:disable:empty-block
-		for range eventChan {
+		select {
+		case <-ctx.Done():
+			rb.abandon()
+		case <-drained:
 		}
-		// revive:enable:empty-block
 	}()
 
-	// Wait for the poll goroutine to exit
-	rb.wg.Wait()
+	if rb.polling {
+		close(rb.stop)
+		rb.wakePoll()
+		rb.wg.Wait()
+		rb.closeWaker()
+	}
+
+	var err error
+	retC := C.ring_buffer__consume(rb.rb)
+	if retC < 0 {
+		err = fmt.Errorf("error draining ring buffer: %w", syscall.Errno(-retC))
+	}
+
+	rb.abandon()
+	rb.closeChannel()
+	rb.stopped = true
 
-	// Close the channel -- this is useful for the consumer but
-	// also to terminate the drain goroutine above.
-	close(eventChan)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
 
-	// Reset pb.stop to allow multiple safe calls to Stop()
-	rb.stop = nil
+	return err
 }
 
+// Close stops the ring buffer and frees its resources. It is safe to call
+// Close multiple times.
 func (rb *RingBuffer) Close() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
 	if rb.closed {
 		return
 	}
 
-	rb.Stop()
+	rb.stopLocked()
 	C.ring_buffer__free(rb.rb)
 	eventChannels.remove(rb.slot)
 	rb.closed = true
 }
 
+func (rb *RingBuffer) closeChannel() {
+	if !rb.keepChan {
+		close(rb.eventsChan)
+	}
+}
+
+// wakePoll wakes up the poll goroutine if it waits with the runtime poller.
+func (rb *RingBuffer) wakePoll() {
+	if rb.waker != nil {
+		_ = rb.waker.wake()
+	}
+}
+
+func (rb *RingBuffer) closeWaker() {
+	if rb.waker != nil {
+		rb.waker.close()
+		rb.waker = nil
+	}
+}
+
+// abandon unblocks the callbacks waiting to deliver records.
+func (rb *RingBuffer) abandon() {
+	rb.doneOnce.Do(func() {
+		close(rb.done)
+	})
+}
+
+// deliver sends the record to the events channel unless deliveries were
+// abandoned. It is called from the ring buffer callback.
+func (rb *RingBuffer) deliver(data []byte) {
+	select {
+	case rb.eventsChan <- data:
+	case <-rb.done:
+	}
+}
+
 func (rb *RingBuffer) isStopped() bool {
 	select {
 	case <-rb.stop:
@@ -90,8 +257,6 @@ func (rb *RingBuffer) isStopped() bool {
 }
 
 func (rb *RingBuffer) poll(timeout int) error {
-	defer rb.wg.Done()
-
 	for {
 		retC := C.ring_buffer__poll(rb.rb, C.int(timeout))
 		if rb.isStopped() {
@@ -110,3 +275,27 @@ func (rb *RingBuffer) poll(timeout int) error {
 
 	return nil
 }
+
+// pollWait waits for data with the runtime poller, relying on the waker to
+// be woken up when stopping.
+func (rb *RingBuffer) pollWait() error {
+	for {
+		ready, err := rb.waker.wait()
+		if err != nil {
+			return fmt.Errorf("error polling ring buffer: %w", err)
+		}
+		if !ready || rb.isStopped() {
+			return nil
+		}
+
+		retC := C.ring_buffer__consume(rb.rb)
+		if retC < 0 {
+			errno := syscall.Errno(-retC)
+			if errno == syscall.EINTR {
+				continue
+			}
+
+			return fmt.Errorf("error polling ring buffer: %w", errno)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"syscall"
+)
+
+//
+// Classic BPF
+//
+// Classic BPF (cBPF) filters, as built by tcpdump -dd or golang.org/x/net/bpf,
+// predate eBPF and still are what old kernels, unprivileged processes and
+// seccomp accept. ConvertCBPF translates a socket filter to eBPF instructions,
+// the way the kernel does internally, so that the same filter can be loaded
+// as a BPF_PROG_TYPE_SOCKET_FILTER program, and AttachCBPFSocketFilter falls
+// back to attaching it as is where eBPF is not available.
+//
+// Seccomp filters are classic only: they read struct seccomp_data instead of
+// the packet, and must be installed with seccomp(2) unconverted.
+//
+
+// CBPFInstruction is a classic BPF instruction, laid out as struct
+// sock_filter. It converts from golang.org/x/net/bpf.RawInstruction and
+// syscall.SockFilter.
+type CBPFInstruction struct {
+	Op uint16
+	Jt uint8
+	Jf uint8
+	K  uint32
+}
+
+// cBPF encoding, shared with eBPF for the classes, sizes, modes and operations
+// both have
+const (
+	cbpfMaxInsns = 4096 // BPF_MAXINSNS
+	cbpfMemWords = 16   // BPF_MEMWORDS
+
+	bpfClassLD   = 0x00
+	bpfClassLDX  = 0x01
+	bpfClassST   = 0x02
+	bpfClassSTX  = 0x03
+	bpfClassALU  = 0x04
+	bpfClassJMP  = 0x05
+	bpfClassRET  = 0x06 // cBPF only
+	bpfClassMISC = 0x07 // cBPF only
+	bpfClassA64  = 0x07 // BPF_ALU64, eBPF only
+
+	bpfSizeW  = 0x00
+	bpfSizeH  = 0x08
+	bpfSizeB  = 0x10
+	bpfSizeDW = 0x18
+
+	bpfModeIMM = 0x00
+	bpfModeABS = 0x20
+	bpfModeIND = 0x40
+	bpfModeMEM = 0x60
+	bpfModeLEN = 0x80
+	bpfModeMSH = 0xa0
+
+	bpfSrcK = 0x00
+	bpfSrcX = 0x08
+
+	bpfOpAdd = 0x00
+	bpfOpSub = 0x10
+	bpfOpMul = 0x20
+	bpfOpDiv = 0x30
+	bpfOpOr  = 0x40
+	bpfOpAnd = 0x50
+	bpfOpLsh = 0x60
+	bpfOpRsh = 0x70
+	bpfOpNeg = 0x80
+	bpfOpMod = 0x90
+	bpfOpXor = 0xa0
+	bpfOpMov = 0xb0 // eBPF only
+
+	bpfJA   = 0x00
+	bpfJEQ  = 0x10
+	bpfJGT  = 0x20
+	bpfJGE  = 0x30
+	bpfJSET = 0x40
+	bpfJNE  = 0x50 // eBPF only
+	bpfExit = 0x90 // eBPF only
+
+	cbpfRetK = 0x00
+	cbpfRetA = 0x10
+
+	cbpfMiscTAX = 0x00
+	cbpfMiscTXA = 0x80
+
+	// skfAdOff is SKF_AD_OFF, the base of the negative offsets of the
+	// ancillary data loads (protocol, ifindex, vlan tag...). The offsets
+	// below it (SKF_NET_OFF, SKF_LL_OFF) are packet offsets.
+	skfAdOff = -0x1000
+)
+
+// The registers of the converted program, as in the kernel: A in R0, where
+// the legacy packet loads put their result, X in R7 and the context in R6,
+// which these loads read. R8 is scratch, kept across the packet loads.
+const (
+	ebpfRegA   = 0
+	ebpfRegCtx = 1
+	ebpfRegSkb = 6
+	ebpfRegX   = 7
+	ebpfRegTmp = 8
+	ebpfRegFP  = 10
+)
+
+// skbLenOff is the offset of len in struct __sk_buff.
+const skbLenOff = 0
+
+// ebpfInsn is a decoded struct bpf_insn.
+type ebpfInsn struct {
+	code uint8
+	dst  uint8
+	src  uint8
+	off  int16
+	imm  int32
+}
+
+// cbpfJump is a jump to be resolved once the conversion of its target is
+// known.
+type cbpfJump struct {
+	pos    int // index of the eBPF jump
+	target int // index of the cBPF target
+}
+
+// cbpfConverter translates the cBPF instructions one by one.
+type cbpfConverter struct {
+	insns  []ebpfInsn
+	starts []int // index of the first eBPF instruction of each cBPF one
+	jumps  []cbpfJump
+}
+
+func (c *cbpfConverter) emit(insns ...ebpfInsn) {
+	c.insns = append(c.insns, insns...)
+}
+
+func (c *cbpfConverter) jump(insn ebpfInsn, target int) {
+	c.jumps = append(c.jumps, cbpfJump{pos: len(c.insns), target: target})
+	c.emit(insn)
+}
+
+// scratchOff returns the stack offset of the scratch memory word k.
+func scratchOff(k uint32) int16 {
+	return -int16(cbpfMemWords-k) * 4
+}
+
+// ConvertCBPF converts a classic BPF socket filter to the eBPF instructions of
+// an equivalent BPF_PROG_TYPE_SOCKET_FILTER program, encoded as struct
+// bpf_insn in host byte order. It fails with syscall.EINVAL if the filter is
+// invalid, and with errors.ErrUnsupported if it loads ancillary data
+// (SKF_AD_OFF), which only classic filters can.
+func ConvertCBPF(filter []CBPFInstruction) ([]byte, error) {
+	if len(filter) == 0 || len(filter) > cbpfMaxInsns {
+		return nil, fmt.Errorf("failed to convert cBPF filter: %d instructions: %w", len(filter), syscall.EINVAL)
+	}
+
+	c := &cbpfConverter{starts: make([]int, len(filter))}
+
+	// Save the context, and clear A and X as the classic interpreter does
+	c.emit(
+		ebpfInsn{code: bpfClassA64 | bpfOpMov | bpfSrcX, dst: ebpfRegSkb, src: ebpfRegCtx},
+		ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcK, dst: ebpfRegA},
+		ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcK, dst: ebpfRegX},
+	)
+
+	// The verifier rejects reads of uninitialized stack, the classic checker
+	// only those not preceded by a store on some path: clear the scratch
+	// memory if it is read
+	for _, ins := range filter {
+		if (ins.Op&0x07 == bpfClassLD || ins.Op&0x07 == bpfClassLDX) && ins.Op&0xe0 == bpfModeMEM {
+			for off := int16(8); off <= cbpfMemWords*4; off += 8 {
+				c.emit(ebpfInsn{code: bpfClassST | bpfModeMEM | bpfSizeDW, dst: ebpfRegFP, off: -off})
+			}
+			break
+		}
+	}
+
+	for i, ins := range filter {
+		c.starts[i] = len(c.insns)
+		if err := c.convert(i, ins, len(filter)); err != nil {
+			return nil, fmt.Errorf("failed to convert cBPF filter: instruction %d (%#04x): %w", i, ins.Op, err)
+		}
+	}
+
+	if op := filter[len(filter)-1].Op; op != bpfClassRET|cbpfRetK && op != bpfClassRET|cbpfRetA {
+		return nil, fmt.Errorf("failed to convert cBPF filter: last instruction is not a return: %w", syscall.EINVAL)
+	}
+
+	for _, j := range c.jumps {
+		off := c.starts[j.target] - j.pos - 1
+		if off > math.MaxInt16 {
+			return nil, fmt.Errorf("failed to convert cBPF filter: jump to instruction %d too far: %w", j.target, syscall.E2BIG)
+		}
+		c.insns[j.pos].off = int16(off)
+	}
+
+	return encodeInsns(c.insns), nil
+}
+
+// convert converts the instruction i of the filter.
+func (c *cbpfConverter) convert(i int, ins CBPFInstruction, n int) error {
+	if ins.Op > 0xff {
+		return syscall.EINVAL
+	}
+	class := uint8(ins.Op) & 0x07
+
+	switch class {
+	case bpfClassLD, bpfClassLDX:
+		return c.convertLoad(ins)
+
+	case bpfClassST, bpfClassSTX:
+		if ins.Op&^0x07 != 0 || ins.K >= cbpfMemWords {
+			return syscall.EINVAL
+		}
+		src := uint8(ebpfRegA)
+		if class == bpfClassSTX {
+			src = ebpfRegX
+		}
+		c.emit(ebpfInsn{code: bpfClassSTX | bpfModeMEM | bpfSizeW, dst: ebpfRegFP, src: src, off: scratchOff(ins.K)})
+
+	case bpfClassALU:
+		return c.convertALU(ins)
+
+	case bpfClassJMP:
+		return c.convertJump(i, ins, n)
+
+	case bpfClassRET:
+		switch ins.Op &^ 0x07 {
+		case cbpfRetK:
+			c.emit(ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcK, dst: ebpfRegA, imm: int32(ins.K)})
+		case cbpfRetA:
+		default:
+			return syscall.EINVAL
+		}
+		c.emit(ebpfInsn{code: bpfClassJMP | bpfExit})
+
+	case bpfClassMISC:
+		switch ins.Op &^ 0x07 {
+		case cbpfMiscTAX:
+			c.emit(ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcX, dst: ebpfRegX, src: ebpfRegA})
+		case cbpfMiscTXA:
+			c.emit(ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcX, dst: ebpfRegA, src: ebpfRegX})
+		default:
+			return syscall.EINVAL
+		}
+	}
+
+	return nil
+}
+
+func (c *cbpfConverter) convertLoad(ins CBPFInstruction) error {
+	class := uint8(ins.Op) & 0x07
+	size := uint8(ins.Op) & 0x18
+	mode := uint8(ins.Op) & 0xe0
+	dst := uint8(ebpfRegA)
+	if class == bpfClassLDX {
+		dst = ebpfRegX
+	}
+
+	switch {
+	case mode == bpfModeIMM && size == bpfSizeW:
+		c.emit(ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcK, dst: dst, imm: int32(ins.K)})
+
+	case mode == bpfModeLEN && size == bpfSizeW:
+		c.emit(ebpfInsn{code: bpfClassLDX | bpfModeMEM | bpfSizeW, dst: dst, src: ebpfRegSkb, off: skbLenOff})
+
+	case mode == bpfModeMEM && size == bpfSizeW:
+		if ins.K >= cbpfMemWords {
+			return syscall.EINVAL
+		}
+		c.emit(ebpfInsn{code: bpfClassLDX | bpfModeMEM | bpfSizeW, dst: dst, src: ebpfRegFP, off: scratchOff(ins.K)})
+
+	case class == bpfClassLD && (mode == bpfModeABS || mode == bpfModeIND) && size != bpfSizeDW:
+		if k := int32(ins.K); k < 0 && k >= skfAdOff {
+			return errors.ErrUnsupported
+		}
+		// The legacy packet loads exist in eBPF, reading the skb of R6
+		// into R0, and ending the program when out of bounds
+		src := uint8(0)
+		if mode == bpfModeIND {
+			src = ebpfRegX
+		}
+		c.emit(ebpfInsn{code: uint8(ins.Op), src: src, imm: int32(ins.K)})
+
+	case class == bpfClassLDX && mode == bpfModeMSH && size == bpfSizeB:
+		// X = 4 * (P[k] & 0xf), the IP header length, loaded through A
+		if k := int32(ins.K); k < 0 && k >= skfAdOff {
+			return errors.ErrUnsupported
+		}
+		c.emit(
+			ebpfInsn{code: bpfClassA64 | bpfOpMov | bpfSrcX, dst: ebpfRegTmp, src: ebpfRegA},
+			ebpfInsn{code: bpfClassLD | bpfModeABS | bpfSizeB, imm: int32(ins.K)},
+			ebpfInsn{code: bpfClassALU | bpfOpAnd | bpfSrcK, dst: ebpfRegA, imm: 0xf},
+			ebpfInsn{code: bpfClassALU | bpfOpLsh | bpfSrcK, dst: ebpfRegA, imm: 2},
+			ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcX, dst: ebpfRegX, src: ebpfRegA},
+			ebpfInsn{code: bpfClassA64 | bpfOpMov | bpfSrcX, dst: ebpfRegA, src: ebpfRegTmp},
+		)
+
+	default:
+		return syscall.EINVAL
+	}
+
+	return nil
+}
+
+func (c *cbpfConverter) convertALU(ins CBPFInstruction) error {
+	op := uint8(ins.Op) & 0xf0
+	src := uint8(ins.Op) & 0x08
+
+	switch op {
+	case bpfOpAdd, bpfOpSub, bpfOpMul, bpfOpOr, bpfOpAnd, bpfOpXor:
+	case bpfOpLsh, bpfOpRsh:
+		if src == bpfSrcK && ins.K >= 32 {
+			return syscall.EINVAL
+		}
+	case bpfOpDiv, bpfOpMod:
+		if src == bpfSrcK && ins.K == 0 {
+			return syscall.EINVAL
+		}
+		if src == bpfSrcX {
+			// eBPF divides by zero without error, the classic filter
+			// returns 0
+			c.emit(
+				ebpfInsn{code: bpfClassJMP | bpfJNE | bpfSrcK, dst: ebpfRegX, off: 2},
+				ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcK, dst: ebpfRegA},
+				ebpfInsn{code: bpfClassJMP | bpfExit},
+			)
+		}
+	case bpfOpNeg:
+		if src != bpfSrcK {
+			return syscall.EINVAL
+		}
+		c.emit(ebpfInsn{code: bpfClassALU | bpfOpNeg, dst: ebpfRegA})
+		return nil
+	default:
+		return syscall.EINVAL
+	}
+
+	if src == bpfSrcX {
+		c.emit(ebpfInsn{code: bpfClassALU | op | bpfSrcX, dst: ebpfRegA, src: ebpfRegX})
+	} else {
+		c.emit(ebpfInsn{code: bpfClassALU | op | bpfSrcK, dst: ebpfRegA, imm: int32(ins.K)})
+	}
+
+	return nil
+}
+
+func (c *cbpfConverter) convertJump(i int, ins CBPFInstruction, n int) error {
+	op := uint8(ins.Op) & 0xf0
+	src := uint8(ins.Op) & 0x08
+
+	if op == bpfJA {
+		if src != bpfSrcK {
+			return syscall.EINVAL
+		}
+		target := i + 1 + int(ins.K)
+		if ins.K >= uint32(n) || target >= n {
+			return syscall.EINVAL
+		}
+		c.jump(ebpfInsn{code: bpfClassJMP | bpfJA}, target)
+		return nil
+	}
+
+	switch op {
+	case bpfJEQ, bpfJGT, bpfJGE, bpfJSET:
+	default:
+		return syscall.EINVAL
+	}
+
+	targetTrue := i + 1 + int(ins.Jt)
+	targetFalse := i + 1 + int(ins.Jf)
+	if targetTrue >= n || targetFalse >= n {
+		return syscall.EINVAL
+	}
+
+	cond := ebpfInsn{code: bpfClassJMP | op | src, dst: ebpfRegA}
+	switch {
+	case src == bpfSrcX:
+		cond.src = ebpfRegX
+	case int32(ins.K) < 0:
+		// The immediates are sign extended, compare with the zero
+		// extended value in a register instead
+		c.emit(ebpfInsn{code: bpfClassALU | bpfOpMov | bpfSrcK, dst: ebpfRegTmp, imm: int32(ins.K)})
+		cond.code = bpfClassJMP | op | bpfSrcX
+		cond.src = ebpfRegTmp
+	default:
+		cond.imm = int32(ins.K)
+	}
+
+	c.jump(cond, targetTrue)
+	if ins.Jf != 0 {
+		c.jump(ebpfInsn{code: bpfClassJMP | bpfJA}, targetFalse)
+	}
+
+	return nil
+}
+
+// encodeInsns encodes the instructions as struct bpf_insn, in host byte
+// order.
+func encodeInsns(insns []ebpfInsn) []byte {
+	bigEndian := binary.NativeEndian.Uint16([]byte{0, 1}) == 1
+
+	buf := make([]byte, len(insns)*bpfInsnSize)
+	for i, insn := range insns {
+		b := buf[i*bpfInsnSize : (i+1)*bpfInsnSize]
+		b[0] = insn.code
+		// dst_reg and src_reg are 4 bits fields, ordered as the bytes
+		if bigEndian {
+			b[1] = insn.dst<<4 | insn.src
+		} else {
+			b[1] = insn.src<<4 | insn.dst
+		}
+		binary.NativeEndian.PutUint16(b[2:], uint16(insn.off))
+		binary.NativeEndian.PutUint32(b[4:], uint32(insn.imm))
+	}
+
+	return buf
+}
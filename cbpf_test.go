@@ -0,0 +1,313 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runConverted interprets the eBPF instructions converted from a cBPF filter,
+// only the ones the conversion emits, on the packet.
+func runConverted(t *testing.T, prog []byte, pkt []byte) uint32 {
+	t.Helper()
+
+	bigEndian := binary.NativeEndian.Uint16([]byte{0, 1}) == 1
+	var regs [11]uint64
+	stack := make([]byte, 512)
+	load := func(size uint8, off int64) (uint64, bool) {
+		n := map[uint8]int64{bpfSizeW: 4, bpfSizeH: 2, bpfSizeB: 1}[size]
+		if off < 0 || off+n > int64(len(pkt)) {
+			return 0, false
+		}
+		switch n {
+		case 4:
+			return uint64(binary.BigEndian.Uint32(pkt[off:])), true
+		case 2:
+			return uint64(binary.BigEndian.Uint16(pkt[off:])), true
+		}
+		return uint64(pkt[off]), true
+	}
+
+	for pc := 0; pc*bpfInsnSize < len(prog); pc++ {
+		b := prog[pc*bpfInsnSize:]
+		code := b[0]
+		dst, src := b[1]&0x0f, b[1]>>4
+		if bigEndian {
+			dst, src = b[1]>>4, b[1]&0x0f
+		}
+		off := int16(binary.NativeEndian.Uint16(b[2:]))
+		imm := int32(binary.NativeEndian.Uint32(b[4:]))
+
+		operand := uint64(uint32(imm))
+		if code&0x08 == bpfSrcX {
+			operand = regs[src]
+		}
+
+		switch class := code & 0x07; {
+		case code == bpfClassA64|bpfOpMov|bpfSrcX:
+			regs[dst] = regs[src]
+		case class == bpfClassALU:
+			a, o := uint32(regs[dst]), uint32(operand)
+			var r uint32
+			switch code & 0xf0 {
+			case bpfOpMov:
+				r = o
+			case bpfOpAdd:
+				r = a + o
+			case bpfOpSub:
+				r = a - o
+			case bpfOpMul:
+				r = a * o
+			case bpfOpDiv:
+				r = a / o
+			case bpfOpMod:
+				r = a % o
+			case bpfOpOr:
+				r = a | o
+			case bpfOpAnd:
+				r = a & o
+			case bpfOpXor:
+				r = a ^ o
+			case bpfOpLsh:
+				r = a << o
+			case bpfOpRsh:
+				r = a >> o
+			case bpfOpNeg:
+				r = -a
+			default:
+				t.Fatalf("unexpected ALU instruction %#02x", code)
+			}
+			regs[dst] = uint64(r)
+		case class == bpfClassLD && (code&0xe0 == bpfModeABS || code&0xe0 == bpfModeIND):
+			addr := int64(imm)
+			if code&0xe0 == bpfModeIND {
+				addr += int64(uint32(regs[src]))
+			}
+			v, ok := load(code&0x18, addr)
+			if !ok {
+				return 0
+			}
+			regs[0] = v
+		case code == bpfClassLDX|bpfModeMEM|bpfSizeW && src == ebpfRegSkb:
+			require.Equal(t, int16(skbLenOff), off)
+			regs[dst] = uint64(len(pkt))
+		case code == bpfClassLDX|bpfModeMEM|bpfSizeW && src == ebpfRegFP:
+			regs[dst] = uint64(binary.NativeEndian.Uint32(stack[len(stack)+int(off):]))
+		case code == bpfClassSTX|bpfModeMEM|bpfSizeW && dst == ebpfRegFP:
+			binary.NativeEndian.PutUint32(stack[len(stack)+int(off):], uint32(regs[src]))
+		case code == bpfClassST|bpfModeMEM|bpfSizeDW && dst == ebpfRegFP:
+			binary.NativeEndian.PutUint64(stack[len(stack)+int(off):], uint64(int64(imm)))
+		case code == bpfClassJMP|bpfExit:
+			return uint32(regs[0])
+		case class == bpfClassJMP:
+			a := regs[dst]
+			taken := false
+			switch code & 0xf0 {
+			case bpfJA:
+				taken = true
+			case bpfJEQ:
+				taken = a == operand
+			case bpfJNE:
+				taken = a != operand
+			case bpfJGT:
+				taken = a > operand
+			case bpfJGE:
+				taken = a >= operand
+			case bpfJSET:
+				taken = a&operand != 0
+			default:
+				t.Fatalf("unexpected jump instruction %#02x", code)
+			}
+			if taken {
+				pc += int(off)
+			}
+		default:
+			t.Fatalf("unexpected instruction %#02x", code)
+		}
+	}
+
+	t.Fatal("program did not exit")
+	return 0
+}
+
+// tcpPort22 is `tcpdump -dd 'ip and tcp dst port 22'`.
+var tcpPort22 = []CBPFInstruction{
+	{0x28, 0, 0, 0x0000000c},
+	{0x15, 0, 8, 0x00000800},
+	{0x30, 0, 0, 0x00000017},
+	{0x15, 0, 6, 0x00000006},
+	{0x28, 0, 0, 0x00000014},
+	{0x45, 4, 0, 0x00001fff},
+	{0xb1, 0, 0, 0x0000000e},
+	{0x48, 0, 0, 0x00000010},
+	{0x15, 0, 1, 0x00000016},
+	{0x6, 0, 0, 0x00040000},
+	{0x6, 0, 0, 0x00000000},
+}
+
+// ethTCPPacket returns an Ethernet IPv4 TCP packet to the destination port.
+func ethTCPPacket(dstPort uint16) []byte {
+	pkt := make([]byte, 14+20+20)
+	binary.BigEndian.PutUint16(pkt[12:], 0x0800)
+	pkt[14] = 0x45 // IPv4, 20 bytes header
+	pkt[14+9] = syscall.IPPROTO_TCP
+	binary.BigEndian.PutUint16(pkt[14+20+2:], dstPort)
+
+	return pkt
+}
+
+func TestConvertCBPF(t *testing.T) {
+	prog, err := ConvertCBPF(tcpPort22)
+	require.NoError(t, err)
+	require.Zero(t, len(prog)%bpfInsnSize)
+
+	assert.EqualValues(t, 0x40000, runConverted(t, prog, ethTCPPacket(22)))
+	assert.EqualValues(t, 0, runConverted(t, prog, ethTCPPacket(80)))
+
+	udp := ethTCPPacket(22)
+	udp[14+9] = syscall.IPPROTO_UDP
+	assert.EqualValues(t, 0, runConverted(t, prog, udp))
+
+	// Out of bounds loads end the program, dropping the packet
+	assert.EqualValues(t, 0, runConverted(t, prog, ethTCPPacket(22)[:20]))
+}
+
+func TestConvertCBPFSemantics(t *testing.T) {
+	tt := []struct {
+		name   string
+		filter []CBPFInstruction
+		pkt    []byte
+		want   uint32
+	}{
+		{
+			name: "return length",
+			filter: []CBPFInstruction{
+				{Op: bpfClassLD | bpfModeLEN},
+				{Op: bpfClassRET | cbpfRetA},
+			},
+			pkt:  make([]byte, 42),
+			want: 42,
+		},
+		{
+			name: "scratch memory and X",
+			filter: []CBPFInstruction{
+				{Op: bpfClassLD | bpfModeIMM, K: 7},
+				{Op: bpfClassST, K: 3},
+				{Op: bpfClassLDX | bpfModeMEM, K: 3},
+				{Op: bpfClassLD | bpfModeIMM, K: 5},
+				{Op: bpfClassALU | bpfOpMul | bpfSrcX},
+				{Op: bpfClassMISC | cbpfMiscTAX},
+				{Op: bpfClassLD | bpfModeMEM, K: 0}, // cleared by the prologue
+				{Op: bpfClassALU | bpfOpAdd | bpfSrcX},
+				{Op: bpfClassRET | cbpfRetA},
+			},
+			want: 35,
+		},
+		{
+			name: "division by zero X returns 0",
+			filter: []CBPFInstruction{
+				{Op: bpfClassLD | bpfModeIMM, K: 10},
+				{Op: bpfClassALU | bpfOpDiv | bpfSrcX},
+				{Op: bpfClassRET | cbpfRetK, K: 1},
+			},
+			want: 0,
+		},
+		{
+			name: "unsigned comparison with a large immediate",
+			filter: []CBPFInstruction{
+				{Op: bpfClassLD | bpfModeIMM, K: 0xfffffff0},
+				{Op: bpfClassJMP | bpfJGT, Jt: 1, K: 0xffffff00},
+				{Op: bpfClassRET | cbpfRetK, K: 1},
+				{Op: bpfClassRET | cbpfRetK, K: 2},
+			},
+			want: 2,
+		},
+		{
+			name: "jump always",
+			filter: []CBPFInstruction{
+				{Op: bpfClassJMP | bpfJA, K: 1},
+				{Op: bpfClassRET | cbpfRetK, K: 1},
+				{Op: bpfClassLD | bpfModeIMM, K: 3},
+				{Op: bpfClassALU | bpfOpNeg},
+				{Op: bpfClassRET | cbpfRetA},
+			},
+			want: 0xfffffffd,
+		},
+		{
+			name: "indirect load",
+			filter: []CBPFInstruction{
+				{Op: bpfClassLDX | bpfModeIMM, K: 2},
+				{Op: bpfClassLD | bpfModeIND | bpfSizeB, K: 1},
+				{Op: bpfClassRET | cbpfRetA},
+			},
+			pkt:  []byte{0, 1, 2, 3, 4},
+			want: 3,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := ConvertCBPF(tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, runConverted(t, prog, tc.pkt))
+		})
+	}
+}
+
+func TestConvertCBPFInvalid(t *testing.T) {
+	ret := CBPFInstruction{Op: bpfClassRET | cbpfRetK}
+
+	tt := []struct {
+		name   string
+		filter []CBPFInstruction
+	}{
+		{"empty", nil},
+		{"too long", make([]CBPFInstruction, cbpfMaxInsns+1)},
+		{"no return", []CBPFInstruction{{Op: bpfClassLD | bpfModeIMM}}},
+		{"jump out of range", []CBPFInstruction{{Op: bpfClassJMP | bpfJEQ, Jt: 1}, ret}},
+		{"jump always out of range", []CBPFInstruction{{Op: bpfClassJMP | bpfJA, K: 0xffffffff}, ret}},
+		{"scratch out of range", []CBPFInstruction{{Op: bpfClassST, K: cbpfMemWords}, ret}},
+		{"division by zero", []CBPFInstruction{{Op: bpfClassALU | bpfOpDiv}, ret}},
+		{"shift too large", []CBPFInstruction{{Op: bpfClassALU | bpfOpLsh, K: 32}, ret}},
+		{"unknown opcode", []CBPFInstruction{{Op: 0x1ff}, ret}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ConvertCBPF(tc.filter)
+			assert.ErrorIs(t, err, syscall.EINVAL)
+		})
+	}
+
+	// Ancillary loads have no eBPF equivalent
+	_, err := ConvertCBPF([]CBPFInstruction{{Op: bpfClassLD | bpfModeABS | bpfSizeH, K: 0xfffff000}, ret})
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+func TestAttachClassicSocketFilter(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+
+	// Drop all the datagrams
+	require.NoError(t, AttachClassicSocketFilter(fds[1], []CBPFInstruction{{Op: bpfClassRET | cbpfRetK}}))
+	_, err = syscall.Write(fds[0], []byte("dropped"))
+	require.NoError(t, err)
+
+	require.NoError(t, DetachSocketFilter(fds[1]))
+	_, err = syscall.Write(fds[0], []byte("received"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 64)
+	n, err := syscall.Read(fds[1], buf)
+	require.NoError(t, err)
+	assert.Equal(t, "received", string(buf[:n]))
+
+	err = AttachClassicSocketFilter(fds[1], nil)
+	assert.ErrorIs(t, err, syscall.EINVAL)
+}
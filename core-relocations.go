@@ -0,0 +1,250 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//
+// CO-RE relocation checks
+//
+// libbpf relocates the CO-RE accesses of the programs (BPF_CORE_READ(),
+// bpf_core_field_exists(), ...) against the BTF of the running kernel, or the
+// one of NewModuleArgs.BTFObjPath. CheckCORERelocations() relocates an object
+// against a given BTF file, such as the ones of BTFHub, and reports the result
+// of each relocation, so that CI validates an object against a matrix of
+// kernels without booting them:
+//
+//	for _, btf := range kernelBTFs {
+//		report, err := libbpfgo.CheckCORERelocations(libbpfgo.NewModuleArgs{
+//			BPFObjPath: "agent.bpf.o",
+//			BTFObjPath: btf,
+//		})
+//		...
+//		for _, relo := range report.Unresolved() {
+//			t.Errorf("%s: %s", btf, relo)
+//		}
+//	}
+//
+// libbpf only relocates the programs while loading the object, and has no API
+// for the result of each relocation: the object is loaded on the running
+// kernel (which takes the privileges to load it), and the relocations are
+// tracked from its debug output. The relocated programs are then likely to be
+// rejected by the verifier, the running kernel not being the one of the BTF,
+// which is not reported as an error. Relocations failing with libbpf older
+// than v0.7 fail the load instead of being reported.
+//
+
+// CORERelocation is the result of a CO-RE relocation of a program.
+type CORERelocation struct {
+	Program string
+	Index   int    // index of the relocation in the program
+	Kind    string // "byte_off", "field_exists", "type_id", ...
+	Local   string // accessed type and field, in the object
+	Target  string // matching type and field in the BTF, if any
+	Insn    int    // relocated instruction, -1 if unknown
+	// Resolved reports whether the relocation succeeded. Existence checks
+	// without a matching target are resolved (to false), accesses are not:
+	// their instruction is replaced by an invalid one, rejected by the
+	// verifier if reachable.
+	Resolved bool
+}
+
+func (r CORERelocation) String() string {
+	status := "resolved"
+	if !r.Resolved {
+		status = "unresolved"
+	}
+
+	return fmt.Sprintf("prog %s relo #%d <%s> %s: %s", r.Program, r.Index, r.Kind, r.Local, status)
+}
+
+// CORERelocReport is the result of the CO-RE relocations of an object.
+type CORERelocReport struct {
+	BTFPath     string
+	Relocations []CORERelocation
+}
+
+// Unresolved returns the relocations that failed.
+func (r *CORERelocReport) Unresolved() []CORERelocation {
+	var unresolved []CORERelocation
+	for _, relo := range r.Relocations {
+		if !relo.Resolved {
+			unresolved = append(unresolved, relo)
+		}
+	}
+
+	return unresolved
+}
+
+// CheckCORERelocations relocates the object given by args (BPFObjBuff, or
+// else BPFObjPath) against the BTF of args.BTFObjPath, and reports the result
+// of each relocation. The object is loaded on the running kernel and closed.
+func CheckCORERelocations(args NewModuleArgs) (*CORERelocReport, error) {
+	if args.BTFObjPath == "" {
+		return nil, fmt.Errorf("failed to check CO-RE relocations: no BTF path")
+	}
+
+	var m *Module
+	var err error
+	if len(args.BPFObjBuff) > 0 {
+		m, err = NewModuleFromBufferArgs(args)
+	} else {
+		m, err = NewModuleFromFileArgs(args)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check CO-RE relocations: %w", err)
+	}
+	defer m.Close()
+
+	progs := make(map[string]bool)
+	it := m.Iterator()
+	for prog := it.NextProgram(); prog != nil; prog = it.NextProgram() {
+		progs[prog.Name()] = true
+	}
+
+	capture := startCORERelocCapture(progs)
+	loadErr := m.BPFLoadObject()
+	relos := parseCORERelocations(capture.stop())
+
+	// A load failing before the relocations, unless the object has none
+	if loadErr != nil && len(relos) == 0 {
+		return nil, fmt.Errorf("failed to check CO-RE relocations against %s: %w", args.BTFObjPath, loadErr)
+	}
+
+	return &CORERelocReport{
+		BTFPath:     args.BTFObjPath,
+		Relocations: relos,
+	}, nil
+}
+
+//
+// CO-RE relocations tracking
+//
+
+// coreRelocCapture records the CO-RE relocation lines of the libbpf output
+// about the programs of an object.
+type coreRelocCapture struct {
+	progs map[string]bool
+	lines []string
+}
+
+var (
+	coreRelocCaptures   = make(map[*coreRelocCapture]struct{})
+	coreRelocCapturesMu sync.Mutex
+)
+
+func startCORERelocCapture(progs map[string]bool) *coreRelocCapture {
+	c := &coreRelocCapture{progs: progs}
+
+	coreRelocCapturesMu.Lock()
+	coreRelocCaptures[c] = struct{}{}
+	coreRelocCapturesMu.Unlock()
+
+	return c
+}
+
+// stop ends the capture and returns the captured lines.
+func (c *coreRelocCapture) stop() []string {
+	coreRelocCapturesMu.Lock()
+	defer coreRelocCapturesMu.Unlock()
+
+	delete(coreRelocCaptures, c)
+
+	return c.lines
+}
+
+// feedCORERelocations feeds the libbpf output to the active captures.
+func feedCORERelocations(output string) {
+	if !strings.Contains(output, ": relo #") {
+		return
+	}
+	match := coreRelocRegexp.FindStringSubmatch(strings.TrimRight(output, "\n"))
+	if match == nil {
+		return
+	}
+
+	coreRelocCapturesMu.Lock()
+	defer coreRelocCapturesMu.Unlock()
+
+	for c := range coreRelocCaptures {
+		if c.progs[match[1]] {
+			c.lines = append(c.lines, match[0])
+		}
+	}
+}
+
+var (
+	// libbpf: prog 'handle_exec': relo #3: ...
+	coreRelocRegexp = regexp.MustCompile(`^(?:libbpf: )?prog '([^']+)': relo #(\d+): (.*)$`)
+	// <byte_off> [7] struct task_struct.pid (0:49 @ offset 2468), or
+	// kind <byte_off> (0), spec is [7] struct task_struct.pid ... before v1.0
+	coreRelocSpecRegexp    = regexp.MustCompile(`^<(\w+)> (.*)$`)
+	coreRelocOldSpecRegexp = regexp.MustCompile(`^kind <(\w+)> \(\d+\), spec is (.*)$`)
+	// matching candidate #0 <byte_off> [123] struct task_struct.pid ...
+	coreRelocCandRegexp = regexp.MustCompile(`^matching candidate #\d+ (?:<\w+> )?(.*)$`)
+	// patched insn #5 (LDX/ST/STX) off 2468 -> 2500
+	coreRelocPatchedRegexp = regexp.MustCompile(`^patched insn #(\d+)`)
+	// substituting insn #5 w/ invalid insn
+	coreRelocPoisonRegexp = regexp.MustCompile(`^substituting insn #(\d+) w/ invalid insn`)
+)
+
+// parseCORERelocations returns the relocations of the libbpf output lines, in
+// the order they were relocated.
+func parseCORERelocations(lines []string) []CORERelocation {
+	type reloKey struct {
+		prog  string
+		index int
+	}
+
+	var relos []CORERelocation
+	indexes := make(map[reloKey]int)
+
+	for _, line := range lines {
+		match := coreRelocRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		index, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		key := reloKey{prog: match[1], index: index}
+		msg := match[3]
+
+		i, ok := indexes[key]
+		if !ok {
+			relos = append(relos, CORERelocation{
+				Program:  key.prog,
+				Index:    index,
+				Insn:     -1,
+				Resolved: true,
+			})
+			i = len(relos) - 1
+			indexes[key] = i
+		}
+		relo := &relos[i]
+
+		if spec := coreRelocSpecRegexp.FindStringSubmatch(msg); spec != nil {
+			relo.Kind, relo.Local = spec[1], spec[2]
+		} else if spec := coreRelocOldSpecRegexp.FindStringSubmatch(msg); spec != nil {
+			relo.Kind, relo.Local = spec[1], spec[2]
+		} else if cand := coreRelocCandRegexp.FindStringSubmatch(msg); cand != nil {
+			if relo.Target == "" {
+				relo.Target = cand[1]
+			}
+		} else if insn := coreRelocPatchedRegexp.FindStringSubmatch(msg); insn != nil {
+			relo.Insn, _ = strconv.Atoi(insn[1])
+		} else if insn := coreRelocPoisonRegexp.FindStringSubmatch(msg); insn != nil {
+			relo.Insn, _ = strconv.Atoi(insn[1])
+			relo.Resolved = false
+		} else if strings.HasPrefix(msg, "failed to") {
+			relo.Resolved = false
+		}
+	}
+
+	return relos
+}
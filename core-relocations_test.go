@@ -0,0 +1,72 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCORERelocations(t *testing.T) {
+	lines := []string{
+		"libbpf: prog 'handle_exec': relo #0: <byte_off> [7] struct task_struct.pid (0:49 @ offset 2468)",
+		"prog 'handle_exec': relo #0: matching candidate #0 <byte_off> [131] struct task_struct.pid (0:50 @ offset 2500)",
+		"prog 'handle_exec': relo #0: patched insn #4 (LDX/ST/STX) off 2468 -> 2500",
+		"prog 'handle_exec': relo #1: <field_exists> [7] struct task_struct.loginuid (0:60 @ offset 3000)",
+		"prog 'handle_exec': relo #1: no matching targets found",
+		"prog 'handle_exec': relo #1: patched insn #9 (ALU/ALU64) imm 1 -> 0",
+		"prog 'handle_exec': relo #2: <byte_off> [12] struct mm_struct.exe_file (0:3 @ offset 24)",
+		"prog 'handle_exec': relo #2: no matching targets found",
+		"prog 'handle_exec': relo #2: substituting insn #12 w/ invalid insn",
+		// before libbpf v1.0
+		"prog 'handle_exit': relo #0: kind <byte_off> (0), spec is [7] struct task_struct.tgid (0:50 @ offset 2472)",
+		"prog 'handle_exit': relo #0: failed to relocate: -22",
+		"prog 'handle_exit': relo #1: <type_exists> [20] struct bpf_iter_task",
+	}
+
+	relos := parseCORERelocations(lines)
+	require.Len(t, relos, 5)
+
+	assert.Equal(t, CORERelocation{
+		Program:  "handle_exec",
+		Index:    0,
+		Kind:     "byte_off",
+		Local:    "[7] struct task_struct.pid (0:49 @ offset 2468)",
+		Target:   "[131] struct task_struct.pid (0:50 @ offset 2500)",
+		Insn:     4,
+		Resolved: true,
+	}, relos[0])
+
+	// Existence check without a target
+	assert.Equal(t, "field_exists", relos[1].Kind)
+	assert.Empty(t, relos[1].Target)
+	assert.Equal(t, 9, relos[1].Insn)
+	assert.True(t, relos[1].Resolved)
+
+	// Poisoned access
+	assert.Equal(t, 12, relos[2].Insn)
+	assert.False(t, relos[2].Resolved)
+
+	assert.Equal(t, "handle_exit", relos[3].Program)
+	assert.Equal(t, "byte_off", relos[3].Kind)
+	assert.Equal(t, "[7] struct task_struct.tgid (0:50 @ offset 2472)", relos[3].Local)
+	assert.False(t, relos[3].Resolved)
+
+	assert.Equal(t, -1, relos[4].Insn)
+	assert.True(t, relos[4].Resolved)
+
+	report := &CORERelocReport{Relocations: relos}
+	assert.Equal(t, []CORERelocation{relos[2], relos[3]}, report.Unresolved())
+	assert.Equal(t, "prog handle_exec relo #2 <byte_off> [12] struct mm_struct.exe_file (0:3 @ offset 24): unresolved", relos[2].String())
+}
+
+func TestFeedCORERelocations(t *testing.T) {
+	capture := startCORERelocCapture(map[string]bool{"handle_exec": true})
+	feedCORERelocations("libbpf: prog 'handle_exec': relo #0: <byte_off> [7] struct task_struct.pid (0:49 @ offset 2468)\n")
+	feedCORERelocations("prog 'other_object': relo #0: <byte_off> [7] struct task_struct.pid (0:49 @ offset 2468)\n")
+	feedCORERelocations("libbpf: prog 'handle_exec': -- BEGIN PROG LOAD LOG --\n")
+	lines := capture.stop()
+
+	feedCORERelocations("prog 'handle_exec': relo #1: <byte_off> [7] struct task_struct.tgid (0:50 @ offset 2472)\n")
+	assert.Equal(t, []string{"libbpf: prog 'handle_exec': relo #0: <byte_off> [7] struct task_struct.pid (0:49 @ offset 2468)"}, lines)
+}
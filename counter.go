@@ -0,0 +1,184 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+//
+// Counter
+//
+// A Counter exposes the u64 slots of an array map as metrics. Per-CPU array
+// maps avoid contention between CPUs, the program increments its CPU slot
+// without atomics:
+//
+//	struct {
+//	    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+//	    __uint(max_entries, 2);
+//	    __type(key, u32);
+//	    __type(value, u64);
+//	} counters SEC(".maps");
+//
+//	u64 *count = bpf_map_lookup_elem(&counters, &idx);
+//	if (count)
+//	    (*count)++;
+//
+// Plain array maps are supported too, for counters incremented with
+// __sync_fetch_and_add().
+//
+// Counters wrap around on overflow. Sums and deltas are computed with the
+// same modular arithmetic, so Delta() stays correct across wrap arounds.
+//
+
+// Counter reads the counters of an array or per-CPU array map of u64 values.
+type Counter struct {
+	bpfMap *BPFMap
+	last   map[uint32][]uint64 // per-CPU values at the last Delta()
+	mu     sync.Mutex
+}
+
+// NewCounter creates a Counter over the map, which must be an array or
+// per-CPU array map with u32 keys and u64 values.
+func NewCounter(bpfMap *BPFMap) (*Counter, error) {
+	mapType := bpfMap.Type()
+	if mapType != MapTypeArray && mapType != MapTypePerCPUArray {
+		return nil, fmt.Errorf("failed to create counter: map %s type is %s", bpfMap.Name(), mapType)
+	}
+	if bpfMap.KeySize() != 4 || bpfMap.ValueSize() != 8 {
+		return nil, fmt.Errorf("failed to create counter: map %s key size %d, value size %d (expected 4 and 8)", bpfMap.Name(), bpfMap.KeySize(), bpfMap.ValueSize())
+	}
+
+	return &Counter{
+		bpfMap: bpfMap,
+		last:   make(map[uint32][]uint64),
+	}, nil
+}
+
+// GetCounter creates a Counter over the map with the given name.
+func (m *Module) GetCounter(mapName string) (*Counter, error) {
+	bpfMap, err := m.GetMap(mapName)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCounter(bpfMap)
+}
+
+// GetPerCPU returns the values of the counter for every possible CPU (a
+// single value for plain array maps).
+func (c *Counter) GetPerCPU(index uint32) ([]uint64, error) {
+	value, err := c.bpfMap.GetValue(unsafe.Pointer(&index))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeCounterValues(value), nil
+}
+
+// Get returns the counter value, summed over all CPUs.
+func (c *Counter) Get(index uint32) (uint64, error) {
+	values, err := c.GetPerCPU(index)
+	if err != nil {
+		return 0, err
+	}
+
+	return sumCounterValues(values), nil
+}
+
+// Delta returns the increase of the counter since the previous call to
+// Delta() (or since the creation of the Counter for the first call, as
+// counters start at 0). The counter is not modified, so no increments are
+// lost, unlike with GetAndReset().
+func (c *Counter) Delta(index uint32) (uint64, error) {
+	values, err := c.GetPerCPU(index)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delta := counterDelta(c.last[index], values)
+	c.last[index] = values
+
+	return delta, nil
+}
+
+// Reset sets the counter to 0 on all CPUs.
+func (c *Counter) Reset(index uint32) error {
+	valueSize, err := CalcMapValueSize(c.bpfMap.ValueSize(), c.bpfMap.Type())
+	if err != nil {
+		return fmt.Errorf("map %s %w", c.bpfMap.Name(), err)
+	}
+
+	value := make([]byte, valueSize)
+	if err := c.bpfMap.Update(unsafe.Pointer(&index), unsafe.Pointer(&value[0])); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.last, index)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// GetAndReset returns the counter value and sets it to 0. Increments done by
+// programs between the read and the reset are lost, use Delta() to avoid it.
+func (c *Counter) GetAndReset(index uint32) (uint64, error) {
+	value, err := c.Get(index)
+	if err != nil {
+		return 0, err
+	}
+
+	return value, c.Reset(index)
+}
+
+// Increment adds n to the counter from user space, on the slot of the first CPU
+// for per-CPU maps. It is not atomic with respect to the program updates.
+func (c *Counter) Increment(index uint32, n uint64) error {
+	value, err := c.bpfMap.GetValue(unsafe.Pointer(&index))
+	if err != nil {
+		return err
+	}
+
+	binary.NativeEndian.PutUint64(value, binary.NativeEndian.Uint64(value)+n)
+
+	return c.bpfMap.Update(unsafe.Pointer(&index), unsafe.Pointer(&value[0]))
+}
+
+// decodeCounterValues decodes the u64 values of a map value, one per CPU for
+// per-CPU maps.
+func decodeCounterValues(value []byte) []uint64 {
+	values := make([]uint64, len(value)/8)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint64(value[i*8:])
+	}
+
+	return values
+}
+
+func sumCounterValues(values []uint64) uint64 {
+	var sum uint64
+	for _, v := range values {
+		sum += v // wraps around like the counters
+	}
+
+	return sum
+}
+
+// counterDelta returns the increase from prev to curr. Every CPU slot is
+// compared on its own, so a slot wrapping around is counted correctly.
+func counterDelta(prev, curr []uint64) uint64 {
+	var delta uint64
+	for i, v := range curr {
+		if i < len(prev) {
+			v -= prev[i]
+		}
+		delta += v
+	}
+
+	return delta
+}
@@ -0,0 +1,35 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCounterValues(t *testing.T) {
+	value := make([]byte, 24)
+	binary.NativeEndian.PutUint64(value[0:], 1)
+	binary.NativeEndian.PutUint64(value[8:], 2)
+	binary.NativeEndian.PutUint64(value[16:], 3)
+
+	values := decodeCounterValues(value)
+	assert.Equal(t, []uint64{1, 2, 3}, values)
+	assert.Equal(t, uint64(6), sumCounterValues(values))
+}
+
+func TestCounterDelta(t *testing.T) {
+	// First read, counters start at 0
+	assert.Equal(t, uint64(15), counterDelta(nil, []uint64{5, 10}))
+
+	assert.Equal(t, uint64(4), counterDelta([]uint64{5, 10}, []uint64{6, 13}))
+
+	// A CPU slot wrapping around
+	assert.Equal(t, uint64(3), counterDelta([]uint64{math.MaxUint64 - 1, 10}, []uint64{1, 10}))
+
+	// The sum wrapping around does not matter either
+	prev := []uint64{math.MaxUint64, math.MaxUint64}
+	curr := []uint64{2, 0}
+	assert.Equal(t, uint64(4), counterDelta(prev, curr))
+}
@@ -1,6 +1,7 @@
 package libbpfgo
 
 import (
+	"bytes"
 	"debug/elf"
 	"encoding/binary"
 	"errors"
@@ -49,6 +50,141 @@ func getGlobalVariableSymbol(e *elf.File, varName string) (*Symbol, error) {
 	return nil, errors.New("symbol not found")
 }
 
+// mapReferences holds the maps (declared in the .maps section) referenced by
+// the BPF object code, as found in its relocation sections.
+type mapReferences struct {
+	declared  map[string]struct{}            // all maps declared in .maps
+	byProg    map[string]map[string]struct{} // program name -> maps
+	bySubprog map[string]struct{}            // maps referenced by .text subprograms
+	byMaps    map[string]struct{}            // maps referenced by other maps (e.g. map-in-map)
+}
+
+func getMapReferences(e *elf.File) (*mapReferences, error) {
+	symbols, err := e.Symbols()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := &mapReferences{
+		declared:  make(map[string]struct{}),
+		byProg:    make(map[string]map[string]struct{}),
+		bySubprog: make(map[string]struct{}),
+		byMaps:    make(map[string]struct{}),
+	}
+
+	sectionName := func(s elf.Symbol) string {
+		i := int(s.Section)
+		if i >= len(e.Sections) {
+			return ""
+		}
+		return e.Sections[i].Name
+	}
+
+	for _, s := range symbols {
+		if sectionName(s) == ".maps" && elf.ST_TYPE(s.Info) == elf.STT_OBJECT {
+			refs.declared[s.Name] = struct{}{}
+		}
+	}
+
+	for _, relSec := range e.Sections {
+		if relSec.Type != elf.SHT_REL || int(relSec.Info) >= len(e.Sections) {
+			continue
+		}
+		target := e.Sections[relSec.Info]
+
+		data, err := relSec.Data()
+		if err != nil {
+			return nil, err
+		}
+
+		rels := make([]elf.Rel64, len(data)/binary.Size(elf.Rel64{}))
+		if err := binary.Read(bytes.NewReader(data), e.ByteOrder, rels); err != nil {
+			return nil, err
+		}
+
+		for _, rel := range rels {
+			// e.Symbols() skips the reserved symbol at index 0
+			symIdx := int(elf.R_SYM64(rel.Info))
+			if symIdx == 0 || symIdx > len(symbols) {
+				continue
+			}
+			sym := symbols[symIdx-1]
+			if sectionName(sym) != ".maps" {
+				continue
+			}
+
+			switch target.Name {
+			case ".text":
+				refs.bySubprog[sym.Name] = struct{}{}
+			case ".maps":
+				refs.byMaps[sym.Name] = struct{}{}
+			default:
+				prog := getFunctionAt(symbols, e, target, rel.Off)
+				if prog == "" {
+					continue
+				}
+				if refs.byProg[prog] == nil {
+					refs.byProg[prog] = make(map[string]struct{})
+				}
+				refs.byProg[prog][sym.Name] = struct{}{}
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// unreferenced returns the maps, among the given ones, declared in .maps and
+// not referenced by the loaded programs, by their subprograms or by other
+// maps.
+func (r *mapReferences) unreferenced(maps []string, loadedProgs []string) []string {
+	used := make(map[string]struct{})
+	for name := range r.byMaps {
+		used[name] = struct{}{}
+	}
+	for _, prog := range loadedProgs {
+		for name := range r.byProg[prog] {
+			used[name] = struct{}{}
+		}
+	}
+	if len(loadedProgs) > 0 {
+		for name := range r.bySubprog {
+			used[name] = struct{}{}
+		}
+	}
+
+	var unused []string
+	for _, name := range maps {
+		if _, ok := r.declared[name]; !ok {
+			continue
+		}
+		if _, ok := used[name]; !ok {
+			unused = append(unused, name)
+		}
+	}
+
+	return unused
+}
+
+// getFunctionAt returns the name of the function symbol, in the given section,
+// which contains the given offset.
+func getFunctionAt(symbols []elf.Symbol, e *elf.File, section *elf.Section, off uint64) string {
+	for _, s := range symbols {
+		if elf.ST_TYPE(s.Info) != elf.STT_FUNC {
+			continue
+		}
+		i := int(s.Section)
+		if i >= len(e.Sections) || e.Sections[i] != section {
+			continue
+		}
+		if off >= s.Value && off < s.Value+s.Size {
+			return s.Name
+		}
+	}
+
+	return ""
+}
+
 func isGlobalVariableSection(sectionName string) bool {
 	if sectionName == ".data" || sectionName == ".rodata" {
 		return true
@@ -0,0 +1,148 @@
+package libbpfgo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMapRefsObject builds a BPF ELF object where:
+//   - the kprobe__sys_mmap program references the events map, and calls
+//     the helper subprogram,
+//   - the helper subprogram references the counts map,
+//   - the outer map-in-map is initialized with the inner map,
+//   - the unused map is not referenced.
+func newMapRefsObject(t *testing.T) []byte {
+	const (
+		secProg = iota + 1
+		secText
+		secMaps
+		secRelProg
+		secRelText
+		secRelMaps
+		secSymtab
+		secStrtab
+		secShstrtab
+		numSections
+	)
+
+	shstrtab := []byte("\x00kprobe/sys_mmap\x00.text\x00.maps\x00.relkprobe/sys_mmap\x00.rel.text\x00.rel.maps\x00.symtab\x00.strtab\x00.shstrtab\x00")
+	shName := func(name string) uint32 {
+		return uint32(bytes.Index(shstrtab, []byte("\x00"+name+"\x00")) + 1)
+	}
+	strtab := []byte("\x00kprobe__sys_mmap\x00helper\x00events\x00counts\x00inner\x00outer\x00unused\x00")
+	symName := func(name string) uint32 {
+		return uint32(bytes.Index(strtab, []byte("\x00"+name+"\x00")) + 1)
+	}
+
+	funcInfo := elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC)
+	objInfo := elf.ST_INFO(elf.STB_GLOBAL, elf.STT_OBJECT)
+	symbols := []elf.Sym64{
+		{},
+		{Name: symName("kprobe__sys_mmap"), Info: funcInfo, Shndx: secProg, Size: 32},
+		{Name: symName("helper"), Info: funcInfo, Shndx: secText, Size: 16},
+		{Name: symName("events"), Info: objInfo, Shndx: secMaps, Value: 0, Size: 32},
+		{Name: symName("counts"), Info: objInfo, Shndx: secMaps, Value: 32, Size: 32},
+		{Name: symName("inner"), Info: objInfo, Shndx: secMaps, Value: 64, Size: 32},
+		{Name: symName("outer"), Info: objInfo, Shndx: secMaps, Value: 96, Size: 40},
+		{Name: symName("unused"), Info: objInfo, Shndx: secMaps, Value: 136, Size: 32},
+	}
+	rel := func(off uint64, sym uint32) elf.Rel64 {
+		return elf.Rel64{Off: off, Info: elf.R_INFO(sym, 1)} // R_BPF_64_64
+	}
+	relProg := []elf.Rel64{rel(8, 3), rel(16, 2)} // events, and the call to helper
+	relText := []elf.Rel64{rel(0, 4)}             // counts
+	relMaps := []elf.Rel64{rel(128, 5)}           // inner, in the values of outer
+
+	var data bytes.Buffer
+	hdrSize := binary.Size(elf.Header64{})
+	sections := make([]elf.Section64, numSections)
+	add := func(i int, name string, typ elf.SectionType, v any) {
+		off := hdrSize + data.Len()
+		require.NoError(t, binary.Write(&data, binary.LittleEndian, v))
+		sections[i] = elf.Section64{
+			Name:      shName(name),
+			Type:      uint32(typ),
+			Off:       uint64(off),
+			Size:      uint64(hdrSize + data.Len() - off),
+			Addralign: 1,
+		}
+	}
+	add(secProg, "kprobe/sys_mmap", elf.SHT_PROGBITS, make([]byte, 32))
+	add(secText, ".text", elf.SHT_PROGBITS, make([]byte, 16))
+	add(secMaps, ".maps", elf.SHT_PROGBITS, make([]byte, 168))
+	add(secRelProg, ".relkprobe/sys_mmap", elf.SHT_REL, relProg)
+	add(secRelText, ".rel.text", elf.SHT_REL, relText)
+	add(secRelMaps, ".rel.maps", elf.SHT_REL, relMaps)
+	add(secSymtab, ".symtab", elf.SHT_SYMTAB, symbols)
+	add(secStrtab, ".strtab", elf.SHT_STRTAB, strtab)
+	add(secShstrtab, ".shstrtab", elf.SHT_STRTAB, shstrtab)
+
+	for i, info := range map[int]int{secRelProg: secProg, secRelText: secText, secRelMaps: secMaps} {
+		sections[i].Link = secSymtab
+		sections[i].Info = uint32(info)
+		sections[i].Entsize = uint64(binary.Size(elf.Rel64{}))
+	}
+	sections[secSymtab].Link = secStrtab
+	sections[secSymtab].Info = 1
+	sections[secSymtab].Entsize = uint64(binary.Size(elf.Sym64{}))
+
+	var buf bytes.Buffer
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_BPF),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(hdrSize + data.Len()),
+		Ehsize:    uint16(hdrSize),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     numSections,
+		Shstrndx:  secShstrtab,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, hdr))
+	buf.Write(data.Bytes())
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, sections))
+
+	return buf.Bytes()
+}
+
+func TestGetMapReferences(t *testing.T) {
+	f, err := elf.NewFile(bytes.NewReader(newMapRefsObject(t)))
+	require.NoError(t, err)
+
+	refs, err := getMapReferences(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]struct{}{"events": {}, "counts": {}, "inner": {}, "outer": {}, "unused": {}}, refs.declared)
+	assert.Equal(t, map[string]map[string]struct{}{"kprobe__sys_mmap": {"events": {}}}, refs.byProg)
+	assert.Equal(t, map[string]struct{}{"counts": {}}, refs.bySubprog)
+	assert.Equal(t, map[string]struct{}{"inner": {}}, refs.byMaps)
+}
+
+func TestMapReferencesUnreferenced(t *testing.T) {
+	refs := &mapReferences{
+		declared: map[string]struct{}{"events": {}, "counts": {}, "inner": {}, "outer": {}, "unused": {}},
+		byProg: map[string]map[string]struct{}{
+			"kprobe__sys_mmap": {"events": {}},
+			"kprobe__sys_read": {"outer": {}},
+		},
+		bySubprog: map[string]struct{}{"counts": {}},
+		byMaps:    map[string]struct{}{"inner": {}},
+	}
+	maps := []string{"events", "counts", "inner", "outer", "unused", ".rodata"}
+
+	// Maps not declared in .maps, as the global data ones, are left alone
+	assert.Equal(t, []string{"outer", "unused"}, refs.unreferenced(maps, []string{"kprobe__sys_mmap"}))
+	assert.Equal(t, []string{"unused"}, refs.unreferenced(maps, []string{"kprobe__sys_mmap", "kprobe__sys_read"}))
+
+	// Subprograms are not loaded without programs, maps initialized with
+	// other maps are kept anyway
+	assert.Equal(t, []string{"events", "counts", "outer", "unused"}, refs.unreferenced(maps, nil))
+}
@@ -0,0 +1,446 @@
+package libbpfgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+//
+// Sentinel errors
+//
+// Load and attach failures are classified from their errno and, for loads,
+// from the libbpf log emitted meanwhile, so applications can branch with
+// errors.Is() instead of matching messages:
+//
+//	if err := m.BPFLoadObject(); errors.Is(err, libbpfgo.ErrVerifierRejected) {
+//		...
+//	}
+//
+// The classification is heuristic, and the errno stays in the error chain.
+//
+// The errnos the kernel returns for an operation are terse, and the same
+// errno has different causes depending on the operation (EPERM is a missing
+// capability or the locked memory limit, E2BIG a too large program or a full
+// map). The errors are given a hint about the likely cause, returned by
+// ErrorHint(), and appended to their message between parentheses after
+// SetErrorHints(true):
+//
+//	failed to load BPF object: operation not permitted (the process lacks
+//	CAP_BPF, or CAP_SYS_ADMIN before v5.8)
+//
+
+var (
+	// ErrNoBTF is returned when kernel BTF, needed by CO-RE relocations or
+	// BTF based programs, is not available.
+	ErrNoBTF = errors.New("kernel BTF not available")
+	// ErrProgTooLarge is returned when a program exceeds the verifier
+	// complexity or size limits.
+	ErrProgTooLarge = errors.New("BPF program too large")
+	// ErrVerifierRejected is returned when the verifier rejects a program.
+	ErrVerifierRejected = errors.New("BPF program rejected by the verifier")
+	// ErrNotSupportedByKernel is returned when the kernel lacks a feature
+	// (program or map type, helper, attach type).
+	ErrNotSupportedByKernel = errors.New("not supported by the kernel")
+	// ErrNotSupportedByLibbpf is returned when the libbpf version libbpfgo
+	// was built against lacks a feature (see the Has* flags).
+	ErrNotSupportedByLibbpf = errors.New("not supported by the libbpf version")
+	// ErrPermission is returned when the process lacks privileges.
+	ErrPermission = errors.New("operation not permitted")
+	// ErrSleepableNotAllowed is returned when a sleepable program is set up
+	// or attached where only non-sleepable programs can run.
+	ErrSleepableNotAllowed = errors.New("sleepable program not allowed")
+	// ErrGPLRequired is returned when a program calls GPL-only helpers or
+	// kfuncs from an object whose license is not GPL-compatible. It also
+	// matches ErrVerifierRejected.
+	ErrGPLRequired = errors.New("GPL-compatible license required")
+	// ErrNoMoreKeys is returned by GetNextKey() past the last key of a map,
+	// or on an empty map.
+	ErrNoMoreKeys = errors.New("no more keys in map")
+)
+
+// enotsupp is the kernel internal ENOTSUPP, which leaks to userspace from
+// some BPF paths.
+const enotsupp = syscall.Errno(524)
+
+var (
+	logMarkersNoBTF = []string{
+		"kernel BTF is missing",
+		"failed to find valid kernel BTF",
+	}
+	logMarkersTooLarge = []string{
+		"BPF program is too large",
+	}
+	logMarkersNotSupported = []string{
+		"unknown func",
+		"invalid func unknown",
+		"program of this type isn't supported",
+		"kernel doesn't support",
+	}
+	logMarkersGPL = []string{
+		"cannot call GPL-restricted function from non-GPL compatible program",
+		"cannot call kernel function from non-GPL compatible program",
+	}
+	logMarkersVerifier = []string{
+		"-- BEGIN PROG LOAD LOG --",
+		"processed ",
+	}
+)
+
+// bpfOp is the kind of operation an error comes from, which the hints
+// depend on.
+type bpfOp int
+
+const (
+	opLoad      bpfOp = iota // program or object load
+	opAttach                 // program attach
+	opMapCreate              // map creation
+	opMapUpdate              // map element update
+	opMapBatch               // map batch operation
+)
+
+// classifiedError wraps an error with the sentinel error it was classified
+// as, if any, and a hint about its cause.
+type classifiedError struct {
+	err      error
+	sentinel error
+	hint     string
+}
+
+func (e *classifiedError) Error() string {
+	if e.hint == "" || !errorHints.Load() {
+		return e.err.Error()
+	}
+
+	return e.err.Error() + " (" + e.hint + ")"
+}
+
+func (e *classifiedError) Unwrap() []error {
+	if e.sentinel == nil {
+		return []error{e.err}
+	}
+
+	return []error{e.err, e.sentinel}
+}
+
+// errorHints tells whether the hints are appended to the error messages.
+var errorHints atomic.Bool
+
+// SetErrorHints sets whether the hints about the cause of the errors, see
+// ErrorHint(), are appended to the messages of the errors returned from then
+// on. They are not by default.
+func SetErrorHints(enabled bool) {
+	errorHints.Store(enabled)
+}
+
+// ErrorHint returns the hint about the likely cause of an error returned by
+// libbpfgo, or "" if there is none.
+func ErrorHint(err error) string {
+	var classified *classifiedError
+	if !errors.As(err, &classified) {
+		return ""
+	}
+
+	return classified.hint
+}
+
+// classifyError wraps err, from op, with the matching sentinel error and
+// hint, found from its errno and the libbpf log. err is returned as is if
+// nothing matches.
+func classifyError(op bpfOp, err error, log string) error {
+	var errno syscall.Errno
+	if err == nil || !errors.As(err, &errno) {
+		return err
+	}
+
+	sentinel := errnoSentinel(errno, log)
+	hint := errnoHint(op, errno, sentinel)
+	if sentinel == nil && hint == "" {
+		return err
+	}
+
+	return &classifiedError{err: err, sentinel: sentinel, hint: hint}
+}
+
+// errnoSentinel returns the sentinel error of errno and the libbpf log, or
+// nil.
+func errnoSentinel(errno syscall.Errno, log string) error {
+	var sentinel error
+	switch {
+	case containsAny(log, logMarkersTooLarge):
+		sentinel = ErrProgTooLarge
+	case containsAny(log, logMarkersNoBTF):
+		sentinel = ErrNoBTF
+	case errno == syscall.EOPNOTSUPP || errno == enotsupp || errno == syscall.ENOSYS,
+		containsAny(log, logMarkersNotSupported):
+		sentinel = ErrNotSupportedByKernel
+	case containsAny(log, logMarkersGPL):
+		sentinel = errors.Join(ErrGPLRequired, ErrVerifierRejected)
+	case errno == syscall.E2BIG && containsAny(log, logMarkersVerifier):
+		sentinel = ErrProgTooLarge
+	case containsAny(log, logMarkersVerifier):
+		sentinel = ErrVerifierRejected
+	case errno == syscall.EPERM || errno == syscall.EACCES:
+		sentinel = ErrPermission
+	}
+
+	return sentinel
+}
+
+// errnoHint returns the hint about the cause of errno from op, classified as
+// sentinel, or "".
+func errnoHint(op bpfOp, errno syscall.Errno, sentinel error) string {
+	switch {
+	case errors.Is(sentinel, ErrPermission):
+		return permissionHint(op, readCapabilities())
+	case errors.Is(sentinel, ErrGPLRequired):
+		return `the object license must be GPL-compatible, as in char LICENSE[] SEC("license") = "GPL"`
+	case sentinel == ErrProgTooLarge:
+		return "the verifier processes at most 1M instructions: split the program with tail calls or global functions, or bound its loops tighter"
+	case sentinel == ErrNoBTF:
+		return "the kernel was built without CONFIG_DEBUG_INFO_BTF: give its BTF, from BTFHub, with NewModuleArgs.BTFObjPath"
+	case sentinel == ErrNotSupportedByKernel:
+		switch op {
+		case opLoad:
+			return "the kernel lacks the program or map type, or a helper or kfunc it uses"
+		case opAttach:
+			return "the kernel lacks the attach type, or BPF links for it: the legacy attach variants cover older kernels"
+		case opMapCreate:
+			return "the kernel lacks the map type, or one of its flags"
+		case opMapBatch:
+			return "the map type has no batch operations, or the kernel predates them (v5.6)"
+		}
+	}
+
+	switch op {
+	case opAttach:
+		switch errno {
+		case syscall.EBUSY:
+			return "another program is attached exclusively (XDP, tc): detach it first"
+		case syscall.EEXIST:
+			return "the program is already attached to the target"
+		case syscall.ENOENT:
+			return "the attach target (function, tracepoint, device, cgroup) does not exist"
+		}
+	case opMapUpdate, opMapBatch:
+		switch errno {
+		case syscall.E2BIG:
+			return "the map is full: raise its max entries, or use an LRU map"
+		case syscall.ENOSPC:
+			if op == opMapBatch {
+				return "a hash bucket holds more elements than the batch: retry with a larger count"
+			}
+		}
+	}
+
+	return ""
+}
+
+// Capabilities, by bit number
+const (
+	capNetAdmin = 12
+	capSysAdmin = 21
+	capPerfmon  = 38
+	capBPF      = 39
+)
+
+// capabilities is a set of effective capabilities.
+type capabilities uint64
+
+func (c capabilities) has(capability int) bool {
+	return c&(1<<capability) != 0
+}
+
+// readCapabilities returns the effective capabilities of the process.
+var readCapabilities = readCapabilitiesProc
+
+// readCapabilitiesProc reads the effective capabilities of the process from
+// procfs, or returns none if they can not be read.
+func readCapabilitiesProc() capabilities {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return 0
+		}
+		return capabilities(caps)
+	}
+
+	return 0
+}
+
+// permissionHint tells the missing capabilities of op from the effective
+// ones, or the other causes of EPERM when none are missing.
+func permissionHint(op bpfOp, caps capabilities) string {
+	privileged := caps.has(capSysAdmin) || caps.has(capBPF)
+
+	switch {
+	case op == opMapUpdate || op == opMapBatch:
+		return "the map is frozen, or read-only from userspace (BPF_F_RDONLY)"
+	case !privileged && op == opAttach:
+		return "the process lacks CAP_BPF, with CAP_PERFMON for tracing programs or CAP_NET_ADMIN for networking ones, or CAP_SYS_ADMIN"
+	case !privileged:
+		return "the process lacks CAP_BPF, or CAP_SYS_ADMIN before v5.8"
+	case op == opAttach && !caps.has(capSysAdmin) && (!caps.has(capPerfmon) || !caps.has(capNetAdmin)):
+		return "the program type may need CAP_PERFMON (tracing) or CAP_NET_ADMIN (networking) besides CAP_BPF"
+	case op == opLoad || op == opMapCreate:
+		return "the locked memory limit may be too low, kernels before v5.11 charge BPF memory to RLIMIT_MEMLOCK (see NewModuleArgs.SkipMemlockBump), or the kernel lockdown or an LSM denies it"
+	default:
+		return "the kernel lockdown or an LSM may deny it"
+	}
+}
+
+// nextKeyError marks the error of a get next key failing with ENOENT, which
+// means the end of the map, with ErrNoMoreKeys.
+func nextKeyError(err error, errno syscall.Errno) error {
+	if errno != syscall.ENOENT {
+		return err
+	}
+
+	return &classifiedError{err: err, sentinel: ErrNoMoreKeys}
+}
+
+// MapBatchError is the error of a batch operation that stopped at one of its
+// elements, after processing the ones before it.
+type MapBatchError struct {
+	// Index is the index of the failing element, which is also the number
+	// of elements processed.
+	Index uint32
+	// Err is the error of the element.
+	Err error
+}
+
+func (e *MapBatchError) Error() string {
+	return fmt.Sprintf("element %d: %v", e.Index, e.Err)
+}
+
+func (e *MapBatchError) Unwrap() error {
+	return e.Err
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// libbpf log capture
+//
+
+// maxCapturedLog bounds the captured log, the end of the verifier log holds
+// the reason of the failure.
+const maxCapturedLog = 64 * 1024
+
+// logCapture records the libbpf output about an object while it loads. The
+// output about the programs and maps of other objects, loading concurrently,
+// is left out; the output about none of them, as a missing kernel BTF, is
+// recorded by all the captures. Since the output is told apart by names only,
+// a capture waits for the ones sharing names with it to stop.
+type logCapture struct {
+	names map[string]bool // of the programs and maps of the object
+	buf   strings.Builder
+}
+
+var (
+	logCaptures        = make(map[*logCapture]struct{})
+	logCapturesMu      sync.Mutex
+	logCapturesStopped = sync.NewCond(&logCapturesMu)
+)
+
+func startLogCapture(names map[string]bool) *logCapture {
+	c := &logCapture{names: names}
+
+	logCapturesMu.Lock()
+	for logCapturesOverlap(names) {
+		logCapturesStopped.Wait()
+	}
+	logCaptures[c] = struct{}{}
+	logCapturesMu.Unlock()
+
+	return c
+}
+
+// logCapturesOverlap reports whether an active capture shares names with the
+// given ones. logCapturesMu must be held.
+func logCapturesOverlap(names map[string]bool) bool {
+	for c := range logCaptures {
+		for name := range names {
+			if c.names[name] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// stop ends the capture and returns the captured output.
+func (c *logCapture) stop() string {
+	logCapturesMu.Lock()
+	delete(logCaptures, c)
+	logCapturesStopped.Broadcast()
+	logCapturesMu.Unlock()
+
+	return c.buf.String()
+}
+
+// captureLog feeds the libbpf output to the active captures it is about.
+func captureLog(output string) {
+	subject, ok := logSubject(output)
+
+	logCapturesMu.Lock()
+	defer logCapturesMu.Unlock()
+
+	for c := range logCaptures {
+		if ok && !c.names[subject] {
+			continue
+		}
+		if c.buf.Len() >= maxCapturedLog {
+			// Keep the end of the log
+			tail := c.buf.String()[c.buf.Len()-maxCapturedLog/2:]
+			c.buf.Reset()
+			c.buf.WriteString(tail)
+		}
+		c.buf.WriteString(output)
+	}
+}
+
+// logSubject returns the name of the program or map the libbpf output is
+// about, if any:
+//
+//	libbpf: prog 'handle_exec': BPF program load failed: Permission denied
+//	libbpf: map 'events': failed to create: Invalid argument(-22)
+func logSubject(output string) (string, bool) {
+	output = strings.TrimPrefix(output, "libbpf: ")
+	for _, prefix := range []string{"prog '", "map '"} {
+		if rest, ok := strings.CutPrefix(output, prefix); ok {
+			if name, _, ok := strings.Cut(rest, "'"); ok {
+				return name, true
+			}
+			break
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,218 @@
+package libbpfgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	verifierLog := "libbpf: prog 'p': -- BEGIN PROG LOAD LOG --\nR1 invalid mem access 'scalar'\nprocessed 12 insns\n"
+
+	testCases := []struct {
+		name     string
+		errno    syscall.Errno
+		log      string
+		sentinel error
+	}{
+		{"verifier", syscall.EACCES, verifierLog, ErrVerifierRejected},
+		{"too large", syscall.E2BIG, "BPF program is too large. Processed 1000001 insn\n", ErrProgTooLarge},
+		{"too large errno", syscall.E2BIG, verifierLog, ErrProgTooLarge},
+		{"no btf", syscall.ESRCH, "libbpf: kernel BTF is missing at '/sys/kernel/btf/vmlinux'\n", ErrNoBTF},
+		{"unknown helper", syscall.EINVAL, "unknown func bpf_loop#181\nprocessed 3 insns\n", ErrNotSupportedByKernel},
+		{"enotsupp", enotsupp, "", ErrNotSupportedByKernel},
+		{"eopnotsupp", syscall.EOPNOTSUPP, "", ErrNotSupportedByKernel},
+		{"permission", syscall.EPERM, "", ErrPermission},
+		{"unclassified", syscall.ENOENT, "", nil},
+	}
+
+	sentinels := []error{ErrNoBTF, ErrProgTooLarge, ErrVerifierRejected, ErrNotSupportedByKernel, ErrPermission}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := fmt.Errorf("failed to load BPF object: %w", classifyError(opLoad, tc.errno, tc.log))
+
+			assert.ErrorIs(t, err, tc.errno)
+			assert.True(t, strings.HasPrefix(err.Error(), "failed to load BPF object: "+tc.errno.Error()), err)
+			for _, sentinel := range sentinels {
+				assert.Equal(t, sentinel == tc.sentinel, errors.Is(err, sentinel), sentinel)
+			}
+		})
+	}
+
+	assert.NoError(t, classifyError(opLoad, nil, verifierLog))
+
+	plain := errors.New("no errno")
+	assert.Equal(t, plain, classifyError(opLoad, plain, verifierLog))
+}
+
+func TestErrorHint(t *testing.T) {
+	caps := capabilities(0)
+	readCapabilities = func() capabilities { return caps }
+	defer func() { readCapabilities = readCapabilitiesProc }()
+
+	testCases := []struct {
+		name  string
+		caps  capabilities
+		op    bpfOp
+		errno syscall.Errno
+		log   string
+		hint  string
+	}{
+		{"load unprivileged", 0, opLoad, syscall.EPERM, "", "lacks CAP_BPF, or CAP_SYS_ADMIN"},
+		{"load memlock", 1 << capBPF, opLoad, syscall.EPERM, "", "RLIMIT_MEMLOCK"},
+		{"attach unprivileged", 0, opAttach, syscall.EPERM, "", "CAP_PERFMON for tracing"},
+		{"attach without perfmon", 1 << capBPF, opAttach, syscall.EACCES, "", "CAP_PERFMON (tracing)"},
+		{"attach privileged", 1 << capSysAdmin, opAttach, syscall.EPERM, "", "lockdown"},
+		{"frozen map", 1 << capSysAdmin, opMapUpdate, syscall.EPERM, "", "frozen"},
+		{"attach not supported", 0, opAttach, enotsupp, "", "attach type"},
+		{"batch not supported", 0, opMapBatch, enotsupp, "", "batch operations"},
+		{"too large", 0, opLoad, syscall.E2BIG, "BPF program is too large. Processed 1000001 insn\n", "tail calls"},
+		{"no btf", 0, opLoad, syscall.ESRCH, "libbpf: kernel BTF is missing at '/sys/kernel/btf/vmlinux'\n", "BTFObjPath"},
+		{"map full", 0, opMapUpdate, syscall.E2BIG, "", "map is full"},
+		{"batch map full", 0, opMapBatch, syscall.E2BIG, "", "map is full"},
+		{"batch bucket", 0, opMapBatch, syscall.ENOSPC, "", "larger count"},
+		{"attach busy", 0, opAttach, syscall.EBUSY, "", "detach it first"},
+		{"no hint", 0, opLoad, syscall.ENOENT, "", ""},
+		{"update no space", 0, opMapUpdate, syscall.ENOSPC, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			caps = tc.caps
+			err := fmt.Errorf("failed: %w", classifyError(tc.op, tc.errno, tc.log))
+
+			assert.ErrorIs(t, err, tc.errno)
+			hint := ErrorHint(err)
+			assert.Equal(t, "failed: "+tc.errno.Error(), err.Error())
+			if tc.hint == "" {
+				assert.Empty(t, hint)
+				return
+			}
+			assert.Contains(t, hint, tc.hint)
+
+			// Appended to the message when enabled
+			SetErrorHints(true)
+			defer SetErrorHints(false)
+			err = fmt.Errorf("failed: %w", classifyError(tc.op, tc.errno, tc.log))
+			assert.Equal(t, "failed: "+tc.errno.Error()+" ("+hint+")", err.Error())
+		})
+	}
+
+	// Hints alone do not classify
+	err := classifyError(opAttach, syscall.EBUSY, "")
+	for _, sentinel := range []error{ErrNoBTF, ErrProgTooLarge, ErrVerifierRejected, ErrNotSupportedByKernel, ErrPermission} {
+		assert.NotErrorIs(t, err, sentinel)
+	}
+
+	assert.Empty(t, ErrorHint(errors.New("not from libbpfgo")))
+}
+
+func TestCapabilities(t *testing.T) {
+	caps := capabilities(0x000001ffffffffff)
+	assert.True(t, caps.has(capBPF))
+	assert.True(t, caps.has(capSysAdmin))
+	assert.False(t, capabilities(1<<capBPF).has(capPerfmon))
+}
+
+func TestNextKeyError(t *testing.T) {
+	err := nextKeyError(fmt.Errorf("failed to get next key: %w", syscall.ENOENT), syscall.ENOENT)
+	assert.ErrorIs(t, err, ErrNoMoreKeys)
+	assert.ErrorIs(t, err, syscall.ENOENT)
+	assert.Equal(t, "failed to get next key: "+syscall.ENOENT.Error(), err.Error())
+
+	err = nextKeyError(fmt.Errorf("failed to get next key: %w", syscall.EBADF), syscall.EBADF)
+	assert.NotErrorIs(t, err, ErrNoMoreKeys)
+	assert.ErrorIs(t, err, syscall.EBADF)
+}
+
+func TestLogCapture(t *testing.T) {
+	captureLog("before\n")
+
+	c := startLogCapture(nil)
+	captureLog("during\n")
+	assert.Equal(t, "during\n", c.stop())
+
+	captureLog("after\n")
+	assert.Equal(t, "during\n", c.buf.String())
+
+	// Only the end of a long log is kept
+	c = startLogCapture(nil)
+	for i := 0; i < maxCapturedLog; i++ {
+		captureLog("x")
+	}
+	captureLog("end\n")
+	log := c.stop()
+	assert.LessOrEqual(t, len(log), maxCapturedLog+len("end\n"))
+	assert.True(t, strings.HasSuffix(log, "xend\n"))
+}
+
+func TestLogCaptureConcurrentLoads(t *testing.T) {
+	a := startLogCapture(map[string]bool{"prog_a": true, "map_a": true})
+	b := startLogCapture(map[string]bool{"prog_b": true})
+
+	captureLog("libbpf: prog 'prog_a': -- BEGIN PROG LOAD LOG --\nunknown func bpf_foo#212\n-- END PROG LOAD LOG --\n")
+	captureLog("libbpf: map 'map_a': failed to create: Invalid argument(-22)\n")
+	captureLog("libbpf: prog 'prog_b': BPF program load failed: Permission denied\n")
+	captureLog("libbpf: loading kernel BTF '/sys/kernel/btf/vmlinux': 0\n")
+
+	logA := a.stop()
+	logB := b.stop()
+	assert.Equal(t, "libbpf: prog 'prog_a': -- BEGIN PROG LOAD LOG --\nunknown func bpf_foo#212\n-- END PROG LOAD LOG --\n"+
+		"libbpf: map 'map_a': failed to create: Invalid argument(-22)\n"+
+		"libbpf: loading kernel BTF '/sys/kernel/btf/vmlinux': 0\n", logA)
+	assert.Equal(t, "libbpf: prog 'prog_b': BPF program load failed: Permission denied\n"+
+		"libbpf: loading kernel BTF '/sys/kernel/btf/vmlinux': 0\n", logB)
+
+	// The load of b is not misclassified from the log of a
+	assert.NotErrorIs(t, classifyError(opLoad, syscall.EPERM, logB), ErrNotSupportedByKernel)
+	assert.ErrorIs(t, classifyError(opLoad, syscall.EINVAL, logA), ErrNotSupportedByKernel)
+}
+
+func TestLogCaptureSameNames(t *testing.T) {
+	a := startLogCapture(map[string]bool{"prog_a": true, "events": true})
+	other := startLogCapture(map[string]bool{"prog_b": true})
+
+	// The same object loading again waits for the first load
+	started := make(chan *logCapture)
+	go func() {
+		started <- startLogCapture(map[string]bool{"prog_c": true, "events": true})
+	}()
+	select {
+	case <-started:
+		t.Fatal("capture started while another one has the same names")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	captureLog("libbpf: map 'events': failed to create: Invalid argument(-22)\n")
+	assert.Equal(t, "libbpf: map 'events': failed to create: Invalid argument(-22)\n", a.stop())
+
+	b := <-started
+	captureLog("libbpf: map 'events': failed to create: Operation not permitted(-1)\n")
+	assert.Equal(t, "libbpf: map 'events': failed to create: Operation not permitted(-1)\n", b.stop())
+	assert.Empty(t, other.stop())
+}
+
+func TestLogSubject(t *testing.T) {
+	tt := []struct {
+		output string
+		name   string
+		ok     bool
+	}{
+		{"libbpf: prog 'handle_exec': BPF program load failed: Permission denied\n", "handle_exec", true},
+		{"map 'events': failed to create: Invalid argument(-22)\n", "events", true},
+		{"libbpf: loading kernel BTF '/sys/kernel/btf/vmlinux': 0\n", "", false},
+		{"libbpf: prog 'truncated\n", "", false},
+	}
+
+	for _, tc := range tt {
+		name, ok := logSubject(tc.output)
+		assert.Equal(t, tc.name, name, tc.output)
+		assert.Equal(t, tc.ok, ok, tc.output)
+	}
+}
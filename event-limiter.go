@@ -0,0 +1,208 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+//
+// EventLimiter
+//
+// During event storms, the consumer of a ring or perf buffer may not keep up,
+// and records are lost in the kernel without any control over which ones. An
+// EventLimiter sheds load in userspace instead: events are classified (for
+// example by the attach cookie stored in their header by the program), and
+// each class is sampled and rate limited according to its EventPolicy. The
+// events dropped are counted per class and per reason.
+//
+// Set on a RingBuffer or PerfBuffer, the limiter runs in the poll goroutine
+// before the record is copied out of the buffer, so dropping an event is
+// cheap. It can also be called directly by any consumer through Allow().
+//
+
+// EventClassifier returns the class of an event, which selects its policy.
+// The data must not be retained.
+type EventClassifier func(data []byte) uint64
+
+// ClassifyByCookie classifies events by the u64, in native byte order, at the
+// given offset of the event, such as the value of bpf_get_attach_cookie()
+// written by the program. Events too short belong to class 0.
+func ClassifyByCookie(offset int) EventClassifier {
+	return func(data []byte) uint64 {
+		if offset < 0 || len(data) < offset+8 {
+			return 0
+		}
+
+		return binary.NativeEndian.Uint64(data[offset:])
+	}
+}
+
+// EventPolicy describes the events of a class passed by an EventLimiter.
+type EventPolicy struct {
+	// SampleEvery passes one event out of SampleEvery, all of them if 0.
+	// Sampling is applied before rate limiting.
+	SampleEvery uint64
+	// Rate is the sustained number of events per second passed, unlimited
+	// if 0.
+	Rate float64
+	// Burst is the number of events that can be passed at once above Rate,
+	// the rounded up Rate if 0.
+	Burst int
+}
+
+func (p EventPolicy) validate() error {
+	if p.Rate < 0 || math.IsNaN(p.Rate) || math.IsInf(p.Rate, 0) {
+		return fmt.Errorf("invalid event rate %v", p.Rate)
+	}
+	if p.Burst < 0 {
+		return fmt.Errorf("invalid event burst %d", p.Burst)
+	}
+
+	return nil
+}
+
+// burst returns the capacity of the token bucket.
+func (p EventPolicy) burst() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+
+	return math.Max(1, math.Ceil(p.Rate))
+}
+
+// EventLimiterStats counts the events of a class seen by an EventLimiter.
+type EventLimiterStats struct {
+	Passed      uint64
+	Sampled     uint64 // dropped by sampling
+	RateLimited uint64 // dropped by the rate limit
+}
+
+// Dropped returns the number of events dropped for any reason.
+func (s EventLimiterStats) Dropped() uint64 {
+	return s.Sampled + s.RateLimited
+}
+
+// EventLimiter samples and rate limits events per class.
+type EventLimiter struct {
+	classify      EventClassifier
+	defaultPolicy EventPolicy
+	policies      map[uint64]EventPolicy
+	classes       map[uint64]*eventClass
+	now           func() time.Time
+	mu            sync.Mutex
+}
+
+// eventClass is the state of a class of events.
+type eventClass struct {
+	policy EventPolicy
+	tokens float64
+	last   time.Time
+	seen   uint64
+	stats  EventLimiterStats
+}
+
+// NewEventLimiter creates an EventLimiter applying the policy of the class
+// returned by classify, or defaultPolicy for the classes without one. A nil
+// classify puts all events in class 0.
+func NewEventLimiter(classify EventClassifier, defaultPolicy EventPolicy, policies map[uint64]EventPolicy) (*EventLimiter, error) {
+	if err := defaultPolicy.validate(); err != nil {
+		return nil, fmt.Errorf("failed to create event limiter: default policy: %w", err)
+	}
+
+	l := &EventLimiter{
+		classify:      classify,
+		defaultPolicy: defaultPolicy,
+		policies:      make(map[uint64]EventPolicy, len(policies)),
+		classes:       make(map[uint64]*eventClass),
+		now:           time.Now,
+	}
+
+	for class, policy := range policies {
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("failed to create event limiter: class %d policy: %w", class, err)
+		}
+		l.policies[class] = policy
+	}
+
+	return l, nil
+}
+
+// Allow reports whether the event must be passed to the consumer, and
+// accounts for it in the stats of its class.
+func (l *EventLimiter) Allow(data []byte) bool {
+	var class uint64
+	if l.classify != nil {
+		class = l.classify(data)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.classes[class]
+	if !ok {
+		policy, ok := l.policies[class]
+		if !ok {
+			policy = l.defaultPolicy
+		}
+		c = &eventClass{
+			policy: policy,
+			tokens: policy.burst(),
+			last:   l.now(),
+		}
+		l.classes[class] = c
+	}
+
+	c.seen++
+	if c.policy.SampleEvery > 1 && (c.seen-1)%c.policy.SampleEvery != 0 {
+		c.stats.Sampled++
+		return false
+	}
+
+	if c.policy.Rate > 0 {
+		now := l.now()
+		elapsed := now.Sub(c.last).Seconds()
+		c.last = now
+		c.tokens = math.Min(c.policy.burst(), c.tokens+elapsed*c.policy.Rate)
+
+		if c.tokens < 1 {
+			c.stats.RateLimited++
+			return false
+		}
+		c.tokens--
+	}
+
+	c.stats.Passed++
+
+	return true
+}
+
+// Stats returns the stats of the classes seen so far.
+func (l *EventLimiter) Stats() map[uint64]EventLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make(map[uint64]EventLimiterStats, len(l.classes))
+	for class, c := range l.classes {
+		stats[class] = c.stats
+	}
+
+	return stats
+}
+
+// TotalStats returns the stats summed over all classes.
+func (l *EventLimiter) TotalStats() EventLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var total EventLimiterStats
+	for _, c := range l.classes {
+		total.Passed += c.stats.Passed
+		total.Sampled += c.stats.Sampled
+		total.RateLimited += c.stats.RateLimited
+	}
+
+	return total
+}
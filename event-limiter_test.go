@@ -0,0 +1,87 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cookieEvent builds an event with the cookie as header.
+func cookieEvent(cookie uint64) []byte {
+	data := make([]byte, 16)
+	binary.NativeEndian.PutUint64(data, cookie)
+
+	return data
+}
+
+func TestClassifyByCookie(t *testing.T) {
+	classify := ClassifyByCookie(8)
+
+	data := make([]byte, 16)
+	binary.NativeEndian.PutUint64(data[8:], 42)
+	assert.Equal(t, uint64(42), classify(data))
+	assert.Equal(t, uint64(0), classify(data[:12]))
+}
+
+func TestEventLimiterSampling(t *testing.T) {
+	limiter, err := NewEventLimiter(nil, EventPolicy{SampleEvery: 3}, nil)
+	require.NoError(t, err)
+
+	var passed []int
+	for i := 0; i < 7; i++ {
+		if limiter.Allow(nil) {
+			passed = append(passed, i)
+		}
+	}
+
+	assert.Equal(t, []int{0, 3, 6}, passed)
+	assert.Equal(t, EventLimiterStats{Passed: 3, Sampled: 4}, limiter.Stats()[0])
+}
+
+func TestEventLimiterRate(t *testing.T) {
+	limiter, err := NewEventLimiter(ClassifyByCookie(0), EventPolicy{}, map[uint64]EventPolicy{
+		1: {Rate: 10, Burst: 2},
+	})
+	require.NoError(t, err)
+
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	// The burst passes, then the bucket is empty
+	assert.True(t, limiter.Allow(cookieEvent(1)))
+	assert.True(t, limiter.Allow(cookieEvent(1)))
+	assert.False(t, limiter.Allow(cookieEvent(1)))
+
+	// Class 2 falls back to the unlimited default policy
+	for i := 0; i < 5; i++ {
+		assert.True(t, limiter.Allow(cookieEvent(2)))
+	}
+
+	// One token every 100ms
+	now = now.Add(150 * time.Millisecond)
+	assert.True(t, limiter.Allow(cookieEvent(1)))
+	assert.False(t, limiter.Allow(cookieEvent(1)))
+
+	// Tokens do not accumulate above the burst
+	now = now.Add(10 * time.Second)
+	assert.True(t, limiter.Allow(cookieEvent(1)))
+	assert.True(t, limiter.Allow(cookieEvent(1)))
+	assert.False(t, limiter.Allow(cookieEvent(1)))
+
+	assert.Equal(t, map[uint64]EventLimiterStats{
+		1: {Passed: 5, RateLimited: 3},
+		2: {Passed: 5},
+	}, limiter.Stats())
+	assert.Equal(t, uint64(3), limiter.TotalStats().Dropped())
+}
+
+func TestNewEventLimiterInvalid(t *testing.T) {
+	_, err := NewEventLimiter(nil, EventPolicy{Rate: -1}, nil)
+	assert.Error(t, err)
+
+	_, err = NewEventLimiter(nil, EventPolicy{}, map[uint64]EventPolicy{7: {Burst: -1}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+//
+// File descriptor passing
+//
+// A privileged process can load eBPF objects and hand their file descriptors
+// to an unprivileged process over a Unix socket (SCM_RIGHTS). The receiver
+// then owns its own copies of the descriptors, which keep the objects alive
+// independently of the sender.
+//
+
+// DupFD duplicates the given file descriptor, setting close-on-exec on the
+// new one. The caller owns the returned file descriptor.
+func DupFD(fd int) (int, error) {
+	newFD, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_DUPFD_CLOEXEC, 0)
+	if errno != 0 {
+		return -1, fmt.Errorf("failed to duplicate fd %d: %w", fd, errno)
+	}
+
+	return int(newFD), nil
+}
+
+// DupFDTo duplicates the given file descriptor as newFD, closing newFD first
+// if it was open, for helper processes expecting their descriptors at fixed
+// numbers (e.g. 3 and up after exec). close-on-exec is set on newFD if
+// cloexec is true. The caller owns newFD.
+func DupFDTo(fd int, newFD int, cloexec bool) error {
+	if fd == newFD {
+		return fmt.Errorf("failed to duplicate fd %d to itself", fd)
+	}
+
+	flags := 0
+	if cloexec {
+		flags = syscall.O_CLOEXEC
+	}
+	if err := syscall.Dup3(fd, newFD, flags); err != nil {
+		return fmt.Errorf("failed to duplicate fd %d to %d: %w", fd, newFD, err)
+	}
+
+	return nil
+}
+
+// CloseOnExec reports whether close-on-exec is set on the file descriptor.
+// The file descriptors created by libbpf and libbpfgo have it set.
+func CloseOnExec(fd int) (bool, error) {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFD, 0)
+	if errno != 0 {
+		return false, fmt.Errorf("failed to get flags of fd %d: %w", fd, errno)
+	}
+
+	return flags&syscall.FD_CLOEXEC != 0, nil
+}
+
+// SetCloseOnExec sets or clears close-on-exec on the file descriptor. A
+// descriptor without it is inherited by the processes exec'ed afterwards,
+// from any goroutine: clear it on a duplicate (DupFD()) made for a given
+// child, rather than on the descriptor of a map or program of a module.
+func SetCloseOnExec(fd int, cloexec bool) error {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFD, 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to get flags of fd %d: %w", fd, errno)
+	}
+
+	if cloexec {
+		flags |= syscall.FD_CLOEXEC
+	} else {
+		flags &^= syscall.FD_CLOEXEC
+	}
+
+	_, _, errno = syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_SETFD, flags)
+	if errno != 0 {
+		return fmt.Errorf("failed to set flags of fd %d: %w", fd, errno)
+	}
+
+	return nil
+}
+
+// DupFD duplicates the program file descriptor. The caller owns the returned
+// file descriptor, which remains valid after the module is closed.
+func (p *BPFProg) DupFD() (int, error) {
+	if err := p.checkNotUnloaded("duplicate the fd of"); err != nil {
+		return -1, err
+	}
+
+	return DupFD(p.FileDescriptor())
+}
+
+// DupFD duplicates the map file descriptor. The caller owns the returned
+// file descriptor, which remains valid after the module is closed.
+func (m *BPFMap) DupFD() (int, error) {
+	return DupFD(m.FileDescriptor())
+}
+
+// DupFD duplicates the link file descriptor. The caller owns the returned
+// file descriptor, which keeps the link attached after it is destroyed.
+func (l *BPFLink) DupFD() (int, error) {
+	return DupFD(l.FileDescriptor())
+}
+
+// SendFDs sends the given file descriptors over a Unix socket, along with an
+// optional payload (e.g. object names) describing them. The file descriptors
+// remain owned by the caller.
+func SendFDs(conn *net.UnixConn, fds []int, payload []byte) error {
+	if len(fds) == 0 {
+		return fmt.Errorf("failed to send fds: no fds given")
+	}
+
+	// At least one byte of regular data is needed to carry control messages
+	if len(payload) == 0 {
+		payload = []byte{0}
+	}
+
+	rights := syscall.UnixRights(fds...)
+	n, oobn, err := conn.WriteMsgUnix(payload, rights, nil)
+	if err != nil {
+		return fmt.Errorf("failed to send fds: %w", err)
+	}
+	if n != len(payload) || oobn != len(rights) {
+		return fmt.Errorf("failed to send fds: short write")
+	}
+
+	return nil
+}
+
+// ReceiveFDs receives up to maxFDs file descriptors, and the payload sent with
+// them, from a Unix socket. The received file descriptors have close-on-exec
+// set and are owned by the caller. payloadSize bounds the payload size.
+func ReceiveFDs(conn *net.UnixConn, maxFDs int, payloadSize int) ([]int, []byte, error) {
+	if maxFDs <= 0 {
+		return nil, nil, fmt.Errorf("failed to receive fds: invalid max fds %d", maxFDs)
+	}
+	if payloadSize <= 0 {
+		payloadSize = 1
+	}
+
+	payload := make([]byte, payloadSize)
+	oob := make([]byte, syscall.CmsgSpace(maxFDs*4))
+
+	n, oobn, flags, _, err := conn.ReadMsgUnix(payload, oob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to receive fds: %w", err)
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse control messages: %w", err)
+	}
+
+	var fds []int
+	for i := range msgs {
+		rights, err := syscall.ParseUnixRights(&msgs[i])
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+
+	for _, fd := range fds {
+		syscall.CloseOnExec(fd)
+	}
+
+	// The control buffer is aligned, so it may fit more fds than requested
+	if flags&syscall.MSG_CTRUNC != 0 || len(fds) > maxFDs {
+		closeFDs(fds)
+		return nil, nil, fmt.Errorf("failed to receive fds: more than %d fds sent", maxFDs)
+	}
+	if len(fds) == 0 {
+		return nil, nil, fmt.Errorf("failed to receive fds: no fds received")
+	}
+
+	return fds, payload[:n], nil
+}
+
+func closeFDs(fds []int) {
+	for _, fd := range fds {
+		_ = syscall.Close(fd)
+	}
+}
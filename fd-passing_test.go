@@ -0,0 +1,126 @@
+package libbpfgo
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unixSocketPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	t.Helper()
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	require.NoError(t, err)
+
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		require.NoError(t, err)
+		f.Close()
+		conns[i] = c.(*net.UnixConn)
+		t.Cleanup(func() { c.Close() })
+	}
+
+	return conns[0], conns[1]
+}
+
+func TestSendReceiveFDs(t *testing.T) {
+	sender, receiver := unixSocketPair(t)
+
+	var p [2]int
+	require.NoError(t, syscall.Pipe(p[:]))
+	defer closeFDs(p[:])
+
+	dup, err := DupFD(p[1])
+	require.NoError(t, err)
+	defer syscall.Close(dup)
+
+	require.NoError(t, SendFDs(sender, []int{p[0], dup}, []byte("pipe")))
+
+	fds, payload, err := ReceiveFDs(receiver, 2, 16)
+	require.NoError(t, err)
+	defer closeFDs(fds)
+
+	assert.Equal(t, "pipe", string(payload))
+	require.Len(t, fds, 2)
+
+	// Data written to the received write end is readable on the original
+	// read end, and vice versa.
+	_, err = syscall.Write(fds[1], []byte("x"))
+	require.NoError(t, err)
+	buf := make([]byte, 1)
+	_, err = syscall.Read(p[0], buf)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(buf))
+}
+
+func TestReceiveFDsTooMany(t *testing.T) {
+	sender, receiver := unixSocketPair(t)
+
+	var p [2]int
+	require.NoError(t, syscall.Pipe(p[:]))
+	defer closeFDs(p[:])
+
+	require.NoError(t, SendFDs(sender, p[:], nil))
+
+	_, _, err := ReceiveFDs(receiver, 1, 0)
+	assert.Error(t, err)
+}
+
+func TestCloseOnExec(t *testing.T) {
+	var p [2]int
+	require.NoError(t, syscall.Pipe2(p[:], syscall.O_CLOEXEC))
+	defer closeFDs(p[:])
+
+	cloexec, err := CloseOnExec(p[0])
+	require.NoError(t, err)
+	assert.True(t, cloexec)
+
+	require.NoError(t, SetCloseOnExec(p[0], false))
+	cloexec, err = CloseOnExec(p[0])
+	require.NoError(t, err)
+	assert.False(t, cloexec)
+
+	require.NoError(t, SetCloseOnExec(p[0], true))
+	cloexec, err = CloseOnExec(p[0])
+	require.NoError(t, err)
+	assert.True(t, cloexec)
+
+	_, err = CloseOnExec(-1)
+	assert.ErrorIs(t, err, syscall.EBADF)
+	assert.ErrorIs(t, SetCloseOnExec(-1, false), syscall.EBADF)
+}
+
+func TestDupFDTo(t *testing.T) {
+	var p [2]int
+	require.NoError(t, syscall.Pipe2(p[:], syscall.O_CLOEXEC))
+	defer closeFDs(p[:])
+
+	// A free fd number, far from the ones the runtime may use meanwhile
+	newFD := 900
+	defer syscall.Close(newFD)
+
+	for _, cloexec := range []bool{false, true} {
+		require.NoError(t, DupFDTo(p[1], newFD, cloexec))
+
+		got, err := CloseOnExec(newFD)
+		require.NoError(t, err)
+		assert.Equal(t, cloexec, got)
+
+		// Same pipe
+		_, err = syscall.Write(newFD, []byte{42})
+		require.NoError(t, err)
+		buf := make([]byte, 1)
+		_, err = syscall.Read(p[0], buf)
+		require.NoError(t, err)
+		assert.Equal(t, byte(42), buf[0])
+	}
+
+	assert.Error(t, DupFDTo(p[1], p[1], true))
+	assert.ErrorIs(t, DupFDTo(-1, newFD, true), syscall.EBADF)
+}
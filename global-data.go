@@ -0,0 +1,181 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Global data
+//
+// Once the object is loaded, the .data, .bss and .rodata maps are memory
+// mapped by libbpf if the kernel supports it (v5.5+). Their values can then
+// be read, and for .data and .bss written, without any syscall, and the
+// programs see the writes right away:
+//
+//	bool trace_enabled = true;
+//
+//	v, err := m.GlobalVariable("trace_enabled")
+//	err = v.Set(false)
+//
+// Writes are not atomic: variables larger than a machine word can be seen
+// half written by a running program.
+//
+
+// MmapedValue returns the memory mapped value of a global data map (.data,
+// .bss, .rodata) of a loaded object. The slice aliases the map memory, and
+// must not be used after the module is closed. .rodata values are read-only:
+// writing to them crashes the process.
+func (m *BPFMap) MmapedValue() ([]byte, error) {
+	if m.module == nil || !m.module.loaded {
+		return nil, errors.New("must be called after the BPF object is loaded")
+	}
+	if m.MapFlags()&C.BPF_F_MMAPABLE == 0 {
+		return nil, fmt.Errorf("failed to get mmaped value of map %s: map is not mmapable: %w", m.Name(), syscall.EOPNOTSUPP)
+	}
+
+	var sizeC C.size_t
+	dataC := C.bpf_map__initial_value(m.bpfMap, &sizeC)
+	if dataC == nil {
+		return nil, fmt.Errorf("failed to get mmaped value of map %s: %w", m.Name(), syscall.ENOENT)
+	}
+
+	return unsafe.Slice((*byte)(dataC), int(sizeC)), nil
+}
+
+// GlobalVariable is a global variable of a loaded object, accessed through
+// the memory mapped value of its global data map.
+type GlobalVariable struct {
+	name     string
+	section  string
+	v        *rodataVar
+	data     []byte
+	readOnly bool
+}
+
+// isGlobalDataSection reports whether the section is a global data section,
+// and whether it is read-only.
+func isGlobalDataSection(name string) (ok bool, readOnly bool) {
+	for _, prefix := range []string{".data", ".bss"} {
+		if name == prefix || strings.HasPrefix(name, prefix+".") {
+			return true, false
+		}
+	}
+	if name == ".rodata" || strings.HasPrefix(name, ".rodata.") {
+		return true, true
+	}
+
+	return false, false
+}
+
+// GlobalVariable returns the global variable with the given name, declared
+// in .data, .bss or .rodata (read-only). It must be called after the BPF
+// object is loaded.
+func (m *Module) GlobalVariable(name string) (*GlobalVariable, error) {
+	if !m.loaded {
+		return nil, errors.New("must be called after the BPF object is loaded")
+	}
+
+	btf := C.bpf_object__btf(m.obj)
+	if btf == nil {
+		return nil, fmt.Errorf("failed to find global variable %s: object has no BTF", name)
+	}
+
+	for id := C.__u32(1); id < C.btf__type_cnt(btf); id++ {
+		if C.cgo_btf_type_kind(btf, id) != C.BTF_KIND_DATASEC {
+			continue
+		}
+		section := C.GoString(C.cgo_btf_type_name(btf, id))
+		ok, readOnly := isGlobalDataSection(section)
+		if !ok {
+			continue
+		}
+
+		v, offset, err := findRodataVar(btf, section, name)
+		if err != nil {
+			continue
+		}
+
+		bpfMap, err := m.GetMap(section)
+		if err != nil {
+			return nil, err
+		}
+		value, err := bpfMap.MmapedValue()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get global variable %s: %w", name, err)
+		}
+		if offset+v.size > len(value) {
+			return nil, fmt.Errorf("failed to get global variable %s: offset %d out of %s", name, offset, section)
+		}
+
+		return &GlobalVariable{
+			name:     name,
+			section:  section,
+			v:        v,
+			data:     value[offset : offset+v.size : offset+v.size],
+			readOnly: readOnly,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("failed to find global variable %s: %w", name, syscall.ENOENT)
+}
+
+// Name returns the name of the variable.
+func (g *GlobalVariable) Name() string {
+	return g.name
+}
+
+// Section returns the data section of the variable.
+func (g *GlobalVariable) Section() string {
+	return g.section
+}
+
+// Size returns the size in bytes of the variable.
+func (g *GlobalVariable) Size() int {
+	return len(g.data)
+}
+
+// ReadOnly reports whether the variable is in .rodata.
+func (g *GlobalVariable) ReadOnly() bool {
+	return g.readOnly
+}
+
+// Get decodes the current value of the variable into value, a pointer to a
+// fixed size type (see encoding/binary).
+func (g *GlobalVariable) Get(value interface{}) error {
+	if err := binary.Read(bytes.NewReader(g.data), binary.NativeEndian, value); err != nil {
+		return fmt.Errorf("failed to decode global variable %s: %w", g.name, err)
+	}
+
+	return nil
+}
+
+// Set writes the value to the variable. The value must match the variable
+// type, like with Module.SetRodataVariable().
+func (g *GlobalVariable) Set(value interface{}) error {
+	if g.readOnly {
+		return fmt.Errorf("failed to set global variable %s: variable is in %s: %w", g.name, g.section, syscall.EPERM)
+	}
+	if err := g.v.check(value); err != nil {
+		return fmt.Errorf("failed to set global variable %s: %w", g.name, err)
+	}
+
+	data := bytes.NewBuffer(make([]byte, 0, len(g.data)))
+	if err := binary.Write(data, binary.NativeEndian, value); err != nil {
+		return fmt.Errorf("failed to encode global variable %s: %w", g.name, err)
+	}
+	copy(g.data, data.Bytes())
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsGlobalDataSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		ok       bool
+		readOnly bool
+	}{
+		{".data", true, false},
+		{".data.qux", true, false},
+		{".bss", true, false},
+		{".rodata", true, true},
+		{".rodata.baz", true, true},
+		{".kconfig", false, false},
+		{".database", false, false},
+		{".maps", false, false},
+	}
+
+	for _, tt := range tests {
+		ok, readOnly := isGlobalDataSection(tt.name)
+		assert.Equal(t, tt.ok, ok, tt.name)
+		assert.Equal(t, tt.readOnly, readOnly, tt.name)
+	}
+}
@@ -1 +1,813 @@
 package helpers
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//
+// BTFHub
+//
+// Kernels built without BTF (CONFIG_DEBUG_INFO_BTF) need a BTF file, such as
+// the ones of BTFHub, for libbpf to relocate the CO-RE objects (see
+// libbpfgo.NewModuleArgs.BTFObjPath). A kernel BTF is several MB, so agents
+// supporting many kernels ship minimized BTF files instead, holding only the
+// types and members the CO-RE relocations of their objects use, as generated
+// by "bpftool gen min_core_btf". GenerateMinCoreBTF() does the same:
+//
+//	err := helpers.GenerateMinCoreBTF(
+//		"4.18.0-305.el8.x86_64.btf", "min/4.18.0-305.el8.x86_64.btf",
+//		"agent.bpf.o", "probes.bpf.o",
+//	)
+//
+// Structs and unions keep their size and only the used members, at their
+// offsets. Pointers to types that are not used become void pointers.
+//
+
+// GenerateMinCoreBTF writes to outputPath the BTF of inputPath (raw BTF, or
+// the ELF file of a kernel) reduced to the types and members used by the
+// CO-RE relocations of the BPF objects of objPaths.
+func GenerateMinCoreBTF(inputPath, outputPath string, objPaths ...string) error {
+	input, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("could not read BTF file: %w", err)
+	}
+
+	objs := make([][]byte, 0, len(objPaths))
+	for _, path := range objPaths {
+		obj, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read BPF object: %w", err)
+		}
+		objs = append(objs, obj)
+	}
+
+	output, err := MinCoreBTF(input, objs...)
+	if err != nil {
+		return fmt.Errorf("could not generate BTF of %s: %w", inputPath, err)
+	}
+
+	if err := os.WriteFile(outputPath, output, 0o644); err != nil {
+		return fmt.Errorf("could not write BTF file: %w", err)
+	}
+
+	return nil
+}
+
+// MinCoreBTF returns the BTF (raw BTF, or the ELF file of a kernel) reduced to
+// the types and members used by the CO-RE relocations of the BPF objects, as
+// raw BTF.
+func MinCoreBTF(btf []byte, objs ...[]byte) ([]byte, error) {
+	target, err := loadBTF(btf)
+	if err != nil {
+		return nil, err
+	}
+
+	gen := newBTFGen(target)
+	for i, obj := range objs {
+		local, relos, err := objectCoreRelos(obj)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", i, err)
+		}
+		for _, relo := range relos {
+			if err := gen.record(local, relo); err != nil {
+				return nil, fmt.Errorf("object %d: %w", i, err)
+			}
+		}
+	}
+
+	return gen.encode(), nil
+}
+
+//
+// BTF types
+//
+
+// btfTypeData is a BTF type and the 32-bit words following it.
+type btfTypeData struct {
+	btfType
+	extra []uint32
+}
+
+func (t *btfTypeData) kind() uint32 {
+	return btfKind(t.btfType)
+}
+
+func (t *btfTypeData) vlen() int {
+	return int(t.Info & 0xffff)
+}
+
+func (t *btfTypeData) composite() bool {
+	return t.kind() == btfKindStruct || t.kind() == btfKindUnion
+}
+
+// btfMember is a member of a struct or union.
+type btfMember struct {
+	nameOff uint32
+	typ     uint32
+}
+
+func (t *btfTypeData) member(idx int) btfMember {
+	return btfMember{nameOff: t.extra[3*idx], typ: t.extra[3*idx+1]}
+}
+
+// enumerator returns the name offset of an enumerator of an enum.
+func (t *btfTypeData) enumerator(idx int) uint32 {
+	if t.kind() == btfKindEnum64 {
+		return t.extra[3*idx]
+	}
+
+	return t.extra[2*idx]
+}
+
+// btfTypes holds all the types of a BTF.
+type btfTypes struct {
+	order binary.ByteOrder
+	strs  []byte
+	types []btfTypeData // by ID, 0 is void
+}
+
+// btfSection returns the .BTF section of an ELF file, or the data as is.
+func btfSection(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(elf.ELFMAG)) {
+		return data, nil
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse ELF file: %w", err)
+	}
+	defer f.Close()
+
+	sec := f.Section(".BTF")
+	if sec == nil {
+		return nil, errors.New("ELF file has no BTF")
+	}
+
+	return sec.Data()
+}
+
+// loadBTF parses the BTF, raw or of an ELF file.
+func loadBTF(data []byte) (*btfTypes, error) {
+	data, err := btfSection(data)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &btfTypes{types: []btfTypeData{{}}}
+	spec, err := parseBTF(data, nil, func(spec *btfSpec, id uint32, t btfType, extra []byte) error {
+		words := make([]uint32, len(extra)/4)
+		for i := range words {
+			words[i] = spec.order.Uint32(extra[4*i:])
+		}
+		b.types = append(b.types, btfTypeData{btfType: t, extra: words})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.order = spec.order
+	b.strs = spec.strs
+
+	for i := range b.types {
+		if err := b.check(&b.types[i]); err != nil {
+			return nil, fmt.Errorf("invalid BTF type %d: %w", i, err)
+		}
+	}
+
+	return b, nil
+}
+
+// check checks that the type references valid types and strings.
+func (b *btfTypes) check(t *btfTypeData) error {
+	ids, strs := btfReferences(t)
+	for _, i := range ids {
+		if *i >= uint32(len(b.types)) {
+			return fmt.Errorf("invalid type ID %d", *i)
+		}
+	}
+	for _, off := range strs {
+		if _, err := btfString(b.strs, *off); err != nil && *off != 0 {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// btfReferences returns the words of the type holding type IDs, and string
+// offsets.
+func btfReferences(t *btfTypeData) (ids, strs []*uint32) {
+	strs = append(strs, &t.NameOff)
+
+	switch t.kind() {
+	case btfKindPtr, btfKindTypedef, btfKindVolatile, btfKindConst, btfKindRestrict,
+		btfKindFunc, btfKindVar, btfKindDeclTag, btfKindTypeTag:
+		ids = append(ids, &t.SizeTyp)
+	case btfKindArray:
+		ids = append(ids, &t.extra[0], &t.extra[1])
+	case btfKindStruct, btfKindUnion:
+		for i := 0; i < t.vlen(); i++ {
+			strs = append(strs, &t.extra[3*i])
+			ids = append(ids, &t.extra[3*i+1])
+		}
+	case btfKindEnum:
+		for i := 0; i < t.vlen(); i++ {
+			strs = append(strs, &t.extra[2*i])
+		}
+	case btfKindEnum64:
+		for i := 0; i < t.vlen(); i++ {
+			strs = append(strs, &t.extra[3*i])
+		}
+	case btfKindFuncProto:
+		ids = append(ids, &t.SizeTyp)
+		for i := 0; i < t.vlen(); i++ {
+			strs = append(strs, &t.extra[2*i])
+			ids = append(ids, &t.extra[2*i+1])
+		}
+	case btfKindDatasec:
+		for i := 0; i < t.vlen(); i++ {
+			ids = append(ids, &t.extra[3*i])
+		}
+	}
+
+	return ids, strs
+}
+
+// typ returns the type of the ID, nil for void or an invalid ID.
+func (b *btfTypes) typ(id uint32) *btfTypeData {
+	if id == 0 || id >= uint32(len(b.types)) {
+		return nil
+	}
+
+	return &b.types[id]
+}
+
+func (b *btfTypes) name(off uint32) string {
+	name, _ := btfString(b.strs, off)
+
+	return name
+}
+
+// btfMaxDepth bounds the types followed, against malformed BTF.
+const btfMaxDepth = 32
+
+// skipModsTypedefs returns the type referenced by the modifiers and typedefs
+// of the ID.
+func (b *btfTypes) skipModsTypedefs(id uint32) uint32 {
+	for depth := 0; depth < btfMaxDepth; depth++ {
+		t := b.typ(id)
+		if t == nil {
+			return id
+		}
+		switch t.kind() {
+		case btfKindTypedef, btfKindVolatile, btfKindConst, btfKindRestrict, btfKindTypeTag:
+			id = t.SizeTyp
+		default:
+			return id
+		}
+	}
+
+	return id
+}
+
+// btfEssentialName returns the name without its flavor (task_struct___old).
+func btfEssentialName(name string) string {
+	if i := strings.LastIndex(name, "___"); i > 0 {
+		return name[:i]
+	}
+
+	return name
+}
+
+func btfKindsCompat(a, b uint32) bool {
+	isEnum := func(k uint32) bool { return k == btfKindEnum || k == btfKindEnum64 }
+
+	return a == b || (isEnum(a) && isEnum(b))
+}
+
+//
+// CO-RE relocations
+//
+
+// CO-RE relocation kinds (enum bpf_core_relo_kind).
+const (
+	btfCoreFieldByteOffset = iota
+	btfCoreFieldByteSize
+	btfCoreFieldExists
+	btfCoreFieldSigned
+	btfCoreFieldLShiftU64
+	btfCoreFieldRShiftU64
+	btfCoreTypeIDLocal
+	btfCoreTypeIDTarget
+	btfCoreTypeExists
+	btfCoreTypeSize
+	btfCoreEnumvalExists
+	btfCoreEnumvalValue
+	btfCoreTypeMatches
+)
+
+type btfExtHeader struct {
+	Magic       uint16
+	Version     uint8
+	Flags       uint8
+	HdrLen      uint32
+	FuncInfoOff uint32
+	FuncInfoLen uint32
+	LineInfoOff uint32
+	LineInfoLen uint32
+	CoreReloOff uint32
+	CoreReloLen uint32
+}
+
+// btfCoreRelo is a CO-RE relocation of an object.
+type btfCoreRelo struct {
+	typeID uint32 // root type, in the BTF of the object
+	access []int  // accessors ("0:1:2")
+	kind   uint32
+}
+
+// objectCoreRelos returns the BTF and the CO-RE relocations of a BPF object,
+// from its .BTF.ext section.
+func objectCoreRelos(obj []byte) (*btfTypes, []btfCoreRelo, error) {
+	f, err := elf.NewFile(bytes.NewReader(obj))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse BPF object: %w", err)
+	}
+	defer f.Close()
+
+	btfSec := f.Section(".BTF")
+	if btfSec == nil {
+		return nil, nil, errors.New("BPF object has no BTF")
+	}
+	data, err := btfSec.Data()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read BTF: %w", err)
+	}
+	local, err := loadBTF(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extSec := f.Section(".BTF.ext")
+	if extSec == nil {
+		return local, nil, nil // no relocations
+	}
+	ext, err := extSec.Data()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read BTF.ext: %w", err)
+	}
+
+	relos, err := parseCoreRelos(ext, local)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return local, relos, nil
+}
+
+// parseCoreRelos parses the CO-RE relocations of the .BTF.ext section, whose
+// strings are the ones of the BTF.
+func parseCoreRelos(ext []byte, local *btfTypes) ([]btfCoreRelo, error) {
+	var hdr btfExtHeader
+	if err := binary.Read(bytes.NewReader(ext), local.order, &hdr); err != nil {
+		return nil, fmt.Errorf("could not read BTF.ext header: %w", err)
+	}
+	if hdr.Magic != btfMagic {
+		return nil, fmt.Errorf("invalid BTF.ext magic: %#x", hdr.Magic)
+	}
+	if hdr.HdrLen < uint32(binary.Size(hdr)) || hdr.CoreReloLen == 0 {
+		return nil, nil // no relocations
+	}
+
+	start := uint64(hdr.HdrLen) + uint64(hdr.CoreReloOff)
+	end := start + uint64(hdr.CoreReloLen)
+	if end > uint64(len(ext)) || hdr.CoreReloLen < 4 {
+		return nil, errors.New("invalid BTF.ext header: relocations out of bounds")
+	}
+	data := ext[start:end]
+
+	order := local.order
+	recSize := int(order.Uint32(data))
+	if recSize < 16 {
+		return nil, fmt.Errorf("invalid CO-RE relocation size: %d", recSize)
+	}
+
+	var relos []btfCoreRelo
+	for off := 4; off < len(data); {
+		// struct btf_ext_info_sec
+		if len(data)-off < 8 {
+			return nil, errors.New("truncated CO-RE relocations")
+		}
+		numInfo := int(order.Uint32(data[off+4:]))
+		off += 8
+		if numInfo > (len(data)-off)/recSize {
+			return nil, errors.New("truncated CO-RE relocations")
+		}
+
+		// struct bpf_core_relo
+		for i := 0; i < numInfo; i++ {
+			rec := data[off : off+recSize]
+			off += recSize
+
+			accessStr, err := btfString(local.strs, order.Uint32(rec[8:]))
+			if err != nil {
+				return nil, err
+			}
+			access, err := parseCoreAccess(accessStr)
+			if err != nil {
+				return nil, err
+			}
+			relos = append(relos, btfCoreRelo{
+				typeID: order.Uint32(rec[4:]),
+				access: access,
+				kind:   order.Uint32(rec[12:]),
+			})
+		}
+	}
+
+	return relos, nil
+}
+
+// parseCoreAccess parses the accessors of a CO-RE relocation ("0:1:2").
+func parseCoreAccess(str string) ([]int, error) {
+	var access []int
+	for _, part := range strings.Split(str, ":") {
+		idx, err := strconv.Atoi(part)
+		if err != nil || idx < 0 {
+			return nil, fmt.Errorf("invalid CO-RE access string: %q", str)
+		}
+		access = append(access, idx)
+	}
+
+	return access, nil
+}
+
+//
+// BTF generation
+//
+
+// btfGen records the types and members of a target BTF used by CO-RE
+// relocations, as bpftool gen min_core_btf does.
+type btfGen struct {
+	target  *btfTypes
+	cands   map[string][]uint32 // by essential name
+	types   map[uint32]bool
+	members map[uint32]map[int]bool // of the structs and unions
+}
+
+func newBTFGen(target *btfTypes) *btfGen {
+	g := &btfGen{
+		target:  target,
+		cands:   make(map[string][]uint32),
+		types:   make(map[uint32]bool),
+		members: make(map[uint32]map[int]bool),
+	}
+	for id := 1; id < len(target.types); id++ {
+		if name := target.name(target.types[id].NameOff); name != "" {
+			essential := btfEssentialName(name)
+			g.cands[essential] = append(g.cands[essential], uint32(id))
+		}
+	}
+
+	return g
+}
+
+// candidates returns the target types matching the local type by essential
+// name and kind.
+func (g *btfGen) candidates(local *btfTypes, id uint32) []uint32 {
+	t := local.typ(id)
+	if t == nil {
+		return nil
+	}
+	name := local.name(t.NameOff)
+	if name == "" {
+		return nil
+	}
+
+	var cands []uint32
+	for _, cand := range g.cands[btfEssentialName(name)] {
+		if btfKindsCompat(t.kind(), g.target.typ(cand).kind()) {
+			cands = append(cands, cand)
+		}
+	}
+
+	return cands
+}
+
+// record marks the target types and members used by the relocation.
+func (g *btfGen) record(local *btfTypes, relo btfCoreRelo) error {
+	if local.typ(relo.typeID) == nil {
+		return fmt.Errorf("invalid CO-RE relocation type ID %d", relo.typeID)
+	}
+
+	switch relo.kind {
+	case btfCoreFieldByteOffset, btfCoreFieldByteSize, btfCoreFieldExists,
+		btfCoreFieldSigned, btfCoreFieldLShiftU64, btfCoreFieldRShiftU64:
+		return g.recordField(local, relo)
+	case btfCoreTypeIDLocal:
+		return nil
+	case btfCoreTypeIDTarget, btfCoreTypeExists, btfCoreTypeSize:
+		if cands := g.candidates(local, relo.typeID); len(cands) > 0 {
+			g.markType(cands[0], true, make(map[uint32]bool))
+		}
+		return nil
+	case btfCoreTypeMatches:
+		if cands := g.candidates(local, relo.typeID); len(cands) > 0 {
+			g.markTypeMatch(cands[0], false, make(map[btfVisit]bool))
+		}
+		return nil
+	case btfCoreEnumvalExists, btfCoreEnumvalValue:
+		return g.recordEnumval(local, relo)
+	}
+
+	return fmt.Errorf("unknown CO-RE relocation kind %d", relo.kind)
+}
+
+// btfAccess is an access of a field relocation: a named member, or an array
+// element.
+type btfAccess struct {
+	name  string
+	array bool
+}
+
+// btfUsedMember is a member of a target struct or union used by a relocation.
+type btfUsedMember struct {
+	id  uint32 // struct or union
+	idx int
+	typ uint32
+}
+
+func (g *btfGen) recordField(local *btfTypes, relo btfCoreRelo) error {
+	// The path of the access in the local type, by member names: the
+	// anonymous members are matched by the names of their own members
+	var path []btfAccess
+	id := relo.typeID
+	for _, idx := range relo.access[1:] {
+		t := local.typ(local.skipModsTypedefs(id))
+		switch {
+		case t != nil && t.composite() && idx < t.vlen():
+			m := t.member(idx)
+			if name := local.name(m.nameOff); name != "" {
+				path = append(path, btfAccess{name: name})
+			}
+			id = m.typ
+		case t != nil && t.kind() == btfKindArray:
+			path = append(path, btfAccess{array: true})
+			id = t.extra[0]
+		default:
+			return fmt.Errorf("invalid CO-RE relocation access %v of type ID %d", relo.access, relo.typeID)
+		}
+	}
+
+	for _, cand := range g.candidates(local, relo.typeID) {
+		used, ok := g.matchField(cand, path)
+		if !ok {
+			continue
+		}
+		g.types[cand] = true
+		for _, m := range used {
+			g.types[m.id] = true
+			if g.members[m.id] == nil {
+				g.members[m.id] = make(map[int]bool)
+			}
+			g.members[m.id][m.idx] = true
+			g.markType(m.typ, false, make(map[uint32]bool))
+		}
+
+		return nil
+	}
+
+	return nil // the field is not in the target
+}
+
+// matchField returns the target members along the path, from the root type.
+func (g *btfGen) matchField(root uint32, path []btfAccess) ([]btfUsedMember, bool) {
+	var used []btfUsedMember
+	id := root
+	for _, access := range path {
+		id = g.target.skipModsTypedefs(id)
+		t := g.target.typ(id)
+		if t == nil {
+			return nil, false
+		}
+
+		if access.array {
+			if t.kind() != btfKindArray {
+				return nil, false
+			}
+			id = t.extra[0]
+			continue
+		}
+		if !t.composite() {
+			return nil, false
+		}
+
+		chain := g.findMember(id, access.name, 0)
+		if chain == nil {
+			return nil, false
+		}
+		used = append(used, chain...)
+		id = chain[len(chain)-1].typ
+	}
+
+	return used, true
+}
+
+// findMember returns the member of the struct or union by name, searched in
+// its anonymous members too, preceded by them.
+func (g *btfGen) findMember(id uint32, name string, depth int) []btfUsedMember {
+	t := g.target.typ(id)
+	if t == nil || !t.composite() || depth >= btfMaxDepth {
+		return nil
+	}
+
+	for i := 0; i < t.vlen(); i++ {
+		m := t.member(i)
+		used := btfUsedMember{id: id, idx: i, typ: m.typ}
+
+		switch g.target.name(m.nameOff) {
+		case name:
+			return []btfUsedMember{used}
+		case "":
+			if chain := g.findMember(g.target.skipModsTypedefs(m.typ), name, depth+1); chain != nil {
+				return append([]btfUsedMember{used}, chain...)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g *btfGen) recordEnumval(local *btfTypes, relo btfCoreRelo) error {
+	t := local.typ(local.skipModsTypedefs(relo.typeID))
+	if t == nil || (t.kind() != btfKindEnum && t.kind() != btfKindEnum64) || relo.access[0] >= t.vlen() {
+		return fmt.Errorf("invalid CO-RE relocation access %v of type ID %d", relo.access, relo.typeID)
+	}
+	name := local.name(t.enumerator(relo.access[0]))
+
+	for _, cand := range g.candidates(local, relo.typeID) {
+		ct := g.target.typ(g.target.skipModsTypedefs(cand))
+		if ct == nil || (ct.kind() != btfKindEnum && ct.kind() != btfKindEnum64) {
+			continue
+		}
+		for i := 0; i < ct.vlen(); i++ {
+			if g.target.name(ct.enumerator(i)) == name {
+				g.markType(cand, false, make(map[uint32]bool))
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// markType marks the type and the types it references, but the members of
+// structs and unions, and the types pointed to unless followPointers.
+func (g *btfGen) markType(id uint32, followPointers bool, seen map[uint32]bool) {
+	t := g.target.typ(id)
+	if t == nil || seen[id] {
+		return
+	}
+	seen[id] = true
+	g.types[id] = true
+
+	switch t.kind() {
+	case btfKindPtr:
+		if followPointers {
+			g.markType(t.SizeTyp, followPointers, seen)
+		}
+	case btfKindTypedef, btfKindVolatile, btfKindConst, btfKindRestrict, btfKindTypeTag, btfKindFunc:
+		g.markType(t.SizeTyp, followPointers, seen)
+	case btfKindArray:
+		g.markType(t.extra[0], followPointers, seen)
+		g.markType(t.extra[1], followPointers, seen)
+	case btfKindFuncProto:
+		g.markType(t.SizeTyp, followPointers, seen)
+		for i := 0; i < t.vlen(); i++ {
+			g.markType(t.extra[2*i+1], followPointers, seen)
+		}
+	}
+}
+
+type btfVisit struct {
+	id        uint32
+	behindPtr bool
+}
+
+// markTypeMatch marks the type and all the types it references, with all
+// their members, but the members of the structs and unions pointed to, for a
+// type match relocation.
+func (g *btfGen) markTypeMatch(id uint32, behindPtr bool, seen map[btfVisit]bool) {
+	t := g.target.typ(id)
+	if t == nil || seen[btfVisit{id, behindPtr}] {
+		return
+	}
+	seen[btfVisit{id, behindPtr}] = true
+	g.types[id] = true
+
+	switch t.kind() {
+	case btfKindStruct, btfKindUnion:
+		if behindPtr {
+			return
+		}
+		if g.members[id] == nil {
+			g.members[id] = make(map[int]bool)
+		}
+		for i := 0; i < t.vlen(); i++ {
+			g.members[id][i] = true
+			g.markTypeMatch(t.member(i).typ, false, seen)
+		}
+	case btfKindPtr:
+		g.markTypeMatch(t.SizeTyp, true, seen)
+	case btfKindTypedef, btfKindVolatile, btfKindConst, btfKindRestrict, btfKindTypeTag, btfKindFunc:
+		g.markTypeMatch(t.SizeTyp, behindPtr, seen)
+	case btfKindArray:
+		g.markTypeMatch(t.extra[0], behindPtr, seen)
+		g.markTypeMatch(t.extra[1], behindPtr, seen)
+	case btfKindFuncProto:
+		g.markTypeMatch(t.SizeTyp, behindPtr, seen)
+		for i := 0; i < t.vlen(); i++ {
+			g.markTypeMatch(t.extra[2*i+1], behindPtr, seen)
+		}
+	}
+}
+
+// encode returns the raw BTF of the marked types and members. The references
+// to the types that are not marked become references to void.
+func (g *btfGen) encode() []byte {
+	ids := make(map[uint32]uint32) // target ID -> new ID
+	var kept []uint32
+	for id := uint32(1); id < uint32(len(g.target.types)); id++ {
+		if g.types[id] {
+			kept = append(kept, id)
+			ids[id] = uint32(len(kept))
+		}
+	}
+
+	strs := []byte{0}
+	strOffs := map[string]uint32{"": 0}
+	addStr := func(off uint32) uint32 {
+		name := g.target.name(off)
+		if newOff, ok := strOffs[name]; ok {
+			return newOff
+		}
+		newOff := uint32(len(strs))
+		strs = append(append(strs, name...), 0)
+		strOffs[name] = newOff
+
+		return newOff
+	}
+
+	order := g.target.order
+	var types bytes.Buffer
+	for _, id := range kept {
+		t := g.target.types[id]
+		t.extra = append([]uint32(nil), t.extra...)
+
+		if t.composite() {
+			var extra []uint32
+			for i := 0; i < t.vlen(); i++ {
+				if g.members[id][i] {
+					extra = append(extra, t.extra[3*i:3*i+3]...)
+				}
+			}
+			t.extra = extra
+			t.Info = t.Info&^0xffff | uint32(len(extra)/3)
+		}
+
+		refs, names := btfReferences(&t)
+		for _, ref := range refs {
+			*ref = ids[*ref]
+		}
+		for _, name := range names {
+			*name = addStr(*name)
+		}
+
+		_ = binary.Write(&types, order, t.btfType)
+		_ = binary.Write(&types, order, t.extra)
+	}
+
+	hdr := btfHeader{
+		Magic:   btfMagic,
+		Version: 1,
+		HdrLen:  uint32(binary.Size(btfHeader{})),
+		TypeLen: uint32(types.Len()),
+		StrOff:  uint32(types.Len()),
+		StrLen:  uint32(len(strs)),
+	}
+
+	var out bytes.Buffer
+	_ = binary.Write(&out, order, hdr)
+	out.Write(types.Bytes())
+	out.Write(strs)
+
+	return out.Bytes()
+}
@@ -0,0 +1,243 @@
+package helpers
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestELF builds a relocatable ELF file with the sections, by name.
+func newTestELF(t *testing.T, names []string, sections [][]byte) []byte {
+	t.Helper()
+
+	shstrtab := []byte{0}
+	nameOffs := make([]uint32, len(names))
+	for i, name := range names {
+		nameOffs[i] = uint32(len(shstrtab))
+		shstrtab = append(append(shstrtab, name...), 0)
+	}
+	shstrtabName := uint32(len(shstrtab))
+	shstrtab = append(shstrtab, ".shstrtab\x00"...)
+
+	var data bytes.Buffer
+	hdrSize := binary.Size(elf.Header64{})
+	headers := []elf.Section64{{}}
+	for i, sec := range sections {
+		headers = append(headers, elf.Section64{
+			Name:      nameOffs[i],
+			Type:      uint32(elf.SHT_PROGBITS),
+			Off:       uint64(hdrSize + data.Len()),
+			Size:      uint64(len(sec)),
+			Addralign: 4,
+		})
+		data.Write(sec)
+	}
+	headers = append(headers, elf.Section64{
+		Name:      shstrtabName,
+		Type:      uint32(elf.SHT_STRTAB),
+		Off:       uint64(hdrSize + data.Len()),
+		Size:      uint64(len(shstrtab)),
+		Addralign: 1,
+	})
+	data.Write(shstrtab)
+
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_BPF),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(hdrSize + data.Len()),
+		Ehsize:    uint16(hdrSize),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     uint16(len(headers)),
+		Shstrndx:  uint16(len(headers) - 1),
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var obj bytes.Buffer
+	require.NoError(t, binary.Write(&obj, binary.LittleEndian, hdr))
+	obj.Write(data.Bytes())
+	require.NoError(t, binary.Write(&obj, binary.LittleEndian, headers))
+
+	return obj.Bytes()
+}
+
+// newTestBTFExt encodes a .BTF.ext section with the CO-RE relocations of a
+// section, each a [4]uint32 bpf_core_relo.
+func newTestBTFExt(t *testing.T, relos ...[4]uint32) []byte {
+	t.Helper()
+
+	var data bytes.Buffer
+	write := func(v interface{}) { require.NoError(t, binary.Write(&data, binary.LittleEndian, v)) }
+	write(uint32(16))         // record size
+	write(uint32(0))          // section name
+	write(uint32(len(relos))) // records
+	for _, relo := range relos {
+		write(relo)
+	}
+
+	hdr := btfExtHeader{
+		Magic:       btfMagic,
+		Version:     1,
+		HdrLen:      uint32(binary.Size(btfExtHeader{})),
+		CoreReloLen: uint32(data.Len()),
+	}
+
+	var ext bytes.Buffer
+	require.NoError(t, binary.Write(&ext, binary.LittleEndian, hdr))
+	ext.Write(data.Bytes())
+
+	return ext.Bytes()
+}
+
+func TestMinCoreBTF(t *testing.T) {
+	info := func(kind, vlen uint32) uint32 { return kind<<24 | vlen }
+
+	// Kernel BTF
+	strs := "\x00int\x00task_struct\x00pid\x00tgid\x00mm\x00mm_struct\x00users\x00state\x00RUNNING\x00STOPPED\x00pid_t\x00sig\x00a\x00b\x00"
+	off := func(name string) uint32 { return uint32(bytes.Index([]byte(strs), []byte("\x00"+name+"\x00")) + 1) }
+	kernel := newTestBTF(t, strs,
+		// 1 int
+		btfType{NameOff: off("int"), Info: info(btfKindInt, 0), SizeTyp: 4}, uint32(32),
+		// 2 struct task_struct { int pid; int tgid; struct mm_struct *mm; }
+		btfType{NameOff: off("task_struct"), Info: info(btfKindStruct, 3), SizeTyp: 16},
+		[3]uint32{off("pid"), 1, 0}, [3]uint32{off("tgid"), 1, 32}, [3]uint32{off("mm"), 3, 64},
+		// 3 struct mm_struct *
+		btfType{Info: info(btfKindPtr, 0), SizeTyp: 4},
+		// 4 struct mm_struct { int users; }
+		btfType{NameOff: off("mm_struct"), Info: info(btfKindStruct, 1), SizeTyp: 4},
+		[3]uint32{off("users"), 1, 0},
+		// 5 enum state { RUNNING, STOPPED }
+		btfType{NameOff: off("state"), Info: info(btfKindEnum, 2), SizeTyp: 4},
+		[2]uint32{off("RUNNING"), 0}, [2]uint32{off("STOPPED"), 1},
+		// 6 typedef int pid_t
+		btfType{NameOff: off("pid_t"), Info: info(btfKindTypedef, 0), SizeTyp: 1},
+		// 7 struct sig { union { int a; int b; }; }
+		btfType{NameOff: off("sig"), Info: info(btfKindStruct, 1), SizeTyp: 4},
+		[3]uint32{0, 8, 0},
+		// 8 union { int a; int b; }
+		btfType{Info: info(btfKindUnion, 2), SizeTyp: 4},
+		[3]uint32{off("a"), 1, 0}, [3]uint32{off("b"), 1, 0},
+	)
+
+	// Object BTF
+	localStrs := "\x00int\x00task_struct___old\x00tgid\x00state\x00STOPPED\x00pid_t\x00sig\x00b\x000:0\x000\x00"
+	localOff := func(name string) uint32 {
+		return uint32(bytes.Index([]byte(localStrs), []byte("\x00"+name+"\x00")) + 1)
+	}
+	localBTF := newTestBTF(t, localStrs,
+		// 1 int
+		btfType{NameOff: localOff("int"), Info: info(btfKindInt, 0), SizeTyp: 4}, uint32(32),
+		// 2 struct task_struct___old { int tgid; }
+		btfType{NameOff: localOff("task_struct___old"), Info: info(btfKindStruct, 1), SizeTyp: 4},
+		[3]uint32{localOff("tgid"), 1, 0},
+		// 3 enum state { STOPPED = 1 }
+		btfType{NameOff: localOff("state"), Info: info(btfKindEnum, 1), SizeTyp: 4},
+		[2]uint32{localOff("STOPPED"), 1},
+		// 4 typedef int pid_t
+		btfType{NameOff: localOff("pid_t"), Info: info(btfKindTypedef, 0), SizeTyp: 1},
+		// 5 struct sig { int b; }
+		btfType{NameOff: localOff("sig"), Info: info(btfKindStruct, 1), SizeTyp: 4},
+		[3]uint32{localOff("b"), 1, 0},
+	)
+	ext := newTestBTFExt(t,
+		[4]uint32{0, 2, localOff("0:0"), btfCoreFieldByteOffset}, // task->tgid
+		[4]uint32{8, 3, localOff("0"), btfCoreEnumvalExists},     // STOPPED
+		[4]uint32{16, 4, localOff("0"), btfCoreTypeExists},       // pid_t
+		[4]uint32{24, 5, localOff("0:0"), btfCoreFieldExists},    // sig->b
+		[4]uint32{32, 1, localOff("0"), btfCoreTypeIDLocal},      // local only
+	)
+	obj := newTestELF(t, []string{".BTF", ".BTF.ext"}, [][]byte{localBTF, ext})
+
+	dir := t.TempDir()
+	kernelPath := filepath.Join(dir, "kernel.btf")
+	objPath := filepath.Join(dir, "prog.bpf.o")
+	minPath := filepath.Join(dir, "min.btf")
+	require.NoError(t, os.WriteFile(kernelPath, kernel, 0o644))
+	require.NoError(t, os.WriteFile(objPath, obj, 0o644))
+	require.NoError(t, GenerateMinCoreBTF(kernelPath, minPath, objPath))
+
+	data, err := os.ReadFile(minPath)
+	require.NoError(t, err)
+	assert.Less(t, len(data), len(kernel))
+
+	reduced, err := loadBTF(data)
+	require.NoError(t, err)
+
+	type member struct {
+		name string
+		typ  string
+	}
+	type typ struct {
+		kind    uint32
+		name    string
+		size    uint32
+		members []member
+	}
+	typeName := func(id uint32) string {
+		if t := reduced.typ(id); t != nil {
+			return reduced.name(t.NameOff)
+		}
+		return "void"
+	}
+	var got []typ
+	for id := 1; id < len(reduced.types); id++ {
+		tt := &reduced.types[id]
+		x := typ{kind: tt.kind(), name: reduced.name(tt.NameOff), size: tt.SizeTyp}
+		if tt.composite() {
+			for i := 0; i < tt.vlen(); i++ {
+				m := tt.member(i)
+				x.members = append(x.members, member{reduced.name(m.nameOff), typeName(m.typ)})
+			}
+		}
+		if tt.kind() == btfKindTypedef {
+			x.size = 0
+		}
+		got = append(got, x)
+	}
+
+	// mm_struct and the pointer to it are not used
+	assert.Equal(t, []typ{
+		{kind: btfKindInt, name: "int", size: 4},
+		{kind: btfKindStruct, name: "task_struct", size: 16, members: []member{{"tgid", "int"}}},
+		{kind: btfKindEnum, name: "state", size: 4},
+		{kind: btfKindTypedef, name: "pid_t"},
+		{kind: btfKindStruct, name: "sig", size: 4, members: []member{{"", ""}}},
+		{kind: btfKindUnion, size: 4, members: []member{{"b", "int"}}},
+	}, got)
+
+	// The member offsets are kept
+	taskStruct := reduced.typ(2)
+	assert.Equal(t, uint32(32), taskStruct.extra[2])
+
+	// Both enumerators are kept
+	assert.Equal(t, 2, reduced.typ(3).vlen())
+}
+
+func TestMinCoreBTFErrors(t *testing.T) {
+	_, err := MinCoreBTF([]byte("not BTF"))
+	assert.Error(t, err)
+
+	kernel := newTestBTF(t, "\x00")
+	_, err = MinCoreBTF(kernel, []byte("not an object"))
+	assert.Error(t, err)
+
+	// Objects without CO-RE relocations use nothing
+	obj := newTestELF(t, []string{".BTF"}, [][]byte{newTestBTF(t, "\x00")})
+	out, err := MinCoreBTF(kernel, obj)
+	require.NoError(t, err)
+	b, err := loadBTF(out)
+	require.NoError(t, err)
+	assert.Len(t, b.types, 1)
+
+	_, err = parseCoreAccess("0:a")
+	assert.Error(t, err)
+}
@@ -0,0 +1,250 @@
+package helpers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const mountInfoPath = "/proc/self/mountinfo"
+
+// ErrCgroupV2NotMounted is returned when the cgroup v2 (unified) hierarchy is
+// not mounted, as on v1 only systems.
+var ErrCgroupV2NotMounted = errors.New("cgroup v2 is not mounted")
+
+// CgroupMode describes which cgroup hierarchies are mounted.
+type CgroupMode uint32
+
+const (
+	CgroupModeNone    CgroupMode = iota // no cgroup mounted
+	CgroupModeLegacy                    // v1 only
+	CgroupModeHybrid                    // v1 controllers and v2, usually at /sys/fs/cgroup/unified
+	CgroupModeUnified                   // v2 only
+)
+
+var cgroupModeToString = map[CgroupMode]string{
+	CgroupModeNone:    "none",
+	CgroupModeLegacy:  "legacy",
+	CgroupModeHybrid:  "hybrid",
+	CgroupModeUnified: "unified",
+}
+
+func (m CgroupMode) String() string {
+	return cgroupModeToString[m]
+}
+
+// CgroupMounts holds the cgroup mount points of the current mount namespace.
+type CgroupMounts struct {
+	Mode CgroupMode
+	// V2 is the cgroup v2 mount point, empty if not mounted.
+	V2 string
+	// V2Root is the cgroup path mounted at V2, "/" unless a sub-tree is
+	// bind mounted (as in some containers).
+	V2Root string
+	// V1 maps the v1 controllers (and named hierarchies, as "name=systemd")
+	// to their mount points.
+	V1 map[string]string
+}
+
+// cgroupV1Options are cgroup v1 mount options which are not controllers.
+var cgroupV1Options = map[string]bool{
+	"rw":             true,
+	"ro":             true,
+	"xattr":          true,
+	"noprefix":       true,
+	"clone_children": true,
+	"cpuset_v2_mode": true,
+	"none":           true,
+}
+
+// GetCgroupMounts discovers the cgroup mount points from
+// /proc/self/mountinfo.
+func GetCgroupMounts() (*CgroupMounts, error) {
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover cgroup mounts: %w", err)
+	}
+	defer file.Close()
+
+	return parseCgroupMounts(file)
+}
+
+// parseCgroupMounts parses mountinfo lines as:
+//
+//	36 25 0:31 / /sys/fs/cgroup rw,nosuid shared:9 - cgroup2 cgroup2 rw,nsdelegate
+//	37 25 0:32 / /sys/fs/cgroup/cpu,cpuacct rw shared:10 - cgroup cgroup rw,cpu,cpuacct
+func parseCgroupMounts(r io.Reader) (*CgroupMounts, error) {
+	mounts := &CgroupMounts{
+		V1: make(map[string]string),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || len(fields) < sep+4 {
+			continue
+		}
+
+		root := unescapeMountField(fields[3])
+		mountPoint := unescapeMountField(fields[4])
+		fsType := fields[sep+1]
+		superOptions := fields[sep+3]
+
+		switch fsType {
+		case "cgroup2":
+			// Prefer a mount of the whole hierarchy over bind mounts of
+			// sub-trees
+			if mounts.V2 == "" || (mounts.V2Root != "/" && root == "/") {
+				mounts.V2 = mountPoint
+				mounts.V2Root = root
+			}
+		case "cgroup":
+			for _, option := range strings.Split(superOptions, ",") {
+				if cgroupV1Options[option] || (strings.Contains(option, "=") && !strings.HasPrefix(option, "name=")) {
+					continue
+				}
+				if _, ok := mounts.V1[option]; !ok {
+					mounts.V1[option] = mountPoint
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse mountinfo: %w", err)
+	}
+
+	switch {
+	case mounts.V2 != "" && len(mounts.V1) > 0:
+		mounts.Mode = CgroupModeHybrid
+	case mounts.V2 != "":
+		mounts.Mode = CgroupModeUnified
+	case len(mounts.V1) > 0:
+		mounts.Mode = CgroupModeLegacy
+	}
+
+	return mounts, nil
+}
+
+// unescapeMountField decodes the octal escapes (\040 for a space) of
+// mountinfo fields.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+
+	return b.String()
+}
+
+// CgroupV2MountPoint returns the cgroup v2 mount point, or
+// ErrCgroupV2NotMounted on v1 only systems.
+func CgroupV2MountPoint() (string, error) {
+	mounts, err := GetCgroupMounts()
+	if err != nil {
+		return "", err
+	}
+	if mounts.V2 == "" {
+		return "", ErrCgroupV2NotMounted
+	}
+
+	return mounts.V2, nil
+}
+
+// GetProcessCgroupV2Path returns the path of the cgroup v2 directory of the
+// process (the calling process if pid is 0), for example
+// /sys/fs/cgroup/system.slice/sshd.service.
+func GetProcessCgroupV2Path(pid int) (string, error) {
+	mounts, err := GetCgroupMounts()
+	if err != nil {
+		return "", err
+	}
+	if mounts.V2 == "" {
+		return "", ErrCgroupV2NotMounted
+	}
+
+	procPath := "/proc/self/cgroup"
+	if pid != 0 {
+		procPath = fmt.Sprintf("/proc/%d/cgroup", pid)
+	}
+
+	file, err := os.Open(procPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cgroup of process %d: %w", pid, err)
+	}
+	defer file.Close()
+
+	cgroup, err := parseProcessCgroupV2(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cgroup of process %d: %w", pid, err)
+	}
+
+	return cgroupV2Path(mounts, cgroup)
+}
+
+// parseProcessCgroupV2 returns the v2 cgroup of a /proc/<pid>/cgroup file,
+// from its "0::<path>" line.
+func parseProcessCgroupV2(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if cgroup, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return cgroup, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("process has no cgroup v2")
+}
+
+// cgroupV2Path converts a cgroup to its directory under the v2 mount point.
+func cgroupV2Path(mounts *CgroupMounts, cgroup string) (string, error) {
+	cgroup = strings.TrimSuffix(cgroup, " (deleted)")
+
+	if mounts.V2Root != "" && mounts.V2Root != "/" {
+		rel, ok := strings.CutPrefix(cgroup, mounts.V2Root)
+		if !ok || (rel != "" && rel[0] != '/') {
+			return "", fmt.Errorf("cgroup %s is not under the mounted cgroup %s", cgroup, mounts.V2Root)
+		}
+		cgroup = rel
+	}
+
+	return filepath.Join(mounts.V2, cgroup), nil
+}
+
+// OpenCgroupDir opens the cgroup v2 directory, returning the file descriptor
+// taken by BPFProg.AttachCgroupFD(). The caller must close it.
+func OpenCgroupDir(path string) (int, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open cgroup directory %s: %w", path, err)
+	}
+
+	return fd, nil
+}
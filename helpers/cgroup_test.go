@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCgroupMounts(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		mountInfo string
+		expected  *CgroupMounts
+	}{
+		{
+			testName: "unified",
+			mountInfo: `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+35 24 0:30 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot
+`,
+			expected: &CgroupMounts{
+				Mode:   CgroupModeUnified,
+				V2:     "/sys/fs/cgroup",
+				V2Root: "/",
+				V1:     map[string]string{},
+			},
+		},
+		{
+			testName: "hybrid",
+			mountInfo: `33 24 0:28 / /sys/fs/cgroup ro,nosuid,nodev,noexec shared:9 - tmpfs tmpfs ro,mode=755
+34 33 0:29 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:10 - cgroup2 cgroup2 rw,nsdelegate
+35 33 0:30 / /sys/fs/cgroup/systemd rw,nosuid,nodev,noexec,relatime shared:11 - cgroup cgroup rw,xattr,name=systemd
+38 33 0:33 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:15 - cgroup cgroup rw,cpu,cpuacct
+`,
+			expected: &CgroupMounts{
+				Mode:   CgroupModeHybrid,
+				V2:     "/sys/fs/cgroup/unified",
+				V2Root: "/",
+				V1: map[string]string{
+					"name=systemd": "/sys/fs/cgroup/systemd",
+					"cpu":          "/sys/fs/cgroup/cpu,cpuacct",
+					"cpuacct":      "/sys/fs/cgroup/cpu,cpuacct",
+				},
+			},
+		},
+		{
+			testName: "legacy",
+			mountInfo: `40 33 0:35 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:17 - cgroup cgroup rw,memory
+41 33 0:36 / /sys/fs/cgroup/pids rw,nosuid,nodev,noexec,relatime shared:18 - cgroup cgroup rw,pids,release_agent=/bin/true
+`,
+			expected: &CgroupMounts{
+				Mode: CgroupModeLegacy,
+				V1: map[string]string{
+					"memory": "/sys/fs/cgroup/memory",
+					"pids":   "/sys/fs/cgroup/pids",
+				},
+			},
+		},
+		{
+			testName: "bind mounted sub-tree",
+			mountInfo: `50 40 0:30 /kubepods/pod1 /sys/fs/cgroup rw,nosuid shared:9 - cgroup2 cgroup2 rw
+51 40 0:30 / /host\040cgroup rw,nosuid shared:9 - cgroup2 cgroup2 rw
+`,
+			expected: &CgroupMounts{
+				Mode:   CgroupModeUnified,
+				V2:     "/host cgroup",
+				V2Root: "/",
+				V1:     map[string]string{},
+			},
+		},
+		{
+			testName:  "none",
+			mountInfo: "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n",
+			expected: &CgroupMounts{
+				Mode: CgroupModeNone,
+				V1:   map[string]string{},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mounts, err := parseCgroupMounts(strings.NewReader(tc.mountInfo))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, mounts)
+		})
+	}
+}
+
+func TestParseProcessCgroupV2(t *testing.T) {
+	cgroup, err := parseProcessCgroupV2(strings.NewReader(`12:memory:/user.slice
+1:name=systemd:/user.slice/session-1.scope
+0::/user.slice/session-1.scope
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "/user.slice/session-1.scope", cgroup)
+
+	_, err = parseProcessCgroupV2(strings.NewReader("12:memory:/user.slice\n"))
+	assert.Error(t, err)
+}
+
+func TestCgroupV2Path(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		mounts        *CgroupMounts
+		cgroup        string
+		expected      string
+		expectedError bool
+	}{
+		{
+			testName: "root mount",
+			mounts:   &CgroupMounts{V2: "/sys/fs/cgroup", V2Root: "/"},
+			cgroup:   "/system.slice/sshd.service",
+			expected: "/sys/fs/cgroup/system.slice/sshd.service",
+		},
+		{
+			testName: "root cgroup",
+			mounts:   &CgroupMounts{V2: "/sys/fs/cgroup/unified", V2Root: "/"},
+			cgroup:   "/",
+			expected: "/sys/fs/cgroup/unified",
+		},
+		{
+			testName: "sub-tree mount",
+			mounts:   &CgroupMounts{V2: "/sys/fs/cgroup", V2Root: "/kubepods/pod1"},
+			cgroup:   "/kubepods/pod1/ctr",
+			expected: "/sys/fs/cgroup/ctr",
+		},
+		{
+			testName:      "outside sub-tree mount",
+			mounts:        &CgroupMounts{V2: "/sys/fs/cgroup", V2Root: "/kubepods/pod1"},
+			cgroup:        "/kubepods/pod10",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			path, err := cgroupV2Path(tc.mounts, tc.cgroup)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, path)
+		})
+	}
+}
+
+func TestCgroupModeString(t *testing.T) {
+	assert.Equal(t, "hybrid", CgroupModeHybrid.String())
+	assert.Equal(t, "unified", CgroupModeUnified.String())
+}
@@ -15,3 +15,22 @@ func ExampleTracePipeListen_usage() {
 		}
 	}()
 }
+
+func ExampleTracePipeReader_usage() {
+	reader, err := helpers.NewTracePipeReader()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return
+	}
+	defer reader.Close()
+
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return
+		}
+
+		fmt.Printf("%s[%d] cpu %d: %s\n", record.Task, record.PID, record.CPU, record.Message)
+	}
+}
@@ -0,0 +1,326 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const vmlinuxBTFPath = "/sys/kernel/btf/vmlinux"
+
+// GetTraceableKernelFunctions returns the sorted list of kernel functions that
+// can be traced (e.g. by kprobe_multi links) and for which filter returns true.
+// A nil filter matches all functions.
+//
+// Functions are taken from the tracefs available_filter_functions file, which
+// only lists functions that are not marked as notrace. If tracefs is not
+// available, the vmlinux BTF functions are used instead. In both cases, the
+// functions in the kprobes blacklist are excluded, as are the ones in exclude.
+func GetTraceableKernelFunctions(filter func(name string) bool, exclude ...string) ([]string, error) {
+	funcs, err := GetAvailableFilterFunctions()
+	if err != nil {
+		var errBTF error
+		funcs, errBTF = GetBTFFunctions(vmlinuxBTFPath)
+		if errBTF != nil {
+			return nil, fmt.Errorf("could not enumerate kernel functions: %w", errors.Join(err, errBTF))
+		}
+	}
+
+	blacklist, err := GetKprobeBlacklist()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return FilterKernelFunctions(funcs, filter, append(blacklist, exclude...)), nil
+}
+
+// FilterKernelFunctions returns the sorted and deduplicated list of the given
+// functions for which filter returns true (a nil filter matches all) and that
+// are not in exclude.
+func FilterKernelFunctions(funcs []string, filter func(name string) bool, exclude []string) []string {
+	excluded := make(map[string]struct{}, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = struct{}{}
+	}
+
+	seen := make(map[string]struct{}, len(funcs))
+	filtered := []string{}
+	for _, name := range funcs {
+		if _, ok := excluded[name]; ok {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		if filter != nil && !filter(name) {
+			continue
+		}
+
+		seen[name] = struct{}{}
+		filtered = append(filtered, name)
+	}
+	sort.Strings(filtered)
+
+	return filtered
+}
+
+// GetAvailableFilterFunctions returns the kernel functions listed in the
+// tracefs available_filter_functions file (see SetTracefsPath()). Module
+// names are stripped.
+func GetAvailableFilterFunctions() ([]string, error) {
+	var errs []error
+	for _, path := range tracefsFiles("available_filter_functions") {
+		f, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		defer f.Close()
+
+		return parseAvailableFilterFunctions(f)
+	}
+
+	return nil, fmt.Errorf("could not open available_filter_functions: %w", errors.Join(errs...))
+}
+
+// parseAvailableFilterFunctions parses lines in the "name [module]" format.
+func parseAvailableFilterFunctions(r io.Reader) ([]string, error) {
+	funcs := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		funcs = append(funcs, fields[0])
+	}
+
+	return funcs, scanner.Err()
+}
+
+// GetKprobeBlacklist returns the functions that can not be probed, as listed
+// in the debugfs kprobes blacklist file. debugfs is looked up at the mount
+// point of tracefs set with SetTracefsPath(), or else detected.
+func GetKprobeBlacklist() ([]string, error) {
+	var errs []error
+	for _, path := range debugfsPaths() {
+		f, err := os.Open(filepath.Join(path, "kprobes", "blacklist"))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		defer f.Close()
+
+		return parseKprobeBlacklist(f)
+	}
+
+	return nil, fmt.Errorf("could not open kprobes blacklist: %w", errors.Join(errs...))
+}
+
+// parseKprobeBlacklist parses lines in the "0xstart-0xend name [module]" format.
+func parseKprobeBlacklist(r io.Reader) ([]string, error) {
+	funcs := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		funcs = append(funcs, fields[1])
+	}
+
+	return funcs, scanner.Err()
+}
+
+//
+// BTF
+//
+
+const (
+	btfMagic = 0xeB9F
+
+	btfKindInt       = 1
+	btfKindPtr       = 2
+	btfKindArray     = 3
+	btfKindStruct    = 4
+	btfKindUnion     = 5
+	btfKindEnum      = 6
+	btfKindFwd       = 7
+	btfKindTypedef   = 8
+	btfKindVolatile  = 9
+	btfKindConst     = 10
+	btfKindRestrict  = 11
+	btfKindFunc      = 12
+	btfKindFuncProto = 13
+	btfKindVar       = 14
+	btfKindDatasec   = 15
+	btfKindFloat     = 16
+	btfKindDeclTag   = 17
+	btfKindTypeTag   = 18
+	btfKindEnum64    = 19
+)
+
+type btfHeader struct {
+	Magic   uint16
+	Version uint8
+	Flags   uint8
+	HdrLen  uint32
+	TypeOff uint32
+	TypeLen uint32
+	StrOff  uint32
+	StrLen  uint32
+}
+
+type btfType struct {
+	NameOff uint32
+	Info    uint32
+	SizeTyp uint32
+}
+
+// GetBTFFunctions returns the names of all functions (BTF_KIND_FUNC) described
+// by the BTF file at the given path (e.g. /sys/kernel/btf/vmlinux).
+func GetBTFFunctions(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read BTF file: %w", err)
+	}
+
+	return parseBTFFunctions(data)
+}
+
+func parseBTFFunctions(data []byte) ([]string, error) {
+	funcs := []string{}
+	_, err := parseBTF(data, nil, func(spec *btfSpec, id uint32, t btfType, _ []byte) error {
+		if btfKind(t) != btfKindFunc {
+			return nil
+		}
+		name, err := spec.name(t.NameOff)
+		if err != nil {
+			return err
+		}
+		funcs = append(funcs, name)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return funcs, nil
+}
+
+// btfSpec is a parsed BTF, whose type IDs and string offsets follow the ones
+// of its base for a split BTF (kernel module BTF, based on vmlinux).
+type btfSpec struct {
+	base    *btfSpec
+	order   binary.ByteOrder
+	strs    []byte
+	nrTypes uint32 // including the ones of the base
+}
+
+// name returns the string at the offset.
+func (s *btfSpec) name(off uint32) (string, error) {
+	if s.base != nil {
+		if off < uint32(len(s.base.strs)) {
+			return s.base.name(off)
+		}
+		off -= uint32(len(s.base.strs))
+	}
+
+	return btfString(s.strs, off)
+}
+
+func btfKind(t btfType) uint32 {
+	return (t.Info >> 24) & 0x1f
+}
+
+// parseBTF parses the BTF, split from base if not nil, calling visit for each
+// type with its ID and the data following it (members, array, ...).
+func parseBTF(data []byte, base *btfSpec, visit func(spec *btfSpec, id uint32, t btfType, extra []byte) error) (*btfSpec, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if len(data) >= 2 && binary.BigEndian.Uint16(data) == btfMagic {
+		order = binary.BigEndian
+	}
+
+	var hdr btfHeader
+	if err := binary.Read(bytes.NewReader(data), order, &hdr); err != nil {
+		return nil, fmt.Errorf("could not read BTF header: %w", err)
+	}
+	if hdr.Magic != btfMagic {
+		return nil, fmt.Errorf("invalid BTF magic: %#x", hdr.Magic)
+	}
+
+	typesStart := uint64(hdr.HdrLen) + uint64(hdr.TypeOff)
+	typesEnd := typesStart + uint64(hdr.TypeLen)
+	strsStart := uint64(hdr.HdrLen) + uint64(hdr.StrOff)
+	strsEnd := strsStart + uint64(hdr.StrLen)
+	if typesEnd > uint64(len(data)) || strsEnd > uint64(len(data)) {
+		return nil, errors.New("invalid BTF header: sections out of bounds")
+	}
+	types := data[typesStart:typesEnd]
+
+	spec := &btfSpec{base: base, order: order, strs: data[strsStart:strsEnd]}
+	if base != nil {
+		spec.nrTypes = base.nrTypes
+	}
+
+	typeSize := binary.Size(btfType{})
+	for off := 0; off < len(types); {
+		if len(types)-off < typeSize {
+			return nil, fmt.Errorf("could not read BTF type: %w", io.ErrUnexpectedEOF)
+		}
+		t := btfType{
+			NameOff: order.Uint32(types[off:]),
+			Info:    order.Uint32(types[off+4:]),
+			SizeTyp: order.Uint32(types[off+8:]),
+		}
+		off += typeSize
+		spec.nrTypes++ // type IDs start at 1, 0 is void
+
+		vlen := int(t.Info & 0xffff)
+
+		var skip int
+		switch btfKind(t) {
+		case btfKindInt, btfKindVar, btfKindDeclTag:
+			skip = 4
+		case btfKindArray:
+			skip = 12
+		case btfKindStruct, btfKindUnion, btfKindDatasec, btfKindEnum64:
+			skip = 12 * vlen
+		case btfKindEnum, btfKindFuncProto:
+			skip = 8 * vlen
+		}
+		if len(types)-off < skip {
+			return nil, fmt.Errorf("could not read BTF type %d: %w", spec.nrTypes, io.ErrUnexpectedEOF)
+		}
+
+		if err := visit(spec, spec.nrTypes, t, types[off:off+skip]); err != nil {
+			return nil, err
+		}
+		off += skip
+	}
+
+	return spec, nil
+}
+
+func btfString(strs []byte, off uint32) (string, error) {
+	if uint64(off) >= uint64(len(strs)) {
+		return "", fmt.Errorf("invalid BTF string offset: %d", off)
+	}
+
+	s := strs[off:]
+	if end := bytes.IndexByte(s, 0); end >= 0 {
+		s = s[:end]
+	}
+
+	return string(s), nil
+}
@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterKernelFunctions(t *testing.T) {
+	funcs := []string{"tcp_sendmsg", "tcp_connect", "udp_sendmsg", "tcp_connect", "tcp_v4_rcv"}
+
+	filtered := FilterKernelFunctions(funcs, func(name string) bool {
+		return strings.HasPrefix(name, "tcp_")
+	}, []string{"tcp_v4_rcv"})
+
+	assert.Equal(t, []string{"tcp_connect", "tcp_sendmsg"}, filtered)
+	assert.Len(t, FilterKernelFunctions(funcs, nil, nil), 4)
+}
+
+func TestParseAvailableFilterFunctions(t *testing.T) {
+	input := "run_init_process\ntcp_connect\nxfs_iget [xfs]\n\n"
+
+	funcs, err := parseAvailableFilterFunctions(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run_init_process", "tcp_connect", "xfs_iget"}, funcs)
+}
+
+func TestParseKprobeBlacklist(t *testing.T) {
+	input := "0xffffffff81000000-0xffffffff81000010\tdo_int3\n" +
+		"0xffffffffc0000000-0xffffffffc0000020\tfoo_bar [foo]\n" +
+		"malformed\n"
+
+	funcs, err := parseKprobeBlacklist(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"do_int3", "foo_bar"}, funcs)
+}
+
+func TestParseBTFFunctions(t *testing.T) {
+	strs := []byte("\x00int\x00foo\x00bar\x00s\x00")
+
+	var types bytes.Buffer
+	write := func(v ...interface{}) {
+		for _, x := range v {
+			require.NoError(t, binary.Write(&types, binary.LittleEndian, x))
+		}
+	}
+	info := func(kind, vlen uint32) uint32 { return kind<<24 | vlen }
+
+	write(btfType{NameOff: 1, Info: info(btfKindInt, 0), SizeTyp: 4}, uint32(0))         // int
+	write(btfType{NameOff: 0, Info: info(btfKindFuncProto, 1), SizeTyp: 1}, [2]uint32{}) // proto
+	write(btfType{NameOff: 5, Info: info(btfKindFunc, 0), SizeTyp: 2})                   // foo
+	write(btfType{NameOff: 13, Info: info(btfKindStruct, 1), SizeTyp: 4}, [3]uint32{})   // s
+	write(btfType{NameOff: 9, Info: info(btfKindFunc, 0), SizeTyp: 2})                   // bar
+
+	hdr := btfHeader{
+		Magic:   btfMagic,
+		Version: 1,
+		HdrLen:  uint32(binary.Size(btfHeader{})),
+		TypeOff: 0,
+		TypeLen: uint32(types.Len()),
+		StrOff:  uint32(types.Len()),
+		StrLen:  uint32(len(strs)),
+	}
+
+	var data bytes.Buffer
+	require.NoError(t, binary.Write(&data, binary.LittleEndian, hdr))
+	data.Write(types.Bytes())
+	data.Write(strs)
+
+	funcs, err := parseBTFFunctions(data.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, funcs)
+
+	_, err = parseBTFFunctions([]byte{0, 0, 0, 0})
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	btfDir = "/sys/kernel/btf"
+
+	// btfKfuncTag is the BTF declaration tag of the kfuncs (__bpf_kfunc),
+	// emitted by pahole 1.26+.
+	btfKfuncTag = "bpf_kfunc"
+)
+
+// KfuncAvailable reports whether the kfunc is defined by the BTF of the
+// running kernel, vmlinux or a loaded module, so that programs calling
+// optional kfuncs (bpf_cpumask_*, bpf_crypto_*, ...) can be selected before
+// they are loaded.
+//
+// If the kernel BTF tags its kfuncs, only the tagged functions are kfuncs.
+// Otherwise, any kernel function of that name is reported: the verifier still
+// rejects the calls of functions that are not registered as kfuncs for the
+// program type.
+func KfuncAvailable(name string) (bool, error) {
+	available, err := KfuncsAvailable(name)
+	if err != nil {
+		return false, err
+	}
+
+	return available[name], nil
+}
+
+// KfuncsAvailable is like KfuncAvailable for several kfuncs, reading the
+// kernel BTF once.
+func KfuncsAvailable(names ...string) (map[string]bool, error) {
+	return kfuncsAvailable(btfDir, names)
+}
+
+func kfuncsAvailable(dir string, names []string) (map[string]bool, error) {
+	available := make(map[string]bool, len(names))
+	for _, name := range names {
+		available[name] = false
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "vmlinux"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read vmlinux BTF: %w", err)
+	}
+	vmlinux, hasTags, err := findKfuncs(data, nil, false, available)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse vmlinux BTF: %w", err)
+	}
+	if allAvailable(available) {
+		return available, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list kernel BTF: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "vmlinux" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// The module may have been unloaded
+			continue
+		}
+		// Modules are built with the pahole of vmlinux, tagging their
+		// kfuncs if vmlinux does
+		_, _, err = findKfuncs(data, vmlinux, hasTags, available)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s BTF: %w", entry.Name(), err)
+		}
+		if allAvailable(available) {
+			break
+		}
+	}
+
+	return available, nil
+}
+
+// findKfuncs marks the kfuncs of available, by name, defined by the BTF,
+// split from base if not nil. Only the functions with the kfunc tag are
+// kfuncs if requireTag, or if the BTF has such tags. It returns the parsed
+// BTF, and whether it has kfunc tags.
+func findKfuncs(data []byte, base *btfSpec, requireTag bool, available map[string]bool) (*btfSpec, bool, error) {
+	funcs := make(map[uint32]string) // by type ID, the wanted ones
+	tagged := make(map[uint32]bool)
+	hasTags := false
+
+	spec, err := parseBTF(data, base, func(spec *btfSpec, id uint32, t btfType, _ []byte) error {
+		switch btfKind(t) {
+		case btfKindFunc:
+			name, err := spec.name(t.NameOff)
+			if err != nil {
+				return err
+			}
+			if found, ok := available[name]; ok && !found {
+				funcs[id] = name
+			}
+		case btfKindDeclTag:
+			name, err := spec.name(t.NameOff)
+			if err != nil {
+				return err
+			}
+			if name == btfKfuncTag {
+				hasTags = true
+				tagged[t.SizeTyp] = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	for id, name := range funcs {
+		if !(requireTag || hasTags) || tagged[id] {
+			available[name] = true
+		}
+	}
+
+	return spec, hasTags, nil
+}
+
+func allAvailable(available map[string]bool) bool {
+	for _, found := range available {
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,88 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBTF encodes a BTF with the types, each a btfType and its trailing
+// data, and the strings.
+func newTestBTF(t *testing.T, strs string, types ...interface{}) []byte {
+	t.Helper()
+
+	var typesBuf bytes.Buffer
+	for _, x := range types {
+		require.NoError(t, binary.Write(&typesBuf, binary.LittleEndian, x))
+	}
+
+	hdr := btfHeader{
+		Magic:   btfMagic,
+		Version: 1,
+		HdrLen:  uint32(binary.Size(btfHeader{})),
+		TypeLen: uint32(typesBuf.Len()),
+		StrOff:  uint32(typesBuf.Len()),
+		StrLen:  uint32(len(strs)),
+	}
+
+	var data bytes.Buffer
+	require.NoError(t, binary.Write(&data, binary.LittleEndian, hdr))
+	data.Write(typesBuf.Bytes())
+	data.WriteString(strs)
+
+	return data.Bytes()
+}
+
+func TestKfuncsAvailable(t *testing.T) {
+	info := func(kind uint32) uint32 { return kind << 24 }
+	dir := t.TempDir()
+
+	// vmlinux: 1 proto, 2 bpf_cpumask_create (kfunc), 3 tag, 4 schedule
+	vmlinuxStrs := "\x00bpf_cpumask_create\x00bpf_kfunc\x00schedule\x00"
+	vmlinux := newTestBTF(t, vmlinuxStrs,
+		btfType{Info: info(btfKindFuncProto)},
+		btfType{NameOff: 1, Info: info(btfKindFunc), SizeTyp: 1},
+		btfType{NameOff: 20, Info: info(btfKindDeclTag), SizeTyp: 2}, int32(-1),
+		btfType{NameOff: 30, Info: info(btfKindFunc), SizeTyp: 1},
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vmlinux"), vmlinux, 0o644))
+
+	// module, split from vmlinux: 5 bpf_crypto_ctx_create (kfunc), 6 tag
+	// referencing the string of vmlinux, 7 crypto_helper
+	base := uint32(len(vmlinuxStrs))
+	module := newTestBTF(t, "\x00bpf_crypto_ctx_create\x00crypto_helper\x00",
+		btfType{NameOff: base + 1, Info: info(btfKindFunc), SizeTyp: 1},
+		btfType{NameOff: 20, Info: info(btfKindDeclTag), SizeTyp: 5}, int32(-1),
+		btfType{NameOff: base + 23, Info: info(btfKindFunc), SizeTyp: 1},
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bpf_crypto"), module, 0o644))
+
+	available, err := kfuncsAvailable(dir, []string{"bpf_cpumask_create", "bpf_crypto_ctx_create", "schedule", "crypto_helper", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"bpf_cpumask_create":    true,
+		"bpf_crypto_ctx_create": true,
+		"schedule":              false, // not tagged as a kfunc
+		"crypto_helper":         false,
+		"missing":               false,
+	}, available)
+
+	// Without kfunc tags, any function may be a kfunc
+	untagged := newTestBTF(t, vmlinuxStrs,
+		btfType{Info: info(btfKindFuncProto)},
+		btfType{NameOff: 30, Info: info(btfKindFunc), SizeTyp: 1},
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vmlinux"), untagged, 0o644))
+
+	available, err = kfuncsAvailable(dir, []string{"schedule"})
+	require.NoError(t, err)
+	assert.True(t, available["schedule"])
+
+	_, err = kfuncsAvailable(t.TempDir(), []string{"schedule"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const cpuOnlinePath = "/sys/devices/system/cpu/online"
+
+// OnlineCPUs returns the online CPUs, from /sys/devices/system/cpu/online.
+func OnlineCPUs() ([]int, error) {
+	data, err := os.ReadFile(cpuOnlinePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read online CPUs: %w", err)
+	}
+
+	cpus, err := parseCPUList(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("could not read online CPUs: %w", err)
+	}
+
+	return cpus, nil
+}
+
+// parseCPUList parses a kernel CPU list, such as "0-3,6,8-9".
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, r := range strings.Split(list, ",") {
+		if r == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(r, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
+
+// NewCPUClockPerfEventAttr returns the attributes of a perf event sampling
+// the CPU clock at freq Hz, as sampling profilers do.
+func NewCPUClockPerfEventAttr(freq uint64) *unix.PerfEventAttr {
+	return &unix.PerfEventAttr{
+		Type:   unix.PERF_TYPE_SOFTWARE,
+		Config: unix.PERF_COUNT_SW_CPU_CLOCK,
+		Size:   uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+		Sample: freq,
+		Bits:   unix.PerfBitFreq,
+	}
+}
+
+// OpenPerfEvents opens the perf event of attr on each online CPU, for the
+// process pid, or all processes if pid is -1. The file descriptors are
+// attached to with BPFProg.AttachPerfEvent() and closed by the returned
+// links, or with ClosePerfEvents().
+//
+// With unix.PerfBitInherit set in attr.Bits, the events of a process also
+// count the threads and processes it creates afterwards.
+func OpenPerfEvents(attr *unix.PerfEventAttr, pid int) ([]int, error) {
+	fds, err := openPerfEvents(attr, pid, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not open perf events of process %d: %w", pid, err)
+	}
+
+	return fds, nil
+}
+
+// OpenCgroupPerfEvents opens the perf event of attr on each online CPU, for
+// the processes of the cgroup v2 directory and its descendants only
+// (PERF_FLAG_PID_CGROUP), for example to profile a single container. The
+// directory of a process is returned by GetProcessCgroupV2Path(). See
+// OpenPerfEvents().
+func OpenCgroupPerfEvents(attr *unix.PerfEventAttr, cgroupPath string) ([]int, error) {
+	cgroupFd, err := OpenCgroupDir(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(cgroupFd)
+
+	fds, err := openPerfEvents(attr, cgroupFd, unix.PERF_FLAG_PID_CGROUP)
+	if err != nil {
+		return nil, fmt.Errorf("could not open perf events of cgroup %s: %w", cgroupPath, err)
+	}
+
+	return fds, nil
+}
+
+// ClosePerfEvents closes the file descriptors of perf events.
+func ClosePerfEvents(fds []int) {
+	for _, fd := range fds {
+		_ = unix.Close(fd)
+	}
+}
+
+// openPerfEvents opens the perf event on each online CPU, closing the ones
+// already opened on error.
+func openPerfEvents(attr *unix.PerfEventAttr, pid int, flags int) ([]int, error) {
+	cpus, err := OnlineCPUs()
+	if err != nil {
+		return nil, err
+	}
+
+	a := *attr
+	if a.Size == 0 {
+		a.Size = uint32(unsafe.Sizeof(a))
+	}
+
+	fds := make([]int, 0, len(cpus))
+	for _, cpu := range cpus {
+		fd, err := unix.PerfEventOpen(&a, pid, cpu, -1, flags|unix.PERF_FLAG_FD_CLOEXEC)
+		if err != nil {
+			ClosePerfEvents(fds)
+			return nil, fmt.Errorf("cpu %d: %w", cpu, err)
+		}
+		fds = append(fds, fd)
+	}
+
+	return fds, nil
+}
@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUList(t *testing.T) {
+	testCases := []struct {
+		testName string
+		list     string
+		expected []int
+	}{
+		{testName: "single", list: "0", expected: []int{0}},
+		{testName: "range", list: "0-3", expected: []int{0, 1, 2, 3}},
+		{testName: "mixed", list: "0-1,4,6-7", expected: []int{0, 1, 4, 6, 7}},
+		{testName: "empty", list: "", expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			cpus, err := parseCPUList(tc.list)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cpus)
+		})
+	}
+
+	for _, list := range []string{"a", "0-", "3-1", "0,x-2"} {
+		_, err := parseCPUList(list)
+		assert.Error(t, err, list)
+	}
+}
+
+func TestNewCPUClockPerfEventAttr(t *testing.T) {
+	attr := NewCPUClockPerfEventAttr(99)
+	assert.EqualValues(t, 99, attr.Sample)
+	assert.NotZero(t, attr.Size)
+}
@@ -0,0 +1,184 @@
+package helpers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// EventClock is the kernel clock used by an eBPF program to timestamp events.
+type EventClock int
+
+const (
+	// ClockMonotonic is the clock used by bpf_ktime_get_ns(). It does not
+	// advance while the system is suspended.
+	ClockMonotonic EventClock = iota
+	// ClockBoottime is the clock used by bpf_ktime_get_boot_ns(). It keeps
+	// advancing while the system is suspended.
+	ClockBoottime
+)
+
+func (c EventClock) clockID() int32 {
+	if c == ClockBoottime {
+		return unix.CLOCK_BOOTTIME
+	}
+
+	return unix.CLOCK_MONOTONIC
+}
+
+func (c EventClock) String() string {
+	switch c {
+	case ClockMonotonic:
+		return "CLOCK_MONOTONIC"
+	case ClockBoottime:
+		return "CLOCK_BOOTTIME"
+	}
+
+	return fmt.Sprintf("EventClock(%d)", int(c))
+}
+
+// DefaultResyncInterval is the interval after which a TimeConverter samples
+// the clocks offset again, so wall clock adjustments (NTP, settimeofday) and
+// system suspends are taken into account.
+const DefaultResyncInterval = time.Second
+
+// clockSamples is the number of samples taken when computing the offset. The
+// sample with the smallest realtime window is the most accurate one.
+const clockSamples = 5
+
+// TimeConverter converts kernel timestamps found in events into wall clock
+// time. It keeps the offset between the event clock and CLOCK_REALTIME, and
+// samples it again once it is older than the resync interval.
+//
+// A TimeConverter is safe for concurrent use.
+type TimeConverter struct {
+	clock    EventClock
+	resync   time.Duration
+	mu       sync.RWMutex
+	offset   int64 // CLOCK_REALTIME - event clock, in nanoseconds
+	sampled  time.Time
+	getClock func(clockID int32) (int64, error) // replaceable for testing
+}
+
+// NewTimeConverter creates a TimeConverter for timestamps taken with the
+// given clock. A resync interval of zero uses DefaultResyncInterval, and a
+// negative one disables resyncing (see TimeConverter.Resync()).
+func NewTimeConverter(clock EventClock, resync time.Duration) (*TimeConverter, error) {
+	if clock != ClockMonotonic && clock != ClockBoottime {
+		return nil, fmt.Errorf("invalid event clock: %v", clock)
+	}
+	if resync == 0 {
+		resync = DefaultResyncInterval
+	}
+
+	c := &TimeConverter{
+		clock:    clock,
+		resync:   resync,
+		getClock: clockGettime,
+	}
+	if err := c.Resync(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Resync samples the offset between the event clock and CLOCK_REALTIME.
+func (c *TimeConverter) Resync() error {
+	offset, err := c.sampleOffset()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.offset = offset
+	c.sampled = time.Now()
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Offset returns the current offset, in nanoseconds, between CLOCK_REALTIME
+// and the event clock.
+func (c *TimeConverter) Offset() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.offset
+}
+
+// ToTime converts a kernel timestamp, in nanoseconds, into wall clock time.
+// If the offset is older than the resync interval, it is sampled again
+// first; if sampling fails, the previous offset is kept.
+func (c *TimeConverter) ToTime(ns uint64) time.Time {
+	return time.Unix(0, c.ToUnixNano(ns))
+}
+
+// ToUnixNano converts a kernel timestamp, in nanoseconds, into the number of
+// nanoseconds elapsed since the Unix epoch.
+func (c *TimeConverter) ToUnixNano(ns uint64) int64 {
+	c.mu.RLock()
+	offset, sampled := c.offset, c.sampled
+	c.mu.RUnlock()
+
+	if c.resync > 0 && time.Since(sampled) >= c.resync {
+		if err := c.Resync(); err == nil {
+			offset = c.Offset()
+		}
+	}
+
+	return int64(ns) + offset
+}
+
+// sampleOffset reads CLOCK_REALTIME before and after the event clock, and
+// uses the middle of the window as the realtime matching the event clock
+// reading. The narrowest window out of a few attempts is kept, so preemption
+// between the reads does not skew the result.
+func (c *TimeConverter) sampleOffset() (int64, error) {
+	var (
+		best   int64
+		window int64 = -1
+	)
+
+	for i := 0; i < clockSamples; i++ {
+		before, err := c.getClock(unix.CLOCK_REALTIME)
+		if err != nil {
+			return 0, err
+		}
+		event, err := c.getClock(c.clock.clockID())
+		if err != nil {
+			return 0, err
+		}
+		after, err := c.getClock(unix.CLOCK_REALTIME)
+		if err != nil {
+			return 0, err
+		}
+
+		w := after - before
+		if w < 0 {
+			// The wall clock was stepped backwards in between
+			continue
+		}
+		if window < 0 || w < window {
+			window = w
+			best = before + w/2 - event
+		}
+	}
+
+	if window < 0 {
+		return 0, fmt.Errorf("could not sample %v offset: wall clock is unstable", c.clock)
+	}
+
+	return best, nil
+}
+
+func clockGettime(clockID int32) (int64, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(clockID, &ts); err != nil {
+		return 0, fmt.Errorf("could not get clock %d time: %w", clockID, err)
+	}
+
+	return ts.Nano(), nil
+}
@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestTimeConverter(t *testing.T) {
+	for _, clock := range []EventClock{ClockMonotonic, ClockBoottime} {
+		t.Run(clock.String(), func(t *testing.T) {
+			c, err := NewTimeConverter(clock, -1)
+			require.NoError(t, err)
+
+			now, err := clockGettime(clock.clockID())
+			require.NoError(t, err)
+
+			got := c.ToTime(uint64(now))
+			assert.WithinDuration(t, time.Now(), got, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestTimeConverterInvalidClock(t *testing.T) {
+	_, err := NewTimeConverter(EventClock(42), 0)
+	assert.Error(t, err)
+}
+
+func TestTimeConverterSampleOffset(t *testing.T) {
+	// realtime, event clock, realtime readings for each sample. The third
+	// sample has the narrowest window, and the fourth one is discarded as the
+	// wall clock went backwards.
+	readings := []int64{
+		1000, 100, 1100,
+		2000, 200, 2050,
+		3000, 300, 3010,
+		4000, 400, 3000,
+		5000, 500, 5100,
+	}
+
+	c := &TimeConverter{clock: ClockMonotonic}
+	c.getClock = func(clockID int32) (int64, error) {
+		v := readings[0]
+		readings = readings[1:]
+
+		return v, nil
+	}
+
+	offset, err := c.sampleOffset()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3005-300), offset)
+	assert.Empty(t, readings)
+}
+
+func TestTimeConverterResync(t *testing.T) {
+	offset := int64(0)
+
+	c := &TimeConverter{clock: ClockBoottime, resync: time.Nanosecond}
+	c.getClock = func(clockID int32) (int64, error) {
+		if clockID == unix.CLOCK_REALTIME {
+			return offset, nil
+		}
+
+		return 0, nil
+	}
+	require.NoError(t, c.Resync())
+	assert.Equal(t, int64(1000), c.ToUnixNano(1000))
+
+	// Wall clock stepped forward: the stale offset is sampled again.
+	offset = 5000
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, int64(6000), c.ToUnixNano(1000))
+}
@@ -0,0 +1,165 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultTracefsPaths are the usual mount points of tracefs.
+var defaultTracefsPaths = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// defaultDebugfsPath is the usual mount point of debugfs.
+const defaultDebugfsPath = "/sys/kernel/debug"
+
+// tracefsPathEnv is the environment variable holding the mount point of
+// tracefs set with SetTracefsPath(), shared with the libbpfgo package.
+const tracefsPathEnv = "LIBBPFGO_TRACEFS_PATH"
+
+// SetTracefsPath sets the mount point of tracefs, used by the trace pipe
+// readers and GetAvailableFilterFunctions(), and of debugfs, its parent used
+// by GetKprobeBlacklist(), instead of detecting them, for
+// hosts mounting it in a non-default location. An empty path restores the
+// detection. The mount point is kept in the LIBBPFGO_TRACEFS_PATH environment
+// variable, so that it is the one of the libbpfgo package too, and the other
+// way around with libbpfgo.SetTracefsPath().
+func SetTracefsPath(path string) {
+	if path == "" {
+		_ = os.Unsetenv(tracefsPathEnv)
+		return
+	}
+	_ = os.Setenv(tracefsPathEnv, path)
+}
+
+// TracefsPaths returns the possible mount points of tracefs, in order: the
+// one set with SetTracefsPath() only, or else /sys/kernel/tracing,
+// /sys/kernel/debug/tracing, and the other mount points of tracefs (and
+// tracing directories of debugfs) from /proc/self/mountinfo.
+func TracefsPaths() []string {
+	if path := os.Getenv(tracefsPathEnv); path != "" {
+		return []string{path}
+	}
+
+	paths := slices.Clone(defaultTracefsPaths)
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return paths
+	}
+	defer file.Close()
+
+	mounts, err := parseTracefsMounts(file)
+	if err != nil {
+		return paths
+	}
+	for _, path := range mounts {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// tracefsFiles returns the paths of the tracefs file at each possible mount
+// point.
+func tracefsFiles(name string) []string {
+	paths := TracefsPaths()
+	for i, path := range paths {
+		paths[i] = filepath.Join(path, name)
+	}
+
+	return paths
+}
+
+// debugfsPaths returns the possible mount points of debugfs, in order: the
+// parent of the tracefs mount point set with SetTracefsPath() only, its
+// tracing directory, or else /sys/kernel/debug and the other mount points of
+// debugfs from /proc/self/mountinfo.
+func debugfsPaths() []string {
+	if path := os.Getenv(tracefsPathEnv); path != "" {
+		return []string{filepath.Dir(filepath.Clean(path))}
+	}
+
+	paths := []string{defaultDebugfsPath}
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return paths
+	}
+	defer file.Close()
+
+	mounts, err := parseDebugfsMounts(file)
+	if err != nil {
+		return paths
+	}
+	for _, path := range mounts {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// parseTracefsMounts returns the mount points of tracefs, and the tracing
+// directories of debugfs, of mountinfo lines as:
+//
+//	31 23 0:12 / /sys/kernel/tracing rw,nosuid shared:15 - tracefs tracefs rw
+//	32 23 0:7 / /sys/kernel/debug rw,nosuid shared:16 - debugfs debugfs rw
+func parseTracefsMounts(r io.Reader) ([]string, error) {
+	var paths []string
+
+	err := parseMounts(r, func(fsType, mountPoint string) {
+		switch fsType {
+		case "tracefs":
+			paths = append(paths, mountPoint)
+		case "debugfs":
+			paths = append(paths, filepath.Join(mountPoint, "tracing"))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// parseDebugfsMounts returns the mount points of debugfs of mountinfo lines.
+func parseDebugfsMounts(r io.Reader) ([]string, error) {
+	var paths []string
+
+	err := parseMounts(r, func(fsType, mountPoint string) {
+		if fsType == "debugfs" {
+			paths = append(paths, mountPoint)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// parseMounts calls fn with the file system type and the mount point of each
+// mountinfo line.
+func parseMounts(r io.Reader, fn func(fsType, mountPoint string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		sep := slices.Index(fields, "-")
+		if sep < 5 || len(fields) < sep+2 {
+			continue
+		}
+
+		fn(fields[sep+1], unescapeMountField(fields[4]))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to parse mountinfo: %w", err)
+	}
+
+	return nil
+}
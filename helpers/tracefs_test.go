@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTracefsMounts(t *testing.T) {
+	mountInfo := "22 28 0:21 / /sys rw,nosuid shared:7 - sysfs sysfs rw\n" +
+		"31 22 0:12 / /sys/kernel/tracing rw,nosuid shared:15 - tracefs tracefs rw\n" +
+		"32 22 0:7 / /run/debug rw,nosuid shared:16 - debugfs debugfs rw\n" +
+		"33 28 0:12 / /run/trace\\040fs rw shared:17 - tracefs tracefs rw\n" +
+		"invalid\n"
+
+	mounts, err := parseTracefsMounts(strings.NewReader(mountInfo))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/sys/kernel/tracing", "/run/debug/tracing", "/run/trace fs"}, mounts)
+
+	mounts, err = parseDebugfsMounts(strings.NewReader(mountInfo))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/run/debug"}, mounts)
+}
+
+func TestSetTracefsPath(t *testing.T) {
+	defer SetTracefsPath("")
+
+	paths := TracefsPaths()
+	assert.Equal(t, defaultTracefsPaths, paths[:len(defaultTracefsPaths)])
+
+	SetTracefsPath("/run/tracing")
+	assert.Equal(t, []string{"/run/tracing"}, TracefsPaths())
+	assert.Equal(t, []string{"/run/tracing/trace_pipe"}, tracefsFiles("trace_pipe"))
+	assert.Equal(t, []string{"/run"}, debugfsPaths())
+
+	SetTracefsPath("/run/debug/tracing/")
+	assert.Equal(t, []string{"/run/debug"}, debugfsPaths())
+
+	SetTracefsPath("")
+	assert.Equal(t, paths, TracefsPaths())
+	assert.Equal(t, defaultDebugfsPath, debugfsPaths()[0])
+
+	// Set in the environment, as by the libbpfgo package
+	t.Setenv(tracefsPathEnv, "/run/tracing")
+	assert.Equal(t, []string{"/run/tracing"}, TracefsPaths())
+}
@@ -4,18 +4,23 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // TracePipeListen reads data from the trace pipe that bpf_trace_printk() writes to,
-// (/sys/kernel/debug/tracing/trace_pipe).
+// (/sys/kernel/tracing/trace_pipe, see SetTracefsPath()).
 // It writes the data to stdout. The pipe is global, so this function is not
 // associated with any BPF program. It is recommended to use bpf_trace_printk()
 // and this function for debug purposes only.
 // This is a blocking function intended to be called from a goroutine.
 func TracePipeListen() error {
-	f, err := os.Open("/sys/kernel/debug/tracing/trace_pipe")
+	f, err := openTracePipe(0)
 	if err != nil {
-		return fmt.Errorf("failed to open trace pipe: %w", err)
+		return err
 	}
 	defer f.Close()
 
@@ -32,3 +37,119 @@ func TracePipeListen() error {
 		fmt.Println(s)
 	}
 }
+
+// TraceRecord is a line read from the trace pipe, split into the standard
+// prefix fields and the message.
+type TraceRecord struct {
+	Task      string        // task comm
+	PID       int           // task pid
+	CPU       int           // cpu the line was written on
+	Flags     string        // irqs-off, need-resched, hardirq/softirq and preempt-depth flags
+	Timestamp time.Duration // time since boot
+	Event     string        // e.g. bpf_trace_printk
+	Message   string
+	Raw       string // the whole line
+}
+
+// Example lines (the tgid column is only present when the record-tgid trace
+// option is set):
+//
+//	<...>-1234    [003] d..31 12345.678901: bpf_trace_printk: message
+//	bash-1234     (   1234) [003] .... 12345.678901: 0: message
+var traceLineRegexp = regexp.MustCompile(
+	`^\s*(.+)-(\d+)\s+(?:\(\s*[-\d]+\)\s+)?\[(\d+)\]\s+(\S+)\s+(\d+)\.(\d+):\s+([^:\s]+):\s?(.*)$`,
+)
+
+// ParseTraceLine parses a line read from the trace pipe. Lines without the
+// standard prefix are returned with only the Message and Raw fields set.
+func ParseTraceLine(line string) TraceRecord {
+	line = strings.TrimRight(line, "\n")
+	record := TraceRecord{
+		Message: line,
+		Raw:     line,
+	}
+
+	m := traceLineRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return record
+	}
+
+	pid, errPID := strconv.Atoi(m[2])
+	cpu, errCPU := strconv.Atoi(m[3])
+	secs, errSecs := strconv.ParseInt(m[5], 10, 64)
+	usecs, errUsecs := strconv.ParseInt(m[6], 10, 64)
+	if errPID != nil || errCPU != nil || errSecs != nil || errUsecs != nil {
+		return record
+	}
+
+	// The fractional part has microsecond precision by default, but it
+	// depends on the trace clock.
+	for i := len(m[6]); i < 9; i++ {
+		usecs *= 10
+	}
+
+	record.Task = m[1]
+	record.PID = pid
+	record.CPU = cpu
+	record.Flags = m[4]
+	record.Timestamp = time.Duration(secs)*time.Second + time.Duration(usecs)
+	record.Event = m[7]
+	record.Message = m[8]
+
+	return record
+}
+
+// TracePipeReader reads the lines bpf_trace_printk() writes to the trace
+// pipe as structured records. The pipe is global, so records are not
+// associated with any BPF program, and the reader is meant for debugging.
+type TracePipeReader struct {
+	f *os.File
+	r *bufio.Reader
+}
+
+// NewTracePipeReader opens the trace pipe. Only one reader should be open
+// at a time, as lines are consumed when read.
+func NewTracePipeReader() (*TracePipeReader, error) {
+	// Non blocking, so Close() can interrupt a pending Read()
+	f, err := openTracePipe(syscall.O_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TracePipeReader{
+		f: f,
+		r: bufio.NewReader(f),
+	}, nil
+}
+
+// openTracePipe opens the trace pipe at the first tracefs mount point that
+// has it.
+func openTracePipe(flags int) (*os.File, error) {
+	var err error
+
+	for _, path := range tracefsFiles("trace_pipe") {
+		var f *os.File
+
+		f, err = os.OpenFile(path, os.O_RDONLY|flags, 0)
+		if err == nil {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to open trace pipe: %w", err)
+}
+
+// Read blocks until a line is available in the trace pipe and returns it.
+func (t *TracePipeReader) Read() (TraceRecord, error) {
+	line, err := t.r.ReadString('\n')
+	if err != nil {
+		return TraceRecord{}, fmt.Errorf("failed to read from trace pipe: %w", err)
+	}
+
+	return ParseTraceLine(line), nil
+}
+
+// Close closes the trace pipe, unblocking any pending Read().
+func (t *TracePipeReader) Close() error {
+	return t.f.Close()
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceLine(t *testing.T) {
+	testCases := []struct {
+		testName string
+		line     string
+		expected TraceRecord
+	}{
+		{
+			testName: "bpf_trace_printk",
+			line:     "           <...>-1234    [003] d..31 12345.678901: bpf_trace_printk: hello: world\n",
+			expected: TraceRecord{
+				Task:      "<...>",
+				PID:       1234,
+				CPU:       3,
+				Flags:     "d..31",
+				Timestamp: 12345*time.Second + 678901*time.Microsecond,
+				Event:     "bpf_trace_printk",
+				Message:   "hello: world",
+			},
+		},
+		{
+			testName: "task with dashes and tgid",
+			line:     " kworker/u8:2-ev-42      (     42) [000] .... 7.000100: 0: value=5",
+			expected: TraceRecord{
+				Task:      "kworker/u8:2-ev",
+				PID:       42,
+				CPU:       0,
+				Flags:     "....",
+				Timestamp: 7*time.Second + 100*time.Microsecond,
+				Event:     "0",
+				Message:   "value=5",
+			},
+		},
+		{
+			testName: "no prefix",
+			line:     "CPU:2 [LOST 10 EVENTS]",
+			expected: TraceRecord{
+				Message: "CPU:2 [LOST 10 EVENTS]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			record := ParseTraceLine(tc.line)
+
+			tc.expected.Raw = record.Raw
+			assert.Equal(t, tc.expected, record)
+		})
+	}
+}
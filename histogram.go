@@ -0,0 +1,202 @@
+package libbpfgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//
+// Histogram
+//
+// Latency and size distributions are usually collected by BPF programs in
+// log2 buckets, as done by the bcc and libbpf tools: bucket i counts the
+// values v with log2(v) == i, so bucket 0 holds 0 and 1, and bucket i > 0
+// holds [2^i, 2^(i+1)-1].
+//
+//	struct {
+//	    __uint(type, BPF_MAP_TYPE_ARRAY);
+//	    __uint(max_entries, 27);
+//	    __type(key, u32);
+//	    __type(value, u64);
+//	} hist SEC(".maps");
+//
+//	u32 slot = log2l(delta);
+//	u64 *count = bpf_map_lookup_elem(&hist, &slot);
+//	if (count)
+//	    __sync_fetch_and_add(count, 1);
+//
+
+// Histogram is a log2 histogram.
+type Histogram struct {
+	Unit    string // unit of the values, e.g. "usecs"
+	Buckets []uint64
+}
+
+// NewHistogram creates a histogram from log2 bucket counts, for example the
+// slots of a struct hist read from a map.
+func NewHistogram(unit string, buckets []uint64) *Histogram {
+	return &Histogram{
+		Unit:    unit,
+		Buckets: buckets,
+	}
+}
+
+// ReadHistogram reads a histogram from an array or per-CPU array map holding
+// one u64 bucket per key (per-CPU buckets are summed).
+func ReadHistogram(bpfMap *BPFMap, unit string) (*Histogram, error) {
+	counter, err := NewCounter(bpfMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read histogram: %w", err)
+	}
+
+	buckets := make([]uint64, bpfMap.MaxEntries())
+	for i := range buckets {
+		buckets[i], err = counter.Get(uint32(i))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read histogram bucket %d: %w", i, err)
+		}
+	}
+
+	return NewHistogram(unit, buckets), nil
+}
+
+// BucketRange returns the lowest and highest values counted in the bucket.
+func BucketRange(bucket int) (uint64, uint64) {
+	if bucket <= 0 {
+		return 0, 1
+	}
+	if bucket >= 63 {
+		return 1 << 63, ^uint64(0)
+	}
+
+	return 1 << bucket, 1<<(bucket+1) - 1
+}
+
+// Count returns the number of values in the histogram.
+func (h *Histogram) Count() uint64 {
+	var count uint64
+	for _, c := range h.Buckets {
+		count += c
+	}
+
+	return count
+}
+
+// Percentile estimates the p-th percentile (0 to 100) of the values,
+// interpolating linearly within the bucket it falls in. It returns 0 for an
+// empty histogram.
+func (h *Histogram) Percentile(p float64) uint64 {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+
+	p = min(max(p, 0), 100)
+	target := p / 100 * float64(total)
+
+	var cumulative float64
+	for i, c := range h.Buckets {
+		if c == 0 {
+			continue
+		}
+
+		if cumulative+float64(c) >= target {
+			low, high := BucketRange(i)
+			fraction := (target - cumulative) / float64(c)
+
+			return low + uint64(fraction*float64(high-low))
+		}
+		cumulative += float64(c)
+	}
+
+	_, high := BucketRange(len(h.Buckets) - 1)
+
+	return high
+}
+
+// lastBucket returns the index of the last non empty bucket, or -1.
+func (h *Histogram) lastBucket() int {
+	for i := len(h.Buckets) - 1; i >= 0; i-- {
+		if h.Buckets[i] != 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// String renders the histogram as text, in the format of the bcc tools:
+//
+//	usecs               : count    distribution
+//	    0 -> 1          : 0        |                                        |
+//	    2 -> 3          : 4        |****************************************|
+func (h *Histogram) String() string {
+	last := h.lastBucket()
+	if last < 0 {
+		return ""
+	}
+
+	var maxCount uint64
+	for _, c := range h.Buckets[:last+1] {
+		maxCount = max(maxCount, c)
+	}
+
+	// Wide values need wider columns and leave less room for stars
+	stars, indent, unitWidth, valueWidth := 40, 5, 19, 10
+	if last > 32 {
+		stars, indent, unitWidth, valueWidth = 20, 15, 29, 20
+	}
+
+	unit := h.Unit
+	if unit == "" {
+		unit = "value"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s%-*s : count    distribution\n", indent, "", unitWidth, unit)
+
+	for i, c := range h.Buckets[:last+1] {
+		low, high := BucketRange(i)
+		numStars := int(c * uint64(stars) / maxCount)
+
+		fmt.Fprintf(&b, "%*d -> %-*d : %-8d |%s%s|\n",
+			valueWidth, low, valueWidth, high, c,
+			strings.Repeat("*", numStars), strings.Repeat(" ", stars-numStars))
+	}
+
+	return b.String()
+}
+
+type histogramBucketJSON struct {
+	Low   uint64 `json:"low"`
+	High  uint64 `json:"high"`
+	Count uint64 `json:"count"`
+}
+
+type histogramJSON struct {
+	Unit    string                `json:"unit,omitempty"`
+	Count   uint64                `json:"count"`
+	Buckets []histogramBucketJSON `json:"buckets"`
+}
+
+// MarshalJSON renders the histogram with the ranges of its buckets, up to
+// the last non empty one.
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	out := histogramJSON{
+		Unit:    h.Unit,
+		Count:   h.Count(),
+		Buckets: []histogramBucketJSON{},
+	}
+
+	for i, c := range h.Buckets[:h.lastBucket()+1] {
+		low, high := BucketRange(i)
+		out.Buckets = append(out.Buckets, histogramBucketJSON{
+			Low:   low,
+			High:  high,
+			Count: c,
+		})
+	}
+
+	return json.Marshal(out)
+}
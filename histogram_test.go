@@ -0,0 +1,72 @@
+package libbpfgo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketRange(t *testing.T) {
+	testCases := []struct {
+		bucket    int
+		low, high uint64
+	}{
+		{0, 0, 1},
+		{1, 2, 3},
+		{2, 4, 7},
+		{10, 1024, 2047},
+		{63, 1 << 63, ^uint64(0)},
+	}
+
+	for _, tc := range testCases {
+		low, high := BucketRange(tc.bucket)
+		assert.Equal(t, tc.low, low, tc.bucket)
+		assert.Equal(t, tc.high, high, tc.bucket)
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := NewHistogram("usecs", []uint64{0, 0, 50, 0, 50, 0})
+
+	assert.Equal(t, uint64(100), h.Count())
+	assert.Equal(t, uint64(4), h.Percentile(0))
+	assert.Equal(t, uint64(5), h.Percentile(25))
+	assert.Equal(t, uint64(7), h.Percentile(50))
+	assert.Equal(t, uint64(23), h.Percentile(75))
+	assert.Equal(t, uint64(31), h.Percentile(100))
+	assert.Equal(t, uint64(31), h.Percentile(200))
+
+	assert.Equal(t, uint64(0), NewHistogram("", nil).Percentile(50))
+}
+
+func TestHistogramString(t *testing.T) {
+	h := NewHistogram("usecs", []uint64{0, 1, 4, 2, 0, 0})
+
+	expected := "" +
+		"     usecs               : count    distribution\n" +
+		"         0 -> 1          : 0        |                                        |\n" +
+		"         2 -> 3          : 1        |**********                              |\n" +
+		"         4 -> 7          : 4        |****************************************|\n" +
+		"         8 -> 15         : 2        |********************                    |\n"
+	assert.Equal(t, expected, h.String())
+
+	assert.Equal(t, "", NewHistogram("usecs", make([]uint64, 4)).String())
+}
+
+func TestHistogramMarshalJSON(t *testing.T) {
+	h := NewHistogram("usecs", []uint64{3, 0, 1, 0})
+
+	b, err := json.Marshal(h)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"unit": "usecs",
+		"count": 4,
+		"buckets": [
+			{"low": 0, "high": 1, "count": 3},
+			{"low": 2, "high": 3, "count": 0},
+			{"low": 4, "high": 7, "count": 1}
+		]
+	}`, string(b))
+}
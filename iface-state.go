@@ -0,0 +1,146 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+//
+// Interface datapath state
+//
+// An interface runs BPF programs at several hooks: XDP (one program per
+// mode), TCX and cls_bpf (see ListTcPrograms()) and, for netkit devices, the
+// primary and peer hooks. InterfaceBPFState() reports all of them at once, so
+// operators can snapshot the datapath of an interface, and check it against
+// the expected programs.
+//
+
+// XDPMode is the mode of an XDP program attached to an interface.
+type XDPMode uint32
+
+const (
+	XDPModeGeneric XDPMode = C.XDP_FLAGS_SKB_MODE
+	XDPModeDriver  XDPMode = C.XDP_FLAGS_DRV_MODE
+	XDPModeHW      XDPMode = C.XDP_FLAGS_HW_MODE
+)
+
+var xdpModeToString = map[XDPMode]string{
+	XDPModeGeneric: "generic",
+	XDPModeDriver:  "driver",
+	XDPModeHW:      "hw",
+}
+
+func (m XDPMode) String() string {
+	str, ok := xdpModeToString[m]
+	if !ok {
+		return fmt.Sprintf("XDPMode(%d)", uint32(m))
+	}
+
+	return str
+}
+
+// QueryXDPProgramID returns the id of the XDP program attached to the
+// interface in the mode, or 0 if none. With mode 0, it returns the program
+// of the interface if attached in a single mode, or 0.
+func QueryXDPProgramID(ifindex int, mode XDPMode) (uint32, error) {
+	var progIDC C.__u32
+
+	retC := C.bpf_xdp_query_id(C.int(ifindex), C.int(mode), &progIDC)
+	if retC < 0 {
+		return 0, fmt.Errorf("failed to query xdp program of interface %d: %w", ifindex, syscall.Errno(-retC))
+	}
+
+	return uint32(progIDC), nil
+}
+
+// XDPQueryResult is the XDP state of an interface: the id of the program
+// attached in each mode, or 0.
+type XDPQueryResult struct {
+	DriverProgID  uint32
+	GenericProgID uint32
+	HWProgID      uint32
+}
+
+// QueryXDP returns the programs attached to the XDP hook of the interface.
+func QueryXDP(ifindex int) (*XDPQueryResult, error) {
+	optsC, errno := C.cgo_bpf_xdp_query_opts_new()
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create bpf_xdp_query_opts: %w", errno)
+	}
+	defer C.cgo_bpf_xdp_query_opts_free(optsC)
+
+	retC := C.bpf_xdp_query(C.int(ifindex), 0, optsC)
+	if retC < 0 {
+		return nil, fmt.Errorf("failed to query xdp hook of interface %d: %w", ifindex, syscall.Errno(-retC))
+	}
+
+	return &XDPQueryResult{
+		DriverProgID:  uint32(C.cgo_bpf_xdp_query_opts_drv_prog_id(optsC)),
+		GenericProgID: uint32(C.cgo_bpf_xdp_query_opts_skb_prog_id(optsC)),
+		HWProgID:      uint32(C.cgo_bpf_xdp_query_opts_hw_prog_id(optsC)),
+	}, nil
+}
+
+// InterfaceState is the state of the BPF hooks of an interface.
+type InterfaceState struct {
+	Name  string
+	Index int
+	XDP   XDPQueryResult
+	// TC lists the TCX programs and cls_bpf filters of the ingress and
+	// egress hooks, as ListTcPrograms().
+	TC []TcHookPrograms
+	// NetkitPrimary and NetkitPeer are nil unless the interface is the
+	// primary device of a netkit pair (kernel 6.7+).
+	NetkitPrimary *TCXQueryResult
+	NetkitPeer    *TCXQueryResult
+}
+
+// InterfaceBPFState reports the programs attached to the XDP, TCX, cls_bpf
+// and netkit hooks of the interface.
+func InterfaceBPFState(deviceName string) (*InterfaceState, error) {
+	iface, err := net.InterfaceByName(deviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find device by name %s: %w", deviceName, err)
+	}
+
+	state := &InterfaceState{Name: iface.Name, Index: iface.Index}
+
+	xdp, err := QueryXDP(iface.Index)
+	if err != nil {
+		return nil, err
+	}
+	state.XDP = *xdp
+
+	state.TC, err = ListTcPrograms(deviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	state.NetkitPrimary, err = QueryNetkit(iface.Index, BPFAttachTypeNetkitPrimary)
+	if err != nil && !notNetkit(err) {
+		return nil, err
+	}
+	state.NetkitPeer, err = QueryNetkit(iface.Index, BPFAttachTypeNetkitPeer)
+	if err != nil && !notNetkit(err) {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// notNetkit reports whether a netkit query failed because the interface is
+// not the primary device of a netkit pair (ENXIO, EACCES for the peer device)
+// or the kernel does not support netkit (EINVAL).
+func notNetkit(err error) bool {
+	return errors.Is(err, syscall.ENXIO) ||
+		errors.Is(err, syscall.EACCES) ||
+		errors.Is(err, syscall.EINVAL)
+}
@@ -0,0 +1,23 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXDPModeString(t *testing.T) {
+	assert.Equal(t, "generic", XDPModeGeneric.String())
+	assert.Equal(t, "driver", XDPModeDriver.String())
+	assert.Equal(t, "hw", XDPModeHW.String())
+	assert.Equal(t, "XDPMode(0)", XDPMode(0).String())
+}
+
+func TestNotNetkit(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.ENXIO, syscall.EACCES, syscall.EINVAL} {
+		assert.True(t, notNetkit(fmt.Errorf("failed to query hook: %w", errno)), errno)
+	}
+	assert.False(t, notNetkit(syscall.EPERM))
+}
@@ -0,0 +1,178 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//
+// Iterator options
+//
+// The parameters of an iterator link are a union in the kernel: a map for
+// map element iterators, a cgroup for cgroup iterators, or a task for task
+// iterators. IterOpts fields of different kinds can not be combined, and
+// some iterators require theirs:
+//
+//	SEC("iter/bpf_map_elem")  MapElemIter(m)   (required)
+//	SEC("iter/cgroup")        CgroupIter(...)  (defaults to the root cgroup)
+//	SEC("iter/task")          TaskIter(pid)    (defaults to all tasks)
+//	SEC("iter/task_vma")      TaskVMAIter(pid) (defaults to all tasks)
+//	SEC("iter/ksym")          KsymIter()
+//
+
+// MapElemIter returns the options of an iterator over the elements of the
+// map (iter/bpf_map_elem, iter/bpf_sk_storage_map, iter/sockmap).
+func MapElemIter(m *BPFMap) IterOpts {
+	return IterOpts{MapFd: m.FileDescriptor()}
+}
+
+// CgroupIter returns the options of an iterator walking the cgroups from the
+// cgroup opened as cgroupFd (iter/cgroup).
+func CgroupIter(cgroupFd int, order BPFCgroupIterOrder) IterOpts {
+	return IterOpts{CgroupFd: cgroupFd, CgroupIterOrder: order}
+}
+
+// CgroupIterByID returns the options of an iterator walking the cgroups from
+// the cgroup with the given id (iter/cgroup).
+func CgroupIterByID(cgroupID uint64, order BPFCgroupIterOrder) IterOpts {
+	return IterOpts{CgroupId: cgroupID, CgroupIterOrder: order}
+}
+
+// TaskIter returns the options of an iterator over the tasks of the process
+// (iter/task, iter/task_file), or over all tasks if pid is 0.
+func TaskIter(pid int) IterOpts {
+	return IterOpts{Pid: pid}
+}
+
+// TaskVMAIter returns the options of an iterator over the memory areas of
+// the process (iter/task_vma), or of all processes if pid is 0.
+func TaskVMAIter(pid int) IterOpts {
+	return IterOpts{Pid: pid}
+}
+
+// KsymIter returns the options of an iterator over the kernel symbols
+// (iter/ksym), which takes no parameters.
+func KsymIter() IterOpts {
+	return IterOpts{}
+}
+
+// iterKind is the union member of bpf_iter_link_info used by the options.
+type iterKind uint32
+
+const (
+	iterKindNone   iterKind = C.CGO_ITER_LINK_INFO_NONE
+	iterKindMap    iterKind = C.CGO_ITER_LINK_INFO_MAP
+	iterKindCgroup iterKind = C.CGO_ITER_LINK_INFO_CGROUP
+	iterKindTask   iterKind = C.CGO_ITER_LINK_INFO_TASK
+)
+
+var iterKindToString = map[iterKind]string{
+	iterKindNone:   "none",
+	iterKindMap:    "map",
+	iterKindCgroup: "cgroup",
+	iterKindTask:   "task",
+}
+
+func (k iterKind) String() string {
+	return iterKindToString[k]
+}
+
+// iterTarget describes the parameters taken by an iterator target.
+type iterTarget struct {
+	kind     iterKind
+	required bool
+}
+
+// iterTargets are the iterator targets taking parameters.
+var iterTargets = map[string]iterTarget{
+	"bpf_map_elem":       {kind: iterKindMap, required: true},
+	"bpf_sk_storage_map": {kind: iterKindMap, required: true},
+	"sockmap":            {kind: iterKindMap, required: true},
+	"cgroup":             {kind: iterKindCgroup},
+	"task":               {kind: iterKindTask},
+	"task_file":          {kind: iterKindTask},
+	"task_vma":           {kind: iterKindTask},
+}
+
+// kind returns the union member used by the options, failing if fields of
+// several members are set.
+func (o IterOpts) kind() (iterKind, error) {
+	var kinds []iterKind
+
+	if o.MapFd != 0 {
+		kinds = append(kinds, iterKindMap)
+	}
+	if o.CgroupFd != 0 || o.CgroupId != 0 || o.CgroupIterOrder != BPFIterOrderUnspec {
+		kinds = append(kinds, iterKindCgroup)
+	}
+	if o.Tid != 0 || o.Pid != 0 || o.PidFd != 0 {
+		kinds = append(kinds, iterKindTask)
+	}
+
+	switch len(kinds) {
+	case 0:
+		return iterKindNone, nil
+	case 1:
+	default:
+		return iterKindNone, fmt.Errorf("%s and %s options can not be combined", kinds[0], kinds[1])
+	}
+
+	switch kinds[0] {
+	case iterKindCgroup:
+		if o.CgroupFd != 0 && o.CgroupId != 0 {
+			return iterKindNone, errors.New("only one of CgroupFd and CgroupId can be set")
+		}
+	case iterKindTask:
+		n := 0
+		for _, v := range []int{o.Tid, o.Pid, o.PidFd} {
+			if v != 0 {
+				n++
+			}
+		}
+		if n > 1 {
+			return iterKindNone, errors.New("only one of Tid, Pid and PidFd can be set")
+		}
+	}
+
+	return kinds[0], nil
+}
+
+// validateIterOpts checks the options against the iterator target, taken
+// from the program section name ("iter/task", "iter.s/task_vma"), and
+// returns the union member to set.
+func validateIterOpts(sectionName string, opts IterOpts) (iterKind, error) {
+	kind, err := opts.kind()
+	if err != nil {
+		return iterKindNone, err
+	}
+
+	name, ok := strings.CutPrefix(sectionName, "iter/")
+	if !ok {
+		name, ok = strings.CutPrefix(sectionName, "iter.s/")
+	}
+	if !ok {
+		// Unknown target, e.g. set with SetAttachTarget()
+		return kind, nil
+	}
+
+	target, ok := iterTargets[name]
+	switch {
+	case !ok && kind != iterKindNone:
+		return iterKindNone, fmt.Errorf("%s iterator takes no options, %s options given", name, kind)
+	case !ok:
+		return kind, nil
+	case kind == iterKindNone && target.required:
+		return iterKindNone, fmt.Errorf("%s iterator requires %s options", name, target.kind)
+	case kind != iterKindNone && kind != target.kind:
+		return iterKindNone, fmt.Errorf("%s iterator takes %s options, %s options given", name, target.kind, kind)
+	}
+
+	return kind, nil
+}
@@ -0,0 +1,110 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterOptsKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    IterOpts
+		want    iterKind
+		wantErr bool
+	}{
+		{name: "none", opts: KsymIter(), want: iterKindNone},
+		{name: "map", opts: IterOpts{MapFd: 5}, want: iterKindMap},
+		{name: "cgroup fd", opts: CgroupIter(5, BPFIterDescendantsPre), want: iterKindCgroup},
+		{name: "cgroup id", opts: CgroupIterByID(1, BPFIterSelfOnly), want: iterKindCgroup},
+		{name: "cgroup order only", opts: IterOpts{CgroupIterOrder: BPFIterAncestorsUp}, want: iterKindCgroup},
+		{name: "task", opts: TaskIter(100), want: iterKindTask},
+		{name: "task vma", opts: TaskVMAIter(100), want: iterKindTask},
+		{name: "all tasks", opts: TaskIter(0), want: iterKindNone},
+		{name: "map and task", opts: IterOpts{MapFd: 5, Pid: 100}, wantErr: true},
+		{name: "cgroup and task", opts: IterOpts{CgroupFd: 5, Tid: 100}, wantErr: true},
+		{name: "cgroup fd and id", opts: IterOpts{CgroupFd: 5, CgroupId: 1}, wantErr: true},
+		{name: "pid and tid", opts: IterOpts{Pid: 100, Tid: 101}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, err := tt.opts.kind()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, kind)
+		})
+	}
+}
+
+func TestValidateIterOpts(t *testing.T) {
+	tests := []struct {
+		name        string
+		sectionName string
+		opts        IterOpts
+		want        iterKind
+		wantErr     string
+	}{
+		{
+			name:        "map elem",
+			sectionName: "iter/bpf_map_elem",
+			opts:        IterOpts{MapFd: 5},
+			want:        iterKindMap,
+		},
+		{
+			name:        "map elem without map",
+			sectionName: "iter/bpf_map_elem",
+			wantErr:     "bpf_map_elem iterator requires map options",
+		},
+		{
+			name:        "sleepable task",
+			sectionName: "iter.s/task",
+			opts:        TaskIter(100),
+			want:        iterKindTask,
+		},
+		{
+			name:        "task with map",
+			sectionName: "iter/task_vma",
+			opts:        IterOpts{MapFd: 5},
+			wantErr:     "task_vma iterator takes task options, map options given",
+		},
+		{
+			name:        "ksym",
+			sectionName: "iter/ksym",
+			opts:        KsymIter(),
+			want:        iterKindNone,
+		},
+		{
+			name:        "ksym with task",
+			sectionName: "iter/ksym",
+			opts:        TaskIter(100),
+			wantErr:     "ksym iterator takes no options, task options given",
+		},
+		{
+			name:        "cgroup root",
+			sectionName: "iter/cgroup",
+			want:        iterKindNone,
+		},
+		{
+			name:        "unknown section",
+			sectionName: "custom",
+			opts:        IterOpts{MapFd: 5},
+			want:        iterKindMap,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, err := validateIterOpts(tt.sectionName, tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, kind)
+		})
+	}
+}
@@ -12,22 +12,50 @@ import (
 
 //export perfCallback
 func perfCallback(ctx unsafe.Pointer, cpu C.int, data unsafe.Pointer, size C.int) {
-	pb := eventChannels.get(uint(uintptr(ctx))).(*PerfBuffer)
-	pb.eventsChan <- C.GoBytes(data, size)
+	pb, ok := eventChannels.get(uint(uintptr(ctx))).(*PerfBuffer)
+	if !ok {
+		return
+	}
+
+	if pb.limiter != nil && !pb.limiter.Allow(unsafe.Slice((*byte)(data), int(size))) {
+		return
+	}
+
+	if pb.sampleFn != nil {
+		pb.sampleFn(int(cpu), unsafe.Slice((*byte)(data), int(size)))
+		return
+	}
+
+	pb.deliver(C.GoBytes(data, size))
 }
 
 //export perfLostCallback
 func perfLostCallback(ctx unsafe.Pointer, cpu C.int, cnt C.ulonglong) {
-	pb := eventChannels.get(uint(uintptr(ctx))).(*PerfBuffer)
-	if pb.lostChan != nil {
-		pb.lostChan <- uint64(cnt)
+	pb, ok := eventChannels.get(uint(uintptr(ctx))).(*PerfBuffer)
+	if !ok {
+		return
 	}
+
+	if pb.lostFn != nil {
+		pb.lostFn(int(cpu), uint64(cnt))
+		return
+	}
+
+	pb.deliverLost(uint64(cnt))
 }
 
 //export ringbufferCallback
 func ringbufferCallback(ctx unsafe.Pointer, data unsafe.Pointer, size C.int) C.int {
-	ch := eventChannels.get(uint(uintptr(ctx))).(chan []byte)
-	ch <- C.GoBytes(data, size)
+	rb, ok := eventChannels.get(uint(uintptr(ctx))).(*RingBuffer)
+	if !ok {
+		return C.int(0)
+	}
+
+	if rb.limiter != nil && !rb.limiter.Allow(unsafe.Slice((*byte)(data), int(size))) {
+		return C.int(0)
+	}
+
+	rb.deliver(C.GoBytes(data, size))
 
 	return C.int(0)
 }
@@ -90,6 +90,28 @@ void cgo_bpf_map__initial_value(struct bpf_map *map, void *value)
     memcpy(value, data, psize);
 }
 
+struct bpf_link *cgo_bpf_program__attach_raw_tracepoint_opts(struct bpf_program *prog,
+                                                             const char *tp_name,
+                                                             struct bpf_raw_tracepoint_opts *opts)
+{
+#if LIBBPFGO_LIBBPF_GEQ(1, 4)
+    return bpf_program__attach_raw_tracepoint_opts(prog, tp_name, opts);
+#else
+    errno = EOPNOTSUPP;
+    return NULL;
+#endif
+}
+
+struct bpf_link *cgo_bpf_program__attach_sockmap(struct bpf_program *prog, int map_fd)
+{
+#if LIBBPFGO_LIBBPF_GEQ(1, 5)
+    return bpf_program__attach_sockmap(prog, map_fd);
+#else
+    errno = EOPNOTSUPP;
+    return NULL;
+#endif
+}
+
 int cgo_bpf_prog_attach_cgroup_legacy(int prog_fd,   // eBPF program file descriptor
                                       int target_fd, // cgroup directory file descriptor
                                       int type)      // BPF_CGROUP_INET_{INGRESS,EGRESS}, ...
@@ -117,11 +139,99 @@ int cgo_bpf_prog_detach_cgroup_legacy(int prog_fd,   // eBPF program file descri
     return syscall(__NR_bpf, BPF_PROG_DETACH, &attr, sizeof(attr));
 }
 
+int cgo_setns(int fd, int nstype)
+{
+    return syscall(__NR_setns, fd, nstype);
+}
+
+// cgo_perf_event_open_tracepoint opens the perf event of the tracepoint id
+// for all processes, as libbpf does to attach tracepoints.
+int cgo_perf_event_open_tracepoint(__u64 id)
+{
+    struct perf_event_attr attr = {};
+
+    attr.type = PERF_TYPE_TRACEPOINT;
+    attr.size = sizeof(attr);
+    attr.config = id;
+
+    return syscall(__NR_perf_event_open, &attr, -1 /* pid */, 0 /* cpu */, -1 /* group_fd */, PERF_FLAG_FD_CLOEXEC);
+}
+
+int cgo_probe_sleepable(enum bpf_prog_type prog_type, char *log_buf, __u32 log_size)
+{
+    // r0 = 0; exit
+    struct bpf_insn insns[] = {
+        {.code = BPF_ALU64 | BPF_MOV | BPF_K, .dst_reg = BPF_REG_0, .imm = 0},
+        {.code = BPF_JMP | BPF_EXIT},
+    };
+    LIBBPF_OPTS(bpf_prog_load_opts, opts);
+    int fd;
+
+    opts.prog_flags = BPF_F_SLEEPABLE;
+    opts.log_buf = log_buf;
+    opts.log_size = log_size;
+    opts.log_level = 1;
+
+    fd = bpf_prog_load(prog_type, NULL, "GPL", insns, sizeof(insns) / sizeof(insns[0]), &opts);
+    if (fd < 0)
+        return fd;
+
+    close(fd);
+
+    return 0;
+}
+
+int cgo_probe_log_stats()
+{
+    // r0 = 0; exit
+    struct bpf_insn insns[] = {
+        {.code = BPF_ALU64 | BPF_MOV | BPF_K, .dst_reg = BPF_REG_0, .imm = 0},
+        {.code = BPF_JMP | BPF_EXIT},
+    };
+    LIBBPF_OPTS(bpf_prog_load_opts, opts);
+    char log_buf[256];
+    int fd;
+
+    opts.log_buf = log_buf;
+    opts.log_size = sizeof(log_buf);
+    opts.log_level = 4; // BPF_LOG_STATS
+
+    fd = bpf_prog_load(BPF_PROG_TYPE_SOCKET_FILTER, NULL, "GPL", insns, sizeof(insns) / sizeof(insns[0]), &opts);
+    if (fd < 0)
+        return fd;
+
+    close(fd);
+
+    return 0;
+}
+
+int cgo_load_socket_filter(const void *insns, __u32 insn_cnt, const char *license, char *log_buf, __u32 log_size)
+{
+    LIBBPF_OPTS(bpf_prog_load_opts, opts);
+
+    opts.log_buf = log_buf;
+    opts.log_size = log_size;
+    opts.log_level = log_size > 0 ? 1 : 0;
+
+    return bpf_prog_load(BPF_PROG_TYPE_SOCKET_FILTER, NULL, license, insns, insn_cnt, &opts);
+}
+
+int cgo_memfd_create(const char *name)
+{
+    // glibc only wraps memfd_create() since v2.27
+    int fd = syscall(SYS_memfd_create, name, MFD_CLOEXEC);
+    if (fd < 0)
+        return -errno;
+
+    return fd;
+}
+
 //
 // struct handlers
 //
 
-struct bpf_iter_attach_opts *cgo_bpf_iter_attach_opts_new(__u32 map_fd,
+struct bpf_iter_attach_opts *cgo_bpf_iter_attach_opts_new(enum cgo_iter_link_info_kind kind,
+                                                          __u32 map_fd,
                                                           enum bpf_cgroup_iter_order order,
                                                           __u32 cgroup_fd,
                                                           __u64 cgroup_id,
@@ -134,13 +244,24 @@ struct bpf_iter_attach_opts *cgo_bpf_iter_attach_opts_new(__u32 map_fd,
     if (!linfo)
         return NULL;
 
-    linfo->map.map_fd = map_fd;
-    linfo->cgroup.order = order;
-    linfo->cgroup.cgroup_fd = cgroup_fd;
-    linfo->cgroup.cgroup_id = cgroup_id;
-    linfo->task.tid = tid;
-    linfo->task.pid = pid;
-    linfo->task.pid_fd = pid_fd;
+    // the members share the union, only set the ones of the iterator kind
+    switch (kind) {
+        case CGO_ITER_LINK_INFO_MAP:
+            linfo->map.map_fd = map_fd;
+            break;
+        case CGO_ITER_LINK_INFO_CGROUP:
+            linfo->cgroup.order = order;
+            linfo->cgroup.cgroup_fd = cgroup_fd;
+            linfo->cgroup.cgroup_id = cgroup_id;
+            break;
+        case CGO_ITER_LINK_INFO_TASK:
+            linfo->task.tid = tid;
+            linfo->task.pid = pid;
+            linfo->task.pid_fd = pid_fd;
+            break;
+        default:
+            break;
+    }
 
     struct bpf_iter_attach_opts *opts;
     opts = calloc(1, sizeof(*opts));
@@ -211,7 +332,9 @@ void cgo_bpf_test_run_opts_free(struct bpf_test_run_opts *opts)
 struct bpf_object_open_opts *cgo_bpf_object_open_opts_new(const char *btf_file_path,
                                                           const char *kconfig_path,
                                                           const char *bpf_obj_name,
-                                                          __u32 kernel_log_level)
+                                                          __u32 kernel_log_level,
+                                                          char *kernel_log_buf,
+                                                          size_t kernel_log_size)
 {
     struct bpf_object_open_opts *opts;
     opts = calloc(1, sizeof(*opts));
@@ -223,6 +346,8 @@ struct bpf_object_open_opts *cgo_bpf_object_open_opts_new(const char *btf_file_p
     opts->kconfig = kconfig_path;
     opts->object_name = bpf_obj_name;
     opts->kernel_log_level = kernel_log_level;
+    opts->kernel_log_buf = kernel_log_buf;
+    opts->kernel_log_size = kernel_log_size;
 
     return opts;
 }
@@ -305,6 +430,64 @@ void cgo_bpf_map_info_free(struct bpf_map_info *info)
     free(info);
 }
 
+struct bpf_prog_info *cgo_bpf_prog_info_new()
+{
+    struct bpf_prog_info *info;
+    info = calloc(1, sizeof(*info));
+    if (!info)
+        return NULL;
+
+    return info;
+}
+
+__u32 cgo_bpf_prog_info_size()
+{
+    return sizeof(struct bpf_prog_info);
+}
+
+void cgo_bpf_prog_info_set_map_ids(struct bpf_prog_info *info, __u32 *map_ids, __u32 nr_map_ids)
+{
+    if (!info)
+        return;
+
+    info->map_ids = (__u64) (uintptr_t) map_ids;
+    info->nr_map_ids = nr_map_ids;
+}
+
+void cgo_bpf_prog_info_set_xlated_prog_insns(struct bpf_prog_info *info, void *insns, __u32 len)
+{
+    if (!info)
+        return;
+
+    info->xlated_prog_insns = (__u64) (uintptr_t) insns;
+    info->xlated_prog_len = len;
+}
+
+void cgo_bpf_prog_info_free(struct bpf_prog_info *info)
+{
+    free(info);
+}
+
+struct bpf_link_info *cgo_bpf_link_info_new()
+{
+    struct bpf_link_info *info;
+    info = calloc(1, sizeof(*info));
+    if (!info)
+        return NULL;
+
+    return info;
+}
+
+__u32 cgo_bpf_link_info_size()
+{
+    return sizeof(struct bpf_link_info);
+}
+
+void cgo_bpf_link_info_free(struct bpf_link_info *info)
+{
+    free(info);
+}
+
 struct bpf_tc_opts *cgo_bpf_tc_opts_new(
     int prog_fd, __u32 flags, __u32 prog_id, __u32 handle, __u32 priority)
 {
@@ -345,6 +528,28 @@ void cgo_bpf_tc_hook_free(struct bpf_tc_hook *hook)
     free(hook);
 }
 
+struct bpf_prog_query_opts *cgo_bpf_prog_query_opts_new(
+    __u32 *prog_ids, __u32 *prog_attach_flags, __u32 *link_ids, __u32 count)
+{
+    struct bpf_prog_query_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
+
+    opts->sz = sizeof(*opts);
+    opts->prog_ids = prog_ids;
+    opts->prog_attach_flags = prog_attach_flags;
+    opts->link_ids = link_ids;
+    opts->count = count;
+
+    return opts;
+}
+
+void cgo_bpf_prog_query_opts_free(struct bpf_prog_query_opts *opts)
+{
+    free(opts);
+}
+
 struct bpf_kprobe_opts *cgo_bpf_kprobe_opts_new(__u64 bpf_cookie,
                                                 size_t offset,
                                                 bool retprobe,
@@ -369,170 +574,890 @@ void cgo_bpf_kprobe_opts_free(struct bpf_kprobe_opts *opts)
     free(opts);
 }
 
-//
-// struct getters
-//
-
-// bpf_map_info
-
-__u32 cgo_bpf_map_info_type(struct bpf_map_info *info)
+struct bpf_kprobe_multi_opts *cgo_bpf_kprobe_multi_opts_new(const char **syms,
+                                                            const unsigned long *addrs,
+                                                            const __u64 *cookies,
+                                                            size_t cnt,
+                                                            bool retprobe,
+                                                            bool session)
 {
-    if (!info)
-        return 0;
+    struct bpf_kprobe_multi_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
 
-    return info->type;
+    opts->sz = sizeof(*opts);
+    opts->syms = syms;
+    opts->addrs = addrs;
+    opts->cookies = cookies;
+    opts->cnt = cnt;
+    opts->retprobe = retprobe;
+#if LIBBPFGO_LIBBPF_GEQ(1, 5)
+    opts->session = session;
+#else
+    if (session) {
+        free(opts);
+        errno = EOPNOTSUPP;
+        return NULL;
+    }
+#endif
+
+    return opts;
 }
 
-__u32 cgo_bpf_map_info_id(struct bpf_map_info *info)
+void cgo_bpf_kprobe_multi_opts_free(struct bpf_kprobe_multi_opts *opts)
 {
-    if (!info)
-        return 0;
-
-    return info->id;
+    free(opts);
 }
 
-__u32 cgo_bpf_map_info_key_size(struct bpf_map_info *info)
+struct bpf_ksyscall_opts *cgo_bpf_ksyscall_opts_new(__u64 bpf_cookie, bool retprobe)
 {
-    if (!info)
-        return 0;
+    struct bpf_ksyscall_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
 
-    return info->key_size;
+    opts->sz = sizeof(*opts);
+    opts->bpf_cookie = bpf_cookie;
+    opts->retprobe = retprobe;
+
+    return opts;
 }
 
-__u32 cgo_bpf_map_info_value_size(struct bpf_map_info *info)
+void cgo_bpf_ksyscall_opts_free(struct bpf_ksyscall_opts *opts)
 {
-    if (!info)
-        return 0;
-
-    return info->value_size;
+    free(opts);
 }
 
-__u32 cgo_bpf_map_info_max_entries(struct bpf_map_info *info)
+struct bpf_raw_tracepoint_opts *cgo_bpf_raw_tracepoint_opts_new(__u64 cookie)
 {
-    if (!info)
-        return 0;
+#if LIBBPFGO_LIBBPF_GEQ(1, 4)
+    struct bpf_raw_tracepoint_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
 
-    return info->max_entries;
+    opts->sz = sizeof(*opts);
+    opts->cookie = cookie;
+
+    return opts;
+#else
+    errno = EOPNOTSUPP;
+    return NULL;
+#endif
 }
 
-__u32 cgo_bpf_map_info_map_flags(struct bpf_map_info *info)
+void cgo_bpf_raw_tracepoint_opts_free(struct bpf_raw_tracepoint_opts *opts)
 {
-    if (!info)
-        return 0;
-
-    return info->map_flags;
+    free(opts);
 }
 
-char *cgo_bpf_map_info_name(struct bpf_map_info *info)
+struct bpf_uprobe_opts *cgo_bpf_uprobe_opts_new(const char *func_name,
+                                                __u64 bpf_cookie,
+                                                bool retprobe,
+                                                int attach_mode)
 {
-    if (!info)
+    struct bpf_uprobe_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
         return NULL;
 
-    return info->name;
-}
-
-__u32 cgo_bpf_map_info_ifindex(struct bpf_map_info *info)
-{
-    if (!info)
-        return 0;
+    opts->sz = sizeof(*opts);
+    opts->func_name = func_name;
+    opts->bpf_cookie = bpf_cookie;
+    opts->retprobe = retprobe;
+    opts->attach_mode = attach_mode;
 
-    return info->ifindex;
+    return opts;
 }
 
-__u32 cgo_bpf_map_info_btf_vmlinux_value_type_id(struct bpf_map_info *info)
+void cgo_bpf_uprobe_opts_free(struct bpf_uprobe_opts *opts)
 {
-    if (!info)
-        return 0;
-
-    return info->btf_vmlinux_value_type_id;
+    free(opts);
 }
 
-__u64 cgo_bpf_map_info_netns_dev(struct bpf_map_info *info)
+struct bpf_uprobe_multi_opts *cgo_bpf_uprobe_multi_opts_new(const char **syms,
+                                                            const unsigned long *offsets,
+                                                            const unsigned long *ref_ctr_offsets,
+                                                            const __u64 *cookies,
+                                                            size_t cnt,
+                                                            bool retprobe)
 {
-    if (!info)
-        return 0;
+    struct bpf_uprobe_multi_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
 
-    return info->netns_dev;
+    opts->sz = sizeof(*opts);
+    opts->syms = syms;
+    opts->offsets = offsets;
+    opts->ref_ctr_offsets = ref_ctr_offsets;
+    opts->cookies = cookies;
+    opts->cnt = cnt;
+    opts->retprobe = retprobe;
+
+    return opts;
 }
 
-__u64 cgo_bpf_map_info_netns_ino(struct bpf_map_info *info)
+void cgo_bpf_uprobe_multi_opts_free(struct bpf_uprobe_multi_opts *opts)
 {
-    if (!info)
-        return 0;
-
-    return info->netns_ino;
+    free(opts);
 }
 
-__u32 cgo_bpf_map_info_btf_id(struct bpf_map_info *info)
+struct bpf_usdt_opts *cgo_bpf_usdt_opts_new(__u64 usdt_cookie)
 {
-    if (!info)
-        return 0;
+    struct bpf_usdt_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
 
-    return info->btf_id;
+    opts->sz = sizeof(*opts);
+    opts->usdt_cookie = usdt_cookie;
+
+    return opts;
 }
 
-__u32 cgo_bpf_map_info_btf_key_type_id(struct bpf_map_info *info)
+void cgo_bpf_usdt_opts_free(struct bpf_usdt_opts *opts)
 {
-    if (!info)
-        return 0;
-
-    return info->btf_key_type_id;
+    free(opts);
 }
 
-__u32 cgo_bpf_map_info_btf_value_type_id(struct bpf_map_info *info)
+struct bpf_tracepoint_opts *cgo_bpf_tracepoint_opts_new(__u64 bpf_cookie)
 {
-    if (!info)
-        return 0;
+    struct bpf_tracepoint_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
 
-    return info->btf_value_type_id;
+    opts->sz = sizeof(*opts);
+    opts->bpf_cookie = bpf_cookie;
+
+    return opts;
 }
 
-__u64 cgo_bpf_map_info_map_extra(struct bpf_map_info *info)
+void cgo_bpf_tracepoint_opts_free(struct bpf_tracepoint_opts *opts)
 {
-    if (!info)
-        return 0;
-
-    return info->map_extra;
+    free(opts);
 }
 
-// bpf_tc_opts
-
-int cgo_bpf_tc_opts_prog_fd(struct bpf_tc_opts *opts)
+struct bpf_perf_event_opts *cgo_bpf_perf_event_opts_new(__u64 bpf_cookie, bool force_ioctl_attach)
 {
+    struct bpf_perf_event_opts *opts;
+    opts = calloc(1, sizeof(*opts));
     if (!opts)
-        return 0;
+        return NULL;
 
-    return opts->prog_fd;
+    opts->sz = sizeof(*opts);
+    opts->bpf_cookie = bpf_cookie;
+    opts->force_ioctl_attach = force_ioctl_attach;
+
+    return opts;
 }
 
-__u32 cgo_bpf_tc_opts_flags(struct bpf_tc_opts *opts)
+void cgo_bpf_perf_event_opts_free(struct bpf_perf_event_opts *opts)
 {
-    if (!opts)
-        return 0;
-
-    return opts->flags;
+    free(opts);
 }
 
-__u32 cgo_bpf_tc_opts_prog_id(struct bpf_tc_opts *opts)
+struct bpf_obj_get_opts *cgo_bpf_obj_get_opts_new(__u32 file_flags)
 {
+    struct bpf_obj_get_opts *opts;
+    opts = calloc(1, sizeof(*opts));
     if (!opts)
-        return 0;
+        return NULL;
 
-    return opts->prog_id;
+    opts->sz = sizeof(*opts);
+    opts->file_flags = file_flags;
+
+    return opts;
 }
 
-__u32 cgo_bpf_tc_opts_handle(struct bpf_tc_opts *opts)
+void cgo_bpf_obj_get_opts_free(struct bpf_obj_get_opts *opts)
 {
-    if (!opts)
-        return 0;
-
-    return opts->handle;
+    free(opts);
 }
 
-__u32 cgo_bpf_tc_opts_priority(struct bpf_tc_opts *opts)
+struct bpf_tcx_opts *cgo_bpf_tcx_opts_new(__u32 flags,
+                                          __u32 relative_fd,
+                                          __u32 relative_id,
+                                          __u64 expected_revision)
 {
+    struct bpf_tcx_opts *opts;
+    opts = calloc(1, sizeof(*opts));
     if (!opts)
-        return 0;
+        return NULL;
 
-    return opts->priority;
+    opts->sz = sizeof(*opts);
+    opts->flags = flags;
+    opts->relative_fd = relative_fd;
+    opts->relative_id = relative_id;
+    opts->expected_revision = expected_revision;
+
+    return opts;
+}
+
+void cgo_bpf_tcx_opts_free(struct bpf_tcx_opts *opts)
+{
+    free(opts);
+}
+
+struct bpf_cgroup_opts *cgo_bpf_cgroup_opts_new(__u32 flags,
+                                                __u32 relative_fd,
+                                                __u32 relative_id,
+                                                __u64 expected_revision)
+{
+    struct bpf_cgroup_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
+
+    opts->sz = sizeof(*opts);
+    opts->flags = flags;
+    opts->relative_fd = relative_fd;
+    opts->relative_id = relative_id;
+    opts->expected_revision = expected_revision;
+
+    return opts;
+}
+
+void cgo_bpf_cgroup_opts_free(struct bpf_cgroup_opts *opts)
+{
+    free(opts);
+}
+
+struct bpf_xdp_query_opts *cgo_bpf_xdp_query_opts_new()
+{
+    struct bpf_xdp_query_opts *opts;
+    opts = calloc(1, sizeof(*opts));
+    if (!opts)
+        return NULL;
+
+    opts->sz = sizeof(*opts);
+
+    return opts;
+}
+
+void cgo_bpf_xdp_query_opts_free(struct bpf_xdp_query_opts *opts)
+{
+    free(opts);
+}
+
+//
+// struct getters
+//
+
+// bpf_map_info
+
+__u32 cgo_bpf_map_info_type(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->type;
+}
+
+__u32 cgo_bpf_map_info_id(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->id;
+}
+
+__u32 cgo_bpf_map_info_key_size(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->key_size;
+}
+
+__u32 cgo_bpf_map_info_value_size(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->value_size;
+}
+
+__u32 cgo_bpf_map_info_max_entries(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->max_entries;
+}
+
+__u32 cgo_bpf_map_info_map_flags(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->map_flags;
+}
+
+char *cgo_bpf_map_info_name(struct bpf_map_info *info)
+{
+    if (!info)
+        return NULL;
+
+    return info->name;
+}
+
+__u32 cgo_bpf_map_info_ifindex(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->ifindex;
+}
+
+__u32 cgo_bpf_map_info_btf_vmlinux_value_type_id(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->btf_vmlinux_value_type_id;
+}
+
+__u64 cgo_bpf_map_info_netns_dev(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->netns_dev;
+}
+
+__u64 cgo_bpf_map_info_netns_ino(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->netns_ino;
+}
+
+__u32 cgo_bpf_map_info_btf_id(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->btf_id;
+}
+
+__u32 cgo_bpf_map_info_btf_key_type_id(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->btf_key_type_id;
+}
+
+__u32 cgo_bpf_map_info_btf_value_type_id(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->btf_value_type_id;
+}
+
+__u64 cgo_bpf_map_info_map_extra(struct bpf_map_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->map_extra;
+}
+
+// bpf_prog_info
+
+__u32 cgo_bpf_prog_info_type(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->type;
+}
+
+__u32 cgo_bpf_prog_info_id(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->id;
+}
+
+unsigned char *cgo_bpf_prog_info_tag(struct bpf_prog_info *info)
+{
+    if (!info)
+        return NULL;
+
+    return info->tag;
+}
+
+char *cgo_bpf_prog_info_name(struct bpf_prog_info *info)
+{
+    if (!info)
+        return NULL;
+
+    return info->name;
+}
+
+__u64 cgo_bpf_prog_info_load_time(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->load_time;
+}
+
+__u32 cgo_bpf_prog_info_created_by_uid(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->created_by_uid;
+}
+
+__u32 cgo_bpf_prog_info_nr_map_ids(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->nr_map_ids;
+}
+
+__u32 cgo_bpf_prog_info_jited_prog_len(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->jited_prog_len;
+}
+
+__u32 cgo_bpf_prog_info_xlated_prog_len(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->xlated_prog_len;
+}
+
+bool cgo_bpf_prog_info_gpl_compatible(struct bpf_prog_info *info)
+{
+    if (!info)
+        return false;
+
+    return info->gpl_compatible;
+}
+
+__u32 cgo_bpf_prog_info_btf_id(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->btf_id;
+}
+
+__u64 cgo_bpf_prog_info_run_time_ns(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->run_time_ns;
+}
+
+__u64 cgo_bpf_prog_info_run_cnt(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->run_cnt;
+}
+
+__u64 cgo_bpf_prog_info_recursion_misses(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->recursion_misses;
+}
+
+__u32 cgo_bpf_prog_info_verified_insns(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->verified_insns;
+}
+
+__u32 cgo_bpf_prog_info_attach_btf_obj_id(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->attach_btf_obj_id;
+}
+
+__u32 cgo_bpf_prog_info_attach_btf_id(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->attach_btf_id;
+}
+
+__u32 cgo_bpf_prog_info_ifindex(struct bpf_prog_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->ifindex;
+}
+
+// bpf_link_info
+
+__u32 cgo_bpf_link_info_type(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->type;
+}
+
+__u32 cgo_bpf_link_info_id(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->id;
+}
+
+__u32 cgo_bpf_link_info_prog_id(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->prog_id;
+}
+
+__u32 cgo_bpf_link_info_attach_type(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    switch (info->type) {
+        case BPF_LINK_TYPE_TRACING:
+            return info->tracing.attach_type;
+        case BPF_LINK_TYPE_CGROUP:
+            return info->cgroup.attach_type;
+        case BPF_LINK_TYPE_NETNS:
+            return info->netns.attach_type;
+        default:
+            return 0;
+    }
+}
+
+__u32 cgo_bpf_link_info_target_obj_id(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->type == BPF_LINK_TYPE_TRACING ? info->tracing.target_obj_id : 0;
+}
+
+__u32 cgo_bpf_link_info_target_btf_id(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->type == BPF_LINK_TYPE_TRACING ? info->tracing.target_btf_id : 0;
+}
+
+__u64 cgo_bpf_link_info_cgroup_id(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->type == BPF_LINK_TYPE_CGROUP ? info->cgroup.cgroup_id : 0;
+}
+
+__u32 cgo_bpf_link_info_netns_ino(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    return info->type == BPF_LINK_TYPE_NETNS ? info->netns.netns_ino : 0;
+}
+
+__u32 cgo_bpf_link_info_ifindex(struct bpf_link_info *info)
+{
+    if (!info)
+        return 0;
+
+    switch (info->type) {
+        case BPF_LINK_TYPE_XDP:
+            return info->xdp.ifindex;
+        case BPF_LINK_TYPE_TCX:
+            return info->tcx.ifindex;
+        default:
+            return 0;
+    }
+}
+
+// bpf_tc_opts
+
+int cgo_bpf_tc_opts_prog_fd(struct bpf_tc_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->prog_fd;
+}
+
+__u32 cgo_bpf_tc_opts_flags(struct bpf_tc_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->flags;
+}
+
+__u32 cgo_bpf_tc_opts_prog_id(struct bpf_tc_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->prog_id;
+}
+
+__u32 cgo_bpf_tc_opts_handle(struct bpf_tc_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->handle;
+}
+
+__u32 cgo_bpf_tc_opts_priority(struct bpf_tc_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->priority;
+}
+
+// bpf_prog_query_opts
+
+__u32 cgo_bpf_prog_query_opts_count(struct bpf_prog_query_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->count;
+}
+
+__u64 cgo_bpf_prog_query_opts_revision(struct bpf_prog_query_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->revision;
+}
+
+// bpf_xdp_query_opts
+
+__u32 cgo_bpf_xdp_query_opts_prog_id(struct bpf_xdp_query_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->prog_id;
+}
+
+__u32 cgo_bpf_xdp_query_opts_drv_prog_id(struct bpf_xdp_query_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->drv_prog_id;
+}
+
+__u32 cgo_bpf_xdp_query_opts_skb_prog_id(struct bpf_xdp_query_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->skb_prog_id;
+}
+
+__u32 cgo_bpf_xdp_query_opts_hw_prog_id(struct bpf_xdp_query_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->hw_prog_id;
+}
+
+__u8 cgo_bpf_xdp_query_opts_attach_mode(struct bpf_xdp_query_opts *opts)
+{
+    if (!opts)
+        return 0;
+
+    return opts->attach_mode;
+}
+
+// btf_type
+
+const char *cgo_btf_type_name(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t)
+        return NULL;
+
+    return btf__name_by_offset(btf, t->name_off);
+}
+
+__u32 cgo_btf_type_kind(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t)
+        return BTF_KIND_UNKN;
+
+    return btf_kind(t);
+}
+
+// cgo_btf_type_ref returns the type referenced by typedefs and modifiers, or 0.
+__u32 cgo_btf_type_ref(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t)
+        return 0;
+
+    switch (btf_kind(t)) {
+        case BTF_KIND_TYPEDEF:
+        case BTF_KIND_CONST:
+        case BTF_KIND_VOLATILE:
+        case BTF_KIND_RESTRICT:
+        case BTF_KIND_TYPE_TAG:
+            return t->type;
+        default:
+            return 0;
+    }
+}
+
+__u16 cgo_btf_type_vlen(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t)
+        return 0;
+
+    return btf_vlen(t);
+}
+
+const char *cgo_btf_member_name(const struct btf *btf, __u32 type_id, __u16 idx)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_composite(t) || idx >= btf_vlen(t))
+        return NULL;
+
+    return btf__name_by_offset(btf, btf_members(t)[idx].name_off);
+}
+
+__u32 cgo_btf_member_type(const struct btf *btf, __u32 type_id, __u16 idx)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_composite(t) || idx >= btf_vlen(t))
+        return 0;
+
+    return btf_members(t)[idx].type;
+}
+
+__u32 cgo_btf_member_bit_offset(const struct btf *btf, __u32 type_id, __u16 idx)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_composite(t) || idx >= btf_vlen(t))
+        return 0;
+
+    return btf_member_bit_offset(t, idx);
+}
+
+__u32 cgo_btf_member_bitfield_size(const struct btf *btf, __u32 type_id, __u16 idx)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_composite(t) || idx >= btf_vlen(t))
+        return 0;
+
+    return btf_member_bitfield_size(t, idx);
+}
+
+__u32 cgo_btf_int_encoding(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_int(t))
+        return 0;
+
+    return btf_int_encoding(t);
+}
+
+__u32 cgo_btf_var_type(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_var(t))
+        return 0;
+
+    return t->type;
+}
+
+__u32 cgo_btf_var_secinfo_type(const struct btf *btf, __u32 type_id, __u16 idx)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_datasec(t) || idx >= btf_vlen(t))
+        return 0;
+
+    return btf_var_secinfos(t)[idx].type;
+}
+
+__u32 cgo_btf_var_secinfo_offset(const struct btf *btf, __u32 type_id, __u16 idx)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_datasec(t) || idx >= btf_vlen(t))
+        return 0;
+
+    return btf_var_secinfos(t)[idx].offset;
+}
+
+__u32 cgo_btf_var_secinfo_size(const struct btf *btf, __u32 type_id, __u16 idx)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_datasec(t) || idx >= btf_vlen(t))
+        return 0;
+
+    return btf_var_secinfos(t)[idx].size;
+}
+
+__u32 cgo_btf_ptr_type(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_ptr(t))
+        return 0;
+
+    return t->type;
+}
+
+__u32 cgo_btf_array_type(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_array(t))
+        return 0;
+
+    return btf_array(t)->type;
+}
+
+__u32 cgo_btf_array_nelems(const struct btf *btf, __u32 type_id)
+{
+    const struct btf_type *t = btf__type_by_id(btf, type_id);
+    if (!t || !btf_is_array(t))
+        return 0;
+
+    return btf_array(t)->nelems;
 }
@@ -31,6 +31,34 @@ func LibbpfVersionString() string {
 	return fmt.Sprintf("v%d.%d", MajorVersion(), MinorVersion())
 }
 
+// LibbpfRuntimeVersionString returns the string representation of the version
+// of the libbpf library loaded at runtime, which may be later than the one
+// libbpfgo was built against when linked dynamically.
+func LibbpfRuntimeVersionString() string {
+	return fmt.Sprintf("v%d.%d", C.libbpf_major_version(), C.libbpf_minor_version())
+}
+
+// The features of libbpfgo depending on the libbpf version it was built
+// against, so that applications detect them instead of failing to attach.
+// They tell nothing about the support of the kernel.
+var (
+	// HasKprobeSession reports whether AttachKprobeSession() is available
+	// (libbpf v1.5).
+	HasKprobeSession = libbpfVersionAtLeast(MajorVersion(), MinorVersion(), 1, 5)
+	// HasRawTracepointCookie reports whether AttachRawTracepointOpts() accepts
+	// a cookie (libbpf v1.4).
+	HasRawTracepointCookie = libbpfVersionAtLeast(MajorVersion(), MinorVersion(), 1, 4)
+	// HasSockMapLink reports whether AttachSockMap() attaches with a BPF link
+	// (libbpf v1.5), instead of falling back to BPF_PROG_ATTACH.
+	HasSockMapLink = libbpfVersionAtLeast(MajorVersion(), MinorVersion(), 1, 5)
+)
+
+// libbpfVersionAtLeast reports whether the version major.minor is at least
+// wantMajor.wantMinor.
+func libbpfVersionAtLeast(major, minor, wantMajor, wantMinor int) bool {
+	return major > wantMajor || (major == wantMajor && minor >= wantMinor)
+}
+
 //
 // Strict Mode
 //
@@ -15,8 +15,34 @@
 #include <unistd.h>
 
 #include <bpf/bpf.h>
+#include <bpf/btf.h>
 #include <bpf/libbpf.h>
 #include <linux/bpf.h> // uapi
+#include <linux/if_link.h> // uapi
+#include <linux/memfd.h> // uapi
+#include <linux/perf_event.h> // uapi
+#include <linux/pkt_cls.h> // uapi
+
+// libbpfgo builds against libbpf v1.3 or later, the APIs of later versions
+// being disabled when missing (see the Has* flags of the Go package).
+#define LIBBPFGO_LIBBPF_GEQ(major, minor) \
+    (LIBBPF_MAJOR_VERSION > (major) || (LIBBPF_MAJOR_VERSION == (major) && LIBBPF_MINOR_VERSION >= (minor)))
+
+#if !LIBBPFGO_LIBBPF_GEQ(1, 3)
+    #error "libbpfgo requires libbpf v1.3 or later"
+#endif
+
+// The attach types of kernels later than the headers of libbpf v1.3, with
+// their uapi values when missing.
+#if LIBBPFGO_LIBBPF_GEQ(1, 5)
+    #define CGO_BPF_NETKIT_PRIMARY BPF_NETKIT_PRIMARY
+    #define CGO_BPF_NETKIT_PEER BPF_NETKIT_PEER
+    #define CGO_BPF_TRACE_KPROBE_SESSION BPF_TRACE_KPROBE_SESSION
+#else
+    #define CGO_BPF_NETKIT_PRIMARY 54
+    #define CGO_BPF_NETKIT_PEER 55
+    #define CGO_BPF_TRACE_KPROBE_SESSION 56
+#endif
 
 void cgo_libbpf_set_print_fn();
 
@@ -25,14 +51,39 @@ struct perf_buffer *cgo_init_perf_buf(int map_fd, int page_cnt, uintptr_t ctx);
 
 void cgo_bpf_map__initial_value(struct bpf_map *map, void *value);
 
+// Declared at file scope, not scoped to the prototype: libbpf v1.3 does not
+// define it.
+struct bpf_raw_tracepoint_opts;
+
+struct bpf_link *cgo_bpf_program__attach_raw_tracepoint_opts(struct bpf_program *prog,
+                                                             const char *tp_name,
+                                                             struct bpf_raw_tracepoint_opts *opts);
+struct bpf_link *cgo_bpf_program__attach_sockmap(struct bpf_program *prog, int map_fd);
+
 int cgo_bpf_prog_attach_cgroup_legacy(int prog_fd, int target_fd, int type);
 int cgo_bpf_prog_detach_cgroup_legacy(int prog_fd, int target_fd, int type);
 
+int cgo_setns(int fd, int nstype);
+int cgo_perf_event_open_tracepoint(__u64 id);
+
+int cgo_probe_sleepable(enum bpf_prog_type prog_type, char *log_buf, __u32 log_size);
+int cgo_probe_log_stats();
+int cgo_load_socket_filter(const void *insns, __u32 insn_cnt, const char *license, char *log_buf, __u32 log_size);
+int cgo_memfd_create(const char *name);
+
 //
 // struct handlers
 //
 
-struct bpf_iter_attach_opts *cgo_bpf_iter_attach_opts_new(__u32 map_fd,
+enum cgo_iter_link_info_kind {
+    CGO_ITER_LINK_INFO_NONE,
+    CGO_ITER_LINK_INFO_MAP,
+    CGO_ITER_LINK_INFO_CGROUP,
+    CGO_ITER_LINK_INFO_TASK,
+};
+
+struct bpf_iter_attach_opts *cgo_bpf_iter_attach_opts_new(enum cgo_iter_link_info_kind kind,
+                                                          __u32 map_fd,
                                                           enum bpf_cgroup_iter_order order,
                                                           __u32 cgroup_fd,
                                                           __u64 cgroup_id,
@@ -58,7 +109,9 @@ void cgo_bpf_test_run_opts_free(struct bpf_test_run_opts *opts);
 struct bpf_object_open_opts *cgo_bpf_object_open_opts_new(const char *btf_file_path,
                                                           const char *kconfig_path,
                                                           const char *bpf_obj_name,
-                                                          __u32 kernel_log_level);
+                                                          __u32 kernel_log_level,
+                                                          char *kernel_log_buf,
+                                                          size_t kernel_log_size);
 void cgo_bpf_object_open_opts_free(struct bpf_object_open_opts *opts);
 
 struct bpf_map_create_opts *cgo_bpf_map_create_opts_new(__u32 btf_fd,
@@ -79,6 +132,16 @@ struct bpf_map_info *cgo_bpf_map_info_new();
 __u32 cgo_bpf_map_info_size();
 void cgo_bpf_map_info_free(struct bpf_map_info *info);
 
+struct bpf_prog_info *cgo_bpf_prog_info_new();
+__u32 cgo_bpf_prog_info_size();
+void cgo_bpf_prog_info_set_map_ids(struct bpf_prog_info *info, __u32 *map_ids, __u32 nr_map_ids);
+void cgo_bpf_prog_info_set_xlated_prog_insns(struct bpf_prog_info *info, void *insns, __u32 len);
+void cgo_bpf_prog_info_free(struct bpf_prog_info *info);
+
+struct bpf_link_info *cgo_bpf_link_info_new();
+__u32 cgo_bpf_link_info_size();
+void cgo_bpf_link_info_free(struct bpf_link_info *info);
+
 struct bpf_tc_opts *cgo_bpf_tc_opts_new(
     int prog_fd, __u32 flags, __u32 prog_id, __u32 handle, __u32 priority);
 void cgo_bpf_tc_opts_free(struct bpf_tc_opts *opts);
@@ -86,12 +149,71 @@ void cgo_bpf_tc_opts_free(struct bpf_tc_opts *opts);
 struct bpf_tc_hook *cgo_bpf_tc_hook_new();
 void cgo_bpf_tc_hook_free(struct bpf_tc_hook *hook);
 
+struct bpf_prog_query_opts *cgo_bpf_prog_query_opts_new(
+    __u32 *prog_ids, __u32 *prog_attach_flags, __u32 *link_ids, __u32 count);
+void cgo_bpf_prog_query_opts_free(struct bpf_prog_query_opts *opts);
+
 struct bpf_kprobe_opts *cgo_bpf_kprobe_opts_new(__u64 bpf_cookie,
                                                 size_t offset,
                                                 bool retprobe,
                                                 int attach_mode);
 void cgo_bpf_kprobe_opts_free(struct bpf_kprobe_opts *opts);
 
+struct bpf_kprobe_multi_opts *cgo_bpf_kprobe_multi_opts_new(const char **syms,
+                                                            const unsigned long *addrs,
+                                                            const __u64 *cookies,
+                                                            size_t cnt,
+                                                            bool retprobe,
+                                                            bool session);
+void cgo_bpf_kprobe_multi_opts_free(struct bpf_kprobe_multi_opts *opts);
+
+struct bpf_ksyscall_opts *cgo_bpf_ksyscall_opts_new(__u64 bpf_cookie, bool retprobe);
+void cgo_bpf_ksyscall_opts_free(struct bpf_ksyscall_opts *opts);
+
+struct bpf_raw_tracepoint_opts *cgo_bpf_raw_tracepoint_opts_new(__u64 cookie);
+void cgo_bpf_raw_tracepoint_opts_free(struct bpf_raw_tracepoint_opts *opts);
+
+struct bpf_uprobe_opts *cgo_bpf_uprobe_opts_new(const char *func_name,
+                                                __u64 bpf_cookie,
+                                                bool retprobe,
+                                                int attach_mode);
+void cgo_bpf_uprobe_opts_free(struct bpf_uprobe_opts *opts);
+
+struct bpf_uprobe_multi_opts *cgo_bpf_uprobe_multi_opts_new(const char **syms,
+                                                            const unsigned long *offsets,
+                                                            const unsigned long *ref_ctr_offsets,
+                                                            const __u64 *cookies,
+                                                            size_t cnt,
+                                                            bool retprobe);
+void cgo_bpf_uprobe_multi_opts_free(struct bpf_uprobe_multi_opts *opts);
+
+struct bpf_usdt_opts *cgo_bpf_usdt_opts_new(__u64 usdt_cookie);
+void cgo_bpf_usdt_opts_free(struct bpf_usdt_opts *opts);
+
+struct bpf_tracepoint_opts *cgo_bpf_tracepoint_opts_new(__u64 bpf_cookie);
+void cgo_bpf_tracepoint_opts_free(struct bpf_tracepoint_opts *opts);
+
+struct bpf_perf_event_opts *cgo_bpf_perf_event_opts_new(__u64 bpf_cookie, bool force_ioctl_attach);
+void cgo_bpf_perf_event_opts_free(struct bpf_perf_event_opts *opts);
+
+struct bpf_obj_get_opts *cgo_bpf_obj_get_opts_new(__u32 file_flags);
+void cgo_bpf_obj_get_opts_free(struct bpf_obj_get_opts *opts);
+
+struct bpf_tcx_opts *cgo_bpf_tcx_opts_new(__u32 flags,
+                                          __u32 relative_fd,
+                                          __u32 relative_id,
+                                          __u64 expected_revision);
+void cgo_bpf_tcx_opts_free(struct bpf_tcx_opts *opts);
+
+struct bpf_cgroup_opts *cgo_bpf_cgroup_opts_new(__u32 flags,
+                                                __u32 relative_fd,
+                                                __u32 relative_id,
+                                                __u64 expected_revision);
+void cgo_bpf_cgroup_opts_free(struct bpf_cgroup_opts *opts);
+
+struct bpf_xdp_query_opts *cgo_bpf_xdp_query_opts_new();
+void cgo_bpf_xdp_query_opts_free(struct bpf_xdp_query_opts *opts);
+
 //
 // struct getters
 //
@@ -114,6 +236,39 @@ __u32 cgo_bpf_map_info_btf_key_type_id(struct bpf_map_info *info);
 __u32 cgo_bpf_map_info_btf_value_type_id(struct bpf_map_info *info);
 __u64 cgo_bpf_map_info_map_extra(struct bpf_map_info *info);
 
+// bpf_prog_info
+
+__u32 cgo_bpf_prog_info_type(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_id(struct bpf_prog_info *info);
+unsigned char *cgo_bpf_prog_info_tag(struct bpf_prog_info *info);
+char *cgo_bpf_prog_info_name(struct bpf_prog_info *info);
+__u64 cgo_bpf_prog_info_load_time(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_created_by_uid(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_nr_map_ids(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_jited_prog_len(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_xlated_prog_len(struct bpf_prog_info *info);
+bool cgo_bpf_prog_info_gpl_compatible(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_btf_id(struct bpf_prog_info *info);
+__u64 cgo_bpf_prog_info_run_time_ns(struct bpf_prog_info *info);
+__u64 cgo_bpf_prog_info_run_cnt(struct bpf_prog_info *info);
+__u64 cgo_bpf_prog_info_recursion_misses(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_verified_insns(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_attach_btf_obj_id(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_attach_btf_id(struct bpf_prog_info *info);
+__u32 cgo_bpf_prog_info_ifindex(struct bpf_prog_info *info);
+
+// bpf_link_info
+
+__u32 cgo_bpf_link_info_type(struct bpf_link_info *info);
+__u32 cgo_bpf_link_info_id(struct bpf_link_info *info);
+__u32 cgo_bpf_link_info_prog_id(struct bpf_link_info *info);
+__u32 cgo_bpf_link_info_attach_type(struct bpf_link_info *info);
+__u32 cgo_bpf_link_info_target_obj_id(struct bpf_link_info *info);
+__u32 cgo_bpf_link_info_target_btf_id(struct bpf_link_info *info);
+__u64 cgo_bpf_link_info_cgroup_id(struct bpf_link_info *info);
+__u32 cgo_bpf_link_info_netns_ino(struct bpf_link_info *info);
+__u32 cgo_bpf_link_info_ifindex(struct bpf_link_info *info);
+
 // bpf_tc_opts
 
 int cgo_bpf_tc_opts_prog_fd(struct bpf_tc_opts *opts);
@@ -122,4 +277,36 @@ __u32 cgo_bpf_tc_opts_prog_id(struct bpf_tc_opts *opts);
 __u32 cgo_bpf_tc_opts_handle(struct bpf_tc_opts *opts);
 __u32 cgo_bpf_tc_opts_priority(struct bpf_tc_opts *opts);
 
+// bpf_prog_query_opts
+
+__u32 cgo_bpf_prog_query_opts_count(struct bpf_prog_query_opts *opts);
+__u64 cgo_bpf_prog_query_opts_revision(struct bpf_prog_query_opts *opts);
+
+// bpf_xdp_query_opts
+
+__u32 cgo_bpf_xdp_query_opts_prog_id(struct bpf_xdp_query_opts *opts);
+__u32 cgo_bpf_xdp_query_opts_drv_prog_id(struct bpf_xdp_query_opts *opts);
+__u32 cgo_bpf_xdp_query_opts_skb_prog_id(struct bpf_xdp_query_opts *opts);
+__u32 cgo_bpf_xdp_query_opts_hw_prog_id(struct bpf_xdp_query_opts *opts);
+__u8 cgo_bpf_xdp_query_opts_attach_mode(struct bpf_xdp_query_opts *opts);
+
+// btf_type
+
+const char *cgo_btf_type_name(const struct btf *btf, __u32 type_id);
+__u32 cgo_btf_type_kind(const struct btf *btf, __u32 type_id);
+__u32 cgo_btf_type_ref(const struct btf *btf, __u32 type_id);
+__u16 cgo_btf_type_vlen(const struct btf *btf, __u32 type_id);
+const char *cgo_btf_member_name(const struct btf *btf, __u32 type_id, __u16 idx);
+__u32 cgo_btf_member_type(const struct btf *btf, __u32 type_id, __u16 idx);
+__u32 cgo_btf_member_bit_offset(const struct btf *btf, __u32 type_id, __u16 idx);
+__u32 cgo_btf_member_bitfield_size(const struct btf *btf, __u32 type_id, __u16 idx);
+__u32 cgo_btf_int_encoding(const struct btf *btf, __u32 type_id);
+__u32 cgo_btf_var_type(const struct btf *btf, __u32 type_id);
+__u32 cgo_btf_var_secinfo_type(const struct btf *btf, __u32 type_id, __u16 idx);
+__u32 cgo_btf_var_secinfo_offset(const struct btf *btf, __u32 type_id, __u16 idx);
+__u32 cgo_btf_var_secinfo_size(const struct btf *btf, __u32 type_id, __u16 idx);
+__u32 cgo_btf_ptr_type(const struct btf *btf, __u32 type_id);
+__u32 cgo_btf_array_type(const struct btf *btf, __u32 type_id);
+__u32 cgo_btf_array_nelems(const struct btf *btf, __u32 type_id);
+
 #endif
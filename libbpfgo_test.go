@@ -0,0 +1,15 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLibbpfVersionAtLeast(t *testing.T) {
+	assert.True(t, libbpfVersionAtLeast(1, 5, 1, 3))
+	assert.True(t, libbpfVersionAtLeast(1, 3, 1, 3))
+	assert.True(t, libbpfVersionAtLeast(2, 0, 1, 5))
+	assert.False(t, libbpfVersionAtLeast(1, 2, 1, 3))
+	assert.False(t, libbpfVersionAtLeast(0, 8, 1, 0))
+}
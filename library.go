@@ -0,0 +1,408 @@
+package libbpfgo
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//
+// Shared library resolution
+//
+// Uprobes and USDT probes are attached to a file, so probing a shared library
+// takes its path, which differs between distributions. The uprobe and USDT
+// attach methods also take a library name ("libssl", "libssl.so.3", "ssl"),
+// resolved like the dynamic loader does, through the ld.so cache.
+//
+// Containers ship their own copy of the libraries, which a probe of the host
+// copy does not trace. LibraryCopies() finds the copies mapped by the running
+// processes, through /proc/<pid>/maps, and the *Library attach methods probe
+// all of them at once, for the "trace libssl everywhere" workflow:
+//
+//	links, err := prog.AttachUprobeLibrary("libssl", WithFunc("SSL_write"))
+//
+// Only the processes running at attach time are scanned: the copies of
+// containers started afterwards are not probed.
+//
+
+const (
+	ldCachePath = "/etc/ld.so.cache"
+	procDir     = "/proc"
+
+	ldCacheMagicOld = "ld.so-1.7.0"
+	ldCacheMagicNew = "glibc-ld.so.cache1.1"
+
+	ldCacheHeaderOldSize = 16
+	ldCacheEntryOldSize  = 12
+	ldCacheHeaderNewSize = 48
+	ldCacheEntryNewSize  = 24
+)
+
+// ld.so cache entry flags
+const (
+	ldCacheFlagTypeMask     = 0x00ff
+	ldCacheFlagELF          = 0x0001
+	ldCacheFlagELFLibc6     = 0x0003
+	ldCacheFlagRequiredMask = 0xff00
+)
+
+// libraryArch tells apart the libraries of an architecture from the ones of
+// the other ABIs installed alongside (i386 on x86_64, armhf on arm64...).
+type libraryArch struct {
+	// cacheFlags are the ld.so cache flags of the ABI (FLAG_X8664_LIB64...)
+	cacheFlags []uint32
+	machine    elf.Machine
+	class      elf.Class
+	// triplet is the Debian multiarch directory of the libraries
+	triplet string
+}
+
+var libraryArchs = map[string]libraryArch{
+	"386":     {[]uint32{0x0000}, elf.EM_386, elf.ELFCLASS32, "i386-linux-gnu"},
+	"amd64":   {[]uint32{0x0300}, elf.EM_X86_64, elf.ELFCLASS64, "x86_64-linux-gnu"},
+	"arm":     {[]uint32{0x0900, 0x0b00}, elf.EM_ARM, elf.ELFCLASS32, "arm-linux-gnueabihf"},
+	"arm64":   {[]uint32{0x0a00}, elf.EM_AARCH64, elf.ELFCLASS64, "aarch64-linux-gnu"},
+	"loong64": {[]uint32{0x1200}, elf.EM_LOONGARCH, elf.ELFCLASS64, "loongarch64-linux-gnu"},
+	"ppc64le": {[]uint32{0x0500}, elf.EM_PPC64, elf.ELFCLASS64, "powerpc64le-linux-gnu"},
+	"riscv64": {[]uint32{0x1000}, elf.EM_RISCV, elf.ELFCLASS64, "riscv64-linux-gnu"},
+	"s390x":   {[]uint32{0x0400}, elf.EM_S390, elf.ELFCLASS64, "s390x-linux-gnu"},
+}
+
+// libraryDirs returns the directories searched, in order, for the libraries
+// of the architecture on systems without an ld.so cache (musl).
+func libraryDirs(arch string) []string {
+	var dirs []string
+	a, ok := libraryArchs[arch]
+	if ok {
+		dirs = append(dirs, "/lib/"+a.triplet, "/usr/lib/"+a.triplet)
+	}
+	if !ok || a.class == elf.ELFCLASS64 {
+		dirs = append(dirs, "/lib64", "/usr/lib64")
+	}
+
+	return append(dirs, "/lib", "/usr/lib", "/usr/local/lib")
+}
+
+// ldCacheEntry is a library of the ld.so cache.
+type ldCacheEntry struct {
+	flags uint32
+	name  string // soname
+	path  string
+}
+
+// compatible reports whether the library is built for the architecture,
+// unknown ones accepting any library.
+func (e ldCacheEntry) compatible(arch string) bool {
+	if t := e.flags & ldCacheFlagTypeMask; t != ldCacheFlagELF && t != ldCacheFlagELFLibc6 {
+		return false
+	}
+
+	a, ok := libraryArchs[arch]
+	if !ok {
+		return true
+	}
+
+	return slices.Contains(a.cacheFlags, e.flags&ldCacheFlagRequiredMask)
+}
+
+// parseLdCache parses the ld.so cache, in the glibc format, new (2.32+) or
+// old with the new one appended. The entries are in the order of preference
+// of the dynamic loader.
+func parseLdCache(data []byte) ([]ldCacheEntry, error) {
+	if bytes.HasPrefix(data, []byte(ldCacheMagicOld)) {
+		if len(data) < ldCacheHeaderOldSize {
+			return nil, errors.New("truncated ld.so cache")
+		}
+		nlibs := binary.NativeEndian.Uint32(data[12:])
+		// The new format follows, aligned on 8 bytes
+		off := (ldCacheHeaderOldSize + uint64(nlibs)*ldCacheEntryOldSize + 7) &^ 7
+		if off > uint64(len(data)) {
+			return nil, errors.New("truncated ld.so cache")
+		}
+		data = data[off:]
+	}
+
+	if !bytes.HasPrefix(data, []byte(ldCacheMagicNew)) {
+		return nil, errors.New("unknown ld.so cache format")
+	}
+	if len(data) < ldCacheHeaderNewSize {
+		return nil, errors.New("truncated ld.so cache")
+	}
+
+	nlibs := binary.NativeEndian.Uint32(data[20:])
+	if uint64(nlibs)*ldCacheEntryNewSize > uint64(len(data)-ldCacheHeaderNewSize) {
+		return nil, errors.New("truncated ld.so cache")
+	}
+
+	// The strings are referenced by their offset from the header
+	str := func(off uint32) (string, error) {
+		if uint64(off) >= uint64(len(data)) {
+			return "", fmt.Errorf("invalid ld.so cache string offset %d", off)
+		}
+		s, _, ok := bytes.Cut(data[off:], []byte{0})
+		if !ok {
+			return "", fmt.Errorf("unterminated ld.so cache string at %d", off)
+		}
+		return string(s), nil
+	}
+
+	entries := make([]ldCacheEntry, 0, nlibs)
+	for i := uint32(0); i < nlibs; i++ {
+		entry := data[ldCacheHeaderNewSize+i*ldCacheEntryNewSize:]
+		name, err := str(binary.NativeEndian.Uint32(entry[4:]))
+		if err != nil {
+			return nil, err
+		}
+		path, err := str(binary.NativeEndian.Uint32(entry[8:]))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ldCacheEntry{
+			flags: binary.NativeEndian.Uint32(entry),
+			name:  name,
+			path:  path,
+		})
+	}
+
+	return entries, nil
+}
+
+// isLibraryName reports whether the uprobe target is a library name to
+// resolve, not a path nor an executable name.
+func isLibraryName(name string) bool {
+	return !strings.Contains(name, "/") && (strings.HasPrefix(name, "lib") || strings.Contains(name, ".so"))
+}
+
+// libraryMatcher returns whether a file name is a version of the library:
+// "libssl.so.3" matches itself, "libssl.so", "libssl" and "ssl" match any
+// "libssl.so" version.
+func libraryMatcher(name string) func(string) bool {
+	if strings.Contains(name, ".so") {
+		return func(file string) bool {
+			return file == name || strings.HasPrefix(file, name+".")
+		}
+	}
+
+	if !strings.HasPrefix(name, "lib") {
+		name = "lib" + name
+	}
+	soname := name + ".so"
+
+	return func(file string) bool {
+		return file == soname || strings.HasPrefix(file, soname+".")
+	}
+}
+
+// ResolveLibrary returns the path of the shared library, given by name
+// ("libssl", "libssl.so.3" or "ssl"), that the dynamic loader would load for
+// the architecture of the caller, looked up in the ld.so cache or, without
+// it, in the standard library directories. Names containing a slash are
+// returned unchanged. It fails with an error wrapping fs.ErrNotExist if the
+// library is not found.
+func ResolveLibrary(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+
+	path, err := resolveLibrary(ldCachePath, libraryDirs(runtime.GOARCH), name, runtime.GOARCH)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve library %s: %w", name, err)
+	}
+
+	return path, nil
+}
+
+func resolveLibrary(cachePath string, dirs []string, name string, arch string) (string, error) {
+	match := libraryMatcher(name)
+
+	data, err := os.ReadFile(cachePath)
+	if err == nil {
+		entries, err := parseLdCache(data)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			// The cache also lists the libraries of the other ABIs
+			if match(entry.name) && entry.compatible(arch) {
+				return entry.path, nil
+			}
+		}
+		return "", fs.ErrNotExist
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	for _, dir := range dirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			path := filepath.Join(dir, file.Name())
+			if match(file.Name()) && elfCompatible(path, arch) {
+				return path, nil
+			}
+		}
+	}
+
+	return "", fs.ErrNotExist
+}
+
+// elfCompatible reports whether the ELF file is built for the architecture,
+// unknown ones accepting any ELF file.
+func elfCompatible(path string, arch string) bool {
+	f, err := elf.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	a, ok := libraryArchs[arch]
+
+	return !ok || (f.Machine == a.machine && f.Class == a.class)
+}
+
+// LibraryCopies returns the paths, accessible from the caller, of the copies
+// of the shared library (see ResolveLibrary()) mapped by the running
+// processes, such as the copies of containers, reached through the root of a
+// process mapping them (/proc/<pid>/root/...). The copy of the caller's
+// filesystem, if any, comes first, under its own path, whether mapped or not.
+func LibraryCopies(name string) ([]string, error) {
+	host, err := ResolveLibrary(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	paths, err := libraryCopies(procDir, host, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find copies of library %s: %w", name, err)
+	}
+
+	return paths, nil
+}
+
+// fileID identifies a file across mount namespaces.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func statFileID(path string) (fileID, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return fileID{}, &fs.PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, nil
+}
+
+func libraryCopies(proc string, host string, name string) ([]string, error) {
+	match := libraryMatcher(name)
+
+	var paths []string
+	seen := make(map[fileID]bool)
+	if host != "" {
+		if id, err := statFileID(host); err == nil {
+			paths = append(paths, host)
+			seen[id] = true
+		}
+	}
+
+	entries, err := os.ReadDir(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	scanned := make(map[string]bool) // by device and inode of the maps
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+
+		mapped, err := mappedFiles(filepath.Join(proc, entry.Name(), "maps"))
+		if err != nil {
+			// The process may have exited, or be a kernel thread
+			continue
+		}
+		for _, m := range mapped {
+			if !match(filepath.Base(m.path)) || scanned[m.id] {
+				continue
+			}
+			scanned[m.id] = true
+
+			// A process of the caller's mount namespace maps the host copy
+			path := filepath.Join(proc, entry.Name(), "root", m.path)
+			id, err := statFileID(path)
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// mappedFile is a file mapped by a process.
+type mappedFile struct {
+	id   string // device and inode, as in the maps
+	path string
+}
+
+// mappedFiles returns the files mapped by a process, from its maps, without
+// the deleted ones.
+func mappedFiles(maps string) ([]mappedFile, error) {
+	f, err := os.Open(maps)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var files []mappedFile
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m, ok := parseMapsLine(scanner.Text())
+		if !ok || seen[m.id] {
+			continue
+		}
+		seen[m.id] = true
+		files = append(files, m)
+	}
+
+	return files, scanner.Err()
+}
+
+// parseMapsLine parses a line of /proc/<pid>/maps, returning the file mapped,
+// if any:
+//
+//	7f2c1a400000-7f2c1a4a0000 r--p 00000000 fd:01 1574036   /usr/lib/x86_64-linux-gnu/libssl.so.3
+func parseMapsLine(line string) (mappedFile, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 6 || fields[4] == "0" {
+		return mappedFile{}, false
+	}
+
+	// The path may contain spaces
+	idx := strings.Index(line, fields[5])
+	path := line[idx:]
+	if !strings.HasPrefix(path, "/") || strings.HasSuffix(path, " (deleted)") {
+		return mappedFile{}, false
+	}
+
+	return mappedFile{id: fields[3] + " " + fields[4], path: path}, true
+}
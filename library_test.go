@@ -0,0 +1,225 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLdCache builds an ld.so cache of the libraries in the new format, or
+// in the old one with the new one appended.
+func newTestLdCache(old bool, libs []ldCacheEntry) []byte {
+	var strs []byte
+	offsets := make([][2]uint32, len(libs))
+	base := uint32(ldCacheHeaderNewSize + len(libs)*ldCacheEntryNewSize)
+	for i, lib := range libs {
+		for j, s := range []string{lib.name, lib.path} {
+			offsets[i][j] = base + uint32(len(strs))
+			strs = append(strs, s...)
+			strs = append(strs, 0)
+		}
+	}
+
+	cache := make([]byte, ldCacheHeaderNewSize, int(base)+len(strs))
+	copy(cache, ldCacheMagicNew)
+	binary.NativeEndian.PutUint32(cache[20:], uint32(len(libs)))
+	binary.NativeEndian.PutUint32(cache[24:], uint32(len(strs)))
+	for i, off := range offsets {
+		entry := make([]byte, ldCacheEntryNewSize)
+		binary.NativeEndian.PutUint32(entry, libs[i].flags)
+		binary.NativeEndian.PutUint32(entry[4:], off[0])
+		binary.NativeEndian.PutUint32(entry[8:], off[1])
+		cache = append(cache, entry...)
+	}
+	cache = append(cache, strs...)
+
+	if !old {
+		return cache
+	}
+
+	// One old entry, padded to 8 bytes
+	prefix := make([]byte, ldCacheHeaderOldSize+ldCacheEntryOldSize+4)
+	copy(prefix, ldCacheMagicOld)
+	binary.NativeEndian.PutUint32(prefix[12:], 1)
+
+	return append(prefix, cache...)
+}
+
+func TestParseLdCache(t *testing.T) {
+	libs := []ldCacheEntry{
+		{flags: 0x0303, name: "libssl.so.3", path: "/lib/x86_64-linux-gnu/libssl.so.3"},
+		{flags: 0x0003, name: "libc.so.6", path: "/lib/i386-linux-gnu/libc.so.6"},
+	}
+
+	for _, old := range []bool{false, true} {
+		entries, err := parseLdCache(newTestLdCache(old, libs))
+		require.NoError(t, err, old)
+		assert.Equal(t, libs, entries, old)
+	}
+
+	_, err := parseLdCache([]byte("not a cache"))
+	assert.Error(t, err)
+	_, err = parseLdCache(newTestLdCache(false, libs)[:60])
+	assert.Error(t, err)
+}
+
+func TestLibraryMatcher(t *testing.T) {
+	tests := []struct {
+		name  string
+		match []string
+		other []string
+	}{
+		{"libssl", []string{"libssl.so", "libssl.so.3"}, []string{"libssl3.so", "libssl.so3", "libcrypto.so.3"}},
+		{"ssl", []string{"libssl.so", "libssl.so.1.1"}, []string{"ssl.so"}},
+		{"libssl.so", []string{"libssl.so", "libssl.so.3"}, []string{"libssl.sox"}},
+		{"libssl.so.3", []string{"libssl.so.3", "libssl.so.3.0.2"}, []string{"libssl.so.30", "libssl.so.1.1"}},
+	}
+
+	for _, tt := range tests {
+		match := libraryMatcher(tt.name)
+		for _, file := range tt.match {
+			assert.True(t, match(file), "%s %s", tt.name, file)
+		}
+		for _, file := range tt.other {
+			assert.False(t, match(file), "%s %s", tt.name, file)
+		}
+	}
+}
+
+func TestIsLibraryName(t *testing.T) {
+	assert.True(t, isLibraryName("libssl"))
+	assert.True(t, isLibraryName("libssl.so.3"))
+	assert.False(t, isLibraryName("bash"))
+	assert.False(t, isLibraryName("/usr/lib/libssl.so.3"))
+	assert.False(t, isLibraryName("./libssl.so.3"))
+}
+
+func TestLdCacheEntryCompatible(t *testing.T) {
+	amd64 := ldCacheEntry{flags: 0x0303}
+	i386 := ldCacheEntry{flags: 0x0003}
+	armhf := ldCacheEntry{flags: 0x0903}
+	armel := ldCacheEntry{flags: 0x0b03}
+
+	assert.True(t, amd64.compatible("amd64"))
+	assert.False(t, i386.compatible("amd64"))
+	assert.True(t, i386.compatible("386"))
+	assert.False(t, amd64.compatible("386"))
+	assert.True(t, armhf.compatible("arm"))
+	assert.True(t, armel.compatible("arm"))
+	assert.False(t, armhf.compatible("arm64"))
+	assert.True(t, armhf.compatible("mips"))
+	assert.False(t, ldCacheEntry{flags: 0x0300}.compatible("amd64"))
+}
+
+func TestLibraryDirs(t *testing.T) {
+	assert.Equal(t, []string{
+		"/lib/x86_64-linux-gnu", "/usr/lib/x86_64-linux-gnu",
+		"/lib64", "/usr/lib64",
+		"/lib", "/usr/lib", "/usr/local/lib",
+	}, libraryDirs("amd64"))
+	assert.Equal(t, []string{
+		"/lib/i386-linux-gnu", "/usr/lib/i386-linux-gnu",
+		"/lib", "/usr/lib", "/usr/local/lib",
+	}, libraryDirs("386"))
+	assert.Equal(t, []string{"/lib64", "/usr/lib64", "/lib", "/usr/lib", "/usr/local/lib"}, libraryDirs("mips"))
+}
+
+func TestResolveLibrary(t *testing.T) {
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "ld.so.cache")
+	require.NoError(t, os.WriteFile(cache, newTestLdCache(false, []ldCacheEntry{
+		{flags: 0x0003, name: "libssl.so.3", path: "/usr/lib/i386-linux-gnu/libssl.so.3"},
+		{flags: 0x0303, name: "libssl.so.3", path: "/usr/lib/x86_64-linux-gnu/libssl.so.3"},
+	}), 0o644))
+
+	path, err := resolveLibrary(cache, nil, "libssl", "amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/lib/x86_64-linux-gnu/libssl.so.3", path)
+
+	path, err = resolveLibrary(cache, nil, "libssl.so.3", "386")
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/lib/i386-linux-gnu/libssl.so.3", path)
+
+	_, err = resolveLibrary(cache, nil, "libssl", "arm64")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = resolveLibrary(cache, nil, "libcrypto", "amd64")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Without cache, the directories are searched for an ELF file of the
+	// architecture: the test binary
+	exe, err := os.ReadFile("/proc/self/exe")
+	require.NoError(t, err)
+	libDir := filepath.Join(dir, "lib")
+	require.NoError(t, os.Mkdir(libDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(libDir, "libssl.so.1.1"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(libDir, "libssl.so.3"), exe, 0o644))
+
+	dirs := []string{filepath.Join(dir, "none"), libDir}
+	path, err = resolveLibrary(filepath.Join(dir, "missing"), dirs, "ssl", runtime.GOARCH)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(libDir, "libssl.so.3"), path)
+
+	path, err = ResolveLibrary("/opt/lib/libssl.so.3")
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/lib/libssl.so.3", path)
+}
+
+func TestParseMapsLine(t *testing.T) {
+	m, ok := parseMapsLine("7f2c1a400000-7f2c1a4a0000 r--p 00000000 fd:01 1574036                    /usr/lib/x86_64-linux-gnu/libssl.so.3")
+	require.True(t, ok)
+	assert.Equal(t, mappedFile{id: "fd:01 1574036", path: "/usr/lib/x86_64-linux-gnu/libssl.so.3"}, m)
+
+	m, ok = parseMapsLine("7f2c1a400000-7f2c1a4a0000 r-xp 00001000 00:2f 42 /opt/my app/libssl.so.3")
+	require.True(t, ok)
+	assert.Equal(t, "/opt/my app/libssl.so.3", m.path)
+
+	for _, line := range []string{
+		"7ffd5e9f1000-7ffd5ea12000 rw-p 00000000 00:00 0                          [stack]",
+		"7f2c1a600000-7f2c1a601000 rw-p 00000000 00:00 0",
+		"7f2c1a400000-7f2c1a4a0000 r--p 00000000 fd:01 1574036 /usr/lib/libssl.so.3 (deleted)",
+	} {
+		_, ok := parseMapsLine(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestLibraryCopies(t *testing.T) {
+	proc := t.TempDir()
+
+	addProcess := func(pid string, lib string) {
+		root := filepath.Join(proc, pid, "root")
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(lib)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, lib), []byte(pid), 0o644))
+		maps := "559a3c000000-559a3c001000 r--p 00000000 fd:01 1 /usr/bin/app\n" +
+			"7f2c1a400000-7f2c1a4a0000 r--p 00000000 00:" + pid + " 7 " + lib + "\n" +
+			"7f2c1a4a0000-7f2c1a4b0000 r-xp 000a0000 00:" + pid + " 7 " + lib + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(proc, pid, "maps"), []byte(maps), 0o644))
+	}
+	addProcess("10", "/usr/lib/libssl.so.3")
+	addProcess("20", "/lib/x86_64-linux-gnu/libssl.so.1.1")
+	require.NoError(t, os.MkdirAll(filepath.Join(proc, "self"), 0o755))
+
+	// A process of the host, mapping the host copy
+	host := filepath.Join(proc, "10", "root", "usr/lib/libssl.so.3")
+	require.NoError(t, os.MkdirAll(filepath.Join(proc, "30"), 0o755))
+	require.NoError(t, os.Symlink(filepath.Join(proc, "10", "root"), filepath.Join(proc, "30", "root")))
+	require.NoError(t, os.WriteFile(filepath.Join(proc, "30", "maps"),
+		[]byte("7f2c1a400000-7f2c1a4a0000 r--p 00000000 fd:01 99 /usr/lib/libssl.so.3\n"), 0o644))
+
+	paths, err := libraryCopies(proc, host, "libssl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		host,
+		filepath.Join(proc, "20", "root", "lib/x86_64-linux-gnu/libssl.so.1.1"),
+	}, paths)
+
+	paths, err = libraryCopies(proc, "", "libssl.so.1.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(proc, "20", "root", "lib/x86_64-linux-gnu/libssl.so.1.1")}, paths)
+}
@@ -0,0 +1,129 @@
+package libbpfgo
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//
+// License
+//
+// The kernel allows the GPL-only helpers and the kfuncs to the programs of a
+// GPL-compatible license, from the "license" section of the object
+// (char LICENSE[] SEC("license") = "GPL"). It only knows its own license
+// strings, not SPDX identifiers: KernelLicense() converts them, so that
+// objects generated at runtime, or distributed under several licenses, get
+// the license matching their terms with NewModuleArgs.License.
+//
+// A program calling GPL-only helpers from an object of another license fails
+// to load with ErrGPLRequired.
+//
+
+// licenseSection is the ELF section holding the license of an object.
+const licenseSection = "license"
+
+// gplCompatibleLicenses are the licenses the kernel considers GPL-compatible
+// (license_is_gpl_compatible()).
+var gplCompatibleLicenses = []string{
+	"GPL",
+	"GPL v2",
+	"GPL and additional rights",
+	"Dual BSD/GPL",
+	"Dual MIT/GPL",
+	"Dual MPL/GPL",
+}
+
+// LicenseIsGPLCompatible reports whether the kernel considers the license
+// GPL-compatible, allowing the programs to call GPL-only helpers.
+func LicenseIsGPLCompatible(license string) bool {
+	return slices.Contains(gplCompatibleLicenses, license)
+}
+
+// KernelLicense returns the kernel license string of the SPDX license
+// expression: "GPL" for GPL-2.0 licenses, or for expressions requiring one
+// of them ("GPL-2.0-only AND MIT"), "Dual BSD/GPL", "Dual MIT/GPL" or
+// "Dual MPL/GPL" for the choice of GPL-2.0 or a BSD, MIT or MPL license
+// ("BSD-2-Clause OR GPL-2.0-only"). Other expressions, not GPL-compatible,
+// are returned as is.
+func KernelLicense(spdx string) string {
+	expr := strings.NewReplacer("(", " ", ")", " ").Replace(spdx)
+
+	var gpl, or bool
+	dual := ""
+	for _, term := range strings.Fields(expr) {
+		switch {
+		case strings.EqualFold(term, "OR"):
+			or = true
+		case strings.EqualFold(term, "AND"), strings.EqualFold(term, "WITH"):
+		case strings.HasPrefix(term, "GPL-2.0"):
+			gpl = true
+		case strings.HasPrefix(term, "BSD-"):
+			dual = "Dual BSD/GPL"
+		case term == "MIT":
+			dual = "Dual MIT/GPL"
+		case strings.HasPrefix(term, "MPL-"):
+			dual = "Dual MPL/GPL"
+		}
+	}
+
+	switch {
+	case !gpl:
+		return spdx
+	case or && dual != "":
+		return dual
+	default:
+		return "GPL"
+	}
+}
+
+// License returns the license of the object, from its license section, or
+// the one set with NewModuleArgs.License.
+func (m *Module) License() string {
+	return m.license
+}
+
+// objectLicense returns the license in the license section of the object.
+func objectLicense(f *elf.File) string {
+	sec := f.Section(licenseSection)
+	if sec == nil {
+		return ""
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return ""
+	}
+	license, _, _ := bytes.Cut(data, []byte{0})
+
+	return string(license)
+}
+
+// setObjectLicense returns a copy of the object with the license in its
+// license section, which must be large enough.
+func setObjectLicense(obj []byte, license string) ([]byte, error) {
+	f, err := elf.NewFile(bytes.NewReader(obj))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sec := f.Section(licenseSection)
+	if sec == nil || sec.Type != elf.SHT_PROGBITS {
+		return nil, fmt.Errorf("failed to set license %q: object has no license section", license)
+	}
+	if uint64(len(license)) >= sec.Size {
+		return nil, fmt.Errorf("failed to set license %q: license section of %d bytes too small", license, sec.Size)
+	}
+	if sec.Offset+sec.Size > uint64(len(obj)) {
+		return nil, fmt.Errorf("failed to set license %q: invalid license section", license)
+	}
+
+	patched := bytes.Clone(obj)
+	data := patched[sec.Offset : sec.Offset+sec.Size]
+	clear(data)
+	copy(data, license)
+
+	return patched, nil
+}
@@ -0,0 +1,122 @@
+package libbpfgo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestObject builds a BPF ELF object with only a license section.
+func newTestObject(t *testing.T, license []byte) []byte {
+	shstrtab := []byte("\x00license\x00.shstrtab\x00")
+
+	hdrSize := binary.Size(elf.Header64{})
+	licenseOff := hdrSize
+	shstrtabOff := licenseOff + len(license)
+	shOff := shstrtabOff + len(shstrtab)
+
+	var buf bytes.Buffer
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_BPF),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(shOff),
+		Ehsize:    uint16(hdrSize),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     3,
+		Shstrndx:  2,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, hdr))
+	buf.Write(license)
+	buf.Write(shstrtab)
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Flags: uint64(elf.SHF_ALLOC | elf.SHF_WRITE), Off: uint64(licenseOff), Size: uint64(len(license)), Addralign: 1},
+		{Name: 9, Type: uint32(elf.SHT_STRTAB), Off: uint64(shstrtabOff), Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, sections))
+
+	return buf.Bytes()
+}
+
+func TestLicenseIsGPLCompatible(t *testing.T) {
+	for _, license := range []string{"GPL", "GPL v2", "Dual BSD/GPL", "Dual MIT/GPL", "Dual MPL/GPL", "GPL and additional rights"} {
+		assert.True(t, LicenseIsGPLCompatible(license), license)
+	}
+	for _, license := range []string{"", "gpl", "GPL-2.0", "BSD", "Proprietary"} {
+		assert.False(t, LicenseIsGPLCompatible(license), license)
+	}
+}
+
+func TestKernelLicense(t *testing.T) {
+	tests := map[string]string{
+		"GPL-2.0":                            "GPL",
+		"GPL-2.0-only":                       "GPL",
+		"GPL-2.0-or-later":                   "GPL",
+		"GPL-2.0 WITH Linux-syscall-note":    "GPL",
+		"GPL-2.0-only AND MIT":               "GPL",
+		"BSD-2-Clause OR GPL-2.0-only":       "Dual BSD/GPL",
+		"(GPL-2.0-only OR BSD-3-Clause)":     "Dual BSD/GPL",
+		"MIT or GPL-2.0":                     "Dual MIT/GPL",
+		"MPL-2.0 OR GPL-2.0-or-later":        "Dual MPL/GPL",
+		"GPL-2.0 OR Apache-2.0":              "GPL",
+		"LGPL-2.1 OR BSD-2-Clause":           "LGPL-2.1 OR BSD-2-Clause",
+		"Apache-2.0":                         "Apache-2.0",
+		"LicenseRef-Proprietary":             "LicenseRef-Proprietary",
+		"GPL-3.0-only":                       "GPL-3.0-only",
+		"BSD-2-Clause AND GPL-2.0-or-later ": "GPL",
+	}
+
+	for spdx, want := range tests {
+		assert.Equal(t, want, KernelLicense(spdx), spdx)
+		if want != spdx {
+			assert.True(t, LicenseIsGPLCompatible(KernelLicense(spdx)), spdx)
+		}
+	}
+}
+
+func TestObjectLicense(t *testing.T) {
+	obj := newTestObject(t, []byte("GPL\x00\x00\x00\x00\x00"))
+
+	f, err := elf.NewFile(bytes.NewReader(obj))
+	require.NoError(t, err)
+	assert.Equal(t, "GPL", objectLicense(f))
+
+	patched, err := setObjectLicense(obj, "Dual BSD")
+	require.Error(t, err, "no room for the terminating NUL")
+	assert.Nil(t, patched)
+
+	patched, err = setObjectLicense(obj, "BSD")
+	require.NoError(t, err)
+	f, err = elf.NewFile(bytes.NewReader(patched))
+	require.NoError(t, err)
+	assert.Equal(t, "BSD", objectLicense(f))
+	// The object itself is left alone
+	assert.Equal(t, newTestObject(t, []byte("GPL\x00\x00\x00\x00\x00")), obj)
+
+	_, err = setObjectLicense([]byte("not an object"), "GPL")
+	assert.Error(t, err)
+}
+
+func TestClassifyErrorGPL(t *testing.T) {
+	log := "libbpf: prog 'p': -- BEGIN PROG LOAD LOG --\n0: (85) call bpf_probe_read#4\n" +
+		"cannot call GPL-restricted function from non-GPL compatible program\nprocessed 1 insns\n"
+
+	err := fmt.Errorf("failed to load BPF object: %w", classifyError(opLoad, syscall.EINVAL, log))
+	assert.ErrorIs(t, err, ErrGPLRequired)
+	assert.ErrorIs(t, err, ErrVerifierRejected)
+	assert.ErrorIs(t, err, syscall.EINVAL)
+	assert.False(t, errors.Is(err, ErrPermission))
+}
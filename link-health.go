@@ -0,0 +1,101 @@
+package libbpfgo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+//
+// Link health checks
+//
+// A link outlives its target: when an interface is deleted, a cgroup
+// removed or a network namespace destroyed, the kernel detaches the link,
+// which stays open but no longer runs its program. The uprobes of a process
+// are gone with it. HealthCheck() tells such links apart, so that
+// supervisors repair them:
+//
+//	if err := link.HealthCheck(); errors.Is(err, ErrLinkDetached) {
+//		_ = link.Destroy()
+//		link, err = prog.AttachXDP(deviceName)
+//	}
+//
+// XDP, TCX, cgroup and netns links are checked with the link information of
+// the kernel, which reports no target once detached. The interface index of
+// a recreated interface differs, see AttachWatcher to attach again to it.
+//
+
+// ErrLinkDetached is the error of the health check of a link detached from
+// its target, or destroyed.
+var ErrLinkDetached = errors.New("link detached")
+
+// HealthCheck returns nil if the link is still attached to its target, an
+// error wrapping ErrLinkDetached if it was detached, or the error preventing
+// the check.
+func (l *BPFLink) HealthCheck() error {
+	if l.legacy != nil {
+		return l.healthCheckLegacy()
+	}
+	if l.link == nil {
+		return fmt.Errorf("link %s: destroyed: %w", l.eventName, ErrLinkDetached)
+	}
+
+	info, err := l.Info()
+	if err != nil {
+		return fmt.Errorf("failed to check link %s: %w", l.eventName, err)
+	}
+	if err := checkLinkTarget(info); err != nil {
+		return fmt.Errorf("link %s: %w", l.eventName, err)
+	}
+	if l.pid > 0 && !processExists(l.pid) {
+		return fmt.Errorf("link %s: process %d exited: %w", l.eventName, l.pid, ErrLinkDetached)
+	}
+
+	return nil
+}
+
+// Valid reports whether the link is still attached to its target. See
+// HealthCheck().
+func (l *BPFLink) Valid() bool {
+	return l.HealthCheck() == nil
+}
+
+func (l *BPFLink) healthCheckLegacy() error {
+	if l.linkType == CgroupLegacy {
+		if _, err := os.Stat(l.legacy.cgroupDir); errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("link %s: cgroup %s removed: %w", l.eventName, l.legacy.cgroupDir, ErrLinkDetached)
+		}
+	}
+
+	return nil
+}
+
+// checkLinkTarget fails if the link information reports the link detached
+// from its target.
+func checkLinkTarget(info *BPFLinkInfo) error {
+	switch info.Type {
+	case BPFLinkTypeXDP, BPFLinkTypeTCX:
+		if info.IfIndex == 0 {
+			return fmt.Errorf("interface removed: %w", ErrLinkDetached)
+		}
+	case BPFLinkTypeCgroup:
+		if info.CgroupID == 0 {
+			return fmt.Errorf("cgroup removed: %w", ErrLinkDetached)
+		}
+	case BPFLinkTypeNetns:
+		if info.NetnsIno == 0 {
+			return fmt.Errorf("network namespace removed: %w", ErrLinkDetached)
+		}
+	}
+
+	return nil
+}
+
+// processExists reports whether the process exists, even if not ours to
+// signal.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
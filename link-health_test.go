@@ -0,0 +1,27 @@
+package libbpfgo
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLinkTarget(t *testing.T) {
+	assert.NoError(t, checkLinkTarget(&BPFLinkInfo{Type: BPFLinkTypeXDP, IfIndex: 2}))
+	assert.NoError(t, checkLinkTarget(&BPFLinkInfo{Type: BPFLinkTypeCgroup, CgroupID: 1234}))
+	assert.NoError(t, checkLinkTarget(&BPFLinkInfo{Type: BPFLinkTypeNetns, NetnsIno: 4026531840}))
+	assert.NoError(t, checkLinkTarget(&BPFLinkInfo{Type: BPFLinkTypeTracing}))
+
+	err := checkLinkTarget(&BPFLinkInfo{Type: BPFLinkTypeTCX})
+	assert.ErrorIs(t, err, ErrLinkDetached)
+	assert.EqualError(t, err, "interface removed: link detached")
+	assert.ErrorIs(t, checkLinkTarget(&BPFLinkInfo{Type: BPFLinkTypeCgroup}), ErrLinkDetached)
+	assert.ErrorIs(t, checkLinkTarget(&BPFLinkInfo{Type: BPFLinkTypeNetns}), ErrLinkDetached)
+}
+
+func TestProcessExists(t *testing.T) {
+	assert.True(t, processExists(os.Getpid()))
+	assert.True(t, processExists(1))
+	assert.False(t, processExists(1<<22+1)) // above pid_max
+}
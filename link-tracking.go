@@ -0,0 +1,78 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"slices"
+)
+
+//
+// Link tracking
+//
+// The module tracks the links created by all the attach methods, emulated
+// legacy links included, until they are destroyed, and destroys the remaining
+// ones when it is closed (or on DetachPrograms(), BPFProg.Unload()).
+// Applications that manage the lifetime of their links, such as the ones
+// pinning links meant to outlive the process, take their ownership instead:
+// for all the links of a program with SetLinkTracking(false), before
+// attaching it, or for a link with Release().
+//
+//	prog.SetLinkTracking(false)
+//	link, _ := prog.AttachKprobe("do_sys_openat2")
+//	link.Pin("/sys/fs/bpf/openat")
+//
+// The links owned by the caller are still reported to the event handler, but
+// are not seen as attachments of their program by AttachPrograms().
+//
+
+// SetLinkTracking sets whether the links that the attach methods create for
+// the program, from now on, are tracked by the module (the default), or
+// owned by the caller. It applies to all the handles of the program.
+func (p *BPFProg) SetLinkTracking(enabled bool) {
+	if enabled {
+		delete(p.module.untrackedProgs, p.prog)
+		return
+	}
+
+	if p.module.untrackedProgs == nil {
+		p.module.untrackedProgs = make(map[*C.struct_bpf_program]struct{})
+	}
+	p.module.untrackedProgs[p.prog] = struct{}{}
+}
+
+// LinkTracking reports whether the links of the program are tracked by the
+// module. See SetLinkTracking().
+func (p *BPFProg) LinkTracking() bool {
+	_, untracked := p.module.untrackedProgs[p.prog]
+
+	return !untracked
+}
+
+// addLink registers a link created by an attach method, to be destroyed when
+// the module is closed, unless the links of its program are not tracked.
+func (m *Module) addLink(link *BPFLink) {
+	if link.prog == nil || link.prog.LinkTracking() {
+		m.links = append(m.links, link)
+	}
+	m.emitLink(ModuleEventLinkAttached, link)
+}
+
+// removeLink unregisters a link, destroyed or released.
+func (m *Module) removeLink(link *BPFLink) {
+	if i := slices.Index(m.links, link); i >= 0 {
+		m.links = slices.Delete(m.links, i, i+1)
+	}
+}
+
+// Release transfers the ownership of the link from the module to the
+// caller: the link is no longer destroyed with the module, and stays
+// attached until destroyed, or until the process exits if it is not pinned.
+// Once the module is closed, destroying the link reports no event, and
+// legacy links (emulated with the program) can no longer be destroyed.
+func (l *BPFLink) Release() {
+	l.module().removeLink(l)
+}
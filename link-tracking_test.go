@@ -0,0 +1,68 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkTracking(t *testing.T) {
+	m := &Module{}
+	prog := &BPFProg{module: m}
+	assert.True(t, prog.LinkTracking())
+
+	tracked := &BPFLink{prog: prog}
+	m.addLink(tracked)
+	assert.Equal(t, []*BPFLink{tracked}, m.links)
+
+	// The setting is shared by the handles of the program
+	prog.SetLinkTracking(false)
+	assert.False(t, (&BPFProg{module: m}).LinkTracking())
+
+	owned := &BPFLink{prog: prog}
+	m.addLink(owned)
+	assert.Equal(t, []*BPFLink{tracked}, m.links)
+
+	prog.SetLinkTracking(true)
+	assert.True(t, prog.LinkTracking())
+	m.addLink(owned)
+	assert.Equal(t, []*BPFLink{tracked, owned}, m.links)
+}
+
+func TestLinkRelease(t *testing.T) {
+	m := &Module{}
+	prog := &BPFProg{module: m}
+	l1, l2, l3 := &BPFLink{prog: prog}, &BPFLink{prog: prog}, &BPFLink{prog: prog}
+	m.addLink(l1)
+	m.addLink(l2)
+	m.addLink(l3)
+
+	l2.Release()
+	assert.Equal(t, []*BPFLink{l1, l3}, m.links)
+
+	// Releasing an untracked link does nothing
+	l2.Release()
+	assert.Equal(t, []*BPFLink{l1, l3}, m.links)
+}
+
+func TestLinkReleaseDestroyAfterClose(t *testing.T) {
+	var events []ModuleEventType
+	m := &Module{}
+	m.SetEventHandler(func(e ModuleEvent) { events = append(events, e.Type) })
+
+	// The program is not backed by libbpf: reading its name would crash
+	prog := &BPFProg{module: m}
+	released := &BPFLink{prog: prog, linkType: Kprobe, eventName: "do_sys_openat2"}
+	m.links = append(m.links, released)
+	released.Release()
+
+	m.Close()
+	assert.Nil(t, m.obj)
+	assert.Equal(t, []ModuleEventType{ModuleEventObjectClosed}, events)
+
+	assert.NoError(t, released.Destroy())
+	assert.Equal(t, []ModuleEventType{ModuleEventObjectClosed}, events)
+
+	legacy := &BPFLink{prog: prog, linkType: CgroupLegacy, eventName: "cgroup", legacy: &bpfLinkLegacy{}}
+	assert.ErrorContains(t, legacy.Destroy(), "module closed")
+}
@@ -33,8 +33,113 @@ const (
 	CgroupLegacy
 	Netns
 	Iter
+	KprobeMulti
+	KretprobeMulti
+	TCX
+	SockMap
+	SockMapLegacy
+	StructOps
+	USDT
+	UprobeMulti
+	UretprobeMulti
+	KprobeSession
+	Ksyscall
+	Kretsyscall
 )
 
+//
+// BPFLinkType
+//
+
+// BPFLinkType is an enum as defined in https://elixir.bootlin.com/linux/latest/source/include/uapi/linux/bpf.h
+type BPFLinkType uint32
+
+const (
+	BPFLinkTypeUnspec        BPFLinkType = C.BPF_LINK_TYPE_UNSPEC
+	BPFLinkTypeRawTracepoint BPFLinkType = C.BPF_LINK_TYPE_RAW_TRACEPOINT
+	BPFLinkTypeTracing       BPFLinkType = C.BPF_LINK_TYPE_TRACING
+	BPFLinkTypeCgroup        BPFLinkType = C.BPF_LINK_TYPE_CGROUP
+	BPFLinkTypeIter          BPFLinkType = C.BPF_LINK_TYPE_ITER
+	BPFLinkTypeNetns         BPFLinkType = C.BPF_LINK_TYPE_NETNS
+	BPFLinkTypeXDP           BPFLinkType = C.BPF_LINK_TYPE_XDP
+	BPFLinkTypePerfEvent     BPFLinkType = C.BPF_LINK_TYPE_PERF_EVENT
+	BPFLinkTypeKprobeMulti   BPFLinkType = C.BPF_LINK_TYPE_KPROBE_MULTI
+	BPFLinkTypeStructOps     BPFLinkType = C.BPF_LINK_TYPE_STRUCT_OPS
+	BPFLinkTypeTCX           BPFLinkType = C.BPF_LINK_TYPE_TCX
+	BPFLinkTypeUprobeMulti   BPFLinkType = C.BPF_LINK_TYPE_UPROBE_MULTI
+)
+
+var bpfLinkTypeToString = map[BPFLinkType]string{
+	BPFLinkTypeUnspec:        "BPF_LINK_TYPE_UNSPEC",
+	BPFLinkTypeRawTracepoint: "BPF_LINK_TYPE_RAW_TRACEPOINT",
+	BPFLinkTypeTracing:       "BPF_LINK_TYPE_TRACING",
+	BPFLinkTypeCgroup:        "BPF_LINK_TYPE_CGROUP",
+	BPFLinkTypeIter:          "BPF_LINK_TYPE_ITER",
+	BPFLinkTypeNetns:         "BPF_LINK_TYPE_NETNS",
+	BPFLinkTypeXDP:           "BPF_LINK_TYPE_XDP",
+	BPFLinkTypePerfEvent:     "BPF_LINK_TYPE_PERF_EVENT",
+	BPFLinkTypeKprobeMulti:   "BPF_LINK_TYPE_KPROBE_MULTI",
+	BPFLinkTypeStructOps:     "BPF_LINK_TYPE_STRUCT_OPS",
+	BPFLinkTypeTCX:           "BPF_LINK_TYPE_TCX",
+	BPFLinkTypeUprobeMulti:   "BPF_LINK_TYPE_UPROBE_MULTI",
+}
+
+func (t BPFLinkType) String() string {
+	str, ok := bpfLinkTypeToString[t]
+	if !ok {
+		// BPFLinkTypeUnspec must exist in bpfLinkTypeToString to avoid infinite recursion.
+		return BPFLinkTypeUnspec.String()
+	}
+
+	return str
+}
+
+func (t BPFLinkType) Name() string {
+	return C.GoString(C.libbpf_bpf_link_type_str(C.enum_bpf_link_type(t)))
+}
+
+//
+// BPFLinkInfo
+//
+
+// BPFLinkInfo mirrors the C structure bpf_link_info. Only the type specific
+// fields matching the link type are set.
+type BPFLinkInfo struct {
+	Type        BPFLinkType
+	ID          uint32
+	ProgID      uint32
+	AttachType  BPFAttachType // tracing, cgroup and netns links
+	TargetObjID uint32        // tracing links
+	TargetBTFID uint32        // tracing links
+	CgroupID    uint64        // cgroup links
+	NetnsIno    uint32        // netns links
+	IfIndex     uint32        // xdp and tcx links
+}
+
+// GetLinkInfoByFD returns the BPFLinkInfo for the link with the given file descriptor.
+func GetLinkInfoByFD(fd int) (*BPFLinkInfo, error) {
+	infoC := C.cgo_bpf_link_info_new()
+	defer C.cgo_bpf_link_info_free(infoC)
+
+	infoLenC := C.cgo_bpf_link_info_size()
+	retC := C.bpf_link_get_info_by_fd(C.int(fd), infoC, &infoLenC)
+	if retC < 0 {
+		return nil, fmt.Errorf("failed to get link info for fd %d: %w", fd, syscall.Errno(-retC))
+	}
+
+	return &BPFLinkInfo{
+		Type:        BPFLinkType(C.cgo_bpf_link_info_type(infoC)),
+		ID:          uint32(C.cgo_bpf_link_info_id(infoC)),
+		ProgID:      uint32(C.cgo_bpf_link_info_prog_id(infoC)),
+		AttachType:  BPFAttachType(C.cgo_bpf_link_info_attach_type(infoC)),
+		TargetObjID: uint32(C.cgo_bpf_link_info_target_obj_id(infoC)),
+		TargetBTFID: uint32(C.cgo_bpf_link_info_target_btf_id(infoC)),
+		CgroupID:    uint64(C.cgo_bpf_link_info_cgroup_id(infoC)),
+		NetnsIno:    uint32(C.cgo_bpf_link_info_netns_ino(infoC)),
+		IfIndex:     uint32(C.cgo_bpf_link_info_ifindex(infoC)),
+	}, nil
+}
+
 //
 // BPFLink
 //
@@ -42,16 +147,29 @@ const (
 type bpfLinkLegacy struct {
 	attachType BPFAttachType
 	cgroupDir  string
+	sockMap    *BPFMap
 }
 
 type BPFLink struct {
 	link      *C.struct_bpf_link
 	prog      *BPFProg
+	structOps *BPFMap // struct_ops links have a map instead of a program
 	linkType  LinkType
 	eventName string
+	pid       int            // process of uprobe and USDT links, 0 if none
 	legacy    *bpfLinkLegacy // if set, this is a fake BPFLink
 }
 
+// ownerName returns the name of the program of the link, or of the map of a
+// struct_ops link.
+func (l *BPFLink) ownerName() string {
+	if l.structOps != nil {
+		return l.structOps.Name()
+	}
+
+	return l.prog.Name()
+}
+
 func (l *BPFLink) DestroyLegacy(linkType LinkType) error {
 	switch l.linkType {
 	case CgroupLegacy:
@@ -59,6 +177,8 @@ func (l *BPFLink) DestroyLegacy(linkType LinkType) error {
 			l.legacy.cgroupDir,
 			l.legacy.attachType,
 		)
+	case SockMapLegacy:
+		return l.prog.DetachSockMapLegacy(l.legacy.sockMap)
 	}
 
 	return fmt.Errorf("unable to destroy legacy link")
@@ -66,17 +186,46 @@ func (l *BPFLink) DestroyLegacy(linkType LinkType) error {
 
 func (l *BPFLink) Destroy() error {
 	if l.legacy != nil {
-		return l.DestroyLegacy(l.linkType)
+		// Legacy links are detached with the program, freed with the module
+		if l.module().obj == nil {
+			return fmt.Errorf("failed to destroy link %s: module closed", l.eventName)
+		}
+		if err := l.DestroyLegacy(l.linkType); err != nil {
+			return err
+		}
+		l.legacy = nil
+		l.module().removeLink(l)
+		l.emitDetached()
+		l.SetUserData(nil)
+
+		return nil
 	}
 	if retC := C.bpf_link__destroy(l.link); retC < 0 {
 		return syscall.Errno(-retC)
 	}
 
 	l.link = nil
+	l.module().removeLink(l)
+	l.emitDetached()
+	l.SetUserData(nil)
 
 	return nil
 }
 
+// module returns the module of the program, or of the struct_ops map, of the
+// link.
+func (l *BPFLink) module() *Module {
+	if l.structOps != nil {
+		return l.structOps.module
+	}
+
+	return l.prog.module
+}
+
+func (l *BPFLink) emitDetached() {
+	l.module().emitLink(ModuleEventLinkDetached, l)
+}
+
 func (l *BPFLink) FileDescriptor() int {
 	return int(C.bpf_link__fd(l.link))
 }
@@ -86,6 +235,36 @@ func (l *BPFLink) GetFd() int {
 	return l.FileDescriptor()
 }
 
+// Info returns the kernel information about the link.
+func (l *BPFLink) Info() (*BPFLinkInfo, error) {
+	if l.legacy != nil {
+		return nil, fmt.Errorf("failed to get link %s info: legacy links have no info", l.eventName)
+	}
+
+	return GetLinkInfoByFD(l.FileDescriptor())
+}
+
+// UpdateProg atomically replaces the program attached through the link with
+// the given one, keeping the link itself (and any path it is pinned to). The
+// new program must be loaded and have the same type as the current one.
+func (l *BPFLink) UpdateProg(prog *BPFProg) error {
+	if l.legacy != nil {
+		return fmt.Errorf("failed to update link %s: legacy links can not be updated", l.eventName)
+	}
+	if prog == nil {
+		return fmt.Errorf("failed to update link %s: nil program", l.eventName)
+	}
+
+	retC := C.bpf_link__update_program(l.link, prog.prog)
+	if retC < 0 {
+		return fmt.Errorf("failed to update link %s with program %s: %w", l.eventName, prog.Name(), syscall.Errno(-retC))
+	}
+
+	l.prog = prog
+
+	return nil
+}
+
 func (l *BPFLink) Pin(pinPath string) error {
 	pathC := C.CString(pinPath)
 	defer C.free(unsafe.Pointer(pathC))
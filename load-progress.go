@@ -0,0 +1,243 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Load progress
+//
+// With an event handler, BPFLoadObject() sends the MapCreated and
+// ProgramLoaded events as the maps are created and the programs verified,
+// instead of all at once when the object is loaded, so that loaders of large
+// objects can display progress and spot the programs slow to verify.
+//
+// libbpf has no hook for this, the progress is tracked from its debug output:
+// it reports each map created, and prints the kernel log of each program
+// loaded with a log level. The programs without a log level of their own get
+// the verifier statistics level (BPF_LOG_STATS), whose log holds the
+// verification time. Programs whose log can't be tracked (custom log buffer,
+// NewModuleArgs.KernelLogSize, kernels older than v5.2) are reported when
+// the object is loaded. The same tracking records the verifier statistics of
+// the programs (NewModuleArgs.VerifierStats).
+//
+// Concurrent loads see each other's output, so objects loaded concurrently
+// should not share map or program names for their progress to be exact.
+//
+
+// logLevelStats is BPF_LOG_STATS.
+const logLevelStats = 4
+
+var (
+	logStatsOnce      sync.Once
+	logStatsSupported bool
+)
+
+// logStatsIsSupported reports whether the kernel accepts the BPF_LOG_STATS
+// log level.
+func logStatsIsSupported() bool {
+	logStatsOnce.Do(func() {
+		logStatsSupported = C.cgo_probe_log_stats() == 0
+	})
+
+	return logStatsSupported
+}
+
+// loadProgress tracks the maps created and programs loaded by an object load.
+type loadProgress struct {
+	module *Module
+	start  time.Time
+	maps   map[string]bool // reported
+	progs  map[string]bool // reported
+	done   int
+	total  int
+	stats  map[string]*VerifierStats
+}
+
+var (
+	loadProgresses   = make(map[*loadProgress]struct{})
+	loadProgressesMu sync.Mutex
+)
+
+// startLoadProgress starts tracking the load of the module, if it has an
+// event handler or records the verifier statistics.
+func (m *Module) startLoadProgress() *loadProgress {
+	if m.eventHandler == nil && !m.recordVerifierStats {
+		return nil
+	}
+
+	p := &loadProgress{
+		module: m,
+		start:  time.Now(),
+		maps:   make(map[string]bool),
+		progs:  make(map[string]bool),
+		stats:  make(map[string]*VerifierStats),
+	}
+	for mapC := C.bpf_object__next_map(m.obj, nil); mapC != nil; mapC = C.bpf_object__next_map(m.obj, mapC) {
+		if C.bpf_map__autocreate(mapC) {
+			p.maps[C.GoString(C.bpf_map__name(mapC))] = false
+		}
+	}
+	for progC := C.bpf_object__next_program(m.obj, nil); progC != nil; progC = C.bpf_object__next_program(m.obj, progC) {
+		if C.bpf_program__autoload(progC) {
+			p.progs[C.GoString(C.bpf_program__name(progC))] = false
+			m.enableLogStats(progC)
+		}
+	}
+	p.total = len(p.maps) + len(p.progs)
+
+	loadProgressesMu.Lock()
+	loadProgresses[p] = struct{}{}
+	loadProgressesMu.Unlock()
+
+	return p
+}
+
+// enableLogStats sets the BPF_LOG_STATS log level on the program, so that
+// libbpf prints its kernel log once loaded, unless the log of the program is
+// already configured.
+func (m *Module) enableLogStats(progC *C.struct_bpf_program) {
+	if m.kernelLogBuf != nil || C.bpf_program__log_level(progC) != 0 {
+		return
+	}
+
+	var logSizeC C.size_t
+	if C.bpf_program__log_buf(progC, &logSizeC) != nil || !logStatsIsSupported() {
+		return
+	}
+
+	C.bpf_program__set_log_level(progC, logLevelStats)
+}
+
+// stop ends the tracking of the load.
+func (p *loadProgress) stop() {
+	if p == nil {
+		return
+	}
+
+	loadProgressesMu.Lock()
+	delete(loadProgresses, p)
+	loadProgressesMu.Unlock()
+}
+
+// reported reports whether the map or program was reported.
+func (p *loadProgress) reported(t ModuleEventType, name string) bool {
+	if p == nil {
+		return false
+	}
+	if t == ModuleEventMapCreated {
+		return p.maps[name]
+	}
+
+	return p.progs[name]
+}
+
+// tracked reports whether the map or program is part of the load, and not
+// reported yet.
+func (p *loadProgress) tracked(t ModuleEventType, name string) bool {
+	objs := p.progs
+	if t == ModuleEventMapCreated {
+		objs = p.maps
+	}
+	reported, ok := objs[name]
+
+	return ok && !reported
+}
+
+// record marks the map or program as reported, keeps the verifier statistics
+// of a program, if any, and returns its event.
+func (p *loadProgress) record(t ModuleEventType, name string, stats *VerifierStats) ModuleEvent {
+	e := ModuleEvent{Type: t, Name: name}
+	if stats != nil {
+		e.VerificationTime = stats.VerificationTime
+	}
+	if p == nil {
+		return e
+	}
+	if stats != nil {
+		p.stats[name] = stats
+	}
+
+	if t == ModuleEventMapCreated {
+		p.maps[name] = true
+	} else {
+		p.progs[name] = true
+	}
+	p.done++
+	e.Elapsed = time.Since(p.start)
+	e.Done = p.done
+	e.Total = p.total
+
+	return e
+}
+
+// feedLoadProgress feeds the libbpf output to the tracked loads, sending the
+// events of the maps created and programs loaded.
+func feedLoadProgress(level int, output string) {
+	loadProgressesMu.Lock()
+	if len(loadProgresses) == 0 {
+		loadProgressesMu.Unlock()
+		return
+	}
+
+	t, name, stats, ok := parseLoadProgress(level, output)
+	if !ok {
+		loadProgressesMu.Unlock()
+		return
+	}
+
+	var modules []*Module
+	var events []ModuleEvent
+	for p := range loadProgresses {
+		if p.tracked(t, name) {
+			modules = append(modules, p.module)
+			events = append(events, p.record(t, name, stats))
+		}
+	}
+	loadProgressesMu.Unlock()
+
+	// The handlers may be slow, don't hold the lock
+	for i, m := range modules {
+		m.emit(events[i])
+	}
+}
+
+// parseLoadProgress parses the libbpf output reporting a map created or a
+// program loaded, and the verifier statistics of a program, if any.
+func parseLoadProgress(level int, output string) (ModuleEventType, string, *VerifierStats, bool) {
+	if level != LibbpfDebugLevel {
+		// The log of a program failing to load is a warning
+		return 0, "", nil, false
+	}
+
+	if rest, ok := strings.CutPrefix(output, "map '"); ok {
+		name, rest, ok := strings.Cut(rest, "': ")
+		if ok && (strings.HasPrefix(rest, "created successfully") || strings.HasPrefix(rest, "skipping creation")) {
+			return ModuleEventMapCreated, name, nil, true
+		}
+		return 0, "", nil, false
+	}
+
+	rest, ok := strings.CutPrefix(output, "prog '")
+	if !ok {
+		return 0, "", nil, false
+	}
+	name, rest, ok := strings.Cut(rest, "': ")
+	if !ok || !strings.HasPrefix(rest, "-- BEGIN PROG LOAD LOG --") {
+		return 0, "", nil, false
+	}
+
+	if stats, ok := parseVerifierStats(rest); ok {
+		return ModuleEventProgramLoaded, name, &stats, true
+	}
+
+	return ModuleEventProgramLoaded, name, nil, true
+}
@@ -0,0 +1,94 @@
+package libbpfgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLoadProgress(t *testing.T) {
+	tests := []struct {
+		level            int
+		output           string
+		wantOk           bool
+		wantType         ModuleEventType
+		wantName         string
+		wantVerification time.Duration
+	}{
+		{LibbpfDebugLevel, "map 'events': created successfully, fd=5\n", true, ModuleEventMapCreated, "events", 0},
+		{LibbpfDebugLevel, "map 'pinned': skipping creation (preset fd=6)\n", true, ModuleEventMapCreated, "pinned", 0},
+		{LibbpfDebugLevel, "map 'events': found type = 27.\n", false, 0, "", 0},
+		{
+			LibbpfDebugLevel,
+			"prog 'kprobe__tcp_connect': -- BEGIN PROG LOAD LOG --\nverification time 1250 usec\nstack depth 16\nprocessed 42 insns (limit 1000000) max_states_per_insn 0 total_states 3 peak_states 3 mark_read 1\n-- END PROG LOAD LOG --\n",
+			true, ModuleEventProgramLoaded, "kprobe__tcp_connect", 1250 * time.Microsecond,
+		},
+		{LibbpfDebugLevel, "prog 'xdp_pass': -- BEGIN PROG LOAD LOG --\nprocessed 2 insns\n-- END PROG LOAD LOG --\n", true, ModuleEventProgramLoaded, "xdp_pass", 0},
+		{LibbpfDebugLevel, "prog 'xdp_pass': relo #0: insn #3 against 'events'\n", false, 0, "", 0},
+		// A program failing to load
+		{LibbpfWarnLevel, "prog 'bad': -- BEGIN PROG LOAD LOG --\ninvalid mem access\n-- END PROG LOAD LOG --\n", false, 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		gotType, gotName, gotStats, gotOk := parseLoadProgress(tt.level, tt.output)
+		require.Equal(t, tt.wantOk, gotOk, tt.output)
+		assert.Equal(t, tt.wantType, gotType, tt.output)
+		assert.Equal(t, tt.wantName, gotName, tt.output)
+		if tt.wantVerification == 0 {
+			assert.Nil(t, gotStats, tt.output)
+		} else {
+			require.NotNil(t, gotStats, tt.output)
+			assert.Equal(t, tt.wantVerification, gotStats.VerificationTime, tt.output)
+		}
+	}
+}
+
+func TestFeedLoadProgress(t *testing.T) {
+	var got []ModuleEvent
+	m := &Module{eventHandler: func(e ModuleEvent) { got = append(got, e) }}
+
+	p := &loadProgress{
+		module: m,
+		start:  time.Now(),
+		maps:   map[string]bool{"events": false},
+		progs:  map[string]bool{"handler": false, "other": false},
+		total:  3,
+		stats:  make(map[string]*VerifierStats),
+	}
+	loadProgressesMu.Lock()
+	loadProgresses[p] = struct{}{}
+	loadProgressesMu.Unlock()
+
+	feedLoadProgress(LibbpfDebugLevel, "map 'events': created successfully, fd=5\n")
+	feedLoadProgress(LibbpfDebugLevel, "map 'unknown': created successfully, fd=6\n")
+	feedLoadProgress(LibbpfDebugLevel, "prog 'handler': -- BEGIN PROG LOAD LOG --\nverification time 10 usec\nstack depth 8\nprocessed 5 insns (limit 1000000) max_states_per_insn 0 total_states 1 peak_states 1 mark_read 0\n-- END PROG LOAD LOG --\n")
+	feedLoadProgress(LibbpfDebugLevel, "map 'events': created successfully, fd=7\n") // already reported
+	p.stop()
+	feedLoadProgress(LibbpfDebugLevel, "prog 'other': -- BEGIN PROG LOAD LOG --\n-- END PROG LOAD LOG --\n")
+
+	require.Len(t, got, 2)
+	assert.Equal(t, ModuleEventMapCreated, got[0].Type)
+	assert.Equal(t, "events", got[0].Name)
+	assert.Equal(t, 1, got[0].Done)
+	assert.Equal(t, 3, got[0].Total)
+	assert.Equal(t, ModuleEventProgramLoaded, got[1].Type)
+	assert.Equal(t, "handler", got[1].Name)
+	assert.Equal(t, 10*time.Microsecond, got[1].VerificationTime)
+	assert.Equal(t, 2, got[1].Done)
+	require.Contains(t, p.stats, "handler")
+	assert.Equal(t, uint32(5), p.stats["handler"].InsnsProcessed)
+	assert.Equal(t, ": program loaded handler (2/3, verified in 10µs)", got[1].String())
+
+	// The programs not seen during the load are reported once loaded
+	assert.True(t, p.reported(ModuleEventProgramLoaded, "handler"))
+	assert.False(t, p.reported(ModuleEventProgramLoaded, "other"))
+	e := p.record(ModuleEventProgramLoaded, "other", nil)
+	assert.Equal(t, 3, e.Done)
+
+	// Without tracking, the events have no progress
+	var none *loadProgress
+	assert.False(t, none.reported(ModuleEventMapCreated, "events"))
+	assert.Zero(t, none.record(ModuleEventMapCreated, "events", nil).Total)
+}
@@ -22,6 +22,11 @@ import (
 func loggerCallback(libbpfPrintLevel int, libbpfOutput *C.char) {
 	goOutput := C.GoString(libbpfOutput)
 
+	// feed error classification before the output is filtered out
+	captureLog(goOutput)
+	feedLoadProgress(libbpfPrintLevel, goOutput)
+	feedCORERelocations(goOutput)
+
 	for _, fnFilterOut := range callbacks.LogFilters {
 		if fnFilterOut != nil {
 			if fnFilterOut(libbpfPrintLevel, goOutput) {
@@ -7,8 +7,10 @@ package libbpfgo
 import "C"
 
 import (
+	"errors"
 	"fmt"
 	"syscall"
+	"unsafe"
 )
 
 //
@@ -182,22 +184,246 @@ func CalcMapValueSize(valueSize int, mapType MapType) (int, error) {
 		return 0, fmt.Errorf("value size must be greater than 0")
 	}
 
+	if !isPerCPUMapType(mapType) {
+		// For other maps, the value size does not change.
+		return valueSize, nil
+	}
+
+	// per-CPU maps have a value size calculated using a round-up of the
+	// element size multiplied by the number of possible CPUs.
+	elemSize := roundUp(uint64(valueSize), 8)
+	numCPU, err := NumPossibleCPUs()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(elemSize) * numCPU, nil
+}
+
+// isPerCPUMapType reports whether the maps of the type hold a value per CPU.
+func isPerCPUMapType(mapType MapType) bool {
 	switch mapType {
 	case MapTypePerCPUArray,
 		MapTypePerCPUHash,
 		MapTypeLRUPerCPUHash,
 		MapTypePerCPUCgroupStorage:
-		// per-CPU maps have a value size calculated using a round-up of the
-		// element size multiplied by the number of possible CPUs.
-		elemSize := roundUp(uint64(valueSize), 8)
-		numCPU, err := NumPossibleCPUs()
-		if err != nil {
-			return 0, err
+		return true
+	}
+
+	return false
+}
+
+// deleteAllKeys deletes the keys of a map one by one, with its get next key
+// and delete key functions. The next key is fetched before the current one is
+// deleted, otherwise hash maps would restart from the first key every time.
+func deleteAllKeys(
+	keySize int,
+	getNextKey func(key unsafe.Pointer, nextKey unsafe.Pointer) error,
+	deleteKey func(key unsafe.Pointer) error,
+) (int, error) {
+	if keySize <= 0 {
+		return 0, fmt.Errorf("invalid key size %d", keySize)
+	}
+
+	key := make([]byte, keySize)
+	next := make([]byte, keySize)
+	deleted := 0
+
+	err := getNextKey(nil, unsafe.Pointer(&key[0]))
+	for err == nil {
+		err = getNextKey(unsafe.Pointer(&key[0]), unsafe.Pointer(&next[0]))
+
+		// The key may have been deleted concurrently
+		if errDel := deleteKey(unsafe.Pointer(&key[0])); errDel == nil {
+			deleted++
+		} else if !errors.Is(errDel, syscall.ENOENT) {
+			return deleted, errDel
 		}
 
-		return int(elemSize) * numCPU, nil
-	default:
-		// For other maps, the value size does not change.
-		return valueSize, nil
+		key, next = next, key
+	}
+	if !errors.Is(err, ErrNoMoreKeys) {
+		return deleted, err
+	}
+
+	return deleted, nil
+}
+
+// walkBatchSize is the number of elements read by each batch lookup when
+// walking the keys of a map.
+const walkBatchSize = 256
+
+// countEntries counts the entries of a map, with its batch lookup function
+// if the kernel supports it for the map type, or else with its get next key
+// function. Arrays are always full, their count is maxEntries.
+func countEntries(
+	mapType MapType,
+	keySize int,
+	maxEntries uint32,
+	lookupBatch func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error),
+	getNextKey func(key unsafe.Pointer, nextKey unsafe.Pointer) error,
+) (uint32, error) {
+	switch mapType {
+	case MapTypeArray, MapTypePerCPUArray:
+		return maxEntries, nil
+	case MapTypeRingbuf, MapTypeQueue, MapTypeStack, MapTypeBloomFilter:
+		return 0, fmt.Errorf("map type %s has no keys: %w", mapType, syscall.EINVAL)
+	}
+
+	return walkKeys(keySize, maxEntries, lookupBatch, getNextKey, nil)
+}
+
+// walkKeys calls visit, if not nil, with each key of a map, and returns the
+// number of keys. It reads the keys with the batch lookup function if the
+// kernel supports it for the map type, or else with the get next key
+// function. The key passed to visit is only valid during the call.
+//
+// The walk is a snapshot racing with the writers of the map, so it stops at
+// maxEntries keys, as a key deleted during the walk restarts the get next key
+// walk of hash maps from the first key.
+func walkKeys(
+	keySize int,
+	maxEntries uint32,
+	lookupBatch func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error),
+	getNextKey func(key unsafe.Pointer, nextKey unsafe.Pointer) error,
+	visit func(key []byte),
+) (uint32, error) {
+	if keySize <= 0 {
+		return 0, fmt.Errorf("invalid key size %d", keySize)
+	}
+	if visit == nil {
+		visit = func([]byte) {}
+	}
+
+	n, err := walkKeysBatch(keySize, maxEntries, lookupBatch, visit)
+	if err == nil {
+		return n, nil
+	}
+	if n > 0 || !batchNotSupported(err) {
+		return 0, err
 	}
+
+	return walkKeysIter(keySize, maxEntries, getNextKey, visit)
+}
+
+// batchNotSupported reports whether a batch operation failed because the
+// kernel does not support it. Batch operations are v5.6+, and not implemented
+// by all map types. A hash bucket bigger than the batch fails with ENOSPC.
+func batchNotSupported(err error) bool {
+	return errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.EOPNOTSUPP) ||
+		errors.Is(err, enotsupp) ||
+		errors.Is(err, syscall.ENOSPC)
+}
+
+// walkKeysBatch looks the keys up by batches until ENOENT, the keys of the
+// last batch included. It returns the number of keys visited before a
+// failure, the walk can only be retried with walkKeysIter if none was.
+func walkKeysBatch(
+	keySize int,
+	maxEntries uint32,
+	lookupBatch func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error),
+	visit func(key []byte),
+) (uint32, error) {
+	batchSize := min(max(maxEntries, 1), walkBatchSize)
+	keys := make([]byte, keySize*int(batchSize))
+	// The batch tokens are opaque to userspace: keys for some maps, bucket
+	// indexes for hash maps.
+	in := make([]byte, max(keySize, 8))
+	out := make([]byte, max(keySize, 8))
+
+	var (
+		startKey unsafe.Pointer
+		total    uint32
+	)
+	for total < maxEntries {
+		n, err := lookupBatch(unsafe.Pointer(&keys[0]), startKey, unsafe.Pointer(&out[0]), batchSize)
+		end := errors.Is(err, syscall.ENOENT)
+		if err != nil && !end {
+			return total, err
+		}
+
+		n = min(n, maxEntries-total)
+		for i := 0; i < int(n); i++ {
+			visit(keys[i*keySize : (i+1)*keySize])
+		}
+		total += n
+		// Only ENOENT ends the walk: hash maps return short batches whenever
+		// the next bucket does not fit in the batch.
+		if end || n == 0 {
+			break
+		}
+
+		in, out = out, in
+		startKey = unsafe.Pointer(&in[0])
+	}
+
+	return total, nil
+}
+
+func walkKeysIter(
+	keySize int,
+	maxEntries uint32,
+	getNextKey func(key unsafe.Pointer, nextKey unsafe.Pointer) error,
+	visit func(key []byte),
+) (uint32, error) {
+	key := make([]byte, keySize)
+	next := make([]byte, keySize)
+
+	var total uint32
+	err := getNextKey(nil, unsafe.Pointer(&key[0]))
+	for err == nil && total < maxEntries {
+		visit(key)
+		total++
+		err = getNextKey(unsafe.Pointer(&key[0]), unsafe.Pointer(&next[0]))
+		key, next = next, key
+	}
+	if err != nil && !errors.Is(err, ErrNoMoreKeys) {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// utilization returns the ratio of the entries counted by count to
+// maxEntries.
+func utilization(count func() (uint32, error), maxEntries uint32) (float64, error) {
+	if maxEntries == 0 {
+		return 0, fmt.Errorf("invalid max entries %d", maxEntries)
+	}
+
+	n, err := count()
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(n) / float64(maxEntries), nil
+}
+
+// MapBatchResult is the progress of a batch operation.
+type MapBatchResult struct {
+	// Count is the number of elements processed. Their keys and values are
+	// valid even if the operation failed on the next one.
+	Count uint32
+	// Done is set by the lookups having read the last elements of the map.
+	// Their next key is not set.
+	Done bool
+}
+
+// batchOutcome interprets the return of a batch operation having processed
+// count elements. endErrno is the errno the operation fails with past the
+// end of the map, ENOENT for the lookups, or 0.
+func batchOutcome(retC int, count uint32, endErrno syscall.Errno) (MapBatchResult, error) {
+	result := MapBatchResult{Count: count}
+	if retC >= 0 {
+		return result, nil
+	}
+
+	errno := syscall.Errno(-retC)
+	if endErrno != 0 && errno == endErrno {
+		result.Done = true
+		return result, nil
+	}
+
+	return result, &MapBatchError{Index: count, Err: classifyError(opMapBatch, errno, "")}
 }
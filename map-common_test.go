@@ -0,0 +1,297 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHashMap mimics the get next key semantics of a hash map with u32 keys:
+// a key which is not in the map restarts the iteration from the first key.
+type fakeHashMap struct {
+	keys map[uint32]bool
+	// onDelete is called before each deletion, to simulate concurrent writers
+	onDelete func(key uint32)
+}
+
+func (f *fakeHashMap) sorted() []uint32 {
+	keys := make([]uint32, 0, len(f.keys))
+	for k := range f.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
+}
+
+func (f *fakeHashMap) getNextKey(key unsafe.Pointer, nextKey unsafe.Pointer) error {
+	keys := f.sorted()
+
+	i := 0
+	if key != nil {
+		cur := *(*uint32)(key)
+		if f.keys[cur] {
+			i = sort.Search(len(keys), func(i int) bool { return keys[i] > cur })
+		}
+	}
+	if i >= len(keys) {
+		return nextKeyError(fmt.Errorf("failed to get next key: %w", syscall.ENOENT), syscall.ENOENT)
+	}
+	binary.NativeEndian.PutUint32(unsafe.Slice((*byte)(nextKey), 4), keys[i])
+
+	return nil
+}
+
+func (f *fakeHashMap) deleteKey(key unsafe.Pointer) error {
+	k := *(*uint32)(key)
+	if f.onDelete != nil {
+		f.onDelete(k)
+	}
+	if !f.keys[k] {
+		return fmt.Errorf("failed to delete key: %w", syscall.ENOENT)
+	}
+	delete(f.keys, k)
+
+	return nil
+}
+
+func newFakeHashMap(n int) *fakeHashMap {
+	f := &fakeHashMap{keys: map[uint32]bool{}}
+	for i := 0; i < n; i++ {
+		f.keys[uint32(i*10)] = true
+	}
+
+	return f
+}
+
+func TestDeleteAllKeys(t *testing.T) {
+	f := newFakeHashMap(100)
+	n, err := deleteAllKeys(4, f.getNextKey, f.deleteKey)
+	require.NoError(t, err)
+	assert.Equal(t, 100, n)
+	assert.Empty(t, f.keys)
+
+	// Empty map
+	n, err = deleteAllKeys(4, f.getNextKey, f.deleteKey)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = deleteAllKeys(0, f.getNextKey, f.deleteKey)
+	assert.Error(t, err)
+}
+
+func TestDeleteAllKeysConcurrentWriters(t *testing.T) {
+	f := newFakeHashMap(10)
+	f.onDelete = func(key uint32) {
+		switch key {
+		case 20:
+			// Another writer deletes the key about to be deleted, and the
+			// next one, which was already fetched
+			delete(f.keys, 20)
+			delete(f.keys, 30)
+		case 50:
+			// Another writer adds keys while the next key was fetched
+			f.keys[5] = true
+			f.keys[55] = true
+		}
+	}
+
+	n, err := deleteAllKeys(4, f.getNextKey, f.deleteKey)
+	require.NoError(t, err)
+	assert.Equal(t, 8, n)
+	// The keys added meanwhile are missed
+	assert.Equal(t, []uint32{5, 55}, f.sorted())
+}
+
+func TestDeleteAllKeysError(t *testing.T) {
+	f := newFakeHashMap(3)
+	errDelete := errors.New("delete failed")
+	n, err := deleteAllKeys(4, f.getNextKey, func(key unsafe.Pointer) error {
+		return errDelete
+	})
+	assert.ErrorIs(t, err, errDelete)
+	assert.Zero(t, n)
+}
+
+// fakeBucketSize is the number of keys of the buckets of fakeHashMap.
+const fakeBucketSize = 3
+
+// lookupBatch mimics the batch lookup of a hash map, whose batch token is
+// the index of the next key to read. Like the kernel, it only returns whole
+// buckets, so batches are short whenever the next bucket does not fit, and
+// it returns the last keys with ENOENT.
+func (f *fakeHashMap) lookupBatch(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error) {
+	sorted := f.sorted()
+
+	start := 0
+	if startKey != nil {
+		start = int(*(*uint32)(startKey))
+	}
+	if start >= len(sorted) {
+		return 0, fmt.Errorf("failed to batch get value: %w", syscall.ENOENT)
+	}
+
+	n := min(len(sorted)-start, int(count))
+	if start+n < len(sorted) {
+		n -= n % fakeBucketSize
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("failed to batch get value: %w", syscall.ENOSPC)
+	}
+	out := unsafe.Slice((*uint32)(keys), count)
+	copy(out, sorted[start:start+n])
+	*(*uint32)(nextKey) = uint32(start + n)
+	if start+n == len(sorted) {
+		return uint32(n), fmt.Errorf("failed to batch get value: %w", syscall.ENOENT)
+	}
+
+	return uint32(n), nil
+}
+
+func TestCountEntries(t *testing.T) {
+	tt := []struct {
+		name       string
+		keys       int
+		maxEntries uint32
+		want       uint32
+	}{
+		{"empty", 0, 1024, 0},
+		{"partial batch", 100, 1024, 100},
+		{"full batches", 2 * walkBatchSize, 1024, 2 * walkBatchSize},
+		{"several batches", 600, 1024, 600},
+		{"small map", 3, 3, 3},
+		{"capped", 100, 50, 50},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeHashMap(tc.keys)
+			n, err := countEntries(MapTypeHash, 4, tc.maxEntries, f.lookupBatch, f.getNextKey)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, n)
+		})
+	}
+}
+
+func TestCountEntriesFallback(t *testing.T) {
+	f := newFakeHashMap(100)
+	calls := 0
+	lookupBatch := func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error) {
+		calls++
+		return 0, fmt.Errorf("failed to batch get value: %w", syscall.EINVAL)
+	}
+
+	n, err := countEntries(MapTypeLRUHash, 4, 1024, lookupBatch, f.getNextKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(100), n)
+	assert.Equal(t, 1, calls)
+
+	// Other errors are not retried
+	lookupBatch = func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error) {
+		return 0, fmt.Errorf("failed to batch get value: %w", syscall.EPERM)
+	}
+	_, err = countEntries(MapTypeHash, 4, 1024, lookupBatch, f.getNextKey)
+	assert.ErrorIs(t, err, syscall.EPERM)
+}
+
+func TestCountEntriesMapTypes(t *testing.T) {
+	f := newFakeHashMap(10)
+
+	n, err := countEntries(MapTypeArray, 4, 64, f.lookupBatch, f.getNextKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(64), n)
+
+	_, err = countEntries(MapTypeRingbuf, 0, 4096, f.lookupBatch, f.getNextKey)
+	assert.ErrorIs(t, err, syscall.EINVAL)
+
+	_, err = countEntries(MapTypeHash, 0, 64, f.lookupBatch, f.getNextKey)
+	assert.Error(t, err)
+}
+
+func TestUtilization(t *testing.T) {
+	u, err := utilization(func() (uint32, error) { return 25, nil }, 100)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.25, u, 1e-9)
+
+	_, err = utilization(func() (uint32, error) { return 0, nil }, 0)
+	assert.Error(t, err)
+
+	errCount := errors.New("count failed")
+	_, err = utilization(func() (uint32, error) { return 0, errCount }, 100)
+	assert.ErrorIs(t, err, errCount)
+}
+
+func TestWalkKeys(t *testing.T) {
+	f := newFakeHashMap(600)
+	for _, lookupBatch := range []func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error){
+		f.lookupBatch,
+		// The last keys read without error, as GetValueBatch() returns them
+		func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error) {
+			n, err := f.lookupBatch(keys, startKey, nextKey, count)
+			if n > 0 && errors.Is(err, syscall.ENOENT) {
+				return n, nil
+			}
+			return n, err
+		},
+		func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error) {
+			return 0, fmt.Errorf("failed to batch get value: %w", syscall.EOPNOTSUPP)
+		},
+	} {
+		var visited []uint32
+		n, err := walkKeys(4, 1024, lookupBatch, f.getNextKey, func(key []byte) {
+			visited = append(visited, binary.NativeEndian.Uint32(key))
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint32(600), n)
+		assert.Equal(t, f.sorted(), visited)
+	}
+}
+
+func TestWalkKeysBatchFailure(t *testing.T) {
+	f := newFakeHashMap(600)
+	calls := 0
+	lookupBatch := func(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error) {
+		calls++
+		if calls > 1 {
+			return 0, fmt.Errorf("failed to batch get value: %w", syscall.EINVAL)
+		}
+		return f.lookupBatch(keys, startKey, nextKey, count)
+	}
+
+	// Keys were visited already, the walk is not restarted
+	visited := 0
+	_, err := walkKeys(4, 1024, lookupBatch, f.getNextKey, func(key []byte) { visited++ })
+	assert.ErrorIs(t, err, syscall.EINVAL)
+	assert.Equal(t, walkBatchSize-walkBatchSize%fakeBucketSize, visited)
+}
+
+func TestBatchOutcome(t *testing.T) {
+	result, err := batchOutcome(0, 8, syscall.ENOENT)
+	require.NoError(t, err)
+	assert.Equal(t, MapBatchResult{Count: 8}, result)
+
+	// Past the end of the map
+	result, err = batchOutcome(-int(syscall.ENOENT), 3, syscall.ENOENT)
+	require.NoError(t, err)
+	assert.Equal(t, MapBatchResult{Count: 3, Done: true}, result)
+
+	// Stopped at an element
+	result, err = batchOutcome(-int(syscall.E2BIG), 5, 0)
+	assert.Equal(t, MapBatchResult{Count: 5}, result)
+	var batchErr *MapBatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.EqualValues(t, 5, batchErr.Index)
+	assert.ErrorIs(t, err, syscall.E2BIG)
+	assert.Equal(t, "element 5: argument list too long", err.Error())
+
+	// ENOENT is an element error for updates and deletions
+	_, err = batchOutcome(-int(syscall.ENOENT), 0, 0)
+	assert.ErrorIs(t, err, syscall.ENOENT)
+}
@@ -0,0 +1,259 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//
+// Map value decoding
+//
+// DecodeKey(), DecodeValue() and DumpJSON() decode the keys and values of a
+// map with its BTF, into values encoding/json marshals: structs become
+// objects of their members, arrays become arrays, char arrays strings and
+// integers numbers. Keys and values without BTF are hex strings.
+//
+// The special fields of map values (bpf_spin_lock, bpf_timer, bpf_wq, kptrs,
+// list and rbtree nodes, ...) are objects of the kernel: a lookup returns
+// them zeroed or stale. They are masked, decoded as their type name between
+// angle brackets ("<bpf_timer>", "<kptr>").
+//
+
+// specialFieldTypes are the structs of the special fields of map values.
+var specialFieldTypes = []string{
+	"bpf_spin_lock",
+	"bpf_res_spin_lock",
+	"bpf_timer",
+	"bpf_wq",
+	"bpf_task_work",
+	"bpf_list_head",
+	"bpf_list_node",
+	"bpf_rb_root",
+	"bpf_rb_node",
+	"bpf_refcount",
+}
+
+// kptrTypeTags are the BTF type tags of the pointers of map values to kernel
+// objects.
+var kptrTypeTags = []string{
+	"kptr",
+	"kptr_untrusted",
+	"kptr_ref",
+	"percpu_kptr",
+	"uptr",
+}
+
+type valueKind int
+
+const (
+	valueBytes valueKind = iota // opaque, decoded as a hex string
+	valueInt
+	valueBool
+	valueFloat
+	valueString // char array
+	valueArray
+	valueStruct
+	valueMasked
+)
+
+// valueLayout is the layout of a map key or value, or of one of their
+// members, resolved from BTF.
+type valueLayout struct {
+	kind   valueKind
+	size   int
+	signed bool
+	name   string       // of masked fields
+	elem   *valueLayout // of arrays
+	count  int          // of arrays
+	fields []valueField // of structs
+}
+
+// valueField is a member of a struct.
+type valueField struct {
+	name      string // "" for anonymous structs and unions
+	bitOffset int
+	bitSize   int // of bitfields, 0 otherwise
+	layout    *valueLayout
+}
+
+// decode decodes data laid out as l.
+func (l *valueLayout) decode(data []byte) any {
+	if len(data) < l.size {
+		return hexString(data)
+	}
+	data = data[:l.size]
+
+	switch l.kind {
+	case valueInt:
+		return decodeInt(data, l.signed)
+	case valueBool:
+		return decodeInt(data, false) != uint64(0)
+	case valueFloat:
+		return decodeFloat(data)
+	case valueString:
+		if s, _, _ := strings.Cut(string(data), "\x00"); isPrintable(s) {
+			return s
+		}
+	case valueArray:
+		elems := make([]any, l.count)
+		for i := range elems {
+			elems[i] = l.elem.decode(data[i*l.elem.size:])
+		}
+		return elems
+	case valueStruct:
+		return l.decodeStruct(data)
+	case valueMasked:
+		return "<" + l.name + ">"
+	}
+
+	return hexString(data)
+}
+
+// decodePerCPU decodes the values of each CPU of a per-CPU map, each rounded
+// up to 8 bytes.
+func (l *valueLayout) decodePerCPU(data []byte) []any {
+	stride := (l.size + 7) &^ 7
+	if stride == 0 {
+		return nil
+	}
+
+	values := make([]any, 0, len(data)/stride)
+	for off := 0; off+stride <= len(data); off += stride {
+		values = append(values, l.decode(data[off:off+stride]))
+	}
+
+	return values
+}
+
+func (l *valueLayout) decodeStruct(data []byte) map[string]any {
+	members := make(map[string]any, len(l.fields))
+	for i, f := range l.fields {
+		var v any
+		if f.bitSize != 0 {
+			v = decodeBitfield(data, f.bitOffset, f.bitSize, f.layout.signed)
+		} else {
+			v = f.layout.decode(data[f.bitOffset/8:])
+		}
+
+		if f.name != "" {
+			members[f.name] = v
+			continue
+		}
+		// The members of anonymous structs and unions are the struct's own
+		nested, ok := v.(map[string]any)
+		if !ok {
+			members[fmt.Sprintf("_%d", i)] = v
+			continue
+		}
+		for name, nv := range nested {
+			members[name] = nv
+		}
+	}
+
+	return members
+}
+
+// decodeInt decodes an integer of 1, 2, 4 or 8 bytes, as an int64 if signed,
+// an uint64 otherwise. Larger integers are hex strings.
+func decodeInt(data []byte, signed bool) any {
+	var u uint64
+	switch len(data) {
+	case 1:
+		u = uint64(data[0])
+	case 2:
+		u = uint64(binary.NativeEndian.Uint16(data))
+	case 4:
+		u = uint64(binary.NativeEndian.Uint32(data))
+	case 8:
+		u = binary.NativeEndian.Uint64(data)
+	default:
+		return hexString(data)
+	}
+	if signed {
+		return signExtend(u, len(data)*8)
+	}
+
+	return u
+}
+
+// decodeBitfield decodes the bitfield of bitSize bits at bitOffset, BTF bit
+// offsets counting from the least significant bit on little endian hosts,
+// from the most significant one on big endian hosts.
+func decodeBitfield(data []byte, bitOffset, bitSize int, signed bool) any {
+	start := bitOffset / 8
+	end := (bitOffset + bitSize + 7) / 8
+	if bitSize > 64 || end-start > 8 || end > len(data) {
+		return hexString(data[min(start, len(data)):min(end, len(data))])
+	}
+
+	bytes := data[start:end]
+	var u uint64
+	var shift int
+	if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+		for _, b := range bytes {
+			u = u<<8 | uint64(b)
+		}
+		shift = len(bytes)*8 - bitOffset%8 - bitSize
+	} else {
+		for i := len(bytes) - 1; i >= 0; i-- {
+			u = u<<8 | uint64(bytes[i])
+		}
+		shift = bitOffset % 8
+	}
+	u = (u >> shift) & (math.MaxUint64 >> (64 - bitSize))
+
+	if signed {
+		return signExtend(u, bitSize)
+	}
+
+	return u
+}
+
+func signExtend(u uint64, bits int) int64 {
+	shift := 64 - bits
+
+	return int64(u<<shift) >> shift
+}
+
+// decodeFloat decodes a float of 4 or 8 bytes. The values JSON can not
+// represent (NaN and infinities) are strings.
+func decodeFloat(data []byte) any {
+	var f float64
+	switch len(data) {
+	case 4:
+		f = float64(math.Float32frombits(binary.NativeEndian.Uint32(data)))
+	case 8:
+		f = math.Float64frombits(binary.NativeEndian.Uint64(data))
+	default:
+		return hexString(data)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+
+	return f
+}
+
+// isPrintable reports whether s is printable UTF-8, so that char arrays
+// holding binary data are not decoded as strings.
+func isPrintable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !strconv.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hexString(data []byte) string {
+	return "0x" + hex.EncodeToString(data)
+}
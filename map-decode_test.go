@@ -0,0 +1,140 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueLayoutDecode(t *testing.T) {
+	u32 := &valueLayout{kind: valueInt, size: 4}
+	s16 := &valueLayout{kind: valueInt, size: 2, signed: true}
+	comm := &valueLayout{kind: valueString, size: 8, elem: &valueLayout{kind: valueInt, size: 1}, count: 8}
+
+	// struct {
+	//	u32 pid;
+	//	s16 delta;
+	//	char comm[8];
+	//	struct bpf_spin_lock lock;
+	//	struct bpf_timer timer;
+	//	struct task_struct __kptr *task;
+	//	u32 counts[2];
+	//	union { u32 a; u16 b; };
+	// };
+	l := &valueLayout{
+		kind: valueStruct,
+		size: 56,
+		fields: []valueField{
+			{name: "pid", bitOffset: 0, layout: u32},
+			{name: "delta", bitOffset: 32, layout: s16},
+			{name: "comm", bitOffset: 48, layout: comm},
+			{name: "lock", bitOffset: 112, layout: &valueLayout{kind: valueMasked, size: 4, name: "bpf_spin_lock"}},
+			{name: "timer", bitOffset: 128, layout: &valueLayout{kind: valueMasked, size: 16, name: "bpf_timer"}},
+			{name: "task", bitOffset: 256, layout: &valueLayout{kind: valueMasked, size: 8, name: "kptr"}},
+			{name: "counts", bitOffset: 320, layout: &valueLayout{kind: valueArray, size: 8, elem: u32, count: 2}},
+			{name: "", bitOffset: 384, layout: &valueLayout{kind: valueBytes, size: 4}},
+		},
+	}
+
+	data := make([]byte, 56)
+	binary.NativeEndian.PutUint32(data[0:], 1234)
+	binary.NativeEndian.PutUint16(data[4:], uint16(0xfffe)) // -2
+	copy(data[6:], "bash\x00xx")
+	for i := 14; i < 40; i++ {
+		data[i] = 0xff // garbage in the special fields
+	}
+	binary.NativeEndian.PutUint32(data[40:], 7)
+	binary.NativeEndian.PutUint32(data[44:], 9)
+	copy(data[48:], []byte{1, 2, 3, 4})
+
+	v := l.decode(data)
+	assert.Equal(t, map[string]any{
+		"pid":    uint64(1234),
+		"delta":  int64(-2),
+		"comm":   "bash",
+		"lock":   "<bpf_spin_lock>",
+		"timer":  "<bpf_timer>",
+		"task":   "<kptr>",
+		"counts": []any{uint64(7), uint64(9)},
+		"_7":     "0x01020304",
+	}, v)
+
+	_, err := json.Marshal(v)
+	require.NoError(t, err)
+}
+
+func TestValueLayoutDecodeAnonymousStruct(t *testing.T) {
+	u8 := &valueLayout{kind: valueInt, size: 1}
+	l := &valueLayout{
+		kind: valueStruct,
+		size: 2,
+		fields: []valueField{
+			{name: "a", layout: u8},
+			{bitOffset: 8, layout: &valueLayout{
+				kind:   valueStruct,
+				size:   1,
+				fields: []valueField{{name: "b", layout: u8}},
+			}},
+		},
+	}
+
+	assert.Equal(t, map[string]any{"a": uint64(1), "b": uint64(2)}, l.decode([]byte{1, 2}))
+}
+
+func TestValueLayoutDecodeScalars(t *testing.T) {
+	b := &valueLayout{kind: valueBool, size: 1}
+	assert.Equal(t, true, b.decode([]byte{1}))
+	assert.Equal(t, false, b.decode([]byte{0}))
+
+	f := &valueLayout{kind: valueFloat, size: 8}
+	data := make([]byte, 8)
+	binary.NativeEndian.PutUint64(data, math.Float64bits(1.5))
+	assert.Equal(t, 1.5, f.decode(data))
+	binary.NativeEndian.PutUint64(data, math.Float64bits(math.Inf(-1)))
+	assert.Equal(t, "-Inf", f.decode(data))
+
+	// Binary data in char arrays, and values too short, are hex strings
+	str := &valueLayout{kind: valueString, size: 4}
+	assert.Equal(t, "0x0a000001", str.decode([]byte{10, 0, 0, 1}))
+	u64 := &valueLayout{kind: valueInt, size: 8}
+	assert.Equal(t, "0x0102", u64.decode([]byte{1, 2}))
+
+	i128 := &valueLayout{kind: valueInt, size: 16}
+	assert.Equal(t, "0x0001"+strings.Repeat("00", 14), i128.decode(append([]byte{0, 1}, make([]byte, 14)...)))
+}
+
+func TestDecodeBitfield(t *testing.T) {
+	// struct { u8 a:3; s8 b:4; u16 c:9; }, laid out by the native endianness
+	var u uint32
+	a, b, c := uint32(5), uint32(0xe), uint32(0x1ab) // b is -2
+	data := make([]byte, 4)
+	if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+		u = a<<29 | b<<25 | c<<16
+		binary.BigEndian.PutUint32(data, u)
+	} else {
+		u = a | b<<3 | c<<7
+		binary.LittleEndian.PutUint32(data, u)
+	}
+
+	assert.Equal(t, uint64(5), decodeBitfield(data, 0, 3, false))
+	assert.Equal(t, int64(-2), decodeBitfield(data, 3, 4, true))
+	assert.Equal(t, uint64(0x1ab), decodeBitfield(data, 7, 9, false))
+	assert.Equal(t, "0x", decodeBitfield(data, 40, 3, false))
+}
+
+func TestValueLayoutDecodePerCPU(t *testing.T) {
+	u32 := &valueLayout{kind: valueInt, size: 4}
+
+	// Each value of a CPU is rounded up to 8 bytes
+	data := make([]byte, 24)
+	binary.NativeEndian.PutUint32(data[0:], 1)
+	binary.NativeEndian.PutUint32(data[8:], 2)
+	binary.NativeEndian.PutUint32(data[16:], 3)
+
+	assert.Equal(t, []any{uint64(1), uint64(2), uint64(3)}, u32.decodePerCPU(data))
+}
@@ -0,0 +1,208 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"syscall"
+	"unsafe"
+)
+
+// DecodeKey decodes a key of the map with its BTF. See DumpJSON().
+func (m *BPFMap) DecodeKey(key []byte) any {
+	return m.keyLayout().decode(key)
+}
+
+// DecodeValue decodes a value of the map, as returned by GetValue(), with its
+// BTF. The value of per-CPU maps is decoded as the values of each CPU. See
+// DumpJSON().
+func (m *BPFMap) DecodeValue(value []byte) any {
+	l := m.valueLayout()
+	if isPerCPUMapType(m.Type()) {
+		return l.decodePerCPU(value)
+	}
+
+	return l.decode(value)
+}
+
+// mapEntryJSON is an entry of the map dumped by DumpJSON().
+type mapEntryJSON struct {
+	Key   any `json:"key"`
+	Value any `json:"value"`
+}
+
+// DumpJSON writes the entries of the map to w as a JSON array of
+// {"key": ..., "value": ...} objects, with the keys and values decoded from
+// their BTF: structs are objects, arrays are arrays, char arrays strings and
+// integers numbers. The special fields of values, which a lookup does not
+// read (bpf_spin_lock, bpf_timer, bpf_wq, kptrs, ...), are masked.
+//
+// Entries deleted while the map is dumped are skipped.
+func (m *BPFMap) DumpJSON(w io.Writer) error {
+	keyLayout := m.keyLayout()
+	valueLayout := m.valueLayout()
+	perCPU := isPerCPUMapType(m.Type())
+
+	bw := bufio.NewWriter(w)
+	sep := "["
+	it := m.Iterator()
+	for it.Next() {
+		key := it.Key()
+		value, err := m.GetValue(unsafe.Pointer(&key[0]))
+		if errors.Is(err, syscall.ENOENT) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to dump map %s: %w", m.Name(), err)
+		}
+
+		entry := mapEntryJSON{Key: keyLayout.decode(key)}
+		if perCPU {
+			entry.Value = valueLayout.decodePerCPU(value)
+		} else {
+			entry.Value = valueLayout.decode(value)
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to dump map %s: %w", m.Name(), err)
+		}
+
+		_, _ = bw.WriteString(sep)
+		_, _ = bw.Write(data)
+		sep = ","
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("failed to dump map %s: %w", m.Name(), err)
+	}
+	if sep == "[" {
+		_, _ = bw.WriteString(sep)
+	}
+	_, _ = bw.WriteString("]\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to dump map %s: %w", m.Name(), err)
+	}
+
+	return nil
+}
+
+func (m *BPFMap) keyLayout() *valueLayout {
+	return m.btfLayout(m.BTFKeyTypeID(), m.KeySize())
+}
+
+func (m *BPFMap) valueLayout() *valueLayout {
+	return m.btfLayout(m.BTFValueTypeID(), m.ValueSize())
+}
+
+// btfLayout returns the layout of the BTF type, or opaque bytes of the size
+// without BTF.
+func (m *BPFMap) btfLayout(typeID uint32, size int) *valueLayout {
+	btf := C.bpf_object__btf(m.module.obj)
+	if btf == nil || typeID == 0 {
+		return &valueLayout{kind: valueBytes, size: size}
+	}
+
+	return btfValueLayout(btf, C.__u32(typeID), 0)
+}
+
+// btfValueLayout returns the layout of the BTF type. Unions, and the types
+// it can not decode, are opaque bytes.
+func btfValueLayout(btf *C.struct_btf, id C.__u32, depth int) *valueLayout {
+	size := int(C.btf__resolve_size(btf, id))
+	if size < 0 || depth >= btfTypeNameMaxDepth {
+		return &valueLayout{kind: valueBytes, size: max(size, 0)}
+	}
+
+	// Follow typedefs and modifiers, down to the actual type
+	for ref := C.cgo_btf_type_ref(btf, id); ref != 0; ref = C.cgo_btf_type_ref(btf, id) {
+		id = ref
+	}
+	l := &valueLayout{kind: valueBytes, size: size}
+
+	switch C.cgo_btf_type_kind(btf, id) {
+	case C.BTF_KIND_INT:
+		encoding := C.cgo_btf_int_encoding(btf, id)
+		l.kind = valueInt
+		l.signed = encoding&C.BTF_INT_SIGNED != 0
+		if encoding&C.BTF_INT_BOOL != 0 {
+			l.kind = valueBool
+		}
+	case C.BTF_KIND_ENUM, C.BTF_KIND_ENUM64:
+		l.kind = valueInt
+	case C.BTF_KIND_FLOAT:
+		l.kind = valueFloat
+	case C.BTF_KIND_PTR:
+		l.kind = valueInt
+		if isKptr(btf, C.cgo_btf_ptr_type(btf, id)) {
+			l.kind = valueMasked
+			l.name = "kptr"
+		}
+	case C.BTF_KIND_ARRAY:
+		l.count = int(C.cgo_btf_array_nelems(btf, id))
+		l.elem = btfValueLayout(btf, C.cgo_btf_array_type(btf, id), depth+1)
+		l.kind = valueArray
+		if isCharType(btf, C.cgo_btf_array_type(btf, id)) {
+			l.kind = valueString
+		}
+		if l.elem.size*l.count != size {
+			l.kind = valueBytes
+		}
+	case C.BTF_KIND_STRUCT:
+		if name := C.GoString(C.cgo_btf_type_name(btf, id)); slices.Contains(specialFieldTypes, name) {
+			l.kind = valueMasked
+			l.name = name
+			break
+		}
+		l.kind = valueStruct
+		vlen := C.cgo_btf_type_vlen(btf, id)
+		for i := C.__u16(0); i < vlen; i++ {
+			l.fields = append(l.fields, valueField{
+				name:      C.GoString(C.cgo_btf_member_name(btf, id, i)),
+				bitOffset: int(C.cgo_btf_member_bit_offset(btf, id, i)),
+				bitSize:   int(C.cgo_btf_member_bitfield_size(btf, id, i)),
+				layout:    btfValueLayout(btf, C.cgo_btf_member_type(btf, id, i), depth+1),
+			})
+		}
+	}
+
+	return l
+}
+
+// isKptr reports whether the pointed type is tagged as a kernel object
+// (__kptr, __percpu_kptr, ...).
+func isKptr(btf *C.struct_btf, id C.__u32) bool {
+	for ; id != 0; id = C.cgo_btf_type_ref(btf, id) {
+		if C.cgo_btf_type_kind(btf, id) != C.BTF_KIND_TYPE_TAG {
+			continue
+		}
+		if slices.Contains(kptrTypeTags, C.GoString(C.cgo_btf_type_name(btf, id))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCharType reports whether the type is a char, through typedefs and
+// modifiers. Compilers do not all set BTF_INT_CHAR, the int is also named
+// "char".
+func isCharType(btf *C.struct_btf, id C.__u32) bool {
+	for ref := C.cgo_btf_type_ref(btf, id); ref != 0; ref = C.cgo_btf_type_ref(btf, id) {
+		id = ref
+	}
+	if C.cgo_btf_type_kind(btf, id) != C.BTF_KIND_INT {
+		return false
+	}
+
+	return C.cgo_btf_int_encoding(btf, id)&C.BTF_INT_CHAR != 0 ||
+		C.GoString(C.cgo_btf_type_name(btf, id)) == "char"
+}
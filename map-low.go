@@ -64,7 +64,7 @@ func CreateMap(mapType MapType, mapName string, keySize, valueSize, maxEntries i
 
 	fdC := C.bpf_map_create(uint32(mapType), mapNameC, C.uint(keySize), C.uint(valueSize), C.uint(maxEntries), optsC)
 	if fdC < 0 {
-		return nil, fmt.Errorf("could not create map %s: %w", mapName, syscall.Errno(-fdC))
+		return nil, fmt.Errorf("could not create map %s: %w", mapName, classifyError(opMapCreate, syscall.Errno(-fdC), ""))
 	}
 
 	info, errInfo := GetMapInfoByFD(int(fdC))
@@ -100,6 +100,21 @@ func GetMapByID(id uint32) (*BPFMapLow, error) {
 	}, nil
 }
 
+// GetMapByFD returns a BPFMapLow instance for the map with the given file
+// descriptor, e.g. one received with ReceiveFDs(). The BPFMapLow takes
+// ownership of the file descriptor.
+func GetMapByFD(fd int) (*BPFMapLow, error) {
+	info, err := GetMapInfoByFD(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BPFMapLow{
+		fd:   fd,
+		info: info,
+	}, nil
+}
+
 // GetMapNextID retrieves the next available map ID after the given startID.
 // It returns the next map ID and an error if one occurs during the operation.
 func GetMapNextID(startId uint32) (uint32, error) {
@@ -266,6 +281,29 @@ func (m *BPFMapLow) GetValueFlags(key unsafe.Pointer, flags MapFlag) ([]byte, er
 	}
 
 	value := make([]byte, valueSize)
+	if err := m.GetValueIntoFlags(key, value, flags); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// GetValueInto retrieves the value associated with the key into value, like
+// BPFMap.GetValueInto().
+func (m *BPFMapLow) GetValueInto(key unsafe.Pointer, value []byte) error {
+	return m.GetValueIntoFlags(key, value, MapFlagUpdateAny)
+}
+
+// GetValueIntoFlags is GetValueInto() with lookup flags (MapFlagFLock).
+func (m *BPFMapLow) GetValueIntoFlags(key unsafe.Pointer, value []byte, flags MapFlag) error {
+	valueSize, err := CalcMapValueSize(m.ValueSize(), m.Type())
+	if err != nil {
+		return fmt.Errorf("map %s %w", m.Name(), err)
+	}
+	if len(value) < valueSize {
+		return fmt.Errorf("failed to lookup value %v in map %s: buffer of %d bytes, value of %d: %w", key, m.Name(), len(value), valueSize, syscall.EINVAL)
+	}
+
 	retC := C.bpf_map_lookup_elem_flags(
 		C.int(m.FileDescriptor()),
 		key,
@@ -273,10 +311,10 @@ func (m *BPFMapLow) GetValueFlags(key unsafe.Pointer, flags MapFlag) ([]byte, er
 		C.ulonglong(flags),
 	)
 	if retC < 0 {
-		return nil, fmt.Errorf("failed to lookup value %v in map %s: %w", key, m.Name(), syscall.Errno(-retC))
+		return fmt.Errorf("failed to lookup value %v in map %s: %w", key, m.Name(), syscall.Errno(-retC))
 	}
 
-	return value, nil
+	return nil
 }
 
 func (m *BPFMapLow) LookupAndDeleteElem(
@@ -362,7 +400,7 @@ func (m *BPFMapLow) UpdateValueFlags(key, value unsafe.Pointer, flags MapFlag) e
 		C.ulonglong(flags),
 	)
 	if retC < 0 {
-		return fmt.Errorf("failed to update map %s: %w", m.Name(), syscall.Errno(-retC))
+		return fmt.Errorf("failed to update map %s: %w", m.Name(), classifyError(opMapUpdate, syscall.Errno(-retC), ""))
 	}
 
 	return nil
@@ -377,6 +415,8 @@ func (m *BPFMapLow) DeleteKey(key unsafe.Pointer) error {
 	return nil
 }
 
+// GetNextKey fetches the key following key, or the first key if key is nil,
+// like BPFMap.GetNextKey().
 func (m *BPFMapLow) GetNextKey(key unsafe.Pointer, nextKey unsafe.Pointer) error {
 	retC := C.bpf_map_get_next_key(
 		C.int(m.FileDescriptor()),
@@ -384,12 +424,51 @@ func (m *BPFMapLow) GetNextKey(key unsafe.Pointer, nextKey unsafe.Pointer) error
 		nextKey,
 	)
 	if retC < 0 {
-		return fmt.Errorf("failed to get next key in map %s: %w", m.Name(), syscall.Errno(-retC))
+		errno := syscall.Errno(-retC)
+		return nextKeyError(fmt.Errorf("failed to get next key in map %s: %w", m.Name(), errno), errno)
 	}
 
 	return nil
 }
 
+// DeleteAll deletes all the keys of the map, like BPFMap.DeleteAll().
+func (m *BPFMapLow) DeleteAll() (int, error) {
+	n, err := deleteAllKeys(m.KeySize(), m.GetNextKey, m.DeleteKey)
+	if err != nil {
+		return n, fmt.Errorf("failed to delete all keys in map %s: %w", m.Name(), err)
+	}
+
+	return n, nil
+}
+
+// ApproxEntryCount returns the number of entries of the map, like
+// BPFMap.ApproxEntryCount().
+func (m *BPFMapLow) ApproxEntryCount() (uint32, error) {
+	n, err := countEntries(m.Type(), m.KeySize(), m.MaxEntries(), m.lookupBatchKeys, m.GetNextKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count entries in map %s: %w", m.Name(), err)
+	}
+
+	return n, nil
+}
+
+// Utilization returns the ratio of the entries of the map to its capacity,
+// like BPFMap.Utilization().
+func (m *BPFMapLow) Utilization() (float64, error) {
+	return utilization(m.ApproxEntryCount, m.MaxEntries())
+}
+
+// walkKeys calls visit with each key of the map, see walkKeys().
+func (m *BPFMapLow) walkKeys(visit func(key []byte)) (uint32, error) {
+	return walkKeys(m.KeySize(), m.MaxEntries(), m.lookupBatchKeys, m.GetNextKey, visit)
+}
+
+// lookupBatchKeys is GetValueBatch() for callers only interested in the keys.
+func (m *BPFMapLow) lookupBatchKeys(keys, startKey, nextKey unsafe.Pointer, count uint32) (uint32, error) {
+	_, n, err := m.GetValueBatch(keys, startKey, nextKey, count)
+	return n, err
+}
+
 //
 // BPFMapLow Batch Operations
 //
@@ -433,7 +512,7 @@ func (m *BPFMapLow) GetValueBatch(keys, startKey, nextKey unsafe.Pointer, count
 	errno = syscall.Errno(-retC)
 	// retC < 0 && errno == syscall.ENOENT indicates a partial read.
 	if retC < 0 && (errno != syscall.ENOENT || countC == 0) {
-		return nil, 0, fmt.Errorf("failed to batch get value %v in map %s: %w", keys, m.Name(), errno)
+		return nil, 0, fmt.Errorf("failed to batch get value %v in map %s: %w", keys, m.Name(), classifyError(opMapBatch, errno, ""))
 	}
 
 	// Either some or all elements were read.
@@ -473,7 +552,7 @@ func (m *BPFMapLow) GetValueAndDeleteBatch(keys, startKey, nextKey unsafe.Pointe
 	errno = syscall.Errno(-retC)
 	// retC < 0 && errno == syscall.ENOENT indicates a partial read and delete.
 	if retC < 0 && (errno != syscall.ENOENT || countC == 0) {
-		return nil, 0, fmt.Errorf("failed to batch lookup and delete values %v in map %s: %w", keys, m.Name(), errno)
+		return nil, 0, fmt.Errorf("failed to batch lookup and delete values %v in map %s: %w", keys, m.Name(), classifyError(opMapBatch, errno, ""))
 	}
 
 	// Either some or all elements were read and deleted.
@@ -501,7 +580,7 @@ func (m *BPFMapLow) UpdateBatch(keys, values unsafe.Pointer, count uint32) (uint
 	errno = syscall.Errno(-retC)
 	// retC < 0 && errno == syscall.E2BIG indicates a partial update.
 	if retC < 0 && (errno != syscall.E2BIG || countC == 0) {
-		return 0, fmt.Errorf("failed to batch update values %v in map %s: %w", keys, m.Name(), errno)
+		return 0, fmt.Errorf("failed to batch update values %v in map %s: %w", keys, m.Name(), classifyError(opMapBatch, errno, ""))
 	}
 
 	// Either some or all elements were updated.
@@ -528,13 +607,113 @@ func (m *BPFMapLow) DeleteKeyBatch(keys unsafe.Pointer, count uint32) (uint32, e
 	errno = syscall.Errno(-retC)
 	// retC < 0 && errno == syscall.ENOENT indicates a partial deletion.
 	if retC < 0 && (errno != syscall.ENOENT || countC == 0) {
-		return 0, fmt.Errorf("failed to batch delete keys %v in map %s: %w", keys, m.Name(), errno)
+		return 0, fmt.Errorf("failed to batch delete keys %v in map %s: %w", keys, m.Name(), classifyError(opMapBatch, errno, ""))
 	}
 
 	// Either some or all elements were deleted.
 	return uint32(countC), nil
 }
 
+// GetValueBatchWithFlags is GetValueBatch reporting the progress of the
+// lookup instead of all-or-nothing errors. elemFlags are the flags of each
+// lookup (MapFlagFLock).
+//
+// It returns the values read, and their number in MapBatchResult.Count, even
+// on error. The error of the element the lookup stopped at is a
+// *MapBatchError. Once the map was fully read, MapBatchResult.Done is set;
+// otherwise nextKey is the token (out_batch) to pass as startKey to resume
+// the lookup. Hash maps fail with ENOSPC if count is smaller than a bucket.
+func (m *BPFMapLow) GetValueBatchWithFlags(keys, startKey, nextKey unsafe.Pointer, count uint32, elemFlags MapFlag) ([][]byte, MapBatchResult, error) {
+	return m.lookupBatchWithFlags(false, keys, startKey, nextKey, count, elemFlags)
+}
+
+// GetValueAndDeleteBatchWithFlags is GetValueAndDeleteBatch reporting the
+// progress of the lookup and deletion, as GetValueBatchWithFlags.
+func (m *BPFMapLow) GetValueAndDeleteBatchWithFlags(keys, startKey, nextKey unsafe.Pointer, count uint32, elemFlags MapFlag) ([][]byte, MapBatchResult, error) {
+	return m.lookupBatchWithFlags(true, keys, startKey, nextKey, count, elemFlags)
+}
+
+func (m *BPFMapLow) lookupBatchWithFlags(
+	andDelete bool,
+	keys, startKey, nextKey unsafe.Pointer,
+	count uint32,
+	elemFlags MapFlag,
+) ([][]byte, MapBatchResult, error) {
+	valueSize, err := CalcMapValueSize(m.ValueSize(), m.Type())
+	if err != nil {
+		return nil, MapBatchResult{}, fmt.Errorf("map %s %w", m.Name(), err)
+	}
+	if count == 0 {
+		return nil, MapBatchResult{}, fmt.Errorf("failed to batch lookup values in map %s: count 0: %w", m.Name(), syscall.EINVAL)
+	}
+
+	var (
+		values    = make([]byte, valueSize*int(count))
+		valuesPtr = unsafe.Pointer(&values[0])
+		countC    = C.uint(count)
+	)
+
+	optsC, errno := C.cgo_bpf_map_batch_opts_new(C.__u64(elemFlags), C.BPF_ANY)
+	if optsC == nil {
+		return nil, MapBatchResult{}, fmt.Errorf("failed to create bpf_map_batch_opts: %w", errno)
+	}
+	defer C.cgo_bpf_map_batch_opts_free(optsC)
+
+	// On failure, the kernel reports the elements processed before the
+	// failing one in countC
+	var retC C.int
+	if andDelete {
+		retC = C.bpf_map_lookup_and_delete_batch(C.int(m.FileDescriptor()), startKey, nextKey, keys, valuesPtr, &countC, optsC)
+	} else {
+		retC = C.bpf_map_lookup_batch(C.int(m.FileDescriptor()), startKey, nextKey, keys, valuesPtr, &countC, optsC)
+	}
+
+	result, err := batchOutcome(int(retC), uint32(countC), syscall.ENOENT)
+	read := collectBatchValues(values, result.Count, valueSize)
+	if err != nil {
+		op := "lookup"
+		if andDelete {
+			op = "lookup and delete"
+		}
+		return read, result, fmt.Errorf("failed to batch %s values in map %s: %w", op, m.Name(), err)
+	}
+
+	return read, result, nil
+}
+
+// UpdateBatchWithFlags is UpdateBatch reporting the progress of the update
+// instead of all-or-nothing errors. elemFlags are the flags of each update
+// (MapFlagUpdateAny, MapFlagUpdateNoExist, MapFlagUpdateExist).
+//
+// It returns the number of elements updated in MapBatchResult.Count, even on
+// error. The error of the element the update stopped at is a
+// *MapBatchError: E2BIG if the map is full, EEXIST or ENOENT as per
+// elemFlags. The update resumes with the keys and values following it.
+func (m *BPFMapLow) UpdateBatchWithFlags(keys, values unsafe.Pointer, count uint32, elemFlags MapFlag) (MapBatchResult, error) {
+	countC := C.uint(count)
+
+	optsC, errno := C.cgo_bpf_map_batch_opts_new(C.__u64(elemFlags), C.BPF_ANY)
+	if optsC == nil {
+		return MapBatchResult{}, fmt.Errorf("failed to create bpf_map_batch_opts: %w", errno)
+	}
+	defer C.cgo_bpf_map_batch_opts_free(optsC)
+
+	retC := C.bpf_map_update_batch(
+		C.int(m.FileDescriptor()),
+		keys,
+		values,
+		&countC,
+		optsC,
+	)
+
+	result, err := batchOutcome(int(retC), uint32(countC), 0)
+	if err != nil {
+		return result, fmt.Errorf("failed to batch update values in map %s: %w", m.Name(), err)
+	}
+
+	return result, nil
+}
+
 func collectBatchValues(values []byte, count uint32, valueSize int) [][]byte {
 	var value []byte
 	var collected [][]byte
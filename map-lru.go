@@ -0,0 +1,154 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//
+// LRUSampler
+//
+// Inserts into a full LRU hash map do not fail: the kernel silently evicts
+// the least recently used entries to make room. For conntrack-like maps,
+// evictions under load lose the state of live flows, and are only noticed
+// through their effects. An LRUSampler estimates the evictions by diffing the
+// keys of the map between samples, taken periodically:
+//
+//	sampler, err := NewLRUSampler(bpfMap)
+//	...
+//	for range time.Tick(10 * time.Second) {
+//	    s, err := sampler.Sample()
+//	    ...
+//	    removalRate.Set(s.RemovalRate())
+//	}
+//
+// The removed keys are the evicted ones plus the ones deleted by programs or
+// userspace, and miss the keys both added and removed between two samples:
+// the count is exact for maps only updated by inserts, and an estimate
+// otherwise. Each sample walks all the keys of the map, and the sampler keeps
+// them in memory until the next one.
+//
+
+// LRUSample is the state of an LRU map at a Sample(), compared to the
+// previous one.
+type LRUSample struct {
+	Time       time.Time
+	Interval   time.Duration // since the previous sample, 0 for the first one
+	Entries    uint32
+	MaxEntries uint32
+	Added      uint32 // keys not in the previous sample
+	Removed    uint32 // keys of the previous sample gone (evicted or deleted)
+}
+
+// Utilization returns the ratio of the entries of the map to its capacity.
+func (s LRUSample) Utilization() float64 {
+	if s.MaxEntries == 0 {
+		return 0
+	}
+
+	return float64(s.Entries) / float64(s.MaxEntries)
+}
+
+// RemovalRate returns the keys removed per second since the previous sample,
+// 0 for the first one.
+func (s LRUSample) RemovalRate() float64 {
+	if s.Interval <= 0 {
+		return 0
+	}
+
+	return float64(s.Removed) / s.Interval.Seconds()
+}
+
+// LRUSampler samples the keys of an LRU hash map to estimate its evictions.
+type LRUSampler struct {
+	bpfMap *BPFMap
+	keys   map[string]struct{} // keys at the last Sample(), nil before
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// NewLRUSampler creates an LRUSampler over the map, which must be an LRU hash
+// or LRU per-CPU hash map.
+func NewLRUSampler(bpfMap *BPFMap) (*LRUSampler, error) {
+	mapType := bpfMap.Type()
+	if mapType != MapTypeLRUHash && mapType != MapTypeLRUPerCPUHash {
+		return nil, fmt.Errorf("failed to create LRU sampler: map %s type is %s", bpfMap.Name(), mapType)
+	}
+
+	return &LRUSampler{bpfMap: bpfMap}, nil
+}
+
+// GetLRUSampler creates an LRUSampler over the map with the given name.
+func (m *Module) GetLRUSampler(mapName string) (*LRUSampler, error) {
+	bpfMap, err := m.GetMap(mapName)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLRUSampler(bpfMap)
+}
+
+// Sample reads the keys of the map and compares them to the previous sample.
+// The first sample is the baseline, with no keys added nor removed.
+func (s *LRUSampler) Sample() (LRUSample, error) {
+	sample, err := s.sample(s.bpfMap.bpfMapLow.walkKeys, s.bpfMap.MaxEntries())
+	if err != nil {
+		return LRUSample{}, fmt.Errorf("failed to sample LRU map %s: %w", s.bpfMap.Name(), err)
+	}
+
+	return sample, nil
+}
+
+// sample takes a sample of the keys visited by walk.
+func (s *LRUSampler) sample(walk func(visit func(key []byte)) (uint32, error), maxEntries uint32) (LRUSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make(map[string]struct{}, len(s.keys))
+	n, err := walk(func(key []byte) {
+		keys[string(key)] = struct{}{}
+	})
+	if err != nil {
+		return LRUSample{}, err
+	}
+
+	now := time.Now()
+	sample := LRUSample{
+		Time:       now,
+		Entries:    n,
+		MaxEntries: maxEntries,
+	}
+	if s.keys != nil {
+		sample.Interval = now.Sub(s.last)
+		sample.Added, sample.Removed = diffKeys(s.keys, keys)
+	}
+	s.keys, s.last = keys, now
+
+	return sample, nil
+}
+
+// Reset drops the previous sample, the next Sample() is a new baseline.
+func (s *LRUSampler) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = nil
+}
+
+// diffKeys returns the number of keys in cur and not in prev, and in prev and
+// not in cur.
+func diffKeys(prev, cur map[string]struct{}) (added, removed uint32) {
+	for k := range cur {
+		if _, ok := prev[k]; !ok {
+			added++
+		}
+	}
+	for k := range prev {
+		if _, ok := cur[k]; !ok {
+			removed++
+		}
+	}
+
+	return added, removed
+}
@@ -0,0 +1,88 @@
+package libbpfgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keySet(keys ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+
+	return set
+}
+
+func TestDiffKeys(t *testing.T) {
+	tt := []struct {
+		name    string
+		prev    map[string]struct{}
+		cur     map[string]struct{}
+		added   uint32
+		removed uint32
+	}{
+		{"empty", keySet(), keySet(), 0, 0},
+		{"unchanged", keySet("a", "b"), keySet("a", "b"), 0, 0},
+		{"inserts", keySet("a"), keySet("a", "b", "c"), 2, 0},
+		{"evictions", keySet("a", "b", "c"), keySet("c", "d", "e"), 2, 2},
+		{"drained", keySet("a", "b"), keySet(), 0, 2},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			added, removed := diffKeys(tc.prev, tc.cur)
+			assert.Equal(t, tc.added, added)
+			assert.Equal(t, tc.removed, removed)
+		})
+	}
+}
+
+func TestLRUSample(t *testing.T) {
+	s := LRUSample{
+		Interval:   2 * time.Second,
+		Entries:    750,
+		MaxEntries: 1000,
+		Removed:    100,
+	}
+	assert.InDelta(t, 0.75, s.Utilization(), 1e-9)
+	assert.InDelta(t, 50, s.RemovalRate(), 1e-9)
+
+	// First sample
+	assert.Zero(t, LRUSample{Removed: 100}.RemovalRate())
+	assert.Zero(t, LRUSample{}.Utilization())
+}
+
+func TestLRUSamplerShortBatches(t *testing.T) {
+	// The batches of hash maps are short whenever the next bucket does not
+	// fit: the keys after them are still walked, not reported removed
+	f := newFakeHashMap(600)
+	walk := func(visit func(key []byte)) (uint32, error) {
+		return walkKeys(4, 1024, f.lookupBatch, f.getNextKey, visit)
+	}
+
+	s := &LRUSampler{}
+	sample, err := s.sample(walk, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(600), sample.Entries)
+
+	sample, err = s.sample(walk, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(600), sample.Entries)
+	assert.Zero(t, sample.Added)
+	assert.Zero(t, sample.Removed)
+	assert.Zero(t, sample.RemovalRate())
+
+	// Evictions of the last batch
+	delete(f.keys, 5980)
+	delete(f.keys, 5990)
+	f.keys[6000] = true
+	sample, err = s.sample(walk, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(599), sample.Entries)
+	assert.Equal(t, uint32(1), sample.Added)
+	assert.Equal(t, uint32(2), sample.Removed)
+}
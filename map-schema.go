@@ -0,0 +1,142 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+//
+// Map schema checking
+//
+
+// BTFTypeNamer can be implemented by Go types mirroring BPF types to have
+// their BTF type name checked by BPFMap.CheckSchema(), since Go and C names
+// usually differ (e.g. EventT and struct event_t). Either the typedef or the
+// underlying type name may be returned.
+type BTFTypeNamer interface {
+	BTFTypeName() string
+}
+
+// CheckSchema validates that the Go key and value types match the map
+// definition, so a BPF object and the Go code using it drifting out of sync
+// fails fast instead of corrupting data. key and value are values (or
+// pointers to values) of the Go types; a nil one is not checked.
+//
+// The sizes are always checked. When the map has BTF, the names of types
+// implementing BTFTypeNamer are checked, and the fields of Go structs are
+// checked against the members of the BTF struct: their count, offsets and
+// sizes (names are not compared). Go blank fields (_) are ignored, so they
+// can be used as explicit padding.
+func (m *BPFMap) CheckSchema(key, value interface{}) error {
+	var errs []error
+
+	btf := C.bpf_object__btf(m.module.obj)
+
+	if key != nil {
+		if err := checkMapSchema(btf, "key", key, m.KeySize(), m.BTFKeyTypeID()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if value != nil {
+		if err := checkMapSchema(btf, "value", value, m.ValueSize(), m.BTFValueTypeID()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("map %s schema mismatch: %w", m.Name(), err)
+	}
+
+	return nil
+}
+
+func checkMapSchema(btf *C.struct_btf, what string, v interface{}, size int, typeID uint32) error {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if int(t.Size()) != size {
+		return fmt.Errorf("%s size is %d, Go type %s size is %d", what, size, t, t.Size())
+	}
+
+	if btf == nil || typeID == 0 {
+		return nil
+	}
+
+	// Collect the names along typedefs and modifiers, down to the actual type
+	var names []string
+	for id := C.__u32(typeID); id != 0; id = C.cgo_btf_type_ref(btf, id) {
+		if name := C.GoString(C.cgo_btf_type_name(btf, id)); name != "" {
+			names = append(names, name)
+		}
+		typeID = uint32(id)
+	}
+
+	if namer, ok := v.(BTFTypeNamer); ok {
+		expected := namer.BTFTypeName()
+		found := false
+		for _, name := range names {
+			if name == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s BTF type is %v, Go type %s expects %s", what, names, t, expected)
+		}
+	}
+
+	if t.Kind() != reflect.Struct || C.cgo_btf_type_kind(btf, C.__u32(typeID)) != C.BTF_KIND_STRUCT {
+		return nil
+	}
+
+	return checkStructSchema(btf, what, t, typeID)
+}
+
+func checkStructSchema(btf *C.struct_btf, what string, t reflect.Type, typeID uint32) error {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Name != "_" {
+			fields = append(fields, f)
+		}
+	}
+
+	typeIDC := C.__u32(typeID)
+	vlen := int(C.cgo_btf_type_vlen(btf, typeIDC))
+	if vlen != len(fields) {
+		return fmt.Errorf("%s BTF struct has %d members, Go type %s has %d fields", what, vlen, t, len(fields))
+	}
+
+	for i, f := range fields {
+		idx := C.__u16(i)
+		member := C.GoString(C.cgo_btf_member_name(btf, typeIDC, idx))
+
+		// Bitfields have no Go counterpart to compare with
+		if C.cgo_btf_member_bitfield_size(btf, typeIDC, idx) != 0 {
+			continue
+		}
+
+		offset := uintptr(C.cgo_btf_member_bit_offset(btf, typeIDC, idx)) / 8
+		if offset != f.Offset {
+			return fmt.Errorf("%s BTF member %s offset is %d, Go field %s.%s offset is %d", what, member, offset, t, f.Name, f.Offset)
+		}
+
+		sizeC := C.btf__resolve_size(btf, C.cgo_btf_member_type(btf, typeIDC, idx))
+		if sizeC < 0 {
+			continue
+		}
+		if uintptr(sizeC) != f.Type.Size() {
+			return fmt.Errorf("%s BTF member %s size is %d, Go field %s.%s size is %d", what, member, sizeC, t, f.Name, f.Type.Size())
+		}
+	}
+
+	return nil
+}
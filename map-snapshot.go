@@ -0,0 +1,352 @@
+package libbpfgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Map snapshots
+//
+// Agents that can not hand their maps over by file descriptor (a new version
+// running in another process, on another boot, or declaring its maps with a
+// new layout) migrate their state by value: Snapshot() writes all the entries
+// of a map to an io.Writer, and Restore() updates a map with them.
+//
+//	f, _ := os.Create("/var/lib/agent/flows.snap")
+//	flows.Snapshot(f)
+//	...
+//	f, _ := os.Open("/var/lib/agent/flows.snap")
+//	flows.Restore(f)
+//
+// A snapshot starts with the metadata of the map (MapSnapshotHeader), which
+// Restore() checks against the target map, followed by the raw keys and
+// values, in host byte order. Several snapshots can be written one after the
+// other to the same stream, and restored in the same order.
+//
+// The map is read entry by entry: a snapshot of a map updated meanwhile is not
+// consistent.
+//
+
+// mapSnapshotMagic starts each snapshot.
+var mapSnapshotMagic = [8]byte{'B', 'P', 'F', 'M', 'S', 'N', 'A', 'P'}
+
+const mapSnapshotVersion = 1
+
+// MapSnapshotHeader is the metadata of the map of a snapshot.
+type MapSnapshotHeader struct {
+	Name      string
+	Type      MapType
+	Flags     MapFlag
+	KeySize   uint32
+	ValueSize uint32 // of the value of a CPU for per-CPU maps
+	CPUs      uint32 // possible CPUs, for per-CPU maps
+	// KeyTypeName and ValueTypeName are the BTF type names of the key and
+	// value, if the map has BTF.
+	KeyTypeName   string
+	ValueTypeName string
+}
+
+// snapshotMapTypes are the map types whose entries are plain data, that a
+// snapshot can hold. The values of the other types are file descriptors,
+// objects of the kernel, or can not be read back.
+var snapshotMapTypes = []MapType{
+	MapTypeHash,
+	MapTypeArray,
+	MapTypePerCPUHash,
+	MapTypePerCPUArray,
+	MapTypeLRUHash,
+	MapTypeLRUPerCPUHash,
+	MapTypeLPMTrie,
+}
+
+// valueLen returns the size of the values of the snapshot, of all the CPUs
+// for per-CPU maps.
+func (h *MapSnapshotHeader) valueLen() int {
+	if !isPerCPUMapType(h.Type) {
+		return int(h.ValueSize)
+	}
+
+	return int(roundUp(uint64(h.ValueSize), 8)) * int(h.CPUs)
+}
+
+// compatible checks that the entries of the snapshot can be restored to a map
+// of the given metadata.
+func (h *MapSnapshotHeader) compatible(m *MapSnapshotHeader) error {
+	switch {
+	case h.Type != m.Type:
+		return fmt.Errorf("snapshot of a %s map", h.Type)
+	case h.KeySize != m.KeySize:
+		return fmt.Errorf("snapshot key size %d, map key size %d", h.KeySize, m.KeySize)
+	case h.ValueSize != m.ValueSize:
+		return fmt.Errorf("snapshot value size %d, map value size %d", h.ValueSize, m.ValueSize)
+	case h.CPUs != m.CPUs:
+		return fmt.Errorf("snapshot of %d possible CPUs, %d now", h.CPUs, m.CPUs)
+	case h.KeyTypeName != "" && m.KeyTypeName != "" && h.KeyTypeName != m.KeyTypeName:
+		return fmt.Errorf("snapshot key type %s, map key type %s", h.KeyTypeName, m.KeyTypeName)
+	case h.ValueTypeName != "" && m.ValueTypeName != "" && h.ValueTypeName != m.ValueTypeName:
+		return fmt.Errorf("snapshot value type %s, map value type %s", h.ValueTypeName, m.ValueTypeName)
+	}
+
+	return nil
+}
+
+// snapshotHeader returns the snapshot metadata of the map.
+func (m *BPFMap) snapshotHeader() (*MapSnapshotHeader, error) {
+	if !slices.Contains(snapshotMapTypes, m.Type()) {
+		return nil, fmt.Errorf("%s maps can not be snapshotted: %w", m.Type(), syscall.EOPNOTSUPP)
+	}
+
+	hdr := &MapSnapshotHeader{
+		Name:      m.Name(),
+		Type:      m.Type(),
+		Flags:     m.MapFlags(),
+		KeySize:   uint32(m.KeySize()),
+		ValueSize: uint32(m.ValueSize()),
+	}
+	if isPerCPUMapType(hdr.Type) {
+		cpus, err := NumPossibleCPUs()
+		if err != nil {
+			return nil, err
+		}
+		hdr.CPUs = uint32(cpus)
+	}
+	hdr.KeyTypeName, _ = m.BTFKeyTypeName()
+	hdr.ValueTypeName, _ = m.BTFValueTypeName()
+
+	return hdr, nil
+}
+
+// Snapshot writes the metadata and all the entries of the map to w, and
+// returns the number of entries written.
+func (m *BPFMap) Snapshot(w io.Writer) (int, error) {
+	hdr, err := m.snapshotHeader()
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot map %s: %w", m.Name(), err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeSnapshotHeader(bw, hdr); err != nil {
+		return 0, fmt.Errorf("failed to snapshot map %s: %w", m.Name(), err)
+	}
+
+	count := 0
+	it := m.Iterator()
+	for it.Next() {
+		key := it.Key()
+		value, err := m.GetValue(unsafe.Pointer(&key[0]))
+		if errors.Is(err, syscall.ENOENT) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to snapshot map %s: %w", m.Name(), err)
+		}
+		if err := writeSnapshotEntry(bw, key, value); err != nil {
+			return count, fmt.Errorf("failed to snapshot map %s: %w", m.Name(), err)
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		return count, fmt.Errorf("failed to snapshot map %s: %w", m.Name(), err)
+	}
+
+	if err := writeSnapshotEnd(bw); err != nil {
+		return count, fmt.Errorf("failed to snapshot map %s: %w", m.Name(), err)
+	}
+	if err := bw.Flush(); err != nil {
+		return count, fmt.Errorf("failed to snapshot map %s: %w", m.Name(), err)
+	}
+
+	return count, nil
+}
+
+// Restore reads a snapshot from r, written by Snapshot(), and updates the map
+// with its entries. It returns the number of entries restored. The snapshot
+// must be of a map of the same type, key and value sizes and, if both maps
+// have BTF, key and value type names. Entries of the map missing in the
+// snapshot are left alone.
+func (m *BPFMap) Restore(r io.Reader) (int, error) {
+	mapHdr, err := m.snapshotHeader()
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore map %s: %w", m.Name(), err)
+	}
+
+	hdr, err := ReadMapSnapshotHeader(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore map %s: %w", m.Name(), err)
+	}
+	if err := hdr.compatible(mapHdr); err != nil {
+		return 0, fmt.Errorf("failed to restore map %s from snapshot of map %s: %w", m.Name(), hdr.Name, err)
+	}
+
+	count := 0
+	key := make([]byte, hdr.KeySize)
+	value := make([]byte, hdr.valueLen())
+	for {
+		more, err := readSnapshotEntry(r, key, value)
+		if err != nil {
+			return count, fmt.Errorf("failed to restore map %s: %w", m.Name(), err)
+		}
+		if !more {
+			return count, nil
+		}
+		if err := m.Update(unsafe.Pointer(&key[0]), unsafe.Pointer(&value[0])); err != nil {
+			return count, fmt.Errorf("failed to restore map %s: %w", m.Name(), err)
+		}
+		count++
+	}
+}
+
+// ReadMapSnapshotHeader reads the metadata of a snapshot from r, leaving r at
+// its first entry.
+func ReadMapSnapshotHeader(r io.Reader) (*MapSnapshotHeader, error) {
+	var fixed struct {
+		Magic     [8]byte
+		Version   uint32
+		Type      uint32
+		Flags     uint32
+		KeySize   uint32
+		ValueSize uint32
+		CPUs      uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &fixed); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if fixed.Magic != mapSnapshotMagic {
+		return nil, fmt.Errorf("failed to read snapshot header: not a map snapshot")
+	}
+	if fixed.Version != mapSnapshotVersion {
+		return nil, fmt.Errorf("failed to read snapshot header: unsupported version %d", fixed.Version)
+	}
+	if fixed.KeySize == 0 || fixed.ValueSize == 0 {
+		return nil, fmt.Errorf("failed to read snapshot header: invalid key or value size")
+	}
+
+	hdr := &MapSnapshotHeader{
+		Type:      MapType(fixed.Type),
+		Flags:     MapFlag(fixed.Flags),
+		KeySize:   fixed.KeySize,
+		ValueSize: fixed.ValueSize,
+		CPUs:      fixed.CPUs,
+	}
+	for _, str := range []*string{&hdr.Name, &hdr.KeyTypeName, &hdr.ValueTypeName} {
+		var err error
+		if *str, err = readSnapshotString(r); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+		}
+	}
+
+	return hdr, nil
+}
+
+// mapSnapshotMaxString bounds the strings of a snapshot header.
+const mapSnapshotMaxString = 4096
+
+func writeSnapshotHeader(w io.Writer, hdr *MapSnapshotHeader) error {
+	fixed := []any{
+		mapSnapshotMagic,
+		uint32(mapSnapshotVersion),
+		uint32(hdr.Type),
+		uint32(hdr.Flags),
+		hdr.KeySize,
+		hdr.ValueSize,
+		hdr.CPUs,
+	}
+	for _, v := range fixed {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	for _, str := range []string{hdr.Name, hdr.KeyTypeName, hdr.ValueTypeName} {
+		if err := writeSnapshotString(w, str); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeSnapshotString(w io.Writer, str string) error {
+	if len(str) > mapSnapshotMaxString {
+		return fmt.Errorf("string of %d bytes too long", len(str))
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(str))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, str)
+
+	return err
+}
+
+func readSnapshotString(r io.Reader) (string, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return "", err
+	}
+	if size > mapSnapshotMaxString {
+		return "", fmt.Errorf("string of %d bytes too long", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// Each entry is preceded by a byte set to 1, and the entries are followed by
+// a byte set to 0.
+const (
+	snapshotEnd   = 0
+	snapshotEntry = 1
+)
+
+func writeSnapshotEntry(w io.Writer, key, value []byte) error {
+	if _, err := w.Write([]byte{snapshotEntry}); err != nil {
+		return err
+	}
+	if _, err := w.Write(key); err != nil {
+		return err
+	}
+	_, err := w.Write(value)
+
+	return err
+}
+
+func writeSnapshotEnd(w io.Writer) error {
+	_, err := w.Write([]byte{snapshotEnd})
+
+	return err
+}
+
+// readSnapshotEntry reads the next entry of a snapshot into key and value. It
+// returns false at the end of the snapshot.
+func readSnapshotEntry(r io.Reader, key, value []byte) (bool, error) {
+	var marker [1]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil {
+		return false, fmt.Errorf("truncated snapshot: %w", err)
+	}
+
+	switch marker[0] {
+	case snapshotEnd:
+		return false, nil
+	case snapshotEntry:
+	default:
+		return false, fmt.Errorf("corrupted snapshot: invalid entry marker %#x", marker[0])
+	}
+
+	if _, err := io.ReadFull(r, key); err != nil {
+		return false, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	if _, err := io.ReadFull(r, value); err != nil {
+		return false, fmt.Errorf("truncated snapshot: %w", err)
+	}
+
+	return true, nil
+}
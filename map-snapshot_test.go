@@ -0,0 +1,101 @@
+package libbpfgo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapSnapshotRoundTrip(t *testing.T) {
+	hdr := &MapSnapshotHeader{
+		Name:          "flows",
+		Type:          MapTypeHash,
+		Flags:         MapFlag(1),
+		KeySize:       4,
+		ValueSize:     8,
+		KeyTypeName:   "u32",
+		ValueTypeName: "struct flow",
+	}
+	perCPU := &MapSnapshotHeader{
+		Name:      "counters",
+		Type:      MapTypePerCPUArray,
+		KeySize:   4,
+		ValueSize: 4,
+		CPUs:      2,
+	}
+	assert.Equal(t, 8, hdr.valueLen())
+	assert.Equal(t, 16, perCPU.valueLen(), "values rounded up to 8 bytes per CPU")
+
+	// Two snapshots in the same stream
+	var buf bytes.Buffer
+	require.NoError(t, writeSnapshotHeader(&buf, hdr))
+	require.NoError(t, writeSnapshotEntry(&buf, []byte{1, 0, 0, 0}, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	require.NoError(t, writeSnapshotEntry(&buf, []byte{2, 0, 0, 0}, []byte{8, 7, 6, 5, 4, 3, 2, 1}))
+	require.NoError(t, writeSnapshotEnd(&buf))
+	require.NoError(t, writeSnapshotHeader(&buf, perCPU))
+	require.NoError(t, writeSnapshotEnd(&buf))
+
+	got, err := ReadMapSnapshotHeader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, hdr, got)
+
+	key, value := make([]byte, got.KeySize), make([]byte, got.valueLen())
+	more, err := readSnapshotEntry(&buf, key, value)
+	require.NoError(t, err)
+	assert.True(t, more)
+	assert.Equal(t, []byte{1, 0, 0, 0}, key)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, value)
+	more, err = readSnapshotEntry(&buf, key, value)
+	require.NoError(t, err)
+	assert.True(t, more)
+	assert.Equal(t, []byte{2, 0, 0, 0}, key)
+	more, err = readSnapshotEntry(&buf, key, value)
+	require.NoError(t, err)
+	assert.False(t, more)
+
+	got, err = ReadMapSnapshotHeader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, perCPU, got)
+	more, err = readSnapshotEntry(&buf, make([]byte, 4), make([]byte, 16))
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Zero(t, buf.Len())
+}
+
+func TestMapSnapshotInvalid(t *testing.T) {
+	_, err := ReadMapSnapshotHeader(bytes.NewReader([]byte("not a snapshot, but a longer text than a header")))
+	assert.ErrorContains(t, err, "not a map snapshot")
+
+	var buf bytes.Buffer
+	require.NoError(t, writeSnapshotHeader(&buf, &MapSnapshotHeader{Type: MapTypeHash, KeySize: 4, ValueSize: 4}))
+	require.NoError(t, writeSnapshotEntry(&buf, []byte{1, 0, 0, 0}, []byte{1, 0}))
+	_, err = ReadMapSnapshotHeader(&buf)
+	require.NoError(t, err)
+	_, err = readSnapshotEntry(&buf, make([]byte, 4), make([]byte, 4))
+	assert.ErrorContains(t, err, "truncated snapshot")
+
+	_, err = readSnapshotEntry(bytes.NewReader([]byte{7}), make([]byte, 4), make([]byte, 4))
+	assert.ErrorContains(t, err, "corrupted snapshot")
+}
+
+func TestMapSnapshotCompatible(t *testing.T) {
+	hdr := &MapSnapshotHeader{Type: MapTypeHash, KeySize: 4, ValueSize: 8, KeyTypeName: "u32", ValueTypeName: "struct flow"}
+
+	assert.NoError(t, hdr.compatible(&MapSnapshotHeader{Type: MapTypeHash, KeySize: 4, ValueSize: 8}), "map without BTF")
+	assert.NoError(t, hdr.compatible(&MapSnapshotHeader{Type: MapTypeHash, KeySize: 4, ValueSize: 8, Flags: MapFlag(1), KeyTypeName: "u32"}))
+
+	for _, m := range []*MapSnapshotHeader{
+		{Type: MapTypeLRUHash, KeySize: 4, ValueSize: 8},
+		{Type: MapTypeHash, KeySize: 8, ValueSize: 8},
+		{Type: MapTypeHash, KeySize: 4, ValueSize: 4},
+		{Type: MapTypeHash, KeySize: 4, ValueSize: 8, KeyTypeName: "pid_t"},
+		{Type: MapTypeHash, KeySize: 4, ValueSize: 8, ValueTypeName: "struct flow_v2"},
+	} {
+		assert.Error(t, hdr.compatible(m), m)
+	}
+
+	perCPU := &MapSnapshotHeader{Type: MapTypePerCPUHash, KeySize: 4, ValueSize: 8, CPUs: 4}
+	assert.ErrorContains(t, perCPU.compatible(&MapSnapshotHeader{Type: MapTypePerCPUHash, KeySize: 4, ValueSize: 8, CPUs: 8}), "possible CPUs")
+}
@@ -0,0 +1,184 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"unsafe"
+)
+
+//
+// 5-tuple map keys
+//
+// Sockhash and conntrack-style maps are commonly keyed by a connection
+// 5-tuple. Addresses are kept in network byte order, as in packets and
+// sockets, while ports are either kept in network byte order (__be16, as in
+// struct bpf_sock_ops remote_port or packet headers) or converted to host
+// byte order by the program (bpf_ntohs()). Getting any of these wrong is a
+// silent mismatch, so FiveTupleLayout encodes the keys of the following C
+// structures:
+//
+//	struct tuple_key {          // FiveTupleLayout{}
+//	    __be32 saddr;
+//	    __be32 daddr;
+//	    __be16 sport;
+//	    __be16 dport;
+//	    __u8 proto;
+//	    __u8 pad[3];
+//	};
+//
+//	struct tuple6_key {         // FiveTupleLayout{IPv6: true}
+//	    __u8 saddr[16];         // IPv4 addresses are IPv4-mapped
+//	    __u8 daddr[16];
+//	    __be16 sport;
+//	    __be16 dport;
+//	    __u8 proto;
+//	    __u8 pad[3];
+//	};
+//
+// With HostOrderPorts the ports are __u16 in host byte order.
+//
+
+// FiveTuple identifies a connection.
+type FiveTuple struct {
+	Src   netip.AddrPort
+	Dst   netip.AddrPort
+	Proto uint8 // IPPROTO_*
+}
+
+// FiveTupleLayout describes how a FiveTuple is encoded as a map key.
+type FiveTupleLayout struct {
+	// IPv6 selects 16 bytes addresses, 4 bytes otherwise.
+	IPv6 bool
+	// HostOrderPorts stores the ports in host byte order.
+	HostOrderPorts bool
+	// Size is the key size, including trailing padding. Defaults to the
+	// size of the C structures above.
+	Size int
+}
+
+func (l FiveTupleLayout) addrSize() int {
+	if l.IPv6 {
+		return 16
+	}
+
+	return 4
+}
+
+func (l FiveTupleLayout) minSize() int {
+	return 2*l.addrSize() + 2 + 2 + 1
+}
+
+// KeySize returns the size of the encoded keys.
+func (l FiveTupleLayout) KeySize() int {
+	if l.Size > 0 {
+		return l.Size
+	}
+
+	// Aligned to the 4 bytes of the address members
+	return (l.minSize() + 3) &^ 3
+}
+
+func (l FiveTupleLayout) portOrder() binary.ByteOrder {
+	if l.HostOrderPorts {
+		return binary.NativeEndian
+	}
+
+	return binary.BigEndian
+}
+
+// Marshal encodes the tuple as a map key.
+func (l FiveTupleLayout) Marshal(t FiveTuple) ([]byte, error) {
+	if l.KeySize() < l.minSize() {
+		return nil, fmt.Errorf("invalid 5-tuple key size %d, minimum is %d", l.KeySize(), l.minSize())
+	}
+
+	b := make([]byte, l.KeySize())
+	addrSize := l.addrSize()
+
+	for i, addr := range []netip.Addr{t.Src.Addr(), t.Dst.Addr()} {
+		dst := b[i*addrSize : (i+1)*addrSize]
+
+		switch {
+		case !addr.IsValid():
+			return nil, fmt.Errorf("invalid 5-tuple address %v", addr)
+		case l.IPv6:
+			a := addr.As16()
+			copy(dst, a[:])
+		case addr.Unmap().Is4():
+			a := addr.Unmap().As4()
+			copy(dst, a[:])
+		default:
+			return nil, fmt.Errorf("IPv6 address %v in IPv4 5-tuple key", addr)
+		}
+	}
+
+	ports := b[2*addrSize:]
+	l.portOrder().PutUint16(ports[0:], t.Src.Port())
+	l.portOrder().PutUint16(ports[2:], t.Dst.Port())
+	ports[4] = t.Proto
+
+	return b, nil
+}
+
+// Unmarshal decodes a map key. IPv4-mapped addresses of IPv6 keys are
+// returned as IPv4 addresses.
+func (l FiveTupleLayout) Unmarshal(b []byte) (FiveTuple, error) {
+	if len(b) < l.minSize() {
+		return FiveTuple{}, fmt.Errorf("invalid 5-tuple key size %d, minimum is %d", len(b), l.minSize())
+	}
+
+	addrSize := l.addrSize()
+	var addrs [2]netip.Addr
+	for i := range addrs {
+		addr, _ := netip.AddrFromSlice(b[i*addrSize : (i+1)*addrSize])
+		addrs[i] = addr.Unmap()
+	}
+
+	ports := b[2*addrSize:]
+
+	return FiveTuple{
+		Src:   netip.AddrPortFrom(addrs[0], l.portOrder().Uint16(ports[0:])),
+		Dst:   netip.AddrPortFrom(addrs[1], l.portOrder().Uint16(ports[2:])),
+		Proto: ports[4],
+	}, nil
+}
+
+func (m *BPFMap) marshalTuple(layout FiveTupleLayout, t FiveTuple) ([]byte, error) {
+	if layout.KeySize() != m.KeySize() {
+		return nil, fmt.Errorf("5-tuple key size %d does not match map %s key size %d", layout.KeySize(), m.Name(), m.KeySize())
+	}
+
+	return layout.Marshal(t)
+}
+
+// GetValueByTuple returns the value of the 5-tuple key.
+func (m *BPFMap) GetValueByTuple(layout FiveTupleLayout, t FiveTuple) ([]byte, error) {
+	key, err := m.marshalTuple(layout, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.GetValue(unsafe.Pointer(&key[0]))
+}
+
+// UpdateByTuple sets the value of the 5-tuple key. For sockhash maps, value
+// points to the socket file descriptor.
+func (m *BPFMap) UpdateByTuple(layout FiveTupleLayout, t FiveTuple, value unsafe.Pointer) error {
+	key, err := m.marshalTuple(layout, t)
+	if err != nil {
+		return err
+	}
+
+	return m.Update(unsafe.Pointer(&key[0]), value)
+}
+
+// DeleteKeyByTuple deletes the 5-tuple key.
+func (m *BPFMap) DeleteKeyByTuple(layout FiveTupleLayout, t FiveTuple) error {
+	key, err := m.marshalTuple(layout, t)
+	if err != nil {
+		return err
+	}
+
+	return m.DeleteKey(unsafe.Pointer(&key[0]))
+}
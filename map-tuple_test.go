@@ -0,0 +1,93 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiveTupleLayoutIPv4(t *testing.T) {
+	tuple := FiveTuple{
+		Src:   netip.MustParseAddrPort("10.0.0.1:40000"),
+		Dst:   netip.MustParseAddrPort("192.168.1.2:443"),
+		Proto: syscall.IPPROTO_TCP,
+	}
+
+	layout := FiveTupleLayout{}
+	key, err := layout.Marshal(tuple)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		10, 0, 0, 1,
+		192, 168, 1, 2,
+		0x9c, 0x40, // 40000
+		0x01, 0xbb, // 443
+		syscall.IPPROTO_TCP, 0, 0, 0,
+	}, key)
+
+	decoded, err := layout.Unmarshal(key)
+	require.NoError(t, err)
+	assert.Equal(t, tuple, decoded)
+
+	// IPv4-mapped addresses are accepted in IPv4 keys
+	mapped := tuple
+	mapped.Src = netip.MustParseAddrPort("[::ffff:10.0.0.1]:40000")
+	mappedKey, err := layout.Marshal(mapped)
+	require.NoError(t, err)
+	assert.Equal(t, key, mappedKey)
+
+	v6 := tuple
+	v6.Dst = netip.MustParseAddrPort("[2001:db8::1]:443")
+	_, err = layout.Marshal(v6)
+	assert.Error(t, err)
+}
+
+func TestFiveTupleLayoutIPv6(t *testing.T) {
+	tuple := FiveTuple{
+		Src:   netip.MustParseAddrPort("[2001:db8::1]:53"),
+		Dst:   netip.MustParseAddrPort("10.0.0.1:5353"),
+		Proto: syscall.IPPROTO_UDP,
+	}
+
+	layout := FiveTupleLayout{IPv6: true, HostOrderPorts: true}
+	assert.Equal(t, 40, layout.KeySize())
+
+	key, err := layout.Marshal(tuple)
+	require.NoError(t, err)
+	require.Len(t, key, 40)
+
+	src := tuple.Src.Addr().As16()
+	dst := netip.MustParseAddr("::ffff:10.0.0.1").As16()
+	assert.Equal(t, src[:], key[0:16])
+	assert.Equal(t, dst[:], key[16:32])
+	assert.Equal(t, uint16(53), binary.NativeEndian.Uint16(key[32:]))
+	assert.Equal(t, uint16(5353), binary.NativeEndian.Uint16(key[34:]))
+	assert.Equal(t, uint8(syscall.IPPROTO_UDP), key[36])
+
+	decoded, err := layout.Unmarshal(key)
+	require.NoError(t, err)
+	assert.Equal(t, tuple, decoded)
+}
+
+func TestFiveTupleLayoutSize(t *testing.T) {
+	tuple := FiveTuple{
+		Src: netip.MustParseAddrPort("10.0.0.1:1"),
+		Dst: netip.MustParseAddrPort("10.0.0.2:2"),
+	}
+
+	key, err := FiveTupleLayout{Size: 13}.Marshal(tuple)
+	require.NoError(t, err)
+	assert.Len(t, key, 13)
+
+	_, err = FiveTupleLayout{Size: 12}.Marshal(tuple)
+	assert.Error(t, err)
+
+	_, err = FiveTupleLayout{}.Marshal(FiveTuple{})
+	assert.Error(t, err)
+
+	_, err = FiveTupleLayout{}.Unmarshal(make([]byte, 12))
+	assert.Error(t, err)
+}
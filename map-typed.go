@@ -0,0 +1,77 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Typed lookups
+//
+// GetMapValue() and GetMapValuePerCPU() look keys up straight into Go
+// values, without allocating nor decoding:
+//
+//	type flowStats struct {
+//		Packets uint64
+//		Bytes   uint64
+//	}
+//
+//	var stats flowStats
+//	err := libbpfgo.GetMapValue(flows, &key, &stats)
+//
+// The keys and values must be laid out as the C types of the map, with the
+// same size, and hold no Go pointers (pointers, slices, strings, maps, ...).
+// Only their sizes are checked.
+//
+
+// GetMapValue looks the key up in the map into value. The map must not be
+// per-CPU.
+func GetMapValue[K, V any](m *BPFMap, key *K, value *V) error {
+	if err := checkTypedKey(m, key); err != nil {
+		return err
+	}
+	if isPerCPUMapType(m.Type()) {
+		return fmt.Errorf("failed to lookup value in map %s: per-CPU map, see GetMapValuePerCPU(): %w", m.Name(), syscall.EINVAL)
+	}
+	if size := int(unsafe.Sizeof(*value)); size != m.ValueSize() {
+		return fmt.Errorf("failed to lookup value in map %s: %T of %d bytes, value of %d: %w", m.Name(), *value, size, m.ValueSize(), syscall.EINVAL)
+	}
+
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(value)), unsafe.Sizeof(*value))
+
+	return m.GetValueInto(unsafe.Pointer(key), buf)
+}
+
+// GetMapValuePerCPU looks the key up in the per-CPU map into values, which
+// holds a value per possible CPU (see NumPossibleCPUs()). Per-CPU values are
+// 8 bytes aligned, so V is padded to a multiple of 8 bytes.
+func GetMapValuePerCPU[K, V any](m *BPFMap, key *K, values []V) error {
+	if err := checkTypedKey(m, key); err != nil {
+		return err
+	}
+	if !isPerCPUMapType(m.Type()) {
+		return fmt.Errorf("failed to lookup value in map %s: not a per-CPU map, see GetMapValue(): %w", m.Name(), syscall.EINVAL)
+	}
+
+	var value V
+	elemSize := int(unsafe.Sizeof(value))
+	if elemSize != int(roundUp(uint64(m.ValueSize()), 8)) {
+		return fmt.Errorf("failed to lookup value in map %s: %T of %d bytes, per-CPU value of %d (padded to %d): %w", m.Name(), value, elemSize, m.ValueSize(), roundUp(uint64(m.ValueSize()), 8), syscall.EINVAL)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("failed to lookup value in map %s: no values: %w", m.Name(), syscall.EINVAL)
+	}
+
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&values[0])), len(values)*elemSize)
+
+	return m.GetValueInto(unsafe.Pointer(key), buf)
+}
+
+func checkTypedKey[K any](m *BPFMap, key *K) error {
+	if size := int(unsafe.Sizeof(*key)); size != m.KeySize() {
+		return fmt.Errorf("failed to lookup value in map %s: %T of %d bytes, key of %d: %w", m.Name(), *key, size, m.KeySize(), syscall.EINVAL)
+	}
+
+	return nil
+}
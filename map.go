@@ -198,6 +198,29 @@ func (m *BPFMap) BTFValueTypeID() uint32 {
 	return uint32(C.bpf_map__btf_value_type_id(m.bpfMap))
 }
 
+// BTFKeyTypeName returns the C name of the key type of the map, from the BTF
+// of the object (e.g. "u32", "struct flow_key"), to label dumps and metrics.
+// It fails with ENOENT if the map has no BTF type, as maps of objects built
+// without BTF, or of the key-less map types.
+func (m *BPFMap) BTFKeyTypeName() (string, error) {
+	return m.btfTypeName("key", m.BTFKeyTypeID())
+}
+
+// BTFValueTypeName returns the C name of the value type of the map, from the
+// BTF of the object. It fails with ENOENT if the map has no BTF type.
+func (m *BPFMap) BTFValueTypeName() (string, error) {
+	return m.btfTypeName("value", m.BTFValueTypeID())
+}
+
+func (m *BPFMap) btfTypeName(what string, typeID uint32) (string, error) {
+	btf := C.bpf_object__btf(m.module.obj)
+	if btf == nil || typeID == 0 {
+		return "", fmt.Errorf("map %s has no BTF %s type: %w", m.Name(), what, syscall.ENOENT)
+	}
+
+	return btfTypeName(btf, typeID), nil
+}
+
 func (m *BPFMap) IfIndex() uint32 {
 	return uint32(C.bpf_map__ifindex(m.bpfMap))
 }
@@ -297,6 +320,37 @@ func (m *BPFMap) Unpin(pinPath string) error {
 	return nil
 }
 
+//
+// BPFMap struct_ops
+//
+
+// AttachStructOps registers the struct_ops map (a sched_ext scheduler, a TCP
+// congestion control...) with the kernel subsystem it implements. The returned
+// link unregisters it when destroyed, and is destroyed when the module is
+// closed. Maps declared in SEC(".struct_ops.link") are registered through a
+// BPF link, which can be pinned to outlive the process; the others through
+// the map itself.
+func (m *BPFMap) AttachStructOps() (*BPFLink, error) {
+	if m.Type() != MapTypeStructOps {
+		return nil, fmt.Errorf("failed to attach struct_ops map %s: map type is %s", m.Name(), m.Type())
+	}
+
+	linkC, errno := C.bpf_map__attach_struct_ops(m.bpfMap)
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach struct_ops map %s: %w", m.Name(), classifyError(opAttach, errno, ""))
+	}
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		structOps: m,
+		linkType:  StructOps,
+		eventName: fmt.Sprintf("struct_ops-%s", m.Name()),
+	}
+	m.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
 //
 // BPFMap Map of Maps
 //
@@ -383,6 +437,8 @@ func (m *BPFMap) GetValue(key unsafe.Pointer) ([]byte, error) {
 	return m.GetValueFlags(key, MapFlagUpdateAny)
 }
 
+// GetValueFlags is GetValue() with lookup flags (MapFlagFLock). The returned
+// value is a new slice, owned by the caller.
 func (m *BPFMap) GetValueFlags(key unsafe.Pointer, flags MapFlag) ([]byte, error) {
 	valueSize, err := CalcMapValueSize(m.ValueSize(), m.Type())
 	if err != nil {
@@ -390,6 +446,33 @@ func (m *BPFMap) GetValueFlags(key unsafe.Pointer, flags MapFlag) ([]byte, error
 	}
 
 	value := make([]byte, valueSize)
+	if err := m.GetValueIntoFlags(key, value, flags); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// GetValueInto retrieves the value associated with the key into value,
+// instead of a new slice as GetValue() does, so that frequent lookups do not
+// allocate. value is owned by the caller, the map does not keep it: it is
+// overwritten by each lookup into it, and can be reused once done with the
+// value. It must hold at least the value size (see CalcMapValueSize()), and
+// only its first value size bytes are written.
+func (m *BPFMap) GetValueInto(key unsafe.Pointer, value []byte) error {
+	return m.GetValueIntoFlags(key, value, MapFlagUpdateAny)
+}
+
+// GetValueIntoFlags is GetValueInto() with lookup flags (MapFlagFLock).
+func (m *BPFMap) GetValueIntoFlags(key unsafe.Pointer, value []byte, flags MapFlag) error {
+	valueSize, err := CalcMapValueSize(m.ValueSize(), m.Type())
+	if err != nil {
+		return fmt.Errorf("map %s %w", m.Name(), err)
+	}
+	if len(value) < valueSize {
+		return fmt.Errorf("failed to lookup value %v in map %s: buffer of %d bytes, value of %d: %w", key, m.Name(), len(value), valueSize, syscall.EINVAL)
+	}
+
 	retC := C.bpf_map__lookup_elem(
 		m.bpfMap,
 		key,
@@ -399,10 +482,10 @@ func (m *BPFMap) GetValueFlags(key unsafe.Pointer, flags MapFlag) ([]byte, error
 		C.ulonglong(flags),
 	)
 	if retC < 0 {
-		return nil, fmt.Errorf("failed to lookup value %v in map %s: %w", key, m.Name(), syscall.Errno(-retC))
+		return fmt.Errorf("failed to lookup value %v in map %s: %w", key, m.Name(), syscall.Errno(-retC))
 	}
 
-	return value, nil
+	return nil
 }
 
 // LookupAndDeleteElem stores the value associated with a given key into the
@@ -500,7 +583,7 @@ func (m *BPFMap) UpdateValueFlags(key, value unsafe.Pointer, flags MapFlag) erro
 		C.ulonglong(flags),
 	)
 	if retC < 0 {
-		return fmt.Errorf("failed to update map %s: %w", m.Name(), syscall.Errno(-retC))
+		return fmt.Errorf("failed to update map %s: %w", m.Name(), classifyError(opMapUpdate, syscall.Errno(-retC), ""))
 	}
 
 	return nil
@@ -525,6 +608,20 @@ func (m *BPFMap) DeleteKey(key unsafe.Pointer) error {
 }
 
 // GetNextKey allows to iterate BPF map keys by fetching next key that follows current key.
+// A nil key fetches the first key. Past the last key, the error is ErrNoMoreKeys
+// (and syscall.ENOENT):
+//
+//	err := m.GetNextKey(nil, unsafe.Pointer(&key))
+//	for err == nil {
+//	    ...
+//	    err = m.GetNextKey(unsafe.Pointer(&key), unsafe.Pointer(&key))
+//	}
+//	if !errors.Is(err, ErrNoMoreKeys) {
+//	    return err
+//	}
+//
+// If key is not in the map (e.g. it was deleted meanwhile), hash maps restart
+// from the first key.
 func (m *BPFMap) GetNextKey(key unsafe.Pointer, nextKey unsafe.Pointer) error {
 	retC := C.bpf_map__get_next_key(
 		m.bpfMap,
@@ -533,12 +630,45 @@ func (m *BPFMap) GetNextKey(key unsafe.Pointer, nextKey unsafe.Pointer) error {
 		C.ulong(m.KeySize()),
 	)
 	if retC < 0 {
-		return fmt.Errorf("failed to get next key %d in map %s: %w", key, m.Name(), syscall.Errno(-retC))
+		errno := syscall.Errno(-retC)
+		return nextKeyError(fmt.Errorf("failed to get next key %d in map %s: %w", key, m.Name(), errno), errno)
 	}
 
 	return nil
 }
 
+// DeleteAll deletes all the keys of the map, and returns the number of keys
+// it deleted. It is safe against concurrent writers: the keys deleted
+// meanwhile are skipped, and the keys added meanwhile may or may not be
+// deleted. Array maps, whose keys can not be deleted, are not supported.
+func (m *BPFMap) DeleteAll() (int, error) {
+	n, err := deleteAllKeys(m.KeySize(), m.GetNextKey, m.DeleteKey)
+	if err != nil {
+		return n, fmt.Errorf("failed to delete all keys in map %s: %w", m.Name(), err)
+	}
+
+	return n, nil
+}
+
+// ApproxEntryCount returns the number of entries of the map, read with batch
+// lookups where the kernel supports them, or else by walking its keys. The
+// count of arrays is their capacity, as they are always full.
+//
+// The count is a snapshot, approximate if programs update the map
+// meanwhile, and costs a walk of the whole map: it is meant for periodic
+// metrics, not for hot paths.
+func (m *BPFMap) ApproxEntryCount() (uint32, error) {
+	return m.bpfMapLow.ApproxEntryCount()
+}
+
+// Utilization returns the ratio, between 0 and 1, of the entries of the map
+// to its capacity (max_entries). Inserts into a full hash map fail, so
+// alerting on a high utilization catches a map about to drop updates; LRU
+// maps evict entries instead, and stay close to 1 once warm.
+func (m *BPFMap) Utilization() (float64, error) {
+	return m.bpfMapLow.Utilization()
+}
+
 //
 // BPFMap Batch Operations (low-level API)
 //
@@ -632,6 +762,29 @@ func (m *BPFMap) DeleteKeyBatch(keys unsafe.Pointer, count uint32) (uint32, erro
 	return m.bpfMapLow.DeleteKeyBatch(keys, count)
 }
 
+// GetValueBatchWithFlags is GetValueBatch reporting the progress of the
+// lookup: the values and number of elements read even on error, the error of
+// the element it stopped at as a *MapBatchError, and whether the map was
+// fully read. Until then, nextKey resumes the lookup as startKey. See
+// `BPFMapLow.GetValueBatchWithFlags` for more context.
+func (m *BPFMap) GetValueBatchWithFlags(keys, startKey, nextKey unsafe.Pointer, count uint32, elemFlags MapFlag) ([][]byte, MapBatchResult, error) {
+	return m.bpfMapLow.GetValueBatchWithFlags(keys, startKey, nextKey, count, elemFlags)
+}
+
+// GetValueAndDeleteBatchWithFlags is GetValueAndDeleteBatch reporting the
+// progress of the lookup and deletion, as GetValueBatchWithFlags.
+func (m *BPFMap) GetValueAndDeleteBatchWithFlags(keys, startKey, nextKey unsafe.Pointer, count uint32, elemFlags MapFlag) ([][]byte, MapBatchResult, error) {
+	return m.bpfMapLow.GetValueAndDeleteBatchWithFlags(keys, startKey, nextKey, count, elemFlags)
+}
+
+// UpdateBatchWithFlags is UpdateBatch reporting the progress of the update:
+// the number of elements updated even on error, and the error of the element
+// it stopped at as a *MapBatchError. See `BPFMapLow.UpdateBatchWithFlags` for
+// more context.
+func (m *BPFMap) UpdateBatchWithFlags(keys, values unsafe.Pointer, count uint32, elemFlags MapFlag) (MapBatchResult, error) {
+	return m.bpfMapLow.UpdateBatchWithFlags(keys, values, count, elemFlags)
+}
+
 //
 // BPFMap Iterator (low-level API)
 //
@@ -6,6 +6,14 @@ package libbpfgo
 */
 import "C"
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"syscall"
+)
+
 //
 // Misc generic helpers
 //
@@ -14,3 +22,54 @@ import "C"
 func roundUp(x, y uint64) uint64 {
 	return ((x + (y - 1)) / y) * y
 }
+
+// parseEnumName finds the enum value named s, case-insensitively, with or
+// without the common prefix of the names.
+func parseEnumName[T comparable](s string, prefix string, names map[T]string) (T, bool) {
+	for value, name := range names {
+		if strings.EqualFold(s, name) || strings.EqualFold(s, strings.TrimPrefix(name, prefix)) {
+			return value, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// sortedEnumValues returns the values of the enum names map, sorted.
+func sortedEnumValues[T ~uint32](names map[T]string) []T {
+	values := make([]T, 0, len(names))
+	for value := range names {
+		values = append(values, value)
+	}
+	slices.Sort(values)
+
+	return values
+}
+
+// maxQueryRetries bounds the retries of a query outgrowing its count.
+const maxQueryRetries = 5
+
+// queryRetrying runs a query sized with the count of the results, first
+// queried. The target may gain results between the calls, the query failing
+// with ENOSPC: it is retried up to maxQueryRetries times.
+func queryRetrying[T any](count func() (uint32, error), query func(count uint32) (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	for i := 0; i <= maxQueryRetries; i++ {
+		var n uint32
+		n, err = count()
+		if err != nil {
+			return result, err
+		}
+
+		result, err = query(n)
+		if !errors.Is(err, syscall.ENOSPC) {
+			return result, err
+		}
+	}
+
+	return result, fmt.Errorf("%w, after %d retries", err, maxQueryRetries)
+}
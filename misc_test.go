@@ -0,0 +1,104 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnumNameRoundTrip(t *testing.T) {
+	for _, progType := range BPFProgTypes() {
+		for _, s := range []string{
+			progType.String(),
+			strings.ToLower(progType.String()),
+			strings.TrimPrefix(progType.String(), "BPF_PROG_TYPE_"),
+		} {
+			parsed, ok := parseEnumName(s, "BPF_PROG_TYPE_", bpfProgTypeToString)
+			assert.True(t, ok, s)
+			assert.Equal(t, progType, parsed, s)
+		}
+	}
+
+	for _, attachType := range BPFAttachTypes() {
+		for _, s := range []string{
+			attachType.String(),
+			strings.ToLower(strings.TrimPrefix(attachType.String(), "BPF_")),
+		} {
+			parsed, ok := parseEnumName(s, "BPF_", bpfAttachTypeToString)
+			assert.True(t, ok, s)
+			assert.Equal(t, attachType, parsed, s)
+		}
+	}
+}
+
+func TestParseEnumNameUnknown(t *testing.T) {
+	_, ok := parseEnumName("kprobe/do_unlinkat", "BPF_PROG_TYPE_", bpfProgTypeToString)
+	assert.False(t, ok)
+
+	_, ok = parseEnumName("", "BPF_", bpfAttachTypeToString)
+	assert.False(t, ok)
+}
+
+func TestSortedEnumValues(t *testing.T) {
+	progTypes := BPFProgTypes()
+	assert.Len(t, progTypes, len(bpfProgTypeToString))
+	assert.Equal(t, BPFProgTypeUnspec, progTypes[0])
+	assert.IsIncreasing(t, progTypes)
+}
+
+// TestEnumsExhaustive fails when the libbpf headers gain program or attach
+// types missing from the enums.
+func TestEnumsExhaustive(t *testing.T) {
+	for progType := BPFProgType(0); progType < bpfProgTypeMax; progType++ {
+		_, ok := bpfProgTypeToString[progType]
+		assert.True(t, ok, "program type %d has no name", progType)
+	}
+	assert.Len(t, bpfProgTypeToString, bpfProgTypeMax)
+
+	for attachType := BPFAttachType(0); attachType < bpfAttachTypeMax; attachType++ {
+		_, ok := bpfAttachTypeToString[attachType]
+		assert.True(t, ok, "attach type %d has no name", attachType)
+	}
+	assert.Len(t, bpfAttachTypeToString, bpfAttachTypeMax)
+}
+
+func TestQueryRetrying(t *testing.T) {
+	// The target gains a result between the count and the query, twice
+	counts, queries := 0, 0
+	count := func() (uint32, error) {
+		counts++
+		return uint32(counts), nil
+	}
+	query := func(n uint32) ([]uint32, error) {
+		queries++
+		if queries <= 2 {
+			return nil, fmt.Errorf("failed to query: %w", syscall.ENOSPC)
+		}
+		return make([]uint32, n), nil
+	}
+	result, err := queryRetrying(count, query)
+	require.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	// A target always outgrowing the count
+	queries = 0
+	_, err = queryRetrying(count, func(n uint32) ([]uint32, error) {
+		queries++
+		return nil, fmt.Errorf("failed to query: %w", syscall.ENOSPC)
+	})
+	assert.ErrorIs(t, err, syscall.ENOSPC)
+	assert.Equal(t, maxQueryRetries+1, queries)
+
+	// Other errors are not retried
+	queries = 0
+	_, err = queryRetrying(count, func(n uint32) ([]uint32, error) {
+		queries++
+		return nil, fmt.Errorf("failed to query: %w", syscall.EPERM)
+	})
+	assert.ErrorIs(t, err, syscall.EPERM)
+	assert.Equal(t, 1, queries)
+}
@@ -0,0 +1,103 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Buffer backed objects
+//
+// An object opened from a buffer has no path: libbpf parses it from memory,
+// and debugging tools looking for the source of the loaded programs find
+// nothing to read. With NewModuleArgs.BufferFile, the buffer is written to a
+// memfd, or to a temporary file in NewModuleArgs.BufferDir, and opened from
+// it, so the object has a path, returned by Module.ObjectPath(), as long as
+// the Module is open.
+//
+// Modules opened from identical buffers share their object name, which tells
+// them apart in module events and logs. NewModuleArgs.UniqueObjName suffixes
+// the name with a number unique to the process.
+//
+
+// objNameSeq numbers the unique object names.
+var objNameSeq atomic.Uint64
+
+// uniqueObjName returns the name suffixed with a number unique to the
+// process.
+func uniqueObjName(name string) string {
+	if name == "" {
+		name = "obj"
+	}
+
+	return fmt.Sprintf("%s-%d", name, objNameSeq.Add(1))
+}
+
+// objectFile is the file an object is opened from, closed (and removed, if a
+// temporary file) with the Module.
+type objectFile struct {
+	file      *os.File
+	path      string
+	temporary bool
+}
+
+// newObjectFile writes the object buffer to a memfd named after the object,
+// or to a temporary file in dir if set.
+func newObjectFile(name, dir string, buf []byte) (*objectFile, error) {
+	pattern := strings.ReplaceAll(name, "/", "_")
+	if pattern == "" {
+		pattern = "libbpfgo"
+	}
+
+	var f *objectFile
+	if dir != "" {
+		file, err := os.CreateTemp(dir, pattern+"-*.bpf.o")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create object file: %w", err)
+		}
+		f = &objectFile{file: file, path: file.Name(), temporary: true}
+	} else {
+		nameC := C.CString(pattern)
+		defer C.free(unsafe.Pointer(nameC))
+
+		fdC := C.cgo_memfd_create(nameC)
+		if fdC < 0 {
+			return nil, fmt.Errorf("failed to create object memfd: %w", syscall.Errno(-fdC))
+		}
+		f = &objectFile{
+			file: os.NewFile(uintptr(fdC), "memfd:"+pattern),
+			path: fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), fdC),
+		}
+	}
+
+	if _, err := f.file.Write(buf); err != nil {
+		f.close()
+		return nil, fmt.Errorf("failed to write object file %s: %w", f.path, err)
+	}
+
+	return f, nil
+}
+
+func (f *objectFile) close() {
+	f.file.Close()
+	if f.temporary {
+		os.Remove(f.path)
+	}
+}
+
+// ObjectPath returns the path the object was opened from: its file, or the
+// memfd or temporary file of a buffer opened with NewModuleArgs.BufferFile.
+// It returns "" for buffers opened from memory.
+func (m *Module) ObjectPath() string {
+	return m.objPath
+}
@@ -0,0 +1,44 @@
+package libbpfgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniqueObjName(t *testing.T) {
+	a, b := uniqueObjName("agent"), uniqueObjName("agent")
+	assert.NotEqual(t, a, b)
+	assert.True(t, strings.HasPrefix(a, "agent-"), a)
+	assert.True(t, strings.HasPrefix(uniqueObjName(""), "obj-"))
+}
+
+func TestNewObjectFile(t *testing.T) {
+	dir := t.TempDir()
+	buf := []byte("\x7fELF object")
+
+	f, err := newObjectFile("probes/agent", dir, buf)
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(f.path))
+	assert.True(t, strings.HasPrefix(filepath.Base(f.path), "probes_agent-"), f.path)
+
+	data, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+	assert.Equal(t, buf, data)
+
+	// Identical buffers do not collide
+	g, err := newObjectFile("probes/agent", dir, buf)
+	require.NoError(t, err)
+	assert.NotEqual(t, f.path, g.path)
+	g.close()
+
+	f.close()
+	assert.NoFileExists(t, f.path)
+
+	_, err = newObjectFile("agent", filepath.Join(dir, "missing"), buf)
+	assert.Error(t, err)
+}
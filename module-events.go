@@ -0,0 +1,188 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"time"
+)
+
+//
+// Module events
+//
+// A Module reports its lifecycle to an optional ModuleEventHandler, so that
+// supervisors can log or trace the loader without wrapping every call:
+//
+//	events := make(chan ModuleEvent, 64)
+//	m, err := NewModuleFromFileArgs(NewModuleArgs{
+//	    BPFObjPath:   "prog.bpf.o",
+//	    EventHandler: ModuleEventsToChannel(events),
+//	})
+//
+// The handler is called synchronously from the goroutine doing the
+// operation, and must not block nor call back into the Module.
+//
+
+// ModuleEventType is the type of a ModuleEvent.
+type ModuleEventType int
+
+const (
+	ModuleEventObjectOpened ModuleEventType = iota
+	ModuleEventMapCreated
+	ModuleEventProgramLoaded
+	ModuleEventObjectLoaded
+	ModuleEventLinkAttached
+	ModuleEventLinkDetached
+	ModuleEventBufferStarted
+	ModuleEventBufferStopped
+	ModuleEventObjectClosed
+)
+
+var moduleEventTypeToString = map[ModuleEventType]string{
+	ModuleEventObjectOpened:  "object opened",
+	ModuleEventMapCreated:    "map created",
+	ModuleEventProgramLoaded: "program loaded",
+	ModuleEventObjectLoaded:  "object loaded",
+	ModuleEventLinkAttached:  "link attached",
+	ModuleEventLinkDetached:  "link detached",
+	ModuleEventBufferStarted: "buffer started",
+	ModuleEventBufferStopped: "buffer stopped",
+	ModuleEventObjectClosed:  "object closed",
+}
+
+func (t ModuleEventType) String() string {
+	str, ok := moduleEventTypeToString[t]
+	if !ok {
+		return fmt.Sprintf("ModuleEventType(%d)", int(t))
+	}
+
+	return str
+}
+
+// ModuleEvent is a lifecycle event of a Module.
+type ModuleEvent struct {
+	Type   ModuleEventType
+	Time   time.Time
+	Object string // name of the BPF object
+	// Name is the name of the map (MapCreated, BufferStarted, BufferStopped),
+	// of the program (ProgramLoaded) or of the link event (LinkAttached,
+	// LinkDetached).
+	Name string
+	// Program is the name of the program of a link, or of the map of a
+	// struct_ops link.
+	Program string
+	// LinkType is the type of a link.
+	LinkType LinkType
+	// Elapsed is the time since the start of BPFLoadObject(), for the
+	// MapCreated and ProgramLoaded events.
+	Elapsed time.Duration
+	// VerificationTime is the time the verifier took on the program
+	// (ProgramLoaded), as reported by the kernel (v5.2+), or 0.
+	VerificationTime time.Duration
+	// Done and Total count the maps and programs of the object created and
+	// loaded so far, and to be, for the MapCreated and ProgramLoaded events.
+	Done  int
+	Total int
+}
+
+func (e ModuleEvent) String() string {
+	switch e.Type {
+	case ModuleEventLinkAttached, ModuleEventLinkDetached:
+		return fmt.Sprintf("%s: %s %s (program %s)", e.Object, e.Type, e.Name, e.Program)
+	case ModuleEventObjectOpened, ModuleEventObjectLoaded, ModuleEventObjectClosed:
+		return fmt.Sprintf("%s: %s", e.Object, e.Type)
+	}
+
+	if e.Total > 0 {
+		if e.VerificationTime > 0 {
+			return fmt.Sprintf("%s: %s %s (%d/%d, verified in %s)", e.Object, e.Type, e.Name, e.Done, e.Total, e.VerificationTime)
+		}
+		return fmt.Sprintf("%s: %s %s (%d/%d)", e.Object, e.Type, e.Name, e.Done, e.Total)
+	}
+
+	return fmt.Sprintf("%s: %s %s", e.Object, e.Type, e.Name)
+}
+
+// ModuleEventHandler receives the lifecycle events of a Module.
+type ModuleEventHandler func(ModuleEvent)
+
+// ModuleEventsToChannel returns a ModuleEventHandler sending the events to
+// the channel. Events are dropped, not waited for, when the channel is full.
+func ModuleEventsToChannel(events chan<- ModuleEvent) ModuleEventHandler {
+	return func(e ModuleEvent) {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// SetEventHandler sets the handler of the lifecycle events of the module, or
+// removes it if nil. It must not be called concurrently with other methods
+// of the module.
+func (m *Module) SetEventHandler(handler ModuleEventHandler) {
+	m.eventHandler = handler
+}
+
+// emit sends an event to the event handler, if any.
+func (m *Module) emit(e ModuleEvent) {
+	if m == nil || m.eventHandler == nil {
+		return
+	}
+
+	e.Time = time.Now()
+	if m.obj != nil {
+		e.Object = C.GoString(C.bpf_object__name(m.obj))
+	}
+	m.eventHandler(e)
+}
+
+// emitLoaded sends the events of the maps created and programs loaded with
+// the object, that were not reported during the load.
+func (m *Module) emitLoaded(progress *loadProgress) {
+	if m.eventHandler == nil {
+		return
+	}
+
+	for mapC := C.bpf_object__next_map(m.obj, nil); mapC != nil; mapC = C.bpf_object__next_map(m.obj, mapC) {
+		name := C.GoString(C.bpf_map__name(mapC))
+		if C.bpf_map__fd(mapC) < 0 || progress.reported(ModuleEventMapCreated, name) {
+			continue
+		}
+		m.emit(progress.record(ModuleEventMapCreated, name, nil))
+	}
+	for progC := C.bpf_object__next_program(m.obj, nil); progC != nil; progC = C.bpf_object__next_program(m.obj, progC) {
+		name := C.GoString(C.bpf_program__name(progC))
+		if C.bpf_program__fd(progC) < 0 || progress.reported(ModuleEventProgramLoaded, name) {
+			continue
+		}
+		m.emit(progress.record(ModuleEventProgramLoaded, name, nil))
+	}
+	m.emit(ModuleEvent{Type: ModuleEventObjectLoaded})
+}
+
+func (m *Module) emitLink(t ModuleEventType, link *BPFLink) {
+	if m == nil || m.eventHandler == nil {
+		return
+	}
+
+	m.emit(ModuleEvent{
+		Type:     t,
+		Name:     link.eventName,
+		Program:  link.ownerName(),
+		LinkType: link.linkType,
+	})
+}
+
+// emitBuffer sends an event of the ring or perf buffer of the map.
+func emitBuffer(t ModuleEventType, bpfMap *BPFMap) {
+	if bpfMap == nil || bpfMap.module == nil {
+		return
+	}
+
+	bpfMap.module.emit(ModuleEvent{Type: t, Name: bpfMap.Name()})
+}
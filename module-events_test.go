@@ -0,0 +1,55 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleEventString(t *testing.T) {
+	tests := []struct {
+		event ModuleEvent
+		want  string
+	}{
+		{ModuleEvent{Type: ModuleEventObjectOpened, Object: "test_bpf"}, "test_bpf: object opened"},
+		{ModuleEvent{Type: ModuleEventMapCreated, Object: "test_bpf", Name: "events"}, "test_bpf: map created events"},
+		{ModuleEvent{Type: ModuleEventLinkAttached, Object: "test_bpf", Name: "tcp_connect", Program: "kprobe__tcp_connect"}, "test_bpf: link attached tcp_connect (program kprobe__tcp_connect)"},
+		{ModuleEvent{Type: ModuleEventType(100), Object: "test_bpf", Name: "x"}, "test_bpf: ModuleEventType(100) x"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.event.String())
+	}
+}
+
+func TestModuleEventsToChannel(t *testing.T) {
+	events := make(chan ModuleEvent, 1)
+	handler := ModuleEventsToChannel(events)
+
+	handler(ModuleEvent{Type: ModuleEventBufferStarted, Name: "events"})
+	// The channel is full, the event is dropped instead of blocking
+	handler(ModuleEvent{Type: ModuleEventBufferStopped, Name: "events"})
+
+	require.Len(t, events, 1)
+	assert.Equal(t, ModuleEventBufferStarted, (<-events).Type)
+}
+
+func TestModuleEmit(t *testing.T) {
+	var m *Module
+	m.emit(ModuleEvent{Type: ModuleEventObjectClosed}) // no module, no handler
+
+	var got []ModuleEvent
+	m = &Module{}
+	m.emit(ModuleEvent{Type: ModuleEventObjectOpened}) // no handler
+
+	m.SetEventHandler(func(e ModuleEvent) { got = append(got, e) })
+	m.emit(ModuleEvent{Type: ModuleEventObjectOpened})
+	require.Len(t, got, 1)
+	assert.Equal(t, ModuleEventObjectOpened, got[0].Type)
+	assert.False(t, got[0].Time.IsZero())
+
+	m.SetEventHandler(nil)
+	m.emit(ModuleEvent{Type: ModuleEventObjectClosed})
+	assert.Len(t, got, 1)
+}
@@ -0,0 +1,148 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Object indices
+//
+// libbpf keeps the maps and programs of a BPF object in arrays filled when
+// the object is opened, and never reorders them: programs are sorted by ELF
+// section and by offset within their section, maps follow their declaration
+// order (SEC(".maps") maps first, then the internal .data, .rodata, .bss and
+// .kconfig maps). The index of an object in these arrays is thus stable for
+// the lifetime of the Module, and the same for every Module opened from the
+// same ELF file, so generated code can reference maps and programs by index
+// instead of looking them up by name. The BPFObjectIterator iterates in index
+// order.
+//
+
+// indexObjects snapshots the maps and programs of the object, once.
+func (m *Module) indexObjects() {
+	if m.mapsC != nil || m.progsC != nil {
+		return
+	}
+
+	m.mapsC = []*C.struct_bpf_map{}
+	for mapC := C.bpf_object__next_map(m.obj, nil); mapC != nil; mapC = C.bpf_object__next_map(m.obj, mapC) {
+		m.mapsC = append(m.mapsC, mapC)
+	}
+
+	m.progsC = []*C.struct_bpf_program{}
+	for progC := C.bpf_object__next_program(m.obj, nil); progC != nil; progC = C.bpf_object__next_program(m.obj, progC) {
+		m.progsC = append(m.progsC, progC)
+	}
+}
+
+// MapCount returns the number of maps in the BPF object.
+func (m *Module) MapCount() int {
+	m.indexObjects()
+	return len(m.mapsC)
+}
+
+// ProgramCount returns the number of programs in the BPF object.
+func (m *Module) ProgramCount() int {
+	m.indexObjects()
+	return len(m.progsC)
+}
+
+// MapByIndex returns the map at the given index, from 0 to MapCount()-1.
+func (m *Module) MapByIndex(index int) (*BPFMap, error) {
+	m.indexObjects()
+	if index < 0 || index >= len(m.mapsC) {
+		return nil, fmt.Errorf("failed to find BPF map at index %d: %w", index, syscall.ENOENT)
+	}
+
+	return m.newBPFMap(m.mapsC[index]), nil
+}
+
+// ProgramByIndex returns the program at the given index, from 0 to
+// ProgramCount()-1.
+func (m *Module) ProgramByIndex(index int) (*BPFProg, error) {
+	m.indexObjects()
+	if index < 0 || index >= len(m.progsC) {
+		return nil, fmt.Errorf("failed to find BPF program at index %d: %w", index, syscall.ENOENT)
+	}
+
+	return &BPFProg{
+		prog:   m.progsC[index],
+		module: m,
+	}, nil
+}
+
+// MapIndex returns the index of the map with the given name.
+func (m *Module) MapIndex(mapName string) (int, error) {
+	mapNameC := C.CString(mapName)
+	defer C.free(unsafe.Pointer(mapNameC))
+
+	bpfMapC, errno := C.bpf_object__find_map_by_name(m.obj, mapNameC)
+	if bpfMapC == nil {
+		return -1, fmt.Errorf("failed to find BPF map %s: %w", mapName, errno)
+	}
+
+	return m.mapIndex(bpfMapC), nil
+}
+
+// ProgramIndex returns the index of the program with the given name.
+func (m *Module) ProgramIndex(progName string) (int, error) {
+	progNameC := C.CString(progName)
+	defer C.free(unsafe.Pointer(progNameC))
+
+	progC, errno := C.bpf_object__find_program_by_name(m.obj, progNameC)
+	if progC == nil {
+		return -1, fmt.Errorf("failed to find BPF program %s: %w", progName, errno)
+	}
+
+	return m.programIndex(progC), nil
+}
+
+func (m *Module) mapIndex(bpfMapC *C.struct_bpf_map) int {
+	m.indexObjects()
+	for i, mapC := range m.mapsC {
+		if mapC == bpfMapC {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (m *Module) programIndex(progC *C.struct_bpf_program) int {
+	m.indexObjects()
+	for i, c := range m.progsC {
+		if c == progC {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Index returns the index of the map in its BPF object, or -1 if the map
+// does not belong to a Module.
+func (b *BPFMap) Index() int {
+	if b.module == nil {
+		return -1
+	}
+
+	return b.module.mapIndex(b.bpfMap)
+}
+
+// Index returns the index of the program in its BPF object, or -1 if the
+// program does not belong to a Module.
+func (p *BPFProg) Index() int {
+	if p.module == nil {
+		return -1
+	}
+
+	return p.module.programIndex(p.prog)
+}
@@ -10,7 +10,8 @@ import "C"
 // BPFObjectIterator (Module Iterator)
 //
 
-// BPFObjectProgramIterator iterates over programs and maps in a BPF object
+// BPFObjectProgramIterator iterates over programs and maps in a BPF object,
+// in index order (see Module.MapByIndex() and Module.ProgramByIndex()).
 type BPFObjectIterator struct {
 	m        *Module
 	prevProg *BPFProg
@@ -0,0 +1,340 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Pinned object tree
+//
+// A loader process pins the maps and programs of a loaded Module into a
+// directory tree (Module.PinObjects()), and a runtime process adopts them
+// later (NewModuleFromPinnedDir()) without needing the ELF object:
+//
+//	<dir>/maps/<map name>
+//	<dir>/progs/<program name>
+//
+
+const (
+	pinnedMapsDir  = "maps"
+	pinnedProgsDir = "progs"
+)
+
+// PinObjects pins all loaded maps and programs of the module into the given
+// directory, which must be in a BPF filesystem. Maps that are not created and
+// programs that are not loaded are skipped. If pinning fails, the objects
+// already pinned by this call are unpinned.
+func (m *Module) PinObjects(dir string) error {
+	if !m.loaded {
+		return fmt.Errorf("failed to pin objects to %s: module not loaded", dir)
+	}
+
+	var objs []pinnedObject
+	it := m.Iterator()
+	for bpfMap := it.NextMap(); bpfMap != nil; bpfMap = it.NextMap() {
+		if fd := bpfMap.FileDescriptor(); fd >= 0 {
+			objs = append(objs, pinnedObject{fd: fd, subdir: pinnedMapsDir, name: bpfMap.Name()})
+		}
+	}
+	for prog := it.NextProgram(); prog != nil; prog = it.NextProgram() {
+		if fd := prog.FileDescriptor(); fd >= 0 {
+			objs = append(objs, pinnedObject{fd: fd, subdir: pinnedProgsDir, name: prog.Name()})
+		}
+	}
+
+	return pinObjects(dir, objs, objPin)
+}
+
+// pinnedObject is a map or program to pin into its subdirectory of the tree.
+type pinnedObject struct {
+	fd     int
+	subdir string
+	name   string
+}
+
+// pinObjects pins the objects into the tree at dir with pin. If pinning
+// fails, the objects already pinned are unpinned.
+func pinObjects(dir string, objs []pinnedObject, pin func(fd int, path string) error) error {
+	var pinned []string
+	for _, obj := range objs {
+		path := filepath.Join(dir, obj.subdir, obj.name)
+		err := os.MkdirAll(filepath.Dir(path), 0o700)
+		if err != nil {
+			err = fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		} else {
+			err = pin(obj.fd, path)
+		}
+		if err != nil {
+			for _, path := range pinned {
+				_ = os.Remove(path)
+			}
+
+			return err
+		}
+		pinned = append(pinned, path)
+	}
+
+	return nil
+}
+
+func objPin(fd int, path string) error {
+	pathC := C.CString(path)
+	defer C.free(unsafe.Pointer(pathC))
+
+	retC := C.bpf_obj_pin(C.int(fd), pathC)
+	if retC < 0 {
+		return fmt.Errorf("failed to pin object to %s: %w", path, syscall.Errno(-retC))
+	}
+
+	return nil
+}
+
+// PinAccess is the access mode of the file descriptor of a pinned object.
+// The bpffs permissions of the pin are checked against it, so a process
+// allowed to read a pin, and not to write it, can only get it read-only.
+type PinAccess uint32
+
+const (
+	PinAccessReadWrite PinAccess = 0
+	// PinAccessReadOnly forbids updates of a map through the descriptor.
+	PinAccessReadOnly PinAccess = C.BPF_F_RDONLY
+	// PinAccessWriteOnly forbids lookups of a map through the descriptor.
+	PinAccessWriteOnly PinAccess = C.BPF_F_WRONLY
+)
+
+var pinAccessToString = map[PinAccess]string{
+	PinAccessReadWrite: "read-write",
+	PinAccessReadOnly:  "read-only",
+	PinAccessWriteOnly: "write-only",
+}
+
+func (a PinAccess) String() string {
+	str, ok := pinAccessToString[a]
+	if !ok {
+		return fmt.Sprintf("PinAccess(%d)", uint32(a))
+	}
+
+	return str
+}
+
+// objGetAccess gets the object pinned at path, through getPath (the path
+// itself, or a descriptor of the pin), with the given access mode.
+func objGetAccess(getPath, path string, access PinAccess) (int, error) {
+	if _, ok := pinAccessToString[access]; !ok {
+		return -1, fmt.Errorf("failed to get pinned object %s: invalid access mode %s", path, access)
+	}
+
+	pathC := C.CString(getPath)
+	defer C.free(unsafe.Pointer(pathC))
+
+	optsC, errno := C.cgo_bpf_obj_get_opts_new(C.__u32(access))
+	if optsC == nil {
+		return -1, fmt.Errorf("failed to create bpf_obj_get_opts: %w", errno)
+	}
+	defer C.cgo_bpf_obj_get_opts_free(optsC)
+
+	fdC := C.bpf_obj_get_opts(pathC, optsC)
+	if fdC < 0 {
+		return -1, fmt.Errorf("failed to get pinned object %s: %w", path, syscall.Errno(-fdC))
+	}
+
+	return int(fdC), nil
+}
+
+//
+// PinnedModule
+//
+
+// PinnedModule holds the maps and programs adopted from a pinned object tree.
+type PinnedModule struct {
+	path  string
+	maps  map[string]*BPFMapLow
+	progs map[string]*PinnedProg
+}
+
+// PinnedProg is a loaded program adopted from a pinned object tree.
+type PinnedProg struct {
+	name    string
+	fd      int
+	pinPath string
+}
+
+// NewModuleFromPinnedDir adopts the maps and programs pinned into the given
+// directory by Module.PinObjects().
+func NewModuleFromPinnedDir(path string) (*PinnedModule, error) {
+	return newModuleFromPinnedDir(path, nil)
+}
+
+// NewModuleFromPinnedDirVerified is like NewModuleFromPinnedDir(), but every
+// pin is verified against the policy before it is adopted. A *PinPolicyError
+// is returned for the first refused pin.
+func NewModuleFromPinnedDirVerified(path string, policy PinPolicy) (*PinnedModule, error) {
+	return newModuleFromPinnedDir(path, &policy)
+}
+
+func newModuleFromPinnedDir(path string, policy *PinPolicy) (*PinnedModule, error) {
+	pm := &PinnedModule{
+		path:  path,
+		maps:  make(map[string]*BPFMapLow),
+		progs: make(map[string]*PinnedProg),
+	}
+
+	mapNames, progNames, err := pinnedTree(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range mapNames {
+		fd, err := GetPinnedObjectFD(filepath.Join(path, pinnedMapsDir, name), policy)
+		if err != nil {
+			pm.Close()
+			return nil, err
+		}
+
+		bpfMapLow, err := GetMapByFD(fd)
+		if err != nil {
+			_ = syscall.Close(fd)
+			pm.Close()
+			return nil, fmt.Errorf("failed to adopt pinned map %s: %w", name, err)
+		}
+		pm.maps[name] = bpfMapLow
+	}
+
+	for _, name := range progNames {
+		progPath := filepath.Join(path, pinnedProgsDir, name)
+		fd, err := GetPinnedObjectFD(progPath, policy)
+		if err != nil {
+			pm.Close()
+			return nil, err
+		}
+
+		pm.progs[name] = &PinnedProg{
+			name:    name,
+			fd:      fd,
+			pinPath: progPath,
+		}
+	}
+
+	return pm, nil
+}
+
+// pinnedTree returns the names of the maps and programs pinned into the tree
+// at path, which must have at least one of their subdirectories.
+func pinnedTree(path string) (mapNames, progNames []string, err error) {
+	mapNames, errMaps := pinnedNames(filepath.Join(path, pinnedMapsDir))
+	if errMaps != nil && !errors.Is(errMaps, os.ErrNotExist) {
+		return nil, nil, errMaps
+	}
+	progNames, errProgs := pinnedNames(filepath.Join(path, pinnedProgsDir))
+	if errProgs != nil && !errors.Is(errProgs, os.ErrNotExist) {
+		return nil, nil, errProgs
+	}
+	if errMaps != nil && errProgs != nil {
+		return nil, nil, fmt.Errorf("failed to adopt pinned objects from %s: no pinned objects found", path)
+	}
+
+	return mapNames, progNames, nil
+}
+
+func pinnedNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	return names, nil
+}
+
+// Path returns the directory the objects were adopted from.
+func (pm *PinnedModule) Path() string {
+	return pm.path
+}
+
+// GetMap returns the adopted map with the given name.
+func (pm *PinnedModule) GetMap(mapName string) (*BPFMapLow, error) {
+	bpfMapLow, ok := pm.maps[mapName]
+	if !ok {
+		return nil, fmt.Errorf("failed to find pinned BPF map %s", mapName)
+	}
+
+	return bpfMapLow, nil
+}
+
+// GetProgram returns the adopted program with the given name.
+func (pm *PinnedModule) GetProgram(progName string) (*PinnedProg, error) {
+	prog, ok := pm.progs[progName]
+	if !ok {
+		return nil, fmt.Errorf("failed to find pinned BPF program %s", progName)
+	}
+
+	return prog, nil
+}
+
+// Maps returns the names of the adopted maps.
+func (pm *PinnedModule) Maps() []string {
+	names := make([]string, 0, len(pm.maps))
+	for name := range pm.maps {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Programs returns the names of the adopted programs.
+func (pm *PinnedModule) Programs() []string {
+	names := make([]string, 0, len(pm.progs))
+	for name := range pm.progs {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Close closes the file descriptors of the adopted objects, which are then
+// dropped from the module: closing it again is a no-op. The pinned objects
+// themselves are kept.
+func (pm *PinnedModule) Close() {
+	for _, bpfMapLow := range pm.maps {
+		_ = syscall.Close(bpfMapLow.FileDescriptor())
+	}
+	for _, prog := range pm.progs {
+		_ = syscall.Close(prog.fd)
+	}
+	pm.maps = nil
+	pm.progs = nil
+}
+
+//
+// PinnedProg Specs
+//
+
+func (p *PinnedProg) Name() string {
+	return p.name
+}
+
+func (p *PinnedProg) FileDescriptor() int {
+	return p.fd
+}
+
+func (p *PinnedProg) PinPath() string {
+	return p.pinPath
+}
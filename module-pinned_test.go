@@ -0,0 +1,93 @@
+package libbpfgo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePin stands for bpf_obj_pin() outside of a BPF filesystem.
+func writePin(fd int, path string) error {
+	return os.WriteFile(path, nil, 0o600)
+}
+
+func TestPinObjectsTree(t *testing.T) {
+	dir := t.TempDir()
+	objs := []pinnedObject{
+		{fd: 3, subdir: pinnedMapsDir, name: "events"},
+		{fd: 4, subdir: pinnedMapsDir, name: "counts"},
+		{fd: 5, subdir: pinnedProgsDir, name: "handle_exec"},
+	}
+	require.NoError(t, pinObjects(dir, objs, writePin))
+
+	// A directory in the tree is not an object
+	require.NoError(t, os.Mkdir(filepath.Join(dir, pinnedProgsDir, "subdir"), 0o700))
+
+	mapNames, progNames, err := pinnedTree(dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"events", "counts"}, mapNames)
+	assert.Equal(t, []string{"handle_exec"}, progNames)
+}
+
+func TestPinObjectsRollback(t *testing.T) {
+	dir := t.TempDir()
+	errPin := errors.New("pin failed")
+	objs := []pinnedObject{
+		{fd: 3, subdir: pinnedMapsDir, name: "events"},
+		{fd: 4, subdir: pinnedProgsDir, name: "handle_exec"},
+	}
+
+	err := pinObjects(dir, objs, func(fd int, path string) error {
+		if fd == 4 {
+			return errPin
+		}
+		return writePin(fd, path)
+	})
+	assert.ErrorIs(t, err, errPin)
+
+	// The pinned map was unpinned
+	assert.NoFileExists(t, filepath.Join(dir, pinnedMapsDir, "events"))
+}
+
+func TestPinnedTree(t *testing.T) {
+	// Maps only
+	dir := t.TempDir()
+	require.NoError(t, pinObjects(dir, []pinnedObject{{subdir: pinnedMapsDir, name: "events"}}, writePin))
+	mapNames, progNames, err := pinnedTree(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"events"}, mapNames)
+	assert.Empty(t, progNames)
+
+	// Nothing pinned
+	_, _, err = pinnedTree(t.TempDir())
+	assert.ErrorContains(t, err, "no pinned objects found")
+
+	// Not a tree
+	dir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, pinnedMapsDir), nil, 0o600))
+	_, _, err = pinnedTree(dir)
+	assert.ErrorIs(t, err, syscall.ENOTDIR)
+}
+
+func TestPinnedModuleCloseTwice(t *testing.T) {
+	fd, err := syscall.Open(os.DevNull, syscall.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	pm := &PinnedModule{progs: map[string]*PinnedProg{"handle_exec": {name: "handle_exec", fd: fd}}}
+	pm.Close()
+	assert.Empty(t, pm.Programs())
+
+	// The descriptor number is reused, a second Close() leaves it open
+	reused, err := syscall.Open(os.DevNull, syscall.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer syscall.Close(reused)
+	pm.Close()
+
+	var stat syscall.Stat_t
+	assert.NoError(t, syscall.Fstat(reused, &stat))
+}
@@ -12,6 +12,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"unsafe"
 )
@@ -21,12 +26,27 @@ import (
 //
 
 type Module struct {
-	obj      *C.struct_bpf_object
-	links    []*BPFLink
-	perfBufs []*PerfBuffer
-	ringBufs []*RingBuffer
-	elf      *elf.File
-	loaded   bool
+	obj               *C.struct_bpf_object
+	links             []*BPFLink
+	perfBufs          []*PerfBuffer
+	ringBufs          []*RingBuffer
+	resizableRingBufs []*ResizableRingBuf
+	elf               *elf.File
+	loaded            bool
+	unloadedProgs     map[*C.struct_bpf_program]struct{}
+	untrackedProgs    map[*C.struct_bpf_program]struct{} // links not tracked
+	mapsC             []*C.struct_bpf_map
+	progsC            []*C.struct_bpf_program
+	kernelLogBuf      *C.char
+	eventHandler      ModuleEventHandler
+	// verifier statistics of the programs, by name, if recorded
+	recordVerifierStats bool
+	verifierStats       map[string]*VerifierStats
+	userData            map[unsafe.Pointer]any
+	license             string
+	userDataMu          sync.Mutex
+	objPath             string
+	objFile             *objectFile // file of a buffer, if any
 }
 
 //
@@ -41,6 +61,27 @@ type NewModuleArgs struct {
 	BPFObjBuff      []byte
 	SkipMemlockBump bool
 	KernelLogLevel  uint32
+	// KernelLogSize is the size of a buffer capturing the kernel log (the
+	// verifier and BTF load logs) of the object load, read with
+	// Module.KernelLog(). No buffer is used if 0.
+	KernelLogSize uint32
+	// EventHandler receives the lifecycle events of the module, starting
+	// with ModuleEventObjectOpened. See Module.SetEventHandler().
+	EventHandler ModuleEventHandler
+	// VerifierStats records the verifier statistics of the programs when
+	// the object is loaded, returned by BPFProg.Info().
+	VerifierStats bool
+	// License overrides the license of the object, written in its license
+	// section, which must be large enough. See KernelLicense().
+	License string
+	// BufferFile opens BPFObjBuff from a memfd holding it, or from a
+	// temporary file in BufferDir if set, instead of from memory. See
+	// Module.ObjectPath().
+	BufferFile bool
+	BufferDir  string
+	// UniqueObjName suffixes the object name with a number unique to the
+	// process ("name-2").
+	UniqueObjName bool
 }
 
 func NewModuleFromFile(bpfObjPath string) (*Module, error) {
@@ -50,6 +91,23 @@ func NewModuleFromFile(bpfObjPath string) (*Module, error) {
 }
 
 func NewModuleFromFileArgs(args NewModuleArgs) (*Module, error) {
+	if args.License != "" {
+		// The license is set in a copy of the object
+		obj, err := os.ReadFile(args.BPFObjPath)
+		if err != nil {
+			return nil, err
+		}
+		args.BPFObjBuff = obj
+		if args.BPFObjName == "" {
+			args.BPFObjName = filepath.Base(args.BPFObjPath)
+		}
+		m, err := NewModuleFromBufferArgs(args)
+		if err == nil && m.objPath == "" {
+			m.objPath = args.BPFObjPath
+		}
+		return m, err
+	}
+
 	f, err := elf.Open(args.BPFObjPath)
 	if err != nil {
 		return nil, err
@@ -78,10 +136,29 @@ func NewModuleFromFileArgs(args NewModuleArgs) (*Module, error) {
 		defer C.free(unsafe.Pointer(kconfigPathC))
 	}
 
+	// libbpf names the object after the base name of its path by default
+	var bpfObjNameC *C.char
+	if args.UniqueObjName {
+		if args.BPFObjName == "" {
+			args.BPFObjName, _, _ = strings.Cut(filepath.Base(args.BPFObjPath), ".")
+		}
+		args.BPFObjName = uniqueObjName(args.BPFObjName)
+	}
+	if args.BPFObjName != "" {
+		bpfObjNameC = C.CString(args.BPFObjName)
+		defer C.free(unsafe.Pointer(bpfObjNameC))
+	}
+
 	kernelLogLevelC := C.uint(args.KernelLogLevel)
 
-	optsC, errno := C.cgo_bpf_object_open_opts_new(btfFilePathC, kconfigPathC, nil, kernelLogLevelC)
+	kernelLogBufC, err := newKernelLogBuf(args.KernelLogSize)
+	if err != nil {
+		return nil, err
+	}
+
+	optsC, errno := C.cgo_bpf_object_open_opts_new(btfFilePathC, kconfigPathC, bpfObjNameC, kernelLogLevelC, kernelLogBufC, C.size_t(args.KernelLogSize))
 	if optsC == nil {
+		C.free(unsafe.Pointer(kernelLogBufC))
 		return nil, fmt.Errorf("failed to create bpf_object_open_opts: %w", errno)
 	}
 	defer C.cgo_bpf_object_open_opts_free(optsC)
@@ -91,13 +168,22 @@ func NewModuleFromFileArgs(args NewModuleArgs) (*Module, error) {
 
 	objC, errno := C.bpf_object__open_file(bpfFileC, optsC)
 	if objC == nil {
+		C.free(unsafe.Pointer(kernelLogBufC))
 		return nil, fmt.Errorf("failed to open BPF object at path %s: %w", args.BPFObjPath, errno)
 	}
 
-	return &Module{
-		obj: objC,
-		elf: f,
-	}, nil
+	m := &Module{
+		obj:                 objC,
+		elf:                 f,
+		kernelLogBuf:        kernelLogBufC,
+		eventHandler:        args.EventHandler,
+		recordVerifierStats: args.VerifierStats,
+		license:             objectLicense(f),
+		objPath:             args.BPFObjPath,
+	}
+	m.emit(ModuleEvent{Type: ModuleEventObjectOpened})
+
+	return m, nil
 }
 
 func NewModuleFromBuffer(bpfObjBuff []byte, bpfObjName string) (*Module, error) {
@@ -108,6 +194,14 @@ func NewModuleFromBuffer(bpfObjBuff []byte, bpfObjName string) (*Module, error)
 }
 
 func NewModuleFromBufferArgs(args NewModuleArgs) (*Module, error) {
+	if args.License != "" {
+		obj, err := setObjectLicense(args.BPFObjBuff, args.License)
+		if err != nil {
+			return nil, err
+		}
+		args.BPFObjBuff = obj
+	}
+
 	f, err := elf.NewFile(bytes.NewReader(args.BPFObjBuff))
 	if err != nil {
 		return nil, err
@@ -134,6 +228,10 @@ func NewModuleFromBufferArgs(args NewModuleArgs) (*Module, error) {
 		defer C.free(unsafe.Pointer(kConfigPathC))
 	}
 
+	if args.UniqueObjName {
+		args.BPFObjName = uniqueObjName(args.BPFObjName)
+	}
+
 	bpfObjNameC := C.CString(args.BPFObjName)
 	defer C.free(unsafe.Pointer(bpfObjNameC))
 	bpfBuffC := unsafe.Pointer(C.CBytes(args.BPFObjBuff))
@@ -145,21 +243,74 @@ func NewModuleFromBufferArgs(args NewModuleArgs) (*Module, error) {
 		kConfigPathC = nil
 	}
 
-	optsC, errno := C.cgo_bpf_object_open_opts_new(btfFilePathC, kConfigPathC, bpfObjNameC, kernelLogLevelC)
+	kernelLogBufC, err := newKernelLogBuf(args.KernelLogSize)
+	if err != nil {
+		return nil, err
+	}
+
+	optsC, errno := C.cgo_bpf_object_open_opts_new(btfFilePathC, kConfigPathC, bpfObjNameC, kernelLogLevelC, kernelLogBufC, C.size_t(args.KernelLogSize))
 	if optsC == nil {
+		C.free(unsafe.Pointer(kernelLogBufC))
 		return nil, fmt.Errorf("failed to create bpf_object_open_opts: %w", errno)
 	}
 	defer C.cgo_bpf_object_open_opts_free(optsC)
 
-	objC, errno := C.bpf_object__open_mem(bpfBuffC, bpfBuffSizeC, optsC)
+	var objC *C.struct_bpf_object
+	var objFile *objectFile
+	if args.BufferFile {
+		objFile, err = newObjectFile(args.BPFObjName, args.BufferDir, args.BPFObjBuff)
+		if err != nil {
+			C.free(unsafe.Pointer(kernelLogBufC))
+			return nil, err
+		}
+		objPathC := C.CString(objFile.path)
+		defer C.free(unsafe.Pointer(objPathC))
+		objC, errno = C.bpf_object__open_file(objPathC, optsC)
+	} else {
+		objC, errno = C.bpf_object__open_mem(bpfBuffC, bpfBuffSizeC, optsC)
+	}
 	if objC == nil {
+		C.free(unsafe.Pointer(kernelLogBufC))
+		if objFile != nil {
+			objFile.close()
+		}
 		return nil, fmt.Errorf("failed to open BPF object %s: %w", args.BPFObjName, errno)
 	}
 
-	return &Module{
-		obj: objC,
-		elf: f,
-	}, nil
+	m := &Module{
+		obj:                 objC,
+		elf:                 f,
+		kernelLogBuf:        kernelLogBufC,
+		eventHandler:        args.EventHandler,
+		recordVerifierStats: args.VerifierStats,
+		license:             objectLicense(f),
+		objFile:             objFile,
+	}
+	if objFile != nil {
+		m.objPath = objFile.path
+	}
+	m.emit(ModuleEvent{Type: ModuleEventObjectOpened})
+
+	return m, nil
+}
+
+// newKernelLogBuf allocates the buffer capturing the kernel log of the object
+// load, if size is not 0.
+func newKernelLogBuf(size uint32) (*C.char, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	// The verifier refuses smaller logs
+	if size < 128 {
+		return nil, fmt.Errorf("kernel log size %d too small, must be at least 128: %w", size, syscall.EINVAL)
+	}
+
+	bufC := (*C.char)(C.calloc(C.size_t(size), 1))
+	if bufC == nil {
+		return nil, fmt.Errorf("failed to allocate kernel log buffer: %w", syscall.ENOMEM)
+	}
+
+	return bufC, nil
 }
 
 // NOTE: libbpf has started raising limits by default but, unfortunately, that
@@ -187,25 +338,119 @@ func (m *Module) Close() {
 	for _, rb := range m.ringBufs {
 		rb.Close()
 	}
-	for _, link := range m.links {
-		if link.link != nil {
-			link.Destroy()
-		}
+	for _, rrb := range m.resizableRingBufs {
+		rrb.Close()
 	}
+	for _, link := range slices.Clone(m.links) {
+		link.Destroy()
+	}
+	m.emit(ModuleEvent{Type: ModuleEventObjectClosed})
+	m.clearUserData()
 	C.bpf_object__close(m.obj)
+	// The links released by the caller outlive the module, their destruction
+	// must neither report events nor read the freed object
+	m.obj = nil
+	m.eventHandler = nil
+	C.free(unsafe.Pointer(m.kernelLogBuf))
+	m.kernelLogBuf = nil
+	if m.objFile != nil {
+		m.objFile.close()
+	}
 }
 
+// BPFLoadObject creates the maps and loads the programs of the object. With
+// an event handler, the maps and programs are reported as the load
+// progresses (see ModuleEvent.Elapsed).
 func (m *Module) BPFLoadObject() error {
+	progress := m.startLoadProgress()
+	capture := startLogCapture(m.logSubjects())
 	retC := C.bpf_object__load(m.obj)
+	log := capture.stop()
+	progress.stop()
 	if retC < 0 {
-		return fmt.Errorf("failed to load BPF object: %w", syscall.Errno(-retC))
+		err := classifyError(opLoad, syscall.Errno(-retC), log)
+		if errors.Is(err, ErrGPLRequired) {
+			return fmt.Errorf("failed to load BPF object: license %q is not GPL-compatible: %w", m.license, err)
+		}
+		return fmt.Errorf("failed to load BPF object: %w", err)
 	}
 	m.loaded = true
 	m.elf.Close()
+	m.emitLoaded(progress)
+	if m.recordVerifierStats {
+		m.verifierStats = progress.stats
+	}
 
 	return nil
 }
 
+// logSubjects returns the names of the programs and maps of the object, which
+// the libbpf output about them starts with.
+func (m *Module) logSubjects() map[string]bool {
+	names := make(map[string]bool)
+	for mapC := C.bpf_object__next_map(m.obj, nil); mapC != nil; mapC = C.bpf_object__next_map(m.obj, mapC) {
+		names[C.GoString(C.bpf_map__name(mapC))] = true
+	}
+	for progC := C.bpf_object__next_program(m.obj, nil); progC != nil; progC = C.bpf_object__next_program(m.obj, progC) {
+		names[C.GoString(C.bpf_program__name(progC))] = true
+	}
+
+	return names
+}
+
+// KernelLog returns the kernel log of the last program or BTF load of the
+// object, if the module was created with a NewModuleArgs.KernelLogSize. With
+// the default KernelLogLevel (0), libbpf only fills it when a load fails,
+// making it the log of the failure.
+func (m *Module) KernelLog() string {
+	if m.kernelLogBuf == nil {
+		return ""
+	}
+
+	return C.GoString(m.kernelLogBuf)
+}
+
+// BPFLoadObjects loads the given modules concurrently, each one in its own
+// goroutine, and waits for all of them to finish.
+//
+// libbpf does not allow programs belonging to the same BPF object to be loaded
+// (and verified) in parallel: bpf_object__load() relocates and loads all of
+// them sequentially. Distinct BPF objects, however, are independent, so
+// callers with many programs can split them across modules (e.g. opening the
+// same object several times and using SetAutoload() to select a subset per
+// module) and load them through this function.
+//
+// NOTE: Each module creates its own maps, an object opened several times
+// has its maps duplicated. Maps shared by the programs of the modules must be
+// pinned (see BPFMap.SetPinPath()) or reused (see BPFMap.ReuseFD()) before
+// the load.
+//
+// Modules with programs or maps of the same names, as the same object opened
+// several times, are loaded one at a time: the libbpf output is told apart
+// by these names only, to classify the errors of each module.
+//
+// It returns the errors of all modules that failed to load, joined.
+func BPFLoadObjects(modules ...*Module) error {
+	return loadObjects(modules)
+}
+
+// loadObjects loads the objects concurrently and returns their errors joined.
+func loadObjects[T interface{ BPFLoadObject() error }](objs []T) error {
+	errs := make([]error, len(objs))
+
+	var wg sync.WaitGroup
+	for i, obj := range objs {
+		wg.Add(1)
+		go func(i int, obj T) {
+			defer wg.Done()
+			errs[i] = obj.BPFLoadObject()
+		}(i, obj)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
 // InitGlobalVariable sets global variables (defined in .data or .rodata)
 // in bpf code. It must be called before the BPF object is loaded.
 func (m *Module) InitGlobalVariable(name string, value interface{}) error {
@@ -247,6 +492,59 @@ func (m *Module) InitGlobalVariable(name string, value interface{}) error {
 	return err
 }
 
+// DisableUnreferencedMaps disables the automatic creation of the maps which
+// are not referenced by any of the programs set to be loaded. It must be
+// called before the BPF object is loaded and after the programs autoload has
+// been set (see BPFProg.SetAutoload()).
+//
+// The references are taken from the BPF object relocations. Only maps declared
+// in the .maps section are considered. Maps referenced by subprograms (.text)
+// are kept as long as at least one program is loaded, and maps referenced by
+// other maps (e.g. map-in-map initialization) are always kept.
+//
+// NOTE: Maps only accessed from userspace are not referenced by any program,
+// so they have to be enabled again with BPFMap.SetAutocreate() if needed.
+//
+// It returns the names of the maps that were disabled.
+func (m *Module) DisableUnreferencedMaps() ([]string, error) {
+	if m.loaded {
+		return nil, errors.New("must be called before the BPF object is loaded")
+	}
+
+	refs, err := getMapReferences(m.elf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get map references: %w", err)
+	}
+
+	var loadedProgs []string
+	iter := m.Iterator()
+	for prog := iter.NextProgram(); prog != nil; prog = iter.NextProgram() {
+		if prog.Autoload() {
+			loadedProgs = append(loadedProgs, prog.Name())
+		}
+	}
+
+	maps := make(map[string]*BPFMap)
+	var mapNames []string
+	iter = m.Iterator()
+	for bpfMap := iter.NextMap(); bpfMap != nil; bpfMap = iter.NextMap() {
+		if bpfMap.Autocreate() {
+			maps[bpfMap.Name()] = bpfMap
+			mapNames = append(mapNames, bpfMap.Name())
+		}
+	}
+
+	var disabled []string
+	for _, name := range refs.unreferenced(mapNames, loadedProgs) {
+		if err := maps[name].SetAutocreate(false); err != nil {
+			return disabled, err
+		}
+		disabled = append(disabled, name)
+	}
+
+	return disabled, nil
+}
+
 func (m *Module) GetMap(mapName string) (*BPFMap, error) {
 	mapNameC := C.CString(mapName)
 	defer C.free(unsafe.Pointer(mapNameC))
@@ -256,6 +554,12 @@ func (m *Module) GetMap(mapName string) (*BPFMap, error) {
 		return nil, fmt.Errorf("failed to find BPF map %s: %w", mapName, errno)
 	}
 
+	return m.newBPFMap(bpfMapC), nil
+}
+
+// newBPFMap returns the map, with its low level counterpart when the object
+// is loaded.
+func (m *Module) newBPFMap(bpfMapC *C.struct_bpf_map) *BPFMap {
 	bpfMap := &BPFMap{
 		bpfMap: bpfMapC,
 		module: m,
@@ -267,7 +571,7 @@ func (m *Module) GetMap(mapName string) (*BPFMap, error) {
 			info: &BPFMapInfo{},
 		}
 
-		return bpfMap, nil
+		return bpfMap
 	}
 
 	fd := bpfMap.FileDescriptor()
@@ -300,7 +604,7 @@ func (m *Module) GetMap(mapName string) (*BPFMap, error) {
 			},
 		}
 
-		return bpfMap, nil
+		return bpfMap
 	}
 
 	bpfMap.bpfMapLow = &BPFMapLow{
@@ -308,7 +612,7 @@ func (m *Module) GetMap(mapName string) (*BPFMap, error) {
 		info: info,
 	}
 
-	return bpfMap, nil
+	return bpfMap
 }
 
 func (m *Module) GetProgram(progName string) (*BPFProg, error) {
@@ -336,41 +640,91 @@ func (m *Module) InitRingBuf(mapName string, eventsChan chan []byte) (*RingBuffe
 		return nil, fmt.Errorf("events channel can not be nil")
 	}
 
-	slot := eventChannels.put(eventsChan)
-	if slot == -1 {
-		return nil, fmt.Errorf("max ring buffers reached")
+	ringBuf := &RingBuffer{
+		bpfMap:     bpfMap,
+		eventsChan: eventsChan,
+		done:       make(chan struct{}),
 	}
 
-	rbC, errno := C.cgo_init_ring_buf(C.int(bpfMap.FileDescriptor()), C.uintptr_t(slot))
-	if rbC == nil {
-		return nil, fmt.Errorf("failed to initialize ring buffer: %w", errno)
+	if err := initRingBuf(bpfMap.FileDescriptor(), ringBuf); err != nil {
+		return nil, err
 	}
 
-	ringBuf := &RingBuffer{
-		rb:     rbC,
-		bpfMap: bpfMap,
-		slot:   uint(slot),
-	}
 	m.ringBufs = append(m.ringBufs, ringBuf)
 	return ringBuf, nil
 }
 
-func (m *Module) InitPerfBuf(mapName string, eventsChan chan []byte, lostChan chan uint64, pageCnt int) (*PerfBuffer, error) {
-	bpfMap, err := m.GetMap(mapName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to init perf buffer: %v", err)
+// initRingBuf sets up the libbpf ring buffer of the ring buffer map.
+func initRingBuf(mapFD int, ringBuf *RingBuffer) error {
+	slot := eventChannels.put(ringBuf)
+	if slot == -1 {
+		return fmt.Errorf("max ring buffers reached")
+	}
+
+	rbC, errno := C.cgo_init_ring_buf(C.int(mapFD), C.uintptr_t(slot))
+	if rbC == nil {
+		eventChannels.remove(uint(slot))
+		return fmt.Errorf("failed to initialize ring buffer: %w", errno)
 	}
 
+	ringBuf.rb = rbC
+	ringBuf.slot = uint(slot)
+
+	return nil
+}
+
+// InitPerfBuf initializes a perf buffer sending the samples to eventsChan,
+// and the lost samples counts to lostChan if not nil. pageCnt is the number
+// of data pages of each per CPU buffer, and must be a power of two.
+func (m *Module) InitPerfBuf(mapName string, eventsChan chan []byte, lostChan chan uint64, pageCnt int) (*PerfBuffer, error) {
 	if eventsChan == nil {
 		return nil, fmt.Errorf("failed to init perf buffer: events channel can not be nil")
 	}
 
-	perfBuf := &PerfBuffer{
-		bpfMap:     bpfMap,
+	return m.initPerfBuf(mapName, &PerfBuffer{
 		eventsChan: eventsChan,
 		lostChan:   lostChan,
+		done:       make(chan struct{}),
+	}, pageCnt)
+}
+
+// InitPerfBufCallback initializes a perf buffer which, instead of sending the
+// samples to channels, calls sampleFn (and lostFn, if not nil) directly from
+// the poll goroutine.
+//
+// This avoids the channel contention and the per-sample allocation, which is
+// useful when the samples are just aggregated. The data slice points to the
+// perf buffer memory and is valid only until the callback returns, so it must
+// be copied if retained. The callbacks must not block, otherwise the perf
+// buffer polling is blocked too.
+func (m *Module) InitPerfBufCallback(
+	mapName string,
+	sampleFn func(cpu int, data []byte),
+	lostFn func(cpu int, cnt uint64),
+	pageCnt int,
+) (*PerfBuffer, error) {
+	if sampleFn == nil {
+		return nil, fmt.Errorf("failed to init perf buffer: sample callback can not be nil")
+	}
+
+	return m.initPerfBuf(mapName, &PerfBuffer{
+		sampleFn: sampleFn,
+		lostFn:   lostFn,
+		done:     make(chan struct{}),
+	}, pageCnt)
+}
+
+func (m *Module) initPerfBuf(mapName string, perfBuf *PerfBuffer, pageCnt int) (*PerfBuffer, error) {
+	if err := validatePerfBufPageCnt(pageCnt); err != nil {
+		return nil, fmt.Errorf("failed to init perf buffer: %w", err)
 	}
 
+	bpfMap, err := m.GetMap(mapName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init perf buffer: %v", err)
+	}
+	perfBuf.bpfMap = bpfMap
+
 	slot := eventChannels.put(perfBuf)
 	if slot == -1 {
 		return nil, fmt.Errorf("max number of ring/perf buffers reached")
@@ -384,6 +738,7 @@ func (m *Module) InitPerfBuf(mapName string, eventsChan chan []byte, lostChan ch
 
 	perfBuf.pb = pbC
 	perfBuf.slot = uint(slot)
+	perfBuf.pageCnt = pageCnt
 
 	m.perfBufs = append(m.perfBufs, perfBuf)
 	return perfBuf, nil
@@ -405,7 +760,7 @@ func (m *Module) Iterator() *BPFObjectIterator {
 
 func (m *Module) linkExist(prog *BPFProg) bool {
 	for _, link := range m.links {
-		if link.prog.Name() == prog.Name() {
+		if link.prog != nil && link.prog.Name() == prog.Name() {
 			return true
 		}
 	}
@@ -422,7 +777,7 @@ func (m *Module) AttachPrograms() error {
 			break
 		}
 
-		if !prog.Autoload() || !prog.Autoattach() {
+		if !prog.Autoload() || !prog.Autoattach() || prog.IsUnloaded() {
 			continue
 		}
 		// if link already exist (is attached), skip it
@@ -430,12 +785,9 @@ func (m *Module) AttachPrograms() error {
 			continue
 		}
 
-		link, err := prog.AttachGeneric()
-		if err != nil {
+		if _, err := prog.AttachGeneric(); err != nil {
 			return err
 		}
-
-		m.links = append(m.links, link)
 	}
 
 	return nil
@@ -445,13 +797,12 @@ func (m *Module) AttachPrograms() error {
 func (m *Module) DetachPrograms() error {
 	errInfo := make(map[string]error)
 
-	for _, link := range m.links {
+	for _, link := range slices.Clone(m.links) {
 		err := link.Destroy()
 		if err != nil {
-			errInfo[link.prog.Name()] = err
+			errInfo[link.ownerName()] = err
 		}
 	}
-	m.links = nil
 
 	if len(errInfo) > 0 {
 		var str string
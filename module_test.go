@@ -0,0 +1,50 @@
+package libbpfgo
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeObject struct {
+	err     error
+	loaded  bool
+	started *sync.WaitGroup
+}
+
+func (o *fakeObject) BPFLoadObject() error {
+	// Loaded concurrently: each load waits for the others to start
+	o.started.Done()
+	o.started.Wait()
+	o.loaded = true
+
+	return o.err
+}
+
+func TestLoadObjects(t *testing.T) {
+	errA := errors.New("failed to load BPF object: a")
+	errC := syscall.EPERM
+
+	var started sync.WaitGroup
+	started.Add(3)
+	objs := []*fakeObject{
+		{err: errA, started: &started},
+		{started: &started},
+		{err: errC, started: &started},
+	}
+
+	err := loadObjects(objs)
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errC)
+	assert.Equal(t, errA.Error()+"\n"+errC.Error(), err.Error())
+	for _, obj := range objs {
+		assert.True(t, obj.loaded)
+	}
+
+	started.Add(1)
+	assert.NoError(t, loadObjects(objs[1:2]))
+	assert.NoError(t, loadObjects([]*fakeObject{}))
+}
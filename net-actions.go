@@ -0,0 +1,102 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+//
+// Network program return codes
+//
+// XDP and TC programs return the action to take on the packet, which tests
+// running them with BPFProg.Run() read from RunOpts.RetVal:
+//
+//	prog.Run(&opts)
+//	if opts.XDPAction() != XDPDrop {
+//	    t.Errorf("action = %s; want %s", opts.XDPAction(), XDPDrop)
+//	}
+//
+
+// XDPAction is the return code of an XDP program, as defined by enum
+// xdp_action.
+type XDPAction uint32
+
+const (
+	XDPAborted  XDPAction = C.XDP_ABORTED
+	XDPDrop     XDPAction = C.XDP_DROP
+	XDPPass     XDPAction = C.XDP_PASS
+	XDPTx       XDPAction = C.XDP_TX
+	XDPRedirect XDPAction = C.XDP_REDIRECT
+)
+
+var xdpActionToString = map[XDPAction]string{
+	XDPAborted:  "XDP_ABORTED",
+	XDPDrop:     "XDP_DROP",
+	XDPPass:     "XDP_PASS",
+	XDPTx:       "XDP_TX",
+	XDPRedirect: "XDP_REDIRECT",
+}
+
+func (a XDPAction) String() string {
+	str, ok := xdpActionToString[a]
+	if !ok {
+		return fmt.Sprintf("XDPAction(%d)", uint32(a))
+	}
+
+	return str
+}
+
+// TCAction is the return code of a TC (sched_cls, sched_act, tcx, netkit)
+// program, as defined by the TC_ACT_* values.
+type TCAction int32
+
+const (
+	TCActUnspec     TCAction = C.TC_ACT_UNSPEC // the default action of the hook
+	TCActOK         TCAction = C.TC_ACT_OK
+	TCActReclassify TCAction = C.TC_ACT_RECLASSIFY
+	TCActShot       TCAction = C.TC_ACT_SHOT
+	TCActPipe       TCAction = C.TC_ACT_PIPE
+	TCActStolen     TCAction = C.TC_ACT_STOLEN
+	TCActQueued     TCAction = C.TC_ACT_QUEUED
+	TCActRepeat     TCAction = C.TC_ACT_REPEAT
+	TCActRedirect   TCAction = C.TC_ACT_REDIRECT
+	TCActTrap       TCAction = C.TC_ACT_TRAP
+)
+
+var tcActionToString = map[TCAction]string{
+	TCActUnspec:     "TC_ACT_UNSPEC",
+	TCActOK:         "TC_ACT_OK",
+	TCActReclassify: "TC_ACT_RECLASSIFY",
+	TCActShot:       "TC_ACT_SHOT",
+	TCActPipe:       "TC_ACT_PIPE",
+	TCActStolen:     "TC_ACT_STOLEN",
+	TCActQueued:     "TC_ACT_QUEUED",
+	TCActRepeat:     "TC_ACT_REPEAT",
+	TCActRedirect:   "TC_ACT_REDIRECT",
+	TCActTrap:       "TC_ACT_TRAP",
+}
+
+func (a TCAction) String() string {
+	str, ok := tcActionToString[a]
+	if !ok {
+		return fmt.Sprintf("TCAction(%d)", int32(a))
+	}
+
+	return str
+}
+
+// XDPAction returns the return code of an XDP program run.
+func (o *RunOpts) XDPAction() XDPAction {
+	return XDPAction(o.RetVal)
+}
+
+// TCAction returns the return code of a TC program run. TC_ACT_UNSPEC (-1)
+// is read back from the unsigned RetVal.
+func (o *RunOpts) TCAction() TCAction {
+	return TCAction(int32(o.RetVal))
+}
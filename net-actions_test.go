@@ -0,0 +1,30 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXDPAction(t *testing.T) {
+	opts := RunOpts{RetVal: 1}
+	assert.Equal(t, XDPDrop, opts.XDPAction())
+	assert.Equal(t, "XDP_DROP", opts.XDPAction().String())
+
+	opts.RetVal = 42
+	assert.Equal(t, "XDPAction(42)", opts.XDPAction().String())
+}
+
+func TestTCAction(t *testing.T) {
+	opts := RunOpts{RetVal: 2}
+	assert.Equal(t, TCActShot, opts.TCAction())
+	assert.Equal(t, "TC_ACT_SHOT", opts.TCAction().String())
+
+	// TC_ACT_UNSPEC is -1
+	opts.RetVal = 0xffffffff
+	assert.Equal(t, TCActUnspec, opts.TCAction())
+	assert.Equal(t, "TC_ACT_UNSPEC", opts.TCAction().String())
+
+	opts.RetVal = 42
+	assert.Equal(t, "TCAction(42)", opts.TCAction().String())
+}
@@ -0,0 +1,95 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+//
+// Network namespace scoped operations
+//
+
+// RunInNetns runs fn with the calling goroutine in the network namespace at
+// netnsPath (e.g. /proc/<pid>/ns/net or /var/run/netns/<name>), restoring the
+// original namespace afterwards.
+//
+// Network interfaces and their indexes are namespace scoped, so attachments
+// to per-container interfaces (XDP, TC) must be done from within the
+// container namespace:
+//
+//	err := RunInNetns("/proc/1234/ns/net", func() error {
+//		_, err := prog.AttachXDP("eth0")
+//		return err
+//	})
+//
+// fn runs on a locked OS thread, and goroutines it starts do not run in the
+// target namespace. If the original namespace can not be restored, the OS
+// thread is left locked so the runtime discards it.
+func RunInNetns(netnsPath string, fn func() error) error {
+	runtime.LockOSThread()
+
+	origFD, err := syscall.Open("/proc/thread-self/ns/net", syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("failed to open current network namespace: %w", err)
+	}
+	defer syscall.Close(origFD)
+
+	targetFD, err := syscall.Open(netnsPath, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("failed to open network namespace %s: %w", netnsPath, err)
+	}
+	defer syscall.Close(targetFD)
+
+	if err := setns(targetFD, syscall.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("failed to enter network namespace %s: %w", netnsPath, err)
+	}
+
+	fnErr := fn()
+
+	if err := setns(origFD, syscall.CLONE_NEWNET); err != nil {
+		// Keep the thread locked: it is in the wrong namespace and must not
+		// be reused by other goroutines.
+		return errors.Join(fnErr, fmt.Errorf("failed to restore network namespace: %w", err))
+	}
+	runtime.UnlockOSThread()
+
+	return fnErr
+}
+
+func setns(fd int, nstype int) error {
+	retC, errno := C.cgo_setns(C.int(fd), C.int(nstype))
+	if retC < 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// AttachXDPInNetns attaches the program to the XDP hook of the device in the
+// network namespace at netnsPath (see RunInNetns()).
+func (p *BPFProg) AttachXDPInNetns(netnsPath string, deviceName string) (*BPFLink, error) {
+	var bpfLink *BPFLink
+
+	err := RunInNetns(netnsPath, func() error {
+		var err error
+		bpfLink, err = p.AttachXDP(deviceName)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bpfLink, nil
+}
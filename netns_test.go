@@ -0,0 +1,35 @@
+package libbpfgo
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInNetns(t *testing.T) {
+	current, err := os.Readlink("/proc/thread-self/ns/net")
+	require.NoError(t, err)
+
+	fnErr := errors.New("fn error")
+	err = RunInNetns("/proc/self/ns/net", func() error {
+		inside, err := os.Readlink("/proc/thread-self/ns/net")
+		require.NoError(t, err)
+		assert.Equal(t, current, inside)
+
+		return fnErr
+	})
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("entering a network namespace requires CAP_SYS_ADMIN")
+	}
+	assert.ErrorIs(t, err, fnErr)
+
+	err = RunInNetns("/nonexistent", func() error {
+		t.Fatal("fn should not run")
+		return nil
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//
+// Pin policy
+//
+// Pinned objects are shared through the BPF filesystem, so a process adopting
+// them trusts whoever could create or replace the pins. A PinPolicy verifies
+// ownership and permissions of a pin before it is opened. The pin is opened
+// with O_PATH first and verified through that descriptor, so it can not be
+// swapped between verification and use.
+//
+
+// oPath is O_PATH, missing from the syscall package.
+const oPath = 0x200000
+
+// PinPolicy describes the pins that may be opened.
+type PinPolicy struct {
+	// UIDs are the allowed owners, any owner if empty.
+	UIDs []uint32
+	// GIDs are the allowed groups, any group if empty.
+	GIDs []uint32
+	// ForbiddenPerm are permission bits the pin must not have. World
+	// writable pins (0o002) are always refused.
+	ForbiddenPerm os.FileMode
+}
+
+// PinViolation is the reason a pin was refused by a PinPolicy.
+type PinViolation uint32
+
+const (
+	PinViolationType          PinViolation = iota + 1 // not a regular file (e.g. symlink)
+	PinViolationOwner                                 // owner not allowed
+	PinViolationGroup                                 // group not allowed
+	PinViolationWorldWritable                         // pin world writable
+	PinViolationPerm                                  // forbidden permission bits set
+	PinViolationDir                                   // parent directory world writable without sticky bit
+)
+
+var pinViolationToString = map[PinViolation]string{
+	PinViolationType:          "not a regular file",
+	PinViolationOwner:         "owner not allowed",
+	PinViolationGroup:         "group not allowed",
+	PinViolationWorldWritable: "world writable",
+	PinViolationPerm:          "forbidden permissions",
+	PinViolationDir:           "parent directory world writable",
+}
+
+func (v PinViolation) String() string {
+	str, ok := pinViolationToString[v]
+	if !ok {
+		return "unknown violation " + strconv.Itoa(int(v))
+	}
+
+	return str
+}
+
+// PinPolicyError is returned when a pin does not satisfy a PinPolicy.
+type PinPolicyError struct {
+	Path      string
+	Violation PinViolation
+	UID       uint32
+	GID       uint32
+	Mode      os.FileMode // permission bits
+}
+
+func (e *PinPolicyError) Error() string {
+	return fmt.Sprintf("pin %s refused: %s (uid %d, gid %d, mode %#o)", e.Path, e.Violation, e.UID, e.GID, uint32(e.Mode))
+}
+
+// check verifies the stat of a pin against the policy.
+func (p *PinPolicy) check(path string, st *syscall.Stat_t) error {
+	perm := os.FileMode(st.Mode & 0o777)
+
+	refuse := func(violation PinViolation) error {
+		return &PinPolicyError{
+			Path:      path,
+			Violation: violation,
+			UID:       st.Uid,
+			GID:       st.Gid,
+			Mode:      perm,
+		}
+	}
+
+	switch {
+	case st.Mode&syscall.S_IFMT != syscall.S_IFREG:
+		return refuse(PinViolationType)
+	case len(p.UIDs) > 0 && !slices.Contains(p.UIDs, st.Uid):
+		return refuse(PinViolationOwner)
+	case len(p.GIDs) > 0 && !slices.Contains(p.GIDs, st.Gid):
+		return refuse(PinViolationGroup)
+	case perm&0o002 != 0:
+		return refuse(PinViolationWorldWritable)
+	case perm&p.ForbiddenPerm != 0:
+		return refuse(PinViolationPerm)
+	}
+
+	return nil
+}
+
+// checkDir verifies that the pin can not be replaced by anyone through its
+// parent directory.
+func (p *PinPolicy) checkDir(path string, st *syscall.Stat_t) error {
+	if st.Mode&0o002 != 0 && st.Mode&syscall.S_ISVTX == 0 {
+		return &PinPolicyError{
+			Path:      path,
+			Violation: PinViolationDir,
+			UID:       st.Uid,
+			GID:       st.Gid,
+			Mode:      os.FileMode(st.Mode & 0o777),
+		}
+	}
+
+	return nil
+}
+
+// GetPinnedObjectFD opens the object pinned at path after verifying the pin
+// against the policy, and returns its file descriptor. A nil policy skips
+// the verification.
+func GetPinnedObjectFD(path string, policy *PinPolicy) (int, error) {
+	return GetPinnedObjectFDAccess(path, policy, PinAccessReadWrite)
+}
+
+// GetPinnedObjectFDAccess is like GetPinnedObjectFD(), with the given access
+// mode. Monitoring processes only reading maps should use PinAccessReadOnly,
+// which needs only read permission on the pin.
+func GetPinnedObjectFDAccess(path string, policy *PinPolicy, access PinAccess) (int, error) {
+	if policy == nil {
+		return objGetAccess(path, path, access)
+	}
+
+	var dirSt syscall.Stat_t
+	if err := syscall.Stat(filepath.Dir(path), &dirSt); err != nil {
+		return -1, fmt.Errorf("failed to stat %s: %w", filepath.Dir(path), err)
+	}
+	if err := policy.checkDir(filepath.Dir(path), &dirSt); err != nil {
+		return -1, err
+	}
+
+	pathFD, err := syscall.Open(path, oPath|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open pin %s: %w", path, err)
+	}
+	defer syscall.Close(pathFD)
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(pathFD, &st); err != nil {
+		return -1, fmt.Errorf("failed to stat pin %s: %w", path, err)
+	}
+	if err := policy.check(path, &st); err != nil {
+		return -1, err
+	}
+
+	// Get the object through the verified descriptor
+	return objGetAccess(fmt.Sprintf("/proc/self/fd/%d", pathFD), path, access)
+}
+
+// GetMapByPinnedPath opens the map pinned at path after verifying the pin
+// against the policy (see GetPinnedObjectFD()).
+func GetMapByPinnedPath(path string, policy *PinPolicy) (*BPFMapLow, error) {
+	return GetMapByPinnedPathAccess(path, policy, PinAccessReadWrite)
+}
+
+// GetMapByPinnedPathAccess is like GetMapByPinnedPath(), with the given access
+// mode. The updates of a map opened with PinAccessReadOnly fail with EPERM.
+func GetMapByPinnedPathAccess(path string, policy *PinPolicy, access PinAccess) (*BPFMapLow, error) {
+	fd, err := GetPinnedObjectFDAccess(path, policy, access)
+	if err != nil {
+		return nil, err
+	}
+
+	bpfMapLow, err := GetMapByFD(fd)
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("failed to get pinned map %s: %w", path, err)
+	}
+
+	return bpfMapLow, nil
+}
+
+// DefaultPinRoot is the directory where libbpf pins the maps declared with
+// __uint(pinning, LIBBPF_PIN_BY_NAME), unless the object is opened with
+// another pin_root_path.
+const DefaultPinRoot = "/sys/fs/bpf"
+
+// LookupPinnedMap opens the map pinned by libbpf under the given name, for
+// maps declared with __uint(pinning, LIBBPF_PIN_BY_NAME), without opening
+// the BPF object declaring it. An empty pinRoot means DefaultPinRoot.
+func LookupPinnedMap(name, pinRoot string) (*BPFMapLow, error) {
+	path, err := pinByNamePath(name, pinRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetMapByPinnedPath(path, nil)
+}
+
+// pinByNamePath returns the path of a map pinned by name, as built by
+// libbpf: <pinRoot>/<name>.
+func pinByNamePath(name, pinRoot string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", fmt.Errorf("failed to lookup pinned map: invalid map name %q", name)
+	}
+	if pinRoot == "" {
+		pinRoot = DefaultPinRoot
+	}
+
+	return filepath.Join(pinRoot, name), nil
+}
@@ -0,0 +1,132 @@
+package libbpfgo
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinPolicyCheck(t *testing.T) {
+	policy := &PinPolicy{
+		UIDs:          []uint32{0},
+		GIDs:          []uint32{0, 1000},
+		ForbiddenPerm: 0o020,
+	}
+
+	testCases := []struct {
+		name      string
+		st        syscall.Stat_t
+		violation PinViolation
+	}{
+		{
+			name: "allowed",
+			st:   syscall.Stat_t{Mode: syscall.S_IFREG | 0o600, Uid: 0, Gid: 1000},
+		},
+		{
+			name:      "symlink",
+			st:        syscall.Stat_t{Mode: syscall.S_IFLNK | 0o777},
+			violation: PinViolationType,
+		},
+		{
+			name:      "owner",
+			st:        syscall.Stat_t{Mode: syscall.S_IFREG | 0o600, Uid: 1000},
+			violation: PinViolationOwner,
+		},
+		{
+			name:      "group",
+			st:        syscall.Stat_t{Mode: syscall.S_IFREG | 0o600, Gid: 5},
+			violation: PinViolationGroup,
+		},
+		{
+			name:      "world writable",
+			st:        syscall.Stat_t{Mode: syscall.S_IFREG | 0o602},
+			violation: PinViolationWorldWritable,
+		},
+		{
+			name:      "forbidden permissions",
+			st:        syscall.Stat_t{Mode: syscall.S_IFREG | 0o620},
+			violation: PinViolationPerm,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.check("/sys/fs/bpf/pin", &tc.st)
+			if tc.violation == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var policyErr *PinPolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, tc.violation, policyErr.Violation)
+			assert.Equal(t, "/sys/fs/bpf/pin", policyErr.Path)
+			assert.Equal(t, os.FileMode(tc.st.Mode&0o777), policyErr.Mode)
+		})
+	}
+}
+
+func TestPinPolicyCheckDir(t *testing.T) {
+	policy := &PinPolicy{}
+
+	assert.NoError(t, policy.checkDir("/sys/fs/bpf", &syscall.Stat_t{Mode: syscall.S_IFDIR | 0o700}))
+	assert.NoError(t, policy.checkDir("/sys/fs/bpf", &syscall.Stat_t{Mode: syscall.S_IFDIR | syscall.S_ISVTX | 0o777}))
+
+	var policyErr *PinPolicyError
+	err := policy.checkDir("/sys/fs/bpf", &syscall.Stat_t{Mode: syscall.S_IFDIR | 0o777})
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, PinViolationDir, policyErr.Violation)
+}
+
+func TestGetPinnedObjectFDRefused(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pin")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	require.NoError(t, os.Chmod(path, 0o666))
+
+	_, err := GetPinnedObjectFD(path, &PinPolicy{})
+
+	var policyErr *PinPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, PinViolationWorldWritable, policyErr.Violation)
+
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(path, link))
+
+	// The symlink itself is verified, not its target
+	_, err = GetPinnedObjectFD(link, &PinPolicy{})
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, PinViolationType, policyErr.Violation)
+}
+
+func TestPinByNamePath(t *testing.T) {
+	path, err := pinByNamePath("events", "")
+	require.NoError(t, err)
+	assert.Equal(t, "/sys/fs/bpf/events", path)
+
+	path, err = pinByNamePath("events", "/sys/fs/bpf/myapp")
+	require.NoError(t, err)
+	assert.Equal(t, "/sys/fs/bpf/myapp/events", path)
+
+	for _, name := range []string{"", ".", "..", "../events", "a/b"} {
+		_, err = pinByNamePath(name, "")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestGetPinnedObjectFDAccessInvalid(t *testing.T) {
+	_, err := GetPinnedObjectFDAccess("/sys/fs/bpf/events", nil, PinAccessReadOnly|PinAccessWriteOnly)
+	assert.ErrorContains(t, err, "invalid access mode")
+
+	// Through a descriptor of the pin verified against the policy
+	path := filepath.Join(t.TempDir(), "pin")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	_, err = GetPinnedObjectFDAccess(path, &PinPolicy{}, PinAccessReadOnly|PinAccessWriteOnly)
+	assert.EqualError(t, err, "failed to get pinned object "+path+": invalid access mode PinAccess(24)")
+
+	assert.Equal(t, "read-only", PinAccessReadOnly.String())
+}
@@ -0,0 +1,12 @@
+//go:build libbpfgo_embed
+
+package procmon
+
+import (
+	_ "embed"
+)
+
+// procmonObject is the build of procmon.bpf.c, with `go generate`.
+//
+//go:embed procmon.bpf.o
+var procmonObject []byte
@@ -0,0 +1,124 @@
+package procmon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// EventType is the type of a process Event.
+type EventType uint32
+
+const (
+	EventExec EventType = iota + 1
+	EventExit
+	EventFork
+)
+
+var eventTypeToString = map[EventType]string{
+	EventExec: "exec",
+	EventExit: "exit",
+	EventFork: "fork",
+}
+
+func (t EventType) String() string {
+	str, ok := eventTypeToString[t]
+	if !ok {
+		return fmt.Sprintf("EventType(%d)", uint32(t))
+	}
+
+	return str
+}
+
+// Event is a process lifecycle event. The process fields are those of the
+// process executing, exiting or forking.
+type Event struct {
+	Type EventType
+	// Time is the time since boot of the event, in CLOCK_MONOTONIC.
+	Time time.Duration
+	PID  int // process id
+	TID  int // id of the thread doing the exec or fork
+	PPID int
+	UID  uint32
+	Comm string // after the exec, for exec events
+	// Filename is the path of the executed file (EventExec).
+	Filename string
+	// ExitStatus is the wait status of the process (EventExit).
+	ExitStatus syscall.WaitStatus
+	// ChildPID is the pid of the new process (EventFork).
+	ChildPID int
+}
+
+func (e Event) String() string {
+	switch e.Type {
+	case EventExec:
+		return fmt.Sprintf("exec pid=%d ppid=%d uid=%d comm=%s filename=%s", e.PID, e.PPID, e.UID, e.Comm, e.Filename)
+	case EventExit:
+		return fmt.Sprintf("exit pid=%d ppid=%d uid=%d comm=%s status=%d", e.PID, e.PPID, e.UID, e.Comm, e.ExitStatus.ExitStatus())
+	case EventFork:
+		return fmt.Sprintf("fork pid=%d ppid=%d uid=%d comm=%s child=%d", e.PID, e.PPID, e.UID, e.Comm, e.ChildPID)
+	}
+
+	return fmt.Sprintf("%s pid=%d", e.Type, e.PID)
+}
+
+// eventRec is the event written by procmon.bpf.c, in native byte order, and
+// must match its C layout.
+type eventRec struct {
+	Type      uint32
+	Pid       uint32
+	Tid       uint32
+	Ppid      uint32
+	Uid       uint32
+	ExitCode  uint32
+	Timestamp uint64
+	Comm      [16]byte
+	Filename  [128]byte
+	ChildPid  uint32
+	_         uint32
+}
+
+// cString returns the NUL terminated string of a fixed size C array.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+// decodeEvent decodes an event of the ring buffer.
+func decodeEvent(data []byte) (Event, error) {
+	var r eventRec
+	if len(data) < binary.Size(r) {
+		return Event{}, fmt.Errorf("failed to decode process event: %d bytes, want %d", len(data), binary.Size(r))
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.NativeEndian, &r); err != nil {
+		return Event{}, fmt.Errorf("failed to decode process event: %w", err)
+	}
+
+	e := Event{
+		Type: EventType(r.Type),
+		Time: time.Duration(r.Timestamp),
+		PID:  int(r.Pid),
+		TID:  int(r.Tid),
+		PPID: int(r.Ppid),
+		UID:  r.Uid,
+		Comm: cString(r.Comm[:]),
+	}
+
+	switch e.Type {
+	case EventExec:
+		e.Filename = cString(r.Filename[:])
+	case EventExit:
+		e.ExitStatus = syscall.WaitStatus(r.ExitCode)
+	case EventFork:
+		e.ChildPID = int(r.ChildPid)
+	default:
+		return Event{}, fmt.Errorf("failed to decode process event: unknown type %d", r.Type)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,79 @@
+package procmon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeEvent(t *testing.T, r eventRec) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.NativeEndian, r))
+
+	return buf.Bytes()
+}
+
+func TestEventRecSize(t *testing.T) {
+	// Size of the C structure of procmon.bpf.c
+	assert.Equal(t, 184, binary.Size(eventRec{}))
+}
+
+func TestDecodeEvent(t *testing.T) {
+	var comm [16]byte
+	copy(comm[:], "bash")
+	var filename [128]byte
+	copy(filename[:], "/usr/bin/ls")
+
+	tests := []struct {
+		name string
+		rec  eventRec
+		want Event
+	}{
+		{
+			name: "exec",
+			rec:  eventRec{Type: uint32(EventExec), Pid: 100, Tid: 100, Ppid: 1, Uid: 1000, Timestamp: uint64(time.Second), Comm: comm, Filename: filename},
+			want: Event{Type: EventExec, Time: time.Second, PID: 100, TID: 100, PPID: 1, UID: 1000, Comm: "bash", Filename: "/usr/bin/ls"},
+		},
+		{
+			name: "exit",
+			rec:  eventRec{Type: uint32(EventExit), Pid: 100, Tid: 100, Ppid: 1, ExitCode: 2 << 8, Comm: comm, Filename: filename},
+			want: Event{Type: EventExit, PID: 100, TID: 100, PPID: 1, Comm: "bash", ExitStatus: syscall.WaitStatus(2 << 8)},
+		},
+		{
+			name: "fork",
+			rec:  eventRec{Type: uint32(EventFork), Pid: 100, Tid: 101, Ppid: 1, ChildPid: 102, Comm: comm},
+			want: Event{Type: EventFork, PID: 100, TID: 101, PPID: 1, Comm: "bash", ChildPID: 102},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := decodeEvent(encodeEvent(t, tt.rec))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e)
+		})
+	}
+
+	assert.Equal(t, 2, tests[1].want.ExitStatus.ExitStatus())
+}
+
+func TestDecodeEventInvalid(t *testing.T) {
+	_, err := decodeEvent(make([]byte, 10))
+	assert.Error(t, err)
+
+	_, err = decodeEvent(encodeEvent(t, eventRec{Type: 42}))
+	assert.Error(t, err)
+}
+
+func TestEventString(t *testing.T) {
+	e := Event{Type: EventFork, PID: 100, PPID: 1, Comm: "bash", ChildPID: 102}
+	assert.Equal(t, "fork pid=100 ppid=1 uid=0 comm=bash child=102", e.String())
+	assert.Equal(t, "EventType(42)", EventType(42).String())
+}
@@ -0,0 +1,7 @@
+//go:build !libbpfgo_embed
+
+package procmon
+
+// procmonObject is empty without the libbpfgo_embed build tag: Open() fails with
+// ErrNotEmbedded, OpenObject() loads a build of procmon.bpf.c given at run time.
+var procmonObject []byte
@@ -0,0 +1,14 @@
+//go:build !libbpfgo_embed
+
+package procmon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenNotEmbedded(t *testing.T) {
+	_, err := Open()
+	assert.ErrorIs(t, err, ErrNotEmbedded)
+}
@@ -0,0 +1,114 @@
+//+build ignore
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#define EVENT_EXEC 1
+#define EVENT_EXIT 2
+#define EVENT_FORK 3
+
+// The event is decoded by events.go, keep them in sync.
+struct event {
+    __u32 type;
+    __u32 pid;
+    __u32 tid;
+    __u32 ppid;
+    __u32 uid;
+    __u32 exit_code;
+    __u64 timestamp;
+    char comm[16];
+    char filename[128];
+    __u32 child_pid;
+    __u32 pad;
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, 256 * 1024);
+} events SEC(".maps");
+
+// Events lost because the ring buffer was full
+__u64 dropped = 0;
+
+static __always_inline struct event *reserve_event(struct task_struct *task, __u32 type)
+{
+    struct event *e;
+
+    e = bpf_ringbuf_reserve(&events, sizeof(*e), 0);
+    if (!e) {
+        __sync_fetch_and_add(&dropped, 1);
+        return NULL;
+    }
+
+    e->type = type;
+    e->pid = BPF_CORE_READ(task, tgid);
+    e->tid = BPF_CORE_READ(task, pid);
+    e->ppid = BPF_CORE_READ(task, real_parent, tgid);
+    e->uid = BPF_CORE_READ(task, cred, uid.val);
+    e->exit_code = 0;
+    e->timestamp = bpf_ktime_get_ns();
+    BPF_CORE_READ_STR_INTO(&e->comm, task, comm);
+    e->filename[0] = '\0';
+    e->child_pid = 0;
+    e->pad = 0;
+
+    return e;
+}
+
+SEC("tp_btf/sched_process_exec")
+int BPF_PROG(handle_exec, struct task_struct *task, pid_t old_pid, struct linux_binprm *bprm)
+{
+    struct event *e;
+
+    e = reserve_event(task, EVENT_EXEC);
+    if (!e)
+        return 0;
+
+    bpf_probe_read_kernel_str(&e->filename, sizeof(e->filename), BPF_CORE_READ(bprm, filename));
+    bpf_ringbuf_submit(e, 0);
+
+    return 0;
+}
+
+SEC("tp_btf/sched_process_exit")
+int BPF_PROG(handle_exit, struct task_struct *task)
+{
+    struct event *e;
+
+    // Only report the exit of whole processes, not of their threads
+    if (BPF_CORE_READ(task, pid) != BPF_CORE_READ(task, tgid))
+        return 0;
+
+    e = reserve_event(task, EVENT_EXIT);
+    if (!e)
+        return 0;
+
+    e->exit_code = BPF_CORE_READ(task, exit_code);
+    bpf_ringbuf_submit(e, 0);
+
+    return 0;
+}
+
+SEC("tp_btf/sched_process_fork")
+int BPF_PROG(handle_fork, struct task_struct *parent, struct task_struct *child)
+{
+    struct event *e;
+
+    // Only report new processes, not new threads
+    if (BPF_CORE_READ(child, pid) != BPF_CORE_READ(child, tgid))
+        return 0;
+
+    e = reserve_event(parent, EVENT_FORK);
+    if (!e)
+        return 0;
+
+    e->child_pid = BPF_CORE_READ(child, tgid);
+    bpf_ringbuf_submit(e, 0);
+
+    return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,214 @@
+// Package procmon is a process exec, exit and fork event source, built on a
+// canned BPF object (procmon.bpf.c) embedded in the package:
+//
+//	mon, err := procmon.Open(procmon.EventExec, procmon.EventExit)
+//	if err != nil {
+//	    return err
+//	}
+//	defer mon.Close()
+//
+//	mon.Start()
+//	for e := range mon.Events() {
+//	    fmt.Println(e)
+//	}
+//
+// It is also an example of the patterns recommended with libbpfgo: tp_btf
+// programs selected with SetAutoload() and attached with AttachPrograms(), a
+// ring buffer decoded into typed events, and a global counter of the events
+// dropped in the kernel read after load.
+//
+// The BPF object is built with the Makefile of this directory (or `go
+// generate`), and embedded with the libbpfgo_embed build tag:
+//
+//	go generate ./procmon && go build -tags libbpfgo_embed
+//
+// It needs a kernel with BTF and ring buffers (v5.8+), and
+// CAP_BPF and CAP_PERFMON (or CAP_SYS_ADMIN).
+package procmon
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+//go:generate make
+
+// ErrNotEmbedded is the error of Open() in builds without the libbpfgo_embed
+// tag, which do not embed the BPF object.
+var ErrNotEmbedded = errors.New("procmon: BPF object not embedded, build with the libbpfgo_embed tag")
+
+// eventsChanSize is the number of events buffered for a slow consumer.
+const eventsChanSize = 1024
+
+var eventPrograms = map[EventType]string{
+	EventExec: "handle_exec",
+	EventExit: "handle_exit",
+	EventFork: "handle_fork",
+}
+
+// Monitor reports the process events of the system.
+type Monitor struct {
+	m       *bpf.Module
+	rb      *bpf.RingBuffer
+	raw     chan []byte
+	events  chan Event
+	done    chan struct{}
+	wg      sync.WaitGroup
+	started bool
+	closed  bool
+	mu      sync.Mutex
+}
+
+// Open loads the embedded BPF object and attaches the programs of the given
+// event types, or of all of them if none is given. The events are reported
+// once the Monitor is started.
+func Open(types ...EventType) (*Monitor, error) {
+	if len(procmonObject) == 0 {
+		return nil, ErrNotEmbedded
+	}
+
+	return OpenObject(procmonObject, types...)
+}
+
+// OpenObject is like Open, loading the given build of procmon.bpf.c.
+func OpenObject(obj []byte, types ...EventType) (*Monitor, error) {
+	wanted := map[EventType]bool{}
+	for _, t := range types {
+		if _, ok := eventPrograms[t]; !ok {
+			return nil, fmt.Errorf("failed to open process monitor: unknown event type %s", t)
+		}
+		wanted[t] = true
+	}
+
+	m, err := bpf.NewModuleFromBufferArgs(bpf.NewModuleArgs{
+		BPFObjBuff: obj,
+		BPFObjName: "procmon",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open process monitor: %w", err)
+	}
+
+	mon, err := newMonitor(m, wanted)
+	if err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to open process monitor: %w", err)
+	}
+
+	return mon, nil
+}
+
+func newMonitor(m *bpf.Module, wanted map[EventType]bool) (*Monitor, error) {
+	for t, progName := range eventPrograms {
+		prog, err := m.GetProgram(progName)
+		if err != nil {
+			return nil, err
+		}
+		if len(wanted) > 0 && !wanted[t] {
+			if err := prog.SetAutoload(false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := m.BPFLoadObject(); err != nil {
+		return nil, err
+	}
+	if err := m.AttachPrograms(); err != nil {
+		return nil, err
+	}
+
+	raw := make(chan []byte, eventsChanSize)
+	rb, err := m.InitRingBuf("events", raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Monitor{
+		m:      m,
+		rb:     rb,
+		raw:    raw,
+		events: make(chan Event, eventsChanSize),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Events returns the channel of the process events, closed by Close().
+func (mon *Monitor) Events() <-chan Event {
+	return mon.events
+}
+
+// Start starts reporting the events. It is safe to call Start multiple times.
+func (mon *Monitor) Start() {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	if mon.started || mon.closed {
+		return
+	}
+	mon.started = true
+
+	mon.wg.Add(1)
+	go mon.decode()
+	mon.rb.Poll(-1)
+}
+
+// decode decodes the raw events until the ring buffer is stopped, or the
+// monitor closed.
+func (mon *Monitor) decode() {
+	defer mon.wg.Done()
+	defer close(mon.events)
+
+	for data := range mon.raw {
+		e, err := decodeEvent(data)
+		if err != nil {
+			continue
+		}
+
+		select {
+		case mon.events <- e:
+		case <-mon.done:
+			return
+		}
+	}
+}
+
+// Dropped returns the number of events dropped in the kernel because the ring
+// buffer was full.
+func (mon *Monitor) Dropped() (uint64, error) {
+	v, err := mon.m.GlobalVariable("dropped")
+	if err != nil {
+		return 0, err
+	}
+
+	var dropped uint64
+	if err := v.Get(&dropped); err != nil {
+		return 0, err
+	}
+
+	return dropped, nil
+}
+
+// Close stops reporting the events, closes the events channel and detaches
+// the programs. It is safe to call Close multiple times.
+func (mon *Monitor) Close() {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	if mon.closed {
+		return
+	}
+	mon.closed = true
+
+	// Unblock the decoder if the consumer is gone, then stop the ring buffer
+	close(mon.done)
+	mon.rb.Stop()
+	mon.wg.Wait()
+	if !mon.started {
+		close(mon.events)
+	}
+
+	mon.m.Close()
+}
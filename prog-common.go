@@ -6,6 +6,12 @@ package libbpfgo
 */
 import "C"
 
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
 //
 // BPFProgType
 //
@@ -46,6 +52,7 @@ const (
 	BPFProgTypeLsm                   BPFProgType = C.BPF_PROG_TYPE_LSM
 	BPFProgTypeSkLookup              BPFProgType = C.BPF_PROG_TYPE_SK_LOOKUP
 	BPFProgTypeSyscall               BPFProgType = C.BPF_PROG_TYPE_SYSCALL
+	BPFProgTypeNetfilter             BPFProgType = C.BPF_PROG_TYPE_NETFILTER
 )
 
 // Deprecated: Convert type directly instead.
@@ -84,6 +91,7 @@ var bpfProgTypeToString = map[BPFProgType]string{
 	BPFProgTypeLsm:                   "BPF_PROG_TYPE_LSM",
 	BPFProgTypeSkLookup:              "BPF_PROG_TYPE_SK_LOOKUP",
 	BPFProgTypeSyscall:               "BPF_PROG_TYPE_SYSCALL",
+	BPFProgTypeNetfilter:             "BPF_PROG_TYPE_NETFILTER",
 }
 
 func (t BPFProgType) String() string {
@@ -100,6 +108,37 @@ func (t BPFProgType) Name() string {
 	return C.GoString(C.libbpf_bpf_prog_type_str(C.enum_bpf_prog_type(t)))
 }
 
+// bpfProgTypeMax is the number of program types of the libbpf headers, all
+// of which must be in bpfProgTypeToString.
+const bpfProgTypeMax = C.__MAX_BPF_PROG_TYPE
+
+// BPFProgTypes returns all the known program types, in enum order.
+func BPFProgTypes() []BPFProgType {
+	return sortedEnumValues(bpfProgTypeToString)
+}
+
+// ParseBPFProgType parses a program type, case-insensitively, from its enum
+// name with or without prefix ("BPF_PROG_TYPE_SCHED_CLS", "sched_cls", the
+// String() and Name() forms) or from a libbpf section name ("tc",
+// "kprobe/do_unlinkat").
+func ParseBPFProgType(s string) (BPFProgType, error) {
+	if t, ok := parseEnumName(s, "BPF_PROG_TYPE_", bpfProgTypeToString); ok {
+		return t, nil
+	}
+
+	nameC := C.CString(s)
+	defer C.free(unsafe.Pointer(nameC))
+
+	var progTypeC C.enum_bpf_prog_type
+	var attachTypeC C.enum_bpf_attach_type
+	retC := C.libbpf_prog_type_by_name(nameC, &progTypeC, &attachTypeC)
+	if retC < 0 {
+		return BPFProgTypeUnspec, fmt.Errorf("failed to parse program type %q: %w", s, syscall.Errno(-retC))
+	}
+
+	return BPFProgType(progTypeC), nil
+}
+
 //
 // BPFAttachType
 //
@@ -150,6 +189,20 @@ const (
 	BPFAttachTypeSKReusePortSelectorMigrate BPFAttachType = C.BPF_SK_REUSEPORT_SELECT_OR_MIGRATE
 	BPFAttachTypePerfEvent                  BPFAttachType = C.BPF_PERF_EVENT
 	BPFAttachTypeTraceKprobeMulti           BPFAttachType = C.BPF_TRACE_KPROBE_MULTI
+	BPFAttachTypeLSMCgroup                  BPFAttachType = C.BPF_LSM_CGROUP
+	BPFAttachTypeStructOps                  BPFAttachType = C.BPF_STRUCT_OPS
+	BPFAttachTypeTCXIngress                 BPFAttachType = C.BPF_TCX_INGRESS
+	BPFAttachTypeTCXEgress                  BPFAttachType = C.BPF_TCX_EGRESS
+	BPFAttachTypeTraceUprobeMulti           BPFAttachType = C.BPF_TRACE_UPROBE_MULTI
+	BPFAttachTypeCgroupUnixConnect          BPFAttachType = C.BPF_CGROUP_UNIX_CONNECT
+	BPFAttachTypeCgroupUnixSendMsg          BPFAttachType = C.BPF_CGROUP_UNIX_SENDMSG
+	BPFAttachTypeCgroupUnixRecvMsg          BPFAttachType = C.BPF_CGROUP_UNIX_RECVMSG
+	BPFAttachTypeCgroupUnixGetPeerName      BPFAttachType = C.BPF_CGROUP_UNIX_GETPEERNAME
+	BPFAttachTypeCgroupUnixGetSockName      BPFAttachType = C.BPF_CGROUP_UNIX_GETSOCKNAME
+	BPFAttachTypeNetkitPrimary              BPFAttachType = C.CGO_BPF_NETKIT_PRIMARY
+	BPFAttachTypeNetkitPeer                 BPFAttachType = C.CGO_BPF_NETKIT_PEER
+	BPFAttachTypeNetfilter                  BPFAttachType = C.BPF_NETFILTER
+	BPFAttachTypeTraceKprobeSession         BPFAttachType = C.CGO_BPF_TRACE_KPROBE_SESSION
 )
 
 var bpfAttachTypeToString = map[BPFAttachType]string{
@@ -196,6 +249,20 @@ var bpfAttachTypeToString = map[BPFAttachType]string{
 	BPFAttachTypeSKReusePortSelectorMigrate: "BPF_SK_REUSEPORT_SELECT_OR_MIGRATE",
 	BPFAttachTypePerfEvent:                  "BPF_PERF_EVENT",
 	BPFAttachTypeTraceKprobeMulti:           "BPF_TRACE_KPROBE_MULTI",
+	BPFAttachTypeLSMCgroup:                  "BPF_LSM_CGROUP",
+	BPFAttachTypeStructOps:                  "BPF_STRUCT_OPS",
+	BPFAttachTypeNetfilter:                  "BPF_NETFILTER",
+	BPFAttachTypeTCXIngress:                 "BPF_TCX_INGRESS",
+	BPFAttachTypeTCXEgress:                  "BPF_TCX_EGRESS",
+	BPFAttachTypeTraceUprobeMulti:           "BPF_TRACE_UPROBE_MULTI",
+	BPFAttachTypeCgroupUnixConnect:          "BPF_CGROUP_UNIX_CONNECT",
+	BPFAttachTypeCgroupUnixSendMsg:          "BPF_CGROUP_UNIX_SENDMSG",
+	BPFAttachTypeCgroupUnixRecvMsg:          "BPF_CGROUP_UNIX_RECVMSG",
+	BPFAttachTypeCgroupUnixGetPeerName:      "BPF_CGROUP_UNIX_GETPEERNAME",
+	BPFAttachTypeCgroupUnixGetSockName:      "BPF_CGROUP_UNIX_GETSOCKNAME",
+	BPFAttachTypeNetkitPrimary:              "BPF_NETKIT_PRIMARY",
+	BPFAttachTypeNetkitPeer:                 "BPF_NETKIT_PEER",
+	BPFAttachTypeTraceKprobeSession:         "BPF_TRACE_KPROBE_SESSION",
 }
 
 func (t BPFAttachType) String() string {
@@ -211,6 +278,43 @@ func (t BPFAttachType) Name() string {
 	return C.GoString(C.libbpf_bpf_attach_type_str(C.enum_bpf_attach_type(t)))
 }
 
+// bpfAttachTypeMax is the number of attach types of the libbpf headers, all
+// of which must be in bpfAttachTypeToString.
+const bpfAttachTypeMax = C.__MAX_BPF_ATTACH_TYPE
+
+// BPFAttachTypes returns all the known attach types, in enum order.
+func BPFAttachTypes() []BPFAttachType {
+	return sortedEnumValues(bpfAttachTypeToString)
+}
+
+// ParseBPFAttachType parses an attach type, case-insensitively, from its
+// enum name with or without prefix ("BPF_CGROUP_INET_INGRESS",
+// "cgroup_inet_ingress", the String() and Name() forms) or from a libbpf
+// section name of a program with an expected attach type ("cgroup_skb/ingress",
+// "fentry/do_unlinkat").
+func ParseBPFAttachType(s string) (BPFAttachType, error) {
+	if t, ok := parseEnumName(s, "BPF_", bpfAttachTypeToString); ok {
+		return t, nil
+	}
+
+	nameC := C.CString(s)
+	defer C.free(unsafe.Pointer(nameC))
+
+	var attachTypeC C.enum_bpf_attach_type
+	retC := C.libbpf_attach_type_by_name(nameC, &attachTypeC)
+	if retC < 0 {
+		// Sections of tracing programs are not attachable by libbpf's
+		// standards, yet have an expected attach type
+		var progTypeC C.enum_bpf_prog_type
+		errC := C.libbpf_prog_type_by_name(nameC, &progTypeC, &attachTypeC)
+		if errC < 0 || (progTypeC != C.BPF_PROG_TYPE_TRACING && progTypeC != C.BPF_PROG_TYPE_LSM) {
+			return 0, fmt.Errorf("failed to parse attach type %q: %w", s, syscall.Errno(-retC))
+		}
+	}
+
+	return BPFAttachType(attachTypeC), nil
+}
+
 //
 // BPFCgroupIterOrder
 //
@@ -237,3 +341,94 @@ const (
 	BPFFAllowMulti    AttachFlag = C.BPF_F_ALLOW_MULTI
 	BPFFReplace       AttachFlag = C.BPF_F_REPLACE
 )
+
+//
+// BPFProgInfo
+//
+
+// BPFProgInfo mirrors the C structure bpf_prog_info.
+type BPFProgInfo struct {
+	Type            BPFProgType
+	ID              uint32
+	Tag             [8]byte
+	Name            string
+	LoadTime        uint64 // nanoseconds since boot
+	CreatedByUID    uint32
+	MapIDs          []uint32
+	JitedProgLen    uint32
+	XlatedProgLen   uint32
+	GPLCompatible   bool
+	BTFID           uint32
+	RunTimeNs       uint64
+	RunCnt          uint64
+	RecursionMisses uint64
+	VerifiedInsns   uint32
+	AttachBTFObjID  uint32 // BTF object of the attach target (tracing programs)
+	AttachBTFID     uint32 // BTF type of the attach target (tracing programs)
+	IfIndex         uint32 // device the program is offloaded to, 0 if none
+	// VerifierStats are only known to the loader of the program, see
+	// BPFProg.Info().
+	VerifierStats *VerifierStats
+}
+
+// GetProgInfoByFD returns the BPFProgInfo for the program with the given file descriptor.
+func GetProgInfoByFD(fd int) (*BPFProgInfo, error) {
+	infoC := C.cgo_bpf_prog_info_new()
+	defer C.cgo_bpf_prog_info_free(infoC)
+
+	infoLenC := C.cgo_bpf_prog_info_size()
+	retC := C.bpf_prog_get_info_by_fd(C.int(fd), infoC, &infoLenC)
+	if retC < 0 {
+		return nil, fmt.Errorf("failed to get prog info for fd %d: %w", fd, syscall.Errno(-retC))
+	}
+
+	info := &BPFProgInfo{
+		Type:            BPFProgType(C.cgo_bpf_prog_info_type(infoC)),
+		ID:              uint32(C.cgo_bpf_prog_info_id(infoC)),
+		Name:            C.GoString(C.cgo_bpf_prog_info_name(infoC)),
+		LoadTime:        uint64(C.cgo_bpf_prog_info_load_time(infoC)),
+		CreatedByUID:    uint32(C.cgo_bpf_prog_info_created_by_uid(infoC)),
+		JitedProgLen:    uint32(C.cgo_bpf_prog_info_jited_prog_len(infoC)),
+		XlatedProgLen:   uint32(C.cgo_bpf_prog_info_xlated_prog_len(infoC)),
+		GPLCompatible:   bool(C.cgo_bpf_prog_info_gpl_compatible(infoC)),
+		BTFID:           uint32(C.cgo_bpf_prog_info_btf_id(infoC)),
+		RunTimeNs:       uint64(C.cgo_bpf_prog_info_run_time_ns(infoC)),
+		RunCnt:          uint64(C.cgo_bpf_prog_info_run_cnt(infoC)),
+		RecursionMisses: uint64(C.cgo_bpf_prog_info_recursion_misses(infoC)),
+		VerifiedInsns:   uint32(C.cgo_bpf_prog_info_verified_insns(infoC)),
+		AttachBTFObjID:  uint32(C.cgo_bpf_prog_info_attach_btf_obj_id(infoC)),
+		AttachBTFID:     uint32(C.cgo_bpf_prog_info_attach_btf_id(infoC)),
+		IfIndex:         uint32(C.cgo_bpf_prog_info_ifindex(infoC)),
+	}
+	copy(info.Tag[:], C.GoBytes(unsafe.Pointer(C.cgo_bpf_prog_info_tag(infoC)), C.int(len(info.Tag))))
+
+	// The map IDs are only filled in when a buffer for them is provided, so
+	// a second query is needed.
+	nrMapIDs := uint32(C.cgo_bpf_prog_info_nr_map_ids(infoC))
+	if nrMapIDs == 0 {
+		return info, nil
+	}
+
+	mapIDsC := C.calloc(C.size_t(nrMapIDs), C.size_t(unsafe.Sizeof(C.__u32(0))))
+	if mapIDsC == nil {
+		return nil, fmt.Errorf("failed to allocate map ids for prog fd %d", fd)
+	}
+	defer C.free(mapIDsC)
+
+	mapsInfoC := C.cgo_bpf_prog_info_new()
+	defer C.cgo_bpf_prog_info_free(mapsInfoC)
+
+	C.cgo_bpf_prog_info_set_map_ids(mapsInfoC, (*C.__u32)(mapIDsC), C.__u32(nrMapIDs))
+	infoLenC = C.cgo_bpf_prog_info_size()
+	retC = C.bpf_prog_get_info_by_fd(C.int(fd), mapsInfoC, &infoLenC)
+	if retC < 0 {
+		return nil, fmt.Errorf("failed to get prog map ids for fd %d: %w", fd, syscall.Errno(-retC))
+	}
+
+	// Maps may have been released in between
+	nrMapIDs = min(nrMapIDs, uint32(C.cgo_bpf_prog_info_nr_map_ids(mapsInfoC)))
+	info.MapIDs = make([]uint32, nrMapIDs)
+	copy(info.MapIDs, unsafe.Slice((*uint32)(mapIDsC), nrMapIDs))
+
+	return info, nil
+}
@@ -0,0 +1,118 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+//
+// LSM hooks
+//
+// LSM programs are attached to the hook of their section name
+// (SEC("lsm/file_open")). SetLSMHook() selects the hook at runtime instead,
+// before the module is loaded, so that a program written for several hooks
+// of the same signature can be attached to the one chosen by configuration:
+//
+//	SEC("lsm/file_open")
+//	int BPF_PROG(check_file, struct file *file) { ... }
+//
+//	prog.SetLSMHook(cfg.Hook) // "file_open", "file_receive", ...
+//
+// A program is attached to a single hook: attaching the same body to several
+// hooks takes a program per hook, or the object opened once per hook.
+//
+// The hooks are the bpf_lsm_<hook> functions of the kernel BTF. The verifier
+// still rejects the hooks not open to BPF, and the programs whose arguments
+// do not match the hook.
+//
+
+const btfLSMPrefix = "bpf_lsm_"
+
+// lsmHookName returns the name of the hook, given with or without the
+// bpf_lsm_ prefix.
+func lsmHookName(hook string) string {
+	return strings.TrimPrefix(hook, btfLSMPrefix)
+}
+
+// loadVmlinuxBTF loads the BTF of the running kernel, to be freed with
+// btf__free().
+func loadVmlinuxBTF() (*C.struct_btf, error) {
+	btfC, errno := C.btf__load_vmlinux_btf()
+	if btfC == nil {
+		return nil, fmt.Errorf("failed to load vmlinux BTF: %w", errno)
+	}
+
+	return btfC, nil
+}
+
+// LSMHooks returns the sorted names of the LSM hooks of the running kernel,
+// without the bpf_lsm_ prefix.
+func LSMHooks() ([]string, error) {
+	btfC, err := loadVmlinuxBTF()
+	if err != nil {
+		return nil, err
+	}
+	defer C.btf__free(btfC)
+
+	var hooks []string
+	for id := C.__u32(1); id < C.btf__type_cnt(btfC); id++ {
+		if C.cgo_btf_type_kind(btfC, id) != C.BTF_KIND_FUNC {
+			continue
+		}
+		if name := C.GoString(C.cgo_btf_type_name(btfC, id)); strings.HasPrefix(name, btfLSMPrefix) {
+			hooks = append(hooks, lsmHookName(name))
+		}
+	}
+	slices.Sort(hooks)
+
+	return hooks, nil
+}
+
+// LSMHookExists reports whether the running kernel has the LSM hook, given
+// with or without the bpf_lsm_ prefix.
+func LSMHookExists(hook string) (bool, error) {
+	btfC, err := loadVmlinuxBTF()
+	if err != nil {
+		return false, err
+	}
+	defer C.btf__free(btfC)
+
+	nameC := C.CString(btfLSMPrefix + lsmHookName(hook))
+	defer C.free(unsafe.Pointer(nameC))
+
+	return C.btf__find_by_name_kind(btfC, nameC, C.BTF_KIND_FUNC) > 0, nil
+}
+
+// SetLSMHook sets the LSM hook the program is attached to, given with or
+// without the bpf_lsm_ prefix, in place of the hook of its section name. The
+// hook is checked against the kernel BTF. It must be called before the module
+// is loaded.
+func (p *BPFProg) SetLSMHook(hook string) error {
+	if p.GetType() != BPFProgTypeLsm {
+		return fmt.Errorf("failed to set lsm hook of program %s: program is %s, not lsm", p.Name(), p.GetType())
+	}
+	if p.module.loaded {
+		return fmt.Errorf("failed to set lsm hook of program %s: module already loaded", p.Name())
+	}
+
+	hook = lsmHookName(hook)
+	exists, err := LSMHookExists(hook)
+	if err != nil {
+		return fmt.Errorf("failed to set lsm hook of program %s: %w", p.Name(), err)
+	}
+	if !exists {
+		return fmt.Errorf("failed to set lsm hook of program %s: unknown hook %s: %w", p.Name(), hook, syscall.ENOENT)
+	}
+
+	// libbpf looks the hook up with the bpf_lsm_ prefix
+	return p.SetAttachTarget(0, hook)
+}
@@ -0,0 +1,12 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLSMHookName(t *testing.T) {
+	assert.Equal(t, "file_open", lsmHookName("file_open"))
+	assert.Equal(t, "file_open", lsmHookName("bpf_lsm_file_open"))
+}
@@ -0,0 +1,191 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+)
+
+//
+// Program run statistics
+//
+// With BPF statistics enabled, the kernel counts the runs of each program
+// and their cumulated run time, reported as RunCnt and RunTimeNs by
+// BPFProgInfo. They are disabled by default, as they add overhead to every
+// run, and enabled by the kernel.bpf_stats_enabled sysctl or, from v5.8, as
+// long as a file descriptor returned by EnableRunStats() is open.
+//
+// SampleRunStats() samples the statistics of programs over an interval,
+// giving their rate of runs and their average run time, which is the core of
+// a "bpf top":
+//
+//	stats, err := libbpfgo.EnableRunStats()
+//	...
+//	defer stats.Close()
+//
+//	report, err := m.SampleRunStats(time.Second)
+//	...
+//	for _, s := range report.ByRunTime() {
+//		fmt.Printf("%-16s %10.0f/s %8v %5.1f%%\n", s.Name, s.RunsPerSec(), s.AvgRunTime(), s.CPUUsage()*100)
+//	}
+//
+
+// EnableRunStats enables the run statistics of the programs until the
+// returned closer is closed, or the process exits.
+func EnableRunStats() (io.Closer, error) {
+	fdC := C.bpf_enable_stats(C.BPF_STATS_RUN_TIME)
+	if fdC < 0 {
+		return nil, fmt.Errorf("failed to enable BPF stats: %w", syscall.Errno(-fdC))
+	}
+
+	return os.NewFile(uintptr(fdC), "bpf-stats"), nil
+}
+
+// ProgRunSample is the activity of a program over a sampling interval.
+type ProgRunSample struct {
+	ID   uint32
+	Name string
+	Type BPFProgType
+	// RunCnt and RunTimeNs are the runs of the program, and their run time,
+	// over the interval.
+	RunCnt    uint64
+	RunTimeNs uint64
+	// RecursionMisses are the runs skipped over the interval, the program
+	// being already running on the CPU.
+	RecursionMisses uint64
+	Interval        time.Duration
+}
+
+// RunsPerSec returns the rate of runs of the program.
+func (s ProgRunSample) RunsPerSec() float64 {
+	if s.Interval <= 0 {
+		return 0
+	}
+
+	return float64(s.RunCnt) / s.Interval.Seconds()
+}
+
+// AvgRunTime returns the average run time of the program.
+func (s ProgRunSample) AvgRunTime() time.Duration {
+	if s.RunCnt == 0 {
+		return 0
+	}
+
+	return time.Duration(s.RunTimeNs / s.RunCnt)
+}
+
+// CPUUsage returns the share of a CPU the program used, which is more than 1
+// for programs running on several CPUs at once.
+func (s ProgRunSample) CPUUsage() float64 {
+	if s.Interval <= 0 {
+		return 0
+	}
+
+	return float64(s.RunTimeNs) / float64(s.Interval.Nanoseconds())
+}
+
+// RunStatsReport is the activity of programs over a sampling interval.
+type RunStatsReport struct {
+	Interval time.Duration
+	Programs []ProgRunSample
+}
+
+// ByRunTime returns the samples, from the program that ran the longest.
+func (r *RunStatsReport) ByRunTime() []ProgRunSample {
+	samples := append([]ProgRunSample(nil), r.Programs...)
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].RunTimeNs > samples[j].RunTimeNs
+	})
+
+	return samples
+}
+
+// SampleRunStats samples the run statistics of the programs, by file
+// descriptor, over the interval. Without run statistics enabled, the
+// programs do not run as far as the report is concerned.
+func SampleRunStats(progFds []int, interval time.Duration) (*RunStatsReport, error) {
+	return sampleRunStats(progFds, interval, GetProgInfoByFD, time.Sleep)
+}
+
+// SampleRunStats samples the run statistics of the loaded programs of the
+// module over the interval (see SampleRunStats()).
+func (m *Module) SampleRunStats(interval time.Duration) (*RunStatsReport, error) {
+	var fds []int
+	it := m.Iterator()
+	for prog := it.NextProgram(); prog != nil; prog = it.NextProgram() {
+		if fd := prog.FileDescriptor(); fd >= 0 {
+			fds = append(fds, fd)
+		}
+	}
+
+	return SampleRunStats(fds, interval)
+}
+
+func sampleRunStats(
+	progFds []int,
+	interval time.Duration,
+	progInfo func(fd int) (*BPFProgInfo, error),
+	sleep func(time.Duration),
+) (*RunStatsReport, error) {
+	read := func() ([]*BPFProgInfo, error) {
+		infos := make([]*BPFProgInfo, len(progFds))
+		for i, fd := range progFds {
+			info, err := progInfo(fd)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sample run stats: %w", err)
+			}
+			infos[i] = info
+		}
+		return infos, nil
+	}
+
+	before, err := read()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	sleep(interval)
+	after, err := read()
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	report := &RunStatsReport{
+		Interval: elapsed,
+		Programs: make([]ProgRunSample, len(progFds)),
+	}
+	for i := range progFds {
+		report.Programs[i] = runStatsDelta(before[i], after[i], elapsed)
+	}
+
+	return report, nil
+}
+
+// runStatsDelta returns the activity of a program between two readings of
+// its information.
+func runStatsDelta(before, after *BPFProgInfo, elapsed time.Duration) ProgRunSample {
+	if before.ID != after.ID {
+		// The file descriptor was closed and reused meanwhile
+		before = &BPFProgInfo{}
+	}
+
+	return ProgRunSample{
+		ID:              after.ID,
+		Name:            after.Name,
+		Type:            after.Type,
+		RunCnt:          after.RunCnt - before.RunCnt,
+		RunTimeNs:       after.RunTimeNs - before.RunTimeNs,
+		RecursionMisses: after.RecursionMisses - before.RecursionMisses,
+		Interval:        elapsed,
+	}
+}
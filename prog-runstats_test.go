@@ -0,0 +1,73 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleRunStats(t *testing.T) {
+	infos := map[int]*BPFProgInfo{
+		3: {ID: 10, Name: "trace_exec", Type: BPFProgTypeTracepoint, RunCnt: 100, RunTimeNs: 50_000},
+		4: {ID: 11, Name: "xdp_drop", Type: BPFProgTypeXdp, RunCnt: 5, RunTimeNs: 1_000, RecursionMisses: 1},
+	}
+	progInfo := func(fd int) (*BPFProgInfo, error) {
+		info, ok := infos[fd]
+		if !ok {
+			return nil, fmt.Errorf("failed to get prog info for fd %d: %w", fd, syscall.EBADF)
+		}
+		copied := *info
+		return &copied, nil
+	}
+	// The programs run while sleeping
+	sleep := func(d time.Duration) {
+		infos[3].RunCnt += 1000
+		infos[3].RunTimeNs += 250_000
+		infos[4].RunCnt += 4000
+		infos[4].RunTimeNs += 800_000
+		infos[4].RecursionMisses += 2
+		time.Sleep(d)
+	}
+
+	report, err := sampleRunStats([]int{3, 4}, 10*time.Millisecond, progInfo, sleep)
+	require.NoError(t, err)
+	require.Len(t, report.Programs, 2)
+	assert.GreaterOrEqual(t, report.Interval, 10*time.Millisecond)
+
+	exec := report.Programs[0]
+	assert.Equal(t, "trace_exec", exec.Name)
+	assert.Equal(t, uint64(1000), exec.RunCnt)
+	assert.Equal(t, uint64(250_000), exec.RunTimeNs)
+	assert.Equal(t, 250*time.Nanosecond, exec.AvgRunTime())
+	assert.Equal(t, report.Interval, exec.Interval)
+
+	xdp := report.Programs[1]
+	assert.Equal(t, uint64(2), xdp.RecursionMisses)
+	assert.Equal(t, 200*time.Nanosecond, xdp.AvgRunTime())
+
+	// Longest running first
+	assert.Equal(t, []string{"xdp_drop", "trace_exec"}, []string{report.ByRunTime()[0].Name, report.ByRunTime()[1].Name})
+	assert.Equal(t, "trace_exec", report.Programs[0].Name, "report left in order")
+
+	_, err = sampleRunStats([]int{3, 5}, 0, progInfo, sleep)
+	assert.ErrorIs(t, err, syscall.EBADF)
+}
+
+func TestProgRunSample(t *testing.T) {
+	s := ProgRunSample{RunCnt: 2000, RunTimeNs: 500_000_000, Interval: 2 * time.Second}
+	assert.InDelta(t, 1000, s.RunsPerSec(), 0.001)
+	assert.Equal(t, 250*time.Microsecond, s.AvgRunTime())
+	assert.InDelta(t, 0.25, s.CPUUsage(), 0.001)
+
+	assert.Zero(t, ProgRunSample{}.RunsPerSec())
+	assert.Zero(t, ProgRunSample{}.AvgRunTime())
+	assert.Zero(t, ProgRunSample{}.CPUUsage())
+
+	// A reused file descriptor counts from 0
+	delta := runStatsDelta(&BPFProgInfo{ID: 1, RunCnt: 500}, &BPFProgInfo{ID: 2, RunCnt: 20}, time.Second)
+	assert.Equal(t, uint64(20), delta.RunCnt)
+}
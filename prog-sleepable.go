@@ -0,0 +1,139 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Sleepable programs
+//
+// Sleepable programs (SEC("fentry.s/..."), SEC("lsm.s/..."),
+// SEC("uprobe.s/...")) may call helpers that fault in user memory, such as
+// bpf_copy_from_user(). Only some program types can be sleepable, and
+// kprobe programs only when attached to uprobes: the kernel rejects the
+// others with a bare EINVAL.
+//
+
+// sleepableProbeLogSize is enough for the verifier messages of the probe.
+const sleepableProbeLogSize = 4096
+
+// BPFSleepableIsSupported reports whether the kernel supports sleepable
+// programs of the given type: tracing (fentry.s, fexit.s, fmod_ret.s,
+// iter.s) and LSM programs since v5.10, kprobe programs attached to uprobes
+// (uprobe.s) and syscall programs since later versions.
+func BPFSleepableIsSupported(progType BPFProgType) (bool, error) {
+	probeType := progType
+	switch progType {
+	case BPFProgTypeKprobe, BPFProgTypeSyscall:
+		// Loadable without an attach target, probe the type itself
+	case BPFProgTypeTracing, BPFProgTypeLsm:
+		// These need an attach target to be loaded. Probe with a socket
+		// filter instead: kernels knowing sleepable programs reject it
+		// with a verifier message, older ones reject the flag silently.
+		probeType = BPFProgTypeSocketFilter
+	default:
+		return false, nil
+	}
+
+	logC := (*C.char)(C.calloc(1, sleepableProbeLogSize))
+	if logC == nil {
+		return false, fmt.Errorf("failed to allocate probe log: %w", syscall.ENOMEM)
+	}
+	defer C.free(unsafe.Pointer(logC))
+
+	retC := C.cgo_probe_sleepable(C.enum_bpf_prog_type(probeType), logC, sleepableProbeLogSize)
+	log := C.GoString(logC)
+
+	if probeType != progType {
+		if retC < 0 && syscall.Errno(-retC) != syscall.EINVAL {
+			return false, fmt.Errorf("failed to probe sleepable %s programs: %w", progType, classifyError(opLoad, syscall.Errno(-retC), log))
+		}
+
+		return strings.Contains(log, "sleepable"), nil
+	}
+
+	switch {
+	case retC == 0:
+		return true, nil
+	case syscall.Errno(-retC) == syscall.EINVAL:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to probe sleepable %s programs: %w", progType, classifyError(opLoad, syscall.Errno(-retC), log))
+	}
+}
+
+// canBeSleepable reports whether programs of the type and expected attach
+// type can be loaded as sleepable.
+func canBeSleepable(progType BPFProgType, attachType BPFAttachType) bool {
+	switch progType {
+	case BPFProgTypeTracing:
+		switch attachType {
+		case BPFAttachTypeTraceFentry,
+			BPFAttachTypeTraceFexit,
+			BPFAttachTypeModifyReturn,
+			BPFAttachTypeTraceIter:
+			return true
+		}
+
+		return false
+	case BPFProgTypeLsm, BPFProgTypeKprobe, BPFProgTypeStructOps, BPFProgTypeSyscall:
+		return true
+	}
+
+	return false
+}
+
+// Sleepable reports whether the program is sleepable, from its section name
+// (e.g. SEC("fentry.s/...")) or SetSleepable().
+func (p *BPFProg) Sleepable() bool {
+	return C.bpf_program__flags(p.prog)&C.BPF_F_SLEEPABLE != 0
+}
+
+// SetSleepable marks the program as sleepable, or not, overriding its section
+// name. It must be called before the BPF object is loaded.
+func (p *BPFProg) SetSleepable(sleepable bool) error {
+	if p.module != nil && p.module.loaded {
+		return errors.New("must be called before the BPF object is loaded")
+	}
+
+	progType := p.GetType()
+	attachType := BPFAttachType(C.bpf_program__expected_attach_type(p.prog))
+	if sleepable && !canBeSleepable(progType, attachType) {
+		return fmt.Errorf("failed to set program %s sleepable: %s programs (%s) can not be sleepable: %w",
+			p.Name(), progType, attachType, ErrSleepableNotAllowed)
+	}
+
+	flags := C.bpf_program__flags(p.prog)
+	if sleepable {
+		flags |= C.BPF_F_SLEEPABLE
+	} else {
+		flags &^= C.BPF_F_SLEEPABLE
+	}
+
+	retC := C.bpf_program__set_flags(p.prog, flags)
+	if retC < 0 {
+		return fmt.Errorf("failed to set program %s sleepable: %w", p.Name(), syscall.Errno(-retC))
+	}
+
+	return nil
+}
+
+// checkNotSleepable fails for sleepable programs, to be called before
+// attaching to hooks which can not run them.
+func (p *BPFProg) checkNotSleepable(hook string) error {
+	if !p.Sleepable() {
+		return nil
+	}
+
+	return fmt.Errorf("sleepable program %s can not be attached to %s: %w", p.Name(), hook, ErrSleepableNotAllowed)
+}
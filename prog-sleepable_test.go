@@ -0,0 +1,31 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanBeSleepable(t *testing.T) {
+	tests := []struct {
+		progType   BPFProgType
+		attachType BPFAttachType
+		want       bool
+	}{
+		{BPFProgTypeTracing, BPFAttachTypeTraceFentry, true},
+		{BPFProgTypeTracing, BPFAttachTypeTraceFexit, true},
+		{BPFProgTypeTracing, BPFAttachTypeModifyReturn, true},
+		{BPFProgTypeTracing, BPFAttachTypeTraceIter, true},
+		{BPFProgTypeTracing, BPFAttachTypeTraceRawTP, false},
+		{BPFProgTypeLsm, BPFAttachTypeLSMMac, true},
+		{BPFProgTypeKprobe, BPFAttachTypeCgroupInetIngress, true},
+		{BPFProgTypeSyscall, BPFAttachTypeCgroupInetIngress, true},
+		{BPFProgTypeXdp, BPFAttachTypeXDP, false},
+		{BPFProgTypeTracepoint, BPFAttachTypeCgroupInetIngress, false},
+		{BPFProgTypeSchedCls, BPFAttachTypeCgroupInetIngress, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, canBeSleepable(tt.progType, tt.attachType), "%s %s", tt.progType, tt.attachType)
+	}
+}
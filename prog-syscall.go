@@ -0,0 +1,113 @@
+package libbpfgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"syscall"
+)
+
+//
+// Syscall programs
+//
+// BPF_PROG_TYPE_SYSCALL programs (SEC("syscall"), v5.14) are attached
+// nowhere, they run on demand from userspace with BPF_PROG_RUN. They are
+// sleepable, and call bpf_sys_bpf() to create maps and load programs (as the
+// loaders of light skeletons do), bpf_sys_close(), bpf_btf_find_by_name_kind()
+// and the kfuncs allowed to syscall programs. Their context is a structure of
+// the program's choosing, copied in before the run and back after it, so the
+// program also returns results through it:
+//
+//	struct args {
+//	    __u32 map_fd;
+//	    __s32 err;
+//	};
+//
+//	SEC("syscall")
+//	int setup(struct args *ctx) { ... }
+//
+//	args := struct {
+//		MapFD uint32
+//		Err   int32
+//	}{MapFD: uint32(m.FileDescriptor())}
+//	ret, err := prog.RunSyscallCtx(&args)
+//
+// The Go structures are encoded with encoding/binary in host byte order,
+// which does not pad fields: the C padding must be explicit fields.
+//
+
+// maxSyscallCtxSize is the size limit of the context of syscall programs.
+const maxSyscallCtxSize = 1<<16 - 1
+
+// RunSyscall runs the BPF_PROG_TYPE_SYSCALL program with ctx as its
+// context, updated with the context after the run, and returns the value
+// returned by the program.
+func (p *BPFProg) RunSyscall(ctx []byte) (int32, error) {
+	if err := p.checkNotUnloaded("run"); err != nil {
+		return 0, err
+	}
+
+	if p.GetType() != BPFProgTypeSyscall {
+		return 0, fmt.Errorf("failed to run program %s: not a syscall program: %w", p.Name(), syscall.EINVAL)
+	}
+	if len(ctx) > maxSyscallCtxSize {
+		return 0, fmt.Errorf("failed to run program %s: context of %d bytes, more than %d: %w", p.Name(), len(ctx), maxSyscallCtxSize, syscall.EINVAL)
+	}
+
+	opts := RunOpts{}
+	if len(ctx) > 0 {
+		opts.CtxIn = ctx
+		opts.CtxSizeIn = uint32(len(ctx))
+	}
+	if err := p.Run(&opts); err != nil {
+		return 0, err
+	}
+	copy(ctx, opts.CtxIn)
+
+	return int32(opts.RetVal), nil
+}
+
+// RunSyscallCtx runs the BPF_PROG_TYPE_SYSCALL program with the structure
+// pointed to by ctx as its context, updated with the context after the run,
+// and returns the value returned by the program. ctx holds fixed size fields
+// only, laid out as the C structure of the program.
+func (p *BPFProg) RunSyscallCtx(ctx any) (int32, error) {
+	data, err := encodeSyscallCtx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run program %s: %w", p.Name(), err)
+	}
+
+	ret, err := p.RunSyscall(data)
+	if err != nil {
+		return 0, err
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.NativeEndian, ctx); err != nil {
+		return 0, fmt.Errorf("failed to run program %s: decoding context: %w", p.Name(), err)
+	}
+
+	return ret, nil
+}
+
+// encodeSyscallCtx encodes the structure pointed to by ctx as the context of
+// a syscall program.
+func encodeSyscallCtx(ctx any) ([]byte, error) {
+	if v := reflect.ValueOf(ctx); v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, fmt.Errorf("context %T not a pointer: %w", ctx, syscall.EINVAL)
+	}
+
+	size := binary.Size(ctx)
+	if size < 0 {
+		return nil, fmt.Errorf("context %T not of fixed size: %w", ctx, syscall.EINVAL)
+	}
+	if size > maxSyscallCtxSize {
+		return nil, fmt.Errorf("context %T of %d bytes, more than %d: %w", ctx, size, maxSyscallCtxSize, syscall.EINVAL)
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.NativeEndian, ctx); err != nil {
+		return nil, fmt.Errorf("encoding context %T: %w", ctx, err)
+	}
+
+	return buf.Bytes(), nil
+}
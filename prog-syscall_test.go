@@ -0,0 +1,42 @@
+package libbpfgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeSyscallCtx(t *testing.T) {
+	type args struct {
+		MapFD uint32
+		Err   int32
+		Flags uint64
+	}
+
+	ctx := &args{MapFD: 7, Err: -1, Flags: 1 << 40}
+	data, err := encodeSyscallCtx(ctx)
+	require.NoError(t, err)
+	require.Len(t, data, 16)
+	assert.Equal(t, uint32(7), binary.NativeEndian.Uint32(data[0:]))
+	assert.Equal(t, uint32(0xffffffff), binary.NativeEndian.Uint32(data[4:]))
+	assert.Equal(t, uint64(1<<40), binary.NativeEndian.Uint64(data[8:]))
+
+	// The context written by the program is read back
+	binary.NativeEndian.PutUint32(data[4:], 3)
+	require.NoError(t, binary.Read(bytes.NewReader(data), binary.NativeEndian, ctx))
+	assert.Equal(t, args{MapFD: 7, Err: 3, Flags: 1 << 40}, *ctx)
+
+	for _, invalid := range []any{
+		args{},
+		(*args)(nil),
+		&struct{ Names []string }{},
+		&[maxSyscallCtxSize + 1]byte{},
+	} {
+		_, err := encodeSyscallCtx(invalid)
+		assert.ErrorIs(t, err, syscall.EINVAL, "%T", invalid)
+	}
+}
@@ -0,0 +1,233 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Multi-uprobes
+//
+// A uprobe_multi link (v6.6) attaches a program to many probe sites of a
+// binary or library at once, instead of a uprobe link per site:
+//
+//	links, err := prog.AttachUprobeMulti("libssl", UprobeMultiOpts{
+//		Pattern: "SSL_*",
+//		PIDs:    []int{1234, 5678},
+//	})
+//
+// The kernel filters a uprobe_multi link on a single process, so a link is
+// created per PID of PIDs, each covering all the probe sites: one link per
+// process, instead of one per process and probe site.
+//
+
+// UprobeMultiOpts are the probe sites, and processes, of a multi-uprobe. The
+// probe sites are given by exactly one of Pattern, Symbols and Offsets.
+type UprobeMultiOpts struct {
+	// Pattern is a glob ('*' and '?') of the functions to probe, resolved by
+	// libbpf from the symbols of the binary or library.
+	Pattern string
+	// Symbols are the functions to probe.
+	Symbols []string
+	// Offsets are the offsets to probe within the binary or library.
+	Offsets []uint64
+	// RefCtrOffsets are the offsets of the reference counters (USDT
+	// semaphores) of the Symbols or Offsets, if any.
+	RefCtrOffsets []uint64
+	// Cookies are the cookies of the Symbols or Offsets, which the program
+	// reads with the bpf_get_attach_cookie() helper.
+	Cookies []uint64
+	// PIDs are the processes to probe, all if empty.
+	PIDs []int
+}
+
+// sites returns the number of probe sites given by Symbols or Offsets, 0 for
+// a Pattern.
+func (o *UprobeMultiOpts) sites() (int, error) {
+	given := 0
+	for _, set := range []bool{o.Pattern != "", len(o.Symbols) > 0, len(o.Offsets) > 0} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return 0, fmt.Errorf("exactly one of Pattern, Symbols and Offsets must be given: %w", syscall.EINVAL)
+	}
+
+	cnt := max(len(o.Symbols), len(o.Offsets))
+	if o.Pattern != "" && (len(o.RefCtrOffsets) > 0 || len(o.Cookies) > 0) {
+		return 0, fmt.Errorf("ref counter offsets and cookies need symbols or offsets: %w", syscall.EINVAL)
+	}
+	if len(o.RefCtrOffsets) > 0 && len(o.RefCtrOffsets) != cnt {
+		return 0, fmt.Errorf("%d RefCtrOffsets for %d probe sites: %w", len(o.RefCtrOffsets), cnt, syscall.EINVAL)
+	}
+	if len(o.Cookies) > 0 && len(o.Cookies) != cnt {
+		return 0, fmt.Errorf("%d Cookies for %d probe sites: %w", len(o.Cookies), cnt, syscall.EINVAL)
+	}
+
+	return cnt, nil
+}
+
+// AttachUprobeMulti attaches the BPFProgram to the entry of the probe sites
+// of the library or binary at 'path', which is looked up like with
+// AttachUprobeFunc(), through uprobe_multi links. It returns a link per
+// process of opts.PIDs, or a single link for all processes.
+func (p *BPFProg) AttachUprobeMulti(path string, opts UprobeMultiOpts) ([]*BPFLink, error) {
+	return doAttachUprobeMulti(p, false, path, opts)
+}
+
+// AttachURetprobeMulti attaches the BPFProgram to the exit of the probe
+// sites. See AttachUprobeMulti().
+func (p *BPFProg) AttachURetprobeMulti(path string, opts UprobeMultiOpts) ([]*BPFLink, error) {
+	return doAttachUprobeMulti(p, true, path, opts)
+}
+
+func doAttachUprobeMulti(prog *BPFProg, isUretprobe bool, path string, opts UprobeMultiOpts) ([]*BPFLink, error) {
+	if err := prog.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	cnt, err := opts.sites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach uprobe multi to program %s: %w", prog.Name(), err)
+	}
+
+	path, err = uprobeTarget(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pathC := C.CString(path)
+	defer C.free(unsafe.Pointer(pathC))
+
+	target := fmt.Sprintf("%d-sites", cnt)
+	var patternC *C.char
+	if opts.Pattern != "" {
+		target = opts.Pattern
+		patternC = C.CString(opts.Pattern)
+		defer C.free(unsafe.Pointer(patternC))
+	}
+
+	// The options are C memory, which must not hold Go pointers
+	symsC := cStringArray(opts.Symbols)
+	defer freeCStringArray(symsC, len(opts.Symbols))
+	offsetsC := cULongArray(opts.Offsets)
+	defer C.free(unsafe.Pointer(offsetsC))
+	refCtrOffsetsC := cULongArray(opts.RefCtrOffsets)
+	defer C.free(unsafe.Pointer(refCtrOffsetsC))
+	cookiesC := cU64Array(opts.Cookies)
+	defer C.free(unsafe.Pointer(cookiesC))
+
+	optsC, errno := C.cgo_bpf_uprobe_multi_opts_new(symsC, offsetsC, refCtrOffsetsC, cookiesC, C.size_t(cnt), C.bool(isUretprobe))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create uprobe_multi_opts for program %s: %w", prog.Name(), errno)
+	}
+	defer C.cgo_bpf_uprobe_multi_opts_free(optsC)
+
+	upType := UprobeMulti
+	if isUretprobe {
+		upType = UretprobeMulti
+	}
+
+	pids := opts.PIDs
+	if len(pids) == 0 {
+		pids = []int{-1}
+	}
+
+	links := make([]*BPFLink, 0, len(pids))
+	for _, pid := range pids {
+		linkC, errno := C.bpf_program__attach_uprobe_multi(prog.prog, C.int(pid), pathC, patternC, optsC)
+		if linkC == nil {
+			for _, link := range links {
+				_ = link.Destroy()
+			}
+			return nil, fmt.Errorf("failed to attach uprobe multi to program %s:%s with pid %d: %w", path, target, pid, classifyError(opAttach, errno, ""))
+		}
+
+		bpfLink := &BPFLink{
+			link:      linkC,
+			prog:      prog,
+			linkType:  upType,
+			eventName: fmt.Sprintf("uprobe_multi-%s:%d:%s", path, pid, target),
+			pid:       max(pid, 0),
+		}
+		prog.module.addLink(bpfLink)
+		links = append(links, bpfLink)
+	}
+
+	return links, nil
+}
+
+// cArray allocates a C array of n elements of size bytes, nil if n is 0.
+func cArray(n int, size uintptr) unsafe.Pointer {
+	if n == 0 {
+		return nil
+	}
+
+	return C.malloc(C.size_t(n) * C.size_t(size))
+}
+
+// cULongArray returns a C copy of the values, nil if there are none.
+func cULongArray(values []uint64) *C.ulong {
+	arrC := (*C.ulong)(cArray(len(values), unsafe.Sizeof(C.ulong(0))))
+	if arrC == nil {
+		return nil
+	}
+
+	arr := unsafe.Slice(arrC, len(values))
+	for i, v := range values {
+		arr[i] = C.ulong(v)
+	}
+
+	return arrC
+}
+
+// cU64Array returns a C copy of the values, nil if there are none.
+func cU64Array(values []uint64) *C.__u64 {
+	arrC := (*C.__u64)(cArray(len(values), unsafe.Sizeof(C.__u64(0))))
+	if arrC == nil {
+		return nil
+	}
+
+	arr := unsafe.Slice(arrC, len(values))
+	for i, v := range values {
+		arr[i] = C.__u64(v)
+	}
+
+	return arrC
+}
+
+// cStringArray returns a C copy of the strings, nil if there are none. It is
+// freed with freeCStringArray().
+func cStringArray(strs []string) **C.char {
+	arrC := (**C.char)(cArray(len(strs), unsafe.Sizeof((*C.char)(nil))))
+	if arrC == nil {
+		return nil
+	}
+
+	arr := unsafe.Slice(arrC, len(strs))
+	for i, s := range strs {
+		arr[i] = C.CString(s)
+	}
+
+	return arrC
+}
+
+// freeCStringArray frees the n strings of arrC, and arrC.
+func freeCStringArray(arrC **C.char, n int) {
+	if arrC == nil {
+		return
+	}
+
+	for _, s := range unsafe.Slice(arrC, n) {
+		C.free(unsafe.Pointer(s))
+	}
+	C.free(unsafe.Pointer(arrC))
+}
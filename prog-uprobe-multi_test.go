@@ -0,0 +1,35 @@
+package libbpfgo
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUprobeMultiOptsSites(t *testing.T) {
+	cnt, err := (&UprobeMultiOpts{Pattern: "SSL_*", PIDs: []int{1, 2}}).sites()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+
+	cnt, err = (&UprobeMultiOpts{Symbols: []string{"SSL_read", "SSL_write"}, Cookies: []uint64{1, 2}}).sites()
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	cnt, err = (&UprobeMultiOpts{Offsets: []uint64{0x10, 0x20, 0x30}, RefCtrOffsets: []uint64{0, 0, 0x8}}).sites()
+	require.NoError(t, err)
+	assert.Equal(t, 3, cnt)
+
+	for _, opts := range []UprobeMultiOpts{
+		{},
+		{Pattern: "SSL_*", Symbols: []string{"SSL_read"}},
+		{Symbols: []string{"SSL_read"}, Offsets: []uint64{0x10}},
+		{Pattern: "SSL_*", Cookies: []uint64{1}},
+		{Symbols: []string{"SSL_read", "SSL_write"}, Cookies: []uint64{1}},
+		{Offsets: []uint64{0x10}, RefCtrOffsets: []uint64{0, 0}},
+	} {
+		_, err := opts.sites()
+		assert.ErrorIs(t, err, syscall.EINVAL, "%+v", opts)
+	}
+}
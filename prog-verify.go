@@ -0,0 +1,203 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+//
+// Program verification
+//
+// Programs adopted from pins or other processes can be verified against
+// known values before they are trusted:
+//
+//   - the tag, computed by the kernel over the instructions as loaded (the
+//     same value "bpftool prog" shows).
+//   - a SHA-256 of the translated instructions, with map references cleared
+//     so it does not depend on map IDs. The translated instructions depend on
+//     the kernel, so the hash is only comparable on the same kernel. Reading
+//     them requires CAP_SYS_ADMIN (or CAP_PERFMON and CAP_BPF).
+//
+
+// ProgDigest holds the hex encoded digests of a program. Empty fields of an
+// expected ProgDigest are not verified.
+type ProgDigest struct {
+	Tag    string
+	SHA256 string
+}
+
+// ProgDigestMismatchError is returned when a program does not match the
+// expected digest.
+type ProgDigestMismatchError struct {
+	ProgID   uint32
+	Field    string // "tag" or "sha256"
+	Expected string
+	Actual   string
+}
+
+func (e *ProgDigestMismatchError) Error() string {
+	return fmt.Sprintf("prog id %d %s mismatch: expected %s, got %s", e.ProgID, e.Field, e.Expected, e.Actual)
+}
+
+// TagString returns the hex encoded program tag.
+func (i *BPFProgInfo) TagString() string {
+	return hex.EncodeToString(i.Tag[:])
+}
+
+// GetProgXlatedInsnsByFD returns the translated instructions of the program
+// with the given file descriptor.
+func GetProgXlatedInsnsByFD(fd int) ([]byte, error) {
+	info, err := GetProgInfoByFD(fd)
+	if err != nil {
+		return nil, err
+	}
+	if info.XlatedProgLen == 0 {
+		return nil, fmt.Errorf("failed to get prog instructions for fd %d: %w", fd, syscall.EPERM)
+	}
+
+	insnsC := C.calloc(C.size_t(info.XlatedProgLen), 1)
+	if insnsC == nil {
+		return nil, fmt.Errorf("failed to allocate instructions for prog fd %d", fd)
+	}
+	defer C.free(insnsC)
+
+	infoC := C.cgo_bpf_prog_info_new()
+	defer C.cgo_bpf_prog_info_free(infoC)
+
+	C.cgo_bpf_prog_info_set_xlated_prog_insns(infoC, insnsC, C.__u32(info.XlatedProgLen))
+	infoLenC := C.cgo_bpf_prog_info_size()
+	retC := C.bpf_prog_get_info_by_fd(C.int(fd), infoC, &infoLenC)
+	if retC < 0 {
+		return nil, fmt.Errorf("failed to get prog instructions for fd %d: %w", fd, syscall.Errno(-retC))
+	}
+
+	xlatedLen := min(info.XlatedProgLen, uint32(C.cgo_bpf_prog_info_xlated_prog_len(infoC)))
+
+	return C.GoBytes(insnsC, C.int(xlatedLen)), nil
+}
+
+// bpf_insn encoding
+const (
+	bpfInsnSize       = 8
+	bpfLdImm64        = 0x18 // BPF_LD | BPF_IMM | BPF_DW
+	bpfPseudoMapFD    = 1    // BPF_PSEUDO_MAP_FD
+	bpfPseudoMapValue = 2    // BPF_PSEUDO_MAP_VALUE
+	bpfPseudoMapIdx   = 5    // BPF_PSEUDO_MAP_IDX
+	bpfPseudoMapIdxVa = 6    // BPF_PSEUDO_MAP_IDX_VALUE
+)
+
+// progInsnsSHA256 hashes translated instructions, clearing the map IDs of
+// 64-bit immediate loads of maps.
+func progInsnsSHA256(insns []byte) [sha256.Size]byte {
+	normalized := make([]byte, len(insns))
+	copy(normalized, insns)
+
+	for off := 0; off+bpfInsnSize <= len(normalized); off += bpfInsnSize {
+		insn := normalized[off : off+bpfInsnSize]
+		if insn[0] != bpfLdImm64 {
+			continue
+		}
+
+		// src_reg is the high nibble of the registers byte on little endian
+		// hosts, the low one on big endian hosts
+		regs := insn[1]
+		srcReg := regs >> 4
+		if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+			srcReg = regs & 0x0f
+		}
+
+		switch srcReg {
+		case bpfPseudoMapFD, bpfPseudoMapValue, bpfPseudoMapIdx, bpfPseudoMapIdxVa:
+			binary.NativeEndian.PutUint32(insn[4:], 0)
+		}
+
+		// Skip the second half of the 16 bytes instruction
+		off += bpfInsnSize
+	}
+
+	return sha256.Sum256(normalized)
+}
+
+// GetProgDigestByFD returns the digests of the program with the given file
+// descriptor.
+func GetProgDigestByFD(fd int) (*ProgDigest, error) {
+	info, err := GetProgInfoByFD(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	insns, err := GetProgXlatedInsnsByFD(fd)
+	if err != nil {
+		return nil, err
+	}
+	sum := progInsnsSHA256(insns)
+
+	return &ProgDigest{
+		Tag:    info.TagString(),
+		SHA256: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// VerifyProgByFD verifies the program with the given file descriptor against
+// the expected digest, returning a *ProgDigestMismatchError on mismatch. The
+// instructions are only read if a SHA-256 is expected.
+func VerifyProgByFD(fd int, expected ProgDigest) error {
+	info, err := GetProgInfoByFD(fd)
+	if err != nil {
+		return err
+	}
+
+	if expected.Tag != "" && !strings.EqualFold(expected.Tag, info.TagString()) {
+		return &ProgDigestMismatchError{
+			ProgID:   info.ID,
+			Field:    "tag",
+			Expected: expected.Tag,
+			Actual:   info.TagString(),
+		}
+	}
+
+	if expected.SHA256 != "" {
+		insns, err := GetProgXlatedInsnsByFD(fd)
+		if err != nil {
+			return err
+		}
+
+		sum := progInsnsSHA256(insns)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(expected.SHA256, actual) {
+			return &ProgDigestMismatchError{
+				ProgID:   info.ID,
+				Field:    "sha256",
+				Expected: expected.SHA256,
+				Actual:   actual,
+			}
+		}
+	}
+
+	return nil
+}
+
+// Verify verifies the loaded program against the expected digest (see
+// VerifyProgByFD()).
+func (p *BPFProg) Verify(expected ProgDigest) error {
+	if err := p.checkNotUnloaded("verify"); err != nil {
+		return err
+	}
+
+	return VerifyProgByFD(p.FileDescriptor(), expected)
+}
+
+// Verify verifies the adopted program against the expected digest (see
+// VerifyProgByFD()).
+func (p *PinnedProg) Verify(expected ProgDigest) error {
+	return VerifyProgByFD(p.fd, expected)
+}
@@ -0,0 +1,35 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgInsnsSHA256(t *testing.T) {
+	// r1 = map[id:7] ll; r0 = 0; exit
+	insns := func(mapID byte, retVal byte) []byte {
+		return []byte{
+			0x18, 0x11, 0x00, 0x00, mapID, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0xb7, 0x00, 0x00, 0x00, retVal, 0x00, 0x00, 0x00,
+			0x95, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}
+	}
+
+	// Map IDs do not change the hash
+	assert.Equal(t, progInsnsSHA256(insns(7, 0)), progInsnsSHA256(insns(42, 0)))
+	assert.NotEqual(t, progInsnsSHA256(insns(7, 0)), progInsnsSHA256(insns(7, 1)))
+
+	// Plain 64-bit immediates are hashed as is
+	ld := insns(7, 0)
+	ld[1] = 0x00
+	other := insns(42, 0)
+	other[1] = 0x00
+	assert.NotEqual(t, progInsnsSHA256(ld), progInsnsSHA256(other))
+
+	// The input is not modified
+	in := insns(7, 0)
+	progInsnsSHA256(in)
+	assert.Equal(t, insns(7, 0), in)
+}
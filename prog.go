@@ -7,9 +7,13 @@ package libbpfgo
 import "C"
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"net"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -27,6 +31,10 @@ type BPFProg struct {
 }
 
 func (p *BPFProg) FileDescriptor() int {
+	if p.IsUnloaded() {
+		return -1
+	}
+
 	return int(C.bpf_program__fd(p.prog))
 }
 
@@ -36,6 +44,10 @@ func (p *BPFProg) GetFd() int {
 }
 
 func (p *BPFProg) Pin(path string) error {
+	if err := p.checkNotUnloaded("pin"); err != nil {
+		return err
+	}
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("invalid path: %s: %v", path, err)
@@ -55,6 +67,10 @@ func (p *BPFProg) Pin(path string) error {
 }
 
 func (p *BPFProg) Unpin(path string) error {
+	if err := p.checkNotUnloaded("unpin"); err != nil {
+		return err
+	}
+
 	pathC := C.CString(path)
 	defer C.free(unsafe.Pointer(pathC))
 
@@ -68,6 +84,79 @@ func (p *BPFProg) Unpin(path string) error {
 	return nil
 }
 
+// Unload releases the kernel resources of the loaded program, keeping the
+// module and its other programs loaded. The links of the program created by
+// the module attach methods are destroyed first; the program stays in the
+// kernel as long as it is pinned or referenced elsewhere (links created by
+// other means, prog arrays). An unloaded program can not be loaded again.
+func (p *BPFProg) Unload() error {
+	if p.module == nil || !p.module.loaded {
+		return fmt.Errorf("failed to unload program %s: program is not loaded", p.Name())
+	}
+	if p.IsUnloaded() {
+		return nil
+	}
+
+	fd := p.FileDescriptor()
+	if fd < 0 {
+		return fmt.Errorf("failed to unload program %s: program is not loaded", p.Name())
+	}
+
+	for _, link := range slices.Clone(p.module.links) {
+		if link.prog == nil || link.prog.prog != p.prog {
+			continue
+		}
+		if err := link.Destroy(); err != nil {
+			return fmt.Errorf("failed to unload program %s: failed to destroy link %s: %w", p.Name(), link.eventName, err)
+		}
+	}
+
+	// libbpf does not export bpf_program__unload() anymore, and closes the
+	// program fd when the object is closed. Replace the fd with /dev/null so
+	// the program reference is dropped while libbpf still owns a valid fd.
+	nullFD, err := syscall.Open("/dev/null", syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to unload program %s: %w", p.Name(), err)
+	}
+	defer syscall.Close(nullFD)
+
+	if err := syscall.Dup3(nullFD, fd, syscall.O_CLOEXEC); err != nil {
+		return fmt.Errorf("failed to unload program %s: %w", p.Name(), err)
+	}
+
+	if p.module.unloadedProgs == nil {
+		p.module.unloadedProgs = make(map[*C.struct_bpf_program]struct{})
+	}
+	p.module.unloadedProgs[p.prog] = struct{}{}
+
+	return nil
+}
+
+// IsUnloaded reports whether the program was unloaded with Unload().
+func (p *BPFProg) IsUnloaded() bool {
+	if p.module == nil {
+		return false
+	}
+
+	_, ok := p.module.unloadedProgs[p.prog]
+
+	return ok
+}
+
+// ErrProgUnloaded is returned by the operations on a program unloaded with
+// BPFProg.Unload(): the file descriptor libbpf still holds for it is not a
+// BPF program anymore.
+var ErrProgUnloaded = errors.New("program unloaded")
+
+// checkNotUnloaded fails the operation on a program unloaded with Unload().
+func (p *BPFProg) checkNotUnloaded(op string) error {
+	if !p.IsUnloaded() {
+		return nil
+	}
+
+	return fmt.Errorf("failed to %s program %s: %w", op, p.Name(), ErrProgUnloaded)
+}
+
 func (p *BPFProg) GetModule() *Module {
 	return p.module
 }
@@ -99,6 +188,22 @@ func (p *BPFProg) GetPinPath() string {
 	return p.PinPath()
 }
 
+// Info returns the kernel information about the loaded program, and its
+// verifier statistics if the module recorded them.
+func (p *BPFProg) Info() (*BPFProgInfo, error) {
+	if err := p.checkNotUnloaded("get info of"); err != nil {
+		return nil, err
+	}
+
+	info, err := GetProgInfoByFD(p.FileDescriptor())
+	if err != nil {
+		return nil, err
+	}
+	info.VerifierStats = p.module.verifierStats[p.Name()]
+
+	return info, nil
+}
+
 func (p *BPFProg) GetType() BPFProgType {
 	return BPFProgType(C.bpf_program__type(p.prog))
 }
@@ -128,17 +233,24 @@ func (p *BPFProg) Autoattach() bool {
 // for the attach target. You can specify the destination in BPF code
 // via the SEC() such as `SEC("fentry/some_kernel_func")`
 func (p *BPFProg) AttachGeneric() (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
 	linkC, errno := C.bpf_program__attach(p.prog)
 	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach program: %w", errno)
+		return nil, fmt.Errorf("failed to attach program: %w", classifyError(opAttach, errno, ""))
 	}
 
-	return &BPFLink{
+	bpfLink := &BPFLink{
 		link:      linkC,
 		prog:      p,
 		linkType:  Tracing,
 		eventName: fmt.Sprintf("tracing-%s", p.Name()),
-	}, nil
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
 }
 
 // SetAttachTarget can be used to specify the program and/or function to attach
@@ -155,6 +267,53 @@ func (p *BPFProg) SetAttachTarget(attachProgFD int, attachFuncName string) error
 	return nil
 }
 
+// SetAttachTargetProg sets another, already loaded, BPF program as the attach
+// target of a fentry/fexit/fmod_ret or freplace program. The function name
+// is resolved in the target program BTF, and defaults to the target program
+// main function if empty. It must be called before the module is loaded.
+func (p *BPFProg) SetAttachTargetProg(targetProg *BPFProg, funcName string) error {
+	if err := p.checkProgTarget(targetProg); err != nil {
+		return err
+	}
+	if p.module.loaded {
+		return fmt.Errorf("failed to set attach target for program %s: module already loaded", p.Name())
+	}
+	if funcName == "" {
+		funcName = targetProg.Name()
+	}
+
+	return p.SetAttachTarget(targetProg.FileDescriptor(), funcName)
+}
+
+// checkProgTarget validates targetProg as an attach target for prog-to-prog
+// tracing.
+func (p *BPFProg) checkProgTarget(targetProg *BPFProg) error {
+	if targetProg == nil {
+		return fmt.Errorf("invalid attach target for program %s: nil program", p.Name())
+	}
+
+	switch p.GetType() {
+	case BPFProgTypeTracing, BPFProgTypeExt:
+	default:
+		return fmt.Errorf("invalid attach target for program %s: %s programs can not trace other programs", p.Name(), p.GetType())
+	}
+
+	targetFD := targetProg.FileDescriptor()
+	if targetFD < 0 {
+		return fmt.Errorf("invalid attach target for program %s: program %s not loaded", p.Name(), targetProg.Name())
+	}
+
+	targetInfo, err := GetProgInfoByFD(targetFD)
+	if err != nil {
+		return fmt.Errorf("invalid attach target for program %s: %w", p.Name(), err)
+	}
+	if targetInfo.BTFID == 0 {
+		return fmt.Errorf("invalid attach target for program %s: program %s has no BTF", p.Name(), targetProg.Name())
+	}
+
+	return nil
+}
+
 // TODO: fix API to return error
 func (p *BPFProg) SetProgramType(progType BPFProgType) {
 	C.bpf_program__set_type(p.prog, C.enum_bpf_prog_type(int(progType)))
@@ -165,6 +324,47 @@ func (p *BPFProg) SetAttachType(attachType BPFAttachType) {
 	C.bpf_program__set_expected_attach_type(p.prog, C.enum_bpf_attach_type(int(attachType)))
 }
 
+// ExpectedAttachType returns the attach type the program is loaded for, from
+// its section name or SetAttachType().
+func (p *BPFProg) ExpectedAttachType() BPFAttachType {
+	return BPFAttachType(C.bpf_program__expected_attach_type(p.prog))
+}
+
+// Flags returns the flags the program is loaded with (BPF_F_SLEEPABLE,
+// BPF_F_XDP_HAS_FRAGS, ...).
+func (p *BPFProg) Flags() uint32 {
+	return uint32(C.bpf_program__flags(p.prog))
+}
+
+// SetFlags sets the flags the program is loaded with. It must be called
+// before the module is loaded.
+func (p *BPFProg) SetFlags(flags uint32) error {
+	retC := C.bpf_program__set_flags(p.prog, C.__u32(flags))
+	if retC < 0 {
+		return fmt.Errorf("failed to set flags of program %s: %w", p.Name(), syscall.Errno(-retC))
+	}
+
+	return nil
+}
+
+// SetIfIndex sets the network device the program is offloaded to. It must be
+// called before the module is loaded.
+func (p *BPFProg) SetIfIndex(ifindex uint32) {
+	C.bpf_program__set_ifindex(p.prog, C.__u32(ifindex))
+}
+
+// IfIndex returns the network device the loaded program is offloaded to, or
+// 0 if it is not offloaded. libbpf does not keep it, it is read from the
+// kernel.
+func (p *BPFProg) IfIndex() (uint32, error) {
+	info, err := p.Info()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.IfIndex, nil
+}
+
 // getCgroupDirFD returns a file descriptor for a given cgroup2 directory path
 func getCgroupDirFD(cgroupV2DirPath string) (int, error) {
 	// revive:disable
@@ -184,17 +384,28 @@ func getCgroupDirFD(cgroupV2DirPath string) (int, error) {
 
 // AttachCgroup attaches the BPFProg to a cgroup described by given fd.
 func (p *BPFProg) AttachCgroup(cgroupV2DirPath string) (*BPFLink, error) {
+	return p.AttachCgroupOpts(cgroupV2DirPath)
+}
+
+// AttachCgroupOpts attaches the BPFProg to a cgroup, as AttachCgroup() does.
+// It accepts the WithBefore, WithAfter and WithExpectedRevision options
+// (v6.12+).
+func (p *BPFProg) AttachCgroupOpts(cgroupV2DirPath string, opts ...AttachOption) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptOrder|attachOptRevision, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach cgroup on cgroupv2 %s to program %s: %w", cgroupV2DirPath, p.Name(), err)
+	}
+
 	cgroupDirFD, err := getCgroupDirFD(cgroupV2DirPath)
 	if err != nil {
 		return nil, err
 	}
 	defer syscall.Close(cgroupDirFD)
 
-	linkC, errno := C.bpf_program__attach_cgroup(p.prog, C.int(cgroupDirFD))
-	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach cgroup on cgroupv2 %s to program %s: %w", cgroupV2DirPath, p.Name(), errno)
-	}
-
 	// dirName will be used in bpfLink.eventName. eventName follows a format
 	// convention and is used to better identify link types and what they are
 	// linked with in case of errors or similar needs. Having eventName as:
@@ -202,13 +413,73 @@ func (p *BPFProg) AttachCgroup(cgroupV2DirPath string) (*BPFLink, error) {
 	// to be cgroup-progName-sys-fs-cgroup-unified instead.
 	dirName := strings.ReplaceAll(cgroupV2DirPath[1:], "/", "-")
 
+	bpfLink, err := p.attachCgroupFD(cgroupDirFD, dirName, o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach cgroup on cgroupv2 %s to program %s: %w", cgroupV2DirPath, p.Name(), err)
+	}
+
+	return bpfLink, nil
+}
+
+// AttachCgroupFD attaches the BPFProg to the cgroup v2 directory opened as
+// cgroupFD, as returned by helpers.OpenCgroupDir(). The file descriptor is
+// not closed.
+func (p *BPFProg) AttachCgroupFD(cgroupFD int) (*BPFLink, error) {
+	return p.AttachCgroupFDOpts(cgroupFD)
+}
+
+// AttachCgroupFDOpts attaches the BPFProg to the cgroup v2 directory opened as
+// cgroupFD, as AttachCgroupFD() does. It accepts the same options as
+// AttachCgroupOpts().
+func (p *BPFProg) AttachCgroupFDOpts(cgroupFD int, opts ...AttachOption) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptOrder|attachOptRevision, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach cgroup fd %d to program %s: %w", cgroupFD, p.Name(), err)
+	}
+
+	bpfLink, err := p.attachCgroupFD(cgroupFD, fmt.Sprintf("fd%d", cgroupFD), o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach cgroup fd %d to program %s: %w", cgroupFD, p.Name(), err)
+	}
+
+	return bpfLink, nil
+}
+
+func (p *BPFProg) attachCgroupFD(cgroupFD int, cgroupName string, o *attachOptions) (*BPFLink, error) {
+	var linkC *C.struct_bpf_link
+	var errno error
+
+	if o.set == 0 {
+		linkC, errno = C.bpf_program__attach_cgroup(p.prog, C.int(cgroupFD))
+	} else {
+		optsC, err := C.cgo_bpf_cgroup_opts_new(
+			C.__u32(o.orderFlags),
+			C.__u32(o.relativeFd),
+			C.__u32(o.relativeID),
+			C.__u64(o.expectedRevision),
+		)
+		if optsC == nil {
+			return nil, fmt.Errorf("failed to create bpf_cgroup_opts: %w", err)
+		}
+		defer C.cgo_bpf_cgroup_opts_free(optsC)
+
+		linkC, errno = C.bpf_program__attach_cgroup_opts(p.prog, C.int(cgroupFD), optsC)
+	}
+	if linkC == nil {
+		return nil, classifyError(opAttach, errno, "")
+	}
+
 	bpfLink := &BPFLink{
 		link:      linkC,
 		prog:      p,
 		linkType:  Cgroup,
-		eventName: fmt.Sprintf("cgroup-%s-%s", p.Name(), dirName),
+		eventName: fmt.Sprintf("cgroup-%s-%s", p.Name(), cgroupName),
 	}
-	p.module.links = append(p.module.links, bpfLink)
+	p.module.addLink(bpfLink)
 
 	return bpfLink, nil
 }
@@ -223,6 +494,10 @@ func (p *BPFProg) AttachCgroup(cgroupV2DirPath string) (*BPFLink, error) {
 //
 // Related kernel commit: https://github.com/torvalds/linux/commit/af6eea57437a
 func (p *BPFProg) AttachCgroupLegacy(cgroupV2DirPath string, attachType BPFAttachType) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
 	bpfLink, err := p.AttachCgroup(cgroupV2DirPath)
 	if err == nil {
 		return bpfLink, nil
@@ -241,7 +516,7 @@ func (p *BPFProg) AttachCgroupLegacy(cgroupV2DirPath string, attachType BPFAttac
 		C.int(attachType),
 	)
 	if retC < 0 {
-		return nil, fmt.Errorf("failed to attach (legacy) program %s to cgroupv2 %s: %w", p.Name(), cgroupV2DirPath, errno)
+		return nil, fmt.Errorf("failed to attach (legacy) program %s to cgroupv2 %s: %w", p.Name(), cgroupV2DirPath, classifyError(opAttach, errno, ""))
 	}
 
 	dirName := strings.ReplaceAll(cgroupV2DirPath[1:], "/", "-")
@@ -258,6 +533,7 @@ func (p *BPFProg) AttachCgroupLegacy(cgroupV2DirPath string, attachType BPFAttac
 		linkType: CgroupLegacy,
 		legacy:   bpfLinkLegacy,
 	}
+	p.module.addLink(fakeBpfLink)
 
 	return fakeBpfLink, nil
 }
@@ -269,6 +545,10 @@ func (p *BPFProg) AttachCgroupLegacy(cgroupV2DirPath string, attachType BPFAttac
 // users don´t need to distinguish between regular and legacy cgroup
 // detachments).
 func (p *BPFProg) DetachCgroupLegacy(cgroupV2DirPath string, attachType BPFAttachType) error {
+	if err := p.checkNotUnloaded("detach"); err != nil {
+		return err
+	}
+
 	cgroupDirFD, err := getCgroupDirFD(cgroupV2DirPath)
 	if err != nil {
 		return err
@@ -287,198 +567,892 @@ func (p *BPFProg) DetachCgroupLegacy(cgroupV2DirPath string, attachType BPFAttac
 	return nil
 }
 
-func (p *BPFProg) AttachXDP(deviceName string) (*BPFLink, error) {
-	iface, err := net.InterfaceByName(deviceName)
+// sockMapAttachType returns the attach type of a socket map verdict or
+// parser program, from its section name (SEC("sk_msg"),
+// SEC("sk_skb/stream_verdict"), SEC("sk_skb/stream_parser"),
+// SEC("sk_skb/verdict")).
+func (p *BPFProg) sockMapAttachType() (BPFAttachType, error) {
+	attachType := p.ExpectedAttachType()
+	switch attachType {
+	case BPFAttachTypeSKMSGVerdict,
+		BPFAttachTypeSKSKBStreamVerdict,
+		BPFAttachTypeSKSKBStreamParser,
+		BPFAttachTypeSKSKBVerdict:
+		return attachType, nil
+	}
+
+	return 0, fmt.Errorf("program %s (%s) is not a socket map program", p.Name(), attachType)
+}
+
+// AttachSockMap attaches a sk_msg or sk_skb program to the given sockmap or
+// sockhash, at the hook of its section name. The program is attached with a
+// BPF link if the kernel (v6.10+) and libbpf (v1.5+, see HasSockMapLink)
+// support it, falling back to BPF_PROG_ATTACH otherwise. Like with
+// AttachCgroupLegacy(), the fallback returns an emulated BPFLink, whose
+// Destroy() detaches the program with BPF_PROG_DETACH.
+func (p *BPFProg) AttachSockMap(sockMap *BPFMap) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	attachType, err := p.sockMapAttachType()
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach program %s to map %s: %w", p.Name(), sockMap.Name(), err)
+	}
+	if sockMap.Type() != MapTypeSockMap && sockMap.Type() != MapTypeSockHash {
+		return nil, fmt.Errorf("failed to attach program %s: map %s is %s, not a sockmap or sockhash", p.Name(), sockMap.Name(), sockMap.Type())
+	}
+
+	errLink := fmt.Errorf("libbpf %s: %w", LibbpfVersionString(), ErrNotSupportedByLibbpf)
+	if HasSockMapLink {
+		linkC, errno := C.cgo_bpf_program__attach_sockmap(p.prog, C.int(sockMap.FileDescriptor()))
+		if linkC != nil {
+			bpfLink := &BPFLink{
+				link:      linkC,
+				prog:      p,
+				linkType:  SockMap,
+				eventName: fmt.Sprintf("sockmap-%s-%s", p.Name(), sockMap.Name()),
+			}
+			p.module.addLink(bpfLink)
+
+			return bpfLink, nil
+		}
+		errLink = classifyError(opAttach, errno, "")
+	}
+
+	// Try the legacy attachment method before fully failing
+	if err := p.AttachGenericFD(sockMap.FileDescriptor(), attachType, BPFFNone); err != nil {
+		return nil, fmt.Errorf("failed to attach program %s to map %s: %w", p.Name(), sockMap.Name(), errors.Join(errLink, err))
+	}
+
+	fakeBpfLink := &BPFLink{
+		link:      nil, // detach/destroy made with progfd
+		prog:      p,
+		eventName: fmt.Sprintf("sockmap-%s-%s", p.Name(), sockMap.Name()),
+		// info bellow needed for detach (there isn't a real ebpf link)
+		linkType: SockMapLegacy,
+		legacy: &bpfLinkLegacy{
+			attachType: attachType,
+			sockMap:    sockMap,
+		},
+	}
+	p.module.addLink(fakeBpfLink)
+
+	return fakeBpfLink, nil
+}
+
+// DetachSockMapLegacy detaches the BPFProg from the sockmap or sockhash it was
+// attached to with BPF_PROG_ATTACH. Like DetachCgroupLegacy(), it is called by
+// the Destroy() of the emulated BPFLink returned by AttachSockMap().
+func (p *BPFProg) DetachSockMapLegacy(sockMap *BPFMap) error {
+	if err := p.checkNotUnloaded("detach"); err != nil {
+		return err
+	}
+
+	attachType, err := p.sockMapAttachType()
+	if err != nil {
+		return fmt.Errorf("failed to detach (legacy) program %s from map %s: %w", p.Name(), sockMap.Name(), err)
+	}
+
+	if err := p.DetachGenericFD(sockMap.FileDescriptor(), attachType); err != nil {
+		return fmt.Errorf("failed to detach (legacy) program %s from map %s: %w", p.Name(), sockMap.Name(), err)
+	}
+
+	return nil
+}
+
+func (p *BPFProg) AttachXDP(deviceName string) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	iface, err := net.InterfaceByName(deviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find device by name %s: %w", deviceName, err)
+	}
+
+	linkC, errno := C.bpf_program__attach_xdp(p.prog, C.int(iface.Index))
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach xdp on device %s to program %s: %w", deviceName, p.Name(), classifyError(opAttach, errno, ""))
+	}
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		prog:      p,
+		linkType:  XDP,
+		eventName: fmt.Sprintf("xdp-%s-%s", p.Name(), deviceName),
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// AttachTCX attaches the program to the TCX hook of the device. The ingress
+// or egress direction is given by the program attach type, usually set with
+// SEC("tcx/ingress") or SEC("tcx/egress"). The program is attached last.
+func (p *BPFProg) AttachTCX(deviceName string) (*BPFLink, error) {
+	return p.AttachTCXOpts(deviceName)
+}
+
+// AttachTCXOpts attaches the program to the TCX hook of the device, as
+// AttachTCX() does. It accepts the WithBefore, WithAfter and
+// WithExpectedRevision options.
+func (p *BPFProg) AttachTCXOpts(deviceName string, opts ...AttachOption) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptOrder|attachOptRevision, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach tcx on device %s to program %s: %w", deviceName, p.Name(), err)
+	}
+
+	iface, err := net.InterfaceByName(deviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find device by name %s: %w", deviceName, err)
+	}
+
+	optsC, errno := C.cgo_bpf_tcx_opts_new(
+		C.__u32(o.orderFlags),
+		C.__u32(o.relativeFd),
+		C.__u32(o.relativeID),
+		C.__u64(o.expectedRevision),
+	)
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create bpf_tcx_opts: %w", errno)
+	}
+	defer C.cgo_bpf_tcx_opts_free(optsC)
+
+	linkC, errno := C.bpf_program__attach_tcx(p.prog, C.int(iface.Index), optsC)
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach tcx on device %s to program %s: %w", deviceName, p.Name(), classifyError(opAttach, errno, ""))
+	}
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		prog:      p,
+		linkType:  TCX,
+		eventName: fmt.Sprintf("tcx-%s-%s", p.Name(), deviceName),
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// AttachTracepoint attaches the BPFProg to the given tracepoint.
+//
+// libbpf reads the id of the tracepoint from a single tracefs mount point.
+// If it fails to, the id is looked up at the other ones too (see
+// SetTracefsPath()), and the tracepoint attached through a perf event opened
+// with it.
+func (p *BPFProg) AttachTracepoint(category, name string) (*BPFLink, error) {
+	return p.AttachTracepointOpts(category, name)
+}
+
+// AttachTracepointOpts attaches the BPFProg to the given tracepoint, as
+// AttachTracepoint() does. It accepts the WithCookie option.
+func (p *BPFProg) AttachTracepointOpts(category, name string, opts ...AttachOption) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptCookie, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach tracepoint %s to program %s: %w", name, p.Name(), err)
+	}
+
+	tpCategoryC := C.CString(category)
+	defer C.free(unsafe.Pointer(tpCategoryC))
+	tpNameC := C.CString(name)
+	defer C.free(unsafe.Pointer(tpNameC))
+
+	optsC, errno := C.cgo_bpf_tracepoint_opts_new(C.__u64(o.cookie))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create tracepoint_opts for program %s: %w", p.Name(), errno)
+	}
+	defer C.cgo_bpf_tracepoint_opts_free(optsC)
+
+	linkC, errno := C.bpf_program__attach_tracepoint_opts(p.prog, tpCategoryC, tpNameC, optsC)
+	if linkC == nil {
+		err := classifyError(opAttach, errno, "")
+		if errors.Is(errno, syscall.ENOENT) || errors.Is(errno, syscall.EACCES) {
+			fallbackC, fallbackErr := p.attachTracepointPerf(category, name, o.cookie)
+			if fallbackErr != nil {
+				err = errors.Join(err, fallbackErr)
+			}
+			linkC = fallbackC
+		}
+		if linkC == nil {
+			return nil, fmt.Errorf("failed to attach tracepoint %s to program %s: %w", name, p.Name(), err)
+		}
+	}
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		prog:      p,
+		linkType:  Tracepoint,
+		eventName: name,
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// attachTracepointPerf attaches the BPFProg to the tracepoint through a perf
+// event opened with its id, looked up at each tracefs mount point.
+func (p *BPFProg) attachTracepointPerf(category, name string, cookie uint64) (*C.struct_bpf_link, error) {
+	id, err := readTracepointID(tracefsPaths(), category, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fdC, errno := C.cgo_perf_event_open_tracepoint(C.__u64(id))
+	if fdC < 0 {
+		return nil, fmt.Errorf("failed to open perf event of tracepoint %s:%s: %w", category, name, errno)
+	}
+
+	optsC, errno := C.cgo_bpf_perf_event_opts_new(C.__u64(cookie), false)
+	if optsC == nil {
+		_ = syscall.Close(int(fdC))
+		return nil, fmt.Errorf("failed to create perf_event_opts for program %s: %w", p.Name(), errno)
+	}
+	defer C.cgo_bpf_perf_event_opts_free(optsC)
+
+	// The link owns the perf event once attached
+	linkC, errno := C.bpf_program__attach_perf_event_opts(p.prog, fdC, optsC)
+	if linkC == nil {
+		_ = syscall.Close(int(fdC))
+		return nil, fmt.Errorf("failed to attach perf event of tracepoint %s:%s: %w", category, name, errno)
+	}
+
+	return linkC, nil
+}
+
+// AttachRawTracepoint attaches the BPFProg to the given raw tracepoint. See
+// AttachRawTracepointOpts() to set a cookie.
+func (p *BPFProg) AttachRawTracepoint(tpEvent string) (*BPFLink, error) {
+	return p.AttachRawTracepointOpts(tpEvent, RawTracepointOpts{})
+}
+
+// RawTracepointOpts mirrors the C structure bpf_raw_tracepoint_opts.
+type RawTracepointOpts struct {
+	Cookie uint64
+}
+
+// AttachRawTracepointOpts attaches the BPFProg to the given raw tracepoint
+// with the given options. The cookie can be read by the program with the
+// bpf_get_attach_cookie() helper, allowing a single program to tell apart
+// multiple attachments. Cookies need libbpf v1.4, see HasRawTracepointCookie.
+func (p *BPFProg) AttachRawTracepointOpts(tpEvent string, opts RawTracepointOpts) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	tpEventC := C.CString(tpEvent)
+	defer C.free(unsafe.Pointer(tpEventC))
+
+	var (
+		linkC *C.struct_bpf_link
+		errno error
+	)
+	switch {
+	case HasRawTracepointCookie:
+		optsC, err := C.cgo_bpf_raw_tracepoint_opts_new(C.ulonglong(opts.Cookie))
+		if optsC == nil {
+			return nil, fmt.Errorf("failed to create raw_tracepoint_opts to program %s: %w", p.Name(), err)
+		}
+		defer C.cgo_bpf_raw_tracepoint_opts_free(optsC)
+
+		linkC, errno = C.cgo_bpf_program__attach_raw_tracepoint_opts(p.prog, tpEventC, optsC)
+	case opts.Cookie != 0:
+		return nil, fmt.Errorf("failed to attach raw tracepoint %s to program %s: cookie: libbpf %s: %w", tpEvent, p.Name(), LibbpfVersionString(), ErrNotSupportedByLibbpf)
+	default:
+		linkC, errno = C.bpf_program__attach_raw_tracepoint(p.prog, tpEventC)
+	}
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach raw tracepoint %s to program %s: %w", tpEvent, p.Name(), classifyError(opAttach, errno, ""))
+	}
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		prog:      p,
+		linkType:  RawTracepoint,
+		eventName: tpEvent,
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// AttachProgramFentry attaches a fentry/fexit/fmod_ret or freplace program to
+// a function of another BPF program (funcName defaults to the target program
+// main function if empty).
+//
+// The kernel binds fentry/fexit/fmod_ret programs to their target when they
+// are loaded, so the target must have been set with SetAttachTargetProg()
+// beforehand; it is then verified to match targetProg. freplace programs may
+// be attached to any compatible target.
+func (p *BPFProg) AttachProgramFentry(targetProg *BPFProg, funcName string) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkProgTarget(targetProg); err != nil {
+		return nil, err
+	}
+	if funcName == "" {
+		funcName = targetProg.Name()
+	}
+
+	var linkC *C.struct_bpf_link
+	var errno error
+
+	if p.GetType() == BPFProgTypeExt {
+		funcNameC := C.CString(funcName)
+		defer C.free(unsafe.Pointer(funcNameC))
+
+		linkC, errno = C.bpf_program__attach_freplace(p.prog, C.int(targetProg.FileDescriptor()), funcNameC)
+	} else {
+		info, err := p.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach program %s to %s: %w", p.Name(), targetProg.Name(), err)
+		}
+		targetInfo, err := targetProg.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach program %s to %s: %w", p.Name(), targetProg.Name(), err)
+		}
+		if info.AttachBTFObjID != targetInfo.BTFID {
+			return nil, fmt.Errorf("failed to attach program %s to %s: program was not loaded with %s as attach target", p.Name(), targetProg.Name(), targetProg.Name())
+		}
+
+		linkC, errno = C.bpf_program__attach_trace(p.prog)
+	}
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach program %s to %s/%s: %w", p.Name(), targetProg.Name(), funcName, classifyError(opAttach, errno, ""))
+	}
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		prog:      p,
+		linkType:  Tracing,
+		eventName: fmt.Sprintf("tracing-%s-%s-%s", p.Name(), targetProg.Name(), funcName),
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+func (p *BPFProg) AttachLSM() (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	linkC, errno := C.bpf_program__attach_lsm(p.prog)
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach lsm to program %s: %w", p.Name(), classifyError(opAttach, errno, ""))
+	}
+
+	bpfLink := &BPFLink{
+		link:     linkC,
+		prog:     p,
+		linkType: LSM,
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// AttachPerfEvent attaches the BPFProg to the perf event opened as fd, which
+// the returned link owns, with a BPF link if the kernel supports perf links
+// (v5.15+), PERF_EVENT_IOC_SET_BPF otherwise.
+//
+// helpers.OpenPerfEvents() and helpers.OpenCgroupPerfEvents() open a perf
+// event per CPU, for a process or a cgroup, each attached to separately.
+func (p *BPFProg) AttachPerfEvent(fd int) (*BPFLink, error) {
+	return p.AttachPerfEventOpts(fd)
+}
+
+// AttachPerfEventOpts attaches the BPFProg to the perf event opened as fd, as
+// AttachPerfEvent() does. It accepts the WithCookie and WithAttachMode
+// options:
+//
+//   - ProbeAttachModeDefault uses a BPF link if the kernel supports perf
+//     links (v5.15+), PERF_EVENT_IOC_SET_BPF otherwise.
+//   - ProbeAttachModePerf always uses PERF_EVENT_IOC_SET_BPF.
+//   - ProbeAttachModeLink fails with ErrNotSupportedByKernel if the kernel
+//     does not support perf links.
+func (p *BPFProg) AttachPerfEventOpts(fd int, opts ...AttachOption) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkNotSleepable("perf event"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptCookie|attachOptAttachMode, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach perf event to program %s: %w", p.Name(), err)
+	}
+	if o.attachMode == ProbeAttachModeLegacy {
+		return nil, fmt.Errorf("failed to attach perf event to program %s: invalid attach mode %s", p.Name(), o.attachMode)
+	}
+
+	optsC, errno := C.cgo_bpf_perf_event_opts_new(C.__u64(o.cookie), C.bool(o.attachMode == ProbeAttachModePerf))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create perf_event_opts for program %s: %w", p.Name(), errno)
+	}
+	defer C.cgo_bpf_perf_event_opts_free(optsC)
+
+	// libbpf silently falls back to the ioctl without perf links
+	if o.attachMode == ProbeAttachModeLink && !p.supportsPerfLink() {
+		return nil, fmt.Errorf("failed to attach perf event to program %s: perf links: %w", p.Name(), ErrNotSupportedByKernel)
+	}
+
+	linkC, errno := C.bpf_program__attach_perf_event_opts(p.prog, C.int(fd), optsC)
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach perf event to program %s: %w", p.Name(), classifyError(opAttach, errno, ""))
+	}
+
+	bpfLink := &BPFLink{
+		link:     linkC,
+		prog:     p,
+		linkType: PerfEvent,
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// supportsPerfLink probes the kernel support of perf links for the program,
+// like libbpf does: creating a perf link to an invalid fd fails with EBADF
+// only if perf links are supported.
+func (p *BPFProg) supportsPerfLink() bool {
+	fdC := C.bpf_link_create(C.int(p.FileDescriptor()), -1, C.BPF_PERF_EVENT, nil)
+	if fdC >= 0 {
+		_ = syscall.Close(int(fdC))
+		return true
+	}
+
+	return syscall.Errno(-fdC) == syscall.EBADF
+}
+
+//
+// Kprobe and Kretprobe
+//
+
+type attachTo struct {
+	symName    string
+	symAddr    uint64
+	isRet      bool
+	cookie     uint64
+	attachMode ProbeAttachMode
+}
+
+// attachKprobeCommon is a common function for attaching kprobe and kretprobe.
+func (p *BPFProg) attachKprobeCommon(a attachTo) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	// Only uprobes can run sleepable kprobe programs
+	if err := p.checkNotSleepable("kprobe"); err != nil {
+		return nil, err
+	}
+	if err := checkLegacyProbeTracefs(a.attachMode); err != nil {
+		return nil, fmt.Errorf("failed to attach to %v: %w", a, err)
+	}
+
+	// Create kprobe_opts.
+	optsC, errno := C.cgo_bpf_kprobe_opts_new(
+		C.ulonglong(a.cookie), // bpf cookie
+		C.size_t(a.symAddr),   // offset within the symbol, or address if no symbol
+		C.bool(a.isRet),       // is kretprobe or kprobe
+		C.int(a.attachMode),   // attach mode
+	)
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create kprobe_opts of %v: %w", a, errno)
+	}
+	defer C.cgo_bpf_kprobe_opts_free(optsC)
+
+	// Create kprobe symbol name.
+	symNameC := C.CString(a.symName)
+	defer C.free(unsafe.Pointer(symNameC))
+
+	// Create kprobe link.
+	var linkC *C.struct_bpf_link
+	linkC, errno = C.bpf_program__attach_kprobe_opts(p.prog, symNameC, optsC)
+	if linkC == nil {
+		return nil, kprobeAttachError(a, errno)
+	}
+
+	linkType := Kprobe
+	if a.isRet {
+		linkType = Kretprobe
+	}
+
+	eventName := a.symName
+	if eventName == "" {
+		eventName = fmt.Sprintf("%d", a.symAddr)
+	} else if a.symAddr != 0 {
+		eventName = fmt.Sprintf("%s+0x%x", a.symName, a.symAddr)
+	}
+
+	// Create bpfLink and append it to the module.
+	bpfLink := &BPFLink{
+		link:      linkC,     // linkC is a pointer to a struct bpf_link
+		prog:      p,         // p is a pointer to the related BPFProg
+		linkType:  linkType,  // linkType is a BPFLinkType
+		eventName: eventName, // eventName is a string
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// kprobeAttachError returns the error of a failed kprobe attachment.
+func kprobeAttachError(a attachTo, errno error) error {
+	if errors.Is(errno, syscall.EILSEQ) && a.symName != "" {
+		return fmt.Errorf("failed to attach to %s+0x%x: offset is not an instruction boundary: %w", a.symName, a.symAddr, errno)
+	}
+
+	return fmt.Errorf("failed to attach to %v: %w", a, classifyError(opAttach, errno, ""))
+}
+
+// attachKprobeSymbol attaches a kprobe or kretprobe to the given symbol name
+// with the options given.
+func (p *BPFProg) attachKprobeSymbol(symbol string, isRet bool, opts []AttachOption) (*BPFLink, error) {
+	o, err := newAttachOptions(attachOptCookie|attachOptOffset|attachOptAttachMode, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach k(ret)probe %s to program %s: %w", symbol, p.Name(), err)
+	}
+
+	symbol, offset, err := splitSymbolOffset(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach k(ret)probe to program %s: %w", p.Name(), err)
+	}
+	if offset != 0 && o.offset != 0 {
+		return nil, fmt.Errorf("failed to attach k(ret)probe %s to program %s: offset given twice", symbol, p.Name())
+	}
+	offset += o.offset
+	if isRet && offset != 0 {
+		return nil, fmt.Errorf("failed to attach kretprobe %s+0x%x to program %s: kretprobes are attached at the function entry", symbol, offset, p.Name())
+	}
+
+	a := attachTo{
+		symName:    symbol,
+		symAddr:    offset,
+		isRet:      isRet,
+		cookie:     o.cookie,
+		attachMode: o.attachMode,
+	}
+	return p.attachKprobeCommon(a)
+}
+
+// AttachKprobe attaches the BPFProgram to the given symbol name. The probe is
+// placed at an instruction within the function with the "symbol+offset"
+// notation ("tcp_connect+0x1a", hexadecimal or decimal), such as an offset
+// computed from the DWARF line info.
+func (p *BPFProg) AttachKprobe(symbol string) (*BPFLink, error) {
+	return p.attachKprobeSymbol(symbol, false, nil)
+}
+
+// AttachKprobeOpts attaches the BPFProgram to the given symbol name, as
+// AttachKprobe() does. The probe is also placed within the function with
+// WithOffset. It accepts the WithCookie, WithOffset and WithAttachMode
+// options.
+func (p *BPFProg) AttachKprobeOpts(symbol string, opts ...AttachOption) (*BPFLink, error) {
+	return p.attachKprobeSymbol(symbol, false, opts)
+}
+
+// AttachKretprobe attaches the BPFProgram to the given symbol name (for return).
+func (p *BPFProg) AttachKretprobe(symbol string) (*BPFLink, error) {
+	return p.attachKprobeSymbol(symbol, true, nil)
+}
+
+// AttachKretprobeOpts attaches the BPFProgram to the given symbol name (for
+// return). It accepts the same options as AttachKprobeOpts(), but no offset.
+func (p *BPFProg) AttachKretprobeOpts(symbol string, opts ...AttachOption) (*BPFLink, error) {
+	return p.attachKprobeSymbol(symbol, true, opts)
+}
+
+// AttachKprobeOnOffset attaches the BPFProgram to the given offset, the
+// absolute address of the instruction. See AttachKprobe() to attach at an
+// offset within a function.
+func (p *BPFProg) AttachKprobeOffset(offset uint64) (*BPFLink, error) {
+	return p.attachKprobeAddress(offset, false, nil)
+}
+
+// AttachKprobeOffsetOpts attaches the BPFProgram to the given offset, as
+// AttachKprobeOffset() does. It accepts the WithCookie and WithAttachMode
+// options.
+func (p *BPFProg) AttachKprobeOffsetOpts(offset uint64, opts ...AttachOption) (*BPFLink, error) {
+	return p.attachKprobeAddress(offset, false, opts)
+}
+
+// AttachKretprobeOnOffset attaches the BPFProgram to the given offset (for
+// return).
+func (p *BPFProg) AttachKretprobeOnOffset(offset uint64) (*BPFLink, error) {
+	return p.attachKprobeAddress(offset, true, nil)
+}
+
+// AttachKretprobeOnOffsetOpts attaches the BPFProgram to the given offset
+// (for return). It accepts the same options as AttachKprobeOffsetOpts().
+func (p *BPFProg) AttachKretprobeOnOffsetOpts(offset uint64, opts ...AttachOption) (*BPFLink, error) {
+	return p.attachKprobeAddress(offset, true, opts)
+}
+
+// attachKprobeAddress attaches a kprobe or kretprobe to the given address
+// with the options given.
+func (p *BPFProg) attachKprobeAddress(addr uint64, isRet bool, opts []AttachOption) (*BPFLink, error) {
+	o, err := newAttachOptions(attachOptCookie|attachOptAttachMode, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach k(ret)probe 0x%x to program %s: %w", addr, p.Name(), err)
+	}
+
+	a := attachTo{
+		symAddr:    addr,
+		isRet:      isRet,
+		cookie:     o.cookie,
+		attachMode: o.attachMode,
+	}
+	return p.attachKprobeCommon(a)
+}
+
+//
+// Ksyscall and Kretsyscall
+//
+
+// AttachKsyscall attaches the BPFProgram to the entry of the given syscall,
+// such as a SEC("ksyscall/openat") program. The syscall is named without
+// prefix ("openat", not "__x64_sys_openat"): libbpf resolves the kernel
+// function of the syscall, whose name depends on the architecture and on
+// the kernel having syscall wrappers. It accepts the WithCookie option.
+func (p *BPFProg) AttachKsyscall(syscallName string, opts ...AttachOption) (*BPFLink, error) {
+	return p.attachKsyscall(syscallName, false, opts)
+}
+
+// AttachKretsyscall attaches the BPFProgram to the exit of the given syscall.
+// See AttachKsyscall().
+func (p *BPFProg) AttachKretsyscall(syscallName string, opts ...AttachOption) (*BPFLink, error) {
+	return p.attachKsyscall(syscallName, true, opts)
+}
+
+func (p *BPFProg) attachKsyscall(syscallName string, isRet bool, opts []AttachOption) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptCookie, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find device by name %s: %w", deviceName, err)
+		return nil, fmt.Errorf("failed to attach k(ret)syscall %s to program %s: %w", syscallName, p.Name(), err)
+	}
+	if syscallName == "" {
+		return nil, fmt.Errorf("failed to attach k(ret)syscall to program %s: no syscall name: %w", p.Name(), syscall.EINVAL)
 	}
 
-	linkC, errno := C.bpf_program__attach_xdp(p.prog, C.int(iface.Index))
-	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach xdp on device %s to program %s: %w", deviceName, p.Name(), errno)
+	// Syscalls are kprobed, and kprobes can not run sleepable programs
+	if err := p.checkNotSleepable("ksyscall"); err != nil {
+		return nil, err
 	}
 
-	bpfLink := &BPFLink{
-		link:      linkC,
-		prog:      p,
-		linkType:  XDP,
-		eventName: fmt.Sprintf("xdp-%s-%s", p.Name(), deviceName),
+	optsC, errno := C.cgo_bpf_ksyscall_opts_new(C.__u64(o.cookie), C.bool(isRet))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create ksyscall_opts for program %s: %w", p.Name(), errno)
 	}
-	p.module.links = append(p.module.links, bpfLink)
+	defer C.cgo_bpf_ksyscall_opts_free(optsC)
 
-	return bpfLink, nil
-}
+	syscallNameC := C.CString(syscallName)
+	defer C.free(unsafe.Pointer(syscallNameC))
 
-func (p *BPFProg) AttachTracepoint(category, name string) (*BPFLink, error) {
-	tpCategoryC := C.CString(category)
-	defer C.free(unsafe.Pointer(tpCategoryC))
-	tpNameC := C.CString(name)
-	defer C.free(unsafe.Pointer(tpNameC))
+	linkType := Ksyscall
+	if isRet {
+		linkType = Kretsyscall
+	}
 
-	linkC, errno := C.bpf_program__attach_tracepoint(p.prog, tpCategoryC, tpNameC)
+	linkC, errno := C.bpf_program__attach_ksyscall(p.prog, syscallNameC, optsC)
 	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach tracepoint %s to program %s: %w", name, p.Name(), errno)
+		return nil, fmt.Errorf("failed to attach k(ret)syscall %s to program %s: %w", syscallName, p.Name(), classifyError(opAttach, errno, ""))
 	}
 
 	bpfLink := &BPFLink{
 		link:      linkC,
 		prog:      p,
-		linkType:  Tracepoint,
-		eventName: name,
+		linkType:  linkType,
+		eventName: syscallName,
 	}
-	p.module.links = append(p.module.links, bpfLink)
+	p.module.addLink(bpfLink)
 
 	return bpfLink, nil
 }
 
-func (p *BPFProg) AttachRawTracepoint(tpEvent string) (*BPFLink, error) {
-	tpEventC := C.CString(tpEvent)
-	defer C.free(unsafe.Pointer(tpEventC))
-
-	linkC, errno := C.bpf_program__attach_raw_tracepoint(p.prog, tpEventC)
-	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach raw tracepoint %s to program %s: %w", tpEvent, p.Name(), errno)
+// AttachKprobeMulti attaches the BPFProgram to all the given kernel functions
+// at once, through a single kprobe_multi link. If retprobe is true, it is
+// attached to the functions return instead.
+//
+// The kernel functions can be enumerated with
+// helpers.GetTraceableKernelFunctions(), which already leaves out the
+// functions that can not be probed.
+func (p *BPFProg) AttachKprobeMulti(symbols []string, retprobe bool) (*BPFLink, error) {
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("failed to attach kprobe multi to program %s: no symbols given", p.Name())
 	}
 
-	bpfLink := &BPFLink{
-		link:      linkC,
-		prog:      p,
-		linkType:  RawTracepoint,
-		eventName: tpEvent,
+	linkType := KprobeMulti
+	if retprobe {
+		linkType = KretprobeMulti
 	}
-	p.module.links = append(p.module.links, bpfLink)
 
-	return bpfLink, nil
+	return doAttachKprobeMulti(p, linkType, KprobeMultiOpts{Symbols: symbols})
 }
 
-func (p *BPFProg) AttachLSM() (*BPFLink, error) {
-	linkC, errno := C.bpf_program__attach_lsm(p.prog)
-	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach lsm to program %s: %w", p.Name(), errno)
-	}
-
-	bpfLink := &BPFLink{
-		link:     linkC,
-		prog:     p,
-		linkType: LSM,
-	}
-	p.module.links = append(p.module.links, bpfLink)
-
-	return bpfLink, nil
+// KprobeMultiOpts are the kernel functions of a kprobe_multi link, given by
+// exactly one of Pattern, Symbols and Addrs.
+type KprobeMultiOpts struct {
+	// Pattern is a glob ('*' and '?') of the functions to probe, matched by
+	// libbpf against the traceable functions (available_filter_functions).
+	Pattern string
+	// Symbols are the functions to probe.
+	Symbols []string
+	// Addrs are the addresses of the functions to probe.
+	Addrs []uint64
+	// Cookies are the cookies of the Symbols or Addrs, which the program
+	// reads with the bpf_get_attach_cookie() helper.
+	Cookies []uint64
 }
 
-func (p *BPFProg) AttachPerfEvent(fd int) (*BPFLink, error) {
-	linkC, errno := C.bpf_program__attach_perf_event(p.prog, C.int(fd))
-	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach perf event to program %s: %w", p.Name(), errno)
+// sites returns the number of functions given by Symbols or Addrs, 0 for a
+// Pattern.
+func (o *KprobeMultiOpts) sites() (int, error) {
+	given := 0
+	for _, set := range []bool{o.Pattern != "", len(o.Symbols) > 0, len(o.Addrs) > 0} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return 0, fmt.Errorf("exactly one of Pattern, Symbols and Addrs must be given: %w", syscall.EINVAL)
 	}
 
-	bpfLink := &BPFLink{
-		link:     linkC,
-		prog:     p,
-		linkType: PerfEvent,
+	cnt := max(len(o.Symbols), len(o.Addrs))
+	if o.Pattern != "" && len(o.Cookies) > 0 {
+		return 0, fmt.Errorf("cookies need symbols or addrs: %w", syscall.EINVAL)
+	}
+	if len(o.Cookies) > 0 && len(o.Cookies) != cnt {
+		return 0, fmt.Errorf("%d Cookies for %d functions: %w", len(o.Cookies), cnt, syscall.EINVAL)
 	}
-	p.module.links = append(p.module.links, bpfLink)
 
-	return bpfLink, nil
+	return cnt, nil
 }
 
+// AttachKprobeMultiOpts attaches the BPFProgram to the entry of the kernel
+// functions matched by a pattern, or given by name or address, through a
+// single kprobe_multi link (v5.18):
 //
-// Kprobe and Kretprobe
-//
+//	link, err := prog.AttachKprobeMultiOpts(KprobeMultiOpts{Pattern: "tcp_*"})
+func (p *BPFProg) AttachKprobeMultiOpts(opts KprobeMultiOpts) (*BPFLink, error) {
+	return doAttachKprobeMulti(p, KprobeMulti, opts)
+}
 
-type attachTo struct {
-	symName string
-	symAddr uint64
-	isRet   bool
+// AttachKretprobeMultiOpts attaches the BPFProgram to the return of the
+// kernel functions. See AttachKprobeMultiOpts().
+func (p *BPFProg) AttachKretprobeMultiOpts(opts KprobeMultiOpts) (*BPFLink, error) {
+	return doAttachKprobeMulti(p, KretprobeMulti, opts)
 }
 
-// attachKprobeCommon is a common function for attaching kprobe and kretprobe.
-func (p *BPFProg) attachKprobeCommon(a attachTo) (*BPFLink, error) {
-	// Create kprobe_opts.
-	optsC, errno := C.cgo_bpf_kprobe_opts_new(
-		C.ulonglong(0),      // bpf cookie (not used)
-		C.size_t(a.symAddr), // might be 0 if attaching using symbol name
-		C.bool(a.isRet),     // is kretprobe or kprobe
-		C.int(0),            // attach mode (default)
-	)
-	if optsC == nil {
-		return nil, fmt.Errorf("failed to create kprobe_opts of %v: %v", a, errno)
+// AttachKprobeSession attaches the BPFProgram to both the entry and the
+// return of the kernel functions, through a single kprobe_multi link in
+// session mode (v6.10). The program, in a SEC("kprobe.session") section,
+// runs on entry and, unless it returned non-zero there, on return. It tells
+// them apart with the bpf_session_is_return() kfunc, and passes data from
+// entry to return through the u64 pointed to by bpf_session_cookie(), kept
+// per function call. The Cookies of the options are read with
+// bpf_get_attach_cookie() on both. See AttachKprobeMultiOpts().
+func (p *BPFProg) AttachKprobeSession(opts KprobeMultiOpts) (*BPFLink, error) {
+	if !HasKprobeSession {
+		return nil, fmt.Errorf("failed to attach kprobe session to program %s: libbpf %s: %w", p.Name(), LibbpfVersionString(), ErrNotSupportedByLibbpf)
+	}
+	if attachType := p.ExpectedAttachType(); attachType != BPFAttachTypeTraceKprobeSession {
+		return nil, fmt.Errorf("failed to attach kprobe session to program %s: expected attach type is %s, not %s (SEC(\"kprobe.session\")): %w", p.Name(), attachType, BPFAttachTypeTraceKprobeSession, syscall.EINVAL)
 	}
-	defer C.cgo_bpf_kprobe_opts_free(optsC)
 
-	// Create kprobe symbol name.
-	symNameC := C.CString(a.symName)
-	defer C.free(unsafe.Pointer(symNameC))
+	return doAttachKprobeMulti(p, KprobeSession, opts)
+}
 
-	// Create kprobe link.
-	var linkC *C.struct_bpf_link
-	linkC, errno = C.bpf_program__attach_kprobe_opts(p.prog, symNameC, optsC)
-	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach to %v: %v", a, errno)
+func doAttachKprobeMulti(p *BPFProg, linkType LinkType, opts KprobeMultiOpts) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
 	}
 
-	linkType := Kprobe
-	if a.isRet {
-		linkType = Kretprobe
+	cnt, err := opts.sites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach %s to program %s: %w", kprobeMultiName(linkType), p.Name(), err)
 	}
-
-	eventName := a.symName
-	if eventName == "" {
-		eventName = fmt.Sprintf("%d", a.symAddr)
+	if err := p.checkNotSleepable(kprobeMultiName(linkType)); err != nil {
+		return nil, err
 	}
 
-	// Create bpfLink and append it to the module.
-	bpfLink := &BPFLink{
-		link:      linkC,     // linkC is a pointer to a struct bpf_link
-		prog:      p,         // p is a pointer to the related BPFProg
-		linkType:  linkType,  // linkType is a BPFLinkType
-		eventName: eventName, // eventName is a string
+	target := fmt.Sprintf("%d", cnt)
+	var patternC *C.char
+	if opts.Pattern != "" {
+		target = opts.Pattern
+		patternC = C.CString(opts.Pattern)
+		defer C.free(unsafe.Pointer(patternC))
 	}
-	p.module.links = append(p.module.links, bpfLink)
 
-	return bpfLink, nil
-}
+	symsC := cStringArray(opts.Symbols)
+	defer freeCStringArray(symsC, len(opts.Symbols))
+	addrsC := cULongArray(opts.Addrs)
+	defer C.free(unsafe.Pointer(addrsC))
+	cookiesC := cU64Array(opts.Cookies)
+	defer C.free(unsafe.Pointer(cookiesC))
 
-// AttachKprobe attaches the BPFProgram to the given symbol name.
-func (p *BPFProg) AttachKprobe(symbol string) (*BPFLink, error) {
-	a := attachTo{
-		symName: symbol,
-		isRet:   false,
+	optsC, errno := C.cgo_bpf_kprobe_multi_opts_new(symsC, addrsC, cookiesC, C.size_t(cnt), C.bool(linkType == KretprobeMulti), C.bool(linkType == KprobeSession))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create kprobe_multi_opts for program %s: %w", p.Name(), errno)
 	}
-	return p.attachKprobeCommon(a)
-}
+	defer C.cgo_bpf_kprobe_multi_opts_free(optsC)
 
-// AttachKretprobe attaches the BPFProgram to the given symbol name (for return).
-func (p *BPFProg) AttachKretprobe(symbol string) (*BPFLink, error) {
-	a := attachTo{
-		symName: symbol,
-		isRet:   true,
+	linkC, errno := C.bpf_program__attach_kprobe_multi_opts(p.prog, patternC, optsC)
+	if linkC == nil {
+		if opts.Pattern != "" {
+			return nil, fmt.Errorf("failed to attach %s (%s) to program %s: %w", kprobeMultiName(linkType), opts.Pattern, p.Name(), classifyError(opAttach, errno, ""))
+		}
+		return nil, fmt.Errorf("failed to attach %s (%d symbols) to program %s: %w", kprobeMultiName(linkType), cnt, p.Name(), classifyError(opAttach, errno, ""))
 	}
-	return p.attachKprobeCommon(a)
-}
 
-// AttachKprobeOnOffset attaches the BPFProgram to the given offset.
-func (p *BPFProg) AttachKprobeOffset(offset uint64) (*BPFLink, error) {
-	a := attachTo{
-		symAddr: offset,
-		isRet:   false,
+	eventName := fmt.Sprintf("kprobe_multi-%s-%s", p.Name(), target)
+	if linkType == KprobeSession {
+		eventName = fmt.Sprintf("kprobe_session-%s-%s", p.Name(), target)
 	}
-	return p.attachKprobeCommon(a)
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		prog:      p,
+		linkType:  linkType,
+		eventName: eventName,
+	}
+	p.module.addLink(bpfLink)
+
+	return bpfLink, nil
 }
 
-// AttachKretprobeOnOffset attaches the BPFProgram to the given offset (for return).
-func (p *BPFProg) AttachKretprobeOnOffset(offset uint64) (*BPFLink, error) {
-	a := attachTo{
-		symAddr: offset,
-		isRet:   true,
+// kprobeMultiName returns the name of the kprobe_multi link type in errors.
+func kprobeMultiName(linkType LinkType) string {
+	if linkType == KprobeSession {
+		return "kprobe session"
 	}
-	return p.attachKprobeCommon(a)
+
+	return "kprobe multi"
 }
 
 // End of Kprobe and Kretprobe
 
 func (p *BPFProg) AttachNetns(networkNamespacePath string) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
 	fd, err := syscall.Open(networkNamespacePath, syscall.O_RDONLY, 0)
 	if fd < 0 {
 		return nil, fmt.Errorf("failed to open network namespace path %s: %w", networkNamespacePath, err)
@@ -486,7 +1460,7 @@ func (p *BPFProg) AttachNetns(networkNamespacePath string) (*BPFLink, error) {
 
 	linkC, errno := C.bpf_program__attach_netns(p.prog, C.int(fd))
 	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach network namespace on %s to program %s: %w", networkNamespacePath, p.Name(), errno)
+		return nil, fmt.Errorf("failed to attach network namespace on %s to program %s: %w", networkNamespacePath, p.Name(), classifyError(opAttach, errno, ""))
 	}
 
 	// fileName will be used in bpfLink.eventName. eventName follows a format
@@ -502,11 +1476,13 @@ func (p *BPFProg) AttachNetns(networkNamespacePath string) (*BPFLink, error) {
 		linkType:  Netns,
 		eventName: fmt.Sprintf("netns-%s-%s", p.Name(), fileName),
 	}
-	p.module.links = append(p.module.links, bpfLink)
+	p.module.addLink(bpfLink)
 
 	return bpfLink, nil
 }
 
+// IterOpts are the parameters of an iterator link, only the fields of the
+// iterator kind (map, cgroup or task) can be set.
 type IterOpts struct {
 	MapFd           int
 	CgroupIterOrder BPFCgroupIterOrder
@@ -517,8 +1493,21 @@ type IterOpts struct {
 	PidFd           int
 }
 
+// AttachIter attaches the iterator program. The options are checked against
+// the iterator target, see MapElemIter(), CgroupIter(), TaskIter(),
+// TaskVMAIter() and KsymIter().
 func (p *BPFProg) AttachIter(opts IterOpts) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	kind, err := validateIterOpts(p.SectionName(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid iter options for program %s: %w", p.Name(), err)
+	}
+
 	optsC, errno := C.cgo_bpf_iter_attach_opts_new(
+		C.enum_cgo_iter_link_info_kind(kind),
 		C.uint(opts.MapFd),
 		uint32(opts.CgroupIterOrder),
 		C.uint(opts.CgroupFd),
@@ -534,7 +1523,7 @@ func (p *BPFProg) AttachIter(opts IterOpts) (*BPFLink, error) {
 
 	linkC, errno := C.bpf_program__attach_iter(p.prog, optsC)
 	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach iter to program %s: %w", p.Name(), errno)
+		return nil, fmt.Errorf("failed to attach iter to program %s: %w", p.Name(), classifyError(opAttach, errno, ""))
 	}
 
 	bpfLink := &BPFLink{
@@ -543,48 +1532,113 @@ func (p *BPFProg) AttachIter(opts IterOpts) (*BPFLink, error) {
 		linkType:  Iter,
 		eventName: fmt.Sprintf("iter-%s-%d", p.Name(), opts.MapFd),
 	}
-	p.module.links = append(p.module.links, bpfLink)
+	p.module.addLink(bpfLink)
 
 	return bpfLink, nil
 }
 
 // AttachUprobe attaches the BPFProgram to entry of the symbol in the library or binary at 'path'
-// which can be relative or absolute. A pid can be provided to attach to, or -1 can be specified
+// which can be relative or absolute, or a library name (see ResolveLibrary()). A pid can be provided to attach to, or -1 can be specified
 // to attach to all processes
 func (p *BPFProg) AttachUprobe(pid int, path string, offset uint32) (*BPFLink, error) {
-	absPath, err := filepath.Abs(path)
+	absPath, err := uprobePath(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return doAttachUprobe(p, false, pid, absPath, offset)
+	return p.AttachUprobeOpts(absPath, WithPID(pid), WithOffset(uint64(offset)))
 }
 
 // AttachURetprobe attaches the BPFProgram to exit of the symbol in the library or binary at 'path'
-// which can be relative or absolute. A pid can be provided to attach to, or -1 can be specified
+// which can be relative or absolute, or a library name (see ResolveLibrary()). A pid can be provided to attach to, or -1 can be specified
 // to attach to all processes
 func (p *BPFProg) AttachURetprobe(pid int, path string, offset uint32) (*BPFLink, error) {
-	absPath, err := filepath.Abs(path)
+	absPath, err := uprobePath(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return doAttachUprobe(p, true, pid, absPath, offset)
+	return p.AttachURetprobeOpts(absPath, WithPID(pid), WithOffset(uint64(offset)))
+}
+
+// AttachUprobeFunc attaches the BPFProgram to the entry of the function funcName
+// in the library or binary at 'path'. libbpf resolves the function offset, and
+// looks up 'path' in the PATH environment variable if it does not contain a
+// slash. Library names ("libssl", "libssl.so.3") are resolved with
+// ResolveLibrary(), or in the libbpf library search paths. A pid can be provided to attach to, or -1 can
+// be specified to attach to all processes. See AttachUprobeOpts() for the
+// attach options.
+func (p *BPFProg) AttachUprobeFunc(pid int, path string, funcName string) (*BPFLink, error) {
+	return doAttachUprobeOpts(p, false, path, []AttachOption{WithPID(pid), WithFunc(funcName)})
+}
+
+// AttachURetprobeFunc attaches the BPFProgram to the exit of the function funcName
+// in the library or binary at 'path'. See AttachUprobeFunc().
+func (p *BPFProg) AttachURetprobeFunc(pid int, path string, funcName string) (*BPFLink, error) {
+	return doAttachUprobeOpts(p, true, path, []AttachOption{WithPID(pid), WithFunc(funcName)})
+}
+
+// AttachUprobeOpts attaches the BPFProgram to the library or binary at 'path',
+// which is looked up like with AttachUprobeFunc(). The probe is placed with the
+// WithFunc and WithOffset options, and attached to all processes unless the
+// WithPID option is given. It also accepts the WithCookie and WithAttachMode
+// options.
+func (p *BPFProg) AttachUprobeOpts(path string, opts ...AttachOption) (*BPFLink, error) {
+	return doAttachUprobeOpts(p, false, path, opts)
+}
+
+// AttachURetprobeOpts attaches the BPFProgram to the exit of the function in
+// the library or binary at 'path'. See AttachUprobeOpts().
+func (p *BPFProg) AttachURetprobeOpts(path string, opts ...AttachOption) (*BPFLink, error) {
+	return doAttachUprobeOpts(p, true, path, opts)
 }
 
-func doAttachUprobe(prog *BPFProg, isUretprobe bool, pid int, path string, offset uint32) (*BPFLink, error) {
+func doAttachUprobeOpts(prog *BPFProg, isUretprobe bool, path string, opts []AttachOption) (*BPFLink, error) {
+	if err := prog.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptCookie|attachOptPID|attachOptOffset|attachOptAttachMode|attachOptFunc, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach u(ret)probe to program %s: %w", path, err)
+	}
+
+	if err := checkLegacyProbeTracefs(o.attachMode); err != nil {
+		return nil, fmt.Errorf("failed to attach u(ret)probe to program %s: %w", path, err)
+	}
+
+	path, err = uprobeTarget(path)
+	if err != nil {
+		return nil, err
+	}
+
+	target := fmt.Sprintf("%d", o.offset)
+	if o.funcName != "" {
+		target = o.funcName
+		if o.offset != 0 {
+			target = fmt.Sprintf("%s+0x%x", o.funcName, o.offset)
+		}
+	}
+
 	pathC := C.CString(path)
 	defer C.free(unsafe.Pointer(pathC))
 
-	linkC, errno := C.bpf_program__attach_uprobe(
-		prog.prog,
-		C.bool(isUretprobe),
-		C.int(pid),
-		pathC,
-		C.size_t(offset),
-	)
+	// Without a function name, the offset is within the binary or library
+	var funcNameC *C.char
+	if o.funcName != "" {
+		funcNameC = C.CString(o.funcName)
+		defer C.free(unsafe.Pointer(funcNameC))
+	}
+
+	optsC, errno := C.cgo_bpf_uprobe_opts_new(funcNameC, C.__u64(o.cookie), C.bool(isUretprobe), C.int(o.attachMode))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create uprobe_opts for program %s: %w", prog.Name(), errno)
+	}
+	defer C.cgo_bpf_uprobe_opts_free(optsC)
+
+	linkC, errno := C.bpf_program__attach_uprobe_opts(prog.prog, C.int(o.pid), pathC, C.size_t(o.offset), optsC)
 	if linkC == nil {
-		return nil, fmt.Errorf("failed to attach u(ret)probe to program %s:%d with pid %d: %w ", path, offset, pid, errno)
+		return nil, fmt.Errorf("failed to attach u(ret)probe to program %s:%s with pid %d: %w", path, target, o.pid, classifyError(opAttach, errno, ""))
 	}
 
 	upType := Uprobe
@@ -596,14 +1650,160 @@ func doAttachUprobe(prog *BPFProg, isUretprobe bool, pid int, path string, offse
 		link:      linkC,
 		prog:      prog,
 		linkType:  upType,
-		eventName: fmt.Sprintf("%s:%d:%d", path, pid, offset),
+		eventName: fmt.Sprintf("%s:%d:%s", path, o.pid, target),
+		pid:       max(o.pid, 0),
+	}
+	prog.module.addLink(bpfLink)
+
+	return bpfLink, nil
+}
+
+// uprobePath returns the absolute path of the binary or library to probe, at a
+// path relative to the working directory, unless it is a library name, not
+// found there, resolved when attached.
+func uprobePath(path string) (string, error) {
+	if isLibraryName(path) {
+		if _, err := os.Stat(path); err != nil {
+			return path, nil
+		}
+	}
+
+	return filepath.Abs(path)
+}
+
+// uprobeTarget returns the path of the binary or library to probe, given to
+// libbpf: absolute, or a name it looks up. Library names are resolved through
+// the ld.so cache, if found there.
+func uprobeTarget(path string) (string, error) {
+	if strings.Contains(path, "/") {
+		return filepath.Abs(path)
+	}
+
+	if isLibraryName(path) {
+		if libPath, err := ResolveLibrary(path); err == nil {
+			return libPath, nil
+		}
+	}
+
+	return path, nil
+}
+
+// AttachUSDT attaches the BPFProgram to the USDT probe provider:name of the
+// library or binary at 'path', which is looked up like with AttachUprobeFunc().
+// A pid can be provided to attach to, or -1 can be specified to attach to all
+// processes. It accepts the WithCookie option, whose cookie the program reads
+// with the bpf_usdt_cookie() helper of libbpf's usdt.bpf.h. Attaching to more
+// probe sites than the object can hold fails with E2BIG, see
+// Module.SetUSDTMaxSpecs().
+func (p *BPFProg) AttachUSDT(pid int, path string, provider string, name string, opts ...AttachOption) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	o, err := newAttachOptions(attachOptCookie, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach usdt %s:%s of binary %s to program %s: %w", provider, name, path, p.Name(), err)
+	}
+
+	path, err = uprobeTarget(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pathC := C.CString(path)
+	defer C.free(unsafe.Pointer(pathC))
+	providerC := C.CString(provider)
+	defer C.free(unsafe.Pointer(providerC))
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+
+	optsC, errno := C.cgo_bpf_usdt_opts_new(C.__u64(o.cookie))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create usdt_opts for program %s: %w", p.Name(), errno)
+	}
+	defer C.cgo_bpf_usdt_opts_free(optsC)
+
+	linkC, errno := C.bpf_program__attach_usdt(p.prog, C.int(pid), pathC, providerC, nameC, optsC)
+	if linkC == nil {
+		return nil, fmt.Errorf("failed to attach usdt %s:%s of binary %s to program %s with pid %d: %w", provider, name, path, p.Name(), pid, usdtError(p.module, classifyError(opAttach, errno, "")))
+	}
+
+	bpfLink := &BPFLink{
+		link:      linkC,
+		prog:      p,
+		linkType:  USDT,
+		eventName: fmt.Sprintf("%s:%d:%s:%s", path, pid, provider, name),
+		pid:       max(pid, 0),
 	}
+	p.module.addLink(bpfLink)
 
 	return bpfLink, nil
 }
 
+// AttachUprobeLibrary attaches the BPFProgram, like AttachUprobeOpts(), to
+// all the copies of the shared library found by LibraryCopies(): the one of
+// the host, and the ones mapped by the processes of containers. It returns a
+// link per copy.
+func (p *BPFProg) AttachUprobeLibrary(library string, opts ...AttachOption) ([]*BPFLink, error) {
+	return attachLibraryCopies(library, func(path string) (*BPFLink, error) {
+		return doAttachUprobeOpts(p, false, path, opts)
+	})
+}
+
+// AttachURetprobeLibrary attaches the BPFProgram to the exit of the function
+// in all the copies of the shared library. See AttachUprobeLibrary().
+func (p *BPFProg) AttachURetprobeLibrary(library string, opts ...AttachOption) ([]*BPFLink, error) {
+	return attachLibraryCopies(library, func(path string) (*BPFLink, error) {
+		return doAttachUprobeOpts(p, true, path, opts)
+	})
+}
+
+// AttachUSDTLibrary attaches the BPFProgram, like AttachUSDT() for all
+// processes, to the USDT probe provider:name of all the copies of the shared
+// library. See AttachUprobeLibrary().
+func (p *BPFProg) AttachUSDTLibrary(library string, provider string, name string, opts ...AttachOption) ([]*BPFLink, error) {
+	return attachLibraryCopies(library, func(path string) (*BPFLink, error) {
+		return p.AttachUSDT(-1, path, provider, name, opts...)
+	})
+}
+
+// attachLibraryCopies attaches to all the copies of the library. The copies
+// gone with their last process before being attached are skipped. On error,
+// the links already created are destroyed.
+func attachLibraryCopies(library string, attach func(path string) (*BPFLink, error)) ([]*BPFLink, error) {
+	paths, err := LibraryCopies(library)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*BPFLink, 0, len(paths))
+	for _, path := range paths {
+		link, err := attach(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			for _, link := range links {
+				_ = link.Destroy()
+			}
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	if len(links) == 0 {
+		return nil, fmt.Errorf("failed to attach to library %s: %w", library, fs.ErrNotExist)
+	}
+
+	return links, nil
+}
+
 // AttachGenericFD attaches the BPFProgram to a targetFd at the specified attachType hook.
 func (p *BPFProg) AttachGenericFD(targetFd int, attachType BPFAttachType, flags AttachFlag) error {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return err
+	}
+
 	retC := C.bpf_prog_attach(
 		C.int(p.FileDescriptor()),
 		C.int(targetFd),
@@ -611,7 +1811,7 @@ func (p *BPFProg) AttachGenericFD(targetFd int, attachType BPFAttachType, flags
 		C.uint(uint(flags)),
 	)
 	if retC < 0 {
-		return fmt.Errorf("failed to attach: %w", syscall.Errno(-retC))
+		return fmt.Errorf("failed to attach: %w", classifyError(opAttach, syscall.Errno(-retC), ""))
 	}
 
 	return nil
@@ -619,6 +1819,10 @@ func (p *BPFProg) AttachGenericFD(targetFd int, attachType BPFAttachType, flags
 
 // DetachGenericFD detaches the BPFProgram associated with the targetFd at the hook specified by attachType.
 func (p *BPFProg) DetachGenericFD(targetFd int, attachType BPFAttachType) error {
+	if err := p.checkNotUnloaded("detach"); err != nil {
+		return err
+	}
+
 	retC := C.bpf_prog_detach2(
 		C.int(p.FileDescriptor()),
 		C.int(targetFd),
@@ -631,6 +1835,88 @@ func (p *BPFProg) DetachGenericFD(targetFd int, attachType BPFAttachType) error
 	return nil
 }
 
+// DetachGenericFDIfAttached is like DetachGenericFD(), but only detaches the
+// program if the query of the hook lists it, and fails with ENOENT otherwise.
+//
+// The kernel may detach whatever program is at the hook, and not the given
+// one, for hooks attached without BPF_F_ALLOW_MULTI (cgroups) or by kernels
+// not checking it: in environments shared with other agents, this avoids
+// ripping out their programs after ours were replaced. A program attached
+// between the query and the detachment is not seen.
+func (p *BPFProg) DetachGenericFDIfAttached(targetFd int, attachType BPFAttachType) error {
+	if err := p.checkNotUnloaded("detach"); err != nil {
+		return err
+	}
+
+	info, err := p.Info()
+	if err != nil {
+		return fmt.Errorf("failed to detach program %s: %w", p.Name(), err)
+	}
+
+	progIDs, err := QueryGenericFD(targetFd, attachType)
+	if err != nil {
+		return fmt.Errorf("failed to detach program %s: %w", p.Name(), err)
+	}
+	if !slices.Contains(progIDs, info.ID) {
+		return fmt.Errorf("failed to detach program %s: program id %d not attached (attached: %v): %w", p.Name(), info.ID, progIDs, syscall.ENOENT)
+	}
+
+	return p.DetachGenericFD(targetFd, attachType)
+}
+
+// QueryGenericFD returns the ids of the programs attached to targetFd at the
+// hook specified by attachType, in execution order: a cgroup directory, a
+// sockmap (v5.19+), a network namespace (flow dissector), ...
+func QueryGenericFD(targetFd int, attachType BPFAttachType) ([]uint32, error) {
+	return queryRetrying(
+		func() (uint32, error) {
+			return queryProgCount(targetFd, attachType)
+		},
+		func(count uint32) ([]uint32, error) {
+			return queryProgIDs(targetFd, attachType, count)
+		},
+	)
+}
+
+func queryProgCount(targetFd int, attachType BPFAttachType) (uint32, error) {
+	optsC, errno := C.cgo_bpf_prog_query_opts_new(nil, nil, nil, 0)
+	if optsC == nil {
+		return 0, fmt.Errorf("failed to create bpf_prog_query_opts: %w", errno)
+	}
+	defer C.cgo_bpf_prog_query_opts_free(optsC)
+
+	retC := C.bpf_prog_query_opts(C.int(targetFd), uint32(attachType), optsC)
+	if retC < 0 {
+		return 0, fmt.Errorf("failed to query programs attached to fd %d (%s): %w", targetFd, attachType, syscall.Errno(-retC))
+	}
+
+	return uint32(C.cgo_bpf_prog_query_opts_count(optsC)), nil
+}
+
+func queryProgIDs(targetFd int, attachType BPFAttachType, count uint32) ([]uint32, error) {
+	// One extra element, so a hook that is empty now still gets an array
+	progIDsC := C.calloc(C.size_t(count+1), C.size_t(unsafe.Sizeof(C.__u32(0))))
+	if progIDsC == nil {
+		return nil, fmt.Errorf("failed to allocate memory for program query")
+	}
+	defer C.free(progIDsC)
+
+	optsC, errno := C.cgo_bpf_prog_query_opts_new((*C.__u32)(progIDsC), nil, nil, C.__u32(count+1))
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create bpf_prog_query_opts: %w", errno)
+	}
+	defer C.cgo_bpf_prog_query_opts_free(optsC)
+
+	retC := C.bpf_prog_query_opts(C.int(targetFd), uint32(attachType), optsC)
+	if retC < 0 {
+		return nil, fmt.Errorf("failed to query programs attached to fd %d (%s): %w", targetFd, attachType, syscall.Errno(-retC))
+	}
+
+	n := uint32(C.cgo_bpf_prog_query_opts_count(optsC))
+
+	return slices.Clone(unsafe.Slice((*uint32)(progIDsC), n)), nil
+}
+
 //
 // BPF_PROG_TEST_RUN
 //
@@ -771,6 +2057,10 @@ func runOptsFromC(runOpts *RunOpts, optsC *C.struct_bpf_test_run_opts) {
 //	    }
 //	}
 func (p *BPFProg) Run(opts *RunOpts) error {
+	if err := p.checkNotUnloaded("run"); err != nil {
+		return err
+	}
+
 	optsC, err := runOptsToC(opts)
 	if err != nil {
 		return err
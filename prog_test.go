@@ -0,0 +1,54 @@
+package libbpfgo
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKprobeMultiOptsSites(t *testing.T) {
+	cnt, err := (&KprobeMultiOpts{Pattern: "tcp_*"}).sites()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+
+	cnt, err = (&KprobeMultiOpts{Symbols: []string{"tcp_connect", "tcp_close"}, Cookies: []uint64{1, 2}}).sites()
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	cnt, err = (&KprobeMultiOpts{Addrs: []uint64{0xffffffff81000000}}).sites()
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	for _, opts := range []KprobeMultiOpts{
+		{},
+		{Pattern: "tcp_*", Symbols: []string{"tcp_connect"}},
+		{Symbols: []string{"tcp_connect"}, Addrs: []uint64{0xffffffff81000000}},
+		{Pattern: "tcp_*", Cookies: []uint64{1}},
+		{Symbols: []string{"tcp_connect", "tcp_close"}, Cookies: []uint64{1}},
+	} {
+		_, err := opts.sites()
+		assert.ErrorIs(t, err, syscall.EINVAL, "%+v", opts)
+	}
+}
+
+func TestKprobeAttachError(t *testing.T) {
+	readCapabilities = func() capabilities { return 0 }
+	defer func() { readCapabilities = readCapabilitiesProc }()
+
+	a := attachTo{symName: "tcp_connect"}
+
+	err := kprobeAttachError(a, syscall.EOPNOTSUPP)
+	assert.ErrorIs(t, err, syscall.EOPNOTSUPP)
+	assert.ErrorIs(t, err, ErrNotSupportedByKernel)
+
+	err = kprobeAttachError(a, syscall.EPERM)
+	assert.ErrorIs(t, err, syscall.EPERM)
+	assert.ErrorIs(t, err, ErrPermission)
+	assert.Contains(t, ErrorHint(err), "CAP_PERFMON")
+
+	err = kprobeAttachError(attachTo{symName: "tcp_connect", symAddr: 3}, syscall.EILSEQ)
+	assert.ErrorIs(t, err, syscall.EILSEQ)
+	assert.ErrorContains(t, err, "tcp_connect+0x3: offset is not an instruction boundary")
+}
@@ -0,0 +1,145 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+//
+// Raw tracepoints
+//
+
+// AttachRawTracepointWritable attaches a writable raw tracepoint program
+// (SEC("raw_tp.w/...")) to the given raw tracepoint. Writable raw tracepoints
+// may write to the tracepoint buffer, which is only possible for tracepoints
+// declared writable by the kernel (e.g. nbd_send_request).
+func (p *BPFProg) AttachRawTracepointWritable(tpEvent string) (*BPFLink, error) {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return nil, err
+	}
+
+	if p.GetType() != BPFProgTypeRawTracepointWritable {
+		return nil, fmt.Errorf("failed to attach writable raw tracepoint %s to program %s: program type is %s", tpEvent, p.Name(), p.GetType())
+	}
+
+	return p.AttachRawTracepoint(tpEvent)
+}
+
+//
+// Syscall tracing
+//
+// The sys_enter and sys_exit raw tracepoints fire for every syscall, so
+// syscall tracers filter by syscall number in the program. The filter can be
+// given to the program in two ways:
+//
+// Map seeding, for any number of syscalls: the syscall numbers are written
+// as u32 keys of a map with non-zero u8 values, all the keys up to its max
+// entries to trace all syscalls.
+//
+//	struct {
+//	    __uint(type, BPF_MAP_TYPE_ARRAY);
+//	    __uint(max_entries, 512);
+//	    __type(key, u32);
+//	    __type(value, u8);
+//	} syscall_filter SEC(".maps");
+//
+//	SEC("raw_tp/sys_enter")
+//	int sys_enter(struct bpf_raw_tracepoint_args *ctx)
+//	{
+//	    u32 id = ctx->args[1];
+//	    u8 *traced = bpf_map_lookup_elem(&syscall_filter, &id);
+//	    if (!traced || !*traced)
+//	        return 0;
+//	    ...
+//	}
+//
+// Attach cookie, for a single syscall and no map (kernel 6.10+): the cookie
+// is the syscall number plus one, 0 meaning all syscalls.
+//
+//	SEC("raw_tp/sys_enter")
+//	int sys_enter(struct bpf_raw_tracepoint_args *ctx)
+//	{
+//	    u64 cookie = bpf_get_attach_cookie(ctx);
+//	    if (cookie && cookie - 1 != ctx->args[1])
+//	        return 0;
+//	    ...
+//	}
+//
+// In sys_exit the syscall number is not an argument, it is read from the
+// registers given as first argument (e.g. PT_REGS orig_ax on x86_64).
+//
+
+// SyscallFilter selects the syscalls traced by a sys_enter/sys_exit program.
+type SyscallFilter struct {
+	// Syscalls are the numbers of the traced syscalls, all if empty.
+	Syscalls []uint32
+	// Map, if set, is seeded with Syscalls, or with all the syscall numbers
+	// below its max entries if empty. Otherwise at most one syscall may be
+	// given, and it is passed as attach cookie.
+	Map *BPFMap
+}
+
+// AttachSysEnter attaches the raw tracepoint program to sys_enter, seeding
+// the syscall filter (see SyscallFilter).
+func (p *BPFProg) AttachSysEnter(filter SyscallFilter) (*BPFLink, error) {
+	return p.attachSyscallTracepoint("sys_enter", filter)
+}
+
+// AttachSysExit attaches the raw tracepoint program to sys_exit, seeding
+// the syscall filter (see SyscallFilter).
+func (p *BPFProg) AttachSysExit(filter SyscallFilter) (*BPFLink, error) {
+	return p.attachSyscallTracepoint("sys_exit", filter)
+}
+
+func (p *BPFProg) attachSyscallTracepoint(tpEvent string, filter SyscallFilter) (*BPFLink, error) {
+	if filter.Map != nil {
+		if err := seedSyscallFilter(filter.Map, filter.Syscalls); err != nil {
+			return nil, fmt.Errorf("failed to attach raw tracepoint %s to program %s: %w", tpEvent, p.Name(), err)
+		}
+
+		return p.AttachRawTracepoint(tpEvent)
+	}
+
+	switch len(filter.Syscalls) {
+	case 0:
+		return p.AttachRawTracepoint(tpEvent)
+	case 1:
+		opts := RawTracepointOpts{Cookie: uint64(filter.Syscalls[0]) + 1}
+		return p.AttachRawTracepointOpts(tpEvent, opts)
+	}
+
+	return nil, fmt.Errorf("failed to attach raw tracepoint %s to program %s: a filter map is required to trace %d syscalls", tpEvent, p.Name(), len(filter.Syscalls))
+}
+
+func seedSyscallFilter(m *BPFMap, syscalls []uint32) error {
+	if m.KeySize() != 4 || m.ValueSize() < 1 {
+		return fmt.Errorf("invalid syscall filter map %s: key size %d, value size %d", m.Name(), m.KeySize(), m.ValueSize())
+	}
+
+	value := make([]byte, m.ValueSize())
+	value[0] = 1
+
+	for _, id := range syscallFilterKeys(syscalls, m.MaxEntries()) {
+		key := id
+		if err := m.Update(unsafe.Pointer(&key), unsafe.Pointer(&value[0])); err != nil {
+			return fmt.Errorf("failed to seed syscall %d in filter map %s: %w", id, m.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// syscallFilterKeys returns the keys seeded in a syscall filter map: the
+// syscalls, or all the syscall numbers below maxEntries if none is given.
+func syscallFilterKeys(syscalls []uint32, maxEntries uint32) []uint32 {
+	if len(syscalls) > 0 {
+		return syscalls
+	}
+
+	keys := make([]uint32, maxEntries)
+	for i := range keys {
+		keys[i] = uint32(i)
+	}
+
+	return keys
+}
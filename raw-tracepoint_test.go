@@ -0,0 +1,19 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyscallFilterKeys(t *testing.T) {
+	assert.Equal(t, []uint32{59, 322}, syscallFilterKeys([]uint32{59, 322}, 512))
+
+	// All syscalls when empty
+	keys := syscallFilterKeys(nil, 512)
+	assert.Len(t, keys, 512)
+	assert.Equal(t, uint32(0), keys[0])
+	assert.Equal(t, uint32(511), keys[511])
+
+	assert.Empty(t, syscallFilterKeys(nil, 0))
+}
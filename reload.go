@@ -0,0 +1,295 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+//
+// ReloadManager
+//
+// Agents upgrading their BPF code without losing events or state load the
+// new version of the object next to the running one, hand it the maps of the
+// old one, move the attachments over, and only then close the old module.
+// The ReloadManager does this blue/green reload:
+//
+//	rm, _ := libbpfgo.NewReloadManager(m, libbpfgo.ReloadOptions{})
+//	...
+//	err := rm.Reload(libbpfgo.NewModuleArgs{BPFObjPath: "agent-v2.bpf.o"})
+//	m = rm.Module()
+//
+// The pinned maps of the old module, and the ones named in
+// ReloadOptions.ReuseMaps, are reused by the new one, which must declare them
+// with the same definition. The links tracked by the old module are moved to
+// the program of the same name of the new module: in place, with
+// BPFLink.UpdateProg(), for the link types the kernel can update (cgroup,
+// XDP, netns, iterators, ...), or else attached again, so that both versions
+// run until the old module is closed. The links of the programs missing in
+// the new object are destroyed with the old module.
+//
+// Should any step fail, the links are given back to the old module, which
+// keeps running, and the new module is closed.
+//
+
+// ReattachFunc attaches the new module in place of a link of the old one that
+// could not be updated in place. It returns a nil link if the new module has
+// nothing to attach in place of the old link.
+type ReattachFunc func(m *Module, old *BPFLink) (*BPFLink, error)
+
+// ReloadOptions configures a ReloadManager.
+type ReloadOptions struct {
+	// Prepare, if set, is called with each new module before it is loaded
+	// (e.g. to set its global variables).
+	Prepare func(m *Module) error
+	// Reattach attaches the links that can not be updated in place. If not
+	// set, the program of the same name is attached with AttachGeneric(), or
+	// to the cgroup or sockmap of a legacy link, and the struct_ops map of the
+	// same name with AttachStructOps().
+	Reattach ReattachFunc
+	// ReuseMaps are the names of the maps, not pinned, that the new module
+	// reuses from the old one.
+	ReuseMaps []string
+}
+
+type ReloadManager struct {
+	mu      sync.Mutex
+	current *Module
+	opts    ReloadOptions
+}
+
+// NewReloadManager returns a ReloadManager of the module, loaded and
+// attached. The module is owned by the manager from now on.
+func NewReloadManager(m *Module, opts ReloadOptions) (*ReloadManager, error) {
+	if m == nil || !m.loaded {
+		return nil, fmt.Errorf("failed to create reload manager: module not loaded")
+	}
+	if opts.Reattach == nil {
+		opts.Reattach = reattachGeneric
+	}
+
+	return &ReloadManager{
+		current: m,
+		opts:    opts,
+	}, nil
+}
+
+// Module returns the current module, the one of the last successful reload.
+func (r *ReloadManager) Module() *Module {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// Reload opens the object given by args (BPFObjBuff, or else BPFObjPath),
+// loads it reusing the maps of the current module, moves the links of the
+// current module over, and closes it. On failure, the current module is
+// left as it was.
+func (r *ReloadManager) Reload(args NewModuleArgs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current == nil {
+		return fmt.Errorf("failed to reload: reload manager closed")
+	}
+
+	var m *Module
+	var err error
+	if len(args.BPFObjBuff) > 0 {
+		m, err = NewModuleFromBufferArgs(args)
+	} else {
+		m, err = NewModuleFromFileArgs(args)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reload: %w", err)
+	}
+
+	if err := r.swap(r.current, m); err != nil {
+		m.Close()
+		return fmt.Errorf("failed to reload: %w", err)
+	}
+
+	old := r.current
+	r.current = m
+	old.Close()
+
+	return nil
+}
+
+// Close closes the current module.
+func (r *ReloadManager) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current != nil {
+		r.current.Close()
+		r.current = nil
+	}
+}
+
+// movedLink is a link updated in place to a program of the new module.
+type movedLink struct {
+	link    *BPFLink
+	oldProg *BPFProg
+}
+
+// swap loads the new module with the maps of the old one, and moves the
+// links of the old module to it. On failure, the links are given back to the
+// old module.
+func (r *ReloadManager) swap(old, m *Module) (err error) {
+	if r.opts.Prepare != nil {
+		if err := r.opts.Prepare(m); err != nil {
+			return err
+		}
+	}
+	if err := r.reuseMaps(old, m); err != nil {
+		return err
+	}
+	if err := m.BPFLoadObject(); err != nil {
+		return err
+	}
+
+	var moved []movedLink
+	var attached []*BPFLink
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, l := range attached {
+			_ = l.Destroy()
+		}
+		for _, ml := range moved {
+			_ = ml.link.UpdateProg(ml.oldProg)
+			moveLink(ml.link, m, old)
+		}
+	}()
+
+	for _, link := range slices.Clone(old.links) {
+		if link.legacy == nil && link.prog != nil {
+			prog, progErr := m.GetProgram(link.prog.Name())
+			if progErr != nil {
+				continue // dropped by the new version
+			}
+			oldProg := link.prog
+			if link.UpdateProg(prog) == nil {
+				moveLink(link, old, m)
+				moved = append(moved, movedLink{link: link, oldProg: oldProg})
+				continue
+			}
+		}
+
+		newLink, err := r.opts.Reattach(m, link)
+		if err != nil {
+			return fmt.Errorf("failed to attach %s again: %w", link.ownerName(), err)
+		}
+		if newLink != nil {
+			attached = append(attached, newLink)
+		}
+	}
+
+	for _, ml := range moved {
+		m.emitLink(ModuleEventLinkAttached, ml.link)
+	}
+
+	return nil
+}
+
+// reuseMaps makes the new module reuse the pinned maps of the old one, and
+// the ones of ReloadOptions.ReuseMaps.
+func (r *ReloadManager) reuseMaps(old, m *Module) error {
+	it := old.Iterator()
+	for oldMap := it.NextMap(); oldMap != nil; oldMap = it.NextMap() {
+		if !oldMap.IsPinned() && !slices.Contains(r.opts.ReuseMaps, oldMap.Name()) {
+			continue
+		}
+
+		newMap, err := m.GetMap(oldMap.Name())
+		if err != nil {
+			continue // dropped by the new version
+		}
+		if err := shapeOf(oldMap).compatible(shapeOf(newMap)); err != nil {
+			return fmt.Errorf("failed to reuse map %s: %w", oldMap.Name(), err)
+		}
+		if err := newMap.ReuseFD(oldMap.FileDescriptor()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reattachGeneric is the default ReattachFunc.
+func reattachGeneric(m *Module, old *BPFLink) (*BPFLink, error) {
+	if old.structOps != nil {
+		ops, err := m.GetMap(old.structOps.Name())
+		if err != nil {
+			return nil, nil
+		}
+
+		return ops.AttachStructOps()
+	}
+
+	prog, err := m.GetProgram(old.prog.Name())
+	if err != nil {
+		return nil, nil
+	}
+
+	switch old.linkType {
+	case CgroupLegacy:
+		return prog.AttachCgroupLegacy(old.legacy.cgroupDir, old.legacy.attachType)
+	case SockMapLegacy:
+		sockMap, err := m.GetMap(old.legacy.sockMap.Name())
+		if err != nil {
+			return nil, nil
+		}
+
+		return prog.AttachSockMap(sockMap)
+	}
+
+	return prog.AttachGeneric()
+}
+
+// moveLink moves the registration of a link from a module to another.
+func moveLink(link *BPFLink, from, to *Module) {
+	from.removeLink(link)
+	to.links = append(to.links, link)
+}
+
+// mapShape is the definition of a map, that a reused map must match.
+type mapShape struct {
+	mapType    MapType
+	keySize    int
+	valueSize  int
+	maxEntries uint32
+	flags      MapFlag
+}
+
+func shapeOf(m *BPFMap) mapShape {
+	return mapShape{
+		mapType:    m.Type(),
+		keySize:    m.KeySize(),
+		valueSize:  m.ValueSize(),
+		maxEntries: m.MaxEntries(),
+		flags:      m.MapFlags(),
+	}
+}
+
+// compatible checks that a map of the shape can be reused as one of the other
+// shape.
+func (s mapShape) compatible(other mapShape) error {
+	switch {
+	case s.mapType != other.mapType:
+		return fmt.Errorf("type changed from %s to %s", s.mapType, other.mapType)
+	case s.keySize != other.keySize:
+		return fmt.Errorf("key size changed from %d to %d", s.keySize, other.keySize)
+	case s.valueSize != other.valueSize:
+		return fmt.Errorf("value size changed from %d to %d", s.valueSize, other.valueSize)
+	case s.maxEntries != other.maxEntries:
+		return fmt.Errorf("max entries changed from %d to %d", s.maxEntries, other.maxEntries)
+	case s.flags != other.flags:
+		return fmt.Errorf("flags changed from %#x to %#x", uint32(s.flags), uint32(other.flags))
+	}
+
+	return nil
+}
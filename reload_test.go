@@ -0,0 +1,38 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapShapeCompatible(t *testing.T) {
+	shape := mapShape{mapType: MapTypeHash, keySize: 4, valueSize: 8, maxEntries: 1024}
+	assert.NoError(t, shape.compatible(shape))
+
+	for _, changed := range []mapShape{
+		{mapType: MapTypeLRUHash, keySize: 4, valueSize: 8, maxEntries: 1024},
+		{mapType: MapTypeHash, keySize: 8, valueSize: 8, maxEntries: 1024},
+		{mapType: MapTypeHash, keySize: 4, valueSize: 16, maxEntries: 1024},
+		{mapType: MapTypeHash, keySize: 4, valueSize: 8, maxEntries: 2048},
+		{mapType: MapTypeHash, keySize: 4, valueSize: 8, maxEntries: 1024, flags: MapFlag(1)},
+	} {
+		assert.Error(t, shape.compatible(changed), changed)
+	}
+}
+
+func TestMoveLink(t *testing.T) {
+	from, to := &Module{}, &Module{}
+	prog := &BPFProg{module: from}
+	l1, l2 := &BPFLink{prog: prog}, &BPFLink{prog: prog}
+	from.addLink(l1)
+	from.addLink(l2)
+
+	moveLink(l1, from, to)
+	assert.Equal(t, []*BPFLink{l2}, from.links)
+	assert.Equal(t, []*BPFLink{l1}, to.links)
+
+	moveLink(l1, to, from)
+	assert.Equal(t, []*BPFLink{l2, l1}, from.links)
+	assert.Empty(t, to.links)
+}
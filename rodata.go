@@ -0,0 +1,206 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+//
+// Read-only global variables
+//
+// Variables declared "const volatile" in BPF code are placed in .rodata, and
+// the verifier treats their values as known constants, pruning dead code
+// depending on them. They can be set before the object is loaded:
+//
+//	const volatile u32 target_pid = 0;
+//	const volatile bool trace_exits = false;
+//
+//	err := m.SetRodataVariable("target_pid", uint32(pid))
+//	err = m.SetRodataVariable("trace_exits", true)
+//
+
+// rodataVar is the BTF description of a .rodata variable.
+type rodataVar struct {
+	class  rodataClass
+	size   int  // size of the variable
+	signed bool // integers only
+	isChar bool // integers only
+}
+
+type rodataClass int
+
+const (
+	rodataInt rodataClass = iota
+	rodataEnum
+	rodataFloat
+	rodataPtr
+	rodataOther // structs, unions and arrays
+)
+
+// SetRodataVariable sets the initial value of a global variable in .rodata
+// (or a .rodata.* section). Unlike InitGlobalVariable(), the variable type is
+// resolved from BTF and the value must match it: integers must have the same
+// size and signedness, enums and floats the same size, and other types
+// (structs, arrays) the same binary size. It must be called before the BPF
+// object is loaded.
+func (m *Module) SetRodataVariable(name string, value interface{}) error {
+	if m.loaded {
+		return errors.New("must be called before the BPF object is loaded")
+	}
+
+	s, err := getGlobalVariableSymbol(m.elf, name)
+	if err != nil {
+		return fmt.Errorf("failed to find rodata variable %s: %w", name, err)
+	}
+	if s.sectionName != ".rodata" && !strings.HasPrefix(s.sectionName, ".rodata.") {
+		return fmt.Errorf("failed to set rodata variable %s: variable is in %s", name, s.sectionName)
+	}
+
+	btf := C.bpf_object__btf(m.obj)
+	if btf == nil {
+		return fmt.Errorf("failed to set rodata variable %s: object has no BTF", name)
+	}
+
+	v, offset, err := findRodataVar(btf, s.sectionName, name)
+	if err != nil {
+		return fmt.Errorf("failed to set rodata variable %s: %w", name, err)
+	}
+	if err := v.check(value); err != nil {
+		return fmt.Errorf("failed to set rodata variable %s: %w", name, err)
+	}
+
+	bpfMap, err := m.GetMap(s.sectionName)
+	if err != nil {
+		return err
+	}
+
+	currMapValue, err := bpfMap.InitialValue()
+	if err != nil {
+		return err
+	}
+	if offset+v.size > len(currMapValue) {
+		return fmt.Errorf("failed to set rodata variable %s: offset %d out of %s", name, offset, s.sectionName)
+	}
+
+	data := bytes.NewBuffer(nil)
+	if err := binary.Write(data, s.byteOrder, value); err != nil {
+		return fmt.Errorf("failed to encode rodata variable %s: %w", name, err)
+	}
+
+	newMapValue := make([]byte, len(currMapValue))
+	copy(newMapValue, currMapValue)
+	copy(newMapValue[offset:offset+v.size], data.Bytes())
+
+	return bpfMap.SetInitialValue(unsafe.Pointer(&newMapValue[0]))
+}
+
+// findRodataVar finds the variable in the BTF data section and returns its
+// description and offset.
+func findRodataVar(btf *C.struct_btf, sectionName string, name string) (*rodataVar, int, error) {
+	sectionNameC := C.CString(sectionName)
+	defer C.free(unsafe.Pointer(sectionNameC))
+
+	datasecID := C.btf__find_by_name_kind(btf, sectionNameC, C.BTF_KIND_DATASEC)
+	if datasecID < 0 {
+		return nil, 0, fmt.Errorf("no BTF data section %s", sectionName)
+	}
+	idC := C.__u32(datasecID)
+
+	for i := C.__u16(0); i < C.cgo_btf_type_vlen(btf, idC); i++ {
+		varID := C.cgo_btf_var_secinfo_type(btf, idC, i)
+		if C.GoString(C.cgo_btf_type_name(btf, varID)) != name {
+			continue
+		}
+
+		// Skip typedefs and modifiers (const volatile)
+		typeID := C.cgo_btf_var_type(btf, varID)
+		for ref := C.cgo_btf_type_ref(btf, typeID); ref != 0; ref = C.cgo_btf_type_ref(btf, typeID) {
+			typeID = ref
+		}
+
+		v := &rodataVar{
+			class: rodataOther,
+			size:  int(C.cgo_btf_var_secinfo_size(btf, idC, i)),
+		}
+
+		switch C.cgo_btf_type_kind(btf, typeID) {
+		case C.BTF_KIND_INT:
+			encoding := C.cgo_btf_int_encoding(btf, typeID)
+			v.class = rodataInt
+			v.signed = encoding&C.BTF_INT_SIGNED != 0
+			v.isChar = encoding&C.BTF_INT_CHAR != 0
+		case C.BTF_KIND_ENUM, C.BTF_KIND_ENUM64:
+			v.class = rodataEnum
+		case C.BTF_KIND_FLOAT:
+			v.class = rodataFloat
+		case C.BTF_KIND_PTR:
+			v.class = rodataPtr
+		}
+
+		return v, int(C.cgo_btf_var_secinfo_offset(btf, idC, i)), nil
+	}
+
+	return nil, 0, fmt.Errorf("variable not found in BTF data section %s", sectionName)
+}
+
+// check verifies that the Go value matches the variable type.
+func (v *rodataVar) check(value interface{}) error {
+	if value == nil {
+		return errors.New("nil value")
+	}
+
+	t := reflect.TypeOf(value)
+	size := binary.Size(value)
+	if size < 0 {
+		return fmt.Errorf("value type %s has no fixed size", t)
+	}
+	if size != v.size {
+		return fmt.Errorf("variable size is %d, value type %s size is %d", v.size, t, size)
+	}
+
+	switch v.class {
+	case rodataPtr:
+		return errors.New("pointer variables can not be set")
+	case rodataInt:
+		switch t.Kind() {
+		case reflect.Bool:
+			if v.signed {
+				return fmt.Errorf("value type %s does not match a signed integer", t)
+			}
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !v.signed && !v.isChar {
+				return fmt.Errorf("value type %s is signed, variable is unsigned", t)
+			}
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.signed && !v.isChar {
+				return fmt.Errorf("value type %s is unsigned, variable is signed", t)
+			}
+		default:
+			return fmt.Errorf("value type %s does not match an integer", t)
+		}
+	case rodataEnum:
+		switch t.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return fmt.Errorf("value type %s does not match an enum", t)
+		}
+	case rodataFloat:
+		if t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+			return fmt.Errorf("value type %s does not match a float", t)
+		}
+	}
+
+	return nil
+}
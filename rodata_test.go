@@ -0,0 +1,52 @@
+package libbpfgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRodataVarCheck(t *testing.T) {
+	type pair struct {
+		A uint32
+		B uint32
+	}
+
+	testCases := []struct {
+		name  string
+		v     rodataVar
+		value interface{}
+		valid bool
+	}{
+		{"u32", rodataVar{class: rodataInt, size: 4}, uint32(1), true},
+		{"u32 from u64", rodataVar{class: rodataInt, size: 4}, uint64(1), false},
+		{"u32 from int32", rodataVar{class: rodataInt, size: 4}, int32(1), false},
+		{"s64", rodataVar{class: rodataInt, size: 8, signed: true}, int64(-1), true},
+		{"s64 from uint64", rodataVar{class: rodataInt, size: 8, signed: true}, uint64(1), false},
+		{"char", rodataVar{class: rodataInt, size: 1, signed: true, isChar: true}, uint8('a'), true},
+		{"bool", rodataVar{class: rodataInt, size: 1}, true, true},
+		{"bool from int", rodataVar{class: rodataInt, size: 1}, 1, false},
+		{"enum", rodataVar{class: rodataEnum, size: 4}, int32(2), true},
+		{"enum from float", rodataVar{class: rodataEnum, size: 4}, float32(2), false},
+		{"double", rodataVar{class: rodataFloat, size: 8}, float64(0.5), true},
+		{"double from u64", rodataVar{class: rodataFloat, size: 8}, uint64(1), false},
+		{"pointer", rodataVar{class: rodataPtr, size: 8}, uint64(0), false},
+		{"struct", rodataVar{class: rodataOther, size: 8}, pair{1, 2}, true},
+		{"array", rodataVar{class: rodataOther, size: 16}, [4]uint32{}, true},
+		{"array too large", rodataVar{class: rodataOther, size: 16}, [5]uint32{}, false},
+		{"slice", rodataVar{class: rodataOther, size: 16}, []uint32{1, 2, 3, 4}, true},
+		{"string", rodataVar{class: rodataOther, size: 4}, "abcd", false},
+		{"nil", rodataVar{class: rodataInt, size: 4}, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.v.check(tc.value)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Package schedext loads, registers and monitors sched_ext schedulers: BPF
+// programs implementing the CPU scheduler through a struct sched_ext_ops
+// struct_ops map (v6.12+):
+//
+//	sched, err := schedext.Load(bpf.NewModuleArgs{BPFObjPath: "scx_simple.bpf.o"}, "simple_ops")
+//	if err != nil {
+//	    return err
+//	}
+//	defer sched.Close()
+//
+//	// Returns once the kernel disabled the scheduler
+//	err = sched.Wait(ctx, time.Second)
+//
+// The kernel disables a scheduler on its own when it misbehaves (a task not
+// scheduled for too long, an invalid dispatch, the SysRq-S key...), and falls
+// back to the fair scheduler: Running() and Wait() tell when that happened.
+// The ops map should be declared in SEC(".struct_ops.link"), so the scheduler
+// is unregistered when its link is destroyed, even if the process crashes.
+package schedext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+// Scheduler is a registered sched_ext scheduler.
+type Scheduler struct {
+	module    *bpf.Module
+	ownModule bool // the module was opened by Load()
+	link      *bpf.BPFLink
+	name      string
+	sysfsDir  string
+	closed    bool
+	mu        sync.Mutex
+}
+
+// Load opens and loads the BPF object, and registers the scheduler of its
+// struct_ops map opsMap. The module is closed by Close().
+func Load(args bpf.NewModuleArgs, opsMap string) (*Scheduler, error) {
+	if err := checkSupport(DefaultSysfsDir); err != nil {
+		return nil, err
+	}
+
+	var (
+		m   *bpf.Module
+		err error
+	)
+	if len(args.BPFObjBuff) > 0 {
+		m, err = bpf.NewModuleFromBufferArgs(args)
+	} else {
+		m, err = bpf.NewModuleFromFileArgs(args)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scheduler: %w", err)
+	}
+
+	if err := m.BPFLoadObject(); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to load scheduler: %w", err)
+	}
+
+	s, err := register(m, opsMap, DefaultSysfsDir)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	s.ownModule = true
+
+	return s, nil
+}
+
+// Register registers the scheduler of the struct_ops map opsMap of a loaded
+// module. Close() unregisters it, and leaves the module open.
+func Register(m *bpf.Module, opsMap string) (*Scheduler, error) {
+	if err := checkSupport(DefaultSysfsDir); err != nil {
+		return nil, err
+	}
+
+	return register(m, opsMap, DefaultSysfsDir)
+}
+
+func checkSupport(sysfsDir string) error {
+	if _, err := os.Stat(sysfsDir); err != nil {
+		return fmt.Errorf("failed to register scheduler: sched_ext: %w", errors.Join(bpf.ErrNotSupportedByKernel, err))
+	}
+
+	return nil
+}
+
+func register(m *bpf.Module, opsMap string, sysfsDir string) (*Scheduler, error) {
+	ops, err := m.GetMap(opsMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register scheduler: %w", err)
+	}
+
+	// Only one scheduler runs at a time, the kernel refuses others with
+	// EBUSY
+	link, err := ops.AttachStructOps()
+	if err != nil {
+		return nil, fmt.Errorf("failed to register scheduler: %w", err)
+	}
+
+	// The scheduler is enabled once registered, unless it failed already
+	name, err := readOps(sysfsDir)
+	if err != nil {
+		_ = link.Destroy()
+		return nil, fmt.Errorf("failed to register scheduler: %w", err)
+	}
+
+	return &Scheduler{
+		module:   m,
+		link:     link,
+		name:     name,
+		sysfsDir: sysfsDir,
+	}, nil
+}
+
+// Name returns the name of the scheduler (the name field of its ops), or ""
+// if the kernel disabled it before it could be read.
+func (s *Scheduler) Name() string {
+	return s.name
+}
+
+// Module returns the module of the scheduler.
+func (s *Scheduler) Module() *bpf.Module {
+	return s.module
+}
+
+// State returns the state of sched_ext, whatever the scheduler running.
+func (s *Scheduler) State() (State, error) {
+	return readState(s.sysfsDir)
+}
+
+// Running reports whether the scheduler is still enabled. The kernel may
+// have disabled it on error, or it may have been closed.
+func (s *Scheduler) Running() (bool, error) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed || s.name == "" {
+		return false, nil
+	}
+
+	state, err := readState(s.sysfsDir)
+	if err != nil {
+		return false, err
+	}
+	if state != StateEnabled {
+		return false, nil
+	}
+
+	name, err := readOps(s.sysfsDir)
+	if err != nil {
+		return false, err
+	}
+
+	return name == s.name, nil
+}
+
+// Wait checks every interval whether the scheduler is still running, and
+// returns nil once it is not, or the context error.
+func (s *Scheduler) Wait(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		running, err := s.Running()
+		if err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close unregisters the scheduler, handing the CPUs back to the fair
+// scheduler, and closes the module if it was opened by Load(). It is safe to
+// call Close multiple times.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	err := s.link.Destroy()
+	if s.ownModule {
+		s.module.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unregister scheduler %s: %w", s.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package schedext
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultSysfsDir is the directory where the kernel reports the state of
+// sched_ext.
+const DefaultSysfsDir = "/sys/kernel/sched_ext"
+
+// State is the state of sched_ext, system-wide, as reported in
+// /sys/kernel/sched_ext/state.
+type State int
+
+const (
+	StateDisabled State = iota
+	StateEnabling
+	StateEnabled
+	StateDisabling
+)
+
+var stateToString = map[State]string{
+	StateDisabled:  "disabled",
+	StateEnabling:  "enabling",
+	StateEnabled:   "enabled",
+	StateDisabling: "disabling",
+}
+
+func (s State) String() string {
+	str, ok := stateToString[s]
+	if !ok {
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+
+	return str
+}
+
+func parseState(s string) (State, error) {
+	s = strings.TrimSpace(s)
+	for state, str := range stateToString {
+		if s == str {
+			return state, nil
+		}
+	}
+
+	return StateDisabled, fmt.Errorf("unknown sched_ext state %q", s)
+}
+
+// readState reads the state of sched_ext from the sysfs directory.
+func readState(sysfsDir string) (State, error) {
+	data, err := os.ReadFile(filepath.Join(sysfsDir, "state"))
+	if err != nil {
+		return StateDisabled, fmt.Errorf("failed to read sched_ext state: %w", err)
+	}
+
+	return parseState(string(data))
+}
+
+// readOps reads the name of the running scheduler from the sysfs directory,
+// or returns "" if none is running.
+func readOps(sysfsDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(sysfsDir, "root", "ops"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read sched_ext scheduler name: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
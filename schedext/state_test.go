@@ -0,0 +1,86 @@
+package schedext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSysfs(t *testing.T, dir, state, ops string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "state"), []byte(state+"\n"), 0o644))
+
+	opsPath := filepath.Join(dir, "root", "ops")
+	if ops == "" {
+		require.NoError(t, os.RemoveAll(filepath.Dir(opsPath)))
+		return
+	}
+	require.NoError(t, os.MkdirAll(filepath.Dir(opsPath), 0o755))
+	require.NoError(t, os.WriteFile(opsPath, []byte(ops+"\n"), 0o644))
+}
+
+func TestParseState(t *testing.T) {
+	for state, str := range stateToString {
+		parsed, err := parseState(str + "\n")
+		require.NoError(t, err)
+		assert.Equal(t, state, parsed)
+		assert.Equal(t, str, state.String())
+	}
+
+	_, err := parseState("bogus")
+	assert.Error(t, err)
+}
+
+func TestRunning(t *testing.T) {
+	dir := t.TempDir()
+	s := &Scheduler{name: "simple", sysfsDir: dir}
+
+	tt := []struct {
+		name    string
+		state   string
+		ops     string
+		running bool
+	}{
+		{"enabled", "enabled", "simple", true},
+		{"other scheduler", "enabled", "rusty", false},
+		{"disabling", "disabling", "simple", false},
+		{"disabled", "disabled", "", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			writeSysfs(t, dir, tc.state, tc.ops)
+
+			running, err := s.Running()
+			require.NoError(t, err)
+			assert.Equal(t, tc.running, running)
+		})
+	}
+
+	// Not running once closed
+	writeSysfs(t, dir, "enabled", "simple")
+	s.closed = true
+	running, err := s.Running()
+	require.NoError(t, err)
+	assert.False(t, running)
+}
+
+func TestReadOps(t *testing.T) {
+	dir := t.TempDir()
+
+	name, err := readOps(dir)
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	writeSysfs(t, dir, "enabled", "simple")
+	name, err = readOps(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "simple", name)
+
+	_, err = readState(t.TempDir())
+	assert.Error(t, err)
+}
@@ -0,0 +1,14 @@
+module github.com/aquasecurity/libbpfgo/selftest/perfbuffers
+
+go 1.21
+
+require (
+	github.com/aquasecurity/libbpfgo v0.0.0
+	github.com/aquasecurity/libbpfgo/helpers v0.4.5
+)
+
+require golang.org/x/sys v0.18.0 // indirect
+
+replace github.com/aquasecurity/libbpfgo => ../../
+
+replace github.com/aquasecurity/libbpfgo/helpers => ../../helpers
@@ -53,6 +53,20 @@ func main() {
 		"qux":    uint32(3000000),
 	})
 
+	// Type checked initialization of .rodata variables, to the same value
+	if err := bpfModule.SetRodataVariable("efg", uint32(80)); err != nil {
+		exitWithErr(err)
+	}
+	if err := bpfModule.SetRodataVariable("efg", uint64(80)); err == nil {
+		exitWithErr(fmt.Errorf("SetRodataVariable should fail on size mismatch"))
+	}
+	if err := bpfModule.SetRodataVariable("baz", uint32(400000)); err == nil {
+		exitWithErr(fmt.Errorf("SetRodataVariable should fail on sign mismatch"))
+	}
+	if err := bpfModule.SetRodataVariable("qux", int32(3000000)); err == nil {
+		exitWithErr(fmt.Errorf("SetRodataVariable should fail on .data variable"))
+	}
+
 	if err := bpfModule.BPFLoadObject(); err != nil {
 		exitWithErr(err)
 	}
@@ -95,6 +109,47 @@ func main() {
 		os.Exit(1)
 	}
 
+	// .data variables can be changed after load, through the mmaped map
+	barVar, err := bpfModule.GlobalVariable("bar")
+	if err != nil {
+		exitWithErr(err)
+	}
+	var bar int32
+	if err := barVar.Get(&bar); err != nil {
+		exitWithErr(err)
+	}
+	if bar != 50000 {
+		exitWithErr(fmt.Errorf("global variable bar is %d, expected 50000", bar))
+	}
+	if err := barVar.Set(int32(0)); err != nil {
+		exitWithErr(err)
+	}
+
+	abcVar, err := bpfModule.GlobalVariable("abc")
+	if err != nil {
+		exitWithErr(err)
+	}
+	if err := abcVar.Set(uint32(0)); err == nil {
+		exitWithErr(fmt.Errorf("GlobalVariable.Set should fail on .rodata variable"))
+	}
+
+	// Events produced before the change may still be queued
+	expect.Sum -= 50000
+	timeout := time.After(10 * time.Second)
+	for event.Sum != expect.Sum {
+		syscall.Mmap(999, 999, 999, 1, 1)
+
+		select {
+		case b = <-eventsChannel:
+		case <-timeout:
+			exitWithErr(fmt.Errorf("timed out waiting for sum %d, last %d", expect.Sum, event.Sum))
+		}
+		err = binary.Read(bytes.NewReader(b), binary.LittleEndian, &event)
+		if err != nil {
+			exitWithErr(err)
+		}
+	}
+
 	rb.Stop()
 	rb.Close()
 }
@@ -0,0 +1,96 @@
+package main
+
+import "C"
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+func exitWithErr(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(-1)
+}
+
+// iterPids returns the pids of the tasks listed by the iterator.
+func iterPids(prog *bpf.BPFProg, opts bpf.IterOpts) map[int]bool {
+	link, err := prog.AttachIter(opts)
+	if err != nil {
+		exitWithErr(err)
+	}
+	defer link.Destroy()
+
+	reader, err := link.Reader()
+	if err != nil {
+		exitWithErr(err)
+	}
+	defer reader.Close()
+
+	pids := make(map[int]bool)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			exitWithErr(fmt.Errorf("invalid data retrieved: %q", scanner.Text()))
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			exitWithErr(err)
+		}
+		pids[pid] = true
+	}
+	if err := scanner.Err(); err != nil {
+		exitWithErr(err)
+	}
+
+	return pids
+}
+
+func main() {
+	bpfModule, err := bpf.NewModuleFromFile("main.bpf.o")
+	if err != nil {
+		exitWithErr(err)
+	}
+	defer bpfModule.Close()
+
+	err = bpfModule.BPFLoadObject()
+	if err != nil {
+		exitWithErr(err)
+	}
+
+	prog, err := bpfModule.GetProgram("iter__task")
+	if err != nil {
+		exitWithErr(err)
+	}
+
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		exitWithErr(err)
+	}
+	defer cmd.Process.Kill()
+	childPid := cmd.Process.Pid
+
+	// The tasks of the child only
+	pids := iterPids(prog, bpf.TaskIter(childPid))
+	if len(pids) != 1 || !pids[childPid] {
+		exitWithErr(fmt.Errorf("TaskIter(%d): expected the child task only, got pids %v", childPid, pids))
+	}
+
+	// All tasks
+	pids = iterPids(prog, bpf.TaskIter(0))
+	if !pids[childPid] || !pids[syscall.Getpid()] {
+		exitWithErr(fmt.Errorf("TaskIter(0): expected all tasks, got %d pids", len(pids)))
+	}
+
+	// The options of other iterators are refused
+	if _, err := prog.AttachIter(bpf.CgroupIterByID(1, bpf.BPFIterDescendantsPre)); err == nil {
+		exitWithErr(fmt.Errorf("CgroupIterByID: expected task iterator to refuse cgroup options"))
+	}
+}
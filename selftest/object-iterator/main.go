@@ -72,4 +72,50 @@ func main() {
 			os.Exit(-1)
 		}
 	}
+
+	// Indices follow the ELF order, and agree with the iterator
+	expectedProgramOrder := []string{"mmap_fentry", "execve_fentry", "execveat_fentry"}
+	if bpfModule.ProgramCount() != len(expectedProgramOrder) {
+		fmt.Fprintf(os.Stderr, "unexpected program count: %d\n", bpfModule.ProgramCount())
+		os.Exit(-1)
+	}
+	for i, name := range expectedProgramOrder {
+		prog, err := bpfModule.ProgramByIndex(i)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		if prog.Name() != name || prog.Index() != i {
+			fmt.Fprintf(os.Stderr, "unexpected program at index %d: %s\n", i, prog.Name())
+			os.Exit(-1)
+		}
+		index, err := bpfModule.ProgramIndex(name)
+		if err != nil || index != i {
+			fmt.Fprintf(os.Stderr, "unexpected index of program %s: %d (%v)\n", name, index, err)
+			os.Exit(-1)
+		}
+	}
+
+	expectedMapOrder := []string{"one", "two"}
+	for i, name := range expectedMapOrder {
+		bpfMap, err := bpfModule.MapByIndex(i)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		if bpfMap.Name() != name || bpfMap.Index() != i {
+			fmt.Fprintf(os.Stderr, "unexpected map at index %d: %s\n", i, bpfMap.Name())
+			os.Exit(-1)
+		}
+		index, err := bpfModule.MapIndex(name)
+		if err != nil || index != i {
+			fmt.Fprintf(os.Stderr, "unexpected index of map %s: %d (%v)\n", name, index, err)
+			os.Exit(-1)
+		}
+	}
+
+	if _, err := bpfModule.MapByIndex(bpfModule.MapCount()); err == nil {
+		fmt.Fprintln(os.Stderr, "map index out of range did not fail")
+		os.Exit(-1)
+	}
 }
@@ -0,0 +1 @@
+../common/run-5.8.sh
\ No newline at end of file
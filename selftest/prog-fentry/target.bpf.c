@@ -0,0 +1,18 @@
+//+build ignore
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#ifdef __TARGET_ARCH_amd64
+SEC("kprobe/__x64_sys_mmap")
+#elif defined(__TARGET_ARCH_arm64)
+SEC("kprobe/__arm64_sys_mmap")
+#endif
+int target_prog(struct pt_regs *ctx)
+{
+    return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
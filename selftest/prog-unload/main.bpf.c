@@ -0,0 +1,19 @@
+//+build ignore
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+
+SEC("tc")
+int expensive(struct __sk_buff *skb)
+{
+    return 1;
+}
+
+SEC("tc")
+int cheap(struct __sk_buff *skb)
+{
+    return 2;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,101 @@
+package main
+
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+func run(prog *bpf.BPFProg) (uint32, error) {
+	opts := bpf.RunOpts{
+		DataIn:     make([]byte, 16),
+		DataSizeIn: 16,
+		Repeat:     1,
+	}
+	err := prog.Run(&opts)
+
+	return opts.RetVal, err
+}
+
+func main() {
+	bpfModule, err := bpf.NewModuleFromFile("main.bpf.o")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer bpfModule.Close()
+
+	err = bpfModule.BPFLoadObject()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	expensive, err := bpfModule.GetProgram("expensive")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	cheap, err := bpfModule.GetProgram("cheap")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	err = expensive.Unload()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	// Programs returned before and after Unload() agree
+	again, err := bpfModule.GetProgram("expensive")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if !expensive.IsUnloaded() || !again.IsUnloaded() || again.FileDescriptor() != -1 {
+		fmt.Fprintln(os.Stderr, "program expensive should be unloaded")
+		os.Exit(-1)
+	}
+
+	if _, err := expensive.Info(); !errors.Is(err, bpf.ErrProgUnloaded) {
+		fmt.Fprintf(os.Stderr, "getting the info of an unloaded program should fail with ErrProgUnloaded, got %v\n", err)
+		os.Exit(-1)
+	}
+	if err := expensive.Pin("/sys/fs/bpf/prog-unload"); !errors.Is(err, bpf.ErrProgUnloaded) {
+		fmt.Fprintf(os.Stderr, "pinning an unloaded program should fail with ErrProgUnloaded, got %v\n", err)
+		os.Exit(-1)
+	}
+	if _, err := expensive.AttachTCX("lo"); !errors.Is(err, bpf.ErrProgUnloaded) {
+		fmt.Fprintf(os.Stderr, "attaching an unloaded program should fail with ErrProgUnloaded, got %v\n", err)
+		os.Exit(-1)
+	}
+
+	// Unloading twice is fine
+	err = expensive.Unload()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	if _, err := run(expensive); !errors.Is(err, bpf.ErrProgUnloaded) {
+		fmt.Fprintf(os.Stderr, "running an unloaded program should fail with ErrProgUnloaded, got %v\n", err)
+		os.Exit(-1)
+	}
+
+	// The other program is still usable
+	retVal, err := run(cheap)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if retVal != 2 {
+		fmt.Fprintf(os.Stderr, "retVal %d should be 2\n", retVal)
+		os.Exit(-1)
+	}
+}
@@ -0,0 +1 @@
+../common/run-4.12.sh
\ No newline at end of file
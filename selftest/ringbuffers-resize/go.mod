@@ -0,0 +1,7 @@
+module github.com/aquasecurity/libbpfgo/selftest/ringbuffers-resize
+
+go 1.21
+
+require github.com/aquasecurity/libbpfgo v0.0.0
+
+replace github.com/aquasecurity/libbpfgo => ../../
@@ -0,0 +1,36 @@
+//+build ignore
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+struct ringbuf {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, 4096);
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY_OF_MAPS);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __array(values, struct ringbuf);
+} events SEC(".maps");
+
+SEC("kprobe/sys_mmap")
+int kprobe__sys_mmap(struct pt_regs *ctx)
+{
+    u32 zero = 0;
+    void *rb;
+
+    rb = bpf_map_lookup_elem(&events, &zero);
+    if (!rb)
+        return 0;
+
+    u32 value = 2021;
+    bpf_ringbuf_output(rb, &value, sizeof(value), 0);
+
+    return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
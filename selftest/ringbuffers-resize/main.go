@@ -0,0 +1,110 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+// receive waits for count records produced by mmap calls.
+func receive(eventsChannel chan []byte, count int) error {
+	for i := 0; i < count; i++ {
+		syscall.Mmap(999, 999, 999, 1, 1)
+
+		select {
+		case b, ok := <-eventsChannel:
+			if !ok {
+				return fmt.Errorf("events channel closed")
+			}
+			if binary.LittleEndian.Uint32(b) != 2021 {
+				return fmt.Errorf("invalid data retrieved")
+			}
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("timed out waiting for record %d", i)
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	bpfModule, err := bpf.NewModuleFromFile("main.bpf.o")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer bpfModule.Close()
+
+	err = bpfModule.BPFLoadObject()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	prog, err := bpfModule.GetProgram("kprobe__sys_mmap")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	_, err = prog.AttachKprobe(fmt.Sprintf("__%s_sys_mmap", ksymArch()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	eventsChannel := make(chan []byte, 16)
+	rb, err := bpfModule.InitResizableRingBuf("events", 0, os.Getpagesize(), eventsChannel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	rb.Poll(300)
+
+	if err := receive(eventsChannel, 3); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	// Producers move to the larger ring buffer without restarting
+	err = rb.Resize(context.Background(), 1<<20)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if rb.Size() != 1<<20 {
+		fmt.Fprintf(os.Stderr, "unexpected ring buffer size %d\n", rb.Size())
+		os.Exit(-1)
+	}
+
+	if err := receive(eventsChannel, 3); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	if err := rb.Resize(context.Background(), 1000); err == nil {
+		fmt.Fprintln(os.Stderr, "resize to an invalid size did not fail")
+		os.Exit(-1)
+	}
+
+	rb.Close()
+	rb.Close()
+}
+
+func ksymArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "arm64":
+		return "arm64"
+	default:
+		panic("unsupported architecture")
+	}
+}
@@ -0,0 +1,42 @@
+//+build ignore
+
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 512);
+    __type(key, u32);
+    __type(value, u8);
+} syscall_filter SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 512);
+    __type(key, u32);
+    __type(value, u64);
+} counts SEC(".maps");
+
+SEC("raw_tp/sys_enter")
+int sys_enter(struct bpf_raw_tracepoint_args *ctx)
+{
+    u32 id = ctx->args[1];
+    u64 one = 1, *count;
+    u8 *traced;
+
+    traced = bpf_map_lookup_elem(&syscall_filter, &id);
+    if (!traced || !*traced)
+        return 0;
+
+    count = bpf_map_lookup_elem(&counts, &id);
+    if (count) {
+        __sync_fetch_and_add(count, 1);
+        return 0;
+    }
+    bpf_map_update_elem(&counts, &id, &one, BPF_NOEXIST);
+
+    return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,91 @@
+package main
+
+import "C"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+)
+
+func main() {
+	bpfModule, err := bpf.NewModuleFromFile("main.bpf.o")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer bpfModule.Close()
+
+	err = bpfModule.BPFLoadObject()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	prog, err := bpfModule.GetProgram("sys_enter")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	filterMap, err := bpfModule.GetMap("syscall_filter")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	_, err = prog.AttachSysEnter(bpf.SyscallFilter{
+		Syscalls: []uint32{syscall.SYS_GETPID, syscall.SYS_GETPPID},
+		Map:      filterMap,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	// Several syscalls without a map can not be filtered
+	_, err = prog.AttachSysEnter(bpf.SyscallFilter{
+		Syscalls: []uint32{syscall.SYS_GETPID, syscall.SYS_GETPPID},
+	})
+	if err == nil {
+		fmt.Fprintln(os.Stderr, "attach should fail without filter map")
+		os.Exit(-1)
+	}
+
+	for i := 0; i < 10; i++ {
+		syscall.Getpid()
+		syscall.Getppid()
+		syscall.Getuid()
+	}
+
+	countsMap, err := bpfModule.GetMap("counts")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	// Other processes may call the traced syscalls too
+	traced := map[uint32]bool{syscall.SYS_GETPID: false, syscall.SYS_GETPPID: false}
+	iter := countsMap.Iterator()
+	for iter.Next() {
+		id := binary.LittleEndian.Uint32(iter.Key())
+		if _, ok := traced[id]; !ok {
+			fmt.Fprintf(os.Stderr, "syscall %d should have been filtered\n", id)
+			os.Exit(-1)
+		}
+		traced[id] = true
+	}
+	if err := iter.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	for id, seen := range traced {
+		if !seen {
+			fmt.Fprintf(os.Stderr, "syscall %d was not traced\n", id)
+			os.Exit(-1)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Socket cookies
+//
+// The kernel gives each socket a unique 64 bits cookie, never reused, which
+// programs read with bpf_get_socket_cookie() to identify the sockets in their
+// events and maps. Userspace reads the cookie of a socket with SO_COOKIE
+// (v4.12), so events are correlated to Go connections:
+//
+//	cookie, err := libbpfgo.ConnSocketCookie(conn)
+//	...
+//	conns[cookie] = conn // events carry bpf_get_socket_cookie(skb)
+//
+// Maps keyed by cookie, including sockhashes, use the cookie as a __u64 in
+// host byte order.
+//
+
+// soCookie is SO_COOKIE, missing from the syscall package.
+const soCookie = 57
+
+// SocketCookie returns the cookie of the socket.
+func SocketCookie(sockFd int) (uint64, error) {
+	var cookie uint64
+	size := uint32(unsafe.Sizeof(cookie))
+
+	_, _, errno := syscall.Syscall6(
+		syscall.SYS_GETSOCKOPT,
+		uintptr(sockFd),
+		syscall.SOL_SOCKET,
+		soCookie,
+		uintptr(unsafe.Pointer(&cookie)),
+		uintptr(unsafe.Pointer(&size)),
+		0,
+	)
+	if errno != 0 {
+		return 0, fmt.Errorf("failed to get cookie of socket %d: %w", sockFd, errno)
+	}
+
+	return cookie, nil
+}
+
+// ConnSocketCookie returns the cookie of the socket of the connection. conn
+// is a connection of the net package (*net.TCPConn, *net.UDPConn,
+// *net.UnixConn, ...), or wraps one with a NetConn() method, as *tls.Conn.
+func ConnSocketCookie(conn net.Conn) (uint64, error) {
+	var cookie uint64
+	err := controlConn(conn, func(fd int) error {
+		var err error
+		cookie, err = SocketCookie(fd)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return cookie, nil
+}
+
+// controlConn runs f with the file descriptor of the socket of conn.
+func controlConn(conn net.Conn, f func(fd int) error) error {
+	for {
+		wrapped, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapped.NetConn()
+	}
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("failed to get socket of %T: %w", conn, errors.ErrUnsupported)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("failed to get socket of %T: %w", conn, err)
+	}
+
+	var errF error
+	err = raw.Control(func(fd uintptr) {
+		errF = f(int(fd))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get socket of %T: %w", conn, err)
+	}
+
+	return errF
+}
+
+// SocketCookieKey encodes the cookie as the key of a map keyed by cookie.
+func SocketCookieKey(cookie uint64) []byte {
+	key := make([]byte, 8)
+	binary.NativeEndian.PutUint64(key, cookie)
+
+	return key
+}
+
+// UpdateSocketByCookie adds the socket of the connection to the sockhash,
+// keyed by its cookie, and returns the cookie. The sockhash holds its own
+// reference on the socket, until deleted by cookie or the socket closes.
+func (m *BPFMap) UpdateSocketByCookie(conn net.Conn) (uint64, error) {
+	if m.Type() != MapTypeSockHash || m.KeySize() != 8 {
+		return 0, fmt.Errorf("failed to add socket to map %s: not a sockhash keyed by cookie: %w", m.Name(), syscall.EINVAL)
+	}
+
+	var cookie uint64
+	err := controlConn(conn, func(fd int) error {
+		var err error
+		cookie, err = SocketCookie(fd)
+		if err != nil {
+			return err
+		}
+
+		key := SocketCookieKey(cookie)
+		value := make([]byte, m.ValueSize())
+		switch len(value) {
+		case 4:
+			binary.NativeEndian.PutUint32(value, uint32(fd))
+		case 8:
+			binary.NativeEndian.PutUint64(value, uint64(fd))
+		default:
+			return fmt.Errorf("value size %d: %w", len(value), syscall.EINVAL)
+		}
+
+		return m.Update(unsafe.Pointer(&key[0]), unsafe.Pointer(&value[0]))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to add socket to map %s: %w", m.Name(), err)
+	}
+
+	return cookie, nil
+}
@@ -0,0 +1,65 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wrappedConn wraps a connection, as *tls.Conn.
+type wrappedConn struct {
+	net.Conn
+}
+
+func (c wrappedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func TestConnSocketCookie(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	server, err := ln.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	clientCookie, err := ConnSocketCookie(client)
+	if errors.Is(err, syscall.ENOPROTOOPT) {
+		t.Skip("SO_COOKIE not supported")
+	}
+	require.NoError(t, err)
+	serverCookie, err := ConnSocketCookie(wrappedConn{server})
+	require.NoError(t, err)
+
+	assert.NotZero(t, clientCookie)
+	assert.NotEqual(t, clientCookie, serverCookie)
+
+	// Stable for a socket
+	again, err := ConnSocketCookie(client)
+	require.NoError(t, err)
+	assert.Equal(t, clientCookie, again)
+
+	p1, p2 := net.Pipe()
+	defer p1.Close()
+	defer p2.Close()
+	_, err = ConnSocketCookie(p1)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+
+	_, err = SocketCookie(-1)
+	assert.ErrorIs(t, err, syscall.EBADF)
+}
+
+func TestSocketCookieKey(t *testing.T) {
+	key := SocketCookieKey(0x0102030405060708)
+	assert.Len(t, key, 8)
+	assert.Equal(t, uint64(0x0102030405060708), binary.NativeEndian.Uint64(key))
+}
@@ -0,0 +1,195 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+//
+// Socket filters
+//
+
+// soAttachBPF is SO_ATTACH_BPF, missing from the syscall package.
+const soAttachBPF = 50
+
+// socketFilterLogSize is the size of the verifier log of a socket filter
+// failing to load.
+const socketFilterLogSize = 64 * 1024
+
+// LoadSocketFilter loads eBPF instructions, as returned by ConvertCBPF, as a
+// BPF_PROG_TYPE_SOCKET_FILTER program, and returns its file descriptor. The
+// caller owns the file descriptor.
+func LoadSocketFilter(insns []byte, license string) (int, error) {
+	if len(insns) == 0 || len(insns)%bpfInsnSize != 0 {
+		return -1, fmt.Errorf("failed to load socket filter: %d bytes of instructions: %w", len(insns), syscall.EINVAL)
+	}
+
+	licenseC := C.CString(license)
+	defer C.free(unsafe.Pointer(licenseC))
+
+	insnsC := C.CBytes(insns)
+	defer C.free(insnsC)
+	insnCntC := C.__u32(len(insns) / bpfInsnSize)
+
+	fdC := C.cgo_load_socket_filter(insnsC, insnCntC, licenseC, nil, 0)
+	if fdC >= 0 {
+		return int(fdC), nil
+	}
+
+	// Load again with the verifier log, only needed to classify the error
+	logC := (*C.char)(C.calloc(1, socketFilterLogSize))
+	if logC == nil {
+		return -1, fmt.Errorf("failed to load socket filter: %w", syscall.Errno(-fdC))
+	}
+	defer C.free(unsafe.Pointer(logC))
+
+	fdC = C.cgo_load_socket_filter(insnsC, insnCntC, licenseC, logC, socketFilterLogSize)
+	if fdC >= 0 {
+		return int(fdC), nil
+	}
+
+	return -1, fmt.Errorf("failed to load socket filter: %w", classifyError(opLoad, syscall.Errno(-fdC), C.GoString(logC)))
+}
+
+// AttachSocketFilterFD attaches the socket filter program to the socket
+// (SO_ATTACH_BPF), replacing its filter if any. The socket keeps its own
+// reference on the program.
+func AttachSocketFilterFD(sockFd int, progFd int) error {
+	if err := syscall.SetsockoptInt(sockFd, syscall.SOL_SOCKET, soAttachBPF, progFd); err != nil {
+		return fmt.Errorf("failed to attach socket filter to socket %d: %w", sockFd, err)
+	}
+
+	return nil
+}
+
+// AttachClassicSocketFilter attaches the classic BPF filter to the socket
+// (SO_ATTACH_FILTER), replacing its filter if any. The kernel validates the
+// filter.
+func AttachClassicSocketFilter(sockFd int, filter []CBPFInstruction) error {
+	if len(filter) == 0 || len(filter) > cbpfMaxInsns {
+		return fmt.Errorf("failed to attach classic socket filter to socket %d: %d instructions: %w", sockFd, len(filter), syscall.EINVAL)
+	}
+
+	prog := syscall.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: (*syscall.SockFilter)(unsafe.Pointer(&filter[0])),
+	}
+	_, _, errno := syscall.Syscall6(
+		syscall.SYS_SETSOCKOPT,
+		uintptr(sockFd),
+		syscall.SOL_SOCKET,
+		syscall.SO_ATTACH_FILTER,
+		uintptr(unsafe.Pointer(&prog)),
+		unsafe.Sizeof(prog),
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to attach classic socket filter to socket %d: %w", sockFd, errno)
+	}
+
+	return nil
+}
+
+// DetachSocketFilter detaches the filter, eBPF or classic, of the socket.
+func DetachSocketFilter(sockFd int) error {
+	if err := syscall.SetsockoptInt(sockFd, syscall.SOL_SOCKET, syscall.SO_DETACH_FILTER, 0); err != nil {
+		return fmt.Errorf("failed to detach socket filter of socket %d: %w", sockFd, err)
+	}
+
+	return nil
+}
+
+// AttachSocketFilter attaches the socket filter program to the socket.
+func (p *BPFProg) AttachSocketFilter(sockFd int) error {
+	if err := p.checkNotUnloaded("attach"); err != nil {
+		return err
+	}
+
+	if p.GetType() != BPFProgTypeSocketFilter {
+		return fmt.Errorf("failed to attach program %s to socket %d: not a socket filter: %w", p.Name(), sockFd, syscall.EINVAL)
+	}
+
+	return AttachSocketFilterFD(sockFd, p.FileDescriptor())
+}
+
+// SocketFilter is a classic BPF filter attached to a socket, converted to
+// eBPF or as is.
+type SocketFilter struct {
+	sockFd  int
+	progFd  int
+	classic bool
+}
+
+// AttachCBPFSocketFilter attaches the classic BPF filter to the socket,
+// converted to an eBPF socket filter program, or as a classic filter if the
+// filter loads ancillary data, or the program can't be loaded or attached
+// (no bpf() syscall, unprivileged_bpf_disabled, kernel older than v3.19).
+func AttachCBPFSocketFilter(sockFd int, filter []CBPFInstruction) (*SocketFilter, error) {
+	f := &SocketFilter{sockFd: sockFd, progFd: -1}
+
+	insns, err := ConvertCBPF(filter)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+	case err != nil:
+		return nil, err
+	default:
+		progFd, err := LoadSocketFilter(insns, "GPL")
+		if err == nil {
+			err = AttachSocketFilterFD(sockFd, progFd)
+			if err == nil {
+				f.progFd = progFd
+				return f, nil
+			}
+			syscall.Close(progFd)
+		}
+		if !classicFallback(err) {
+			return nil, err
+		}
+	}
+
+	if err := AttachClassicSocketFilter(sockFd, filter); err != nil {
+		return nil, err
+	}
+	f.classic = true
+
+	return f, nil
+}
+
+// classicFallback reports whether the failure to load or attach an eBPF
+// socket filter calls for a classic filter instead.
+func classicFallback(err error) bool {
+	return errors.Is(err, ErrPermission) ||
+		errors.Is(err, ErrNotSupportedByKernel) ||
+		errors.Is(err, syscall.EPERM) ||
+		errors.Is(err, syscall.ENOPROTOOPT)
+}
+
+// Classic reports whether the filter is attached as a classic filter.
+func (f *SocketFilter) Classic() bool {
+	return f.classic
+}
+
+// ProgramFD returns the file descriptor of the eBPF program of the filter, or
+// -1 if it is attached as a classic filter or detached.
+func (f *SocketFilter) ProgramFD() int {
+	return f.progFd
+}
+
+// Detach detaches the filter from the socket, and closes its program.
+func (f *SocketFilter) Detach() error {
+	err := DetachSocketFilter(f.sockFd)
+	if f.progFd >= 0 {
+		syscall.Close(f.progFd)
+		f.progFd = -1
+	}
+
+	return err
+}
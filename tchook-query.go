@@ -0,0 +1,382 @@
+package libbpfgo
+
+/*
+#cgo LDFLAGS: -lelf -lz
+#include "libbpfgo.h"
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+//
+// TC/TCX hook listing
+//
+// Programs may be attached to the ingress and egress hooks of an interface
+// either as TCX links/programs (kernel 6.6+) or as classic cls_bpf filters of
+// the clsact qdisc. Both can coexist, TCX programs run first.
+//
+
+// TCXProgram is a program attached to a TCX or netkit hook, in execution
+// order.
+type TCXProgram struct {
+	ProgID      uint32
+	LinkID      uint32 // 0 if attached without a link
+	AttachFlags uint32
+}
+
+// TCXQueryResult is the state of a TCX or netkit hook.
+type TCXQueryResult struct {
+	// Revision is bumped by the kernel on every change of the hook, and can
+	// be given as expected revision to detect concurrent updates.
+	Revision uint64
+	Programs []TCXProgram
+}
+
+// QueryTCX lists the programs attached to the TCX ingress or egress hook of
+// the interface.
+func QueryTCX(ifindex int, attachPoint TcAttachPoint) (*TCXQueryResult, error) {
+	var attachType BPFAttachType
+	switch attachPoint {
+	case BPFTcIngress:
+		attachType = BPFAttachTypeTCXIngress
+	case BPFTcEgress:
+		attachType = BPFAttachTypeTCXEgress
+	default:
+		return nil, fmt.Errorf("failed to query tcx hook: invalid attach point %d", attachPoint)
+	}
+
+	return queryMprog(ifindex, attachType)
+}
+
+// QueryNetkit lists the programs attached to the primary or peer hook
+// (BPFAttachTypeNetkitPrimary, BPFAttachTypeNetkitPeer) of a netkit device,
+// given by the interface index of its primary device.
+func QueryNetkit(ifindex int, attachType BPFAttachType) (*TCXQueryResult, error) {
+	if attachType != BPFAttachTypeNetkitPrimary && attachType != BPFAttachTypeNetkitPeer {
+		return nil, fmt.Errorf("failed to query netkit hook: invalid attach type %s", attachType)
+	}
+
+	return queryMprog(ifindex, attachType)
+}
+
+// queryMprog lists the programs of a multi-program hook of an interface
+// (TCX, netkit).
+func queryMprog(ifindex int, attachType BPFAttachType) (*TCXQueryResult, error) {
+	return queryRetrying(
+		func() (uint32, error) {
+			return queryMprogCount(ifindex, attachType)
+		},
+		func(count uint32) (*TCXQueryResult, error) {
+			return queryMprogPrograms(ifindex, attachType, count)
+		},
+	)
+}
+
+func queryMprogCount(ifindex int, attachType BPFAttachType) (uint32, error) {
+	optsC, errno := C.cgo_bpf_prog_query_opts_new(nil, nil, nil, 0)
+	if optsC == nil {
+		return 0, fmt.Errorf("failed to create bpf_prog_query_opts: %w", errno)
+	}
+	defer C.cgo_bpf_prog_query_opts_free(optsC)
+
+	retC := C.bpf_prog_query_opts(C.int(ifindex), uint32(attachType), optsC)
+	if retC < 0 {
+		return 0, fmt.Errorf("failed to query %s hook: %w", attachType, syscall.Errno(-retC))
+	}
+
+	return uint32(C.cgo_bpf_prog_query_opts_count(optsC)), nil
+}
+
+func queryMprogPrograms(ifindex int, attachType BPFAttachType, count uint32) (*TCXQueryResult, error) {
+	// One extra element, so a hook that is empty now still gets arrays
+	size := C.size_t(count + 1)
+	elemSize := C.size_t(unsafe.Sizeof(C.__u32(0)))
+
+	progIDsC := C.calloc(size, elemSize)
+	attachFlagsC := C.calloc(size, elemSize)
+	linkIDsC := C.calloc(size, elemSize)
+	defer C.free(progIDsC)
+	defer C.free(attachFlagsC)
+	defer C.free(linkIDsC)
+	if progIDsC == nil || attachFlagsC == nil || linkIDsC == nil {
+		return nil, fmt.Errorf("failed to allocate memory for %s query", attachType)
+	}
+
+	optsC, errno := C.cgo_bpf_prog_query_opts_new(
+		(*C.__u32)(progIDsC),
+		(*C.__u32)(attachFlagsC),
+		(*C.__u32)(linkIDsC),
+		C.__u32(count+1),
+	)
+	if optsC == nil {
+		return nil, fmt.Errorf("failed to create bpf_prog_query_opts: %w", errno)
+	}
+	defer C.cgo_bpf_prog_query_opts_free(optsC)
+
+	retC := C.bpf_prog_query_opts(C.int(ifindex), uint32(attachType), optsC)
+	if retC < 0 {
+		return nil, fmt.Errorf("failed to query %s hook: %w", attachType, syscall.Errno(-retC))
+	}
+
+	n := uint32(C.cgo_bpf_prog_query_opts_count(optsC))
+	progIDs := unsafe.Slice((*uint32)(progIDsC), n)
+	attachFlags := unsafe.Slice((*uint32)(attachFlagsC), n)
+	linkIDs := unsafe.Slice((*uint32)(linkIDsC), n)
+
+	result := &TCXQueryResult{
+		Revision: uint64(C.cgo_bpf_prog_query_opts_revision(optsC)),
+		Programs: make([]TCXProgram, 0, n),
+	}
+	for i := range progIDs {
+		result.Programs = append(result.Programs, TCXProgram{
+			ProgID:      progIDs[i],
+			LinkID:      linkIDs[i],
+			AttachFlags: attachFlags[i],
+		})
+	}
+
+	return result, nil
+}
+
+// TcFilter is a cls_bpf filter attached to a clsact qdisc hook.
+type TcFilter struct {
+	Priority     uint   // same as TcOpts.Priority
+	Handle       uint   // same as TcOpts.Handle
+	Protocol     uint16 // ETH_P_* in host byte order
+	ProgID       uint32
+	Name         string // usually "<object file>:[<section>]"
+	Tag          [8]byte
+	DirectAction bool
+}
+
+// netlink constants missing from the syscall package
+const (
+	tcaKind             = 1      // TCA_KIND
+	tcaOptions          = 2      // TCA_OPTIONS
+	tcaBPFName          = 7      // TCA_BPF_NAME
+	tcaBPFFlags         = 8      // TCA_BPF_FLAGS
+	tcaBPFTag           = 10     // TCA_BPF_TAG
+	tcaBPFID            = 11     // TCA_BPF_ID
+	tcaBPFFlagActDirect = 1 << 0 // TCA_BPF_FLAG_ACT_DIRECT
+
+	tcHClsact     = 0xfffffff1 // TC_H_CLSACT
+	tcHMinIngress = 0xfff2     // TC_H_MIN_INGRESS
+	tcHMinEgress  = 0xfff3     // TC_H_MIN_EGRESS
+
+	nlaTypeMask  = 0x3fff // ~(NLA_F_NESTED | NLA_F_NET_BYTEORDER)
+	sizeofTcMsg  = 20
+	sizeofNlAttr = 4
+)
+
+// ListTcFilters lists the cls_bpf filters attached to the ingress or egress
+// hook of the clsact qdisc of the interface. An interface without clsact
+// qdisc has no filters.
+func ListTcFilters(ifindex int, attachPoint TcAttachPoint) ([]TcFilter, error) {
+	var parent uint32
+	switch attachPoint {
+	case BPFTcIngress:
+		parent = tcHClsact&0xffff0000 | tcHMinIngress
+	case BPFTcEgress:
+		parent = tcHClsact&0xffff0000 | tcHMinEgress
+	default:
+		return nil, fmt.Errorf("failed to list tc filters: invalid attach point %d", attachPoint)
+	}
+
+	msgs, err := netlinkDump(syscall.RTM_GETTFILTER, newTcMsg(ifindex, parent))
+	if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.EINVAL) {
+		// No clsact qdisc
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tc filters: %w", err)
+	}
+
+	return parseTcFilters(msgs), nil
+}
+
+func newTcMsg(ifindex int, parent uint32) []byte {
+	b := make([]byte, sizeofTcMsg)
+	b[0] = syscall.AF_UNSPEC
+	binary.NativeEndian.PutUint32(b[4:], uint32(ifindex))
+	binary.NativeEndian.PutUint32(b[12:], parent)
+
+	return b
+}
+
+// parseTcFilters parses the RTM_NEWTFILTER messages of a filter dump,
+// skipping non bpf filters and the per priority chain entries.
+func parseTcFilters(msgs []syscall.NetlinkMessage) []TcFilter {
+	var filters []TcFilter
+
+	for i := range msgs {
+		msg := &msgs[i]
+		if msg.Header.Type != syscall.RTM_NEWTFILTER || len(msg.Data) < sizeofTcMsg {
+			continue
+		}
+
+		attrs := parseNetlinkAttrs(msg.Data[sizeofTcMsg:])
+		if string(trimNull(attrs[tcaKind])) != "bpf" {
+			continue
+		}
+		options := parseNetlinkAttrs(attrs[tcaOptions])
+		if len(options[tcaBPFID]) < 4 {
+			continue
+		}
+
+		info := binary.NativeEndian.Uint32(msg.Data[16:])
+		filter := TcFilter{
+			Priority: uint(info >> 16),
+			Handle:   uint(binary.NativeEndian.Uint32(msg.Data[8:])),
+			Protocol: ntohs(uint16(info)),
+			ProgID:   binary.NativeEndian.Uint32(options[tcaBPFID]),
+			Name:     string(trimNull(options[tcaBPFName])),
+		}
+		copy(filter.Tag[:], options[tcaBPFTag])
+		if flags := options[tcaBPFFlags]; len(flags) >= 4 {
+			filter.DirectAction = binary.NativeEndian.Uint32(flags)&tcaBPFFlagActDirect != 0
+		}
+
+		filters = append(filters, filter)
+	}
+
+	return filters
+}
+
+func ntohs(v uint16) uint16 {
+	b := make([]byte, 2)
+	binary.NativeEndian.PutUint16(b, v)
+
+	return binary.BigEndian.Uint16(b)
+}
+
+// parseNetlinkAttrs parses a netlink attribute stream into a map of attribute
+// type to payload. Malformed trailing data is ignored.
+func parseNetlinkAttrs(b []byte) map[uint16][]byte {
+	attrs := make(map[uint16][]byte)
+
+	for len(b) >= sizeofNlAttr {
+		attrLen := int(binary.NativeEndian.Uint16(b[0:]))
+		attrType := binary.NativeEndian.Uint16(b[2:]) & nlaTypeMask
+		if attrLen < sizeofNlAttr || attrLen > len(b) {
+			break
+		}
+		attrs[attrType] = b[sizeofNlAttr:attrLen]
+
+		aligned := (attrLen + syscall.NLMSG_ALIGNTO - 1) &^ (syscall.NLMSG_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+
+	return attrs
+}
+
+// netlinkDump sends a rtnetlink dump request and returns the messages of
+// the reply.
+func netlinkDump(msgType uint16, payload []byte) ([]syscall.NetlinkMessage, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create netlink socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to bind netlink socket: %w", err)
+	}
+
+	const seq = 1
+	req := make([]byte, syscall.NLMSG_HDRLEN+len(payload))
+	binary.NativeEndian.PutUint32(req[0:], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:], msgType)
+	binary.NativeEndian.PutUint16(req[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:], seq)
+	copy(req[syscall.NLMSG_HDRLEN:], payload)
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to send netlink request: %w", err)
+	}
+
+	var result []syscall.NetlinkMessage
+	buf := make([]byte, syscall.Getpagesize()*4)
+
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive netlink reply: %w", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse netlink reply: %w", err)
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Seq != seq {
+				continue
+			}
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return result, nil
+			case syscall.NLMSG_ERROR:
+				if len(msg.Data) < 4 {
+					return nil, fmt.Errorf("failed to parse netlink error")
+				}
+				if errno := int32(binary.NativeEndian.Uint32(msg.Data)); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				return result, nil
+			}
+
+			// The buffer is reused by the next read
+			msg.Data = append([]byte(nil), msg.Data...)
+			result = append(result, msg)
+		}
+	}
+}
+
+// TcHookPrograms lists the programs attached to a hook of an interface.
+type TcHookPrograms struct {
+	AttachPoint TcAttachPoint
+	TCX         *TCXQueryResult // nil if the kernel does not support TCX
+	Filters     []TcFilter
+}
+
+// ListTcPrograms lists the TCX programs and cls_bpf filters attached to the
+// ingress and egress hooks of the interface, so the datapath state can be
+// asserted and programs of other tools detected.
+func ListTcPrograms(deviceName string) ([]TcHookPrograms, error) {
+	iface, err := net.InterfaceByName(deviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find device by name %s: %w", deviceName, err)
+	}
+
+	var hooks []TcHookPrograms
+	for _, attachPoint := range []TcAttachPoint{BPFTcIngress, BPFTcEgress} {
+		hook := TcHookPrograms{AttachPoint: attachPoint}
+
+		hook.TCX, err = QueryTCX(iface.Index, attachPoint)
+		if err != nil && !errors.Is(err, syscall.EINVAL) {
+			return nil, err
+		}
+
+		hook.Filters, err = ListTcFilters(iface.Index, attachPoint)
+		if err != nil {
+			return nil, err
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
@@ -0,0 +1,84 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nlAttr(attrType uint16, value []byte) []byte {
+	attrLen := sizeofNlAttr + len(value)
+
+	b := make([]byte, (attrLen+3)&^3)
+	binary.NativeEndian.PutUint16(b[0:], uint16(attrLen))
+	binary.NativeEndian.PutUint16(b[2:], attrType)
+	copy(b[sizeofNlAttr:], value)
+
+	return b
+}
+
+func nlUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.NativeEndian.PutUint32(b, v)
+
+	return b
+}
+
+// filterMessage builds a RTM_NEWTFILTER message payload (tcmsg and attributes).
+func filterMessage(handle uint32, info uint32, attrs ...[]byte) syscall.NetlinkMessage {
+	data := make([]byte, sizeofTcMsg)
+	binary.NativeEndian.PutUint32(data[8:], handle)
+	binary.NativeEndian.PutUint32(data[16:], info)
+	for _, attr := range attrs {
+		data = append(data, attr...)
+	}
+
+	return syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: syscall.RTM_NEWTFILTER},
+		Data:   data,
+	}
+}
+
+func TestParseTcFilters(t *testing.T) {
+	// TC_H_MAKE(prio << 16, htons(ETH_P_ALL))
+	info := uint32(49152)<<16 | uint32(ntohs(0x0003))
+
+	options := append(nlAttr(tcaBPFID, nlUint32(42)), nlAttr(tcaBPFName, []byte("tc.bpf.o:[tc]\x00"))...)
+	options = append(options, nlAttr(tcaBPFFlags, nlUint32(tcaBPFFlagActDirect))...)
+	options = append(options, nlAttr(tcaBPFTag, []byte{1, 2, 3, 4, 5, 6, 7, 8})...)
+
+	msgs := []syscall.NetlinkMessage{
+		// per priority chain entry, without options
+		filterMessage(0, info, nlAttr(tcaKind, []byte("bpf\x00"))),
+		filterMessage(1, info,
+			nlAttr(tcaKind, []byte("bpf\x00")),
+			nlAttr(tcaOptions|0x8000, options),
+		),
+		filterMessage(2, info,
+			nlAttr(tcaKind, []byte("u32\x00")),
+			nlAttr(tcaOptions, nlAttr(tcaBPFID, nlUint32(7))),
+		),
+	}
+
+	filters := parseTcFilters(msgs)
+	assert.Equal(t, []TcFilter{
+		{
+			Priority:     49152,
+			Handle:       1,
+			Protocol:     0x0003,
+			ProgID:       42,
+			Name:         "tc.bpf.o:[tc]",
+			Tag:          [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+			DirectAction: true,
+		},
+	}, filters)
+}
+
+func TestParseNetlinkAttrsTruncated(t *testing.T) {
+	b := append(nlAttr(1, []byte("ab")), 0xff, 0x00, 0x02, 0x00)
+
+	attrs := parseNetlinkAttrs(b)
+	assert.Equal(t, map[uint16][]byte{1: []byte("ab")}, attrs)
+}
@@ -115,7 +115,7 @@ func (hook *TcHook) Attach(tcOpts *TcOpts) error {
 
 	retC := C.bpf_tc_attach(hook.hook, optsC)
 	if retC < 0 {
-		return fmt.Errorf("failed to attach tc hook: %w", syscall.Errno(-retC))
+		return fmt.Errorf("failed to attach tc hook: %w", classifyError(opAttach, syscall.Errno(-retC), ""))
 	}
 
 	// update tcOpts with the values from the libbpf
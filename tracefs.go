@@ -0,0 +1,158 @@
+package libbpfgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//
+// Tracefs
+//
+// Tracepoints are looked up in tracefs, usually mounted at
+// /sys/kernel/tracing, or at /sys/kernel/debug/tracing with debugfs.
+// Hardened hosts mount it elsewhere: its other mount points are then found
+// in /proc/self/mountinfo, or it is set with SetTracefsPath():
+//
+//	libbpfgo.SetTracefsPath("/run/tracing")
+//
+// The mount point set is kept in the LIBBPFGO_TRACEFS_PATH environment
+// variable, which the helpers package reads too (trace pipe readers,
+// available filter functions): setting it with either package's
+// SetTracefsPath(), or in the environment of the process, sets it for both.
+//
+
+// defaultTracefsPaths are the usual mount points of tracefs, the only ones
+// libbpf looks up.
+var defaultTracefsPaths = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// tracefsPathEnv is the environment variable holding the mount point of
+// tracefs set with SetTracefsPath(), shared with the helpers package.
+const tracefsPathEnv = "LIBBPFGO_TRACEFS_PATH"
+
+var mountInfoPath = "/proc/self/mountinfo"
+
+// SetTracefsPath sets the mount point of tracefs, used to look tracepoints
+// up, instead of detecting it. An empty path restores the detection.
+//
+// libbpf creates legacy kprobes and uprobes (ProbeAttachModeLegacy) in
+// tracefs at /sys/kernel/tracing or /sys/kernel/debug/tracing only: they
+// fail when another mount point is set.
+func SetTracefsPath(path string) {
+	if path == "" {
+		_ = os.Unsetenv(tracefsPathEnv)
+		return
+	}
+	_ = os.Setenv(tracefsPathEnv, path)
+}
+
+// tracefsPathSet returns the mount point of tracefs set with
+// SetTracefsPath(), if any.
+func tracefsPathSet() (string, bool) {
+	path := os.Getenv(tracefsPathEnv)
+
+	return path, path != ""
+}
+
+// TracefsPath returns the mount point of tracefs: the one set with
+// SetTracefsPath(), or else the first of the usual and mounted ones that
+// has tracepoints.
+func TracefsPath() (string, error) {
+	for _, path := range tracefsPaths() {
+		if _, err := os.Stat(filepath.Join(path, "events")); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("tracefs not mounted: %w", fs.ErrNotExist)
+}
+
+// tracefsPaths returns the possible mount points of tracefs: the one set
+// only, or else the usual ones, and the ones of /proc/self/mountinfo.
+func tracefsPaths() []string {
+	if path, ok := tracefsPathSet(); ok {
+		return []string{path}
+	}
+
+	paths := slices.Clone(defaultTracefsPaths)
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return paths
+	}
+	defer f.Close()
+
+	for _, path := range parseTracefsMounts(f) {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// parseTracefsMounts returns the mount points of tracefs, and the tracing
+// directories of debugfs, of mountinfo lines as:
+//
+//	31 23 0:12 / /sys/kernel/tracing rw,nosuid shared:15 - tracefs tracefs rw
+//	32 23 0:7 / /sys/kernel/debug rw,nosuid shared:16 - debugfs debugfs rw
+func parseTracefsMounts(r io.Reader) []string {
+	var paths []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		sep := slices.Index(fields, "-")
+		if sep < 5 || len(fields) < sep+2 {
+			continue
+		}
+
+		switch mountPoint := unescapeMountPoint(fields[4]); fields[sep+1] {
+		case "tracefs":
+			paths = append(paths, mountPoint)
+		case "debugfs":
+			paths = append(paths, filepath.Join(mountPoint, "tracing"))
+		}
+	}
+
+	return paths
+}
+
+// unescapeMountPoint decodes the octal escapes (\040 for a space) of a
+// mountinfo mount point.
+func unescapeMountPoint(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+
+	return b.String()
+}
+
+// checkLegacyProbeTracefs fails for legacy probes if tracefs is set to a
+// mount point libbpf does not use.
+func checkLegacyProbeTracefs(mode ProbeAttachMode) error {
+	path, ok := tracefsPathSet()
+	if mode != ProbeAttachModeLegacy || !ok || slices.Contains(defaultTracefsPaths, filepath.Clean(path)) {
+		return nil
+	}
+
+	return fmt.Errorf("legacy probes need tracefs at %s, not %s", strings.Join(defaultTracefsPaths, " or "), path)
+}
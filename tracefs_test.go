@@ -0,0 +1,71 @@
+package libbpfgo
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTracefsMounts(t *testing.T) {
+	mountInfo := "22 28 0:21 / /sys rw,nosuid shared:7 - sysfs sysfs rw\n" +
+		"31 22 0:12 / /sys/kernel/tracing rw,nosuid shared:15 - tracefs tracefs rw\n" +
+		"32 22 0:7 / /run/debug rw,nosuid shared:16 - debugfs debugfs rw\n" +
+		"33 28 0:12 / /run/trace\\040fs rw shared:17 - tracefs tracefs rw\n" +
+		"invalid\n"
+
+	assert.Equal(t, []string{
+		"/sys/kernel/tracing",
+		"/run/debug/tracing",
+		"/run/trace fs",
+	}, parseTracefsMounts(strings.NewReader(mountInfo)))
+}
+
+func TestTracefsPath(t *testing.T) {
+	root := t.TempDir()
+	mounted := filepath.Join(root, "tracing")
+	require.NoError(t, os.MkdirAll(filepath.Join(mounted, "events"), 0o755))
+
+	mountInfoPath = filepath.Join(root, "mountinfo")
+	defer func() { mountInfoPath = "/proc/self/mountinfo" }()
+	require.NoError(t, os.WriteFile(mountInfoPath, []byte("31 22 0:12 / "+mounted+" rw shared:15 - tracefs tracefs rw\n"), 0o644))
+
+	// Detected from the mounts, after the usual mount points
+	paths := tracefsPaths()
+	assert.Equal(t, defaultTracefsPaths, paths[:len(defaultTracefsPaths)])
+	assert.Contains(t, paths, mounted)
+
+	// The set mount point only
+	SetTracefsPath(filepath.Join(root, "missing"))
+	defer SetTracefsPath("")
+	assert.Equal(t, []string{filepath.Join(root, "missing")}, tracefsPaths())
+	_, err := TracefsPath()
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	SetTracefsPath(mounted)
+	path, err := TracefsPath()
+	require.NoError(t, err)
+	assert.Equal(t, mounted, path)
+
+	// Set in the environment, as by the helpers package
+	SetTracefsPath("")
+	t.Setenv(tracefsPathEnv, filepath.Join(root, "missing"))
+	assert.Equal(t, []string{filepath.Join(root, "missing")}, tracefsPaths())
+}
+
+func TestCheckLegacyProbeTracefs(t *testing.T) {
+	defer SetTracefsPath("")
+
+	assert.NoError(t, checkLegacyProbeTracefs(ProbeAttachModeLegacy))
+
+	SetTracefsPath("/sys/kernel/debug/tracing/")
+	assert.NoError(t, checkLegacyProbeTracefs(ProbeAttachModeLegacy))
+
+	SetTracefsPath("/run/tracing")
+	assert.NoError(t, checkLegacyProbeTracefs(ProbeAttachModeDefault))
+	assert.ErrorContains(t, checkLegacyProbeTracefs(ProbeAttachModeLegacy), "not /run/tracing")
+}
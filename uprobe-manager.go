@@ -0,0 +1,355 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+//
+// UprobeManager
+//
+// Uprobes attached with a pid only trace that process, and uprobes attached
+// without one trace every process mapping the binary, even those the user is
+// not interested in. The UprobeManager attaches per process to all the
+// running processes whose executable matches a pattern, and follows the
+// process events of the kernel proc connector to attach to new matching
+// processes (on fork and exec) and destroy the links of the exited ones.
+//
+// The proc connector requires CAP_NET_ADMIN.
+//
+
+// UprobeAttachFunc attaches a program to a process, for example
+// BPFProg.AttachUprobe or BPFProg.AttachURetprobe.
+type UprobeAttachFunc func(pid int, path string, offset uint32) (*BPFLink, error)
+
+// UprobeNotifyFunc is called by the UprobeManager after an attempt to attach
+// to a new process.
+type UprobeNotifyFunc func(pid int, link *BPFLink, err error)
+
+// Proc connector (linux/connector.h, linux/cn_proc.h)
+const (
+	netlinkConnector  = 11 // NETLINK_CONNECTOR
+	cnIdxProc         = 1  // CN_IDX_PROC
+	cnValProc         = 1  // CN_VAL_PROC
+	procCnMcastListen = 1  // PROC_CN_MCAST_LISTEN
+
+	procEventFork = 0x00000001
+	procEventExec = 0x00000002
+	procEventExit = 0x80000000
+
+	cnMsgLen     = 20 // sizeof(struct cn_msg)
+	procEventLen = 16 // offset of proc_event.event_data
+)
+
+type UprobeManager struct {
+	attach  UprobeAttachFunc
+	notify  UprobeNotifyFunc
+	pattern string
+	offset  uint32
+	fd      int
+	waker   *pollWaker
+	procs   map[int]*BPFLink
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+}
+
+// NewUprobeManager creates an UprobeManager that uses attach to attach at
+// offset in the executable of the processes matching pattern. The pattern is
+// matched with filepath.Match() against the executable path, or against its
+// base name if the pattern has no slash ("nginx", "/usr/*/nginx"). The
+// running processes are attached to before returning; failures are reported
+// to notify, if not nil, which is also called from the manager goroutine
+// for the processes started afterwards.
+func NewUprobeManager(pattern string, offset uint32, attach UprobeAttachFunc, notify UprobeNotifyFunc) (*UprobeManager, error) {
+	if attach == nil {
+		return nil, fmt.Errorf("failed to create uprobe manager: nil attach function")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("failed to create uprobe manager: invalid pattern %s: %w", pattern, err)
+	}
+
+	fd, err := newProcConnector()
+	if err != nil {
+		return nil, err
+	}
+
+	waker, err := newPollWaker(fd)
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	m := &UprobeManager{
+		attach:  attach,
+		notify:  notify,
+		pattern: pattern,
+		offset:  offset,
+		fd:      fd,
+		waker:   waker,
+		procs:   make(map[int]*BPFLink),
+	}
+
+	// Listen before scanning, so processes started meanwhile are not missed
+	m.wg.Add(1)
+	go m.run()
+
+	m.scan()
+
+	return m, nil
+}
+
+// newProcConnector opens a netlink socket subscribed to the process events.
+func newProcConnector() (int, error) {
+	fd, err := syscall.Socket(
+		syscall.AF_NETLINK,
+		syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK,
+		netlinkConnector,
+	)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create proc connector socket: %w", err)
+	}
+
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc}
+	if err := syscall.Bind(fd, sa); err != nil {
+		_ = syscall.Close(fd)
+		return -1, fmt.Errorf("failed to bind proc connector socket: %w", err)
+	}
+
+	// nlmsghdr + cn_msg + PROC_CN_MCAST_LISTEN
+	msg := make([]byte, syscall.NLMSG_HDRLEN+cnMsgLen+4)
+	binary.NativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+	binary.NativeEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+	cn := msg[syscall.NLMSG_HDRLEN:]
+	binary.NativeEndian.PutUint32(cn[0:], cnIdxProc)
+	binary.NativeEndian.PutUint32(cn[4:], cnValProc)
+	binary.NativeEndian.PutUint16(cn[16:], 4)
+	binary.NativeEndian.PutUint32(cn[cnMsgLen:], procCnMcastListen)
+
+	if err := syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		_ = syscall.Close(fd)
+		return -1, fmt.Errorf("failed to subscribe to process events: %w", err)
+	}
+
+	return fd, nil
+}
+
+// Links returns the links of the attached processes, by pid.
+func (m *UprobeManager) Links() map[int]*BPFLink {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	links := make(map[int]*BPFLink, len(m.procs))
+	for pid, link := range m.procs {
+		links[pid] = link
+	}
+
+	return links
+}
+
+// Close stops the manager and destroys the links it created.
+func (m *UprobeManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	_ = m.waker.wake()
+	m.wg.Wait()
+	m.waker.close()
+	_ = syscall.Close(m.fd)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for pid, link := range m.procs {
+		if err := link.Destroy(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to destroy uprobe link of pid %d: %w", pid, err))
+		}
+	}
+	m.procs = nil
+
+	return errors.Join(errs...)
+}
+
+// scan attaches to the running processes.
+func (m *UprobeManager) scan() {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		m.handleStart(pid)
+	}
+}
+
+// handleStart attaches to the process if its executable matches.
+func (m *UprobeManager) handleStart(pid int) {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil || !matchExecutable(m.pattern, exe) {
+		// A process doing exec() of another binary is not traced anymore
+		m.handleExit(pid)
+		return
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	if _, ok := m.procs[pid]; ok {
+		// Already attached, the uprobe is bound to the binary and survives
+		// exec() of the same binary
+		m.mu.Unlock()
+		return
+	}
+
+	// Going through /proc/<pid>/exe resolves the binary from the process
+	// mount namespace, and works for deleted binaries
+	link, err := m.attach(pid, fmt.Sprintf("/proc/%d/exe", pid), m.offset)
+	if err == nil {
+		m.procs[pid] = link
+	}
+	m.mu.Unlock()
+
+	if m.notify != nil {
+		m.notify(pid, link, err)
+	}
+}
+
+// handleExit destroys the link of the process.
+func (m *UprobeManager) handleExit(pid int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if link, ok := m.procs[pid]; ok {
+		_ = link.Destroy()
+		delete(m.procs, pid)
+	}
+}
+
+func (m *UprobeManager) run() {
+	defer m.wg.Done()
+
+	buf := make([]byte, syscall.Getpagesize())
+
+	for {
+		ready, err := m.waker.wait()
+		if err != nil || !ready {
+			return
+		}
+
+		for {
+			n, _, err := syscall.Recvfrom(m.fd, buf, 0)
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				break
+			}
+			if err == syscall.ENOBUFS {
+				// Events were dropped, look for new processes
+				m.scan()
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			for _, event := range parseProcEvents(buf[:n]) {
+				switch event.what {
+				case procEventFork, procEventExec:
+					m.handleStart(event.pid)
+				case procEventExit:
+					m.handleExit(event.pid)
+				}
+			}
+		}
+	}
+}
+
+// matchExecutable reports whether the executable path matches the pattern,
+// or its base name if the pattern has no slash.
+func matchExecutable(pattern, exe string) bool {
+	exe = strings.TrimSuffix(exe, " (deleted)")
+	if !strings.Contains(pattern, "/") {
+		exe = filepath.Base(exe)
+	}
+
+	ok, _ := filepath.Match(pattern, exe)
+
+	return ok
+}
+
+//
+// Proc connector events
+//
+
+type procEvent struct {
+	what uint32
+	pid  int // tgid of the new, exec'ing or exiting process
+}
+
+// parseProcEvents parses the fork, exec and exit events of processes in a
+// proc connector datagram. Thread events and malformed messages are
+// skipped.
+func parseProcEvents(buf []byte) []procEvent {
+	msgs, err := syscall.ParseNetlinkMessage(buf)
+	if err != nil {
+		return nil
+	}
+
+	var events []procEvent
+	for _, msg := range msgs {
+		if len(msg.Data) < cnMsgLen+procEventLen+8 {
+			continue
+		}
+		if binary.NativeEndian.Uint32(msg.Data[0:]) != cnIdxProc ||
+			binary.NativeEndian.Uint32(msg.Data[4:]) != cnValProc {
+			continue
+		}
+
+		ev := msg.Data[cnMsgLen:]
+		what := binary.NativeEndian.Uint32(ev[0:])
+		data := ev[procEventLen:]
+
+		var pid, tgid uint32
+		switch what {
+		case procEventFork:
+			if len(data) < 16 {
+				continue
+			}
+			// child_pid, child_tgid
+			pid = binary.NativeEndian.Uint32(data[8:])
+			tgid = binary.NativeEndian.Uint32(data[12:])
+		case procEventExec, procEventExit:
+			// process_pid, process_tgid
+			pid = binary.NativeEndian.Uint32(data[0:])
+			tgid = binary.NativeEndian.Uint32(data[4:])
+		default:
+			continue
+		}
+
+		if pid != tgid {
+			continue // thread
+		}
+
+		events = append(events, procEvent{what: what, pid: int(tgid)})
+	}
+
+	return events
+}
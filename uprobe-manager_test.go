@@ -0,0 +1,73 @@
+package libbpfgo
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchExecutable(t *testing.T) {
+	tests := []struct {
+		pattern string
+		exe     string
+		want    bool
+	}{
+		{"nginx", "/usr/sbin/nginx", true},
+		{"nginx", "/usr/sbin/nginx (deleted)", true},
+		{"nginx", "/usr/sbin/nginx-debug", false},
+		{"python3*", "/usr/bin/python3.11", true},
+		{"/usr/*/nginx", "/usr/sbin/nginx", true},
+		{"/usr/*/nginx", "/opt/nginx", false},
+		{"/usr/sbin/nginx", "/usr/sbin/nginx", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchExecutable(tt.pattern, tt.exe), "%s %s", tt.pattern, tt.exe)
+	}
+}
+
+// procEventMsg builds a proc connector netlink message.
+func procEventMsg(what uint32, data ...uint32) []byte {
+	msg := make([]byte, syscall.NLMSG_HDRLEN+cnMsgLen+procEventLen+4*len(data))
+	binary.NativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+
+	cn := msg[syscall.NLMSG_HDRLEN:]
+	binary.NativeEndian.PutUint32(cn[0:], cnIdxProc)
+	binary.NativeEndian.PutUint32(cn[4:], cnValProc)
+	binary.NativeEndian.PutUint16(cn[16:], uint16(procEventLen+4*len(data)))
+
+	ev := cn[cnMsgLen:]
+	binary.NativeEndian.PutUint32(ev[0:], what)
+	for i, d := range data {
+		binary.NativeEndian.PutUint32(ev[procEventLen+4*i:], d)
+	}
+
+	return msg
+}
+
+func TestParseProcEvents(t *testing.T) {
+	var buf []byte
+	buf = append(buf, procEventMsg(procEventFork, 100, 100, 200, 200)...)       // process fork
+	buf = append(buf, procEventMsg(procEventFork, 100, 100, 201, 100)...)       // thread creation
+	buf = append(buf, procEventMsg(procEventExec, 200, 200)...)                 // exec
+	buf = append(buf, procEventMsg(0x00000004, 200, 200, 0, 0)...)              // uid change
+	buf = append(buf, procEventMsg(procEventExit, 201, 100, 0, 0, 100, 100)...) // thread exit
+	buf = append(buf, procEventMsg(procEventExit, 200, 200, 0, 0, 100, 100)...) // process exit
+
+	assert.Equal(t, []procEvent{
+		{what: procEventFork, pid: 200},
+		{what: procEventExec, pid: 200},
+		{what: procEventExit, pid: 200},
+	}, parseProcEvents(buf))
+}
+
+func TestParseProcEventsMalformed(t *testing.T) {
+	assert.Empty(t, parseProcEvents(nil))
+	assert.Empty(t, parseProcEvents([]byte{1, 2, 3}))
+
+	// Truncated fork event
+	assert.Empty(t, parseProcEvents(procEventMsg(procEventFork, 100, 100)))
+}
@@ -0,0 +1,91 @@
+package libbpfgo
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+//
+// USDT specs
+//
+// libbpf describes each USDT probe site (its arguments, and cookie) with a
+// spec, kept in the __bpf_usdt_specs map that usdt.bpf.h adds to the object,
+// and the sites by address in the __bpf_usdt_ip_to_spec_id map. Their size is
+// fixed at build time by BPF_USDT_MAX_SPEC_CNT (256 specs, and 4 times as
+// many addresses): attaching to more sites fails with E2BIG. SetUSDTMaxSpecs()
+// resizes them before the object is loaded, so that heavy USDT users don't
+// need to rebuild their BPF code:
+//
+//	m.SetUSDTMaxSpecs(4096)
+//	m.BPFLoadObject()
+//
+// Identical specs of several sites are shared, and the specs of a destroyed
+// USDT link are reused.
+//
+
+const (
+	usdtSpecsMap      = "__bpf_usdt_specs"
+	usdtIPToSpecIDMap = "__bpf_usdt_ip_to_spec_id"
+
+	// usdtIPsPerSpec is the ratio of BPF_USDT_MAX_IP_CNT to
+	// BPF_USDT_MAX_SPEC_CNT of usdt.bpf.h.
+	usdtIPsPerSpec = 4
+)
+
+// USDTMaxSpecs returns the number of USDT specs the object can hold, the
+// size of its __bpf_usdt_specs map. The object must use libbpf's usdt.bpf.h.
+func (m *Module) USDTMaxSpecs() (uint32, error) {
+	specs, err := m.GetMap(usdtSpecsMap)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get usdt max specs: object does not use usdt.bpf.h: %w", err)
+	}
+
+	return specs.MaxEntries(), nil
+}
+
+// SetUSDTMaxSpecs sets the number of USDT specs the object can hold, and
+// the number of USDT probe sites to 4 times as many, in place of
+// BPF_USDT_MAX_SPEC_CNT. It must be called before the object is loaded.
+func (m *Module) SetUSDTMaxSpecs(maxSpecs uint32) error {
+	if m.loaded {
+		return fmt.Errorf("failed to set usdt max specs: module already loaded")
+	}
+	if maxSpecs == 0 {
+		return fmt.Errorf("failed to set usdt max specs to 0: %w", syscall.EINVAL)
+	}
+
+	specs, err := m.GetMap(usdtSpecsMap)
+	if err != nil {
+		return fmt.Errorf("failed to set usdt max specs: object does not use usdt.bpf.h: %w", err)
+	}
+	if err := specs.SetMaxEntries(maxSpecs); err != nil {
+		return fmt.Errorf("failed to set usdt max specs: %w", err)
+	}
+
+	// Only used without BPF cookies (before v5.15), to find the spec of a
+	// site by address
+	ipToSpecID, err := m.GetMap(usdtIPToSpecIDMap)
+	if err != nil {
+		return nil
+	}
+	if err := ipToSpecID.SetMaxEntries(maxSpecs * usdtIPsPerSpec); err != nil {
+		return fmt.Errorf("failed to set usdt max specs: %w", err)
+	}
+
+	return nil
+}
+
+// usdtError explains the E2BIG failure of a USDT attachment, out of specs.
+func usdtError(m *Module, err error) error {
+	if !errors.Is(err, syscall.E2BIG) {
+		return err
+	}
+
+	maxSpecs, specsErr := m.USDTMaxSpecs()
+	if specsErr != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: out of USDT specs, the object holds %d, raise BPF_USDT_MAX_SPEC_CNT or call Module.SetUSDTMaxSpecs() before loading it", err, maxSpecs)
+}
@@ -0,0 +1,88 @@
+package libbpfgo
+
+import (
+	"unsafe"
+)
+
+//
+// User data
+//
+// Programs, maps and links carry an optional Go value set by the application,
+// like libbpf's former bpf_map__set_priv(), so that frameworks layering on
+// libbpfgo can find their own state from a handle instead of maintaining
+// lookup tables keyed by name:
+//
+//	prog.SetUserData(&probeState{...})
+//	...
+//	state := prog.UserData().(*probeState)
+//
+// The handles returned by GetProgram() and GetMap() are created on each call,
+// so the data is kept by the module, per underlying libbpf object, and is
+// shared by all the handles of a program or map. It is dropped when the
+// module is closed, or for a link, when it is destroyed.
+//
+
+// setUserData sets the user data of the object, or removes it if nil.
+func (m *Module) setUserData(key unsafe.Pointer, data any) {
+	m.userDataMu.Lock()
+	defer m.userDataMu.Unlock()
+
+	if data == nil {
+		delete(m.userData, key)
+		return
+	}
+	if m.userData == nil {
+		m.userData = make(map[unsafe.Pointer]any)
+	}
+	m.userData[key] = data
+}
+
+// getUserData returns the user data of the object, or nil.
+func (m *Module) getUserData(key unsafe.Pointer) any {
+	m.userDataMu.Lock()
+	defer m.userDataMu.Unlock()
+
+	return m.userData[key]
+}
+
+// clearUserData drops the user data of all the objects of the module.
+func (m *Module) clearUserData() {
+	m.userDataMu.Lock()
+	defer m.userDataMu.Unlock()
+
+	m.userData = nil
+}
+
+// SetUserData attaches a Go value to the program, retrieved with UserData()
+// from any handle of the program, or removes it if nil. It is safe for
+// concurrent use.
+func (p *BPFProg) SetUserData(data any) {
+	p.module.setUserData(unsafe.Pointer(p.prog), data)
+}
+
+// UserData returns the value set with SetUserData(), or nil.
+func (p *BPFProg) UserData() any {
+	return p.module.getUserData(unsafe.Pointer(p.prog))
+}
+
+// SetUserData attaches a Go value to the map, retrieved with UserData() from
+// any handle of the map, or removes it if nil. It is safe for concurrent use.
+func (m *BPFMap) SetUserData(data any) {
+	m.module.setUserData(unsafe.Pointer(m.bpfMap), data)
+}
+
+// UserData returns the value set with SetUserData(), or nil.
+func (m *BPFMap) UserData() any {
+	return m.module.getUserData(unsafe.Pointer(m.bpfMap))
+}
+
+// SetUserData attaches a Go value to the link, retrieved with UserData(), or
+// removes it if nil. It is safe for concurrent use.
+func (l *BPFLink) SetUserData(data any) {
+	l.module().setUserData(unsafe.Pointer(l), data)
+}
+
+// UserData returns the value set with SetUserData(), or nil.
+func (l *BPFLink) UserData() any {
+	return l.module().getUserData(unsafe.Pointer(l))
+}
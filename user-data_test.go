@@ -0,0 +1,65 @@
+package libbpfgo
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserData(t *testing.T) {
+	m := &Module{}
+	a, b := new(int), new(int)
+
+	assert.Nil(t, m.getUserData(unsafe.Pointer(a)))
+
+	m.setUserData(unsafe.Pointer(a), "a")
+	m.setUserData(unsafe.Pointer(b), 42)
+	assert.Equal(t, "a", m.getUserData(unsafe.Pointer(a)))
+	assert.Equal(t, 42, m.getUserData(unsafe.Pointer(b)))
+
+	// nil removes the data
+	m.setUserData(unsafe.Pointer(a), nil)
+	assert.Nil(t, m.getUserData(unsafe.Pointer(a)))
+	assert.Equal(t, 42, m.getUserData(unsafe.Pointer(b)))
+
+	m.clearUserData()
+	assert.Nil(t, m.getUserData(unsafe.Pointer(b)))
+}
+
+func TestLinkUserData(t *testing.T) {
+	m := &Module{}
+	prog := &BPFProg{module: m}
+	l1 := &BPFLink{prog: prog}
+	l2 := &BPFLink{prog: prog}
+
+	// Each link has its own data
+	l1.SetUserData("first")
+	assert.Equal(t, "first", l1.UserData())
+	assert.Nil(t, l2.UserData())
+
+	l1.SetUserData(nil)
+	assert.Nil(t, l1.UserData())
+}
+
+func TestUserDataConcurrent(t *testing.T) {
+	m := &Module{}
+	keys := make([]*int, 8)
+	for i := range keys {
+		keys[i] = new(int)
+	}
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key unsafe.Pointer) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.setUserData(key, i)
+				assert.Equal(t, i, m.getUserData(key))
+			}
+		}(i, unsafe.Pointer(key))
+	}
+	wg.Wait()
+}
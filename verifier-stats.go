@@ -0,0 +1,68 @@
+package libbpfgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// Verifier statistics
+//
+// The kernel does not keep the cost of verifying a program: besides the
+// instructions processed (BPFProgInfo.VerifiedInsns), the statistics are only
+// printed at the end of the verifier log. Modules created with
+// NewModuleArgs.VerifierStats record them from the log of each program while
+// loading the object (see load-progress.go), and BPFProg.Info() returns them,
+// so that the verifier cost of BPF code can be tracked across releases.
+//
+
+// VerifierStats are the statistics of the verification of a program.
+type VerifierStats struct {
+	// VerificationTime is only reported with the BPF_LOG_STATS log level
+	// (v5.2+), which programs get unless they have a log level of their own.
+	VerificationTime time.Duration
+	// StackDepth is the stack depth of each subprogram, main program first.
+	// Only reported with the BPF_LOG_STATS log level.
+	StackDepth       []uint32
+	InsnsProcessed   uint32
+	InsnsLimit       uint32
+	MaxStatesPerInsn uint32
+	TotalStates      uint32
+	PeakStates       uint32
+	MarkRead         uint32
+}
+
+// parseVerifierStats parses the statistics at the end of a verifier log. It
+// returns false if the log has no statistics.
+func parseVerifierStats(log string) (VerifierStats, bool) {
+	var stats VerifierStats
+	found := false
+
+	for _, line := range strings.Split(log, "\n") {
+		switch {
+		case strings.HasPrefix(line, "verification time "):
+			var usec uint64
+			if _, err := fmt.Sscanf(line, "verification time %d usec", &usec); err == nil {
+				stats.VerificationTime = time.Duration(usec) * time.Microsecond
+			}
+		case strings.HasPrefix(line, "stack depth "):
+			stats.StackDepth = stats.StackDepth[:0]
+			for _, depth := range strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "stack depth ")), "+") {
+				n, err := strconv.ParseUint(depth, 10, 32)
+				if err != nil {
+					stats.StackDepth = nil
+					break
+				}
+				stats.StackDepth = append(stats.StackDepth, uint32(n))
+			}
+		case strings.HasPrefix(line, "processed "):
+			_, err := fmt.Sscanf(line, "processed %d insns (limit %d) max_states_per_insn %d total_states %d peak_states %d mark_read %d",
+				&stats.InsnsProcessed, &stats.InsnsLimit, &stats.MaxStatesPerInsn, &stats.TotalStates, &stats.PeakStates, &stats.MarkRead)
+			found = err == nil
+		}
+	}
+
+	return stats, found
+}
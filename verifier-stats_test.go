@@ -0,0 +1,39 @@
+package libbpfgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVerifierStats(t *testing.T) {
+	log := "func#0 @0\n" +
+		"0: R1=ctx() R10=fp0\n" +
+		"verification time 1432 usec\n" +
+		"stack depth 64+16\n" +
+		"processed 1234 insns (limit 1000000) max_states_per_insn 4 total_states 87 peak_states 80 mark_read 12\n"
+
+	stats, ok := parseVerifierStats(log)
+	assert.True(t, ok)
+	assert.Equal(t, VerifierStats{
+		VerificationTime: 1432 * time.Microsecond,
+		StackDepth:       []uint32{64, 16},
+		InsnsProcessed:   1234,
+		InsnsLimit:       1000000,
+		MaxStatesPerInsn: 4,
+		TotalStates:      87,
+		PeakStates:       80,
+		MarkRead:         12,
+	}, stats)
+
+	// Without BPF_LOG_STATS, only the processed line is printed
+	stats, ok = parseVerifierStats("processed 2 insns (limit 1000000) max_states_per_insn 0 total_states 0 peak_states 0 mark_read 0\n")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2), stats.InsnsProcessed)
+	assert.Zero(t, stats.VerificationTime)
+	assert.Nil(t, stats.StackDepth)
+
+	_, ok = parseVerifierStats("0: R1=ctx() R10=fp0\ninvalid mem access 'scalar'\n")
+	assert.False(t, ok)
+}